@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ChunkKey is the key of a chunk in the object store: <userID>/<fingerprint>:<from>:<through>:<checksum>, all in hex.
+type ChunkKey struct {
+	UserID      string
+	Fingerprint uint64
+	From        int64
+	Through     int64
+	Checksum    uint32
+}
+
+// ParseChunkKey parses the key of a chunk from its file name, which is the last part of its key when downloaded from
+// an object store, or its base64 encoded key in the filesystem store.
+func ParseChunkKey(filename string) (ChunkKey, bool) {
+	name := filepath.Base(filename)
+	if decoded, err := base64.StdEncoding.DecodeString(name); err == nil {
+		name = string(decoded)
+	}
+
+	var key ChunkKey
+	if ix := strings.LastIndex(name, "/"); ix >= 0 {
+		key.UserID, name = name[:ix], name[ix+1:]
+	}
+
+	parts := strings.Split(name, ":")
+	if len(parts) != 4 {
+		return ChunkKey{}, false
+	}
+	fingerprint, err1 := strconv.ParseUint(parts[0], 16, 64)
+	from, err2 := strconv.ParseInt(parts[1], 16, 64)
+	through, err3 := strconv.ParseInt(parts[2], 16, 64)
+	checksum, err4 := strconv.ParseUint(parts[3], 16, 32)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		return ChunkKey{}, false
+	}
+
+	key.Fingerprint, key.From, key.Through, key.Checksum = fingerprint, from, through, uint32(checksum)
+	return key, true
+}
@@ -65,6 +65,7 @@ type LokiBlock struct {
 	entries          []LokiEntry
 	storedChecksum   uint32
 	computedChecksum uint32
+	err              error // error decompressing or parsing rawData
 }
 
 type LokiEntry struct {
@@ -79,6 +80,10 @@ func parseLokiChunk(chunkHeader *ChunkHeader, r io.Reader) (*LokiChunk, error) {
 		return nil, fmt.Errorf("failed to read rawData for Loki chunk into memory: %w", err)
 	}
 
+	if len(data) < 6+8+4 {
+		return nil, fmt.Errorf("chunk data too short: %d bytes", len(data))
+	}
+
 	if num := binary.BigEndian.Uint32(data[0:4]); num != 0x012EE56A {
 		return nil, fmt.Errorf("invalid magic number: %0x", num)
 	}
@@ -91,6 +96,9 @@ func parseLokiChunk(chunkHeader *ChunkHeader, r io.Reader) (*LokiChunk, error) {
 	// return &LokiChunk{encoding: compression}, nil
 
 	metasOffset := binary.BigEndian.Uint64(data[len(data)-8:])
+	if metasOffset > uint64(len(data)-(8+4)) {
+		return nil, fmt.Errorf("invalid blocks metadata offset: %d, data length: %d", metasOffset, len(data))
+	}
 
 	metadata := data[metasOffset : len(data)-(8+4)]
 
@@ -121,11 +129,14 @@ func parseLokiChunk(chunkHeader *ChunkHeader, r io.Reader) (*LokiChunk, error) {
 		if err != nil {
 			return nil, err
 		}
+		if block.dataOffset+dataLength+4 > metasOffset {
+			return nil, fmt.Errorf("block %d out of data: offset %d, length %d, blocks metadata offset %d", ix, block.dataOffset, dataLength, metasOffset)
+		}
 
 		block.rawData = data[block.dataOffset : block.dataOffset+dataLength]
 		block.storedChecksum = binary.BigEndian.Uint32(data[block.dataOffset+dataLength : block.dataOffset+dataLength+4])
 		block.computedChecksum = crc32.Checksum(block.rawData, castagnoliTable)
-		block.originalData, block.entries, err = parseLokiBlock(compression, block.rawData)
+		block.originalData, block.entries, block.err = parseLokiBlock(compression, block.rawData)
 		lokiChunk.blocks = append(lokiChunk.blocks, block)
 	}
 
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"flag"
 	"fmt"
+	"hash/crc32"
 	"io/ioutil"
 	"log"
 	"os"
@@ -21,33 +23,55 @@ func main() {
 	storeBlocks := flag.Bool("s", false, "store blocks, using input filename, and appending block index to it")
 	flag.Parse()
 
+	valid := true
 	for _, f := range flag.Args() {
-		printFile(f, *blocks, *lines, *storeBlocks)
+		if !printFile(f, *blocks, *lines, *storeBlocks) {
+			valid = false
+		}
+	}
+	// for scripts checking chunks to tell the corrupted ones.
+	if !valid {
+		os.Exit(1)
 	}
 }
 
-func printFile(filename string, blockDetails, printLines, storeBlocks bool) {
-	f, err := os.Open(filename)
-	if err != nil {
-		log.Printf("%s: %v", filename, err)
-		return
+// readFile reads the whole chunks file, or the standard input for "-" so that chunks can be piped from the object
+// store clients.
+func readFile(filename string) ([]byte, error) {
+	if filename == "-" {
+		return ioutil.ReadAll(os.Stdin)
 	}
-	defer f.Close()
+	return ioutil.ReadFile(filename)
+}
 
-	si, err := f.Stat()
+// printFile prints the chunks file and returns whether it is valid: it could be parsed, and all its checksums are OK.
+func printFile(filename string, blockDetails, printLines, storeBlocks bool) bool {
+	data, err := readFile(filename)
 	if err != nil {
-		log.Println("failed to stat file", err)
-		return
+		log.Printf("%s: %v", filename, err)
+		return false
 	}
 
-	h, err := DecodeHeader(f)
+	h, err := DecodeHeader(bytes.NewReader(data))
 	if err != nil {
 		log.Printf("%s: %v", filename, err)
-		return
+		return false
 	}
 
+	valid := true
 	fmt.Println()
 	fmt.Println("Chunks file:", filename)
+	if key, ok := ParseChunkKey(filename); ok {
+		// the checksum of the chunk key is the checksum of the whole chunks file.
+		computed := crc32.Checksum(data, castagnoliTable)
+		fmt.Print("Chunk key checksum: ", fmt.Sprintf("%08x", key.Checksum))
+		if key.Checksum == computed {
+			fmt.Println(" OK")
+		} else {
+			fmt.Println(" BAD, computed checksum:", fmt.Sprintf("%08x", computed))
+			valid = false
+		}
+	}
 	fmt.Println("Metadata length:", h.MetadataLength)
 	fmt.Println("Data length:", h.DataLength)
 	fmt.Println("UserID:", h.UserID)
@@ -60,10 +84,11 @@ func printFile(filename string, blockDetails, printLines, storeBlocks bool) {
 		fmt.Println("\t", l.Name, "=", l.Value)
 	}
 
-	lokiChunk, err := parseLokiChunk(h, f)
+	// the header is followed by the Loki chunk.
+	lokiChunk, err := parseLokiChunk(h, bytes.NewReader(data[h.MetadataLength+4:]))
 	if err != nil {
 		log.Printf("%s: %v", filename, err)
-		return
+		return false
 	}
 
 	fmt.Println("Encoding:", lokiChunk.encoding)
@@ -72,6 +97,7 @@ func printFile(filename string, blockDetails, printLines, storeBlocks bool) {
 		fmt.Println(" OK")
 	} else {
 		fmt.Println(" BAD, computed checksum:", fmt.Sprintf("%08x", lokiChunk.computedMetadataChecksum))
+		valid = false
 	}
 	if blockDetails {
 		fmt.Println("Found", len(lokiChunk.blocks), "block(s)")
@@ -90,6 +116,10 @@ func printFile(filename string, blockDetails, printLines, storeBlocks bool) {
 	totalSize := 0
 
 	for ix, b := range lokiChunk.blocks {
+		if b.storedChecksum != b.computedChecksum || b.err != nil || uint64(len(b.entries)) != b.numEntries {
+			valid = false
+		}
+
 		if blockDetails {
 			cksum := ""
 			if b.storedChecksum == b.computedChecksum {
@@ -97,11 +127,16 @@ func printFile(filename string, blockDetails, printLines, storeBlocks bool) {
 			} else {
 				cksum = fmt.Sprintf("%08x BAD (computed: %08x)", b.storedChecksum, b.computedChecksum)
 			}
-			fmt.Printf("Block %4d: position: %8d, original length: %6d (stored: %6d, ratio: %.2f), minT: %v maxT: %v, checksum: %s\n",
-				ix, b.dataOffset, len(b.originalData), len(b.rawData), float64(len(b.originalData))/float64(len(b.rawData)),
+			fmt.Printf("Block %4d: position: %8d, original length: %6d (stored: %6d, ratio: %.2f), entries: %6d, minT: %v maxT: %v, checksum: %s\n",
+				ix, b.dataOffset, len(b.originalData), len(b.rawData), float64(len(b.originalData))/float64(len(b.rawData)), b.numEntries,
 				time.Unix(0, b.minT).In(timezone).Format(format), time.Unix(0, b.maxT).In(timezone).Format(format),
 				cksum)
 			fmt.Printf("Block %4d: digest compressed: %02x, original: %02x\n", ix, sha256.Sum256(b.rawData), sha256.Sum256(b.originalData))
+			if b.err != nil {
+				fmt.Printf("Block %4d: BAD, failed to read entries: %v\n", ix, b.err)
+			} else if uint64(len(b.entries)) != b.numEntries {
+				fmt.Printf("Block %4d: BAD, found %d entries\n", ix, len(b.entries))
+			}
 		}
 
 		totalSize += len(b.originalData)
@@ -113,12 +148,20 @@ func printFile(filename string, blockDetails, printLines, storeBlocks bool) {
 		}
 
 		if storeBlocks {
-			writeBlockToFile(b.rawData, ix, fmt.Sprintf("%s.block.%d", filename, ix))
-			writeBlockToFile(b.originalData, ix, fmt.Sprintf("%s.original.%d", filename, ix))
+			name := filename
+			if name == "-" {
+				name = "stdin"
+			}
+			writeBlockToFile(b.rawData, ix, fmt.Sprintf("%s.block.%d", name, ix))
+			writeBlockToFile(b.originalData, ix, fmt.Sprintf("%s.original.%d", name, ix))
 		}
 	}
 
-	fmt.Println("Total size of original data:", totalSize, "file size:", si.Size(), "ratio:", fmt.Sprintf("%0.3g", float64(totalSize)/float64(si.Size())))
+	fmt.Println("Total size of original data:", totalSize, "file size:", len(data), "ratio:", fmt.Sprintf("%0.3g", float64(totalSize)/float64(len(data))))
+	if !valid {
+		fmt.Println("Chunk is corrupted, see the BAD checksums above or use -b to show the block details")
+	}
+	return valid
 }
 
 func writeBlockToFile(data []byte, blockIndex int, filename string) {
@@ -13,17 +13,24 @@ import (
 
 	_ "github.com/famarks/loki/pkg/build"
 	"github.com/famarks/loki/pkg/logcli/client"
+	"github.com/famarks/loki/pkg/logcli/deletequery"
+	"github.com/famarks/loki/pkg/logcli/fields"
 	"github.com/famarks/loki/pkg/logcli/labelquery"
 	"github.com/famarks/loki/pkg/logcli/output"
+	"github.com/famarks/loki/pkg/logcli/push"
 	"github.com/famarks/loki/pkg/logcli/query"
 	"github.com/famarks/loki/pkg/logcli/seriesquery"
+	"github.com/famarks/loki/pkg/logcli/volume"
 )
 
+const defaultAddress = "http://localhost:3100"
+
 var (
 	app        = kingpin.New("logcli", "A command-line for loki.").Version(version.Print("logcli"))
 	quiet      = app.Flag("quiet", "Suppress query metadata").Default("false").Short('q').Bool()
 	statistics = app.Flag("stats", "Show query statistics").Default("false").Bool()
-	outputMode = app.Flag("output", "Specify output mode [default, raw, jsonl]. raw suppresses log labels and timestamp.").Default("default").Short('o').Enum("default", "raw", "jsonl")
+	outputMode = app.Flag("output", "Specify output mode [default, raw, jsonl, logfmt]. raw suppresses log labels and timestamp.").Default("default").Short('o').Enum("default", "raw", "jsonl", "logfmt")
+	outputTmpl = app.Flag("template", "Render each log entry with a Go template instead of the output mode, eg '{{.Timestamp}} {{.Labels.app}} {{.Line}}'.").Default("").String()
 	timezone   = app.Flag("timezone", "Specify the timezone to use when formatting output timestamps [Local, UTC]").Default("Local").Short('z').Enum("Local", "UTC")
 	cpuProfile = app.Flag("cpuprofile", "Specify the location for writing a CPU profile.").Default("").String()
 	memProfile = app.Flag("memprofile", "Specify the location for writing a memory profile.").Default("").String()
@@ -38,9 +45,12 @@ returned in a few output modes:
 	raw: log line
 	default: log timestamp + log labels + log line
 	jsonl: JSON response from Loki API of log line
+	logfmt: log timestamp + log labels + log line as logfmt
 
 The output of the log can be specified with the "-o" flag, for
-example, "-o raw" for the raw output format.
+example, "-o raw" for the raw output format. The "--template" flag
+renders each log entry with a Go template instead, the template
+being executed with the .Timestamp, .Labels and .Line of the entry.
 
 The "query" command will output extra information about the query
 and its results, such as the API URL, set of common labels, and set
@@ -56,6 +66,7 @@ the "instant-query" command instead.`)
 	rangeQuery = newQuery(false, queryCmd)
 	tail       = queryCmd.Flag("tail", "Tail the logs").Short('t').Default("false").Bool()
 	delayFor   = queryCmd.Flag("delay-for", "Delay in tailing by number of seconds to accumulate logs for re-ordering").Default("0").Int()
+	follow     = queryCmd.Flag("follow", "Reconnect when the tail connection is lost, resuming after the last entry printed").Default("false").Bool()
 
 	instantQueryCmd = app.Command("instant-query", `Run an instant LogQL query.
 
@@ -73,7 +84,12 @@ LogQL documentation:
 https://github.com/famarks/loki/blob/master/docs/logql.md`)
 	instantQuery = newQuery(true, instantQueryCmd)
 
-	labelsCmd   = app.Command("labels", "Find values for a given label.")
+	labelsCmd = app.Command("labels", `Find values for a given label.
+
+With --interactive, the "labels" command lets you drill into the label
+names, the values of a label and the series matching the selector built
+from the values picked, typing text to filter the items listed. The
+selector is printed on quitting.`)
 	labelsQuery = newLabelQuery(labelsCmd)
 
 	seriesCmd = app.Command("series", `Run series query.
@@ -87,6 +103,55 @@ Use the --analyze-labels flag to get a summary of the labels found in all stream
 This is helpful to find high cardinality labels. 
 `)
 	seriesQuery = newSeriesQuery(seriesCmd)
+
+	statsCmd = app.Command("stats", `Print the number of streams, chunks, entries and bytes matching a stream selector.
+
+The "stats" command runs metric queries over the whole time window,
+reading all the matching chunks.`)
+	statsQuery = newVolumeQuery(false, statsCmd)
+
+	volumeCmd = app.Command("volume", `Print the streams with the largest volume, grouped by labels.
+
+The "volume" command sums up the bytes of the streams matching a stream
+selector in the time window by the values of the --by labels, and
+prints the top groups along their share of the total volume. It runs
+a metric query over the whole time window, reading all the matching
+chunks.`)
+	volumeQuery = newVolumeQuery(true, volumeCmd)
+
+	detectFieldsCmd = app.Command("detect-fields", `Detect the fields of the lines matching a stream selector.
+
+The "detect-fields" command samples the most recent lines matching the
+stream selector in the time window, parses them as JSON or logfmt and
+prints their fields along their type, the share of the lines they are
+found in, their number of distinct values and an example value. It then
+suggests queries parsing the lines and making use of the fields, like
+the quantiles of the numeric fields or the counts by the fields with few
+values.`)
+	detectFieldsQuery = newDetectFieldsQuery(detectFieldsCmd)
+
+	pushCmd = app.Command("push", `Push the lines of local files to Loki.
+
+The "push" command reads the lines of the files, or of stdin without
+files, extracts their timestamp with the --timestamp-regex and pushes
+them in order to the stream of the --labels in rate-limited batches,
+for backfilling historical logs. The lines without a timestamp, like
+the lines of a multi-line entry, get the timestamp of the previous
+line. Without --timestamp-regex, the lines are pushed with the current
+time.`)
+	pushQuery = newPush(pushCmd)
+
+	deleteCmd = app.Command("delete", `Manage the delete requests of the tenant.
+
+The delete requests delete the log entries of the streams matching a
+stream selector within a time range. The compactor processes them once
+their cancel period is over, with deletion enabled.`)
+	deleteCreateCmd   = deleteCmd.Command("create", "Request the deletion of the log entries of the selected streams within a time range.")
+	deleteCreateQuery = newDeleteCreateQuery(deleteCreateCmd)
+	deleteListCmd     = deleteCmd.Command("list", "List the delete requests of the tenant.")
+	deleteListQuery   = newDeleteQuery(deleteListCmd)
+	deleteCancelCmd   = deleteCmd.Command("cancel", "Cancel a delete request, possible until its cancel period is over.")
+	deleteCancelQuery = newDeleteCancelQuery(deleteCancelCmd)
 )
 
 func main() {
@@ -130,6 +195,7 @@ func main() {
 			Timezone:      location,
 			NoLabels:      rangeQuery.NoLabels,
 			ColoredOutput: rangeQuery.ColoredOutput,
+			Template:      *outputTmpl,
 		}
 
 		out, err := output.NewLogOutput(os.Stdout, *outputMode, outputOptions)
@@ -138,7 +204,10 @@ func main() {
 		}
 
 		if *tail {
+			rangeQuery.Follow = *follow
 			rangeQuery.TailQuery(*delayFor, queryClient, out)
+		} else if rangeQuery.ParallelDuration > 0 {
+			rangeQuery.DoQueryParallel(queryClient, out, *statistics)
 		} else {
 			rangeQuery.DoQuery(queryClient, out, *statistics)
 		}
@@ -152,6 +221,7 @@ func main() {
 			Timezone:      location,
 			NoLabels:      instantQuery.NoLabels,
 			ColoredOutput: instantQuery.ColoredOutput,
+			Template:      *outputTmpl,
 		}
 
 		out, err := output.NewLogOutput(os.Stdout, *outputMode, outputOptions)
@@ -161,13 +231,37 @@ func main() {
 
 		instantQuery.DoQuery(queryClient, out, *statistics)
 	case labelsCmd.FullCommand():
-		labelsQuery.DoLabels(queryClient)
+		if labelsQuery.Interactive {
+			labelsQuery.DoExplore(queryClient, os.Stdin, os.Stdout)
+		} else {
+			labelsQuery.DoLabels(queryClient)
+		}
 	case seriesCmd.FullCommand():
 		seriesQuery.DoSeries(queryClient)
+	case statsCmd.FullCommand():
+		statsQuery.DoStats(queryClient)
+	case volumeCmd.FullCommand():
+		volumeQuery.DoVolume(queryClient)
+	case detectFieldsCmd.FullCommand():
+		detectFieldsQuery.DoDetect(queryClient)
+	case pushCmd.FullCommand():
+		location, err := time.LoadLocation(*timezone)
+		if err != nil {
+			log.Fatalf("Unable to load timezone '%s': %s", *timezone, err)
+		}
+		pushQuery.Location = location
+		pushQuery.DoPush(queryClient)
+	case deleteCreateCmd.FullCommand():
+		deleteCreateQuery.DoCreate(queryClient)
+	case deleteListCmd.FullCommand():
+		deleteListQuery.DoList(queryClient)
+	case deleteCancelCmd.FullCommand():
+		deleteCancelQuery.DoCancel(queryClient)
 	}
 }
 
 func newQueryClient(app *kingpin.Application) client.Client {
+	defaultContextsPath := client.DefaultContextsPath()
 
 	client := &client.DefaultClient{
 		TLSConfig: config.TLSConfig{},
@@ -183,9 +277,28 @@ func newQueryClient(app *kingpin.Application) client.Client {
 		return nil
 	}
 
-	app.Flag("addr", "Server address. Can also be set using LOKI_ADDR env var.").Default("http://localhost:3100").Envar("LOKI_ADDR").Action(addressAction).StringVar(&client.Address)
+	var contextName, contextsFile string
+
+	// fill the settings not set by the flags or the environment from the selected context, once all flags are parsed
+	app.PreAction(func(c *kingpin.ParseContext) error {
+		fromContext, err := applyContext(client, contextName, contextsFile)
+		if err != nil {
+			return err
+		}
+		if fromContext {
+			return addressAction(c)
+		}
+		return nil
+	})
+
+	app.Flag("context", "Name of the context of the config file to connect with, its current context by default. Can also be set using LOKI_CONTEXT env var.").Default("").Envar("LOKI_CONTEXT").StringVar(&contextName)
+	app.Flag("config-file", "Path of the config file holding the contexts. Can also be set using LOGCLI_CONFIG env var.").Default(defaultContextsPath).Envar("LOGCLI_CONFIG").StringVar(&contextsFile)
+	app.Flag("addr", "Server address, "+defaultAddress+" by default. Can also be set using LOKI_ADDR env var.").Default("").Envar("LOKI_ADDR").Action(addressAction).StringVar(&client.Address)
 	app.Flag("username", "Username for HTTP basic auth. Can also be set using LOKI_USERNAME env var.").Default("").Envar("LOKI_USERNAME").StringVar(&client.Username)
 	app.Flag("password", "Password for HTTP basic auth. Can also be set using LOKI_PASSWORD env var.").Default("").Envar("LOKI_PASSWORD").StringVar(&client.Password)
+	app.Flag("bearer-token", "Bearer token sent in the Authorization header, instead of the basic auth. Can also be set using LOKI_BEARER_TOKEN env var.").Default("").Envar("LOKI_BEARER_TOKEN").StringVar(&client.BearerToken)
+	app.Flag("bearer-token-file", "Path of a file holding the bearer token, read again on every request. Can also be set using LOKI_BEARER_TOKEN_FILE env var.").Default("").Envar("LOKI_BEARER_TOKEN_FILE").StringVar(&client.BearerTokenFile)
+	app.Flag("auth-exec", "Command printing the bearer token, or a JSON object with its token and expiry, run again once the token expires or is rejected. Can also be set using LOKI_AUTH_EXEC env var.").Default("").Envar("LOKI_AUTH_EXEC").StringVar(&client.AuthExec)
 	app.Flag("ca-cert", "Path to the server Certificate Authority. Can also be set using LOKI_CA_CERT_PATH env var.").Default("").Envar("LOKI_CA_CERT_PATH").StringVar(&client.TLSConfig.CAFile)
 	app.Flag("tls-skip-verify", "Server certificate TLS skip verify.").Default("false").Envar("LOKI_TLS_SKIP_VERIFY").BoolVar(&client.TLSConfig.InsecureSkipVerify)
 	app.Flag("cert", "Path to the client certificate. Can also be set using LOKI_CLIENT_CERT_PATH env var.").Default("").Envar("LOKI_CLIENT_CERT_PATH").StringVar(&client.TLSConfig.CertFile)
@@ -195,6 +308,28 @@ func newQueryClient(app *kingpin.Application) client.Client {
 	return client
 }
 
+// applyContext applies the selected context of the config file to the settings of the client not set otherwise, and
+// tells whether the address of the client comes from the context.
+func applyContext(c *client.DefaultClient, name, path string) (bool, error) {
+	cfg, err := client.LoadContexts(path)
+	if err != nil {
+		return false, err
+	}
+	ctx, ok, err := cfg.Context(name)
+	if err != nil {
+		return false, err
+	}
+
+	fromContext := ok && c.Address == "" && ctx.Address != ""
+	if ok {
+		c.ApplyContext(ctx)
+	}
+	if c.Address == "" {
+		c.Address = defaultAddress
+	}
+	return fromContext, nil
+}
+
 func newLabelQuery(cmd *kingpin.CmdClause) *labelquery.LabelQuery {
 	var labelName, from, to string
 	var since time.Duration
@@ -218,6 +353,7 @@ func newLabelQuery(cmd *kingpin.CmdClause) *labelquery.LabelQuery {
 	cmd.Flag("since", "Lookback window.").Default("1h").DurationVar(&since)
 	cmd.Flag("from", "Start looking for labels at this absolute time (inclusive)").StringVar(&from)
 	cmd.Flag("to", "Stop looking for labels at this absolute time (exclusive)").StringVar(&to)
+	cmd.Flag("interactive", "Explore the label names, values and series interactively to build a selector.").Short('i').Default("false").BoolVar(&q.Interactive)
 
 	return q
 }
@@ -250,6 +386,135 @@ func newSeriesQuery(cmd *kingpin.CmdClause) *seriesquery.SeriesQuery {
 	return q
 }
 
+func newVolumeQuery(grouped bool, cmd *kingpin.CmdClause) *volume.Query {
+	// calculate volume range from cli params
+	var from, to string
+	var since time.Duration
+
+	q := &volume.Query{}
+
+	// executed after all command flags are parsed
+	cmd.Action(func(c *kingpin.ParseContext) error {
+
+		defaultEnd := time.Now()
+		defaultStart := defaultEnd.Add(-since)
+
+		q.Start = mustParse(from, defaultStart)
+		q.End = mustParse(to, defaultEnd)
+		q.Quiet = *quiet
+		return nil
+	})
+
+	cmd.Arg("matcher", "eg '{foo=\"bar\",baz=~\".*blip\"}'").Required().StringVar(&q.Matcher)
+	cmd.Flag("since", "Lookback window.").Default("1h").DurationVar(&since)
+	cmd.Flag("from", "Start looking for logs at this absolute time (inclusive)").StringVar(&from)
+	cmd.Flag("to", "Stop looking for logs at this absolute time (exclusive)").StringVar(&to)
+	if grouped {
+		cmd.Flag("by", "Label to group the streams by, can be repeated.").Default("app").StringsVar(&q.GroupBy)
+		cmd.Flag("limit", "Number of groups to print, the largest first.").Default("10").IntVar(&q.Limit)
+	}
+
+	return q
+}
+
+func newDetectFieldsQuery(cmd *kingpin.CmdClause) *fields.Query {
+	// calculate sample range from cli params
+	var from, to string
+	var since time.Duration
+
+	q := &fields.Query{}
+
+	// executed after all command flags are parsed
+	cmd.Action(func(c *kingpin.ParseContext) error {
+
+		defaultEnd := time.Now()
+		defaultStart := defaultEnd.Add(-since)
+
+		q.Start = mustParse(from, defaultStart)
+		q.End = mustParse(to, defaultEnd)
+		q.Quiet = *quiet
+		return nil
+	})
+
+	cmd.Arg("matcher", "eg '{foo=\"bar\",baz=~\".*blip\"}'").Required().StringVar(&q.Matcher)
+	cmd.Flag("since", "Lookback window.").Default("1h").DurationVar(&since)
+	cmd.Flag("from", "Start looking for logs at this absolute time (inclusive)").StringVar(&from)
+	cmd.Flag("to", "Stop looking for logs at this absolute time (exclusive)").StringVar(&to)
+	cmd.Flag("limit", "Number of the most recent lines sampled.").Default("1000").IntVar(&q.Limit)
+
+	return q
+}
+
+func newPush(cmd *kingpin.CmdClause) *push.Push {
+	p := &push.Push{}
+
+	// executed after all command flags are parsed
+	cmd.Action(func(c *kingpin.ParseContext) error {
+		p.Quiet = *quiet
+		return nil
+	})
+
+	cmd.Arg("file", "Files to push, stdin by default.").StringsVar(&p.Files)
+	cmd.Flag("labels", "Labels of the stream the lines are pushed to, eg '{job=\"import\"}'.").Required().StringVar(&p.Labels)
+	cmd.Flag("timestamp-regex", "Regex extracting the timestamp of the lines, from its group named ts or its first group.").StringVar(&p.TimestampRegex)
+	cmd.Flag("timestamp-format", "Go layout of the timestamps, or unix, unix_ms or unix_ns. The timestamps without timezone are in --timezone.").Default(time.RFC3339Nano).StringVar(&p.TimestampFormat)
+	cmd.Flag("batch-size", "Maximum number of bytes of lines pushed by request.").Default("1048576").IntVar(&p.BatchSize)
+	cmd.Flag("rate-limit", "Maximum number of bytes of lines pushed per second, unlimited when zero.").Default("0").Float64Var(&p.RateLimit)
+
+	return p
+}
+
+func newDeleteQuery(cmd *kingpin.CmdClause) *deletequery.DeleteQuery {
+	q := &deletequery.DeleteQuery{}
+
+	// executed after all command flags are parsed
+	cmd.Action(func(c *kingpin.ParseContext) error {
+		q.Quiet = *quiet
+		return nil
+	})
+
+	return q
+}
+
+func newDeleteCreateQuery(cmd *kingpin.CmdClause) *deletequery.DeleteQuery {
+	// calculate delete range from cli params
+	var from, to string
+	var since time.Duration
+
+	q := newDeleteQuery(cmd)
+
+	// executed after all command flags are parsed
+	cmd.Action(func(c *kingpin.ParseContext) error {
+
+		defaultEnd := time.Now()
+		// like the deletion API, everything ingested so far is deleted by default
+		defaultStart := time.Unix(0, 0)
+		if since != 0 {
+			defaultStart = defaultEnd.Add(-since)
+		}
+
+		q.Start = mustParse(from, defaultStart)
+		q.End = mustParse(to, defaultEnd)
+		return nil
+	})
+
+	cmd.Arg("matcher", "eg '{foo=\"bar\",baz=~\".*blip\"}'").Required().StringVar(&q.Query)
+	cmd.Flag("since", "Delete the log entries of this lookback window, instead of all of them.").DurationVar(&since)
+	cmd.Flag("from", "Start deleting log entries at this absolute time (inclusive)").StringVar(&from)
+	cmd.Flag("to", "Stop deleting log entries at this absolute time (exclusive)").StringVar(&to)
+	cmd.Flag("dry-run", "Print the streams the request would delete the log entries of, instead of creating it.").Default("false").BoolVar(&q.DryRun)
+
+	return q
+}
+
+func newDeleteCancelQuery(cmd *kingpin.CmdClause) *deletequery.DeleteQuery {
+	q := newDeleteQuery(cmd)
+
+	cmd.Arg("request-id", "The ID of the delete request, as listed by the list command.").Required().StringVar(&q.RequestID)
+
+	return q
+}
+
 func newQuery(instant bool, cmd *kingpin.CmdClause) *query.Query {
 	// calculate query range from cli params
 	var now, from, to string
@@ -277,6 +542,7 @@ func newQuery(instant bool, cmd *kingpin.CmdClause) *query.Query {
 	if instant {
 		cmd.Arg("query", "eg 'rate({foo=\"bar\"} |~ \".*error.*\" [5m])'").Required().StringVar(&q.QueryString)
 		cmd.Flag("now", "Time at which to execute the instant query.").StringVar(&now)
+		cmd.Flag("format", "Specify the format of the vector results [json, table, csv].").Default("json").EnumVar(&q.VectorFormat, "json", "table", "csv")
 	} else {
 		cmd.Arg("query", "eg '{foo=\"bar\",baz=~\".*blip\"} |~ \".*error.*\"'").Required().StringVar(&q.QueryString)
 		cmd.Flag("since", "Lookback window.").Default("1h").DurationVar(&since)
@@ -285,6 +551,12 @@ func newQuery(instant bool, cmd *kingpin.CmdClause) *query.Query {
 		cmd.Flag("step", "Query resolution step width, for metric queries. Evaluate the query at the specified step over the time range.").DurationVar(&q.Step)
 		cmd.Flag("interval", "Query interval, for log queries. Return entries at the specified interval, ignoring those between. **This parameter is experimental, please see Issue 1779**").DurationVar(&q.Interval)
 		cmd.Flag("batch", "Query batch size to use until 'limit' is reached").Default("1000").IntVar(&q.BatchSize)
+		cmd.Flag("parallel-duration", "Split the time range of the query in parts of this duration, downloaded to part files. The limit applies to every part.").DurationVar(&q.ParallelDuration)
+		cmd.Flag("parallel-max-workers", "Number of parts downloaded concurrently.").Default("1").IntVar(&q.ParallelMaxWorkers)
+		cmd.Flag("part-path-prefix", "Prefix of the path of the part files, suffixed with the time range of each part.").StringVar(&q.PartPathPrefix)
+		cmd.Flag("overwrite-completed-parts", "Download again the parts already completed, instead of skipping them.").Default("false").BoolVar(&q.OverwriteCompleted)
+		cmd.Flag("merge-parts", "Print the part files in order once downloaded, and remove them.").Default("false").BoolVar(&q.MergeParts)
+		cmd.Flag("keep-parts", "Keep the part files once merged.").Default("false").BoolVar(&q.KeepParts)
 	}
 
 	cmd.Flag("forward", "Scan forwards through logs.").Default("false").BoolVar(&q.Forward)
@@ -293,7 +565,7 @@ func newQuery(instant bool, cmd *kingpin.CmdClause) *query.Query {
 	cmd.Flag("include-label", "Include labels given the provided key during output.").StringsVar(&q.ShowLabelsKey)
 	cmd.Flag("labels-length", "Set a fixed padding to labels").Default("0").IntVar(&q.FixedLabelsLen)
 	cmd.Flag("store-config", "Execute the current query using a configured storage from a given Loki configuration file.").Default("").StringVar(&q.LocalConfig)
-	cmd.Flag("colored-output", "Show output with colored labels").Default("false").BoolVar(&q.ColoredOutput)
+	cmd.Flag("colored-output", "Show output with colored labels, and the lines colored by their detected level").Default("false").BoolVar(&q.ColoredOutput)
 
 	return q
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
@@ -24,6 +25,7 @@ type canary struct {
 	lock sync.Mutex
 
 	writer     *writer.Writer
+	push       *writer.Push
 	reader     *reader.Reader
 	comparator *comparator.Comparator
 }
@@ -39,6 +41,8 @@ func main() {
 	tls := flag.Bool("tls", false, "Does the loki connection use TLS?")
 	user := flag.String("user", "", "Loki username")
 	pass := flag.String("pass", "", "Loki password")
+	tenantID := flag.String("tenant-id", "", "Tenant ID to be set in the X-Scope-OrgID header of the requests to Loki")
+	push := flag.Bool("push", false, "Push the logs directly to Loki with the push API instead of writing them to stdout for an agent to send them")
 	queryTimeout := flag.Duration("query-timeout", 10*time.Second, "How long to wait for a query response from Loki")
 
 	interval := flag.Duration("interval", 1000*time.Millisecond, "Duration between log entries")
@@ -83,9 +87,17 @@ func main() {
 		c.lock.Lock()
 		defer c.lock.Unlock()
 
-		c.writer = writer.NewWriter(os.Stdout, sentChan, *interval, *size)
-		c.reader = reader.NewReader(os.Stderr, receivedChan, *tls, *addr, *user, *pass, *queryTimeout, *lName, *lVal, *sName, *sValue, *interval)
-		c.comparator = comparator.NewComparator(os.Stderr, *wait, *maxWait, *pruneInterval, *spotCheckInterval, *spotCheckMax, *spotCheckQueryRate, *spotCheckWait, *metricTestInterval, *metricTestQueryRange, *interval, *buckets, sentChan, receivedChan, c.reader, true)
+		var w io.Writer = os.Stdout
+		if *push {
+			c.push = writer.NewPush(os.Stderr, *tls, *addr, *tenantID, *user, *pass, *queryTimeout, map[string]string{
+				*lName: *lVal,
+				*sName: *sValue,
+			})
+			w = c.push
+		}
+		c.writer = writer.NewWriter(w, sentChan, *interval, *size)
+		c.reader = reader.NewReader(os.Stderr, receivedChan, *tls, *addr, *user, *pass, *tenantID, *queryTimeout, *lName, *lVal, *sName, *sValue, *interval)
+		c.comparator = comparator.NewComparator(os.Stderr, *wait, *maxWait, *pruneInterval, *spotCheckInterval, *spotCheckMax, *spotCheckQueryRate, *spotCheckWait, *metricTestInterval, *metricTestQueryRange, *buckets, sentChan, receivedChan, c.reader, true)
 	}
 
 	startCanary()
@@ -124,11 +136,16 @@ func (c *canary) stop() {
 		return
 	}
 
+	if c.push != nil {
+		// Abort the push in progress, for the writer to stop right away.
+		c.push.Stop()
+	}
 	c.writer.Stop()
 	c.reader.Stop()
 	c.comparator.Stop()
 
 	c.writer = nil
+	c.push = nil
 	c.reader = nil
 	c.comparator = nil
 }
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/chunk/cache"
+	pkg_util "github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"gopkg.in/yaml.v2"
+
+	_ "github.com/famarks/loki/pkg/build"
+	"github.com/famarks/loki/pkg/logql"
+	"github.com/famarks/loki/pkg/loki"
+	"github.com/famarks/loki/pkg/storage"
+	"github.com/famarks/loki/pkg/storage/stores/shipper"
+	"github.com/famarks/loki/pkg/util/validation"
+)
+
+// ingesterName names the index files uploaded by the shippers of the destination store.
+const ingesterName = "migrate"
+
+type config struct {
+	sourceFile, destFile     string
+	sourceTenant, destTenant string
+	from, to                 string
+	match                    string
+	shardBy                  time.Duration
+	parallel                 int
+	batch                    int
+	retries                  int
+	progressFile             string
+}
+
+func (c *config) registerFlags(f *flag.FlagSet) {
+	f.StringVar(&c.sourceFile, "source.config.file", "", "Loki config file of the store to read the chunks from.")
+	f.StringVar(&c.destFile, "dest.config.file", "", "Loki config file of the store to write the chunks to.")
+	f.StringVar(&c.sourceTenant, "source.tenant", "fake", "Tenant of the chunks to read, fake for a single tenant Loki.")
+	f.StringVar(&c.destTenant, "dest.tenant", "fake", "Tenant to write the chunks for, fake for a single tenant Loki.")
+	f.StringVar(&c.from, "from", "", "Start of the time range to migrate, RFC3339 2006-01-02T15:04:05Z07:00.")
+	f.StringVar(&c.to, "to", "", "End of the time range to migrate, RFC3339 2006-01-02T15:04:05Z07:00.")
+	f.StringVar(&c.match, "match", "", "Optional label matchers selecting the streams to migrate, e.g. {app=\"foo\"}.")
+	f.DurationVar(&c.shardBy, "shard-by", 6*time.Hour, "Duration of the ranges the time range is split into, which are migrated in parallel and tracked by the progress file.")
+	f.IntVar(&c.parallel, "parallel", 8, "Number of ranges migrated in parallel.")
+	f.IntVar(&c.batch, "batch", 500, "Number of chunks read and written at once.")
+	f.IntVar(&c.retries, "retries", 10, "Number of attempts to read or write a batch of chunks before failing.")
+	f.StringVar(&c.progressFile, "progress.file", "", "File recording the migrated ranges, for an interrupted migration to resume where it stopped. Empty to disable.")
+}
+
+func main() {
+	var cfg config
+	cfg.registerFlags(flag.CommandLine)
+	flag.Parse()
+
+	if cfg.sourceFile == "" || cfg.destFile == "" || cfg.from == "" || cfg.to == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if err := migrate(cfg); err != nil {
+		log.Printf("migration failed: %v", err)
+		os.Exit(1)
+	}
+}
+
+func migrate(cfg config) error {
+	start, err := time.Parse(time.RFC3339, cfg.from)
+	if err != nil {
+		return fmt.Errorf("failed to parse -from: %w", err)
+	}
+	end, err := time.Parse(time.RFC3339, cfg.to)
+	if err != nil {
+		return fmt.Errorf("failed to parse -to: %w", err)
+	}
+	if !start.Before(end) || cfg.shardBy <= 0 || cfg.parallel <= 0 || cfg.batch <= 0 || cfg.retries <= 0 {
+		return errors.New("-from must be before -to, and -shard-by, -parallel, -batch and -retries must be positive")
+	}
+
+	matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "logs")}
+	if cfg.match != "" {
+		streamMatchers, err := logql.ParseMatchers(cfg.match)
+		if err != nil {
+			return fmt.Errorf("failed to parse -match: %w", err)
+		}
+		matchers = append(matchers, streamMatchers...)
+	}
+
+	sourceCfg, err := loadConfig(cfg.sourceFile)
+	if err != nil {
+		return fmt.Errorf("failed to load the source config: %w", err)
+	}
+	destCfg, err := loadConfig(cfg.destFile)
+	if err != nil {
+		return fmt.Errorf("failed to load the destination config: %w", err)
+	}
+
+	// the stores are made one after the other as the boltdb-shipper index client of their config is registered globally.
+	source, err := newStore(sourceCfg, shipper.ModeReadOnly)
+	if err != nil {
+		return fmt.Errorf("failed to create the source store: %w", err)
+	}
+	defer source.Stop()
+	dest, err := newStore(destCfg, shipper.ModeWriteOnly)
+	if err != nil {
+		return fmt.Errorf("failed to create the destination store: %w", err)
+	}
+	// stopping the destination store uploads the index files it wrote, even when the migration failed.
+	defer dest.Stop()
+
+	progress, err := openProgress(cfg.progressFile)
+	if err != nil {
+		return fmt.Errorf("failed to open the progress file: %w", err)
+	}
+	defer progress.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		<-signals
+		log.Println("interrupted, stopping once the ranges being migrated are done")
+		cancel()
+	}()
+
+	mover := &chunkMover{
+		source:     source,
+		dest:       dest,
+		sourceUser: cfg.sourceTenant,
+		destUser:   cfg.destTenant,
+		matchers:   matchers,
+		batch:      cfg.batch,
+		backoff:    pkg_util.BackoffConfig{MinBackoff: time.Second, MaxBackoff: 30 * time.Second, MaxRetries: cfg.retries},
+		progress:   progress,
+	}
+	ranges := splitRange(start, end, cfg.shardBy)
+	log.Printf("migrating %d ranges of %v from %v to %v", len(ranges), cfg.shardBy, start, end)
+
+	begun := time.Now()
+	total, err := mover.run(ctx, ranges, cfg.parallel)
+	log.Printf("migrated %d chunks, %d bytes, in %v", total.chunks, total.bytes, time.Since(begun))
+	return err
+}
+
+// loadConfig loads the Loki config file over the default values of the config.
+func loadConfig(file string) (loki.Config, error) {
+	var cfg loki.Config
+	flagext.DefaultValues(&cfg)
+	validation.SetDefaultLimitsForYAMLUnmarshalling(cfg.LimitsConfig)
+
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return cfg, err
+	}
+	if err := yaml.UnmarshalStrict(buf, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", file, err)
+	}
+	if err := cfg.SchemaConfig.Validate(); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.StorageConfig.Validate()
+}
+
+// newStore makes the chunk store of the config, whose shipped indexes are used in the mode. The chunks caches are
+// disabled as the chunks are only read or written once.
+func newStore(cfg loki.Config, mode int) (chunk.Store, error) {
+	cfg.StorageConfig.BoltDBShipperConfig.Mode = mode
+	cfg.StorageConfig.BoltDBShipperConfig.IngesterName = ingesterName
+	cfg.StorageConfig.TSDBShipperConfig.Mode = mode
+	cfg.StorageConfig.TSDBShipperConfig.IngesterName = ingesterName
+	cfg.ChunkStoreConfig.ChunkCacheConfig = cache.Config{}
+	cfg.StorageConfig.EmbeddedChunkCache.Enabled = false
+
+	if err := cfg.StorageConfig.InjectS3Compatible(); err != nil {
+		return nil, err
+	}
+
+	// each store registers its metrics separately, for those of the two stores not to conflict.
+	registerer := prometheus.NewRegistry()
+	storage.RegisterCustomIndexClients(&cfg.StorageConfig, registerer)
+	limits, err := validation.NewOverrides(cfg.LimitsConfig, nil)
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewChunkStore(cfg.StorageConfig, cfg.ChunkStoreConfig, cfg.SchemaConfig, limits, registerer)
+}
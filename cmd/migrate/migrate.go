@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	pkg_util "github.com/cortexproject/cortex/pkg/util"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/weaveworks/common/user"
+)
+
+// syncRange is a range of the time range to migrate, whose chunks are migrated at once.
+type syncRange struct {
+	number   int
+	from, to time.Time
+}
+
+// splitRange splits the time range into ranges of the duration.
+func splitRange(from, to time.Time, shardBy time.Duration) []syncRange {
+	var ranges []syncRange
+	for start := from; start.Before(to); start = start.Add(shardBy) {
+		end := start.Add(shardBy)
+		if end.After(to) {
+			end = to
+		}
+		ranges = append(ranges, syncRange{number: len(ranges), from: start, to: end})
+	}
+	return ranges
+}
+
+type migrateStats struct {
+	chunks int
+	bytes  int
+}
+
+// chunkMover migrates the chunks of the source store to the destination store, whose schema config determines how their
+// index is written.
+type chunkMover struct {
+	source, dest         chunk.Store
+	sourceUser, destUser string
+	matchers             []*labels.Matcher
+	batch                int
+	backoff              pkg_util.BackoffConfig
+	progress             *progress
+}
+
+// run migrates the ranges in parallel, skipping those already migrated, until they are all migrated or one of them failed.
+func (m *chunkMover) run(ctx context.Context, ranges []syncRange, parallel int) (migrateStats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		mtx     sync.Mutex
+		total   migrateStats
+		lastErr error
+	)
+	queue := make(chan syncRange)
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range queue {
+				start := time.Now()
+				stats, err := m.moveRange(ctx, r, r.number == 0)
+				if err == nil {
+					err = m.progress.markDone(m.key(r))
+				}
+
+				mtx.Lock()
+				total.chunks += stats.chunks
+				total.bytes += stats.bytes
+				if err != nil {
+					lastErr = fmt.Errorf("range %d from %v to %v: %w", r.number, r.from, r.to, err)
+					cancel()
+				}
+				mtx.Unlock()
+				if err == nil {
+					log.Printf("migrated range %d from %v to %v: %d chunks, %d bytes, in %v", r.number, r.from, r.to, stats.chunks, stats.bytes, time.Since(start))
+				}
+			}
+		}()
+	}
+
+outer:
+	for _, r := range ranges {
+		if m.progress.isDone(m.key(r)) {
+			log.Printf("skipping range %d from %v to %v, already migrated", r.number, r.from, r.to)
+			continue
+		}
+		select {
+		case queue <- r:
+		case <-ctx.Done():
+			break outer
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if lastErr == nil {
+		lastErr = ctx.Err()
+	}
+	return total, lastErr
+}
+
+// key identifies the migration of the range in the progress file.
+func (m *chunkMover) key(r syncRange) string {
+	return fmt.Sprintf("%s %s %s %s", m.sourceUser, m.destUser, r.from.UTC().Format(time.RFC3339Nano), r.to.UTC().Format(time.RFC3339Nano))
+}
+
+// moveRange migrates the chunks starting within the range, for the chunks overlapping several ranges to be migrated
+// once. The chunks starting before the range are migrated with the first one.
+func (m *chunkMover) moveRange(ctx context.Context, r syncRange, first bool) (migrateStats, error) {
+	var stats migrateStats
+	from, through := model.TimeFromUnixNano(r.from.UnixNano()), model.TimeFromUnixNano(r.to.UnixNano())
+
+	var (
+		groups   [][]chunk.Chunk
+		fetchers []*chunk.Fetcher
+	)
+	err := m.retry(ctx, func() error {
+		var err error
+		groups, fetchers, err = m.source.GetChunkRefs(user.InjectOrgID(ctx, m.sourceUser), m.sourceUser, from, through, m.matchers...)
+		return err
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	for i, fetcher := range fetchers {
+		chunks := make([]chunk.Chunk, 0, len(groups[i]))
+		for _, chk := range groups[i] {
+			if (chk.From >= from || first) && chk.From < through {
+				chunks = append(chunks, chk)
+			}
+		}
+		// FetchChunks requires chunks to be ordered by external key.
+		sort.Slice(chunks, func(i, j int) bool { return chunks[i].ExternalKey() < chunks[j].ExternalKey() })
+
+		for len(chunks) > 0 {
+			n := m.batch
+			if n > len(chunks) {
+				n = len(chunks)
+			}
+			batchStats, err := m.moveBatch(ctx, fetcher, chunks[:n])
+			stats.chunks += batchStats.chunks
+			stats.bytes += batchStats.bytes
+			if err != nil {
+				return stats, err
+			}
+			chunks = chunks[n:]
+		}
+	}
+	return stats, nil
+}
+
+func (m *chunkMover) moveBatch(ctx context.Context, fetcher *chunk.Fetcher, chunks []chunk.Chunk) (migrateStats, error) {
+	var stats migrateStats
+	keys := make([]string, 0, len(chunks))
+	for _, chk := range chunks {
+		keys = append(keys, chk.ExternalKey())
+	}
+
+	var fetched []chunk.Chunk
+	err := m.retry(ctx, func() error {
+		var err error
+		fetched, err = fetcher.FetchChunks(user.InjectOrgID(ctx, m.sourceUser), chunks, keys)
+		return err
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	output := make([]chunk.Chunk, 0, len(fetched))
+	for _, chk := range fetched {
+		if m.sourceUser != m.destUser {
+			// the tenant is part of the external key and of the encoded chunk, so the chunk is encoded again.
+			chk = chunk.NewChunk(m.destUser, chk.Fingerprint, chk.Metric, chk.Data, chk.From, chk.Through)
+			if err := chk.Encode(); err != nil {
+				return stats, err
+			}
+		}
+		encoded, err := chk.Encoded()
+		if err != nil {
+			return stats, err
+		}
+		stats.bytes += len(encoded)
+		output = append(output, chk)
+	}
+
+	err = m.retry(ctx, func() error {
+		return m.dest.Put(user.InjectOrgID(ctx, m.destUser), output)
+	})
+	if err != nil {
+		return stats, err
+	}
+	stats.chunks = len(output)
+	return stats, nil
+}
+
+// retry retries the function with the backoff until it succeeds.
+func (m *chunkMover) retry(ctx context.Context, f func() error) error {
+	backoff := pkg_util.NewBackoff(ctx, m.backoff)
+	var err error
+	for backoff.Ongoing() {
+		if err = f(); err == nil {
+			return nil
+		}
+		log.Printf("retrying after error: %v", err)
+		backoff.Wait()
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/ingester/client"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/famarks/loki/pkg/chunkenc"
+	"github.com/famarks/loki/pkg/logproto"
+	"github.com/famarks/loki/pkg/storage/stores/shipper"
+)
+
+func TestSplitRange(t *testing.T) {
+	from := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.Equal(t, []syncRange{
+		{number: 0, from: from, to: from.Add(6 * time.Hour)},
+		{number: 1, from: from.Add(6 * time.Hour), to: from.Add(12 * time.Hour)},
+		{number: 2, from: from.Add(12 * time.Hour), to: from.Add(14 * time.Hour)},
+	}, splitRange(from, from.Add(14*time.Hour), 6*time.Hour))
+}
+
+func TestProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate-progress")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "progress")
+
+	p, err := openProgress(path)
+	require.NoError(t, err)
+	require.False(t, p.isDone("range1"))
+	require.NoError(t, p.markDone("range1"))
+	require.True(t, p.isDone("range1"))
+	require.NoError(t, p.Close())
+
+	// the ranges recorded by a previous migration are done.
+	p, err = openProgress(path)
+	require.NoError(t, err)
+	defer p.Close()
+	require.True(t, p.isDone("range1"))
+	require.False(t, p.isDone("range2"))
+}
+
+const sourceConfig = `
+schema_config:
+  configs:
+  - from: 2020-01-01
+    store: boltdb
+    object_store: filesystem
+    schema: v11
+    index:
+      prefix: index_
+      period: 168h
+storage_config:
+  boltdb:
+    directory: %[1]s/source/index
+  filesystem:
+    directory: %[1]s/source/chunks
+`
+
+const destConfig = `
+schema_config:
+  configs:
+  - from: 2020-01-01
+    store: boltdb-shipper
+    object_store: filesystem
+    schema: v11
+    index:
+      prefix: index_
+      period: 24h
+storage_config:
+  boltdb_shipper:
+    active_index_directory: %[1]s/dest/index
+    cache_location: %[1]s/dest/cache
+    shared_store: filesystem
+  filesystem:
+    directory: %[1]s/dest/chunks
+`
+
+func newTestChunk(t *testing.T, userID, app string, from time.Time) chunk.Chunk {
+	lbs := labels.Labels{{Name: labels.MetricName, Value: "logs"}, {Name: "app", Value: app}}
+
+	chk := chunkenc.NewMemChunk(chunkenc.EncGZIP, 256*1024, 0)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, chk.Append(&logproto.Entry{Timestamp: from.Add(time.Duration(i) * time.Minute), Line: fmt.Sprintf("line %d", i)}))
+	}
+	require.NoError(t, chk.Close())
+
+	c := chunk.NewChunk(userID, client.Fingerprint(lbs), lbs, chunkenc.NewFacade(chk, 0, 0), model.TimeFromUnixNano(from.UnixNano()), model.TimeFromUnixNano(from.Add(9*time.Minute).UnixNano()))
+	require.NoError(t, c.Encode())
+	return c
+}
+
+func TestMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cfg := config{
+		sourceFile:   filepath.Join(dir, "source.yaml"),
+		destFile:     filepath.Join(dir, "dest.yaml"),
+		sourceTenant: "source",
+		destTenant:   "dest",
+		from:         "2021-01-01T00:00:00Z",
+		to:           "2021-01-02T00:00:00Z",
+		shardBy:      6 * time.Hour,
+		parallel:     2,
+		batch:        2,
+		retries:      1,
+		progressFile: filepath.Join(dir, "progress"),
+	}
+	require.NoError(t, ioutil.WriteFile(cfg.sourceFile, []byte(fmt.Sprintf(sourceConfig, dir)), 0o644))
+	require.NoError(t, ioutil.WriteFile(cfg.destFile, []byte(fmt.Sprintf(destConfig, dir)), 0o644))
+
+	from := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	sourceCfg, err := loadConfig(cfg.sourceFile)
+	require.NoError(t, err)
+	source, err := newStore(sourceCfg, shipper.ModeReadWrite)
+	require.NoError(t, err)
+	require.NoError(t, source.Put(context.Background(), []chunk.Chunk{
+		newTestChunk(t, "source", "foo", from.Add(time.Hour)),
+		// the chunk overlapping two ranges is migrated once.
+		newTestChunk(t, "source", "foo", from.Add(6*time.Hour-5*time.Minute)),
+		newTestChunk(t, "source", "bar", from.Add(13*time.Hour)),
+		newTestChunk(t, "other", "foo", from.Add(time.Hour)),
+	}))
+	source.Stop()
+
+	require.NoError(t, migrate(cfg))
+	progress, err := ioutil.ReadFile(cfg.progressFile)
+	require.NoError(t, err)
+	require.Equal(t, 4, strings.Count(string(progress), "\n"))
+
+	destCfg, err := loadConfig(cfg.destFile)
+	require.NoError(t, err)
+	dest, err := newStore(destCfg, shipper.ModeReadOnly)
+	require.NoError(t, err)
+
+	chunks, err := dest.Get(user.InjectOrgID(context.Background(), "dest"), "dest", model.TimeFromUnixNano(from.UnixNano()), model.TimeFromUnixNano(from.Add(24*time.Hour).UnixNano()), labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "logs"))
+	require.NoError(t, err)
+	require.Len(t, chunks, 3)
+	for _, chk := range chunks {
+		require.Equal(t, "dest", chk.UserID)
+		require.Equal(t, 10, chk.Data.(*chunkenc.Facade).LokiChunk().Size())
+	}
+	dest.Stop()
+
+	// the ranges already migrated are skipped. The index files of the destination store are still open, so the
+	// destination store is made in another directory.
+	cfg.destFile = filepath.Join(dir, "rerun.yaml")
+	require.NoError(t, ioutil.WriteFile(cfg.destFile, []byte(fmt.Sprintf(destConfig, filepath.Join(dir, "rerun"))), 0o644))
+	require.NoError(t, migrate(cfg))
+	written, err := ioutil.ReadDir(filepath.Join(dir, "rerun", "dest", "chunks"))
+	require.NoError(t, err)
+	require.Empty(t, written)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// progress records the ranges already migrated in a file, one per line, for an interrupted migration to skip them
+// when it's run again.
+type progress struct {
+	mtx  sync.Mutex
+	file *os.File
+	done map[string]struct{}
+}
+
+// openProgress opens the progress file, creating it when it doesn't exist. The progress isn't recorded when the path
+// is empty.
+func openProgress(path string) (*progress, error) {
+	p := &progress{done: map[string]struct{}{}}
+	if path == "" {
+		return p, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		p.done[scanner.Text()] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	p.file = file
+	return p, nil
+}
+
+func (p *progress) isDone(key string) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	_, ok := p.done[key]
+	return ok
+}
+
+// markDone records the range as migrated, syncing the file for the range not to be lost when the migration is killed.
+func (p *progress) markDone(key string) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.done[key] = struct{}{}
+	if p.file == nil {
+		return nil
+	}
+	if _, err := fmt.Fprintln(p.file, key); err != nil {
+		return err
+	}
+	return p.file.Sync()
+}
+
+func (p *progress) Close() error {
+	if p.file == nil {
+		return nil
+	}
+	return p.file.Close()
+}
@@ -56,6 +56,10 @@ func (c *Config) Clone() flagext.Registerer {
 
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == testPipelineCommand {
+		runTestPipeline(os.Args[2:])
+	}
+
 	// Load config, merging config file and CLI flags
 	var config Config
 	if err := cfg.Parse(&config); err != nil {
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/famarks/loki/pkg/cfg"
+	"github.com/famarks/loki/pkg/logentry/stages"
+)
+
+// testPipelineCommand is the subcommand running sample entries through the pipeline of a scrape config.
+const testPipelineCommand = "test-pipeline"
+
+// testPipelineReport is the machine readable output of the test-pipeline subcommand.
+type testPipelineReport struct {
+	Job     string                `json:"job"`
+	Passed  int                   `json:"passed"`
+	Failed  int                   `json:"failed"`
+	Results []stages.SampleResult `json:"results"`
+}
+
+// testPipeline runs the samples of the input file through the pipeline of the scrape config of the job, and writes
+// the report to out. It returns whether all the samples passed.
+func testPipeline(args []string, out io.Writer) (bool, error) {
+	var configFile, jobName, inputFile string
+	f := flag.NewFlagSet(testPipelineCommand, flag.ContinueOnError)
+	f.StringVar(&configFile, "config", "", "Promtail config file.")
+	f.StringVar(&jobName, "stage-name", "", "Job name of the scrape config whose pipeline is tested, the first one if empty.")
+	f.StringVar(&inputFile, "input", "", "JSON file of the samples, a list of entries with their line, labels, timestamp and output expected.")
+	if err := f.Parse(args); err != nil {
+		return false, err
+	}
+	if configFile == "" || inputFile == "" {
+		return false, errors.New("-config and -input are required")
+	}
+
+	var config Config
+	if err := cfg.Unmarshal(&config, cfg.YAML(configFile)); err != nil {
+		return false, err
+	}
+	if len(config.ScrapeConfig) == 0 {
+		return false, errors.New("no scrape config in the config file")
+	}
+	scrapeConfig := config.ScrapeConfig[0]
+	if jobName != "" {
+		found := false
+		for _, sc := range config.ScrapeConfig {
+			if sc.JobName == jobName {
+				scrapeConfig, found = sc, true
+				break
+			}
+		}
+		if !found {
+			return false, errors.Errorf("no scrape config with the job name %q", jobName)
+		}
+	}
+
+	buf, err := ioutil.ReadFile(inputFile)
+	if err != nil {
+		return false, err
+	}
+	var samples []stages.Sample
+	if err := json.Unmarshal(buf, &samples); err != nil {
+		return false, errors.Wrap(err, inputFile)
+	}
+
+	pipeline, err := stages.NewPipeline(util.Logger, scrapeConfig.PipelineStages, &scrapeConfig.JobName, prometheus.NewRegistry())
+	if err != nil {
+		return false, err
+	}
+	report := testPipelineReport{Job: scrapeConfig.JobName, Results: pipeline.RunSamples(samples)}
+	for _, result := range report.Results {
+		if result.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return false, err
+	}
+	return report.Failed == 0, nil
+}
+
+// runTestPipeline runs the test-pipeline subcommand, exiting with 1 when a sample failed and 2 on errors.
+func runTestPipeline(args []string) {
+	passed, err := testPipeline(args, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to test the pipeline:", err)
+		os.Exit(2)
+	}
+	if !passed {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
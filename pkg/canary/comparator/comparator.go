@@ -36,6 +36,12 @@ var (
 		Name:      "out_of_order_entries_total",
 		Help:      "counts log entries received with a timestamp more recent than the others in the queue",
 	})
+	outOfOrderDelta = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "loki_canary",
+		Name:      "out_of_order_entry_delta_seconds",
+		Help:      "how much more recent than the oldest entry not received yet an out of order entry was, in seconds",
+		Buckets:   instrument.DefBuckets,
+	})
 	wsMissingEntries = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: "loki_canary",
 		Name:      "websocket_missing_entries_total",
@@ -76,6 +82,11 @@ var (
 		Name:      "metric_test_actual",
 		Help:      "How many counts were actually received by the metric test query",
 	})
+	metricTestDeviation = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "loki_canary",
+		Name:      "metric_test_deviation",
+		Help:      "How many counts the metric test query was missing, negative when it received more counts than expected",
+	})
 	responseLatency   prometheus.Histogram
 	metricTestLatency = promauto.NewHistogram(prometheus.HistogramOpts{
 		Namespace: "loki_canary",
@@ -91,8 +102,14 @@ var (
 	})
 )
 
+// sentCount is the number of entries sent during a second.
+type sentCount struct {
+	second time.Time
+	count  int
+}
+
 type Comparator struct {
-	entMtx              sync.Mutex // Locks access to []entries and []ackdEntries
+	entMtx              sync.Mutex // Locks access to []entries, []ackdEntries and []sentCounts
 	missingMtx          sync.Mutex // Locks access to []missingEntries
 	spotEntMtx          sync.Mutex // Locks access to []spotCheck
 	spotMtx             sync.Mutex // Locks spotcheckRunning for single threaded but async spotCheck()
@@ -103,6 +120,7 @@ type Comparator struct {
 	missingEntries      []*time.Time
 	spotCheck           []*time.Time
 	ackdEntries         []*time.Time
+	sentCounts          []sentCount
 	wait                time.Duration
 	maxWait             time.Duration
 	pruneInterval       time.Duration
@@ -115,7 +133,6 @@ type Comparator struct {
 	metricTestInterval  time.Duration
 	metricTestRange     time.Duration
 	metricTestRunning   bool
-	writeInterval       time.Duration
 	confirmAsync        bool
 	startTime           time.Time
 	sent                chan time.Time
@@ -132,7 +149,6 @@ func NewComparator(writer io.Writer,
 	spotCheckInterval, spotCheckMax, spotCheckQueryRate, spotCheckWait time.Duration,
 	metricTestInterval time.Duration,
 	metricTestRange time.Duration,
-	writeInterval time.Duration,
 	buckets int,
 	sentChan chan time.Time,
 	receivedChan chan time.Time,
@@ -154,7 +170,6 @@ func NewComparator(writer io.Writer,
 		metricTestInterval:  metricTestInterval,
 		metricTestRange:     metricTestRange,
 		metricTestRunning:   false,
-		writeInterval:       writeInterval,
 		confirmAsync:        confirmAsync,
 		startTime:           time.Now(),
 		sent:                sentChan,
@@ -186,15 +201,22 @@ func (c *Comparator) Stop() {
 	}
 }
 
-func (c *Comparator) entrySent(time time.Time) {
+func (c *Comparator) entrySent(ts time.Time) {
 	c.entMtx.Lock()
-	c.entries = append(c.entries, &time)
+	c.entries = append(c.entries, &ts)
 	totalEntries.Inc()
+	// Count the entries sent each second, for the metric test to compare the count queried with them.
+	second := ts.Truncate(time.Second)
+	if n := len(c.sentCounts); n > 0 && c.sentCounts[n-1].second.Equal(second) {
+		c.sentCounts[n-1].count++
+	} else {
+		c.sentCounts = append(c.sentCounts, sentCount{second: second, count: 1})
+	}
 	c.entMtx.Unlock()
 	//If this entry equals or exceeds the spot check interval from the last entry in the spot check array, add it.
 	c.spotEntMtx.Lock()
-	if len(c.spotCheck) == 0 || time.Sub(*c.spotCheck[len(c.spotCheck)-1]) >= c.spotCheckInterval {
-		c.spotCheck = append(c.spotCheck, &time)
+	if len(c.spotCheck) == 0 || ts.Sub(*c.spotCheck[len(c.spotCheck)-1]) >= c.spotCheckInterval {
+		c.spotCheck = append(c.spotCheck, &ts)
 	}
 	c.spotEntMtx.Unlock()
 
@@ -215,6 +237,7 @@ func (c *Comparator) entryReceived(ts time.Time) {
 			// If this isn't the first item in the list we received it out of order
 			if i != 0 {
 				outOfOrderEntries.Inc()
+				outOfOrderDelta.Observe(t.Sub(*c.entries[0]).Seconds())
 				fmt.Fprintf(c.w, ErrOutOfOrderEntry, t, c.entries[:i])
 			}
 			responseLatency.Observe(time.Since(ts).Seconds())
@@ -307,6 +330,7 @@ func (c *Comparator) metricTest(currTime time.Time) {
 	if currTime.Add(-c.metricTestRange).Before(c.startTime) {
 		adjustedRange = currTime.Sub(c.startTime)
 	}
+	expectedCount := float64(c.countSent(currTime.Add(-adjustedRange)))
 	begin := time.Now()
 	actualCount, err := c.rdr.QueryCountOverTime(fmt.Sprintf("%.0fs", adjustedRange.Seconds()))
 	metricTestLatency.Observe(time.Since(begin).Seconds())
@@ -314,9 +338,29 @@ func (c *Comparator) metricTest(currTime time.Time) {
 		fmt.Fprintf(c.w, "error running metric query test: %s\n", err.Error())
 		return
 	}
-	expectedCount := float64(adjustedRange.Milliseconds()) / float64(c.writeInterval.Milliseconds())
 	metricTestExpected.Set(expectedCount)
 	metricTestActual.Set(actualCount)
+	metricTestDeviation.Set(expectedCount - actualCount)
+}
+
+// countSent returns the number of entries sent since the time, counted by second, and forgets about the entries sent
+// before. The metric test range never grows, the counts older than the range are not needed anymore.
+func (c *Comparator) countSent(since time.Time) int {
+	c.entMtx.Lock()
+	defer c.entMtx.Unlock()
+
+	since = since.Truncate(time.Second)
+	k := 0
+	for k < len(c.sentCounts) && c.sentCounts[k].second.Before(since) {
+		k++
+	}
+	c.sentCounts = c.sentCounts[k:]
+
+	count := 0
+	for _, s := range c.sentCounts {
+		count += s.count
+	}
+	return count
 }
 
 func (c *Comparator) spotCheckEntries(currTime time.Time) {
@@ -357,8 +401,9 @@ func (c *Comparator) spotCheckEntries(currTime time.Time) {
 		recvd, err := c.rdr.Query(adjustedStart, adjustedEnd)
 		spotTestLatency.Observe(time.Since(begin).Seconds())
 		if err != nil {
+			// The other entries are still checked, an entry not queried being checked again at the next spot check.
 			fmt.Fprintf(c.w, "error querying loki: %s\n", err)
-			return
+			continue
 		}
 
 		found := false
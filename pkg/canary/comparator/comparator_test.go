@@ -2,7 +2,9 @@ package comparator
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -14,12 +16,13 @@ import (
 
 func TestComparatorEntryReceivedOutOfOrder(t *testing.T) {
 	outOfOrderEntries = &mockCounter{}
+	outOfOrderDelta = &mockHistogram{}
 	wsMissingEntries = &mockCounter{}
 	unexpectedEntries = &mockCounter{}
 	duplicateEntries = &mockCounter{}
 
 	actual := &bytes.Buffer{}
-	c := NewComparator(actual, 1*time.Hour, 1*time.Hour, 1*time.Hour, 15*time.Minute, 4*time.Hour, 4*time.Hour, 0, 1*time.Minute, 0, 0, 1, make(chan time.Time), make(chan time.Time), nil, false)
+	c := NewComparator(actual, 1*time.Hour, 1*time.Hour, 1*time.Hour, 15*time.Minute, 4*time.Hour, 4*time.Hour, 0, 1*time.Minute, 0, 1, make(chan time.Time), make(chan time.Time), nil, false)
 
 	t1 := time.Now()
 	t2 := t1.Add(1 * time.Second)
@@ -43,6 +46,8 @@ func TestComparatorEntryReceivedOutOfOrder(t *testing.T) {
 	assert.Equal(t, expected, actual.String())
 
 	assert.Equal(t, 1, outOfOrderEntries.(*mockCounter).count)
+	// t4 was received 2s ahead of t2
+	assert.Equal(t, []float64{2}, outOfOrderDelta.(*mockHistogram).observed)
 	assert.Equal(t, 0, unexpectedEntries.(*mockCounter).count)
 	assert.Equal(t, 0, wsMissingEntries.(*mockCounter).count)
 	assert.Equal(t, 0, duplicateEntries.(*mockCounter).count)
@@ -60,7 +65,7 @@ func TestComparatorEntryReceivedNotExpected(t *testing.T) {
 	duplicateEntries = &mockCounter{}
 
 	actual := &bytes.Buffer{}
-	c := NewComparator(actual, 1*time.Hour, 1*time.Hour, 1*time.Hour, 15*time.Minute, 4*time.Hour, 4*time.Hour, 0, 1*time.Minute, 0, 0, 1, make(chan time.Time), make(chan time.Time), nil, false)
+	c := NewComparator(actual, 1*time.Hour, 1*time.Hour, 1*time.Hour, 15*time.Minute, 4*time.Hour, 4*time.Hour, 0, 1*time.Minute, 0, 1, make(chan time.Time), make(chan time.Time), nil, false)
 
 	t1 := time.Now()
 	t2 := t1.Add(1 * time.Second)
@@ -101,7 +106,7 @@ func TestComparatorEntryReceivedDuplicate(t *testing.T) {
 	duplicateEntries = &mockCounter{}
 
 	actual := &bytes.Buffer{}
-	c := NewComparator(actual, 1*time.Hour, 1*time.Hour, 1*time.Hour, 15*time.Minute, 4*time.Hour, 4*time.Hour, 0, 1*time.Minute, 0, 0, 1, make(chan time.Time), make(chan time.Time), nil, false)
+	c := NewComparator(actual, 1*time.Hour, 1*time.Hour, 1*time.Hour, 15*time.Minute, 4*time.Hour, 4*time.Hour, 0, 1*time.Minute, 0, 1, make(chan time.Time), make(chan time.Time), nil, false)
 
 	t1 := time.Unix(0, 0)
 	t2 := t1.Add(1 * time.Second)
@@ -159,7 +164,7 @@ func TestEntryNeverReceived(t *testing.T) {
 	wait := 60 * time.Second
 	maxWait := 300 * time.Second
 	//We set the prune interval timer to a huge value here so that it never runs, instead we call pruneEntries manually below
-	c := NewComparator(actual, wait, maxWait, 50*time.Hour, 15*time.Minute, 4*time.Hour, 4*time.Hour, 0, 1*time.Minute, 0, 0, 1, make(chan time.Time), make(chan time.Time), mr, false)
+	c := NewComparator(actual, wait, maxWait, 50*time.Hour, 15*time.Minute, 4*time.Hour, 4*time.Hour, 0, 1*time.Minute, 0, 1, make(chan time.Time), make(chan time.Time), mr, false)
 
 	c.entrySent(t1)
 	c.entrySent(t2)
@@ -223,7 +228,7 @@ func TestPruneAckdEntires(t *testing.T) {
 	wait := 30 * time.Millisecond
 	maxWait := 30 * time.Millisecond
 	//We set the prune interval timer to a huge value here so that it never runs, instead we call pruneEntries manually below
-	c := NewComparator(actual, wait, maxWait, 50*time.Hour, 15*time.Minute, 4*time.Hour, 4*time.Hour, 0, 1*time.Minute, 0, 0, 1, make(chan time.Time), make(chan time.Time), nil, false)
+	c := NewComparator(actual, wait, maxWait, 50*time.Hour, 15*time.Minute, 4*time.Hour, 4*time.Hour, 0, 1*time.Minute, 0, 1, make(chan time.Time), make(chan time.Time), nil, false)
 
 	t1 := time.Unix(0, 0)
 	t2 := t1.Add(1 * time.Millisecond)
@@ -280,7 +285,7 @@ func TestSpotCheck(t *testing.T) {
 	spotCheck := 10 * time.Millisecond
 	spotCheckMax := 20 * time.Millisecond
 	//We set the prune interval timer to a huge value here so that it never runs, instead we call spotCheckEntries manually below
-	c := NewComparator(actual, 1*time.Hour, 1*time.Hour, 50*time.Hour, spotCheck, spotCheckMax, 4*time.Hour, 3*time.Millisecond, 1*time.Minute, 0, 0, 1, make(chan time.Time), make(chan time.Time), mr, false)
+	c := NewComparator(actual, 1*time.Hour, 1*time.Hour, 50*time.Hour, spotCheck, spotCheckMax, 4*time.Hour, 3*time.Millisecond, 1*time.Minute, 0, 1, make(chan time.Time), make(chan time.Time), mr, false)
 
 	// Send all the entries
 	for i := range entries {
@@ -320,9 +325,32 @@ func TestSpotCheck(t *testing.T) {
 	prometheus.Unregister(responseLatency)
 }
 
+func TestSpotCheck_QueryError(t *testing.T) {
+	spotCheckMissing = &mockCounter{}
+	spotCheckEntries = &mockCounter{}
+
+	actual := &bytes.Buffer{}
+
+	mr := &mockReader{err: errors.New("query timeout")}
+	c := NewComparator(actual, 1*time.Hour, 1*time.Hour, 50*time.Hour, 10*time.Millisecond, 1*time.Hour, 4*time.Hour, 3*time.Millisecond, 1*time.Minute, 0, 1, make(chan time.Time), make(chan time.Time), mr, false)
+
+	c.entrySent(time.Unix(0, 0))
+	c.entrySent(time.Unix(0, 10*time.Millisecond.Nanoseconds()))
+
+	// Both entries are queried despite the error of the first query, and not reported missing
+	c.spotCheckEntries(time.Unix(0, 20*time.Millisecond.Nanoseconds()))
+	assert.Equal(t, strings.Repeat("error querying loki: query timeout\n", 2), actual.String())
+	assert.Equal(t, 2, spotCheckEntries.(*mockCounter).count)
+	assert.Equal(t, 0, spotCheckMissing.(*mockCounter).count)
+	assert.Equal(t, 2, len(c.spotCheck))
+
+	prometheus.Unregister(responseLatency)
+}
+
 func TestMetricTest(t *testing.T) {
 	metricTestActual = &mockGauge{}
 	metricTestExpected = &mockGauge{}
+	metricTestDeviation = &mockGauge{}
 
 	actual := &bytes.Buffer{}
 
@@ -331,36 +359,50 @@ func TestMetricTest(t *testing.T) {
 	mr := &mockReader{}
 	metricTestRange := 30 * time.Second
 	//We set the prune interval timer to a huge value here so that it never runs, instead we call spotCheckEntries manually below
-	c := NewComparator(actual, 1*time.Hour, 1*time.Hour, 50*time.Hour, 0, 0, 4*time.Hour, 0, 10*time.Minute, metricTestRange, writeInterval, 1, make(chan time.Time), make(chan time.Time), mr, false)
+	c := NewComparator(actual, 1*time.Hour, 1*time.Hour, 50*time.Hour, 0, 0, 4*time.Hour, 0, 10*time.Minute, metricTestRange, 1, make(chan time.Time), make(chan time.Time), mr, false)
 	// Force the start time to a known value
 	c.startTime = time.Unix(10, 0)
 
+	// sendUntil sends the entries from the last one sent until the time, at the write interval
+	next := c.startTime
+	sendUntil := func(until time.Time) {
+		for ; next.Before(until); next = next.Add(writeInterval) {
+			c.entrySent(next)
+		}
+	}
+
 	// Run test at time 20s which is 10s after start
-	mr.countOverTime = float64((10 * time.Second).Milliseconds()) / float64(writeInterval.Milliseconds())
+	sendUntil(time.Unix(20, 0))
+	mr.countOverTime = 20
 	c.metricTest(time.Unix(0, 20*time.Second.Nanoseconds()))
 	// We want to look back 30s but have only been running from time 10s to time 20s so the query range should be adjusted to 10s
 	assert.Equal(t, "10s", mr.queryRange)
-	// Should be no deviation we set countOverTime to the runtime/writeinterval which should be what metrictTest expected
+	// Should be no deviation, the query returning the count of the entries sent
 	assert.Equal(t, float64(20), metricTestExpected.(*mockGauge).val)
 	assert.Equal(t, float64(20), metricTestActual.(*mockGauge).val)
+	assert.Equal(t, float64(0), metricTestDeviation.(*mockGauge).val)
 
-	// Run test at time 30s which is 20s after start
-	mr.countOverTime = float64((20 * time.Second).Milliseconds()) / float64(writeInterval.Milliseconds())
+	// Run test at time 30s which is 20s after start, the canary having stopped writing for 5s
+	sendUntil(time.Unix(25, 0))
+	next = time.Unix(30, 0)
+	mr.countOverTime = 28
 	c.metricTest(time.Unix(0, 30*time.Second.Nanoseconds()))
-	// We want to look back 30s but have only been running from time 10s to time 20s so the query range should be adjusted to 10s
 	assert.Equal(t, "20s", mr.queryRange)
-	// Gauge should be equal to the countOverTime value
-	assert.Equal(t, float64(40), metricTestExpected.(*mockGauge).val)
-	assert.Equal(t, float64(40), metricTestActual.(*mockGauge).val)
+	// Only the entries actually sent are expected, the query missing 2 of them
+	assert.Equal(t, float64(30), metricTestExpected.(*mockGauge).val)
+	assert.Equal(t, float64(28), metricTestActual.(*mockGauge).val)
+	assert.Equal(t, float64(2), metricTestDeviation.(*mockGauge).val)
 
 	// Run test 60s after start, we should now be capping the query range to 30s and expecting only 30s of counts
-	mr.countOverTime = float64((30 * time.Second).Milliseconds()) / float64(writeInterval.Milliseconds())
-	c.metricTest(time.Unix(0, 60*time.Second.Nanoseconds()))
-	// We want to look back 30s but have only been running from time 10s to time 20s so the query range should be adjusted to 10s
+	sendUntil(time.Unix(70, 0))
+	mr.countOverTime = 60
+	c.metricTest(time.Unix(0, 70*time.Second.Nanoseconds()))
 	assert.Equal(t, "30s", mr.queryRange)
-	// Gauge should be equal to the countOverTime value
 	assert.Equal(t, float64(60), metricTestExpected.(*mockGauge).val)
 	assert.Equal(t, float64(60), metricTestActual.(*mockGauge).val)
+	assert.Equal(t, float64(0), metricTestDeviation.(*mockGauge).val)
+	// The counts older than the range are forgotten
+	assert.Equal(t, time.Unix(40, 0), c.sentCounts[0].second)
 
 	prometheus.Unregister(responseLatency)
 }
@@ -463,14 +505,42 @@ func (m *mockGauge) SetToCurrentTime() {
 	panic("implement me")
 }
 
+type mockHistogram struct {
+	cLck     sync.Mutex
+	observed []float64
+}
+
+func (m *mockHistogram) Desc() *prometheus.Desc {
+	panic("implement me")
+}
+
+func (m *mockHistogram) Write(*io_prometheus_client.Metric) error {
+	panic("implement me")
+}
+
+func (m *mockHistogram) Describe(chan<- *prometheus.Desc) {
+	panic("implement me")
+}
+
+func (m *mockHistogram) Collect(chan<- prometheus.Metric) {
+	panic("implement me")
+}
+
+func (m *mockHistogram) Observe(v float64) {
+	m.cLck.Lock()
+	defer m.cLck.Unlock()
+	m.observed = append(m.observed, v)
+}
+
 type mockReader struct {
 	resp          []time.Time
+	err           error
 	countOverTime float64
 	queryRange    string
 }
 
 func (r *mockReader) Query(start time.Time, end time.Time) ([]time.Time, error) {
-	return r.resp, nil
+	return r.resp, r.err
 }
 
 func (r *mockReader) QueryCountOverTime(queryRange string) (float64, error) {
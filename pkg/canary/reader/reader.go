@@ -52,6 +52,7 @@ type Reader struct {
 	addr         string
 	user         string
 	pass         string
+	tenantID     string
 	queryTimeout time.Duration
 	sName        string
 	sValue       string
@@ -75,6 +76,7 @@ func NewReader(writer io.Writer,
 	address string,
 	user string,
 	pass string,
+	tenantID string,
 	queryTimeout time.Duration,
 	labelName string,
 	labelVal string,
@@ -85,6 +87,9 @@ func NewReader(writer io.Writer,
 	if user != "" {
 		h = http.Header{"Authorization": {"Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))}}
 	}
+	if tenantID != "" {
+		h.Set("X-Scope-OrgID", tenantID)
+	}
 
 	next := time.Now()
 	bkcfg := util.BackoffConfig{
@@ -100,6 +105,7 @@ func NewReader(writer io.Writer,
 		addr:         address,
 		user:         user,
 		pass:         pass,
+		tenantID:     tenantID,
 		queryTimeout: queryTimeout,
 		sName:        streamName,
 		sValue:       streamValue,
@@ -175,6 +181,9 @@ func (r *Reader) QueryCountOverTime(queryRange string) (float64, error) {
 
 	req.SetBasicAuth(r.user, r.pass)
 	req.Header.Set("User-Agent", userAgent)
+	if r.tenantID != "" {
+		req.Header.Set("X-Scope-OrgID", r.tenantID)
+	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -261,6 +270,9 @@ func (r *Reader) Query(start time.Time, end time.Time) ([]time.Time, error) {
 
 	req.SetBasicAuth(r.user, r.pass)
 	req.Header.Set("User-Agent", userAgent)
+	if r.tenantID != "" {
+		req.Header.Set("X-Scope-OrgID", r.tenantID)
+	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -0,0 +1,147 @@
+package writer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/famarks/loki/pkg/build"
+	"github.com/famarks/loki/pkg/loghttp"
+)
+
+var (
+	pushErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "loki_canary",
+		Name:      "push_errors_total",
+		Help:      "counts the log entries which could not be pushed to Loki",
+	})
+	userAgent = fmt.Sprintf("loki-canary/%s", build.Version)
+)
+
+// Push is an io.Writer pushing each log line written to Loki with the push API, for the canary to run where no agent
+// sends its logs to Loki.
+type Push struct {
+	url      string
+	tenantID string
+	user     string
+	pass     string
+	labels   loghttp.LabelSet
+	timeout  time.Duration
+	backoff  util.BackoffConfig
+	w        io.Writer
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewPush creates a Push sending the log lines to the stream of the labels, logging the errors to the writer.
+func NewPush(writer io.Writer, tls bool, address, tenantID, user, pass string, timeout time.Duration, labels map[string]string) *Push {
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+	u := url.URL{
+		Scheme: scheme,
+		Host:   address,
+		Path:   "/loki/api/v1/push",
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Push{
+		url:      u.String(),
+		tenantID: tenantID,
+		user:     user,
+		pass:     pass,
+		labels:   labels,
+		timeout:  timeout,
+		backoff: util.BackoffConfig{
+			MinBackoff: 500 * time.Millisecond,
+			MaxBackoff: 5 * time.Second,
+			MaxRetries: 5,
+		},
+		w:      writer,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Stop aborts the push in progress.
+func (p *Push) Stop() {
+	p.cancel()
+}
+
+// Write implements io.Writer, pushing the line written as an entry timestamped with the current time. The push is
+// retried on network errors, 429 and 5xx responses, the entry being dropped when all the retries failed.
+func (p *Push) Write(b []byte) (int, error) {
+	line := strings.TrimSuffix(string(b), "\n")
+	body, err := json.Marshal(loghttp.PushRequest{
+		Streams: []*loghttp.Stream{{
+			Labels:  p.labels,
+			Entries: []loghttp.Entry{{Timestamp: time.Now(), Line: line}},
+		}},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	backoff := util.NewBackoff(p.ctx, p.backoff)
+	for {
+		var status int
+		status, err = p.send(body)
+		if err == nil {
+			return len(b), nil
+		}
+		// 4xx responses other than 429 will not succeed when retried.
+		if status/100 == 4 && status != http.StatusTooManyRequests {
+			break
+		}
+		fmt.Fprintf(p.w, "error pushing entry, will retry: %s\n", err)
+		backoff.Wait()
+		if !backoff.Ongoing() {
+			break
+		}
+	}
+	pushErrors.Inc()
+	fmt.Fprintf(p.w, "failed to push entry %s: %s\n", line, err)
+	return 0, err
+}
+
+func (p *Push) send(body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, p.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.url, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	if p.user != "" {
+		req.SetBasicAuth(p.user, p.pass)
+	}
+	if p.tenantID != "" {
+		req.Header.Set("X-Scope-OrgID", p.tenantID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		buf, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, fmt.Errorf("server returned HTTP status %s: %s", resp.Status, strings.TrimSpace(string(buf)))
+	}
+	return resp.StatusCode, nil
+}
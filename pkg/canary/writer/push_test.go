@@ -0,0 +1,70 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/loghttp"
+)
+
+type pushRequest struct {
+	tenantID string
+	user     string
+	pass     string
+	body     string
+}
+
+func TestPush(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		requests []pushRequest
+		statuses = []int{http.StatusInternalServerError, http.StatusNoContent, http.StatusBadRequest}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		user, pass, _ := req.BasicAuth()
+		requests = append(requests, pushRequest{tenantID: req.Header.Get("X-Scope-OrgID"), user: user, pass: pass, body: string(body)})
+		w.WriteHeader(statuses[0])
+		statuses = statuses[1:]
+	}))
+	defer srv.Close()
+
+	logs := &bytes.Buffer{}
+	p := NewPush(logs, false, strings.TrimPrefix(srv.URL, "http://"), "tenant1", "user", "pass", time.Second, map[string]string{"name": "loki-canary", "stream": "stdout"})
+	p.backoff.MinBackoff = time.Millisecond
+	p.backoff.MaxBackoff = time.Millisecond
+
+	// The push is retried after the 500
+	n, err := p.Write([]byte("1557935669096040040 ppp\n"))
+	require.NoError(t, err)
+	require.Equal(t, 24, n)
+	require.Len(t, requests, 2)
+	for _, r := range requests {
+		require.Equal(t, pushRequest{tenantID: "tenant1", user: "user", pass: "pass", body: r.body}, r)
+	}
+
+	var decoded loghttp.PushRequest
+	require.NoError(t, json.Unmarshal([]byte(requests[1].body), &decoded))
+	require.Len(t, decoded.Streams, 1)
+	require.Equal(t, loghttp.LabelSet{"name": "loki-canary", "stream": "stdout"}, decoded.Streams[0].Labels)
+	require.Len(t, decoded.Streams[0].Entries, 1)
+	require.Equal(t, "1557935669096040040 ppp", decoded.Streams[0].Entries[0].Line)
+
+	// The push is not retried after the 400
+	_, err = p.Write([]byte("1557935670096040040 ppp\n"))
+	require.Error(t, err)
+	require.Len(t, requests, 3)
+	require.Contains(t, logs.String(), "failed to push entry 1557935670096040040 ppp")
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/cespare/xxhash/v2"
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/go-kit/kit/log/level"
+	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 	"github.com/prometheus/prometheus/pkg/labels"
 
@@ -322,6 +323,12 @@ func (c *MemChunk) Encoding() Encoding {
 	return c.encoding
 }
 
+// Legacy tells whether the chunk has the v1 format, whose blocks are always gzip compressed. New chunks are always
+// written with the v2 format.
+func (c *MemChunk) Legacy() bool {
+	return c.format == chunkFormatV1
+}
+
 // Size implements Chunk.
 func (c *MemChunk) Size() int {
 	ne := 0
@@ -470,23 +477,27 @@ func (c *MemChunk) Iterator(ctx context.Context, mintT, maxtT time.Time, directi
 	mint, maxt := mintT.UnixNano(), maxtT.UnixNano()
 	its := make([]iter.EntryIterator, 0, len(c.blocks)+1)
 
+	var blocks, blocksSize int
 	for _, b := range c.blocks {
 		if maxt < b.mint || b.maxt < mint {
 			continue
 		}
+		blocks++
+		blocksSize += len(b.b)
 		its = append(its, encBlock{c.encoding, b}.Iterator(ctx, lbs, pipeline))
 	}
 
 	if !c.head.isEmpty() {
 		its = append(its, c.head.iterator(ctx, direction, mint, maxt, lbs, pipeline))
 	}
+	sp := c.startIteratorSpan(ctx, "MemChunk.Iterator", blocks, blocksSize)
 
 	if direction == logproto.FORWARD {
-		return iter.NewTimeRangedIterator(
+		return newSpanEntryIterator(sp, iter.NewTimeRangedIterator(
 			iter.NewNonOverlappingIterator(its, ""),
 			time.Unix(0, mint),
 			time.Unix(0, maxt),
-		), nil
+		)), nil
 	}
 	for i, it := range its {
 		r, err := iter.NewEntryReversedIter(
@@ -495,6 +506,9 @@ func (c *MemChunk) Iterator(ctx context.Context, mintT, maxtT time.Time, directi
 				time.Unix(0, maxt),
 			))
 		if err != nil {
+			if sp != nil {
+				sp.Finish()
+			}
 			return nil, err
 		}
 		its[i] = r
@@ -504,7 +518,7 @@ func (c *MemChunk) Iterator(ctx context.Context, mintT, maxtT time.Time, directi
 		its[i], its[j] = its[j], its[i]
 	}
 
-	return iter.NewNonOverlappingIterator(its, ""), nil
+	return newSpanEntryIterator(sp, iter.NewNonOverlappingIterator(its, "")), nil
 }
 
 // Iterator implements Chunk.
@@ -512,10 +526,13 @@ func (c *MemChunk) SampleIterator(ctx context.Context, from, through time.Time,
 	mint, maxt := from.UnixNano(), through.UnixNano()
 	its := make([]iter.SampleIterator, 0, len(c.blocks)+1)
 
+	var blocks, blocksSize int
 	for _, b := range c.blocks {
 		if maxt < b.mint || b.maxt < mint {
 			continue
 		}
+		blocks++
+		blocksSize += len(b.b)
 		its = append(its, encBlock{c.encoding, b}.SampleIterator(ctx, lbs, extractor))
 	}
 
@@ -523,11 +540,62 @@ func (c *MemChunk) SampleIterator(ctx context.Context, from, through time.Time,
 		its = append(its, c.head.sampleIterator(ctx, mint, maxt, lbs, extractor))
 	}
 
-	return iter.NewTimeRangedSampleIterator(
+	return newSpanSampleIterator(c.startIteratorSpan(ctx, "MemChunk.SampleIterator", blocks, blocksSize), iter.NewTimeRangedSampleIterator(
 		iter.NewNonOverlappingSampleIterator(its, ""),
 		mint,
 		maxt,
-	)
+	))
+}
+
+// startIteratorSpan starts the span of the decoding of the blocks by an iterator, tagged with the number of blocks and
+// their compressed size. No span is started when the query isn't traced, the chunks being also iterated outside of
+// queries.
+func (c *MemChunk) startIteratorSpan(ctx context.Context, operation string, blocks, blocksSize int) opentracing.Span {
+	parent := opentracing.SpanFromContext(ctx)
+	if parent == nil {
+		return nil
+	}
+	sp := parent.Tracer().StartSpan(operation, opentracing.ChildOf(parent.Context()))
+	sp.SetTag("blocks", blocks)
+	sp.SetTag("blocks_bytes", blocksSize)
+	sp.SetTag("head_entries", len(c.head.entries))
+	return sp
+}
+
+// spanEntryIterator finishes the span of the blocks decoded by the iterator when it's closed.
+type spanEntryIterator struct {
+	iter.EntryIterator
+	sp opentracing.Span
+}
+
+func newSpanEntryIterator(sp opentracing.Span, it iter.EntryIterator) iter.EntryIterator {
+	if sp == nil {
+		return it
+	}
+	return &spanEntryIterator{EntryIterator: it, sp: sp}
+}
+
+func (it *spanEntryIterator) Close() error {
+	it.sp.Finish()
+	return it.EntryIterator.Close()
+}
+
+// spanSampleIterator finishes the span of the blocks decoded by the iterator when it's closed.
+type spanSampleIterator struct {
+	iter.SampleIterator
+	sp opentracing.Span
+}
+
+func newSpanSampleIterator(sp opentracing.Span, it iter.SampleIterator) iter.SampleIterator {
+	if sp == nil {
+		return it
+	}
+	return &spanSampleIterator{SampleIterator: it, sp: sp}
+}
+
+func (it *spanSampleIterator) Close() error {
+	it.sp.Finish()
+	return it.SampleIterator.Close()
 }
 
 // Blocks implements Chunk
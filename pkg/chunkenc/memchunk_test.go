@@ -13,8 +13,10 @@ import (
 	"time"
 
 	"github.com/dustin/go-humanize"
+	"github.com/opentracing/opentracing-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-client-go"
 
 	"github.com/famarks/loki/pkg/chunkenc/testdata"
 	"github.com/famarks/loki/pkg/iter"
@@ -767,3 +769,55 @@ func TestMemchunkLongLine(t *testing.T) {
 		})
 	}
 }
+
+func TestMemChunk_IteratorSpan(t *testing.T) {
+	reporter := jaeger.NewInMemoryReporter()
+	tr, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), reporter)
+	defer closer.Close()
+
+	c := NewMemChunk(EncGZIP, testBlockSize, testTargetSize)
+	for i := int64(0); i < 3; i++ {
+		require.NoError(t, c.Append(logprotoEntry(i, testdata.LogString(i))))
+		if i < 2 {
+			require.NoError(t, c.cut())
+		}
+	}
+	blocksSize := len(c.blocks[0].b) + len(c.blocks[1].b)
+
+	// Without a span in the context, no span is started.
+	it, err := c.Iterator(context.Background(), time.Unix(0, 0), time.Unix(0, 3), logproto.FORWARD, nil, logql.NoopPipeline)
+	require.NoError(t, err)
+	require.NoError(t, it.Close())
+	require.Equal(t, 0, reporter.SpansSubmitted())
+
+	ctx := opentracing.ContextWithSpan(context.Background(), tr.StartSpan("query"))
+	it, err = c.Iterator(ctx, time.Unix(0, 0), time.Unix(0, 3), logproto.BACKWARD, nil, logql.NoopPipeline)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.True(t, it.Next())
+	}
+	require.Equal(t, 0, reporter.SpansSubmitted())
+	require.NoError(t, it.Close())
+
+	sampleIt := c.SampleIterator(ctx, time.Unix(0, 1), time.Unix(0, 3), nil, log.CountExtractor.ToSampleExtractor(nil, false, false))
+	require.NoError(t, sampleIt.Close())
+
+	spans := reporter.GetSpans()
+	require.Len(t, spans, 2)
+	for i, expected := range []struct {
+		operation  string
+		blocks     int
+		blocksSize int
+	}{
+		{"MemChunk.Iterator", 2, blocksSize},
+		{"MemChunk.SampleIterator", 1, len(c.blocks[1].b)},
+	} {
+		sp := spans[i].(*jaeger.Span)
+		require.Equal(t, expected.operation, sp.OperationName())
+		require.Equal(t, opentracing.Tags{
+			"blocks":       expected.blocks,
+			"blocks_bytes": expected.blocksSize,
+			"head_entries": 1,
+		}, sp.Tags())
+	}
+}
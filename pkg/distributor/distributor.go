@@ -3,13 +3,16 @@ package distributor
 import (
 	"context"
 	"flag"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	cortex_distributor "github.com/cortexproject/cortex/pkg/distributor"
 	"github.com/cortexproject/cortex/pkg/ring"
 	ring_client "github.com/cortexproject/cortex/pkg/ring/client"
 	cortex_util "github.com/cortexproject/cortex/pkg/util"
+	cortex_flagext "github.com/cortexproject/cortex/pkg/util/flagext"
 	"github.com/cortexproject/cortex/pkg/util/limiter"
 	"github.com/cortexproject/cortex/pkg/util/services"
 	"github.com/pkg/errors"
@@ -28,6 +31,9 @@ import (
 	"github.com/famarks/loki/pkg/util/validation"
 )
 
+// DefaultMaxPushBodySize is the default max size of the push request bodies once decompressed.
+const DefaultMaxPushBodySize = 100 << 20
+
 var (
 	ingesterAppends = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "loki",
@@ -57,6 +63,15 @@ type Config struct {
 	// Distributors ring
 	DistributorRing cortex_distributor.RingConfig `yaml:"ring,omitempty"`
 
+	// Labels which are not taken into account when hashing streams to ingesters.
+	ShardingIgnoredLabels cortex_flagext.StringSliceCSV `yaml:"sharding_ignored_labels"`
+
+	// Label holding the log line of series pushed through the remote write endpoint.
+	RemoteWriteLineLabel string `yaml:"remote_write_line_label"`
+
+	// Max size of the push request bodies once decompressed.
+	MaxPushBodySize int `yaml:"max_push_body_size"`
+
 	// For testing.
 	factory ring_client.PoolFactory `yaml:"-"`
 }
@@ -64,6 +79,9 @@ type Config struct {
 // RegisterFlags registers the flags.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.DistributorRing.RegisterFlags(f)
+	f.StringVar(&cfg.RemoteWriteLineLabel, "distributor.remote-write-line-label", "line", "Label of series pushed through the Prometheus remote write endpoint holding the log line. Series without this label use their sample value as the log line.")
+	f.IntVar(&cfg.MaxPushBodySize, "distributor.max-push-body-size", DefaultMaxPushBodySize, "Max size in bytes of the bodies of the push requests once decompressed, the larger ones being rejected with 413 Request Entity Too Large.")
+	f.Var(&cfg.ShardingIgnoredLabels, "distributor.sharding-ignored-labels", "Comma separated list of label names ignored when hashing streams to ingesters, so that streams only differing by these labels are sent to the same ingesters.")
 }
 
 // Distributor coordinates replicates and distribution of log streams.
@@ -74,8 +92,12 @@ type Distributor struct {
 	clientCfg     client.Config
 	ingestersRing ring.ReadRing
 	validator     *Validator
+	limits        Limits
 	pool          *ring_client.Pool
 
+	// Label names ignored when computing the ring token of a stream.
+	shardingIgnoredLabels map[string]struct{}
+
 	// The global rate limiter requires a distributors ring to count
 	// the number of healthy instances.
 	distributorsRing *ring.Lifecycler
@@ -120,14 +142,24 @@ func New(cfg Config, clientCfg client.Config, ingestersRing ring.ReadRing, overr
 		ingestionRateStrategy = newLocalIngestionRateStrategy(overrides)
 	}
 
+	var shardingIgnoredLabels map[string]struct{}
+	if len(cfg.ShardingIgnoredLabels) > 0 {
+		shardingIgnoredLabels = make(map[string]struct{}, len(cfg.ShardingIgnoredLabels))
+		for _, name := range cfg.ShardingIgnoredLabels {
+			shardingIgnoredLabels[name] = struct{}{}
+		}
+	}
+
 	d := Distributor{
-		cfg:                  cfg,
-		clientCfg:            clientCfg,
-		ingestersRing:        ingestersRing,
-		distributorsRing:     distributorsRing,
-		validator:            validator,
-		pool:                 cortex_distributor.NewPool(clientCfg.PoolConfig, ingestersRing, factory, cortex_util.Logger),
-		ingestionRateLimiter: limiter.NewRateLimiter(ingestionRateStrategy, 10*time.Second),
+		cfg:                   cfg,
+		shardingIgnoredLabels: shardingIgnoredLabels,
+		clientCfg:             clientCfg,
+		ingestersRing:         ingestersRing,
+		distributorsRing:      distributorsRing,
+		validator:             validator,
+		limits:                overrides,
+		pool:                  cortex_distributor.NewPool(clientCfg.PoolConfig, ingestersRing, factory, cortex_util.Logger),
+		ingestionRateLimiter:  limiter.NewRateLimiter(ingestionRateStrategy, 10*time.Second),
 	}
 
 	servs = append(servs, d.pool)
@@ -204,15 +236,22 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 	validatedSamplesSize := 0
 	validatedSamplesCount := 0
 
+	now := time.Now()
+	vCtx := d.validator.getValidationContextFor(now, userID)
+	sanitizer := newLineSanitizer(d.limits, userID)
+
 	for _, stream := range req.Streams {
-		if err := d.validator.ValidateLabels(userID, stream); err != nil {
+		if err := d.validator.ValidateLabels(vCtx, stream); err != nil {
 			validationErr = err
 			continue
 		}
 
 		entries := make([]logproto.Entry, 0, len(stream.Entries))
 		for _, entry := range stream.Entries {
-			if err := d.validator.ValidateEntry(userID, stream.Labels, entry); err != nil {
+			if sanitizer.enabled() {
+				entry.Line = sanitizer.sanitize(entry.Line)
+			}
+			if err := d.validator.ValidateEntry(vCtx, stream.Labels, entry); err != nil {
 				validationErr = err
 				continue
 			}
@@ -225,7 +264,7 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 			continue
 		}
 		stream.Entries = entries
-		keys = append(keys, util.TokenFor(userID, stream.Labels))
+		keys = append(keys, d.tokenFor(userID, stream.Labels))
 		streams = append(streams, streamTracker{
 			stream: stream,
 		})
@@ -235,12 +274,10 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 		return &logproto.PushResponse{}, validationErr
 	}
 
-	now := time.Now()
 	if !d.ingestionRateLimiter.AllowN(now, userID, validatedSamplesSize) {
 		// Return a 429 to indicate to the client they are being rate limited
-		validation.DiscardedSamples.WithLabelValues(validation.RateLimited, userID).Add(float64(validatedSamplesCount))
-		validation.DiscardedBytes.WithLabelValues(validation.RateLimited, userID).Add(float64(validatedSamplesSize))
-		return nil, httpgrpc.Errorf(http.StatusTooManyRequests, validation.RateLimitedErrorMsg(int(d.ingestionRateLimiter.Limit(now, userID)), validatedSamplesCount, validatedSamplesSize))
+		validation.Discards.Record(validation.RateLimited, userID, "", validatedSamplesCount, validatedSamplesSize)
+		return nil, rateLimitedError(d.ingestionRateLimiter.Limit(now, userID), d.ingestionRateLimiter.Burst(now, userID), validatedSamplesCount, validatedSamplesSize)
 	}
 
 	const maxExpectedReplicationSet = 5 // typical replication factor 3 plus one for inactive plus one for luck
@@ -289,6 +326,48 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 	}
 }
 
+// tokenFor returns the ring token of a stream, ignoring the configured sharding ignored labels.
+func (d *Distributor) tokenFor(userID, labels string) uint32 {
+	if len(d.shardingIgnoredLabels) == 0 {
+		return util.TokenFor(userID, labels)
+	}
+
+	// The labels have already been validated, so parsing can't fail here.
+	ls, err := util.ParseLabels(labels)
+	if err != nil {
+		return util.TokenFor(userID, labels)
+	}
+	kept := ls[:0]
+	for _, l := range ls {
+		if _, ok := d.shardingIgnoredLabels[l.Name]; !ok {
+			kept = append(kept, l)
+		}
+	}
+	return util.TokenFor(userID, kept.String())
+}
+
+// rateLimitedError returns a 429 error carrying hints for clients on how long to back off:
+// Retry-After is the number of seconds the limiter needs to accumulate enough budget for
+// a push of the same size, capped to the burst size since the bucket never holds more.
+func rateLimitedError(limit float64, burst, lines, bytes int) error {
+	headers := []*httpgrpc.Header{
+		{Key: RateLimitHeader, Values: []string{strconv.Itoa(int(limit))}},
+		{Key: RateLimitBurstHeader, Values: []string{strconv.Itoa(burst)}},
+	}
+	if limit > 0 {
+		needed := bytes
+		if needed > burst {
+			needed = burst
+		}
+		retryAfter := int(math.Ceil(float64(needed) / limit))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		headers = append(headers, &httpgrpc.Header{Key: RetryAfterHeader, Values: []string{strconv.Itoa(retryAfter)}})
+	}
+	return validationError(http.StatusTooManyRequests, validation.RateLimited, validation.RateLimitedErrorMsg(int(limit), lines, bytes), headers...)
+}
+
 // TODO taken from Cortex, see if we can refactor out an usable interface.
 func (d *Distributor) sendSamples(ctx context.Context, ingester ring.IngesterDesc, streamTrackers []*streamTracker, pushTracker *pushTracker) {
 	err := d.sendSamplesErr(ctx, ingester, streamTrackers)
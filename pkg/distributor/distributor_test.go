@@ -64,7 +64,7 @@ func TestDistributor(t *testing.T) {
 			lines:            100,
 			maxLineSize:      1,
 			expectedResponse: success,
-			expectedError:    httpgrpc.Errorf(http.StatusBadRequest, validation.LineTooLongErrorMsg(1, 10, "{foo=\"bar\"}")),
+			expectedError:    validationError(http.StatusBadRequest, validation.LineTooLong, validation.LineTooLongErrorMsg(1, 10, "{foo=\"bar\"}")),
 		},
 		{
 			lines:            100,
@@ -92,7 +92,7 @@ func TestDistributor(t *testing.T) {
 
 			response, err := d.Push(ctx, request)
 			assert.Equal(t, tc.expectedResponse, response)
-			assert.Equal(t, tc.expectedError, err)
+			assertHTTPGRPCError(t, tc.expectedError, err)
 		})
 	}
 }
@@ -187,13 +187,80 @@ func TestDistributor_PushIngestionRateLimiter(t *testing.T) {
 					assert.Nil(t, err)
 				} else {
 					assert.Nil(t, response)
-					assert.Equal(t, push.expectedError, err)
+					assertHTTPGRPCError(t, push.expectedError, err)
 				}
 			}
 		})
 	}
 }
 
+func TestDistributor_TokenForIgnoresShardingLabels(t *testing.T) {
+	d := &Distributor{}
+	require.NotEqual(t, d.tokenFor("test", `{app="foo", pod="a"}`), d.tokenFor("test", `{app="foo", pod="b"}`))
+
+	d.shardingIgnoredLabels = map[string]struct{}{"pod": {}}
+	require.Equal(t, d.tokenFor("test", `{app="foo", pod="a"}`), d.tokenFor("test", `{app="foo", pod="b"}`))
+	require.Equal(t, d.tokenFor("test", `{app="foo", pod="a"}`), d.tokenFor("test", `{app="foo"}`))
+	require.NotEqual(t, d.tokenFor("test", `{app="foo", pod="a"}`), d.tokenFor("test", `{app="bar", pod="a"}`))
+	require.NotEqual(t, d.tokenFor("test", `{app="foo"}`), d.tokenFor("other", `{app="foo"}`))
+}
+
+func TestDistributor_RateLimitedHeaders(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.IngestionRateMB = 10 * (1.0 / float64(bytesInMB))
+	limits.IngestionBurstSizeMB = 20 * (1.0 / float64(bytesInMB))
+
+	d := prepare(t, limits, nil)
+	defer services.StopAndAwaitTerminated(context.Background(), d) //nolint:errcheck
+
+	for _, tc := range []struct {
+		bytes      int
+		retryAfter string
+	}{
+		{bytes: 15},
+		{bytes: 15, retryAfter: "2"},
+		// pushes bigger than the burst only need to wait for the bucket to refill.
+		{bytes: 50, retryAfter: "2"},
+	} {
+		_, err := d.Push(ctx, makeWriteRequest(1, tc.bytes))
+		if tc.retryAfter == "" {
+			require.NoError(t, err)
+			continue
+		}
+		resp, ok := httpgrpc.HTTPResponseFromError(err)
+		require.True(t, ok)
+		require.Equal(t, int32(http.StatusTooManyRequests), resp.Code)
+
+		headers := http.Header{}
+		for _, h := range resp.Headers {
+			for _, v := range h.Values {
+				headers.Add(h.Key, v)
+			}
+		}
+		require.Equal(t, validation.RateLimited, headers.Get(DiscardReasonHeader))
+		require.Equal(t, tc.retryAfter, headers.Get(RetryAfterHeader))
+		require.Equal(t, "10", headers.Get(RateLimitHeader))
+		require.Equal(t, "20", headers.Get(RateLimitBurstHeader))
+	}
+}
+
+// assertHTTPGRPCError checks that actual has the same status code and body as expected,
+// ignoring the headers set on the response.
+func assertHTTPGRPCError(t *testing.T, expected, actual error) {
+	t.Helper()
+	if expected == nil {
+		assert.NoError(t, actual)
+		return
+	}
+	expectedResp, ok := httpgrpc.HTTPResponseFromError(expected)
+	require.True(t, ok)
+	actualResp, ok := httpgrpc.HTTPResponseFromError(actual)
+	require.True(t, ok, "expected an httpgrpc error, got %v", actual)
+	assert.Equal(t, expectedResp.Code, actualResp.Code)
+	assert.Equal(t, string(expectedResp.Body), string(actualResp.Body))
+}
+
 // loopbackInterfaceName search for the name of a loopback interface in the list
 // of the system's network interfaces.
 func loopbackInterfaceName() (string, error) {
@@ -240,6 +307,7 @@ func prepare(t *testing.T, limits *validation.Limits, kvStore kv.Client) *Distri
 	require.NoError(t, err)
 
 	distributorConfig.DistributorRing.HeartbeatPeriod = 100 * time.Millisecond
+	distributorConfig.MaxPushBodySize = 4 << 20
 	distributorConfig.DistributorRing.InstanceID = strconv.Itoa(rand.Int())
 	distributorConfig.DistributorRing.KVStore.Mock = kvStore
 	distributorConfig.DistributorRing.InstanceInterfaceNames = []string{loopbackName}
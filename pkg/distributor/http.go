@@ -1,9 +1,17 @@
 package distributor
 
 import (
-	"math"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"strings"
 
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
 	"github.com/weaveworks/common/httpgrpc"
 
 	"github.com/cortexproject/cortex/pkg/util"
@@ -14,54 +22,228 @@ import (
 	unmarshal_legacy "github.com/famarks/loki/pkg/logql/unmarshal/legacy"
 )
 
-var contentType = http.CanonicalHeaderKey("Content-Type")
+var (
+	contentType     = http.CanonicalHeaderKey("Content-Type")
+	contentEncoding = http.CanonicalHeaderKey("Content-Encoding")
+	acceptEncoding  = http.CanonicalHeaderKey("Accept-Encoding")
+)
+
+// supportedContentEncodings are the Content-Encoding values accepted on push requests,
+// advertised to clients through the Accept-Encoding response header.
+const supportedContentEncodings = "gzip, zstd"
 
 const applicationJSON = "application/json"
 
+// DiscardReasonHeader is set on push responses rejected by validation or rate limiting
+// to the reason the entries were discarded, e.g. "line_too_long" or "rate_limited".
+const DiscardReasonHeader = "X-Loki-Discard-Reason"
+
+// Headers set on pushes rejected because of the ingestion rate limit.
+const (
+	RetryAfterHeader     = "Retry-After"
+	RateLimitHeader      = "X-RateLimit-Limit"
+	RateLimitBurstHeader = "X-RateLimit-Burst"
+)
+
+// pushError is the JSON body returned to clients that accept application/json
+// when a push is rejected. It follows the Prometheus API error format.
+type pushError struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType,omitempty"`
+	Error     string `json:"error"`
+}
+
 // PushHandler reads a snappy-compressed proto from the HTTP body.
 func (d *Distributor) PushHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(acceptEncoding, supportedContentEncodings)
 
-	req, err := ParseRequest(r)
+	req, err := ParseRequest(r, d.cfg.MaxPushBodySize)
 	if err != nil {
+		switch err.(type) {
+		case unsupportedEncodingError:
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		case requestTooLargeError:
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	_, err = d.Push(r.Context(), req)
+	d.push(w, r, req)
+}
+
+// push sends req to the ingesters and writes the outcome to w, exposing the
+// discard reason and any rate limiting hints to the client.
+func (d *Distributor) push(w http.ResponseWriter, r *http.Request, req *logproto.PushRequest) {
+	_, err := d.Push(r.Context(), req)
 	if err == nil {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
 	resp, ok := httpgrpc.HTTPResponseFromError(err)
-	if ok {
-		http.Error(w, string(resp.Body), int(resp.Code))
-	} else {
+	if !ok {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var reason string
+	for _, h := range resp.Headers {
+		for _, v := range h.Values {
+			w.Header().Add(h.Key, v)
+		}
+		if http.CanonicalHeaderKey(h.Key) == DiscardReasonHeader && len(h.Values) > 0 {
+			reason = h.Values[0]
+		}
+	}
+
+	if !acceptsJSON(r) {
+		http.Error(w, string(resp.Body), int(resp.Code))
+		return
 	}
+
+	w.Header().Set(contentType, applicationJSON)
+	w.WriteHeader(int(resp.Code))
+	_ = json.NewEncoder(w).Encode(pushError{
+		Status:    "error",
+		ErrorType: reason,
+		Error:     string(resp.Body),
+	})
 }
 
-func ParseRequest(r *http.Request) (*logproto.PushRequest, error) {
+func acceptsJSON(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaType := range strings.Split(accept, ",") {
+			if strings.HasPrefix(strings.TrimSpace(mediaType), applicationJSON) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type unsupportedEncodingError string
+
+func (e unsupportedEncodingError) Error() string {
+	return fmt.Sprintf("unsupported Content-Encoding %q, supported encodings are: %s", string(e), supportedContentEncodings)
+}
+
+type requestTooLargeError int
+
+func (e requestTooLargeError) Error() string {
+	return fmt.Sprintf("request body larger than max (%d bytes) once decompressed", int(e))
+}
+
+// ParseRequest decodes a push request, either JSON or a proto. Protos are expected to be
+// snappy-compressed, unless a gzip or zstd Content-Encoding is used instead. The body is
+// read up to maxSize bytes once decompressed, requestTooLargeError being returned past it,
+// including when the snappy-compressed proto would decode past it.
+func ParseRequest(r *http.Request, maxSize int) (*logproto.PushRequest, error) {
 	var req logproto.PushRequest
 
+	decoded, expectedSize, compression, err := decodedBody(r, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	defer decoded.Close()
+	body := &maxSizeReader{r: decoded, max: maxSize}
+
 	switch r.Header.Get(contentType) {
 	case applicationJSON:
-		var err error
-
 		if loghttp.GetVersion(r.RequestURI) == loghttp.VersionV1 {
-			err = unmarshal.DecodePushRequest(r.Body, &req)
+			err = unmarshal.DecodePushRequest(body, &req)
 		} else {
-			err = unmarshal_legacy.DecodePushRequest(r.Body, &req)
-		}
-
-		if err != nil {
-			return nil, err
+			err = unmarshal_legacy.DecodePushRequest(body, &req)
 		}
 
 	default:
-		if err := util.ParseProtoReader(r.Context(), r.Body, int(r.ContentLength), math.MaxInt32, &req, util.RawSnappy); err != nil {
-			return nil, err
+		if compression == util.RawSnappy {
+			err = parseSnappyProto(body, maxSize, &req)
+		} else {
+			err = util.ParseProtoReader(r.Context(), body, expectedSize, maxSize, &req, compression)
 		}
 	}
+	// the decoders don't all return the errors of the reader as is.
+	if body.exceeded() {
+		return nil, requestTooLargeError(maxSize)
+	}
+	if err != nil {
+		return nil, err
+	}
 	return &req, nil
 }
+
+// parseSnappyProto decodes the snappy-compressed proto of the body, checking its decoded length
+// before decoding it, for a small body not to be inflated past maxSize.
+func parseSnappyProto(body io.Reader, maxSize int, msg proto.Message) error {
+	buf, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	size, err := snappy.DecodedLen(buf)
+	if err != nil {
+		return err
+	}
+	if size > maxSize {
+		return requestTooLargeError(maxSize)
+	}
+	decoded, err := snappy.Decode(nil, buf)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(decoded, msg)
+}
+
+// decodedBody returns the request body decoded according to its Content-Encoding,
+// its expected size, which is unknown (0) once decompressing, and the compression
+// left to apply to proto payloads.
+func decodedBody(r *http.Request, maxSize int) (io.ReadCloser, int, util.CompressionType, error) {
+	switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get(contentEncoding))); encoding {
+	case "", "identity":
+		return r.Body, int(r.ContentLength), util.RawSnappy, nil
+	case "gzip":
+		gr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, 0, util.NoCompression, err
+		}
+		return gr, 0, util.NoCompression, nil
+	case "zstd":
+		zr, err := zstd.NewReader(r.Body, zstd.WithDecoderMaxMemory(uint64(maxSize)))
+		if err != nil {
+			return nil, 0, util.NoCompression, err
+		}
+		return zr.IOReadCloser(), 0, util.NoCompression, nil
+	default:
+		return nil, 0, util.NoCompression, unsupportedEncodingError(encoding)
+	}
+}
+
+// maxSizeReader fails the reads past max bytes, for a small compressed body not to be
+// inflated without bound.
+type maxSizeReader struct {
+	r    io.Reader
+	max  int
+	read int
+}
+
+func (m *maxSizeReader) Read(p []byte) (int, error) {
+	if m.exceeded() {
+		return 0, requestTooLargeError(m.max)
+	}
+	// one more byte than allowed is read for telling whether the body is larger.
+	if len(p) > m.max-m.read+1 {
+		p = p[:m.max-m.read+1]
+	}
+	n, err := m.r.Read(p)
+	m.read += n
+	if m.exceeded() {
+		return n, requestTooLargeError(m.max)
+	}
+	return n, err
+}
+
+func (m *maxSizeReader) exceeded() bool {
+	return m.read > m.max
+}
@@ -0,0 +1,188 @@
+package distributor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/famarks/loki/pkg/logproto"
+	"github.com/famarks/loki/pkg/util/validation"
+)
+
+func TestPushHandler_DiscardReason(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.MaxLineSize = 1
+	d := prepare(t, limits, nil)
+
+	body := `{"streams":[{"stream":{"foo":"bar"},"values":[["1","too long"]]}]}`
+
+	for _, tc := range []struct {
+		name   string
+		accept string
+		json   bool
+	}{
+		{name: "plain text", accept: ""},
+		{name: "json", accept: "text/plain, application/json;q=0.9", json: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/loki/api/v1/push", strings.NewReader(body))
+			req = req.WithContext(user.InjectOrgID(req.Context(), "test"))
+			req.Header.Set("Content-Type", applicationJSON)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			d.PushHandler(rec, req)
+
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+			require.Equal(t, validation.LineTooLong, rec.Header().Get(DiscardReasonHeader))
+			if !tc.json {
+				require.Contains(t, rec.Body.String(), "Max entry size")
+				return
+			}
+			var resp pushError
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			require.Equal(t, "error", resp.Status)
+			require.Equal(t, validation.LineTooLong, resp.ErrorType)
+			require.Contains(t, resp.Error, "Max entry size")
+		})
+	}
+}
+
+func TestParseRequest_ContentEncoding(t *testing.T) {
+	body := `{"streams":[{"stream":{"foo":"bar"},"values":[["1","hello, world"]]}]}`
+
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, err := gw.Write([]byte(body))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	for _, tc := range []struct {
+		name     string
+		encoding string
+		body     string
+		err      bool
+	}{
+		{name: "identity", body: body},
+		{name: "gzip", encoding: "gzip", body: gzipped.String()},
+		{name: "zstd", encoding: "zstd", body: zstdRawFrame(body)},
+		{name: "invalid zstd", encoding: "zstd", body: body, err: true},
+		{name: "unsupported", encoding: "br", body: body, err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/loki/api/v1/push", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", applicationJSON)
+			if tc.encoding != "" {
+				req.Header.Set("Content-Encoding", tc.encoding)
+			}
+			pushReq, err := ParseRequest(req, 1<<20)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, pushReq.Streams, 1)
+			require.Equal(t, "hello, world", pushReq.Streams[0].Entries[0].Line)
+		})
+	}
+}
+
+func TestPushHandler_UnsupportedEncoding(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	d := prepare(t, limits, nil)
+
+	req := httptest.NewRequest("POST", "/loki/api/v1/push", strings.NewReader("{}"))
+	req.Header.Set("Content-Encoding", "br")
+	rec := httptest.NewRecorder()
+
+	d.PushHandler(rec, req)
+
+	require.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	require.Equal(t, supportedContentEncodings, rec.Header().Get("Accept-Encoding"))
+}
+
+func TestPushHandler_DecompressedTooLarge(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	d := prepare(t, limits, nil)
+
+	// a few KB of compressed body inflating past the max size.
+	body := `{"streams":[{"stream":{"foo":"bar"},"values":[["1","` + strings.Repeat("a", 8<<20) + `"]]}]}`
+
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, err := gw.Write([]byte(body))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	zw, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	zstded := zw.EncodeAll([]byte(body), nil)
+	require.NoError(t, zw.Close())
+
+	pushReq := logproto.PushRequest{Streams: []logproto.Stream{{
+		Labels:  `{foo="bar"}`,
+		Entries: []logproto.Entry{{Timestamp: time.Unix(1, 0), Line: strings.Repeat("a", 8<<20)}},
+	}}}
+	buf, err := proto.Marshal(&pushReq)
+	require.NoError(t, err)
+	snappied := snappy.Encode(nil, buf)
+
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		encoding    string
+		body        []byte
+	}{
+		{name: "gzip", contentType: applicationJSON, encoding: "gzip", body: gzipped.Bytes()},
+		{name: "zstd", contentType: applicationJSON, encoding: "zstd", body: zstded},
+		{name: "snappy", contentType: "application/x-protobuf", body: snappied},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Less(t, len(tc.body), d.cfg.MaxPushBodySize)
+
+			req := httptest.NewRequest("POST", "/loki/api/v1/push", bytes.NewReader(tc.body))
+			req = req.WithContext(user.InjectOrgID(req.Context(), "test"))
+			req.Header.Set("Content-Type", tc.contentType)
+			req.Header.Set("Content-Encoding", tc.encoding)
+			rec := httptest.NewRecorder()
+
+			d.PushHandler(rec, req)
+
+			require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		})
+	}
+}
+
+// zstdRawFrame wraps b in a single segment zstd frame made of one raw block.
+func zstdRawFrame(b string) string {
+	var frame bytes.Buffer
+	frame.Write([]byte{0x28, 0xb5, 0x2f, 0xfd})
+	// Frame header descriptor: single segment with a 4 bytes frame content size.
+	frame.WriteByte(0xa0)
+	size := make([]byte, 4)
+	binary.LittleEndian.PutUint32(size, uint32(len(b)))
+	frame.Write(size)
+	// Block header: last block, raw block type and its size.
+	header := uint32(1) | uint32(len(b))<<3
+	frame.Write([]byte{byte(header), byte(header >> 8), byte(header >> 16)})
+	frame.WriteString(b)
+	return frame.String()
+}
@@ -13,4 +13,8 @@ type Limits interface {
 	CreationGracePeriod(userID string) time.Duration
 	RejectOldSamples(userID string) bool
 	RejectOldSamplesMaxAge(userID string) time.Duration
+
+	SanitizeStripANSI(userID string) bool
+	SanitizeInvalidUTF8(userID string) bool
+	SanitizeTrimTrailingWhitespace(userID string) bool
 }
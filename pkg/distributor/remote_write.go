@@ -0,0 +1,97 @@
+package distributor
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/prompb"
+
+	"github.com/famarks/loki/pkg/logproto"
+)
+
+// RemoteWriteHandler accepts a snappy-compressed Prometheus remote write request and pushes
+// it as log streams, for agents which can only speak the remote write protocol.
+func (d *Distributor) RemoteWriteHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := ParseRemoteWriteRequest(r, d.cfg.RemoteWriteLineLabel, d.cfg.MaxPushBodySize)
+	if err != nil {
+		if _, ok := err.(requestTooLargeError); ok {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	d.push(w, r, req)
+}
+
+// ParseRemoteWriteRequest decodes a Prometheus remote write request into a push request.
+//
+// Each sample becomes a log entry at the sample timestamp. The log line is the value of the
+// lineLabel label of the series, which is removed from the stream labels; series without
+// this label have their sample value formatted as the log line instead. The request is
+// read up to maxSize bytes once decompressed, requestTooLargeError being returned past it.
+func ParseRemoteWriteRequest(r *http.Request, lineLabel string, maxSize int) (*logproto.PushRequest, error) {
+	var wr prompb.WriteRequest
+	if err := parseSnappyProto(&maxSizeReader{r: r.Body, max: maxSize}, maxSize, &wr); err != nil {
+		return nil, err
+	}
+	return remoteWriteToPushRequest(wr, lineLabel), nil
+}
+
+func remoteWriteToPushRequest(wr prompb.WriteRequest, lineLabel string) *logproto.PushRequest {
+	var (
+		streams = map[string]*logproto.Stream{}
+		keys    []string
+	)
+
+	for _, ts := range wr.Timeseries {
+		var (
+			line    string
+			hasLine bool
+			ls      = make(labels.Labels, 0, len(ts.Labels))
+		)
+		for _, l := range ts.Labels {
+			if l.Name == lineLabel {
+				line, hasLine = l.Value, true
+				continue
+			}
+			ls = append(ls, labels.Label{Name: l.Name, Value: l.Value})
+		}
+		sort.Sort(ls)
+
+		key := ls.String()
+		stream, ok := streams[key]
+		if !ok {
+			stream = &logproto.Stream{Labels: key}
+			streams[key] = stream
+			keys = append(keys, key)
+		}
+
+		for _, s := range ts.Samples {
+			entryLine := line
+			if !hasLine {
+				entryLine = strconv.FormatFloat(s.Value, 'f', -1, 64)
+			}
+			stream.Entries = append(stream.Entries, logproto.Entry{
+				Timestamp: time.Unix(0, s.Timestamp*int64(time.Millisecond)),
+				Line:      entryLine,
+			})
+		}
+	}
+
+	req := &logproto.PushRequest{Streams: make([]logproto.Stream, 0, len(keys))}
+	for _, key := range keys {
+		stream := streams[key]
+		// Several series can be merged into the same stream, so entries need
+		// to be ordered again as ingesters reject out of order entries.
+		sort.SliceStable(stream.Entries, func(i, j int) bool {
+			return stream.Entries[i].Timestamp.Before(stream.Entries[j].Timestamp)
+		})
+		req.Streams = append(req.Streams, *stream)
+	}
+	return req
+}
@@ -0,0 +1,89 @@
+package distributor
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/famarks/loki/pkg/logproto"
+	"github.com/famarks/loki/pkg/util/validation"
+)
+
+func TestParseRemoteWriteRequest(t *testing.T) {
+	wr := prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{
+			{
+				Labels:  []prompb.Label{{Name: "job", Value: "agent"}, {Name: "line", Value: "second"}},
+				Samples: []prompb.Sample{{Timestamp: 2000}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: "line", Value: "first"}, {Name: "job", Value: "agent"}},
+				Samples: []prompb.Sample{{Timestamp: 1000}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: "job", Value: "metrics"}, {Name: "__name__", Value: "up"}},
+				Samples: []prompb.Sample{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 0.5}},
+			},
+		},
+	}
+	b, err := wr.Marshal()
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/loki/api/v1/remote_write", bytes.NewReader(snappy.Encode(nil, b)))
+	pushReq, err := ParseRemoteWriteRequest(req, "line", DefaultMaxPushBodySize)
+	require.NoError(t, err)
+
+	require.Equal(t, []logproto.Stream{
+		{
+			Labels: `{job="agent"}`,
+			Entries: []logproto.Entry{
+				{Timestamp: time.Unix(1, 0), Line: "first"},
+				{Timestamp: time.Unix(2, 0), Line: "second"},
+			},
+		},
+		{
+			Labels: `{__name__="up", job="metrics"}`,
+			Entries: []logproto.Entry{
+				{Timestamp: time.Unix(1, 0), Line: "1"},
+				{Timestamp: time.Unix(2, 0), Line: "0.5"},
+			},
+		},
+	}, pushReq.Streams)
+}
+
+func TestRemoteWriteHandler_TooLarge(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	d := prepare(t, limits, nil)
+
+	// a few KB of snappy-compressed request decoding past the max size.
+	wr := prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{
+			{
+				Labels:  []prompb.Label{{Name: "job", Value: "agent"}, {Name: "line", Value: strings.Repeat("a", 8<<20)}},
+				Samples: []prompb.Sample{{Timestamp: 1000}},
+			},
+		},
+	}
+	b, err := wr.Marshal()
+	require.NoError(t, err)
+	body := snappy.Encode(nil, b)
+	require.Less(t, len(body), d.cfg.MaxPushBodySize)
+
+	req := httptest.NewRequest("POST", "/loki/api/v1/remote_write", bytes.NewReader(body))
+	req = req.WithContext(user.InjectOrgID(req.Context(), "test"))
+	rec := httptest.NewRecorder()
+
+	d.RemoteWriteHandler(rec, req)
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
@@ -0,0 +1,41 @@
+package distributor
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/famarks/loki/pkg/util"
+)
+
+// lineSanitizer rewrites log lines according to the tenant sanitization settings,
+// so that downstream filters and deduplication don't depend on the agent which sent them.
+type lineSanitizer struct {
+	stripANSI              bool
+	invalidUTF8            bool
+	trimTrailingWhitespace bool
+}
+
+func newLineSanitizer(limits Limits, userID string) lineSanitizer {
+	return lineSanitizer{
+		stripANSI:              limits.SanitizeStripANSI(userID),
+		invalidUTF8:            limits.SanitizeInvalidUTF8(userID),
+		trimTrailingWhitespace: limits.SanitizeTrimTrailingWhitespace(userID),
+	}
+}
+
+func (s lineSanitizer) enabled() bool {
+	return s.stripANSI || s.invalidUTF8 || s.trimTrailingWhitespace
+}
+
+func (s lineSanitizer) sanitize(line string) string {
+	if s.stripANSI {
+		line = util.StripANSI(line)
+	}
+	if s.invalidUTF8 && !utf8.ValidString(line) {
+		line = strings.ToValidUTF8(line, string(utf8.RuneError))
+	}
+	if s.trimTrailingWhitespace {
+		line = strings.TrimRight(line, " \t\r\n")
+	}
+	return line
+}
@@ -0,0 +1,50 @@
+package distributor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineSanitizer(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		sanitizer lineSanitizer
+		line      string
+		expected  string
+	}{
+		{
+			name:     "disabled",
+			line:     "\x1b[31mred\x1b[0m \xff\n",
+			expected: "\x1b[31mred\x1b[0m \xff\n",
+		},
+		{
+			name:      "strip ansi",
+			sanitizer: lineSanitizer{stripANSI: true},
+			line:      "\x1b[31mred\x1b[0m text",
+			expected:  "red text",
+		},
+		{
+			name:      "invalid utf8",
+			sanitizer: lineSanitizer{invalidUTF8: true},
+			line:      "bad \xff\xfe bytes",
+			expected:  "bad � bytes",
+		},
+		{
+			name:      "trim trailing whitespace",
+			sanitizer: lineSanitizer{trimTrailingWhitespace: true},
+			line:      "  line \t\r\n\n",
+			expected:  "  line",
+		},
+		{
+			name:      "all",
+			sanitizer: lineSanitizer{stripANSI: true, invalidUTF8: true, trimTrailingWhitespace: true},
+			line:      "\x1b[1mbold\x1b[0m \xff \x1b[0m\n",
+			expected:  "bold �",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.sanitizer.sanitize(tc.line))
+		})
+	}
+}
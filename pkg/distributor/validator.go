@@ -25,35 +25,64 @@ func NewValidator(l Limits) (*Validator, error) {
 	return &Validator{l}, nil
 }
 
+type validationContext struct {
+	userID string
+
+	rejectOldSample       bool
+	rejectOldSampleMaxAge time.Time
+	creationGracePeriod   time.Time
+
+	maxLineSize int
+
+	maxLabelNamesPerSeries int
+	maxLabelNameLength     int
+	maxLabelValueLength    int
+}
+
+// getValidationContextFor resolves the tenant limits and timestamp bounds once per request,
+// so that every entry of a push is validated against the same window.
+func (v Validator) getValidationContextFor(now time.Time, userID string) validationContext {
+	return validationContext{
+		userID:                userID,
+		rejectOldSample:       v.RejectOldSamples(userID),
+		rejectOldSampleMaxAge: now.Add(-v.RejectOldSamplesMaxAge(userID)),
+		creationGracePeriod:   now.Add(v.CreationGracePeriod(userID)),
+		maxLineSize:           v.MaxLineSize(userID),
+
+		maxLabelNamesPerSeries: v.MaxLabelNamesPerSeries(userID),
+		maxLabelNameLength:     v.MaxLabelNameLength(userID),
+		maxLabelValueLength:    v.MaxLabelValueLength(userID),
+	}
+}
+
 // ValidateEntry returns an error if the entry is invalid
-func (v Validator) ValidateEntry(userID string, labels string, entry logproto.Entry) error {
-	if v.RejectOldSamples(userID) && entry.Timestamp.UnixNano() < time.Now().Add(-v.RejectOldSamplesMaxAge(userID)).UnixNano() {
-		validation.DiscardedSamples.WithLabelValues(validation.GreaterThanMaxSampleAge, userID).Inc()
-		validation.DiscardedBytes.WithLabelValues(validation.GreaterThanMaxSampleAge, userID).Add(float64(len(entry.Line)))
-		return httpgrpc.Errorf(http.StatusBadRequest, validation.GreaterThanMaxSampleAgeErrorMsg(labels, entry.Timestamp))
+func (v Validator) ValidateEntry(vCtx validationContext, labels string, entry logproto.Entry) error {
+	ts := entry.Timestamp.UnixNano()
+
+	if vCtx.rejectOldSample && ts < vCtx.rejectOldSampleMaxAge.UnixNano() {
+		validation.Discards.Record(validation.GreaterThanMaxSampleAge, vCtx.userID, labels, 1, len(entry.Line))
+		return validationError(http.StatusBadRequest, validation.GreaterThanMaxSampleAge, validation.GreaterThanMaxSampleAgeErrorMsg(labels, entry.Timestamp, vCtx.rejectOldSampleMaxAge))
 	}
 
-	if entry.Timestamp.UnixNano() > time.Now().Add(v.CreationGracePeriod(userID)).UnixNano() {
-		validation.DiscardedSamples.WithLabelValues(validation.TooFarInFuture, userID).Inc()
-		validation.DiscardedBytes.WithLabelValues(validation.TooFarInFuture, userID).Add(float64(len(entry.Line)))
-		return httpgrpc.Errorf(http.StatusBadRequest, validation.TooFarInFutureErrorMsg(labels, entry.Timestamp))
+	if ts > vCtx.creationGracePeriod.UnixNano() {
+		validation.Discards.Record(validation.TooFarInFuture, vCtx.userID, labels, 1, len(entry.Line))
+		return validationError(http.StatusBadRequest, validation.TooFarInFuture, validation.TooFarInFutureErrorMsg(labels, entry.Timestamp, vCtx.creationGracePeriod))
 	}
 
-	if maxSize := v.MaxLineSize(userID); maxSize != 0 && len(entry.Line) > maxSize {
+	if maxSize := vCtx.maxLineSize; maxSize != 0 && len(entry.Line) > maxSize {
 		// I wish we didn't return httpgrpc errors here as it seems
 		// an orthogonal concept (we need not use ValidateLabels in this context)
 		// but the upstream cortex_validation pkg uses it, so we keep this
 		// for parity.
-		validation.DiscardedSamples.WithLabelValues(validation.LineTooLong, userID).Inc()
-		validation.DiscardedBytes.WithLabelValues(validation.LineTooLong, userID).Add(float64(len(entry.Line)))
-		return httpgrpc.Errorf(http.StatusBadRequest, validation.LineTooLongErrorMsg(maxSize, len(entry.Line), labels))
+		validation.Discards.Record(validation.LineTooLong, vCtx.userID, labels, 1, len(entry.Line))
+		return validationError(http.StatusBadRequest, validation.LineTooLong, validation.LineTooLongErrorMsg(maxSize, len(entry.Line), labels))
 	}
 
 	return nil
 }
 
 // Validate labels returns an error if the labels are invalid
-func (v Validator) ValidateLabels(userID string, stream logproto.Stream) error {
+func (v Validator) ValidateLabels(vCtx validationContext, stream logproto.Stream) error {
 	ls, err := util.ToClientLabels(stream.Labels)
 	if err != nil {
 		// I wish we didn't return httpgrpc errors here as it seems
@@ -64,29 +93,22 @@ func (v Validator) ValidateLabels(userID string, stream logproto.Stream) error {
 	}
 
 	numLabelNames := len(ls)
-	if numLabelNames > v.MaxLabelNamesPerSeries(userID) {
-		validation.DiscardedSamples.WithLabelValues(validation.MaxLabelNamesPerSeries, userID).Inc()
-		bytes := 0
-		for _, e := range stream.Entries {
-			bytes += len(e.Line)
-		}
-		validation.DiscardedBytes.WithLabelValues(validation.MaxLabelNamesPerSeries, userID).Add(float64(bytes))
-		return httpgrpc.Errorf(http.StatusBadRequest, validation.MaxLabelNamesPerSeriesErrorMsg(cortex_client.FromLabelAdaptersToMetric(ls).String(), numLabelNames, v.MaxLabelNamesPerSeries(userID)))
+	if numLabelNames > vCtx.maxLabelNamesPerSeries {
+		updateMetrics(validation.MaxLabelNamesPerSeries, vCtx.userID, stream)
+		return validationError(http.StatusBadRequest, validation.MaxLabelNamesPerSeries, validation.MaxLabelNamesPerSeriesErrorMsg(cortex_client.FromLabelAdaptersToMetric(ls).String(), numLabelNames, vCtx.maxLabelNamesPerSeries))
 	}
 
-	maxLabelNameLength := v.MaxLabelNameLength(userID)
-	maxLabelValueLength := v.MaxLabelValueLength(userID)
 	lastLabelName := ""
 	for _, l := range ls {
-		if len(l.Name) > maxLabelNameLength {
-			updateMetrics(validation.LabelNameTooLong, userID, stream)
-			return httpgrpc.Errorf(http.StatusBadRequest, validation.LabelNameTooLongErrorMsg(stream.Labels, l.Name))
-		} else if len(l.Value) > maxLabelValueLength {
-			updateMetrics(validation.LabelValueTooLong, userID, stream)
-			return httpgrpc.Errorf(http.StatusBadRequest, validation.LabelValueTooLongErrorMsg(stream.Labels, l.Value))
+		if len(l.Name) > vCtx.maxLabelNameLength {
+			updateMetrics(validation.LabelNameTooLong, vCtx.userID, stream)
+			return validationError(http.StatusBadRequest, validation.LabelNameTooLong, validation.LabelNameTooLongErrorMsg(stream.Labels, l.Name))
+		} else if len(l.Value) > vCtx.maxLabelValueLength {
+			updateMetrics(validation.LabelValueTooLong, vCtx.userID, stream)
+			return validationError(http.StatusBadRequest, validation.LabelValueTooLong, validation.LabelValueTooLongErrorMsg(stream.Labels, l.Value))
 		} else if cmp := strings.Compare(lastLabelName, l.Name); cmp == 0 {
-			updateMetrics(validation.DuplicateLabelNames, userID, stream)
-			return httpgrpc.Errorf(http.StatusBadRequest, validation.DuplicateLabelNamesErrorMsg(stream.Labels, l.Name))
+			updateMetrics(validation.DuplicateLabelNames, vCtx.userID, stream)
+			return validationError(http.StatusBadRequest, validation.DuplicateLabelNames, validation.DuplicateLabelNamesErrorMsg(stream.Labels, l.Name))
 		}
 		lastLabelName = l.Name
 	}
@@ -94,10 +116,22 @@ func (v Validator) ValidateLabels(userID string, stream logproto.Stream) error {
 }
 
 func updateMetrics(reason, userID string, stream logproto.Stream) {
-	validation.DiscardedSamples.WithLabelValues(reason, userID).Inc()
 	bytes := 0
 	for _, e := range stream.Entries {
 		bytes += len(e.Line)
 	}
-	validation.DiscardedBytes.WithLabelValues(reason, userID).Add(float64(bytes))
+	validation.Discards.Record(reason, userID, stream.Labels, len(stream.Entries), bytes)
+}
+
+// validationError returns an httpgrpc error which carries the discard reason in the
+// DiscardReasonHeader, along with any extra headers, so that the push handler can
+// expose them to clients.
+func validationError(code int, reason, msg string, headers ...*httpgrpc.Header) error {
+	return httpgrpc.ErrorFromHTTPResponse(&httpgrpc.HTTPResponse{
+		Code: int32(code),
+		Headers: append([]*httpgrpc.Header{
+			{Key: DiscardReasonHeader, Values: []string{reason}},
+		}, headers...),
+		Body: []byte(msg),
+	})
 }
@@ -7,7 +7,6 @@ import (
 
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 	"github.com/stretchr/testify/assert"
-	"github.com/weaveworks/common/httpgrpc"
 
 	"github.com/famarks/loki/pkg/logproto"
 	"github.com/famarks/loki/pkg/util/validation"
@@ -41,14 +40,14 @@ func TestValidator_ValidateEntry(t *testing.T) {
 				}
 			},
 			logproto.Entry{Timestamp: testTime.Add(-time.Hour * 5), Line: "test"},
-			httpgrpc.Errorf(http.StatusBadRequest, validation.GreaterThanMaxSampleAgeErrorMsg(testStreamLabels, testTime.Add(-time.Hour*5))),
+			validationError(http.StatusBadRequest, validation.GreaterThanMaxSampleAge, validation.GreaterThanMaxSampleAgeErrorMsg(testStreamLabels, testTime.Add(-time.Hour*5), testTime.Add(-time.Hour))),
 		},
 		{
 			"test too new",
 			"test",
 			nil,
 			logproto.Entry{Timestamp: testTime.Add(time.Hour * 5), Line: "test"},
-			httpgrpc.Errorf(http.StatusBadRequest, validation.TooFarInFutureErrorMsg(testStreamLabels, testTime.Add(time.Hour*5))),
+			validationError(http.StatusBadRequest, validation.TooFarInFuture, validation.TooFarInFutureErrorMsg(testStreamLabels, testTime.Add(time.Hour*5), testTime.Add(10*time.Minute))),
 		},
 		{
 			"line too long",
@@ -59,7 +58,7 @@ func TestValidator_ValidateEntry(t *testing.T) {
 				}
 			},
 			logproto.Entry{Timestamp: testTime, Line: "12345678901"},
-			httpgrpc.Errorf(http.StatusBadRequest, validation.LineTooLongErrorMsg(10, 11, testStreamLabels)),
+			validationError(http.StatusBadRequest, validation.LineTooLong, validation.LineTooLongErrorMsg(10, 11, testStreamLabels)),
 		},
 	}
 	for _, tt := range tests {
@@ -71,7 +70,7 @@ func TestValidator_ValidateEntry(t *testing.T) {
 			v, err := NewValidator(o)
 			assert.NoError(t, err)
 
-			err = v.ValidateEntry(tt.userID, testStreamLabels, tt.entry)
+			err = v.ValidateEntry(v.getValidationContextFor(testTime, tt.userID), testStreamLabels, tt.entry)
 			assert.Equal(t, tt.expected, err)
 		})
 	}
@@ -99,7 +98,7 @@ func TestValidator_ValidateLabels(t *testing.T) {
 				return &validation.Limits{MaxLabelNamesPerSeries: 2}
 			},
 			"{foo=\"bar\",food=\"bars\",fed=\"bears\"}",
-			httpgrpc.Errorf(http.StatusBadRequest, validation.MaxLabelNamesPerSeriesErrorMsg("{fed=\"bears\", foo=\"bar\", food=\"bars\"}", 3, 2)),
+			validationError(http.StatusBadRequest, validation.MaxLabelNamesPerSeries, validation.MaxLabelNamesPerSeriesErrorMsg("{fed=\"bears\", foo=\"bar\", food=\"bars\"}", 3, 2)),
 		},
 		{
 			"label name too long",
@@ -111,7 +110,7 @@ func TestValidator_ValidateLabels(t *testing.T) {
 				}
 			},
 			"{fooooo=\"bar\"}",
-			httpgrpc.Errorf(http.StatusBadRequest, validation.LabelNameTooLongErrorMsg("{fooooo=\"bar\"}", "fooooo")),
+			validationError(http.StatusBadRequest, validation.LabelNameTooLong, validation.LabelNameTooLongErrorMsg("{fooooo=\"bar\"}", "fooooo")),
 		},
 		{
 			"label value too long",
@@ -124,7 +123,7 @@ func TestValidator_ValidateLabels(t *testing.T) {
 				}
 			},
 			"{foo=\"barrrrrr\"}",
-			httpgrpc.Errorf(http.StatusBadRequest, validation.LabelValueTooLongErrorMsg("{foo=\"barrrrrr\"}", "barrrrrr")),
+			validationError(http.StatusBadRequest, validation.LabelValueTooLong, validation.LabelValueTooLongErrorMsg("{foo=\"barrrrrr\"}", "barrrrrr")),
 		},
 		{
 			"duplicate label",
@@ -137,7 +136,7 @@ func TestValidator_ValidateLabels(t *testing.T) {
 				}
 			},
 			"{foo=\"bar\", foo=\"barf\"}",
-			httpgrpc.Errorf(http.StatusBadRequest, validation.DuplicateLabelNamesErrorMsg("{foo=\"bar\", foo=\"barf\"}", "foo")),
+			validationError(http.StatusBadRequest, validation.DuplicateLabelNames, validation.DuplicateLabelNamesErrorMsg("{foo=\"bar\", foo=\"barf\"}", "foo")),
 		},
 	}
 	for _, tt := range tests {
@@ -149,7 +148,7 @@ func TestValidator_ValidateLabels(t *testing.T) {
 			v, err := NewValidator(o)
 			assert.NoError(t, err)
 
-			err = v.ValidateLabels(tt.userID, logproto.Stream{Labels: tt.labels})
+			err = v.ValidateLabels(v.getValidationContextFor(testTime, tt.userID), logproto.Stream{Labels: tt.labels})
 			assert.Equal(t, tt.expected, err)
 		})
 	}
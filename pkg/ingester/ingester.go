@@ -29,7 +29,6 @@ import (
 	"github.com/famarks/loki/pkg/logproto"
 	"github.com/famarks/loki/pkg/logql"
 	"github.com/famarks/loki/pkg/logql/stats"
-	"github.com/famarks/loki/pkg/storage/stores/shipper"
 	listutil "github.com/famarks/loki/pkg/util"
 	"github.com/famarks/loki/pkg/util/validation"
 )
@@ -362,18 +361,18 @@ func (i *Ingester) QuerySample(req *logproto.SampleQueryRequest, queryServer log
 	return sendSampleBatches(queryServer.Context(), heapItr, queryServer)
 }
 
-// boltdbShipperMaxLookBack returns a max look back period only if active index type is boltdb-shipper.
-// max look back is limited to from time of boltdb-shipper config.
-// It considers previous periodic config's from time if that also has index type set to boltdb-shipper.
+// boltdbShipperMaxLookBack returns a max look back period only if active index type is boltdb-shipper or tsdb.
+// max look back is limited to from time of boltdb-shipper or tsdb config.
+// It considers previous periodic config's from time if that also has index type set to boltdb-shipper or tsdb.
 func (i *Ingester) boltdbShipperMaxLookBack() time.Duration {
 	activePeriodicConfigIndex := storage.ActivePeriodConfig(i.periodicConfigs)
 	activePeriodicConfig := i.periodicConfigs[activePeriodicConfigIndex]
-	if activePeriodicConfig.IndexType != shipper.BoltDBShipperType {
+	if !storage.IsShippedIndexType(activePeriodicConfig.IndexType) {
 		return 0
 	}
 
 	startTime := activePeriodicConfig.From
-	if activePeriodicConfigIndex != 0 && i.periodicConfigs[activePeriodicConfigIndex-1].IndexType == shipper.BoltDBShipperType {
+	if activePeriodicConfigIndex != 0 && storage.IsShippedIndexType(i.periodicConfigs[activePeriodicConfigIndex-1].IndexType) {
 		startTime = i.periodicConfigs[activePeriodicConfigIndex-1].From
 	}
 
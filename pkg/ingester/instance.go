@@ -6,6 +6,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -167,12 +169,11 @@ func (i *instance) getOrCreateStream(pushReqStream logproto.Stream) (*stream, er
 
 	err = i.limiter.AssertMaxStreamsPerUser(i.instanceID, len(i.streams))
 	if err != nil {
-		validation.DiscardedSamples.WithLabelValues(validation.StreamLimit, i.instanceID).Add(float64(len(pushReqStream.Entries)))
 		bytes := 0
 		for _, e := range pushReqStream.Entries {
 			bytes += len(e.Line)
 		}
-		validation.DiscardedBytes.WithLabelValues(validation.StreamLimit, i.instanceID).Add(float64(bytes))
+		validation.Discards.Record(validation.StreamLimit, i.instanceID, pushReqStream.Labels, len(pushReqStream.Entries), bytes)
 		return nil, httpgrpc.Errorf(http.StatusTooManyRequests, validation.StreamLimitErrorMsg())
 	}
 
@@ -222,6 +223,7 @@ func (i *instance) Query(ctx context.Context, req logql.SelectLogParams) ([]iter
 	if err != nil {
 		return nil, err
 	}
+	logMatchedToSpan(ctx, len(iters), ingStats.TotalChunksMatched)
 
 	return iters, nil
 }
@@ -253,10 +255,18 @@ func (i *instance) QuerySample(ctx context.Context, req logql.SelectSampleParams
 	if err != nil {
 		return nil, err
 	}
+	logMatchedToSpan(ctx, len(iters), ingStats.TotalChunksMatched)
 
 	return iters, nil
 }
 
+// logMatchedToSpan logs the streams and chunks matched by a query.
+func logMatchedToSpan(ctx context.Context, streams int, chunks int64) {
+	if sp := opentracing.SpanFromContext(ctx); sp != nil {
+		sp.LogFields(otlog.Int("streams", streams), otlog.Int64("chunks", chunks))
+	}
+}
+
 func (i *instance) Label(_ context.Context, req *logproto.LabelRequest) (*logproto.LabelResponse, error) {
 	var labels []string
 	if req.Values {
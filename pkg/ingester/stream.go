@@ -14,11 +14,13 @@ import (
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
 
 	"github.com/famarks/loki/pkg/chunkenc"
 	"github.com/famarks/loki/pkg/iter"
 	"github.com/famarks/loki/pkg/logproto"
 	"github.com/famarks/loki/pkg/logql"
+	"github.com/famarks/loki/pkg/util/validation"
 )
 
 var (
@@ -221,6 +223,13 @@ func (s *stream) Push(ctx context.Context, entries []logproto.Entry, synchronize
 
 			fmt.Fprintf(&buf, "total ignored: %d out of %d", len(failedEntriesWithError), len(entries))
 
+			discardedBytes := 0
+			for _, entryWithError := range failedEntriesWithError {
+				discardedBytes += len(entryWithError.entry.Line)
+			}
+			tenant, _ := user.ExtractOrgID(ctx)
+			validation.Discards.Record(validation.OutOfOrder, tenant, streamName, len(failedEntriesWithError), discardedBytes)
+
 			return httpgrpc.Errorf(http.StatusBadRequest, buf.String())
 		}
 		return lastEntryWithErr.e
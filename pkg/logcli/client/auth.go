@@ -0,0 +1,119 @@
+package client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+// expiryMargin is how long before its expiry a token of the exec-credential plugin is refreshed.
+const expiryMargin = 30 * time.Second
+
+// ExecCredential is the output of the exec-credential plugin, when it does not print the token alone.
+type ExecCredential struct {
+	Token string `json:"token"`
+	// Expiry is when the token expires, the token being kept until rejected by the server when zero.
+	Expiry time.Time `json:"expiry"`
+}
+
+// execToken caches the token of the exec-credential plugin across requests.
+type execToken struct {
+	mtx    sync.Mutex
+	cred   ExecCredential
+	loaded bool
+}
+
+// authorization returns the value of the Authorization header of the requests: the token of the exec-credential
+// plugin, of the bearer token file or the bearer token, the basic auth otherwise.
+func (c *DefaultClient) authorization() (string, error) {
+	switch {
+	case c.AuthExec != "":
+		token, err := c.execToken.get(c.AuthExec)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	case c.BearerTokenFile != "":
+		// read on every request, for the files rotated by a sidecar
+		b, err := ioutil.ReadFile(c.BearerTokenFile)
+		if err != nil {
+			return "", fmt.Errorf("unable to read bearer token file %s: %w", c.BearerTokenFile, err)
+		}
+		return "Bearer " + strings.TrimSpace(string(b)), nil
+	case c.BearerToken != "":
+		return "Bearer " + c.BearerToken, nil
+	}
+	if c.Username == "" && c.Password == "" {
+		return "", nil
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password)), nil
+}
+
+// refreshable tells whether a request rejected as unauthorized is worth retrying with a new token.
+func (c *DefaultClient) refreshable() bool {
+	if c.AuthExec == "" {
+		return false
+	}
+	c.execToken.invalidate()
+	return true
+}
+
+func (t *execToken) get(command string) (string, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.loaded && (t.cred.Expiry.IsZero() || time.Now().Add(expiryMargin).Before(t.cred.Expiry)) {
+		return t.cred.Token, nil
+	}
+	cred, err := runExecCredential(command)
+	if err != nil {
+		return "", err
+	}
+	t.cred, t.loaded = cred, true
+	return cred.Token, nil
+}
+
+func (t *execToken) invalidate() {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.loaded = false
+}
+
+// runExecCredential runs the command of the exec-credential plugin, which prints either an ExecCredential as JSON or
+// the token alone.
+func runExecCredential(command string) (ExecCredential, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return ExecCredential{}, fmt.Errorf("empty auth exec command")
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return ExecCredential{}, fmt.Errorf("auth exec command %q failed: %w", command, err)
+	}
+
+	out := bytes.TrimSpace(stdout.Bytes())
+	var cred ExecCredential
+	if bytes.HasPrefix(out, []byte("{")) {
+		if err := json.Unmarshal(out, &cred); err != nil {
+			return ExecCredential{}, fmt.Errorf("unable to parse the output of auth exec command %q: %w", command, err)
+		}
+	} else {
+		cred.Token = string(out)
+	}
+	if cred.Token == "" {
+		return ExecCredential{}, fmt.Errorf("auth exec command %q returned no token", command)
+	}
+	return cred, nil
+}
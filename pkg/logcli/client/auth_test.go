@@ -0,0 +1,102 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorization(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "auth")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	tokenFile := filepath.Join(tempDir, "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("from-file\n"), 0600))
+
+	for _, tc := range []struct {
+		name   string
+		client *DefaultClient
+		want   string
+	}{
+		{"none", &DefaultClient{}, ""},
+		{"basic", &DefaultClient{Username: "user", Password: "pass"}, "Basic dXNlcjpwYXNz"},
+		{"bearer", &DefaultClient{Username: "user", BearerToken: "token"}, "Bearer token"},
+		{"bearer file", &DefaultClient{BearerToken: "token", BearerTokenFile: tokenFile}, "Bearer from-file"},
+		{"exec", &DefaultClient{BearerTokenFile: tokenFile, AuthExec: "echo from-exec"}, "Bearer from-exec"},
+		{"exec json", &DefaultClient{AuthExec: `echo {"token":"from-json"}`}, "Bearer from-json"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			auth, err := tc.client.authorization()
+			require.NoError(t, err)
+			require.Equal(t, tc.want, auth)
+		})
+	}
+
+	_, err = (&DefaultClient{BearerTokenFile: filepath.Join(tempDir, "missing")}).authorization()
+	require.Error(t, err)
+	_, err = (&DefaultClient{AuthExec: "false"}).authorization()
+	require.Error(t, err)
+	_, err = (&DefaultClient{AuthExec: "true"}).authorization()
+	require.Error(t, err)
+}
+
+func TestExecTokenRefresh(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "auth")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	// the plugin prints the content of the file, rewritten to rotate the token
+	tokenFile := filepath.Join(tempDir, "token")
+	writeToken := func(token string, expiry time.Time) {
+		data := fmt.Sprintf(`{"token":%q,"expiry":%q}`, token, expiry.Format(time.RFC3339))
+		require.NoError(t, ioutil.WriteFile(tokenFile, []byte(data), 0600))
+	}
+
+	var valid string
+	var auths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auths = append(auths, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer "+valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":["app"]}`))
+	}))
+	defer server.Close()
+
+	c := &DefaultClient{Address: server.URL, AuthExec: "cat " + tokenFile}
+
+	// the token is cached until its expiry
+	valid = "a"
+	writeToken("a", time.Now().Add(time.Hour))
+	_, err = c.ListLabelNames(true, time.Unix(0, 0), time.Unix(1, 0))
+	require.NoError(t, err)
+	writeToken("b", time.Now().Add(time.Hour))
+	_, err = c.ListLabelNames(true, time.Unix(0, 0), time.Unix(1, 0))
+	require.NoError(t, err)
+	require.Equal(t, []string{"Bearer a", "Bearer a"}, auths)
+
+	// a rejected token is refreshed once
+	valid = "b"
+	auths = nil
+	_, err = c.ListLabelNames(true, time.Unix(0, 0), time.Unix(1, 0))
+	require.NoError(t, err)
+	require.Equal(t, []string{"Bearer a", "Bearer b"}, auths)
+
+	// an expired token is refreshed before the request
+	c.execToken.cred.Expiry = time.Now()
+	writeToken("c", time.Now().Add(time.Hour))
+	valid = "c"
+	auths = nil
+	_, err = c.ListLabelNames(true, time.Unix(0, 0), time.Unix(1, 0))
+	require.NoError(t, err)
+	require.Equal(t, []string{"Bearer c"}, auths)
+}
@@ -1,8 +1,9 @@
 package client
 
 import (
-	"encoding/base64"
+	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -11,6 +12,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
 	"github.com/gorilla/websocket"
 	json "github.com/json-iterator/go"
 	"github.com/prometheus/common/config"
@@ -28,6 +31,8 @@ const (
 	labelValuesPath = "/loki/api/v1/label/%s/values"
 	seriesPath      = "/loki/api/v1/series"
 	tailPath        = "/loki/api/v1/tail"
+	deletePath      = "/loki/api/v1/delete"
+	pushPath        = "/loki/api/v1/push"
 )
 
 var (
@@ -42,16 +47,26 @@ type Client interface {
 	ListLabelValues(name string, quiet bool, from, through time.Time) (*loghttp.LabelResponse, error)
 	Series(matchers []string, from, through time.Time, quiet bool) (*loghttp.SeriesResponse, error)
 	LiveTailQueryConn(queryStr string, delayFor int, limit int, from int64, quiet bool) (*websocket.Conn, error)
+	CreateDeleteRequest(query string, from, through time.Time, quiet bool) error
+	ListDeleteRequests(quiet bool) ([]loghttp.DeleteRequestResponse, error)
+	CancelDeleteRequest(requestID string, quiet bool) error
+	Push(req *logproto.PushRequest, quiet bool) error
 	GetOrgID() string
 }
 
 // Client contains fields necessary to query a Loki instance
 type DefaultClient struct {
-	TLSConfig config.TLSConfig
-	Username  string
-	Password  string
-	Address   string
-	OrgID     string
+	TLSConfig       config.TLSConfig
+	Username        string
+	Password        string
+	Address         string
+	OrgID           string
+	BearerToken     string
+	BearerTokenFile string
+	// AuthExec is the command of the exec-credential plugin printing the bearer token, refreshed once expired.
+	AuthExec string
+
+	execToken execToken
 }
 
 // Query uses the /api/v1/query endpoint to execute an instant query
@@ -141,6 +156,46 @@ func (c *DefaultClient) LiveTailQueryConn(queryStr string, delayFor int, limit i
 	return c.wsConnect(tailPath, qsb.Encode(), quiet)
 }
 
+// CreateDeleteRequest uses the /loki/api/v1/delete endpoint to request the deletion of the log entries of the streams
+// matching the query between from and through
+func (c *DefaultClient) CreateDeleteRequest(query string, from, through time.Time, quiet bool) error {
+	params := util.NewQueryStringBuilder()
+	params.SetString("query", query)
+	params.SetInt("start", from.UnixNano())
+	params.SetInt("end", through.UnixNano())
+
+	return c.doHTTPRequest("POST", deletePath, params.Encode(), nil, quiet, nil)
+}
+
+// ListDeleteRequests uses the /loki/api/v1/delete endpoint to list the delete requests of the tenant
+func (c *DefaultClient) ListDeleteRequests(quiet bool) ([]loghttp.DeleteRequestResponse, error) {
+	var requests []loghttp.DeleteRequestResponse
+	if err := c.doRequest(deletePath, "", quiet, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// CancelDeleteRequest uses the /loki/api/v1/delete endpoint to cancel a delete request of the tenant
+func (c *DefaultClient) CancelDeleteRequest(requestID string, quiet bool) error {
+	params := util.NewQueryStringBuilder()
+	params.SetString("request_id", requestID)
+
+	return c.doHTTPRequest("DELETE", deletePath, params.Encode(), nil, quiet, nil)
+}
+
+// Push uses the /loki/api/v1/push endpoint to push the entries of the request as a snappy-compressed proto
+func (c *DefaultClient) Push(req *logproto.PushRequest, quiet bool) error {
+	buf, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return c.doHTTPRequest("POST", pushPath, "", &requestBody{
+		contentType: "application/x-protobuf",
+		data:        snappy.Encode(nil, buf),
+	}, quiet, nil)
+}
+
 func (c *DefaultClient) GetOrgID() string {
 	return c.OrgID
 }
@@ -157,21 +212,36 @@ func (c *DefaultClient) doQuery(path string, query string, quiet bool) (*loghttp
 }
 
 func (c *DefaultClient) doRequest(path, query string, quiet bool, out interface{}) error {
+	return c.doHTTPRequest("GET", path, query, nil, quiet, out)
+}
 
-	us, err := buildURL(c.Address, path, query)
+// requestBody is the body of a request, along its content type.
+type requestBody struct {
+	contentType string
+	data        []byte
+}
+
+// send sends a single request with the optional body.
+func (c *DefaultClient) send(method, us string, body *requestBody) (*http.Response, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body.data)
+	}
+	req, err := http.NewRequest(method, us, reqBody)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if !quiet {
-		log.Print(us)
+	if body != nil {
+		req.Header.Set("Content-Type", body.contentType)
 	}
 
-	req, err := http.NewRequest("GET", us, nil)
+	auth, err := c.authorization()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
 	}
-
-	req.SetBasicAuth(c.Username, c.Password)
 	req.Header.Set("User-Agent", userAgent)
 
 	if c.OrgID != "" {
@@ -184,14 +254,36 @@ func (c *DefaultClient) doRequest(path, query string, quiet bool, out interface{
 	}
 
 	client, err := config.NewClientFromConfig(clientConfig, "logcli", false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Do(req)
+}
+
+// doHTTPRequest sends the request with the optional body and decodes its json response into out, the response is
+// discarded when out is nil.
+func (c *DefaultClient) doHTTPRequest(method, path, query string, body *requestBody, quiet bool, out interface{}) error {
+
+	us, err := buildURL(c.Address, path, query)
 	if err != nil {
 		return err
 	}
+	if !quiet {
+		log.Print(us)
+	}
 
-	resp, err := client.Do(req)
+	resp, err := c.send(method, us, body)
 	if err != nil {
 		return err
 	}
+	// the token of the exec-credential plugin may have been revoked before its expiry
+	if resp.StatusCode == http.StatusUnauthorized && c.refreshable() {
+		_ = resp.Body.Close()
+		if resp, err = c.send(method, us, body); err != nil {
+			return err
+		}
+	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
 			log.Println("error closing body", err)
@@ -203,6 +295,9 @@ func (c *DefaultClient) doRequest(path, query string, quiet bool, out interface{
 		return fmt.Errorf("Error response from server: %s (%v)", string(buf), err)
 	}
 
+	if out == nil {
+		return nil
+	}
 	return json.NewDecoder(resp.Body).Decode(out)
 }
 
@@ -226,7 +321,14 @@ func (c *DefaultClient) wsConnect(path, query string, quiet bool) (*websocket.Co
 		log.Println(us)
 	}
 
-	h := http.Header{"Authorization": {"Basic " + base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password))}}
+	auth, err := c.authorization()
+	if err != nil {
+		return nil, err
+	}
+	h := http.Header{}
+	if auth != "" {
+		h.Set("Authorization", auth)
+	}
 
 	if c.OrgID != "" {
 		h.Set("X-Scope-OrgID", c.OrgID)
@@ -1,6 +1,16 @@
 package client
 
-import "testing"
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/loghttp"
+)
 
 func Test_buildURL(t *testing.T) {
 	tests := []struct {
@@ -26,3 +36,41 @@ func Test_buildURL(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteRequests(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, deletePath, r.URL.Path)
+		require.Equal(t, "tenant", r.Header.Get("X-Scope-OrgID"))
+		methods = append(methods, r.Method)
+
+		switch r.Method {
+		case "POST":
+			require.Equal(t, `{app="foo"}`, r.URL.Query().Get("query"))
+			require.Equal(t, "1000000000", r.URL.Query().Get("start"))
+			require.Equal(t, "2000000000", r.URL.Query().Get("end"))
+			w.WriteHeader(http.StatusNoContent)
+		case "GET":
+			_, _ = w.Write([]byte(`[{"request_id":"abc","query":"{app=\"foo\"}","start_time":1,"end_time":2,"created_at":3.5,"status":"received"}]`))
+		case "DELETE":
+			require.Equal(t, "abc", r.URL.Query().Get("request_id"))
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	c := &DefaultClient{Address: server.URL, OrgID: "tenant"}
+	require.NoError(t, c.CreateDeleteRequest(`{app="foo"}`, time.Unix(1, 0), time.Unix(2, 0), true))
+	requests, err := c.ListDeleteRequests(true)
+	require.NoError(t, err)
+	require.Equal(t, []loghttp.DeleteRequestResponse{{
+		RequestID: "abc",
+		Query:     `{app="foo"}`,
+		StartTime: model.TimeFromUnix(1),
+		EndTime:   model.TimeFromUnix(2),
+		CreatedAt: model.Time(3500),
+		Status:    "received",
+	}}, requests)
+	require.NoError(t, c.CancelDeleteRequest("abc", true))
+	require.Equal(t, []string{"POST", "GET", "DELETE"}, methods)
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Context is a named set of connection settings of the config file, like a Loki cluster and a tenant.
+type Context struct {
+	Address            string `yaml:"addr"`
+	Username           string `yaml:"username"`
+	Password           string `yaml:"password"`
+	OrgID              string `yaml:"org-id"`
+	CAFile             string `yaml:"ca-cert"`
+	CertFile           string `yaml:"cert"`
+	KeyFile            string `yaml:"key"`
+	InsecureSkipVerify bool   `yaml:"tls-skip-verify"`
+	BearerToken        string `yaml:"bearer-token"`
+	BearerTokenFile    string `yaml:"bearer-token-file"`
+	AuthExec           string `yaml:"auth-exec"`
+}
+
+// ContextsConfig is the config file of logcli, holding the contexts selectable with --context.
+type ContextsConfig struct {
+	CurrentContext string             `yaml:"current-context"`
+	Contexts       map[string]Context `yaml:"contexts"`
+}
+
+// DefaultContextsPath returns the path of the config file: $XDG_CONFIG_HOME/logcli/config.yaml when it exists,
+// ~/.logcli.yaml otherwise.
+func DefaultContextsPath() string {
+	home, _ := os.UserHomeDir()
+
+	xdgConfigHome := os.Getenv("XDG_CONFIG_HOME")
+	if xdgConfigHome == "" && home != "" {
+		xdgConfigHome = filepath.Join(home, ".config")
+	}
+	if xdgConfigHome != "" {
+		path := filepath.Join(xdgConfigHome, "logcli", "config.yaml")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(home, ".logcli.yaml")
+}
+
+// LoadContexts reads the config file at path, a missing file having no context.
+func LoadContexts(path string) (*ContextsConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &ContextsConfig{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &ContextsConfig{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Context returns the context named name, or the current context when name is empty. No context is selected when both
+// are empty.
+func (c *ContextsConfig) Context(name string) (Context, bool, error) {
+	if name == "" {
+		name = c.CurrentContext
+	}
+	if name == "" {
+		return Context{}, false, nil
+	}
+	ctx, ok := c.Contexts[name]
+	if !ok {
+		return Context{}, false, fmt.Errorf("context %q not found", name)
+	}
+	return ctx, true, nil
+}
+
+// ApplyContext sets the settings of the client not set by the flags or by the environment to the ones of the context.
+func (c *DefaultClient) ApplyContext(ctx Context) {
+	setIfEmpty(&c.Address, ctx.Address)
+	setIfEmpty(&c.Username, ctx.Username)
+	setIfEmpty(&c.Password, ctx.Password)
+	setIfEmpty(&c.OrgID, ctx.OrgID)
+	setIfEmpty(&c.TLSConfig.CAFile, ctx.CAFile)
+	setIfEmpty(&c.TLSConfig.CertFile, ctx.CertFile)
+	setIfEmpty(&c.TLSConfig.KeyFile, ctx.KeyFile)
+	setIfEmpty(&c.BearerToken, ctx.BearerToken)
+	setIfEmpty(&c.BearerTokenFile, ctx.BearerTokenFile)
+	setIfEmpty(&c.AuthExec, ctx.AuthExec)
+	c.TLSConfig.InsecureSkipVerify = c.TLSConfig.InsecureSkipVerify || ctx.InsecureSkipVerify
+}
+
+func setIfEmpty(v *string, value string) {
+	if *v == "" {
+		*v = value
+	}
+}
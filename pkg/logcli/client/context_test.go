@@ -0,0 +1,67 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/common/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContexts(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "contexts")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "config.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+current-context: staging
+contexts:
+  staging:
+    addr: https://staging.example.com
+    org-id: team-a
+  prod:
+    addr: https://prod.example.com
+    username: admin
+    password: secret
+    ca-cert: /etc/ca.pem
+    tls-skip-verify: true
+`), 0644))
+
+	cfg, err := LoadContexts(path)
+	require.NoError(t, err)
+
+	ctx, ok, err := cfg.Context("")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, Context{Address: "https://staging.example.com", OrgID: "team-a"}, ctx)
+
+	ctx, ok, err = cfg.Context("prod")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// the settings set by the flags or the environment win over the context.
+	c := &DefaultClient{Username: "me", TLSConfig: config.TLSConfig{}}
+	c.ApplyContext(ctx)
+	require.Equal(t, "https://prod.example.com", c.Address)
+	require.Equal(t, "me", c.Username)
+	require.Equal(t, "secret", c.Password)
+	require.Equal(t, "/etc/ca.pem", c.TLSConfig.CAFile)
+	require.True(t, c.TLSConfig.InsecureSkipVerify)
+
+	_, _, err = cfg.Context("dev")
+	require.Error(t, err)
+
+	// no context is selected without a config file.
+	cfg, err = LoadContexts(filepath.Join(tempDir, "missing.yaml"))
+	require.NoError(t, err)
+	_, ok, err = cfg.Context("")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("contexts:\n  prod:\n    address: https://prod.example.com\n"), 0644))
+	_, err = LoadContexts(path)
+	require.Error(t, err)
+}
@@ -0,0 +1,94 @@
+package deletequery
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/famarks/loki/pkg/logcli/client"
+	"github.com/famarks/loki/pkg/loghttp"
+)
+
+// DeleteQuery contains all necessary fields to manage the delete requests of a tenant and print out the results
+type DeleteQuery struct {
+	Query     string
+	Start     time.Time
+	End       time.Time
+	RequestID string
+	DryRun    bool
+	Quiet     bool
+}
+
+// DoCreate creates a delete request and prints it out, or prints out the streams it would delete on a dry run
+func (q *DeleteQuery) DoCreate(c client.Client) {
+	if q.DryRun {
+		seriesResponse, err := c.Series([]string{q.Query}, q.Start, q.End, q.Quiet)
+		if err != nil {
+			log.Fatalf("Error doing request: %+v", err)
+		}
+		fmt.Printf("Dry run, the log entries of %d streams between %s and %s would be deleted:\n",
+			len(seriesResponse.Data), formatTime(q.Start), formatTime(q.End))
+		for _, stream := range seriesResponse.Data {
+			fmt.Println(stream)
+		}
+		return
+	}
+
+	if err := c.CreateDeleteRequest(q.Query, q.Start, q.End, q.Quiet); err != nil {
+		log.Fatalf("Error doing request: %+v", err)
+	}
+
+	// the deletion API doesn't return the created request, it is the latest one matching the query and the time range
+	requests, err := c.ListDeleteRequests(q.Quiet)
+	if err != nil {
+		log.Fatalf("Error doing request: %+v", err)
+	}
+	var created *loghttp.DeleteRequestResponse
+	start, end := model.TimeFromUnixNano(q.Start.UnixNano()), model.TimeFromUnixNano(q.End.UnixNano())
+	for i, r := range requests {
+		if r.Query == q.Query && r.StartTime == start && r.EndTime == end && (created == nil || r.CreatedAt > created.CreatedAt) {
+			created = &requests[i]
+		}
+	}
+	if created == nil {
+		fmt.Println("Delete request created")
+		return
+	}
+	printRequests(os.Stdout, []loghttp.DeleteRequestResponse{*created})
+}
+
+// DoList prints out the delete requests of the tenant
+func (q *DeleteQuery) DoList(c client.Client) {
+	requests, err := c.ListDeleteRequests(q.Quiet)
+	if err != nil {
+		log.Fatalf("Error doing request: %+v", err)
+	}
+	printRequests(os.Stdout, requests)
+}
+
+// DoCancel cancels a delete request of the tenant, possible until the compactor starts processing it
+func (q *DeleteQuery) DoCancel(c client.Client) {
+	if err := c.CancelDeleteRequest(q.RequestID, q.Quiet); err != nil {
+		log.Fatalf("Error doing request: %+v", err)
+	}
+	fmt.Printf("Delete request %s cancelled\n", q.RequestID)
+}
+
+func printRequests(out io.Writer, requests []loghttp.DeleteRequestResponse) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Request ID\tStatus\tCreated At\tStart\tEnd\tQuery\n")
+	for _, r := range requests {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.RequestID, r.Status, formatTime(r.CreatedAt.Time()),
+			formatTime(r.StartTime.Time()), formatTime(r.EndTime.Time()), r.Query)
+	}
+	w.Flush()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
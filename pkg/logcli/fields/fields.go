@@ -0,0 +1,278 @@
+package fields
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/famarks/loki/pkg/logcli/client"
+	"github.com/famarks/loki/pkg/loghttp"
+	"github.com/famarks/loki/pkg/logproto"
+	logqllog "github.com/famarks/loki/pkg/logql/log"
+)
+
+// maxTrackedValues is the number of distinct values tracked by field, the cardinality being reported as higher beyond.
+const maxTrackedValues = 1000
+
+// lowCardinality is the number of distinct values under which a field is suggested to group by.
+const lowCardinality = 20
+
+// Parsers of the fields.
+const (
+	ParserJSON   = "json"
+	ParserLogfmt = "logfmt"
+)
+
+// Types of the fields, from the most specific to the least one.
+const (
+	TypeInt      = "int"
+	TypeFloat    = "float"
+	TypeDuration = "duration"
+	TypeBytes    = "bytes"
+	TypeBool     = "bool"
+	TypeString   = "string"
+)
+
+var types = []string{TypeInt, TypeFloat, TypeDuration, TypeBytes, TypeBool}
+
+// Query contains all necessary fields to sample the lines matching a selector, detect their fields and print out the
+// results.
+type Query struct {
+	Matcher string
+	Start   time.Time
+	End     time.Time
+	Limit   int
+	Quiet   bool
+}
+
+// Field is a field detected in the sampled lines, with the name it gets from the parser.
+type Field struct {
+	Name    string
+	Parser  string
+	Type    string
+	Count   int
+	Example string
+	// Values holds the distinct values of the field, up to maxTrackedValues.
+	Values map[string]int
+
+	// matches tells whether all the values seen so far are of the types.
+	matches map[string]bool
+}
+
+// Cardinality returns the number of distinct values of the field, and whether there are more.
+func (f *Field) Cardinality() (int, bool) {
+	return len(f.Values), len(f.Values) >= maxTrackedValues
+}
+
+// Detection is the result of the detection over the sampled lines.
+type Detection struct {
+	Lines   int
+	Parsers map[string]int
+	Fields  []*Field
+}
+
+// DoDetect prints out the fields detected in the lines matching the selector and suggests queries using them
+func (q *Query) DoDetect(c client.Client) {
+	resp, err := c.QueryRange(q.Matcher, q.Limit, q.Start, q.End, logproto.BACKWARD, 0, 0, q.Quiet)
+	if err != nil {
+		log.Fatalf("Query failed: %+v", err)
+	}
+	streams, ok := resp.Data.Result.(loghttp.Streams)
+	if !ok {
+		log.Fatalf("Unexpected result type: %v", resp.Data.ResultType)
+	}
+
+	var lines []string
+	for _, s := range streams {
+		for _, e := range s.Entries {
+			lines = append(lines, e.Line)
+		}
+	}
+	d := Detect(lines)
+	printDetection(os.Stdout, d)
+	fmt.Println()
+	fmt.Println("Suggested queries:")
+	for _, s := range Suggest(q.Matcher, d) {
+		fmt.Println(s)
+	}
+}
+
+// Detect parses the lines as JSON, or as logfmt otherwise, and returns their fields, the most frequent first.
+func Detect(lines []string) *Detection {
+	d := &Detection{Lines: len(lines), Parsers: map[string]int{}}
+	byKey := map[string]*Field{}
+
+	json, logfmt := logqllog.NewJSONParser(), logqllog.NewLogfmtParser()
+	lbs := logqllog.NewLabelsBuilder()
+	for _, line := range lines {
+		parser := ParserLogfmt
+		stage := logqllog.Stage(logfmt)
+		if strings.HasPrefix(strings.TrimSpace(line), "{") {
+			parser, stage = ParserJSON, json
+		}
+
+		lbs.Reset(nil)
+		stage.Process([]byte(line), lbs)
+		if lbs.HasErr() {
+			continue
+		}
+		extracted := lbs.Labels()
+		found := false
+		for _, l := range extracted {
+			// the words of unstructured lines are keys without values for the logfmt parser
+			if l.Value == "" {
+				continue
+			}
+			found = true
+			key := parser + "/" + l.Name
+			f, ok := byKey[key]
+			if !ok {
+				f = newField(l, parser)
+				byKey[key] = f
+			}
+			f.add(l.Value)
+		}
+		if found {
+			d.Parsers[parser]++
+		}
+	}
+
+	for _, f := range byKey {
+		f.Type = TypeString
+		for _, t := range types {
+			if f.matches[t] {
+				f.Type = t
+				break
+			}
+		}
+		d.Fields = append(d.Fields, f)
+	}
+	sort.Slice(d.Fields, func(i, j int) bool {
+		if d.Fields[i].Count == d.Fields[j].Count {
+			return d.Fields[i].Name < d.Fields[j].Name
+		}
+		return d.Fields[i].Count > d.Fields[j].Count
+	})
+	return d
+}
+
+func newField(l labels.Label, parser string) *Field {
+	f := &Field{
+		Name:    l.Name,
+		Parser:  parser,
+		Example: l.Value,
+		Values:  map[string]int{},
+		matches: map[string]bool{},
+	}
+	for _, t := range types {
+		f.matches[t] = true
+	}
+	return f
+}
+
+func (f *Field) add(value string) {
+	f.Count++
+	if _, ok := f.Values[value]; ok || len(f.Values) < maxTrackedValues {
+		f.Values[value]++
+	}
+	for t, ok := range f.matches {
+		if ok && !isType(t, value) {
+			f.matches[t] = false
+		}
+	}
+}
+
+func isType(t, value string) bool {
+	var err error
+	switch t {
+	case TypeInt:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case TypeFloat:
+		_, err = strconv.ParseFloat(value, 64)
+	case TypeDuration:
+		_, err = time.ParseDuration(value)
+	case TypeBytes:
+		_, err = humanize.ParseBytes(value)
+	case TypeBool:
+		_, err = strconv.ParseBool(value)
+	}
+	return err == nil
+}
+
+// Suggest returns queries parsing the lines matching the selector with the parser found the most, and making use of
+// the fields according to their type and cardinality.
+func Suggest(matcher string, d *Detection) []string {
+	parser := ""
+	for p, n := range d.Parsers {
+		if parser == "" || n > d.Parsers[parser] || (n == d.Parsers[parser] && p < parser) {
+			parser = p
+		}
+	}
+	if parser == "" {
+		return nil
+	}
+
+	pipeline := fmt.Sprintf("%s | %s", matcher, parser)
+	suggestions := []string{pipeline}
+	for _, f := range d.Fields {
+		if f.Parser != parser {
+			continue
+		}
+		switch cardinality, more := f.Cardinality(); {
+		case (f.Type == TypeInt || f.Type == TypeBool || f.Type == TypeString) && !more && cardinality <= lowCardinality:
+			suggestions = append(suggestions, fmt.Sprintf("sum by (%s) (count_over_time(%s [1m]))", f.Name, pipeline))
+		case f.Type == TypeInt || f.Type == TypeFloat:
+			suggestions = append(suggestions, fmt.Sprintf("quantile_over_time(0.99, %s | unwrap %s [1m])", pipeline, f.Name))
+		case f.Type == TypeDuration:
+			suggestions = append(suggestions, fmt.Sprintf("%s | %s > %s", pipeline, f.Name, f.Example))
+			suggestions = append(suggestions, fmt.Sprintf("quantile_over_time(0.99, %s | unwrap duration(%s) [1m])", pipeline, f.Name))
+		case f.Type == TypeBytes:
+			suggestions = append(suggestions, fmt.Sprintf("%s | %s > %s", pipeline, f.Name, f.Example))
+		}
+	}
+	return suggestions
+}
+
+func printDetection(out io.Writer, d *Detection) {
+	parsers := make([]string, 0, len(d.Parsers))
+	for p, n := range d.Parsers {
+		parsers = append(parsers, fmt.Sprintf("%s %d", p, n))
+	}
+	sort.Strings(parsers)
+	if len(parsers) == 0 {
+		parsers = append(parsers, "none")
+	}
+	fmt.Fprintf(out, "Sampled lines: %d, parsed by %s\n\n", d.Lines, strings.Join(parsers, ", "))
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Field\tParser\tType\tPresent\tCardinality\tExample\n")
+	for _, f := range d.Fields {
+		cardinality, more := f.Cardinality()
+		c := strconv.Itoa(cardinality)
+		if more {
+			c = ">" + c
+		}
+		present := 0.0
+		if d.Lines > 0 {
+			present = 100 * float64(f.Count) / float64(d.Lines)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.1f%%\t%s\t%s\n", f.Name, f.Parser, f.Type, present, c, truncate(f.Example, 40))
+	}
+	w.Flush()
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,66 @@
+package fields
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	lines := []string{
+		`level=info status=200 duration=12ms size=1kB msg="request served"`,
+		`level=error status=500 duration=1.5s size=10kB msg="request failed"`,
+		`level=info status=200 duration=3ms size=2kB latency=0.25 msg="request served"`,
+		`{"level":"warn","user":{"id":42}}`,
+		`unstructured line`,
+	}
+	d := Detect(lines)
+	require.Equal(t, 5, d.Lines)
+	require.Equal(t, map[string]int{ParserLogfmt: 3, ParserJSON: 1}, d.Parsers)
+
+	byName := map[string]*Field{}
+	for _, f := range d.Fields {
+		byName[f.Parser+"/"+f.Name] = f
+	}
+	require.Len(t, byName, 8)
+	for key, typ := range map[string]string{
+		"logfmt/level":    TypeString,
+		"logfmt/status":   TypeInt,
+		"logfmt/duration": TypeDuration,
+		"logfmt/size":     TypeBytes,
+		"logfmt/latency":  TypeFloat,
+		"logfmt/msg":      TypeString,
+		"json/level":      TypeString,
+		"json/user_id":    TypeInt,
+	} {
+		require.Equal(t, typ, byName[key].Type, key)
+	}
+	require.Equal(t, 3, byName["logfmt/status"].Count)
+	cardinality, more := byName["logfmt/status"].Cardinality()
+	require.Equal(t, 2, cardinality)
+	require.False(t, more)
+
+	suggestions := Suggest(`{app="foo"}`, d)
+	require.Equal(t, `{app="foo"} | logfmt`, suggestions[0])
+	require.Contains(t, suggestions, `sum by (level) (count_over_time({app="foo"} | logfmt [1m]))`)
+	require.Contains(t, suggestions, `sum by (status) (count_over_time({app="foo"} | logfmt [1m]))`)
+	require.Contains(t, suggestions, `{app="foo"} | logfmt | duration > 12ms`)
+	require.Contains(t, suggestions, `quantile_over_time(0.99, {app="foo"} | logfmt | unwrap duration(duration) [1m])`)
+	require.Contains(t, suggestions, `{app="foo"} | logfmt | size > 1kB`)
+	require.Contains(t, suggestions, `quantile_over_time(0.99, {app="foo"} | logfmt | unwrap latency [1m])`)
+	for _, s := range suggestions {
+		require.NotContains(t, s, "user_id")
+	}
+
+	var out bytes.Buffer
+	printDetection(&out, d)
+	require.True(t, strings.HasPrefix(out.String(), "Sampled lines: 5, parsed by json 1, logfmt 3\n"), out.String())
+}
+
+func TestDetectNothing(t *testing.T) {
+	d := Detect([]string{"plain text", "more text"})
+	require.Empty(t, d.Fields)
+	require.Empty(t, Suggest(`{app="foo"}`, d))
+}
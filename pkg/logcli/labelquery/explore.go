@@ -0,0 +1,279 @@
+package labelquery
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/famarks/loki/pkg/logcli/client"
+	"github.com/famarks/loki/pkg/loghttp"
+)
+
+// maxListed is the number of items listed at once, the others being left to the filters.
+const maxListed = 50
+
+const exploreHelp = `Type a number to pick an item, text to filter the items by, an empty line to clear the filters,
+'+' to add another label to the selector, '..' to go back, '?' for this help and 'q' to quit.`
+
+type exploreLevel int
+
+const (
+	levelNames exploreLevel = iota
+	levelValues
+	levelSeries
+)
+
+// explorer holds the state of an interactive exploration: the matchers of the selector built so far, the label the
+// values are listed of and the filters typed on the listed items.
+type explorer struct {
+	q   *LabelQuery
+	c   client.Client
+	out io.Writer
+
+	level    exploreLevel
+	matchers []*labels.Matcher
+	label    string
+	filters  []string
+
+	// series caches the series matching the selector, the label names and values being narrowed to them.
+	series []loghttp.LabelSet
+}
+
+// DoExplore lets the user drill interactively into the label names, the values of a label and the series matching
+// the selector built from the picked values, filtering the items listed at each step. The selector is printed on
+// quitting.
+func (q *LabelQuery) DoExplore(c client.Client, in io.Reader, out io.Writer) {
+	e := &explorer{q: q, c: c, out: out}
+	if q.LabelName != "" {
+		e.label = q.LabelName
+		e.level = levelValues
+	}
+
+	fmt.Fprintln(out, exploreHelp)
+	scanner := bufio.NewScanner(in)
+	for {
+		items := e.items()
+		e.print(items)
+		if !scanner.Scan() {
+			// end the prompt left without a newline
+			fmt.Fprintln(out)
+			break
+		}
+		if !e.handle(strings.TrimSpace(scanner.Text()), items) {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Error reading input: %+v", err)
+	}
+	fmt.Fprintln(out, e.selector())
+}
+
+// handle applies the input line to the state of the exploration, returning false to quit.
+func (e *explorer) handle(input string, items []string) bool {
+	switch input {
+	case "q", "quit", "exit":
+		return false
+	case "?", "help":
+		fmt.Fprintln(e.out, exploreHelp)
+	case "":
+		e.filters = nil
+	case "..":
+		e.back()
+	case "+":
+		e.level = levelNames
+		e.filters = nil
+	default:
+		n, err := strconv.Atoi(input)
+		if err != nil {
+			// the filters narrow down the items incrementally
+			e.filters = append(e.filters, strings.ToLower(input))
+			return true
+		}
+		if n < 1 || n > len(items) || n > maxListed {
+			fmt.Fprintf(e.out, "No item %d\n", n)
+			return true
+		}
+		e.pick(items[n-1])
+	}
+	return true
+}
+
+func (e *explorer) pick(item string) {
+	e.filters = nil
+	switch e.level {
+	case levelNames:
+		e.label = item
+		e.level = levelValues
+	case levelValues:
+		e.matchers = append(e.matchers, labels.MustNewMatcher(labels.MatchEqual, e.label, item))
+		e.series = nil
+		e.level = levelSeries
+	case levelSeries:
+		// picking a series selects it whole
+		var ls loghttp.LabelSet
+		for _, s := range e.matchingSeries() {
+			if s.String() == item {
+				ls = s
+				break
+			}
+		}
+		e.matchers = e.matchers[:0]
+		for name, value := range ls {
+			e.matchers = append(e.matchers, labels.MustNewMatcher(labels.MatchEqual, name, value))
+		}
+		sort.Slice(e.matchers, func(i, j int) bool { return e.matchers[i].Name < e.matchers[j].Name })
+		e.series = nil
+	}
+}
+
+func (e *explorer) back() {
+	e.filters = nil
+	switch e.level {
+	case levelValues:
+		e.level = levelNames
+	case levelSeries:
+		if len(e.matchers) > 0 {
+			last := e.matchers[len(e.matchers)-1]
+			e.matchers = e.matchers[:len(e.matchers)-1]
+			e.label = last.Name
+			e.series = nil
+		}
+		e.level = levelValues
+	}
+}
+
+// items returns the items of the current level matching all the filters.
+func (e *explorer) items() []string {
+	var items []string
+	switch e.level {
+	case levelNames:
+		items = e.names()
+	case levelValues:
+		items = e.values()
+	case levelSeries:
+		for _, ls := range e.matchingSeries() {
+			items = append(items, ls.String())
+		}
+		sort.Strings(items)
+	}
+	return filterItems(items, e.filters)
+}
+
+func (e *explorer) names() []string {
+	if len(e.matchers) == 0 {
+		q := &LabelQuery{Quiet: e.q.Quiet, Start: e.q.Start, End: e.q.End}
+		return q.ListLabels(e.c)
+	}
+	return seriesNames(e.matchingSeries(), e.matchers)
+}
+
+func (e *explorer) values() []string {
+	if len(e.matchers) == 0 {
+		q := &LabelQuery{LabelName: e.label, Quiet: e.q.Quiet, Start: e.q.Start, End: e.q.End}
+		return q.ListLabels(e.c)
+	}
+	return seriesValues(e.matchingSeries(), e.label)
+}
+
+func (e *explorer) matchingSeries() []loghttp.LabelSet {
+	if e.series == nil {
+		resp, err := e.c.Series([]string{e.selector()}, e.q.Start, e.q.End, e.q.Quiet)
+		if err != nil {
+			log.Fatalf("Error doing request: %+v", err)
+		}
+		e.series = resp.Data
+	}
+	return e.series
+}
+
+func (e *explorer) selector() string {
+	ms := make([]string, 0, len(e.matchers))
+	for _, m := range e.matchers {
+		ms = append(ms, m.String())
+	}
+	return "{" + strings.Join(ms, ", ") + "}"
+}
+
+func (e *explorer) print(items []string) {
+	fmt.Fprintln(e.out)
+	switch e.level {
+	case levelNames:
+		fmt.Fprintf(e.out, "Label names of %s", e.selector())
+	case levelValues:
+		fmt.Fprintf(e.out, "Values of %s in %s", e.label, e.selector())
+	case levelSeries:
+		fmt.Fprintf(e.out, "Series of %s", e.selector())
+	}
+	if len(e.filters) > 0 {
+		fmt.Fprintf(e.out, " containing %q", e.filters)
+	}
+	fmt.Fprintf(e.out, ": %d\n", len(items))
+	for i, item := range items {
+		if i == maxListed {
+			fmt.Fprintf(e.out, "... %d more, type text to filter them\n", len(items)-maxListed)
+			break
+		}
+		fmt.Fprintf(e.out, "%3d  %s\n", i+1, item)
+	}
+	fmt.Fprint(e.out, "> ")
+}
+
+// filterItems returns the items containing all the filters, ignoring the case.
+func filterItems(items []string, filters []string) []string {
+	if len(filters) == 0 {
+		return items
+	}
+	filtered := make([]string, 0, len(items))
+outer:
+	for _, item := range items {
+		lower := strings.ToLower(item)
+		for _, f := range filters {
+			if !strings.Contains(lower, f) {
+				continue outer
+			}
+		}
+		filtered = append(filtered, item)
+	}
+	return filtered
+}
+
+// seriesNames returns the sorted label names of the series, but the ones of the matchers.
+func seriesNames(series []loghttp.LabelSet, matchers []*labels.Matcher) []string {
+	set := map[string]struct{}{}
+	for _, ls := range series {
+		for name := range ls {
+			set[name] = struct{}{}
+		}
+	}
+	for _, m := range matchers {
+		delete(set, m.Name)
+	}
+	return sortedKeys(set)
+}
+
+// seriesValues returns the sorted values of the label in the series.
+func seriesValues(series []loghttp.LabelSet, name string) []string {
+	set := map[string]struct{}{}
+	for _, ls := range series {
+		if v, ok := ls[name]; ok {
+			set[v] = struct{}{}
+		}
+	}
+	return sortedKeys(set)
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
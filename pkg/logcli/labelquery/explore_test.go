@@ -0,0 +1,92 @@
+package labelquery
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/logcli/client"
+	"github.com/famarks/loki/pkg/loghttp"
+)
+
+type exploreClient struct {
+	client.Client
+	series []loghttp.LabelSet
+}
+
+func (c *exploreClient) ListLabelNames(quiet bool, from, through time.Time) (*loghttp.LabelResponse, error) {
+	return &loghttp.LabelResponse{Data: seriesNames(c.series, nil)}, nil
+}
+
+func (c *exploreClient) ListLabelValues(name string, quiet bool, from, through time.Time) (*loghttp.LabelResponse, error) {
+	return &loghttp.LabelResponse{Data: seriesValues(c.series, name)}, nil
+}
+
+func (c *exploreClient) Series(matchers []string, from, through time.Time, quiet bool) (*loghttp.SeriesResponse, error) {
+	ms, err := loghttp.Match(matchers)
+	if err != nil {
+		return nil, err
+	}
+	var data []loghttp.LabelSet
+outer:
+	for _, ls := range c.series {
+		for _, m := range ms[0] {
+			if !m.Matches(ls[m.Name]) {
+				continue outer
+			}
+		}
+		data = append(data, ls)
+	}
+	return &loghttp.SeriesResponse{Data: data}, nil
+}
+
+func TestDoExplore(t *testing.T) {
+	c := &exploreClient{series: []loghttp.LabelSet{
+		{"app": "api", "env": "prod", "pod": "api-1"},
+		{"app": "api", "env": "dev", "pod": "api-2"},
+		{"app": "web", "env": "prod", "pod": "web-1"},
+	}}
+
+	for _, tc := range []struct {
+		name     string
+		input    []string
+		selector string
+	}{
+		{"quit", []string{"q"}, "{}"},
+		// app, then api
+		{"value", []string{"1", "1", "q"}, `{app="api"}`},
+		// the filter leaves env only, then prod, then env is not listed anymore among app and pod
+		{"filtered", []string{"en", "1", "PRO", "1", "+", "1", "2", "q"}, `{env="prod", app="web"}`},
+		// the second series of app="api" is picked whole
+		{"series", []string{"1", "1", "2"}, `{app="api", env="prod", pod="api-1"}`},
+		{"back", []string{"1", "1", "..", "2"}, `{app="web"}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			q := &LabelQuery{}
+			q.DoExplore(c, strings.NewReader(strings.Join(tc.input, "\n")+"\n"), &out)
+
+			// the selector follows the last prompt, on a line of its own on the end of the input
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			require.Equal(t, tc.selector, strings.TrimPrefix(lines[len(lines)-1], "> "))
+		})
+	}
+}
+
+func TestFilterItems(t *testing.T) {
+	items := []string{"app", "namespace", "pod", "container_name"}
+	require.Equal(t, items, filterItems(items, nil))
+	require.Equal(t, []string{"namespace", "container_name"}, filterItems(items, []string{"name"}))
+	require.Equal(t, []string{"container_name"}, filterItems(items, []string{"name", "co"}))
+	require.Empty(t, filterItems(items, []string{"job"}))
+}
+
+func TestSeriesNames(t *testing.T) {
+	series := []loghttp.LabelSet{{"app": "api", "env": "prod"}, {"app": "web", "pod": "web-1"}}
+	require.Equal(t, []string{"env", "pod"}, seriesNames(series, []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "app", "api")}))
+	require.Equal(t, []string{"api", "web"}, seriesValues(series, "app"))
+}
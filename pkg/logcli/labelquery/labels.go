@@ -11,10 +11,11 @@ import (
 
 // LabelQuery contains all necessary fields to execute label queries and print out the results
 type LabelQuery struct {
-	LabelName string
-	Quiet     bool
-	Start     time.Time
-	End       time.Time
+	LabelName   string
+	Quiet       bool
+	Start       time.Time
+	End         time.Time
+	Interactive bool
 }
 
 // DoLabels prints out label results
@@ -22,6 +22,10 @@ func (o *DefaultOutput) FormatAndPrintln(ts time.Time, lbls loghttp.LabelSet, ma
 	timestamp := ts.In(o.options.Timezone).Format(time.RFC3339)
 	line = strings.TrimSpace(line)
 
+	if o.options.ColoredOutput {
+		line = colorLine(line)
+	}
+
 	if o.options.NoLabels {
 		fmt.Fprintf(o.w, "%s %s\n", color.BlueString(timestamp), line)
 		return
@@ -43,3 +47,11 @@ func padLabel(ls loghttp.LabelSet, maxLabelsLen int) string {
 	}
 	return labels
 }
+
+// WithWriter returns a copy of the output printing to the writer
+func (o *DefaultOutput) WithWriter(w io.Writer) LogOutput {
+	return &DefaultOutput{
+		w:       w,
+		options: o.options,
+	}
+}
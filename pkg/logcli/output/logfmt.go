@@ -0,0 +1,56 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/go-logfmt/logfmt"
+
+	"github.com/famarks/loki/pkg/loghttp"
+)
+
+// LogfmtOutput prints logs and metadata as logfmt, the labels between the timestamp and the line
+type LogfmtOutput struct {
+	w       io.Writer
+	options *LogOutputOptions
+}
+
+// Format a log entry as logfmt
+func (o *LogfmtOutput) FormatAndPrintln(ts time.Time, lbls loghttp.LabelSet, maxLabelsLen int, line string) {
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		line = line[:len(line)-1]
+	}
+
+	keyvals := []interface{}{"ts", ts.In(o.options.Timezone).Format(time.RFC3339Nano)}
+
+	// Labels are optional
+	if !o.options.NoLabels {
+		names := make([]string, 0, len(lbls))
+		for name := range lbls {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			keyvals = append(keyvals, name, lbls[name])
+		}
+	}
+	keyvals = append(keyvals, "line", line)
+
+	out, err := logfmt.MarshalKeyvals(keyvals...)
+	if err != nil {
+		log.Fatalf("error marshalling entry: %s", err)
+	}
+
+	fmt.Fprintln(o.w, string(out))
+}
+
+// WithWriter returns a copy of the output printing to the writer
+func (o *LogfmtOutput) WithWriter(w io.Writer) LogOutput {
+	return &LogfmtOutput{
+		w:       w,
+		options: o.options,
+	}
+}
@@ -0,0 +1,66 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/famarks/loki/pkg/loghttp"
+)
+
+func TestLogfmtOutput_Format(t *testing.T) {
+	t.Parallel()
+
+	timestamp, _ := time.Parse(time.RFC3339, "2006-01-02T15:04:05+07:00")
+	someLabels := loghttp.LabelSet(map[string]string{
+		"type": "test",
+		"app":  "foo bar",
+	})
+
+	tests := map[string]struct {
+		options  *LogOutputOptions
+		lbls     loghttp.LabelSet
+		line     string
+		expected string
+	}{
+		"empty line with no labels": {
+			&LogOutputOptions{Timezone: time.UTC, NoLabels: false},
+			loghttp.LabelSet{},
+			"",
+			`ts=2006-01-02T08:04:05Z line=` + "\n",
+		},
+		"line with labels": {
+			&LogOutputOptions{Timezone: time.UTC, NoLabels: false},
+			someLabels,
+			"level=info msg=\"Hello\"\n",
+			`ts=2006-01-02T08:04:05Z app="foo bar" type=test line="level=info msg=\"Hello\""` + "\n",
+		},
+		"timezone option set to a Local one": {
+			&LogOutputOptions{Timezone: time.FixedZone("test", 2*60*60), NoLabels: false},
+			someLabels,
+			"Hello",
+			`ts=2006-01-02T10:04:05+02:00 app="foo bar" type=test line=Hello` + "\n",
+		},
+		"labels output disabled": {
+			&LogOutputOptions{Timezone: time.UTC, NoLabels: true},
+			someLabels,
+			"Hello",
+			`ts=2006-01-02T08:04:05Z line=Hello` + "\n",
+		},
+	}
+
+	for testName, testData := range tests {
+		testData := testData
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+			writer := &bytes.Buffer{}
+			out := &LogfmtOutput{writer, testData.options}
+			out.FormatAndPrintln(timestamp, testData.lbls, 0, testData.line)
+
+			assert.Equal(t, testData.expected, writer.String())
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"hash/fnv"
 	"io"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
@@ -29,6 +31,8 @@ var colorList = []*color.Color{
 // LogOutput is the interface any output mode must implement
 type LogOutput interface {
 	FormatAndPrintln(ts time.Time, lbls loghttp.LabelSet, maxLabelsLen int, line string)
+	// WithWriter returns a copy of the output printing to the writer.
+	WithWriter(w io.Writer) LogOutput
 }
 
 // LogOutputOptions defines options supported by LogOutput
@@ -36,6 +40,8 @@ type LogOutputOptions struct {
 	Timezone      *time.Location
 	NoLabels      bool
 	ColoredOutput bool
+	// Template renders the entries with a Go template instead of the output mode, when set.
+	Template string
 }
 
 // NewLogOutput creates a log output based on the input mode and options
@@ -44,6 +50,10 @@ func NewLogOutput(w io.Writer, mode string, options *LogOutputOptions) (LogOutpu
 		options.Timezone = time.Local
 	}
 
+	if options.Template != "" {
+		return newTemplateOutput(w, options)
+	}
+
 	switch mode {
 	case "default":
 		return &DefaultOutput{
@@ -60,6 +70,11 @@ func NewLogOutput(w io.Writer, mode string, options *LogOutputOptions) (LogOutpu
 			w:       w,
 			options: options,
 		}, nil
+	case "logfmt":
+		return &LogfmtOutput{
+			w:       w,
+			options: options,
+		}, nil
 	default:
 		return nil, fmt.Errorf("unknown log output mode '%s'", mode)
 	}
@@ -72,3 +87,41 @@ func getColor(labels string) *color.Color {
 	color := colorList[id]
 	return color
 }
+
+var (
+	// levelRegexp matches the level of the lines formatted as logfmt or JSON, like level=error or "level":"error".
+	levelRegexp = regexp.MustCompile(`(?i)"?\b(?:level|lvl|severity)"?\s*[=:]\s*"?([a-z]+)`)
+	// levelWordRegexp matches the level written as a word by the usual log formats, like ERROR or WARN.
+	levelWordRegexp = regexp.MustCompile(`\b(CRIT|CRITICAL|FATAL|ERROR|ERR|WARN|WARNING|INFO|DEBUG|TRACE)\b`)
+)
+
+var levelColors = map[string]*color.Color{
+	"crit":     color.New(color.FgHiRed, color.Bold),
+	"critical": color.New(color.FgHiRed, color.Bold),
+	"fatal":    color.New(color.FgHiRed, color.Bold),
+	"error":    color.New(color.FgHiRed),
+	"err":      color.New(color.FgHiRed),
+	"warn":     color.New(color.FgHiYellow),
+	"warning":  color.New(color.FgHiYellow),
+	"debug":    color.New(color.FgHiBlack),
+	"trace":    color.New(color.FgHiBlack),
+}
+
+// detectLevel returns the level of the log line, empty when it isn't found.
+func detectLevel(line string) string {
+	if m := levelRegexp.FindStringSubmatch(line); m != nil {
+		return strings.ToLower(m[1])
+	}
+	if m := levelWordRegexp.FindStringSubmatch(line); m != nil {
+		return strings.ToLower(m[1])
+	}
+	return ""
+}
+
+// colorLine colors the line by its detected level, the info lines and the lines without a level aren't colored.
+func colorLine(line string) string {
+	if c, ok := levelColors[detectLevel(line)]; ok {
+		return c.Sprint(line)
+	}
+	return line
+}
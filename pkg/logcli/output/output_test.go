@@ -8,7 +8,7 @@ import (
 )
 
 func TestNewLogOutput(t *testing.T) {
-	options := &LogOutputOptions{time.UTC, false, false}
+	options := &LogOutputOptions{time.UTC, false, false, ""}
 
 	out, err := NewLogOutput(nil, "default", options)
 	assert.NoError(t, err)
@@ -22,7 +22,30 @@ func TestNewLogOutput(t *testing.T) {
 	assert.NoError(t, err)
 	assert.IsType(t, &RawOutput{nil, options}, out)
 
+	out, err = NewLogOutput(nil, "logfmt", options)
+	assert.NoError(t, err)
+	assert.IsType(t, &LogfmtOutput{nil, options}, out)
+
+	out, err = NewLogOutput(nil, "default", &LogOutputOptions{Timezone: time.UTC, Template: "{{.Line}}"})
+	assert.NoError(t, err)
+	assert.IsType(t, &TemplateOutput{}, out)
+
 	out, err = NewLogOutput(nil, "unknown", options)
 	assert.Error(t, err)
 	assert.Nil(t, out)
 }
+
+func TestDetectLevel(t *testing.T) {
+	for line, expected := range map[string]string{
+		`level=error msg="failed"`:           "error",
+		`ts=2020-01-01 lvl=WARN msg="slow"`:  "warn",
+		`{"level":"debug","msg":"details"}`:  "debug",
+		`{"severity": "CRITICAL"}`:           "critical",
+		`2020/01/01 12:00:00 [ERROR] failed`: "error",
+		`I0101 12:00:00 main.go:10] INFO ok`: "info",
+		`GET /api/v1/query 200`:              "",
+		`the error budget is burnt`:          "",
+	} {
+		assert.Equal(t, expected, detectLevel(line), line)
+	}
+}
@@ -0,0 +1,68 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"text/template"
+	"time"
+
+	"github.com/famarks/loki/pkg/loghttp"
+)
+
+// TemplateEntry is the log entry a template is executed with
+type TemplateEntry struct {
+	Timestamp time.Time
+	Labels    loghttp.LabelSet
+	Line      string
+}
+
+// TemplateOutput prints logs rendered with a Go template, one entry per line
+type TemplateOutput struct {
+	w        io.Writer
+	options  *LogOutputOptions
+	template *template.Template
+}
+
+func newTemplateOutput(w io.Writer, options *LogOutputOptions) (*TemplateOutput, error) {
+	tmpl, err := template.New("output").Option("missingkey=zero").Parse(options.Template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output template: %w", err)
+	}
+	return &TemplateOutput{
+		w:        w,
+		options:  options,
+		template: tmpl,
+	}, nil
+}
+
+// Format a log entry with the template
+func (o *TemplateOutput) FormatAndPrintln(ts time.Time, lbls loghttp.LabelSet, maxLabelsLen int, line string) {
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		line = line[:len(line)-1]
+	}
+
+	entry := TemplateEntry{
+		Timestamp: ts.In(o.options.Timezone),
+		Line:      line,
+	}
+
+	// Labels are optional
+	if !o.options.NoLabels {
+		entry.Labels = lbls
+	}
+
+	if err := o.template.Execute(o.w, entry); err != nil {
+		log.Fatalf("error executing output template: %s", err)
+	}
+	fmt.Fprintln(o.w)
+}
+
+// WithWriter returns a copy of the output printing to the writer
+func (o *TemplateOutput) WithWriter(w io.Writer) LogOutput {
+	return &TemplateOutput{
+		w:        w,
+		options:  o.options,
+		template: o.template,
+	}
+}
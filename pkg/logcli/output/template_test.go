@@ -0,0 +1,72 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/loghttp"
+)
+
+func TestTemplateOutput_Format(t *testing.T) {
+	t.Parallel()
+
+	timestamp, _ := time.Parse(time.RFC3339, "2006-01-02T15:04:05+07:00")
+	someLabels := loghttp.LabelSet(map[string]string{
+		"type": "test",
+	})
+
+	tests := map[string]struct {
+		options  *LogOutputOptions
+		lbls     loghttp.LabelSet
+		line     string
+		expected string
+	}{
+		"line with labels": {
+			&LogOutputOptions{Timezone: time.UTC, Template: `{{.Timestamp.Unix}} {{.Labels.type}} {{.Line}}`},
+			someLabels,
+			"Hello\n",
+			"1136189045 test Hello\n",
+		},
+		"missing label": {
+			&LogOutputOptions{Timezone: time.UTC, Template: `[{{.Labels.app}}] {{.Line}}`},
+			someLabels,
+			"Hello",
+			"[] Hello\n",
+		},
+		"timezone option set to a Local one": {
+			&LogOutputOptions{Timezone: time.FixedZone("test", 2*60*60), Template: `{{.Timestamp.Format "15:04:05"}} {{.Line}}`},
+			someLabels,
+			"Hello",
+			"10:04:05 Hello\n",
+		},
+		"labels output disabled": {
+			&LogOutputOptions{Timezone: time.UTC, NoLabels: true, Template: `{{.Labels}} {{.Line}}`},
+			someLabels,
+			"Hello",
+			"{} Hello\n",
+		},
+	}
+
+	for testName, testData := range tests {
+		testData := testData
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+			writer := &bytes.Buffer{}
+			out, err := newTemplateOutput(writer, testData.options)
+			require.NoError(t, err)
+			out.FormatAndPrintln(timestamp, testData.lbls, 0, testData.line)
+
+			assert.Equal(t, testData.expected, writer.String())
+		})
+	}
+}
+
+func TestNewTemplateOutput_Invalid(t *testing.T) {
+	_, err := newTemplateOutput(nil, &LogOutputOptions{Template: `{{.Line`})
+	assert.Error(t, err)
+}
@@ -0,0 +1,232 @@
+package push
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/famarks/loki/pkg/logcli/client"
+	"github.com/famarks/loki/pkg/logproto"
+	"github.com/famarks/loki/pkg/util"
+)
+
+// maxLineSize is the size of the longest line read from the files.
+const maxLineSize = 4 << 20
+
+// Push contains all necessary fields to push the lines of local files to Loki in batches
+type Push struct {
+	Files  []string
+	Labels string
+	// TimestampRegex extracts the timestamp of the lines, from its group named ts or its first group. The lines
+	// without a timestamp, like the lines of a multi-line entry, get the timestamp of the previous line.
+	TimestampRegex string
+	// TimestampFormat is the Go layout of the timestamps, or unix, unix_ms or unix_ns for unix timestamps.
+	TimestampFormat string
+	Location        *time.Location
+	BatchSize       int
+	// RateLimit is the number of bytes of lines pushed per second, unlimited when zero.
+	RateLimit float64
+	Quiet     bool
+}
+
+// DoPush pushes the lines of the files, the lines of stdin when there is no file
+func (p *Push) DoPush(c client.Client) {
+	if err := p.PushFiles(c); err != nil {
+		log.Fatalf("Push failed: %+v", err)
+	}
+}
+
+// PushFiles pushes the lines of the files, in order, to the stream of the labels
+func (p *Push) PushFiles(c client.Client) error {
+	lbls, err := util.ParseLabels(p.Labels)
+	if err != nil {
+		return fmt.Errorf("invalid labels %s: %w", p.Labels, err)
+	}
+	if len(lbls) == 0 {
+		return fmt.Errorf("at least one label is required")
+	}
+
+	var tsRegex *regexp.Regexp
+	tsGroup := 0
+	if p.TimestampRegex != "" {
+		tsRegex, err = regexp.Compile(p.TimestampRegex)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp regex: %w", err)
+		}
+		if tsRegex.NumSubexp() > 0 {
+			tsGroup = 1
+		}
+		for i, name := range tsRegex.SubexpNames() {
+			if name == "ts" {
+				tsGroup = i
+			}
+		}
+	}
+
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if p.RateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(p.RateLimit), p.BatchSize)
+	}
+
+	b := &batcher{
+		client:  c,
+		labels:  lbls.String(),
+		size:    p.BatchSize,
+		limiter: limiter,
+	}
+
+	files := p.Files
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, name := range files {
+		if err := p.pushFile(b, name, tsRegex, tsGroup); err != nil {
+			return err
+		}
+	}
+	if err := b.flush(); err != nil {
+		return err
+	}
+
+	if !p.Quiet {
+		log.Printf("Pushed %d entries (%d bytes) in %d batches to %s", b.entries, b.bytes, b.batches, b.labels)
+	}
+	return nil
+}
+
+func (p *Push) pushFile(b *batcher, name string, tsRegex *regexp.Regexp, tsGroup int) error {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	var ts time.Time
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		switch {
+		case tsRegex == nil:
+			ts = time.Now()
+		default:
+			m := tsRegex.FindStringSubmatch(line)
+			if m == nil {
+				if ts.IsZero() {
+					return fmt.Errorf("no timestamp found in line %d of %s", n, name)
+				}
+				break
+			}
+			parsed, err := parseTimestamp(m[tsGroup], p.TimestampFormat, p.Location)
+			if err != nil {
+				return fmt.Errorf("invalid timestamp in line %d of %s: %w", n, name, err)
+			}
+			ts = parsed
+		}
+
+		if err := b.add(logproto.Entry{Timestamp: ts, Line: line}); err != nil {
+			return fmt.Errorf("failed to push the lines of %s until line %d: %w", name, n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// parseTimestamp parses the timestamp with the format, in the location when it has none.
+func parseTimestamp(s, format string, location *time.Location) (time.Time, error) {
+	switch format {
+	case "unix":
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		sec, dec := math.Modf(f)
+		return time.Unix(int64(sec), int64(dec*1e9)), nil
+	case "unix_ms":
+		ms, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, ms*int64(time.Millisecond)), nil
+	case "unix_ns":
+		ns, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, ns), nil
+	default:
+		if location == nil {
+			location = time.Local
+		}
+		return time.ParseInLocation(format, s, location)
+	}
+}
+
+// batcher pushes the entries of a stream in batches of about size bytes.
+type batcher struct {
+	client  client.Client
+	labels  string
+	size    int
+	limiter *rate.Limiter
+
+	pending      []logproto.Entry
+	pendingBytes int
+
+	entries, bytes, batches int
+}
+
+func (b *batcher) add(entry logproto.Entry) error {
+	if len(b.pending) > 0 && b.pendingBytes+len(entry.Line) > b.size {
+		if err := b.flush(); err != nil {
+			return err
+		}
+	}
+	b.pending = append(b.pending, entry)
+	b.pendingBytes += len(entry.Line)
+	return nil
+}
+
+func (b *batcher) flush() error {
+	if len(b.pending) == 0 {
+		return nil
+	}
+
+	// a line longer than the batch size is pushed alone, without waiting longer than for a full batch.
+	n := b.pendingBytes
+	if b.limiter.Burst() > 0 && n > b.limiter.Burst() {
+		n = b.limiter.Burst()
+	}
+	if err := b.limiter.WaitN(context.Background(), n); err != nil {
+		return err
+	}
+
+	req := &logproto.PushRequest{
+		Streams: []logproto.Stream{{Labels: b.labels, Entries: b.pending}},
+	}
+	if err := b.client.Push(req, true); err != nil {
+		return err
+	}
+
+	b.entries += len(b.pending)
+	b.bytes += b.pendingBytes
+	b.batches++
+	b.pending, b.pendingBytes = nil, 0
+	return nil
+}
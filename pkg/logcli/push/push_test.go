@@ -0,0 +1,84 @@
+package push
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/logcli/client"
+	"github.com/famarks/loki/pkg/logproto"
+)
+
+type pushClient struct {
+	client.Client
+	requests []*logproto.PushRequest
+}
+
+func (c *pushClient) Push(req *logproto.PushRequest, quiet bool) error {
+	c.requests = append(c.requests, req)
+	return nil
+}
+
+func TestPushFiles(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "push")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "app.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`2020-09-13 12:26:40 level=info msg="started"
+2020-09-13 12:26:41 level=error msg="panic"
+goroutine 1 [running]:
+
+2020-09-13 12:26:42 level=info msg="restarted"
+`), 0644))
+
+	c := &pushClient{}
+	p := &Push{
+		Files:           []string{path},
+		Labels:          `{job="import", app="foo"}`,
+		TimestampRegex:  `^(?P<ts>\d{4}-\d{2}-\d{2} \S+)`,
+		TimestampFormat: "2006-01-02 15:04:05",
+		Location:        time.UTC,
+		BatchSize:       80,
+		Quiet:           true,
+	}
+	require.NoError(t, p.PushFiles(c))
+
+	require.Len(t, c.requests, 3)
+	require.Equal(t, `{app="foo", job="import"}`, c.requests[0].Streams[0].Labels)
+	require.Equal(t, []logproto.Entry{
+		{Timestamp: time.Date(2020, 9, 13, 12, 26, 40, 0, time.UTC), Line: `2020-09-13 12:26:40 level=info msg="started"`},
+	}, c.requests[0].Streams[0].Entries)
+	// the lines without a timestamp get the timestamp of the previous line.
+	require.Equal(t, []logproto.Entry{
+		{Timestamp: time.Date(2020, 9, 13, 12, 26, 41, 0, time.UTC), Line: `2020-09-13 12:26:41 level=error msg="panic"`},
+		{Timestamp: time.Date(2020, 9, 13, 12, 26, 41, 0, time.UTC), Line: `goroutine 1 [running]:`},
+	}, c.requests[1].Streams[0].Entries)
+
+	p.TimestampRegex = `^ts=(\d+)`
+	require.EqualError(t, p.PushFiles(c), "no timestamp found in line 1 of "+path)
+}
+
+func TestParseTimestamp(t *testing.T) {
+	for _, tc := range []struct {
+		value, format string
+		expected      time.Time
+	}{
+		{"1600000000.5", "unix", time.Unix(1600000000, 500000000)},
+		{"1600000000123", "unix_ms", time.Unix(1600000000, 123000000)},
+		{"1600000000000000001", "unix_ns", time.Unix(1600000000, 1)},
+		{"2020-09-13T12:26:40.1Z", time.RFC3339Nano, time.Date(2020, 9, 13, 12, 26, 40, 100000000, time.UTC)},
+		{"13/Sep/2020:12:26:40", "02/Jan/2006:15:04:05", time.Date(2020, 9, 13, 12, 26, 40, 0, time.UTC)},
+	} {
+		ts, err := parseTimestamp(tc.value, tc.format, time.UTC)
+		require.NoError(t, err)
+		require.True(t, tc.expected.Equal(ts), tc.value)
+	}
+
+	_, err := parseTimestamp("yesterday", "unix", time.UTC)
+	require.Error(t, err)
+}
@@ -0,0 +1,128 @@
+package query
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/famarks/loki/pkg/logcli/client"
+	"github.com/famarks/loki/pkg/logcli/output"
+)
+
+const partTimeFormat = "20060102T150405"
+
+// partJob is the query of a part of the time range, written to its part file.
+type partJob struct {
+	start, end time.Time
+	path       string
+}
+
+// DoQueryParallel splits the time range of the query in parts of ParallelDuration, runs the batched queries of
+// ParallelMaxWorkers parts concurrently and writes the results of each part to its part file. A part is written to a
+// temporary file renamed once its query completed, so a download interrupted is resumed by running it again: the parts
+// already completed are skipped unless OverwriteCompleted is set. The limit and the batch size apply to every part.
+func (q *Query) DoQueryParallel(c client.Client, out output.LogOutput, statistics bool) {
+	if q.PartPathPrefix == "" {
+		log.Fatalf("The part path prefix is required to download the parts of a query")
+	}
+
+	jobs := q.partJobs()
+	queue := make(chan partJob, len(jobs))
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+
+	workers := q.ParallelMaxWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(jobs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				if err := q.downloadPart(c, out, statistics, job); err != nil {
+					log.Fatalf("Query of part %s failed: %+v", job.path, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if q.MergeParts {
+		for _, job := range jobs {
+			if err := mergePart(os.Stdout, job.path, q.KeepParts); err != nil {
+				log.Fatalf("Failed to merge part %s: %+v", job.path, err)
+			}
+		}
+	}
+}
+
+// partJobs splits the time range of the query in parts, ordered in the direction of the query.
+func (q *Query) partJobs() []partJob {
+	var jobs []partJob
+	for start := q.Start; start.Before(q.End); start = start.Add(q.ParallelDuration) {
+		end := start.Add(q.ParallelDuration)
+		if end.After(q.End) {
+			end = q.End
+		}
+		jobs = append(jobs, partJob{
+			start: start,
+			end:   end,
+			path:  fmt.Sprintf("%s_%s_%s.part", q.PartPathPrefix, start.UTC().Format(partTimeFormat), end.UTC().Format(partTimeFormat)),
+		})
+	}
+
+	if !q.Forward {
+		for i, j := 0, len(jobs)-1; i < j; i, j = i+1, j-1 {
+			jobs[i], jobs[j] = jobs[j], jobs[i]
+		}
+	}
+	return jobs
+}
+
+func (q *Query) downloadPart(c client.Client, out output.LogOutput, statistics bool, job partJob) error {
+	if _, err := os.Stat(job.path); err == nil && !q.OverwriteCompleted {
+		if !q.Quiet {
+			log.Println("Skipping completed part", job.path)
+		}
+		return nil
+	}
+
+	tmpPath := job.path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	partQuery := *q
+	partQuery.Start, partQuery.End = job.start, job.end
+	partQuery.DoQuery(c, out.WithWriter(f), statistics)
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, job.path)
+}
+
+// mergePart copies the part file to the writer, and removes it unless it is kept.
+func mergePart(w io.Writer, path string, keep bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	_ = f.Close()
+	if err != nil {
+		return err
+	}
+	if keep {
+		return nil
+	}
+	return os.Remove(path)
+}
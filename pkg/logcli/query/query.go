@@ -2,6 +2,7 @@ package query
 
 import (
 	"context"
+	"encoding/csv"
 	"errors"
 	"flag"
 	"fmt"
@@ -17,6 +18,7 @@ import (
 	"github.com/fatih/color"
 	json "github.com/json-iterator/go"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
 	"github.com/weaveworks/common/user"
 
 	"github.com/famarks/loki/pkg/cfg"
@@ -54,6 +56,18 @@ type Query struct {
 	FixedLabelsLen  int
 	ColoredOutput   bool
 	LocalConfig     string
+	// Follow reconnects the tail when its connection is lost.
+	Follow bool
+	// VectorFormat is the format of the vector results: json, table or csv.
+	VectorFormat string
+
+	// ParallelDuration splits the time range of the query in parts downloaded to part files, when set.
+	ParallelDuration   time.Duration
+	ParallelMaxWorkers int
+	PartPathPrefix     string
+	OverwriteCompleted bool
+	MergeParts         bool
+	KeepParts          bool
 }
 
 // DoQuery executes the query and prints out the results
@@ -76,16 +90,18 @@ func (q *Query) DoQuery(c client.Client, out output.LogOutput, statistics bool)
 		if err != nil {
 			log.Fatalf("Query failed: %+v", err)
 		}
+		_, _ = q.printResult(resp.Data.Result, out, nil)
 		if statistics {
 			q.printStats(resp.Data.Statistics)
 		}
-		_, _ = q.printResult(resp.Data.Result, out, nil)
 	} else {
 		if q.Limit < q.BatchSize {
 			q.BatchSize = q.Limit
 		}
 		resultLength := 0
 		total := 0
+		// the statistics of the batches are merged and printed once after all the results.
+		var batchStats stats.Result
 		start := q.Start
 		end := q.End
 		var lastEntry []*loghttp.Entry
@@ -104,9 +120,7 @@ func (q *Query) DoQuery(c client.Client, out output.LogOutput, statistics bool)
 				log.Fatalf("Query failed: %+v", err)
 			}
 
-			if statistics {
-				q.printStats(resp.Data.Statistics)
-			}
+			batchStats.Merge(resp.Data.Statistics)
 
 			resultLength, lastEntry = q.printResult(resp.Data.Result, out, lastEntry)
 			// Was not a log stream query, or no results, no more batching
@@ -148,6 +162,9 @@ func (q *Query) DoQuery(c client.Client, out output.LogOutput, statistics bool)
 			}
 
 		}
+		if statistics {
+			q.printStats(batchStats)
+		}
 	}
 
 }
@@ -235,16 +252,15 @@ func (q *Query) DoLocalQuery(out output.LogOutput, statistics bool, orgID string
 		return err
 	}
 
-	if statistics {
-		q.printStats(result.Statistics)
-	}
-
 	value, err := marshal.NewResultValue(result.Data)
 	if err != nil {
 		return err
 	}
 
 	q.printResult(value, out, nil)
+	if statistics {
+		q.printStats(result.Statistics)
+	}
 	return nil
 }
 
@@ -370,13 +386,59 @@ func (q *Query) printMatrix(matrix loghttp.Matrix) {
 }
 
 func (q *Query) printVector(vector loghttp.Vector) {
-	bytes, err := json.MarshalIndent(vector, "", "  ")
+	switch q.VectorFormat {
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		writeVector(vector, func(record []string) {
+			fmt.Fprintln(w, strings.Join(record, "\t"))
+		})
+		w.Flush()
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		writeVector(vector, func(record []string) {
+			_ = w.Write(record)
+		})
+		w.Flush()
+		if err := w.Error(); err != nil {
+			log.Fatalf("Error writing vector: %v", err)
+		}
+	default:
+		bytes, err := json.MarshalIndent(vector, "", "  ")
 
-	if err != nil {
-		log.Fatalf("Error marshalling vector: %v", err)
+		if err != nil {
+			log.Fatalf("Error marshalling vector: %v", err)
+		}
+
+		fmt.Print(string(bytes))
 	}
+}
 
-	fmt.Print(string(bytes))
+// writeVector writes the vector as records: a header with the names of the labels of all the samples followed by the
+// timestamp and the value, then a record by sample.
+func writeVector(vector loghttp.Vector, write func(record []string)) {
+	names := map[string]struct{}{}
+	for _, sample := range vector {
+		for name := range sample.Metric {
+			names[string(name)] = struct{}{}
+		}
+	}
+	header := make([]string, 0, len(names)+2)
+	for name := range names {
+		header = append(header, name)
+	}
+	sort.Strings(header)
+	labelNames := header
+	header = append(header, "timestamp", "value")
+	write(header)
+
+	for _, sample := range vector {
+		record := make([]string, 0, len(header))
+		for _, name := range labelNames {
+			record = append(record, string(sample.Metric[model.LabelName(name)]))
+		}
+		record = append(record, sample.Timestamp.Time().UTC().Format(time.RFC3339Nano), sample.Value.String())
+		write(record)
+	}
 }
 
 func (q *Query) printScalar(scalar loghttp.Scalar) {
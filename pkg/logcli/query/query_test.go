@@ -3,14 +3,19 @@ package query
 import (
 	"bytes"
 	"context"
+	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/famarks/loki/pkg/logcli/output"
 	"github.com/famarks/loki/pkg/loghttp"
@@ -150,6 +155,102 @@ func Test_subtract(t *testing.T) {
 	}
 }
 
+func Test_writeVector(t *testing.T) {
+	vector := loghttp.Vector{
+		{Metric: model.Metric{"app": "foo", "level": "error"}, Value: 1.5, Timestamp: model.TimeFromUnix(1600000000)},
+		{Metric: model.Metric{"app": "bar"}, Value: 2, Timestamp: model.TimeFromUnix(1600000000)},
+	}
+
+	var records [][]string
+	writeVector(vector, func(record []string) {
+		records = append(records, record)
+	})
+	assert.Equal(t, [][]string{
+		{"app", "level", "timestamp", "value"},
+		{"foo", "error", "2020-09-13T12:26:40Z", "1.5"},
+		{"bar", "", "2020-09-13T12:26:40Z", "2"},
+	}, records)
+}
+
+func Test_DoQueryParallel(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "parallel")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	tc := newTestQueryClient(logproto.Stream{
+		Labels: "{test=\"simple\"}",
+		Entries: []logproto.Entry{
+			{Timestamp: time.Unix(1, 0), Line: "line1"},
+			{Timestamp: time.Unix(2, 0), Line: "line2"},
+			{Timestamp: time.Unix(3, 0), Line: "line3"},
+			{Timestamp: time.Unix(4, 0), Line: "line4"},
+			{Timestamp: time.Unix(5, 0), Line: "line5"},
+		},
+	})
+	q := &Query{
+		QueryString:        "{test=\"simple\"}",
+		Start:              time.Unix(1, 0),
+		End:                time.Unix(6, 0),
+		Limit:              10,
+		BatchSize:          10,
+		Forward:            true,
+		Quiet:              true,
+		ParallelDuration:   2 * time.Second,
+		ParallelMaxWorkers: 1,
+		PartPathPrefix:     filepath.Join(tempDir, "export"),
+	}
+	out, err := output.NewLogOutput(nil, "raw", &output.LogOutputOptions{Timezone: time.UTC})
+	require.NoError(t, err)
+
+	jobs := q.partJobs()
+	require.Len(t, jobs, 3)
+	require.Equal(t, time.Unix(5, 0), jobs[2].start)
+	require.Equal(t, time.Unix(6, 0), jobs[2].end)
+	require.Equal(t, filepath.Join(tempDir, "export_19700101T000005_19700101T000006.part"), jobs[2].path)
+
+	q.DoQueryParallel(tc, out, false)
+	for i, expected := range []string{"line1\nline2\n", "line3\nline4\n", "line5\n"} {
+		data, err := ioutil.ReadFile(jobs[i].path)
+		require.NoError(t, err)
+		require.Equal(t, expected, string(data))
+	}
+
+	// the completed parts are skipped.
+	calls := tc.queryRangeCalls
+	require.NoError(t, os.Remove(jobs[1].path))
+	q.DoQueryParallel(tc, out, false)
+	require.Equal(t, calls+2, tc.queryRangeCalls)
+	data, err := ioutil.ReadFile(jobs[1].path)
+	require.NoError(t, err)
+	require.Equal(t, "line3\nline4\n", string(data))
+
+	// the parts are printed in the direction of the query.
+	q.Forward = false
+	jobs = q.partJobs()
+	require.Equal(t, time.Unix(5, 0), jobs[0].start)
+	require.Equal(t, time.Unix(1, 0), jobs[2].start)
+}
+
+func Test_tailPosition(t *testing.T) {
+	foo := loghttp.LabelSet{"app": "foo"}
+	bar := loghttp.LabelSet{"app": "bar"}
+	p := &tailPosition{}
+
+	require.True(t, p.next(foo, loghttp.Entry{Timestamp: time.Unix(1, 0), Line: "line1"}))
+	require.True(t, p.next(foo, loghttp.Entry{Timestamp: time.Unix(2, 0), Line: "line2"}))
+	require.True(t, p.next(bar, loghttp.Entry{Timestamp: time.Unix(2, 0), Line: "line2"}))
+	// the entries out of order are printed while not resuming.
+	require.True(t, p.next(bar, loghttp.Entry{Timestamp: time.Unix(1, 0), Line: "line1"}))
+
+	// the entries sent again when resuming are skipped.
+	p.resume()
+	require.False(t, p.next(foo, loghttp.Entry{Timestamp: time.Unix(1, 0), Line: "line1"}))
+	require.False(t, p.next(foo, loghttp.Entry{Timestamp: time.Unix(2, 0), Line: "line2"}))
+	require.True(t, p.next(foo, loghttp.Entry{Timestamp: time.Unix(2, 0), Line: "line2 again"}))
+	require.True(t, p.next(foo, loghttp.Entry{Timestamp: time.Unix(3, 0), Line: "line3"}))
+	require.True(t, p.next(foo, loghttp.Entry{Timestamp: time.Unix(1, 0), Line: "late"}))
+}
+
 func Test_batch(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -555,6 +656,22 @@ func (t *testQueryClient) LiveTailQueryConn(queryStr string, delayFor int, limit
 	panic("implement me")
 }
 
+func (t *testQueryClient) CreateDeleteRequest(query string, from, through time.Time, quiet bool) error {
+	panic("implement me")
+}
+
+func (t *testQueryClient) ListDeleteRequests(quiet bool) ([]loghttp.DeleteRequestResponse, error) {
+	panic("implement me")
+}
+
+func (t *testQueryClient) CancelDeleteRequest(requestID string, quiet bool) error {
+	panic("implement me")
+}
+
+func (t *testQueryClient) Push(req *logproto.PushRequest, quiet bool) error {
+	panic("implement me")
+}
+
 func (t *testQueryClient) GetOrgID() string {
 	panic("implement me")
 }
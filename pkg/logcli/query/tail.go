@@ -1,12 +1,16 @@
 package query
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
+	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/fatih/color"
 	"github.com/gorilla/websocket"
 
@@ -15,25 +19,32 @@ import (
 	"github.com/famarks/loki/pkg/loghttp"
 )
 
-// TailQuery connects to the Loki websocket endpoint and tails logs
+var tailReconnectBackoff = util.BackoffConfig{
+	MinBackoff: time.Second,
+	MaxBackoff: 30 * time.Second,
+}
+
+// TailQuery connects to the Loki websocket endpoint and tails logs. The query can be a full LogQL log query, its
+// pipeline being applied by the server. When following, the connection is opened again once lost, resuming after the
+// last entry printed.
 func (q *Query) TailQuery(delayFor int, c client.Client, out output.LogOutput) {
 	conn, err := c.LiveTailQueryConn(q.QueryString, delayFor, q.Limit, q.Start.UnixNano(), q.Quiet)
 	if err != nil {
 		log.Fatalf("Tailing logs failed: %+v", err)
 	}
 
+	var connMtx sync.Mutex
 	go func() {
 		stopChan := make(chan os.Signal, 1)
 		signal.Notify(stopChan, os.Interrupt, syscall.SIGTERM)
 		<-stopChan
+		connMtx.Lock()
 		if err := conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")); err != nil {
 			log.Println("Error closing websocket:", err)
 		}
 		os.Exit(0)
 	}()
 
-	tailResponse := new(loghttp.TailResponse)
-
 	if len(q.IgnoreLabelsKey) > 0 {
 		log.Println("Ignoring labels key:", color.RedString(strings.Join(q.IgnoreLabelsKey, ",")))
 	}
@@ -42,12 +53,43 @@ func (q *Query) TailQuery(delayFor int, c client.Client, out output.LogOutput) {
 		log.Println("Print only labels key:", color.RedString(strings.Join(q.ShowLabelsKey, ",")))
 	}
 
+	printed := &tailPosition{}
 	for {
-		err := conn.ReadJSON(tailResponse)
-		if err != nil {
+		err := q.printTail(conn, out, printed)
+		if !q.Follow {
 			log.Println("Error reading stream:", err)
 			return
 		}
+		log.Println("Error reading stream, reconnecting:", err)
+
+		from := q.Start
+		if !printed.last.IsZero() {
+			from = printed.last
+			printed.resume()
+		}
+		backoff := util.NewBackoff(context.Background(), tailReconnectBackoff)
+		for {
+			newConn, err := c.LiveTailQueryConn(q.QueryString, delayFor, q.Limit, from.UnixNano(), q.Quiet)
+			if err == nil {
+				connMtx.Lock()
+				conn = newConn
+				connMtx.Unlock()
+				break
+			}
+			log.Println("Error reconnecting:", err)
+			backoff.Wait()
+		}
+	}
+}
+
+// printTail prints the entries read from the connection until it fails.
+func (q *Query) printTail(conn *websocket.Conn, out output.LogOutput, printed *tailPosition) error {
+	tailResponse := new(loghttp.TailResponse)
+	for {
+		err := conn.ReadJSON(tailResponse)
+		if err != nil {
+			return err
+		}
 
 		labels := loghttp.LabelSet{}
 		for _, stream := range tailResponse.Streams {
@@ -62,7 +104,7 @@ func (q *Query) TailQuery(delayFor int, c client.Client, out output.LogOutput) {
 					}
 
 					if len(q.IgnoreLabelsKey) > 0 {
-						ls = matchLabels(false, ls, q.ShowLabelsKey)
+						ls = matchLabels(false, ls, q.IgnoreLabelsKey)
 					}
 
 					labels = ls
@@ -74,6 +116,9 @@ func (q *Query) TailQuery(delayFor int, c client.Client, out output.LogOutput) {
 			}
 
 			for _, entry := range stream.Entries {
+				if !printed.next(stream.Labels, entry) {
+					continue
+				}
 				out.FormatAndPrintln(entry.Timestamp, labels, 0, entry.Line)
 			}
 
@@ -86,3 +131,36 @@ func (q *Query) TailQuery(delayFor int, c client.Client, out output.LogOutput) {
 		}
 	}
 }
+
+// tailPosition is the timestamp of the last entry printed, along the entries printed at this timestamp, so the
+// entries sent again when resuming the tail from this timestamp aren't printed twice.
+type tailPosition struct {
+	last     time.Time
+	entries  map[string]struct{}
+	resuming bool
+}
+
+// resume skips the entries already printed until an entry after the last one is read.
+func (p *tailPosition) resume() {
+	p.resuming = true
+}
+
+// next tells whether the entry is printed, moving the position to it when it is.
+func (p *tailPosition) next(labels loghttp.LabelSet, entry loghttp.Entry) bool {
+	key := labels.String() + entry.Line
+	switch {
+	case entry.Timestamp.After(p.last):
+		p.last = entry.Timestamp
+		p.entries = map[string]struct{}{key: {}}
+		p.resuming = false
+		return true
+	case entry.Timestamp.Equal(p.last):
+		if _, ok := p.entries[key]; ok && p.resuming {
+			return false
+		}
+		p.entries[key] = struct{}{}
+		return true
+	default:
+		return !p.resuming
+	}
+}
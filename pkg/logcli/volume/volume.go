@@ -0,0 +1,135 @@
+package volume
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/prometheus/common/model"
+
+	"github.com/famarks/loki/pkg/logcli/client"
+	"github.com/famarks/loki/pkg/loghttp"
+	"github.com/famarks/loki/pkg/logproto"
+)
+
+// Query contains all necessary fields to compute the volume of the streams matching a selector and print out the
+// results. The volume is computed by metric queries over the whole time range, which read all the matching chunks.
+type Query struct {
+	Matcher string
+	Start   time.Time
+	End     time.Time
+	GroupBy []string
+	Limit   int
+	Quiet   bool
+}
+
+// Volume is the volume of the streams sharing the values of the grouping labels.
+type Volume struct {
+	Labels model.Metric
+	Bytes  float64
+}
+
+// DoVolume prints out the top volumes by the grouping labels, along their share of the total volume
+func (q *Query) DoVolume(c client.Client) {
+	volumes, total := q.GetVolumes(c)
+	printVolumes(os.Stdout, q.GroupBy, volumes, total, q.Limit)
+}
+
+// GetVolumes returns the volumes by the grouping labels, the largest first, along the total volume
+func (q *Query) GetVolumes(c client.Client) ([]Volume, float64) {
+	query := fmt.Sprintf("sum by (%s) (bytes_over_time(%s))", strings.Join(q.GroupBy, ","), q.rangeSelector())
+	vector := q.instantQuery(c, query)
+
+	volumes := make([]Volume, 0, len(vector))
+	var total float64
+	for _, sample := range vector {
+		volumes = append(volumes, Volume{Labels: sample.Metric, Bytes: float64(sample.Value)})
+		total += float64(sample.Value)
+	}
+	sort.Slice(volumes, func(i, j int) bool {
+		if volumes[i].Bytes == volumes[j].Bytes {
+			return volumes[i].Labels.String() < volumes[j].Labels.String()
+		}
+		return volumes[i].Bytes > volumes[j].Bytes
+	})
+	return volumes, total
+}
+
+// DoStats prints out the number of streams, chunks, entries and bytes matching the selector
+func (q *Query) DoStats(c client.Client) {
+	seriesResponse, err := c.Series([]string{q.Matcher}, q.Start, q.End, q.Quiet)
+	if err != nil {
+		log.Fatalf("Error doing request: %+v", err)
+	}
+
+	bytesResponse := q.doQuery(c, fmt.Sprintf("sum(bytes_over_time(%s))", q.rangeSelector()))
+	entries := q.instantQuery(c, fmt.Sprintf("sum(count_over_time(%s))", q.rangeSelector()))
+	statistics := bytesResponse.Data.Statistics
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Streams:\t%d\n", len(seriesResponse.Data))
+	fmt.Fprintf(w, "Chunks:\t%d\n", statistics.Store.TotalChunksRef+statistics.Ingester.TotalChunksMatched)
+	fmt.Fprintf(w, "Entries:\t%d\n", int64(sum(entries)))
+	fmt.Fprintf(w, "Bytes:\t%s\n", humanize.Bytes(uint64(sum(vectorOf(bytesResponse)))))
+	w.Flush()
+}
+
+// rangeSelector returns the selector with the time range of the query as range.
+func (q *Query) rangeSelector() string {
+	return fmt.Sprintf("%s[%ds]", q.Matcher, int64(q.End.Sub(q.Start).Seconds()))
+}
+
+func (q *Query) doQuery(c client.Client, query string) *loghttp.QueryResponse {
+	// the limit applies to the log entries returned, not to the samples of the metric queries.
+	resp, err := c.Query(query, 1, q.End, logproto.BACKWARD, q.Quiet)
+	if err != nil {
+		log.Fatalf("Query failed: %+v", err)
+	}
+	return resp
+}
+
+func (q *Query) instantQuery(c client.Client, query string) loghttp.Vector {
+	return vectorOf(q.doQuery(c, query))
+}
+
+func vectorOf(resp *loghttp.QueryResponse) loghttp.Vector {
+	vector, ok := resp.Data.Result.(loghttp.Vector)
+	if !ok {
+		log.Fatalf("Unexpected result type: %v", resp.Data.ResultType)
+	}
+	return vector
+}
+
+func sum(vector loghttp.Vector) float64 {
+	var total float64
+	for _, sample := range vector {
+		total += float64(sample.Value)
+	}
+	return total
+}
+
+func printVolumes(out io.Writer, groupBy []string, volumes []Volume, total float64, limit int) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tBytes\tShare\n", strings.Join(groupBy, "\t"))
+	for i, v := range volumes {
+		if limit > 0 && i >= limit {
+			break
+		}
+		values := make([]string, 0, len(groupBy))
+		for _, name := range groupBy {
+			values = append(values, string(v.Labels[model.LabelName(name)]))
+		}
+		share := 0.0
+		if total > 0 {
+			share = 100 * v.Bytes / total
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.1f%%\n", strings.Join(values, "\t"), humanize.Bytes(uint64(v.Bytes)), share)
+	}
+	w.Flush()
+}
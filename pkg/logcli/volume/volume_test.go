@@ -0,0 +1,54 @@
+package volume
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/logcli/client"
+	"github.com/famarks/loki/pkg/loghttp"
+	"github.com/famarks/loki/pkg/logproto"
+)
+
+type vectorClient struct {
+	client.Client
+	queries []string
+	vector  loghttp.Vector
+}
+
+func (c *vectorClient) Query(queryStr string, limit int, time time.Time, direction logproto.Direction, quiet bool) (*loghttp.QueryResponse, error) {
+	c.queries = append(c.queries, queryStr)
+	return &loghttp.QueryResponse{
+		Data: loghttp.QueryResponseData{ResultType: loghttp.ResultTypeVector, Result: c.vector},
+	}, nil
+}
+
+func TestVolumes(t *testing.T) {
+	c := &vectorClient{vector: loghttp.Vector{
+		{Metric: model.Metric{"app": "foo", "env": "prod"}, Value: 1000},
+		{Metric: model.Metric{"app": "bar", "env": "prod"}, Value: 3000},
+		{Metric: model.Metric{"app": "baz"}, Value: 1000},
+	}}
+	q := &Query{
+		Matcher: `{namespace="loki"}`,
+		Start:   time.Unix(0, 0),
+		End:     time.Unix(3600, 0),
+		GroupBy: []string{"app", "env"},
+		Limit:   2,
+	}
+
+	volumes, total := q.GetVolumes(c)
+	require.Equal(t, []string{`sum by (app,env) (bytes_over_time({namespace="loki"}[3600s]))`}, c.queries)
+	require.Equal(t, float64(5000), total)
+	require.Equal(t, model.LabelValue("bar"), volumes[0].Labels["app"])
+
+	var out bytes.Buffer
+	printVolumes(&out, q.GroupBy, volumes, total, q.Limit)
+	require.Equal(t, `app  env   Bytes   Share
+bar  prod  3.0 kB  60.0%
+baz        1.0 kB  20.0%
+`, out.String())
+}
@@ -0,0 +1,34 @@
+package stages
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// ansiEscape matches the ANSI escape sequences: the CSI sequences like the colors, the OSC sequences like the window
+// titles and hyperlinks, and the two characters escapes.
+var ansiEscape = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[0-~])`)
+
+// newDecolorizeStage creates a decolorizeStage, which has no config.
+func newDecolorizeStage(config interface{}) (Stage, error) {
+	return &decolorizeStage{}, nil
+}
+
+// decolorizeStage strips the ANSI escape sequences, like the colors of the terminal, from the log line.
+type decolorizeStage struct{}
+
+// Process implements Stage
+func (d *decolorizeStage) Process(labels model.LabelSet, extracted map[string]interface{}, t *time.Time, entry *string) {
+	if !strings.Contains(*entry, "\x1b") {
+		return
+	}
+	*entry = ansiEscape.ReplaceAllString(*entry, "")
+}
+
+// Name implements Stage
+func (d *decolorizeStage) Name() string {
+	return StageTypeDecolorize
+}
@@ -0,0 +1,53 @@
+package stages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+var testDecolorizeYaml = `
+pipeline_stages:
+- decolorize:
+- regex:
+    expression: "^(?P<level>\\w+) "
+`
+
+func TestDecolorizeStage(t *testing.T) {
+	stage, err := newDecolorizeStage(nil)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		entry    string
+		expected string
+	}{
+		{"no colors", "no colors"},
+		{"\x1b[31merror\x1b[0m: failed", "error: failed"},
+		{"\x1b[1;38;5;208mbold orange\x1b[m", "bold orange"},
+		{"\x1b[2K\x1b[1Gprogress", "progress"},
+		{"\x1b]0;title\x07line", "line"},
+		{"\x1b]8;;https://grafana.com\x1b\\link\x1b]8;;\x1b\\", "link"},
+		{"\x1bcreset", "reset"},
+	} {
+		entry := tc.entry
+		ts := time.Now()
+		stage.Process(model.LabelSet{}, map[string]interface{}{}, &ts, &entry)
+		require.Equal(t, tc.expected, entry)
+	}
+}
+
+func TestDecolorizeStage_Pipeline(t *testing.T) {
+	pl, err := NewPipeline(util.Logger, loadConfig(testDecolorizeYaml), nil, prometheus.DefaultRegisterer)
+	require.NoError(t, err)
+
+	extracted := map[string]interface{}{}
+	ts := time.Now()
+	entry := "\x1b[33mwarn\x1b[0m disk almost full"
+	pl.Process(model.LabelSet{}, extracted, &ts, &entry)
+	require.Equal(t, "warn disk almost full", entry)
+	require.Equal(t, "warn", extracted["level"])
+}
@@ -143,7 +143,8 @@ func (m *dropStage) Process(labels model.LabelSet, extracted map[string]interfac
 					level.Debug(m.logger).Log("msg", "line met drop criteria for finding source key in extracted map")
 				}
 			} else {
-				if *m.cfg.Value == v {
+				// The extracted values of the json stage may not be strings, like the numbers and booleans
+				if s, err := getString(v); err == nil && *m.cfg.Value == s {
 					// Found in map with value set for drop
 					if Debug {
 						level.Debug(m.logger).Log("msg", "line met drop criteria for finding source key in extracted map with value matching desired drop value")
@@ -140,6 +140,18 @@ func Test_dropStage_Process(t *testing.T) {
 			},
 			shouldDrop: true,
 		},
+		{
+			name: "Matched Source and numeric Value",
+			config: &DropConfig{
+				Source: ptrFromString("status"),
+				Value:  ptrFromString("404"),
+			},
+			labels: model.LabelSet{},
+			extracted: map[string]interface{}{
+				"status": float64(404),
+			},
+			shouldDrop: true,
+		},
 		{
 			name: "Did not match Source and Value",
 			config: &DropConfig{
@@ -326,6 +338,11 @@ var (
 	dropInvalidByteSize = "23QB"
 )
 
+func invalidDurationErr(d string) error {
+	_, err := time.ParseDuration(d)
+	return err
+}
+
 func Test_validateDropConfig(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -342,7 +359,7 @@ func Test_validateDropConfig(t *testing.T) {
 			config: &DropConfig{
 				OlderThan: &dropInvalidDur,
 			},
-			wantErr: fmt.Errorf(ErrDropStageInvalidDuration, dropInvalidDur, "time: unknown unit y in duration 10y"),
+			wantErr: fmt.Errorf(ErrDropStageInvalidDuration, dropInvalidDur, invalidDurationErr(dropInvalidDur)),
 		},
 		{
 			name: "Invalid Config",
@@ -0,0 +1,121 @@
+package stages
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/mitchellh/mapstructure"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	defaultEventLogMessageSource = "message"
+	eventLogMessageDuplicateKey  = "_extracted"
+)
+
+// EventLogMessageConfig contains the configuration for an eventLogMessageStage
+type EventLogMessageConfig struct {
+	Source            *string `mapstructure:"source"`
+	DropInvalidLabels bool    `mapstructure:"drop_invalid_labels"`
+	OverwriteExisting bool    `mapstructure:"overwrite_existing"`
+}
+
+// validateEventLogMessageConfig validates the EventLogMessageConfig for the eventLogMessageStage
+func validateEventLogMessageConfig(cfg *EventLogMessageConfig) error {
+	if cfg.Source == nil || *cfg.Source == "" {
+		source := defaultEventLogMessageSource
+		cfg.Source = &source
+	}
+	return nil
+}
+
+// newEventLogMessageStage creates an eventLogMessageStage from config
+func newEventLogMessageStage(logger log.Logger, config interface{}) (Stage, error) {
+	cfg := &EventLogMessageConfig{}
+	err := mapstructure.Decode(config, cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = validateEventLogMessageConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventLogMessageStage{
+		logger: log.With(logger, "component", "stage", "type", "eventlogmessage"),
+		cfg:    cfg,
+	}, nil
+}
+
+// eventLogMessageStage parses the message of the Windows events, made of `key: value` lines, into the extracted
+// data. The keys are sanitized into valid label names, for the values to be promoted to labels.
+type eventLogMessageStage struct {
+	logger log.Logger
+	cfg    *EventLogMessageConfig
+}
+
+// Process implements Stage
+func (e *eventLogMessageStage) Process(labels model.LabelSet, extracted map[string]interface{}, t *time.Time, entry *string) {
+	v, ok := extracted[*e.cfg.Source]
+	if !ok {
+		if Debug {
+			level.Debug(e.logger).Log("msg", "the source was not found in the extracted map", "source", *e.cfg.Source)
+		}
+		return
+	}
+	message, err := getString(v)
+	if err != nil {
+		if Debug {
+			level.Debug(e.logger).Log("msg", "failed to convert the source value to string", "err", err, "type", reflect.TypeOf(v))
+		}
+		return
+	}
+
+	for _, line := range strings.Split(message, "\n") {
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:idx])
+		value := strings.TrimSpace(line[idx+1:])
+		// The lines without value are the headers of the sections of the message.
+		if key == "" || value == "" {
+			continue
+		}
+		if !model.LabelName(key).IsValid() {
+			if e.cfg.DropInvalidLabels {
+				if Debug {
+					level.Debug(e.logger).Log("msg", "invalid label name parsed from the message", "key", key)
+				}
+				continue
+			}
+			key = sanitizeLabelName(key)
+		}
+		if _, ok := extracted[key]; ok && !e.cfg.OverwriteExisting {
+			key += eventLogMessageDuplicateKey
+		}
+		extracted[key] = value
+	}
+}
+
+// sanitizeLabelName replaces the characters not allowed in label names with underscores, prefixing the names starting
+// with a digit with an underscore.
+func sanitizeLabelName(name string) string {
+	if name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_' || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// Name implements Stage
+func (e *eventLogMessageStage) Name() string {
+	return StageTypeEventLogMessage
+}
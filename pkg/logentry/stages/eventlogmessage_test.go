@@ -0,0 +1,73 @@
+package stages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+var testEventLogMessageYaml = `
+pipeline_stages:
+- json:
+    expressions:
+      message:
+      Account_Name: source
+- eventlogmessage:
+- labels:
+    Logon_Type:
+`
+
+var testEventLogMessageLine = `{"source":"Microsoft-Windows-Security-Auditing","message":"An account was successfully logged on.\r\n\r\nSubject:\r\n\tSecurity ID:\t\tS-1-5-18\r\n\tAccount Name:\t\tDESKTOP$\r\n\r\nLogon Information:\r\n\tLogon Type:\t\t5\r\n\tElevated Token:\t\tYes\r\n\r\n2FA: enabled"}`
+
+func TestEventLogMessageStage(t *testing.T) {
+	pl, err := NewPipeline(util.Logger, loadConfig(testEventLogMessageYaml), nil, prometheus.DefaultRegisterer)
+	require.NoError(t, err)
+
+	lbls := model.LabelSet{}
+	extracted := map[string]interface{}{}
+	ts := time.Now()
+	entry := testEventLogMessageLine
+	pl.Process(lbls, extracted, &ts, &entry)
+
+	require.Equal(t, model.LabelSet{"Logon_Type": "5"}, lbls)
+	require.Contains(t, extracted, "message")
+	delete(extracted, "message")
+	require.Equal(t, map[string]interface{}{
+		"Account_Name":           "Microsoft-Windows-Security-Auditing",
+		"Security_ID":            "S-1-5-18",
+		"Account_Name_extracted": "DESKTOP$",
+		"Logon_Type":             "5",
+		"Elevated_Token":         "Yes",
+		"_2FA":                   "enabled",
+	}, extracted)
+}
+
+func TestEventLogMessageStage_Options(t *testing.T) {
+	stage, err := newEventLogMessageStage(util.Logger, map[string]interface{}{
+		"source":              "msg",
+		"drop_invalid_labels": true,
+		"overwrite_existing":  true,
+	})
+	require.NoError(t, err)
+
+	extracted := map[string]interface{}{
+		"msg":      "Process ID: 4\nProcess Name: C:\\Windows\\System32\\lsass.exe\nStatus: 0x0",
+		"Status":   "previous",
+		"Process":  "other",
+		"ignoring": "untouched",
+	}
+	ts := time.Now()
+	entry := "line"
+	stage.Process(model.LabelSet{}, extracted, &ts, &entry)
+	delete(extracted, "msg")
+	// the names with spaces are dropped rather than sanitized, the existing values are overwritten
+	require.Equal(t, map[string]interface{}{
+		"Status":   "0x0",
+		"Process":  "other",
+		"ignoring": "untouched",
+	}, extracted)
+}
@@ -1,8 +1,19 @@
 package stages
 
 import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+
+	"github.com/famarks/loki/pkg/promtail/api"
 )
 
 const RFC3339Nano = "RFC3339Nano"
@@ -35,8 +46,45 @@ func NewDocker(logger log.Logger, registerer prometheus.Registerer) (Stage, erro
 	return NewPipeline(logger, stages, nil, registerer)
 }
 
+const (
+	ErrCRIStageInvalidMaxPartialLines    = "cri stage max_partial_lines must not be negative"
+	ErrCRIStageInvalidMaxPartialLineSize = "cri stage max_partial_line_size must not be negative"
+
+	defaultCRIMaxPartialLines = 100
+)
+
+// CRIConfig contains the configuration for a criStage
+type CRIConfig struct {
+	MaxPartialLines    int `mapstructure:"max_partial_lines"`
+	MaxPartialLineSize int `mapstructure:"max_partial_line_size"`
+}
+
+// validateCRIConfig validates the CRIConfig for the criStage
+func validateCRIConfig(cfg *CRIConfig) error {
+	if cfg.MaxPartialLines < 0 {
+		return errors.New(ErrCRIStageInvalidMaxPartialLines)
+	}
+	if cfg.MaxPartialLineSize < 0 {
+		return errors.New(ErrCRIStageInvalidMaxPartialLineSize)
+	}
+	if cfg.MaxPartialLines == 0 {
+		cfg.MaxPartialLines = defaultCRIMaxPartialLines
+	}
+	return nil
+}
+
 // NewCRI creates a CRI format specific pipeline stage
-func NewCRI(logger log.Logger, registerer prometheus.Registerer) (Stage, error) {
+func NewCRI(logger log.Logger, config interface{}, registerer prometheus.Registerer) (Stage, error) {
+	cfg := &CRIConfig{}
+	err := mapstructure.Decode(config, cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = validateCRIConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	stages := PipelineStages{
 		PipelineStage{
 			StageTypeRegex: RegexConfig{
@@ -60,5 +108,101 @@ func NewCRI(logger log.Logger, registerer prometheus.Registerer) (Stage, error)
 			},
 		},
 	}
-	return NewPipeline(logger, stages, nil, registerer)
+	base, err := NewPipeline(logger, stages, nil, registerer)
+	if err != nil {
+		return nil, err
+	}
+	return &criStage{
+		logger: log.With(logger, "component", "stage", "type", "cri"),
+		cfg:    cfg,
+		base:   base,
+	}, nil
+}
+
+// criStage parses the lines of the CRI log format. The runtimes split the long lines into partial lines, flagged with
+// P, up to the full line flagged with F: the partial lines are joined back when the stage wraps the handler of the
+// stages following it, see wrap.
+type criStage struct {
+	logger log.Logger
+	cfg    *CRIConfig
+	base   *Pipeline
+}
+
+// Process implements Stage
+func (c *criStage) Process(labels model.LabelSet, extracted map[string]interface{}, t *time.Time, entry *string) {
+	c.base.Process(labels, extracted, t, entry)
+}
+
+// Name implements Stage
+func (c *criStage) Name() string {
+	return StageTypeCRI
+}
+
+// criPartialLine is the partial line of a stream being joined, with the labels and timestamp of its first part.
+type criPartialLine struct {
+	labels model.LabelSet
+	time   time.Time
+	parts  []string
+	size   int
+}
+
+// wrap returns the handler parsing the lines and joining the partial lines of each stream, sending them to next once
+// the full line is read, once max_partial_lines are joined or once the line reaches max_partial_line_size.
+func (c *criStage) wrap(next api.EntryHandler) (api.EntryHandler, func() error) {
+	var (
+		mtx      sync.Mutex
+		partials = map[model.Fingerprint]*criPartialLine{}
+	)
+
+	handler := api.EntryHandlerFunc(func(labels model.LabelSet, t time.Time, line string) error {
+		extracted := map[string]interface{}{}
+		c.base.Process(labels, extracted, &t, &line)
+		flags, _ := extracted["flags"].(string)
+		key := labels.Fingerprint()
+
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		partial, ok := partials[key]
+		if !ok {
+			if !strings.Contains(flags, "P") {
+				return next.Handle(labels, t, line)
+			}
+			partial = &criPartialLine{labels: labels.Clone(), time: t}
+			partials[key] = partial
+		}
+		partial.parts = append(partial.parts, line)
+		partial.size += len(line)
+
+		if !strings.Contains(flags, "P") || len(partial.parts) >= c.cfg.MaxPartialLines ||
+			(c.cfg.MaxPartialLineSize > 0 && partial.size >= c.cfg.MaxPartialLineSize) {
+			delete(partials, key)
+			if strings.Contains(flags, "P") {
+				level.Warn(c.logger).Log("msg", "sending a partial line before its end, the limits of the partial lines were reached", "labels", partial.labels, "lines", len(partial.parts), "size", partial.size)
+			}
+			return next.Handle(partial.labels, partial.time, strings.Join(partial.parts, ""))
+		}
+		return nil
+	})
+
+	flush := func() error {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		pending := make([]model.Fingerprint, 0, len(partials))
+		for key := range partials {
+			pending = append(pending, key)
+		}
+		sort.Slice(pending, func(i, j int) bool { return partials[pending[i]].time.Before(partials[pending[j]].time) })
+		var lastErr error
+		for _, key := range pending {
+			partial := partials[key]
+			delete(partials, key)
+			if err := next.Handle(partial.labels, partial.time, strings.Join(partial.parts, "")); err != nil {
+				lastErr = err
+			}
+		}
+		return lastErr
+	}
+	return handler, flush
 }
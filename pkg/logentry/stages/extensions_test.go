@@ -6,7 +6,9 @@ import (
 
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -141,7 +143,7 @@ func TestNewCri(t *testing.T) {
 		tt := tt
 		t.Run(tName, func(t *testing.T) {
 			t.Parallel()
-			p, err := NewCRI(util.Logger, prometheus.DefaultRegisterer)
+			p, err := NewCRI(util.Logger, nil, prometheus.DefaultRegisterer)
 			if err != nil {
 				t.Fatalf("failed to create CRI parser: %s", err)
 			}
@@ -158,3 +160,81 @@ func TestNewCri(t *testing.T) {
 	}
 
 }
+
+var testCRIPartialYaml = `
+pipeline_stages:
+- cri:
+    max_partial_lines: 3
+- regex:
+    expression: "^(?P<level>\\w+) "
+- labels:
+    level:
+`
+
+func TestCRI_PartialLines(t *testing.T) {
+	pl, err := NewPipeline(util.Logger, loadConfig(testCRIPartialYaml), nil, prometheus.DefaultRegisterer)
+	require.NoError(t, err)
+	out := &multilineEntries{}
+	handler := pl.Wrap(out)
+
+	lbls := model.LabelSet{"filename": "a.log"}
+	for _, line := range []string{
+		criTestTimeStr + " stdout P info first ",
+		criTestTimeStr + " stderr F error other stream",
+		"2019-01-01T01:00:01.000000001Z stdout P part, ",
+		"2019-01-01T01:00:02.000000001Z stdout F last part",
+		"2019-01-01T01:00:03.000000001Z stdout F debug full line",
+		// max_partial_lines are joined, the next part starting a line
+		"2019-01-01T01:00:04.000000001Z stdout P warn a",
+		"2019-01-01T01:00:05.000000001Z stdout P b",
+		"2019-01-01T01:00:06.000000001Z stdout P c",
+		"2019-01-01T01:00:07.000000001Z stdout F info d",
+	} {
+		require.NoError(t, handler.Handle(lbls.Clone(), time.Now(), line))
+	}
+
+	ts := func(s string) time.Time {
+		ts, err := time.Parse(time.RFC3339Nano, s)
+		require.NoError(t, err)
+		return ts
+	}
+	require.Equal(t, []multilineEntry{
+		{model.LabelSet{"filename": "a.log", "stream": "stderr", "level": "error"}, criTestTime, "error other stream"},
+		{model.LabelSet{"filename": "a.log", "stream": "stdout", "level": "info"}, criTestTime, "info first part, last part"},
+		{model.LabelSet{"filename": "a.log", "stream": "stdout", "level": "debug"}, ts("2019-01-01T01:00:03.000000001Z"), "debug full line"},
+		{model.LabelSet{"filename": "a.log", "stream": "stdout", "level": "warn"}, ts("2019-01-01T01:00:04.000000001Z"), "warn abc"},
+		{model.LabelSet{"filename": "a.log", "stream": "stdout", "level": "info"}, ts("2019-01-01T01:00:07.000000001Z"), "info d"},
+	}, out.get())
+}
+
+func TestCRI_MaxPartialLineSize(t *testing.T) {
+	stage, err := NewCRI(util.Logger, map[string]interface{}{"max_partial_line_size": 8}, prometheus.DefaultRegisterer)
+	require.NoError(t, err)
+	out := &multilineEntries{}
+	handler, flush := stage.(*criStage).wrap(out)
+
+	for _, line := range []string{
+		criTestTimeStr + " stdout P 0123",
+		criTestTimeStr + " stdout P 4567",
+		criTestTimeStr + " stdout F 89",
+		criTestTimeStr + " stdout P ab",
+	} {
+		require.NoError(t, handler.Handle(model.LabelSet{}, time.Now(), line))
+	}
+	require.Equal(t, []multilineEntry{
+		{model.LabelSet{"stream": "stdout"}, criTestTime, "01234567"},
+		{model.LabelSet{"stream": "stdout"}, criTestTime, "89"},
+	}, out.get())
+
+	// the partial lines held back are sent when flushed
+	require.NoError(t, flush())
+	require.Equal(t, multilineEntry{model.LabelSet{"stream": "stdout"}, criTestTime, "ab"}, out.get()[2])
+}
+
+func TestCRI_Validation(t *testing.T) {
+	_, err := NewCRI(util.Logger, map[string]interface{}{"max_partial_lines": -1}, prometheus.DefaultRegisterer)
+	require.EqualError(t, err, ErrCRIStageInvalidMaxPartialLines)
+
+	_, err = NewCRI(util.Logger, map[string]interface{}{"max_partial_line_size": -1}, prometheus.DefaultRegisterer)
+	require.EqualError(t, err, ErrCRIStageInvalidMaxPartialLineSize)
+}
@@ -0,0 +1,245 @@
+package stages
+
+import (
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/mitchellh/mapstructure"
+	"github.com/oschwald/geoip2-golang"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	ErrEmptyGeoIPStageConfig   = "geoip stage config cannot be empty"
+	ErrGeoIPStageEmptyDB       = "geoip stage db path must be set"
+	ErrGeoIPStageEmptySource   = "geoip stage source must be set"
+	ErrGeoIPStageInvalidDBType = "geoip stage db_type must be one of city, country or asn: %v"
+	ErrGeoIPStageOpenDB        = "geoip stage failed to open the database"
+
+	GeoIPDBTypeCity    = "city"
+	GeoIPDBTypeCountry = "country"
+	GeoIPDBTypeASN     = "asn"
+
+	geoIPLanguage       = "en"
+	geoIPReloadInterval = 10 * time.Second
+)
+
+// GeoIPConfig contains the configuration for a geoIPStage
+type GeoIPConfig struct {
+	DB     string  `mapstructure:"db"`
+	Source *string `mapstructure:"source"`
+	DBType string  `mapstructure:"db_type"`
+}
+
+// validateGeoIPConfig validates the GeoIPConfig for the geoIPStage
+func validateGeoIPConfig(cfg *GeoIPConfig) error {
+	if cfg == nil {
+		return errors.New(ErrEmptyGeoIPStageConfig)
+	}
+	if cfg.DB == "" {
+		return errors.New(ErrGeoIPStageEmptyDB)
+	}
+	if cfg.Source == nil || *cfg.Source == "" {
+		return errors.New(ErrGeoIPStageEmptySource)
+	}
+	switch cfg.DBType {
+	case "":
+		cfg.DBType = GeoIPDBTypeCity
+	case GeoIPDBTypeCity, GeoIPDBTypeCountry, GeoIPDBTypeASN:
+	default:
+		return errors.Errorf(ErrGeoIPStageInvalidDBType, cfg.DBType)
+	}
+	return nil
+}
+
+// newGeoIPStage creates a geoIPStage from config
+func newGeoIPStage(logger log.Logger, config interface{}) (Stage, error) {
+	cfg := &GeoIPConfig{}
+	err := mapstructure.Decode(config, cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = validateGeoIPConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &geoIPStage{
+		logger: log.With(logger, "component", "stage", "type", "geoip"),
+		cfg:    cfg,
+	}
+	fi, err := os.Stat(cfg.DB)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGeoIPStageOpenDB)
+	}
+	g.reader, err = openGeoIPDB(cfg.DB, cfg.DBType)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrGeoIPStageOpenDB)
+	}
+	g.modTime = fi.ModTime()
+	g.nextCheck = time.Now().Add(geoIPReloadInterval).UnixNano()
+	return g, nil
+}
+
+// openGeoIPDB opens the database, making sure that it supports the lookups of the db_type.
+func openGeoIPDB(path, dbType string) (*geoip2.Reader, error) {
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	switch dbType {
+	case GeoIPDBTypeCity:
+		_, err = reader.City(net.IPv4zero)
+	case GeoIPDBTypeCountry:
+		_, err = reader.Country(net.IPv4zero)
+	case GeoIPDBTypeASN:
+		_, err = reader.ASN(net.IPv4zero)
+	}
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return reader, nil
+}
+
+// geoIPStage looks up the IP address of the source in a MaxMind database, adding the location or the autonomous
+// system of the address to the extracted data. The database is reopened when the file changes, for it to be updated
+// without restarting promtail.
+type geoIPStage struct {
+	logger log.Logger
+	cfg    *GeoIPConfig
+
+	nextCheck int64 // UnixNano, accessed atomically.
+
+	mtx     sync.RWMutex
+	reader  *geoip2.Reader
+	modTime time.Time
+}
+
+// Process implements Stage
+func (g *geoIPStage) Process(labels model.LabelSet, extracted map[string]interface{}, t *time.Time, entry *string) {
+	v, ok := extracted[*g.cfg.Source]
+	if !ok {
+		if Debug {
+			level.Debug(g.logger).Log("msg", "the source was not found in the extracted map", "source", *g.cfg.Source)
+		}
+		return
+	}
+	s, err := getString(v)
+	if err != nil {
+		if Debug {
+			level.Debug(g.logger).Log("msg", "failed to convert the source value to string", "err", err, "type", reflect.TypeOf(v))
+		}
+		return
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		if Debug {
+			level.Debug(g.logger).Log("msg", "the source value is not an IP address", "source", *g.cfg.Source, "value", s)
+		}
+		return
+	}
+
+	g.reloadIfChanged()
+
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+	switch g.cfg.DBType {
+	case GeoIPDBTypeCity:
+		record, err := g.reader.City(ip)
+		if err != nil {
+			level.Error(g.logger).Log("msg", "failed to look up the IP address", "ip", s, "err", err)
+			return
+		}
+		setExtracted(extracted, "geoip_city_name", record.City.Names[geoIPLanguage])
+		setExtracted(extracted, "geoip_country_name", record.Country.Names[geoIPLanguage])
+		setExtracted(extracted, "geoip_country_code", record.Country.IsoCode)
+		setExtracted(extracted, "geoip_continent_name", record.Continent.Names[geoIPLanguage])
+		setExtracted(extracted, "geoip_continent_code", record.Continent.Code)
+		setExtracted(extracted, "geoip_postal_code", record.Postal.Code)
+		setExtracted(extracted, "geoip_timezone", record.Location.TimeZone)
+		if record.Location.Latitude != 0 || record.Location.Longitude != 0 {
+			extracted["geoip_location_latitude"] = record.Location.Latitude
+			extracted["geoip_location_longitude"] = record.Location.Longitude
+		}
+		if len(record.Subdivisions) > 0 {
+			setExtracted(extracted, "geoip_subdivision_name", record.Subdivisions[0].Names[geoIPLanguage])
+			setExtracted(extracted, "geoip_subdivision_code", record.Subdivisions[0].IsoCode)
+		}
+	case GeoIPDBTypeCountry:
+		record, err := g.reader.Country(ip)
+		if err != nil {
+			level.Error(g.logger).Log("msg", "failed to look up the IP address", "ip", s, "err", err)
+			return
+		}
+		setExtracted(extracted, "geoip_country_name", record.Country.Names[geoIPLanguage])
+		setExtracted(extracted, "geoip_country_code", record.Country.IsoCode)
+		setExtracted(extracted, "geoip_continent_name", record.Continent.Names[geoIPLanguage])
+		setExtracted(extracted, "geoip_continent_code", record.Continent.Code)
+	case GeoIPDBTypeASN:
+		record, err := g.reader.ASN(ip)
+		if err != nil {
+			level.Error(g.logger).Log("msg", "failed to look up the IP address", "ip", s, "err", err)
+			return
+		}
+		if record.AutonomousSystemNumber != 0 {
+			extracted["geoip_autonomous_system_number"] = strconv.FormatUint(uint64(record.AutonomousSystemNumber), 10)
+		}
+		setExtracted(extracted, "geoip_autonomous_system_organization", record.AutonomousSystemOrganization)
+	}
+}
+
+// setExtracted sets the value in the extracted data, unless it is empty.
+func setExtracted(extracted map[string]interface{}, key, value string) {
+	if value != "" {
+		extracted[key] = value
+	}
+}
+
+// reloadIfChanged reopens the database when the modification time of the file changed, at most every
+// geoIPReloadInterval. The previous database is kept when the new one cannot be opened.
+func (g *geoIPStage) reloadIfChanged() {
+	now := time.Now()
+	next := atomic.LoadInt64(&g.nextCheck)
+	if now.UnixNano() < next || !atomic.CompareAndSwapInt64(&g.nextCheck, next, now.Add(geoIPReloadInterval).UnixNano()) {
+		return
+	}
+
+	fi, err := os.Stat(g.cfg.DB)
+	if err != nil {
+		level.Warn(g.logger).Log("msg", "failed to stat the database, keeping the current one", "db", g.cfg.DB, "err", err)
+		return
+	}
+	g.mtx.RLock()
+	changed := !fi.ModTime().Equal(g.modTime)
+	g.mtx.RUnlock()
+	if !changed {
+		return
+	}
+
+	reader, err := openGeoIPDB(g.cfg.DB, g.cfg.DBType)
+	if err != nil {
+		level.Warn(g.logger).Log("msg", "failed to reload the database, keeping the current one", "db", g.cfg.DB, "err", err)
+		return
+	}
+	g.mtx.Lock()
+	previous := g.reader
+	g.reader = reader
+	g.modTime = fi.ModTime()
+	g.mtx.Unlock()
+	previous.Close()
+	level.Info(g.logger).Log("msg", "reloaded the database", "db", g.cfg.DB)
+}
+
+// Name implements Stage
+func (g *geoIPStage) Name() string {
+	return StageTypeGeoIP
+}
@@ -0,0 +1,310 @@
+package stages
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+var testGeoIPYaml = `
+pipeline_stages:
+- regex:
+    expression: "^(?P<ip>\\S+) "
+- geoip:
+    db: %s
+    source: ip
+    db_type: country
+- labels:
+    geoip_country_code:
+`
+
+// writeMMDB writes an IPv4 MaxMind database of the type, mapping each network to its record.
+func writeMMDB(t *testing.T, path, dbType string, records map[string]map[string]interface{}) {
+	t.Helper()
+
+	var data bytes.Buffer
+	type record struct {
+		ip     net.IP
+		ones   int
+		offset int
+	}
+	var networks []record
+	for cidr, r := range records {
+		_, network, err := net.ParseCIDR(cidr)
+		require.NoError(t, err)
+		ones, _ := network.Mask.Size()
+		networks = append(networks, record{ip: network.IP.To4(), ones: ones, offset: data.Len()})
+		encodeMMDB(&data, r)
+	}
+
+	// Records are either a node, empty (-1) or data (-2 - offset) until the node count is known.
+	nodes := [][2]int{{-1, -1}}
+	for _, r := range networks {
+		n := 0
+		for i := 0; i < r.ones; i++ {
+			bit := (r.ip[i/8] >> (7 - uint(i%8))) & 1
+			if i == r.ones-1 {
+				nodes[n][bit] = -2 - r.offset
+				break
+			}
+			if nodes[n][bit] < 0 {
+				nodes = append(nodes, [2]int{-1, -1})
+				nodes[n][bit] = len(nodes) - 1
+			}
+			n = nodes[n][bit]
+		}
+	}
+
+	var db bytes.Buffer
+	count := len(nodes)
+	for _, node := range nodes {
+		for _, v := range node {
+			switch {
+			case v == -1:
+				v = count
+			case v < -1:
+				v = count + 16 + (-2 - v)
+			}
+			db.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+	db.Write(make([]byte, 16))
+	db.Write(data.Bytes())
+	db.WriteString("\xAB\xCD\xEFMaxMind.com")
+	encodeMMDB(&db, map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(time.Now().Unix()),
+		"database_type":               dbType,
+		"description":                 map[string]interface{}{"en": "test"},
+		"ip_version":                  uint16(4),
+		"languages":                   []interface{}{"en"},
+		"node_count":                  uint32(count),
+		"record_size":                 uint16(24),
+	})
+	require.NoError(t, ioutil.WriteFile(path, db.Bytes(), 0644))
+}
+
+// encodeMMDB encodes the value in the data section format of the MaxMind databases.
+func encodeMMDB(buf *bytes.Buffer, value interface{}) {
+	control := func(typ, size int) {
+		ctrl := typ
+		if typ > 7 {
+			ctrl = 0
+		}
+		var extra []byte
+		switch {
+		case size < 29:
+			buf.WriteByte(byte(ctrl<<5 | size))
+		case size < 285:
+			buf.WriteByte(byte(ctrl<<5 | 29))
+			extra = []byte{byte(size - 29)}
+		default:
+			buf.WriteByte(byte(ctrl<<5 | 30))
+			extra = []byte{byte((size - 285) >> 8), byte(size - 285)}
+		}
+		if typ > 7 {
+			buf.WriteByte(byte(typ - 7))
+		}
+		buf.Write(extra)
+	}
+	switch v := value.(type) {
+	case string:
+		control(2, len(v))
+		buf.WriteString(v)
+	case float64:
+		control(3, 8)
+		_ = binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case uint16:
+		control(5, 2)
+		_ = binary.Write(buf, binary.BigEndian, v)
+	case uint32:
+		control(6, 4)
+		_ = binary.Write(buf, binary.BigEndian, v)
+	case uint64:
+		control(9, 8)
+		_ = binary.Write(buf, binary.BigEndian, v)
+	case map[string]interface{}:
+		control(7, len(v))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			encodeMMDB(buf, k)
+			encodeMMDB(buf, v[k])
+		}
+	case []interface{}:
+		control(11, len(v))
+		for _, e := range v {
+			encodeMMDB(buf, e)
+		}
+	default:
+		panic("unsupported type")
+	}
+}
+
+var testGeoIPCity = map[string]interface{}{
+	"city":      map[string]interface{}{"names": map[string]interface{}{"en": "London"}},
+	"continent": map[string]interface{}{"code": "EU", "names": map[string]interface{}{"en": "Europe"}},
+	"country":   map[string]interface{}{"iso_code": "GB", "names": map[string]interface{}{"en": "United Kingdom"}},
+	"location":  map[string]interface{}{"latitude": 51.5142, "longitude": -0.0931, "time_zone": "Europe/London"},
+	"subdivisions": []interface{}{
+		map[string]interface{}{"iso_code": "ENG", "names": map[string]interface{}{"en": "England"}},
+	},
+}
+
+func TestGeoIPStage_City(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db := filepath.Join(dir, "city.mmdb")
+	writeMMDB(t, db, "GeoLite2-City", map[string]map[string]interface{}{"81.2.69.0/24": testGeoIPCity})
+
+	stage, err := newGeoIPStage(util.Logger, map[string]interface{}{"db": db, "source": "ip"})
+	require.NoError(t, err)
+
+	extracted := map[string]interface{}{"ip": "81.2.69.142"}
+	ts := time.Now()
+	entry := "line"
+	stage.Process(model.LabelSet{}, extracted, &ts, &entry)
+	require.Equal(t, map[string]interface{}{
+		"ip":                       "81.2.69.142",
+		"geoip_city_name":          "London",
+		"geoip_country_name":       "United Kingdom",
+		"geoip_country_code":       "GB",
+		"geoip_continent_name":     "Europe",
+		"geoip_continent_code":     "EU",
+		"geoip_timezone":           "Europe/London",
+		"geoip_location_latitude":  51.5142,
+		"geoip_location_longitude": -0.0931,
+		"geoip_subdivision_name":   "England",
+		"geoip_subdivision_code":   "ENG",
+	}, extracted)
+
+	// the addresses outside of the database and the invalid addresses are not enriched
+	for _, ip := range []string{"10.0.0.1", "not an ip"} {
+		extracted = map[string]interface{}{"ip": ip}
+		stage.Process(model.LabelSet{}, extracted, &ts, &entry)
+		require.Equal(t, map[string]interface{}{"ip": ip}, extracted)
+	}
+}
+
+func TestGeoIPStage_Pipeline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db := filepath.Join(dir, "country.mmdb")
+	writeMMDB(t, db, "GeoLite2-Country", map[string]map[string]interface{}{"81.2.69.0/24": testGeoIPCity})
+
+	pl, err := NewPipeline(util.Logger, loadConfig(fmt.Sprintf(testGeoIPYaml, db)), nil, prometheus.DefaultRegisterer)
+	require.NoError(t, err)
+
+	lbls := model.LabelSet{}
+	ts := time.Now()
+	entry := "81.2.69.142 GET /loki/api/v1/push"
+	pl.Process(lbls, map[string]interface{}{}, &ts, &entry)
+	require.Equal(t, model.LabelSet{"geoip_country_code": "GB"}, lbls)
+}
+
+func TestGeoIPStage_ASN(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db := filepath.Join(dir, "asn.mmdb")
+	writeMMDB(t, db, "GeoLite2-ASN", map[string]map[string]interface{}{
+		"1.128.0.0/11": {"autonomous_system_number": uint32(1221), "autonomous_system_organization": "Telstra Pty Ltd"},
+	})
+
+	stage, err := newGeoIPStage(util.Logger, map[string]interface{}{"db": db, "source": "ip", "db_type": "asn"})
+	require.NoError(t, err)
+
+	extracted := map[string]interface{}{"ip": "1.128.0.1"}
+	ts := time.Now()
+	entry := "line"
+	stage.Process(model.LabelSet{}, extracted, &ts, &entry)
+	require.Equal(t, map[string]interface{}{
+		"ip":                                   "1.128.0.1",
+		"geoip_autonomous_system_number":       "1221",
+		"geoip_autonomous_system_organization": "Telstra Pty Ltd",
+	}, extracted)
+}
+
+func TestGeoIPStage_Reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db := filepath.Join(dir, "asn.mmdb")
+	writeMMDB(t, db, "GeoLite2-ASN", map[string]map[string]interface{}{
+		"1.128.0.0/11": {"autonomous_system_number": uint32(1221)},
+	})
+
+	stage, err := newGeoIPStage(util.Logger, map[string]interface{}{"db": db, "source": "ip", "db_type": "asn"})
+	require.NoError(t, err)
+	g := stage.(*geoIPStage)
+
+	// the database is memory mapped, it is replaced rather than written in place like the updaters do
+	updated := filepath.Join(dir, "asn.mmdb.tmp")
+	writeMMDB(t, updated, "GeoLite2-ASN", map[string]map[string]interface{}{
+		"1.128.0.0/11": {"autonomous_system_number": uint32(1222)},
+	})
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(updated, later, later))
+	require.NoError(t, os.Rename(updated, db))
+
+	ts := time.Now()
+	entry := "line"
+	extracted := map[string]interface{}{"ip": "1.128.0.1"}
+	stage.Process(model.LabelSet{}, extracted, &ts, &entry)
+	// the file is not checked before the reload interval
+	require.Equal(t, "1221", extracted["geoip_autonomous_system_number"])
+
+	g.nextCheck = 0
+	stage.Process(model.LabelSet{}, extracted, &ts, &entry)
+	require.Equal(t, "1222", extracted["geoip_autonomous_system_number"])
+
+	// an invalid database is not reloaded
+	require.NoError(t, ioutil.WriteFile(updated, []byte("invalid"), 0644))
+	require.NoError(t, os.Rename(updated, db))
+	g.nextCheck = 0
+	stage.Process(model.LabelSet{}, extracted, &ts, &entry)
+	require.Equal(t, "1222", extracted["geoip_autonomous_system_number"])
+}
+
+func TestGeoIPStage_Validation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db := filepath.Join(dir, "asn.mmdb")
+	writeMMDB(t, db, "GeoLite2-ASN", map[string]map[string]interface{}{
+		"1.128.0.0/11": {"autonomous_system_number": uint32(1221)},
+	})
+
+	for _, tc := range []struct {
+		config map[string]interface{}
+		err    string
+	}{
+		{map[string]interface{}{"source": "ip"}, ErrGeoIPStageEmptyDB},
+		{map[string]interface{}{"db": db}, ErrGeoIPStageEmptySource},
+		{map[string]interface{}{"db": db, "source": "ip", "db_type": "isp"}, fmt.Sprintf(ErrGeoIPStageInvalidDBType, "isp")},
+		{map[string]interface{}{"db": filepath.Join(dir, "missing.mmdb"), "source": "ip"}, ErrGeoIPStageOpenDB + ": stat " + filepath.Join(dir, "missing.mmdb") + ": no such file or directory"},
+		{map[string]interface{}{"db": db, "source": "ip"}, ErrGeoIPStageOpenDB + ": geoip2: the City method does not support the GeoLite2-ASN database"},
+	} {
+		_, err := newGeoIPStage(util.Logger, tc.config)
+		require.EqualError(t, err, tc.err)
+	}
+}
@@ -0,0 +1,118 @@
+package stages
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"golang.org/x/time/rate"
+)
+
+const (
+	ErrLimitStageInvalidRateOrBurst = "limit stage rate and burst must be greater than zero"
+
+	limitDropReason               = "limit_stage"
+	defaultLimitMaxDistinctLabels = 10000
+)
+
+// LimitConfig contains the configuration for a limitStage
+type LimitConfig struct {
+	Rate              float64 `mapstructure:"rate"`
+	Burst             int     `mapstructure:"burst"`
+	Drop              bool    `mapstructure:"drop"`
+	ByLabelName       string  `mapstructure:"by_label_name"`
+	MaxDistinctLabels int     `mapstructure:"max_distinct_labels"`
+}
+
+// validateLimitConfig validates the LimitConfig for the limitStage
+func validateLimitConfig(cfg *LimitConfig) error {
+	if cfg.Rate <= 0 || cfg.Burst <= 0 {
+		return errors.New(ErrLimitStageInvalidRateOrBurst)
+	}
+	if cfg.MaxDistinctLabels <= 0 {
+		cfg.MaxDistinctLabels = defaultLimitMaxDistinctLabels
+	}
+	return nil
+}
+
+// newLimitStage creates a limitStage from config
+func newLimitStage(logger log.Logger, config interface{}) (Stage, error) {
+	cfg := &LimitConfig{}
+	err := mapstructure.WeakDecode(config, cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = validateLimitConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &limitStage{
+		logger:   log.With(logger, "component", "stage", "type", "limit"),
+		cfg:      cfg,
+		limiter:  rate.NewLimiter(rate.Limit(cfg.Rate), cfg.Burst),
+		limiters: map[model.LabelValue]*rate.Limiter{},
+	}, nil
+}
+
+// limitStage limits the rate of the entries, either dropping the entries over the rate or waiting for the rate to
+// allow them, which applies backpressure to the target. When a label name is set, the entries are limited by value of
+// the label, the entries without the label not being limited.
+type limitStage struct {
+	logger  log.Logger
+	cfg     *LimitConfig
+	limiter *rate.Limiter
+
+	mtx      sync.Mutex
+	limiters map[model.LabelValue]*rate.Limiter
+}
+
+// Process implements Stage
+func (l *limitStage) Process(labels model.LabelSet, extracted map[string]interface{}, t *time.Time, entry *string) {
+	limiter := l.limiter
+	if l.cfg.ByLabelName != "" {
+		value, ok := labels[model.LabelName(l.cfg.ByLabelName)]
+		if !ok {
+			return
+		}
+		limiter = l.labelLimiter(value)
+	}
+
+	if l.cfg.Drop {
+		if !limiter.Allow() {
+			labels[dropLabel] = limitDropReason
+		}
+		return
+	}
+	if err := limiter.Wait(context.Background()); err != nil {
+		level.Error(l.logger).Log("msg", "failed to wait for the rate limit", "err", err)
+	}
+}
+
+// labelLimiter returns the limiter of the value of the label, the limiters being reset when there are more than
+// max_distinct_labels of them.
+func (l *limitStage) labelLimiter(value model.LabelValue) *rate.Limiter {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	limiter, ok := l.limiters[value]
+	if !ok {
+		if len(l.limiters) >= l.cfg.MaxDistinctLabels {
+			level.Warn(l.logger).Log("msg", "too many distinct label values, resetting the rate limiters", "label", l.cfg.ByLabelName, "max_distinct_labels", l.cfg.MaxDistinctLabels)
+			l.limiters = map[model.LabelValue]*rate.Limiter{}
+		}
+		limiter = rate.NewLimiter(rate.Limit(l.cfg.Rate), l.cfg.Burst)
+		l.limiters[value] = limiter
+	}
+	return limiter
+}
+
+// Name implements Stage
+func (l *limitStage) Name() string {
+	return StageTypeLimit
+}
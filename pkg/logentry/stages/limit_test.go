@@ -0,0 +1,102 @@
+package stages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+var testLimitYaml = `
+pipeline_stages:
+- limit:
+    rate: 1
+    burst: 2
+    drop: true
+`
+
+var testLimitByLabelYaml = `
+pipeline_stages:
+- limit:
+    rate: 1
+    burst: 1
+    drop: true
+    by_label_name: app
+`
+
+func processLimited(t *testing.T, pl *Pipeline, lbls model.LabelSet) bool {
+	t.Helper()
+	ts := time.Now()
+	entry := "line"
+	pl.Process(lbls, map[string]interface{}{}, &ts, &entry)
+	_, dropped := lbls[dropLabel]
+	return dropped
+}
+
+func TestLimitStage_Drop(t *testing.T) {
+	pl, err := NewPipeline(util.Logger, loadConfig(testLimitYaml), nil, prometheus.DefaultRegisterer)
+	require.NoError(t, err)
+
+	require.False(t, processLimited(t, pl, model.LabelSet{"app": "loki"}))
+	require.False(t, processLimited(t, pl, model.LabelSet{"app": "loki"}))
+	lbls := model.LabelSet{"app": "promtail"}
+	require.True(t, processLimited(t, pl, lbls))
+	require.Equal(t, model.LabelValue(limitDropReason), lbls[dropLabel])
+}
+
+func TestLimitStage_ByLabelName(t *testing.T) {
+	pl, err := NewPipeline(util.Logger, loadConfig(testLimitByLabelYaml), nil, prometheus.DefaultRegisterer)
+	require.NoError(t, err)
+
+	require.False(t, processLimited(t, pl, model.LabelSet{"app": "loki"}))
+	require.True(t, processLimited(t, pl, model.LabelSet{"app": "loki"}))
+	// the other values of the label are limited separately
+	require.False(t, processLimited(t, pl, model.LabelSet{"app": "promtail"}))
+	// the entries without the label are not limited
+	require.False(t, processLimited(t, pl, model.LabelSet{}))
+	require.False(t, processLimited(t, pl, model.LabelSet{}))
+}
+
+func TestLimitStage_MaxDistinctLabels(t *testing.T) {
+	stage, err := newLimitStage(util.Logger, map[string]interface{}{
+		"rate": 1, "burst": 1, "drop": true, "by_label_name": "app", "max_distinct_labels": 2,
+	})
+	require.NoError(t, err)
+	l := stage.(*limitStage)
+
+	for _, app := range []model.LabelValue{"a", "b", "c"} {
+		l.labelLimiter(app)
+	}
+	require.Len(t, l.limiters, 1)
+}
+
+func TestLimitStage_Wait(t *testing.T) {
+	stage, err := newLimitStage(util.Logger, map[string]interface{}{"rate": 20, "burst": 1})
+	require.NoError(t, err)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		lbls := model.LabelSet{}
+		ts := time.Now()
+		entry := "line"
+		stage.Process(lbls, map[string]interface{}{}, &ts, &entry)
+		require.Equal(t, model.LabelSet{}, lbls)
+	}
+	// the entries over the burst wait for the rate to allow them
+	require.True(t, time.Since(start) >= 90*time.Millisecond)
+}
+
+func TestLimitStage_Validation(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{},
+		{"rate": 10},
+		{"burst": 10},
+		{"rate": -1, "burst": 10},
+	} {
+		_, err := newLimitStage(util.Logger, config)
+		require.EqualError(t, err, ErrLimitStageInvalidRateOrBurst)
+	}
+}
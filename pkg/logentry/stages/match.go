@@ -94,6 +94,11 @@ func newMatcherStage(logger log.Logger, jobName *string, config interface{}, reg
 		if err != nil {
 			return nil, errors.Wrapf(err, "match stage failed to create pipeline from config: %v", config)
 		}
+		for _, stage := range pl.stages {
+			if _, ok := stage.(*multilineStage); ok {
+				return nil, errors.New(ErrMultilineStageInMatch)
+			}
+		}
 	}
 
 	pipeline, err := selector.Pipeline()
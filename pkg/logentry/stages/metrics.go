@@ -234,10 +234,11 @@ func (m *metricStage) recordGauge(name string, gauge *metric.Gauges, labels mode
 
 	switch gauge.Cfg.Action {
 	case metric.GaugeSet:
+		// Unlike the value added or subtracted, the value set can be negative, like a temperature or a delta.
 		f, err := getFloat(v)
-		if err != nil || f < 0 {
+		if err != nil {
 			if Debug {
-				level.Debug(m.logger).Log("msg", "failed to convert extracted value to positive float", "metric", name, "err", err)
+				level.Debug(m.logger).Log("msg", "failed to convert extracted value to float", "metric", name, "err", err)
 			}
 			return
 		}
@@ -13,6 +13,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/famarks/loki/pkg/logentry/metric"
 )
@@ -388,6 +389,29 @@ func TestMetricStage_Process(t *testing.T) {
 	}
 }
 
+func TestMetricStage_GaugeSetNegative(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metricStage, err := New(util.Logger, nil, StageTypeMetric, MetricsConfig{
+		"temperature": MetricConfig{
+			MetricType:  "Gauge",
+			Description: "temperature",
+			Config: metric.GaugeConfig{
+				Action: metric.GaugeSet,
+			},
+		},
+	}, registry)
+	require.NoError(t, err)
+
+	ts := time.Now()
+	entry := "temperature=-12.5"
+	metricStage.Process(model.LabelSet{"sensor": "outside"}, map[string]interface{}{"temperature": "-12.5"}, &ts, &entry)
+
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`# HELP promtail_custom_temperature temperature
+# TYPE promtail_custom_temperature gauge
+promtail_custom_temperature{sensor="outside"} -12.5
+`), "promtail_custom_temperature"))
+}
+
 func metricNames(cfg MetricsConfig) []string {
 	result := make([]string, 0, len(cfg))
 	for name, config := range cfg {
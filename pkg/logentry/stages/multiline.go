@@ -0,0 +1,185 @@
+package stages
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+
+	"github.com/famarks/loki/pkg/promtail/api"
+)
+
+const (
+	ErrMultilineStageEmptyConfig        = "multiline stage config must define `firstline` regular expression"
+	ErrMultilineStageInvalidRegex       = "multiline stage first line regex compilation error: %v"
+	ErrMultilineStageInvalidMaxWaitTime = "multiline stage invalid max wait time duration: %v"
+	ErrMultilineStageInMatch            = "multiline stage cannot be used within a match stage"
+)
+
+const (
+	defaultMultilineMaxWaitTime = 3 * time.Second
+	defaultMultilineMaxLines    = 128
+)
+
+// MultilineConfig contains the configuration for a multilineStage
+type MultilineConfig struct {
+	Expression  *string `mapstructure:"firstline"`
+	regex       *regexp.Regexp
+	MaxLines    *uint64 `mapstructure:"max_lines"`
+	MaxWaitTime *string `mapstructure:"max_wait_time"`
+	maxWait     time.Duration
+}
+
+func validateMultilineConfig(cfg *MultilineConfig) error {
+	if cfg == nil || cfg.Expression == nil || *cfg.Expression == "" {
+		return errors.New(ErrMultilineStageEmptyConfig)
+	}
+
+	expr, err := regexp.Compile(*cfg.Expression)
+	if err != nil {
+		return errors.Errorf(ErrMultilineStageInvalidRegex, err)
+	}
+	cfg.regex = expr
+
+	cfg.maxWait = defaultMultilineMaxWaitTime
+	if cfg.MaxWaitTime != nil {
+		maxWait, err := time.ParseDuration(*cfg.MaxWaitTime)
+		if err != nil || maxWait <= 0 {
+			return errors.Errorf(ErrMultilineStageInvalidMaxWaitTime, *cfg.MaxWaitTime)
+		}
+		cfg.maxWait = maxWait
+	}
+
+	if cfg.MaxLines == nil || *cfg.MaxLines == 0 {
+		maxLines := uint64(defaultMultilineMaxLines)
+		cfg.MaxLines = &maxLines
+	}
+
+	return nil
+}
+
+// multilineStage joins the lines of a block, starting with a line matching the first line regular expression, into a
+// single entry. The blocks are kept by stream, for the lines of several streams not to be joined together.
+type multilineStage struct {
+	logger log.Logger
+	cfg    *MultilineConfig
+}
+
+// newMultilineStage creates a new multilineStage from config
+func newMultilineStage(logger log.Logger, config interface{}) (Stage, error) {
+	cfg := &MultilineConfig{}
+	err := mapstructure.Decode(config, cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = validateMultilineConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &multilineStage{
+		logger: log.With(logger, "component", "stage", "type", "multiline"),
+		cfg:    cfg,
+	}, nil
+}
+
+// Process implements Stage, leaving the entries unchanged: the lines are only joined when the stage wraps the handler of
+// the stages following it, see wrap.
+func (m *multilineStage) Process(labels model.LabelSet, extracted map[string]interface{}, t *time.Time, entry *string) {
+}
+
+// Name implements Stage
+func (m *multilineStage) Name() string {
+	return StageTypeMultiline
+}
+
+// multilineBlock is the block of lines of a stream being joined, with the labels and timestamp of its first line.
+type multilineBlock struct {
+	labels model.LabelSet
+	time   time.Time
+	lines  []string
+	// last is when the last line was read, the timer being reset then.
+	last  time.Time
+	timer *time.Timer
+}
+
+// wrap returns the handler joining the lines of the blocks, sending them to next once the first line of the next block
+// is read, once max_lines are joined or once no line is read for max_wait_time.
+func (m *multilineStage) wrap(next api.EntryHandler) (api.EntryHandler, func() error) {
+	var (
+		mtx    sync.Mutex
+		blocks = map[model.Fingerprint]*multilineBlock{}
+	)
+
+	flush := func(key model.Fingerprint, block *multilineBlock) error {
+		block.timer.Stop()
+		delete(blocks, key)
+		return next.Handle(block.labels, block.time, strings.Join(block.lines, "\n"))
+	}
+
+	handler := api.EntryHandlerFunc(func(labels model.LabelSet, t time.Time, line string) error {
+		key := labels.Fingerprint()
+
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		var err error
+		block, ok := blocks[key]
+		if ok && m.cfg.regex.MatchString(line) {
+			err = flush(key, block)
+			ok = false
+		}
+		if !ok {
+			block = &multilineBlock{labels: labels.Clone(), time: t}
+			block.timer = time.AfterFunc(m.cfg.maxWait, func() {
+				mtx.Lock()
+				defer mtx.Unlock()
+				// the block may have been sent, or a line read while waiting for the lock
+				if blocks[key] != block || time.Since(block.last) < m.cfg.maxWait {
+					return
+				}
+				if err := flush(key, block); err != nil {
+					level.Error(m.logger).Log("msg", "failed to send the lines of the block after max wait time", "err", err)
+				}
+			})
+			blocks[key] = block
+		} else {
+			block.timer.Reset(m.cfg.maxWait)
+		}
+
+		block.last = time.Now()
+		block.lines = append(block.lines, line)
+		if uint64(len(block.lines)) >= *m.cfg.MaxLines {
+			if flushErr := flush(key, block); flushErr != nil {
+				err = flushErr
+			}
+		}
+		return err
+	})
+
+	flushAll := func() error {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		pending := make([]model.Fingerprint, 0, len(blocks))
+		for key := range blocks {
+			pending = append(pending, key)
+		}
+		sort.Slice(pending, func(i, j int) bool { return blocks[pending[i]].time.Before(blocks[pending[j]].time) })
+		var lastErr error
+		for _, key := range pending {
+			if err := flush(key, blocks[key]); err != nil {
+				lastErr = err
+			}
+		}
+		return lastErr
+	}
+	return handler, flushAll
+}
@@ -0,0 +1,149 @@
+package stages
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/promtail/api"
+)
+
+var testMultilineYaml = `
+pipeline_stages:
+- multiline:
+    firstline: "^\\[\\d{4}-\\d{2}-\\d{2}"
+    max_lines: 3
+    max_wait_time: 100ms
+- regex:
+    expression: "^\\[(?P<date>[^\\]]+)\\] (?P<level>\\w+)"
+- labels:
+    level:
+`
+
+type multilineEntries struct {
+	mtx     sync.Mutex
+	entries []multilineEntry
+}
+
+type multilineEntry struct {
+	labels model.LabelSet
+	time   time.Time
+	line   string
+}
+
+func (e *multilineEntries) Handle(labels model.LabelSet, t time.Time, line string) error {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	e.entries = append(e.entries, multilineEntry{labels, t, line})
+	return nil
+}
+
+func (e *multilineEntries) get() []multilineEntry {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	return append([]multilineEntry(nil), e.entries...)
+}
+
+func TestMultilineStage(t *testing.T) {
+	pl, err := NewPipeline(util.Logger, loadConfig(testMultilineYaml), nil, prometheus.DefaultRegisterer)
+	require.NoError(t, err)
+	out := &multilineEntries{}
+	handler := pl.Wrap(out)
+
+	ts := time.Now()
+	a := model.LabelSet{"filename": "a.log"}
+	b := model.LabelSet{"filename": "b.log"}
+	for _, e := range []multilineEntry{
+		{a, ts, "[2020-10-15 12:00:00] ERROR panic"},
+		{b, ts.Add(time.Second), "[2020-10-15 12:00:01] INFO started"},
+		{a, ts.Add(2 * time.Second), "  at main.go:12"},
+		{b, ts.Add(3 * time.Second), "[2020-10-15 12:00:03] WARN slow"},
+		{a, ts.Add(4 * time.Second), "  at server.go:34"},
+		// max_lines are joined, the next line starting a block
+		{a, ts.Add(5 * time.Second), "  at http.go:56"},
+	} {
+		require.NoError(t, handler.Handle(e.labels.Clone(), e.time, e.line))
+	}
+
+	require.Equal(t, []multilineEntry{
+		{model.LabelSet{"filename": "b.log", "level": "INFO"}, ts.Add(time.Second), "[2020-10-15 12:00:01] INFO started"},
+		{model.LabelSet{"filename": "a.log", "level": "ERROR"}, ts, "[2020-10-15 12:00:00] ERROR panic\n  at main.go:12\n  at server.go:34"},
+	}, out.get())
+
+	// the last blocks are sent after max_wait_time
+	require.Eventually(t, func() bool { return len(out.get()) == 4 }, time.Second, 10*time.Millisecond)
+	entries := out.get()
+	require.ElementsMatch(t, []multilineEntry{
+		{model.LabelSet{"filename": "b.log", "level": "WARN"}, ts.Add(3 * time.Second), "[2020-10-15 12:00:03] WARN slow"},
+		{model.LabelSet{"filename": "a.log"}, ts.Add(5 * time.Second), "  at http.go:56"},
+	}, entries[2:])
+}
+
+func TestMultilineStage_Flush(t *testing.T) {
+	pl, err := NewPipeline(util.Logger, loadConfig(`
+pipeline_stages:
+- multiline:
+    firstline: "^\\["
+    max_wait_time: 1h
+`), nil, prometheus.DefaultRegisterer)
+	require.NoError(t, err)
+	out := &multilineEntries{}
+	handler := pl.Wrap(out)
+
+	ts := time.Now()
+	a := model.LabelSet{"filename": "a.log"}
+	b := model.LabelSet{"filename": "b.log"}
+	require.NoError(t, handler.Handle(b.Clone(), ts.Add(time.Second), "[b] started"))
+	require.NoError(t, handler.Handle(a.Clone(), ts, "[a] panic"))
+	require.NoError(t, handler.Handle(a.Clone(), ts.Add(2*time.Second), "  at main.go:12"))
+	require.Empty(t, out.get())
+
+	// the blocks held back are sent in order
+	require.NoError(t, handler.(api.EntryFlusher).Flush())
+	require.Equal(t, []multilineEntry{
+		{a, ts, "[a] panic\n  at main.go:12"},
+		{b, ts.Add(time.Second), "[b] started"},
+	}, out.get())
+
+	// the pipelines without stages holding back the entries cannot be flushed
+	pl, err = NewPipeline(util.Logger, loadConfig(testLabelsYaml), nil, prometheus.DefaultRegisterer)
+	require.NoError(t, err)
+	_, ok := pl.Wrap(out).(api.EntryFlusher)
+	require.False(t, ok)
+}
+
+func TestMultilineStage_Validation(t *testing.T) {
+	for name, config := range map[string]interface{}{
+		ErrMultilineStageEmptyConfig:        map[string]interface{}{"max_lines": 10},
+		ErrMultilineStageInvalidRegex:       map[string]interface{}{"firstline": "(["},
+		ErrMultilineStageInvalidMaxWaitTime: map[string]interface{}{"firstline": "^\\S", "max_wait_time": "soon"},
+	} {
+		_, err := newMultilineStage(util.Logger, config)
+		require.Error(t, err, name)
+	}
+
+	_, err := NewPipeline(util.Logger, loadConfig(`
+pipeline_stages:
+- match:
+    selector: '{app="loki"}'
+    stages:
+    - multiline:
+        firstline: "^\\S"
+`), nil, prometheus.DefaultRegisterer)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), ErrMultilineStageInMatch)
+
+	// the entries are left unchanged when processed outside of a handler
+	stage, err := newMultilineStage(util.Logger, map[string]interface{}{"firstline": "^\\S"})
+	require.NoError(t, err)
+	line := "  continued"
+	stage.Process(model.LabelSet{}, map[string]interface{}{}, &time.Time{}, &line)
+	require.Equal(t, "  continued", line)
+}
+
+var _ api.EntryHandler = &multilineEntries{}
@@ -0,0 +1,116 @@
+package stages
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+
+	logqllog "github.com/famarks/loki/pkg/logql/log"
+)
+
+const (
+	ErrEmptyPackStageConfig = "pack stage config must contain at least one label"
+	ErrPackStageEntryLabel  = "pack stage cannot pack the `_entry` label"
+)
+
+// PackConfig contains the configuration for a packStage
+type PackConfig struct {
+	Labels          []string `mapstructure:"labels"`
+	IngestTimestamp *bool    `mapstructure:"ingest_timestamp"`
+}
+
+// validatePackConfig validates the PackConfig for the packStage
+func validatePackConfig(cfg *PackConfig) error {
+	if cfg == nil || len(cfg.Labels) == 0 {
+		return errors.New(ErrEmptyPackStageConfig)
+	}
+	for _, l := range cfg.Labels {
+		if l == logqllog.PackedEntryKey {
+			return errors.New(ErrPackStageEntryLabel)
+		}
+	}
+	if cfg.IngestTimestamp == nil {
+		ingestTimestamp := true
+		cfg.IngestTimestamp = &ingestTimestamp
+	}
+	return nil
+}
+
+// newPackStage creates a packStage from config
+func newPackStage(logger log.Logger, config interface{}) (Stage, error) {
+	cfg := &PackConfig{}
+	err := mapstructure.Decode(config, cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = validatePackConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &packStage{
+		logger: log.With(logger, "component", "stage", "type", "pack"),
+		cfg:    cfg,
+	}, nil
+}
+
+// packStage embeds the labels of the config, and the extracted data of the same names, into a JSON object with the
+// log line, the labels being removed from the stream. The lines are unpacked with the unpack parser of LogQL.
+type packStage struct {
+	logger log.Logger
+	cfg    *PackConfig
+}
+
+// Process implements Stage
+func (p *packStage) Process(labels model.LabelSet, extracted map[string]interface{}, t *time.Time, entry *string) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, name := range p.cfg.Labels {
+		var value string
+		if lv, ok := labels[model.LabelName(name)]; ok {
+			value = string(lv)
+			delete(labels, model.LabelName(name))
+		} else if ev, ok := extracted[name]; ok {
+			s, err := getString(ev)
+			if err != nil {
+				if Debug {
+					level.Debug(p.logger).Log("msg", "failed to convert extracted value to string", "err", err, "type", reflect.TypeOf(ev))
+				}
+				continue
+			}
+			value = s
+		} else {
+			continue
+		}
+		writeJSONField(&buf, name, value)
+		buf.WriteByte(',')
+	}
+	writeJSONField(&buf, logqllog.PackedEntryKey, *entry)
+	buf.WriteByte('}')
+	*entry = buf.String()
+
+	// The packed lines of several streams are sent in the same stream, where they must be in order.
+	if *p.cfg.IngestTimestamp {
+		*t = time.Now()
+	}
+}
+
+func writeJSONField(buf *bytes.Buffer, key, value string) {
+	k, _ := json.Marshal(key)
+	v, _ := json.Marshal(value)
+	buf.Write(k)
+	buf.WriteByte(':')
+	buf.Write(v)
+}
+
+// Name implements Stage
+func (p *packStage) Name() string {
+	return StageTypePack
+}
@@ -0,0 +1,60 @@
+package stages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+var testPackYaml = `
+pipeline_stages:
+- regex:
+    expression: "^(?P<level>\\w+) "
+- pack:
+    labels:
+    - pod
+    - level
+    - missing
+    ingest_timestamp: false
+`
+
+func TestPackStage(t *testing.T) {
+	pl, err := NewPipeline(util.Logger, loadConfig(testPackYaml), nil, prometheus.DefaultRegisterer)
+	require.NoError(t, err)
+
+	lbls := model.LabelSet{"job": "loki", "pod": "loki-0"}
+	ts := time.Date(2020, 10, 15, 12, 0, 0, 0, time.UTC)
+	entry := `info msg="started" path="/loki/api/v1/push"`
+	pl.Process(lbls, map[string]interface{}{}, &ts, &entry)
+
+	require.Equal(t, model.LabelSet{"job": "loki"}, lbls)
+	require.Equal(t, `{"pod":"loki-0","level":"info","_entry":"info msg=\"started\" path=\"/loki/api/v1/push\""}`, entry)
+	require.Equal(t, time.Date(2020, 10, 15, 12, 0, 0, 0, time.UTC), ts)
+}
+
+func TestPackStage_IngestTimestamp(t *testing.T) {
+	stage, err := newPackStage(util.Logger, map[string]interface{}{"labels": []string{"pod"}})
+	require.NoError(t, err)
+
+	lbls := model.LabelSet{"pod": "loki-0"}
+	ts := time.Date(2020, 10, 15, 12, 0, 0, 0, time.UTC)
+	entry := "line"
+	before := time.Now()
+	stage.Process(lbls, map[string]interface{}{}, &ts, &entry)
+
+	require.Equal(t, model.LabelSet{}, lbls)
+	require.Equal(t, `{"pod":"loki-0","_entry":"line"}`, entry)
+	require.False(t, ts.Before(before))
+}
+
+func TestPackStage_Validation(t *testing.T) {
+	_, err := newPackStage(util.Logger, map[string]interface{}{})
+	require.EqualError(t, err, ErrEmptyPackStageConfig)
+
+	_, err = newPackStage(util.Logger, map[string]interface{}{"labels": []string{"_entry"}})
+	require.EqualError(t, err, ErrPackStageEntryLabel)
+}
@@ -94,6 +94,10 @@ func NewPipeline(logger log.Logger, stgs PipelineStages, jobName *string, regist
 
 // Process implements Stage allowing a pipeline stage to also be an entire pipeline
 func (p *Pipeline) Process(labels model.LabelSet, extracted map[string]interface{}, ts *time.Time, entry *string) {
+	p.process(p.stages, labels, extracted, ts, entry)
+}
+
+func (p *Pipeline) process(stages []Stage, labels model.LabelSet, extracted map[string]interface{}, ts *time.Time, entry *string) {
 	start := time.Now()
 
 	// Initialize the extracted map with the initial labels (ie. "filename"),
@@ -102,7 +106,7 @@ func (p *Pipeline) Process(labels model.LabelSet, extracted map[string]interface
 		extracted[string(labelName)] = string(labelValue)
 	}
 
-	for i, stage := range p.stages {
+	for i, stage := range stages {
 		if Debug {
 			level.Debug(p.logger).Log("msg", "processing pipeline", "stage", i, "name", stage.Name(), "labels", labels, "time", ts, "entry", entry)
 		}
@@ -122,11 +126,55 @@ func (p *Pipeline) Name() string {
 	return StageTypePipeline
 }
 
+// entryWrapper is implemented by the stages holding back the entries until they are complete, like the multiline stage
+// joining the lines of a block. The stage wraps the handler of the stages following it, to which it sends the entries
+// once complete, and returns a function sending the entries held back.
+type entryWrapper interface {
+	wrap(next api.EntryHandler) (api.EntryHandler, func() error)
+}
+
+// flushingHandler is the handler of a pipeline holding back entries.
+type flushingHandler struct {
+	api.EntryHandler
+	flushes []func() error
+}
+
+// Flush implements api.EntryFlusher, sending the entries held back by the stages in order, as the entries sent by a
+// stage may be held back by the following ones.
+func (h *flushingHandler) Flush() error {
+	var lastErr error
+	for _, flush := range h.flushes {
+		if err := flush(); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
 // Wrap implements EntryMiddleware
 func (p *Pipeline) Wrap(next api.EntryHandler) api.EntryHandler {
+	// The stages following a stage holding back the entries process the entries it sends once complete.
+	handler := next
+	end := len(p.stages)
+	var flushes []func() error
+	for i := len(p.stages) - 1; i >= 0; i-- {
+		if w, ok := p.stages[i].(entryWrapper); ok {
+			var flush func() error
+			handler, flush = w.wrap(p.wrapStages(p.stages[i+1:end], handler))
+			flushes = append([]func() error{flush}, flushes...)
+			end = i
+		}
+	}
+	if len(flushes) == 0 {
+		return p.wrapStages(p.stages, next)
+	}
+	return &flushingHandler{EntryHandler: p.wrapStages(p.stages[:end], handler), flushes: flushes}
+}
+
+func (p *Pipeline) wrapStages(stages []Stage, next api.EntryHandler) api.EntryHandler {
 	return api.EntryHandlerFunc(func(labels model.LabelSet, timestamp time.Time, line string) error {
 		extracted := map[string]interface{}{}
-		p.Process(labels, extracted, &timestamp, &line)
+		p.process(stages, labels, extracted, &timestamp, &line)
 		// if the labels set contains the __drop__ label we don't send this entry to the next EntryHandler
 		if reason, ok := labels[dropLabel]; ok {
 			if reason == "" {
@@ -16,8 +16,9 @@ import (
 
 // Config Errors
 const (
-	ErrEmptyReplaceStageConfig = "empty replace stage configuration"
-	ErrEmptyReplaceStageSource = "empty source in replace stage"
+	ErrEmptyReplaceStageConfig     = "empty replace stage configuration"
+	ErrEmptyReplaceStageSource     = "empty source in replace stage"
+	ErrReplaceStageInvalidTemplate = "invalid replace stage template"
 )
 
 // ReplaceConfig contains a regexStage configuration
@@ -52,6 +53,7 @@ func validateReplaceConfig(c *ReplaceConfig) (*regexp.Regexp, error) {
 type replaceStage struct {
 	cfg        *ReplaceConfig
 	expression *regexp.Regexp
+	template   *template.Template
 	logger     log.Logger
 }
 
@@ -66,9 +68,16 @@ func newReplaceStage(logger log.Logger, config interface{}) (Stage, error) {
 		return nil, err
 	}
 
+	// Initialize the template with the "replace" string defined by user
+	templ, err := template.New("pipeline_template").Funcs(functionMap).Parse(cfg.Replace)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrReplaceStageInvalidTemplate)
+	}
+
 	return &replaceStage{
 		cfg:        cfg,
 		expression: expression,
+		template:   templ,
 		logger:     log.With(logger, "component", "stage", "type", "replace"),
 	}, nil
 }
@@ -130,16 +139,7 @@ func (r *replaceStage) Process(labels model.LabelSet, extracted map[string]inter
 	// All extracted values will be available for templating
 	td := r.getTemplateData(extracted)
 
-	// Initialize the template with the "replace" string defined by user
-	templ, err := template.New("pipeline_template").Funcs(functionMap).Parse(r.cfg.Replace)
-	if err != nil {
-		if Debug {
-			level.Debug(r.logger).Log("msg", "template initialization error", "err", err)
-		}
-		return
-	}
-
-	result, capturedMap, err := r.getReplacedEntry(matchAllIndex, *input, td, templ)
+	result, capturedMap, err := r.getReplacedEntry(matchAllIndex, *input, td, r.template)
 	if err != nil {
 		if Debug {
 			level.Debug(r.logger).Log("msg", "failed to execute template on extracted value", "err", err)
@@ -175,8 +175,13 @@ func (r *replaceStage) getReplacedEntry(matchAllIndex [][]int, input string, td
 	// matched string and the next values will be start and end index of the matched
 	// captured group. Here 0-19 is "11.11.11.11 - frank",  0-11 is "11.11.11.11" and
 	// 14-19 is "frank". So, we advance by 2 index to get the next match
+	// When the regex has no captured group, the entire matched string is replaced.
+	first := 2
+	if len(matchAllIndex[0]) == 2 {
+		first = 0
+	}
 	for _, matchIndex := range matchAllIndex {
-		for i := 2; i < len(matchIndex); i += 2 {
+		for i := first; i < len(matchIndex); i += 2 {
 			capturedString := input[matchIndex[i]:matchIndex[i+1]]
 			buf := &bytes.Buffer{}
 			td["Value"] = capturedString
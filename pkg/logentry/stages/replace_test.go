@@ -58,6 +58,24 @@ pipeline_stages:
       replace: ''
 `
 
+var testReplaceYamlWithoutCapturedGroup = `
+---
+pipeline_stages:
+  -
+    replace:
+      expression: "\\d{4}-\\d{4}-\\d{4}-\\d{4}"
+      replace: '****-****-****-****'
+`
+
+var testReplaceYamlWithHashedEmail = `
+---
+pipeline_stages:
+  -
+    replace:
+      expression: "(?P<email>[\\w.+-]+@[\\w-]+\\.[\\w.]+)"
+      replace: '{{ .Value | Hash "salt" }}'
+`
+
 var testReplaceLogLine = `11.11.11.11 - frank [25/Jan/2000:14:00:01 -0500] "GET /1986.js HTTP/1.1" 200 932 "-" "Mozilla/5.0 (Windows; U; Windows NT 5.1; de; rv:1.9.1.7) Gecko/20091221 Firefox/3.5.7 GTB6"`
 var testReplaceLogJSONLine = `{"time":"2019-01-01T01:00:00.000000001Z", "level": "info", "msg": "11.11.11.11 - \"POST /loki/api/push/ HTTP/1.1\" 200 932 \"-\" \"Mozilla/5.0 (Windows; U; Windows NT 5.1; de; rv:1.9.1.7) Gecko/20091221 Firefox/3.5.7 GTB6\""}`
 
@@ -114,6 +132,20 @@ func TestPipeline_Replace(t *testing.T) {
 			map[string]interface{}{},
 			`11.11.11.11 - [25/Jan/2000:14:00:01 -0500] "GET /1986.js HTTP/1.1" 200 932 "-" "Mozilla/5.0 (Windows; U; Windows NT 5.1; de; rv:1.9.1.7) Gecko/20091221 Firefox/3.5.7 GTB6"`,
 		},
+		"successfully run a pipeline replacing the entire match of a regex without captured group": {
+			testReplaceYamlWithoutCapturedGroup,
+			"payment card=1234-5678-9012-3456 refund card=4321-8765-2109-6543 done",
+			map[string]interface{}{},
+			"payment card=****-****-****-**** refund card=****-****-****-**** done",
+		},
+		"successfully run a pipeline hashing the captured emails": {
+			testReplaceYamlWithHashedEmail,
+			"login of jane.doe@example.com",
+			map[string]interface{}{
+				"email": "97672cc31802c5cc6bf35117df8cee7e0cc596cd2aec8063a45c383396406a58",
+			},
+			"login of 97672cc31802c5cc6bf35117df8cee7e0cc596cd2aec8063a45c383396406a58",
+		},
 	}
 
 	for testName, testData := range tests {
@@ -168,6 +200,17 @@ func TestReplaceMapStructure(t *testing.T) {
 	}
 }
 
+func TestReplaceStage_InvalidTemplate(t *testing.T) {
+	t.Parallel()
+
+	_, err := newReplaceStage(util.Logger, map[string]interface{}{
+		"expression": "(\\d+)",
+		"replace":    "{{ .Value ",
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), ErrReplaceStageInvalidTemplate)
+}
+
 func TestReplaceConfig_validate(t *testing.T) {
 	t.Parallel()
 	tests := map[string]struct {
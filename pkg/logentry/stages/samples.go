@@ -0,0 +1,131 @@
+package stages
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// Sample is an entry run through a pipeline to test it, with the output expected.
+type Sample struct {
+	Name      string            `json:"name,omitempty"`
+	Line      string            `json:"line"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Timestamp *time.Time        `json:"timestamp,omitempty"`
+	// Expected is compared with the output of the pipeline, only its fields set being checked.
+	Expected *SampleOutput `json:"expected,omitempty"`
+}
+
+// SampleOutput is the entry output by a pipeline, and the values extracted by its stages.
+type SampleOutput struct {
+	Line      *string                `json:"line,omitempty"`
+	Labels    map[string]string      `json:"labels,omitempty"`
+	Timestamp *time.Time             `json:"timestamp,omitempty"`
+	Extracted map[string]interface{} `json:"extracted,omitempty"`
+	// Dropped is the reason why the entry was dropped, if it was.
+	Dropped *string `json:"dropped,omitempty"`
+}
+
+// SampleResult is the output of a pipeline for a sample, and the differences with the output expected.
+type SampleResult struct {
+	Name     string        `json:"name,omitempty"`
+	Passed   bool          `json:"passed"`
+	Expected *SampleOutput `json:"expected,omitempty"`
+	Actual   SampleOutput  `json:"actual"`
+	Errors   []string      `json:"errors,omitempty"`
+}
+
+// RunSamples runs each sample through the pipeline and compares its output with the output expected. The samples are
+// run one by one, the stages holding back the entries, like the multiline stage, processing them without joining them.
+func (p *Pipeline) RunSamples(samples []Sample) []SampleResult {
+	results := make([]SampleResult, 0, len(samples))
+	for _, sample := range samples {
+		labels := model.LabelSet{}
+		for name, value := range sample.Labels {
+			labels[model.LabelName(name)] = model.LabelValue(value)
+		}
+		ts := time.Now()
+		if sample.Timestamp != nil {
+			ts = *sample.Timestamp
+		}
+		line := sample.Line
+		extracted := map[string]interface{}{}
+		p.process(p.stages, labels, extracted, &ts, &line)
+
+		actual := SampleOutput{
+			Line:      &line,
+			Labels:    map[string]string{},
+			Timestamp: &ts,
+			Extracted: extracted,
+		}
+		for name, value := range labels {
+			if name == dropLabel {
+				reason := string(value)
+				if reason == "" {
+					reason = "undefined"
+				}
+				actual.Dropped = &reason
+				continue
+			}
+			actual.Labels[string(name)] = string(value)
+		}
+
+		result := SampleResult{Name: sample.Name, Expected: sample.Expected, Actual: actual}
+		if sample.Expected != nil {
+			result.Errors = compareSampleOutput(*sample.Expected, actual)
+		}
+		result.Passed = len(result.Errors) == 0
+		results = append(results, result)
+	}
+	return results
+}
+
+func compareSampleOutput(expected, actual SampleOutput) []string {
+	var errs []string
+	if expected.Line != nil && *expected.Line != *actual.Line {
+		errs = append(errs, fmt.Sprintf("line: expected %q, got %q", *expected.Line, *actual.Line))
+	}
+	if expected.Labels != nil && !reflect.DeepEqual(expected.Labels, actual.Labels) {
+		errs = append(errs, fmt.Sprintf("labels: expected %v, got %v", sampleLabelSet(expected.Labels), sampleLabelSet(actual.Labels)))
+	}
+	if expected.Timestamp != nil && !expected.Timestamp.Equal(*actual.Timestamp) {
+		errs = append(errs, fmt.Sprintf("timestamp: expected %s, got %s", expected.Timestamp.Format(time.RFC3339Nano), actual.Timestamp.Format(time.RFC3339Nano)))
+	}
+	// Only the values expected are compared, as their string, the numbers decoded from the samples being floats.
+	names := make([]string, 0, len(expected.Extracted))
+	for name := range expected.Extracted {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, ok := actual.Extracted[name]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("extracted %s: expected %v, got nothing", name, expected.Extracted[name]))
+			continue
+		}
+		if fmt.Sprint(expected.Extracted[name]) != fmt.Sprint(value) {
+			errs = append(errs, fmt.Sprintf("extracted %s: expected %v, got %v", name, expected.Extracted[name], value))
+		}
+	}
+	switch {
+	case expected.Dropped == nil && actual.Dropped != nil:
+		errs = append(errs, fmt.Sprintf("dropped: expected not dropped, got %q", *actual.Dropped))
+	case expected.Dropped == nil:
+	case actual.Dropped == nil:
+		errs = append(errs, fmt.Sprintf("dropped: expected %q, got not dropped", *expected.Dropped))
+	case *expected.Dropped != *actual.Dropped:
+		errs = append(errs, fmt.Sprintf("dropped: expected %q, got %q", *expected.Dropped, *actual.Dropped))
+	}
+	return errs
+}
+
+func sampleLabelSet(labels map[string]string) model.LabelSet {
+	ls := make(model.LabelSet, len(labels))
+	for name, value := range labels {
+		ls[model.LabelName(name)] = model.LabelValue(value)
+	}
+	return ls
+}
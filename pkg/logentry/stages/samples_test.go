@@ -0,0 +1,85 @@
+package stages
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+var testSamplesYaml = `
+pipeline_stages:
+- regex:
+    expression: "^level=(?P<level>\\w+) took=(?P<took>\\d+) (?P<msg>.*)$"
+- labels:
+    level:
+- output:
+    source: msg
+- drop:
+    source: level
+    value: debug
+    drop_counter_reason: debug_lines
+`
+
+var testSamples = `[
+  {
+    "name": "passed",
+    "line": "level=info took=12 started",
+    "labels": {"job": "app"},
+    "timestamp": "2020-10-15T12:00:00Z",
+    "expected": {
+      "line": "started",
+      "labels": {"job": "app", "level": "info"},
+      "timestamp": "2020-10-15T12:00:00Z",
+      "extracted": {"took": 12}
+    }
+  },
+  {
+    "name": "dropped",
+    "line": "level=debug took=1 noise",
+    "expected": {"dropped": "debug_lines"}
+  },
+  {
+    "name": "failed",
+    "line": "level=warn took=3 slow",
+    "expected": {"line": "level=warn took=3 slow", "labels": {"level": "error"}, "extracted": {"took": 4, "user": "a"}}
+  },
+  {
+    "name": "no expectations",
+    "line": "level=debug took=1 noise"
+  }
+]`
+
+func TestPipeline_RunSamples(t *testing.T) {
+	pl, err := NewPipeline(util.Logger, loadConfig(testSamplesYaml), nil, prometheus.DefaultRegisterer)
+	require.NoError(t, err)
+
+	var samples []Sample
+	require.NoError(t, json.Unmarshal([]byte(testSamples), &samples))
+	results := pl.RunSamples(samples)
+	require.Len(t, results, 4)
+
+	require.True(t, results[0].Passed, results[0].Errors)
+	require.Equal(t, "started", *results[0].Actual.Line)
+	require.Equal(t, map[string]string{"job": "app", "level": "info"}, results[0].Actual.Labels)
+	require.Equal(t, time.Date(2020, 10, 15, 12, 0, 0, 0, time.UTC), *results[0].Actual.Timestamp)
+	require.Equal(t, "12", results[0].Actual.Extracted["took"])
+
+	require.True(t, results[1].Passed, results[1].Errors)
+	require.Equal(t, "debug_lines", *results[1].Actual.Dropped)
+
+	require.False(t, results[2].Passed)
+	require.Equal(t, []string{
+		`line: expected "level=warn took=3 slow", got "slow"`,
+		`labels: expected {level="error"}, got {level="warn"}`,
+		`extracted took: expected 4, got 3`,
+		`extracted user: expected a, got nothing`,
+	}, results[2].Errors)
+
+	// without expectations, the dropped entries pass as well
+	require.True(t, results[3].Passed)
+	require.Equal(t, "debug_lines", *results[3].Actual.Dropped)
+}
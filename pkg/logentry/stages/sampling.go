@@ -0,0 +1,117 @@
+package stages
+
+import (
+	"hash/fnv"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	ErrSamplingStageInvalidRate      = "sampling stage rate must be greater than 0 and at most 1"
+	ErrSamplingStageInvalidRateLabel = "sampling stage rate label is not a valid label name: %v"
+)
+
+var (
+	defaultSamplingDropReason = "sampling_stage"
+)
+
+// SamplingConfig contains the configuration for a samplingStage
+type SamplingConfig struct {
+	Rate       float64 `mapstructure:"rate"`
+	Source     *string `mapstructure:"source"`
+	RateLabel  *string `mapstructure:"rate_label"`
+	DropReason *string `mapstructure:"drop_counter_reason"`
+}
+
+// validateSamplingConfig validates the SamplingConfig for the samplingStage
+func validateSamplingConfig(cfg *SamplingConfig) error {
+	if cfg.Rate <= 0 || cfg.Rate > 1 {
+		return errors.New(ErrSamplingStageInvalidRate)
+	}
+	if cfg.RateLabel != nil && !model.LabelName(*cfg.RateLabel).IsValid() {
+		return errors.Errorf(ErrSamplingStageInvalidRateLabel, *cfg.RateLabel)
+	}
+	if cfg.DropReason == nil || *cfg.DropReason == "" {
+		cfg.DropReason = &defaultSamplingDropReason
+	}
+	return nil
+}
+
+// newSamplingStage creates a samplingStage from config
+func newSamplingStage(logger log.Logger, config interface{}) (Stage, error) {
+	cfg := &SamplingConfig{}
+	err := mapstructure.WeakDecode(config, cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = validateSamplingConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &samplingStage{
+		logger:    log.With(logger, "component", "stage", "type", "sampling"),
+		cfg:       cfg,
+		threshold: samplingThreshold(cfg.Rate),
+		rate:      model.LabelValue(strconv.FormatFloat(cfg.Rate, 'f', -1, 64)),
+	}, nil
+}
+
+// samplingThreshold returns the hash under which the entries are kept for the rate.
+func samplingThreshold(rate float64) uint64 {
+	if rate >= 1 {
+		return math.MaxUint64
+	}
+	return uint64(rate * math.MaxUint64)
+}
+
+// samplingStage keeps a fraction of the entries, deciding from the hash of the log line, or of the value of the source
+// in the extracted data, for all the instances of promtail to keep the same entries.
+type samplingStage struct {
+	logger    log.Logger
+	cfg       *SamplingConfig
+	threshold uint64
+	rate      model.LabelValue
+}
+
+// Process implements Stage
+func (s *samplingStage) Process(labels model.LabelSet, extracted map[string]interface{}, t *time.Time, entry *string) {
+	value := *entry
+	if s.cfg.Source != nil {
+		v, ok := extracted[*s.cfg.Source]
+		if !ok {
+			if Debug {
+				level.Debug(s.logger).Log("msg", "the source was not found in the extracted map, sampling the log line", "source", *s.cfg.Source)
+			}
+		} else if str, err := getString(v); err != nil {
+			if Debug {
+				level.Debug(s.logger).Log("msg", "failed to convert the source value to string, sampling the log line", "err", err, "type", reflect.TypeOf(v))
+			}
+		} else {
+			value = str
+		}
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(value))
+	if h.Sum64() > s.threshold {
+		labels[dropLabel] = model.LabelValue(*s.cfg.DropReason)
+		return
+	}
+	if s.cfg.RateLabel != nil {
+		labels[model.LabelName(*s.cfg.RateLabel)] = s.rate
+	}
+}
+
+// Name implements Stage
+func (s *samplingStage) Name() string {
+	return StageTypeSampling
+}
@@ -0,0 +1,92 @@
+package stages
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+var testSamplingYaml = `
+pipeline_stages:
+- json:
+    expressions:
+      trace_id:
+- sampling:
+    rate: 0.25
+    source: trace_id
+    rate_label: sample_rate
+`
+
+func TestSamplingStage(t *testing.T) {
+	stage, err := newSamplingStage(util.Logger, map[string]interface{}{"rate": 0.1})
+	require.NoError(t, err)
+
+	sample := func() map[string]bool {
+		kept := map[string]bool{}
+		for i := 0; i < 10000; i++ {
+			lbls := model.LabelSet{}
+			ts := time.Now()
+			entry := fmt.Sprintf("GET /api/%d 200", i)
+			stage.Process(lbls, map[string]interface{}{}, &ts, &entry)
+			if _, dropped := lbls[dropLabel]; !dropped {
+				kept[entry] = true
+			} else {
+				require.Equal(t, model.LabelValue(defaultSamplingDropReason), lbls[dropLabel])
+			}
+		}
+		return kept
+	}
+	kept := sample()
+	require.InDelta(t, 1000, len(kept), 150)
+	// the same lines are always kept
+	require.Equal(t, kept, sample())
+}
+
+func TestSamplingStage_Source(t *testing.T) {
+	pl, err := NewPipeline(util.Logger, loadConfig(testSamplingYaml), nil, prometheus.DefaultRegisterer)
+	require.NoError(t, err)
+
+	// the lines of a trace are all kept or all dropped
+	for i := 0; i < 100; i++ {
+		var kept []bool
+		for _, msg := range []string{"start", "query", "end"} {
+			lbls := model.LabelSet{}
+			ts := time.Now()
+			entry := fmt.Sprintf(`{"trace_id": "%d", "msg": "%s"}`, i, msg)
+			pl.Process(lbls, map[string]interface{}{}, &ts, &entry)
+			_, dropped := lbls[dropLabel]
+			if !dropped {
+				require.Equal(t, model.LabelSet{"sample_rate": "0.25"}, lbls)
+			}
+			kept = append(kept, !dropped)
+		}
+		require.Equal(t, kept[0], kept[1])
+		require.Equal(t, kept[0], kept[2])
+	}
+}
+
+func TestSamplingStage_Validation(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{},
+		{"rate": 0},
+		{"rate": 1.5},
+	} {
+		_, err := newSamplingStage(util.Logger, config)
+		require.EqualError(t, err, ErrSamplingStageInvalidRate)
+	}
+	_, err := newSamplingStage(util.Logger, map[string]interface{}{"rate": 1, "rate_label": "sample-rate"})
+	require.Error(t, err)
+
+	stage, err := newSamplingStage(util.Logger, map[string]interface{}{"rate": 1})
+	require.NoError(t, err)
+	lbls := model.LabelSet{}
+	ts := time.Now()
+	entry := "line"
+	stage.Process(lbls, map[string]interface{}{}, &ts, &entry)
+	require.Equal(t, model.LabelSet{}, lbls)
+}
@@ -10,21 +10,29 @@ import (
 )
 
 const (
-	StageTypeJSON      = "json"
-	StageTypeRegex     = "regex"
-	StageTypeReplace   = "replace"
-	StageTypeMetric    = "metrics"
-	StageTypeLabel     = "labels"
-	StageTypeLabelDrop = "labeldrop"
-	StageTypeTimestamp = "timestamp"
-	StageTypeOutput    = "output"
-	StageTypeDocker    = "docker"
-	StageTypeCRI       = "cri"
-	StageTypeMatch     = "match"
-	StageTypeTemplate  = "template"
-	StageTypePipeline  = "pipeline"
-	StageTypeTenant    = "tenant"
-	StageTypeDrop      = "drop"
+	StageTypeJSON            = "json"
+	StageTypeRegex           = "regex"
+	StageTypeReplace         = "replace"
+	StageTypeMetric          = "metrics"
+	StageTypeLabel           = "labels"
+	StageTypeLabelDrop       = "labeldrop"
+	StageTypeTimestamp       = "timestamp"
+	StageTypeOutput          = "output"
+	StageTypeDocker          = "docker"
+	StageTypeCRI             = "cri"
+	StageTypeMatch           = "match"
+	StageTypeTemplate        = "template"
+	StageTypePipeline        = "pipeline"
+	StageTypeTenant          = "tenant"
+	StageTypeDrop            = "drop"
+	StageTypeMultiline       = "multiline"
+	StageTypePack            = "pack"
+	StageTypeLimit           = "limit"
+	StageTypeSampling        = "sampling"
+	StageTypeGeoIP           = "geoip"
+	StageTypeDecolorize      = "decolorize"
+	StageTypeStaticLabels    = "static_labels"
+	StageTypeEventLogMessage = "eventlogmessage"
 )
 
 // Stage takes an existing set of labels, timestamp and log entry and returns either a possibly mutated
@@ -54,7 +62,7 @@ func New(logger log.Logger, jobName *string, stageType string,
 			return nil, err
 		}
 	case StageTypeCRI:
-		s, err = NewCRI(logger, registerer)
+		s, err = NewCRI(logger, cfg, registerer)
 		if err != nil {
 			return nil, err
 		}
@@ -118,6 +126,46 @@ func New(logger log.Logger, jobName *string, stageType string,
 		if err != nil {
 			return nil, err
 		}
+	case StageTypeMultiline:
+		s, err = newMultilineStage(logger, cfg)
+		if err != nil {
+			return nil, err
+		}
+	case StageTypePack:
+		s, err = newPackStage(logger, cfg)
+		if err != nil {
+			return nil, err
+		}
+	case StageTypeLimit:
+		s, err = newLimitStage(logger, cfg)
+		if err != nil {
+			return nil, err
+		}
+	case StageTypeSampling:
+		s, err = newSamplingStage(logger, cfg)
+		if err != nil {
+			return nil, err
+		}
+	case StageTypeGeoIP:
+		s, err = newGeoIPStage(logger, cfg)
+		if err != nil {
+			return nil, err
+		}
+	case StageTypeDecolorize:
+		s, err = newDecolorizeStage(cfg)
+		if err != nil {
+			return nil, err
+		}
+	case StageTypeStaticLabels:
+		s, err = newStaticLabelsStage(cfg)
+		if err != nil {
+			return nil, err
+		}
+	case StageTypeEventLogMessage:
+		s, err = newEventLogMessageStage(logger, cfg)
+		if err != nil {
+			return nil, err
+		}
 	default:
 		return nil, errors.Errorf("Unknown stage type: %s", stageType)
 	}
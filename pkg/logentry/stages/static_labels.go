@@ -0,0 +1,71 @@
+package stages
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	ErrEmptyStaticLabelStageConfig = "static_labels stage config cannot be empty"
+	ErrInvalidStaticLabelValue     = "invalid value of the static label %s: %q"
+)
+
+// StaticLabelConfig is a set of labels to be set to fixed values
+type StaticLabelConfig map[string]string
+
+// validateStaticLabelConfig validates the static_labels stage configuration
+func validateStaticLabelConfig(c StaticLabelConfig) error {
+	if len(c) == 0 {
+		return errors.New(ErrEmptyStaticLabelStageConfig)
+	}
+	for labelName, labelValue := range c {
+		if !model.LabelName(labelName).IsValid() {
+			return fmt.Errorf(ErrInvalidLabelName, labelName)
+		}
+		if labelValue == "" || !model.LabelValue(labelValue).IsValid() {
+			return fmt.Errorf(ErrInvalidStaticLabelValue, labelName, labelValue)
+		}
+	}
+	return nil
+}
+
+// newStaticLabelsStage creates a new static_labels stage to set fixed labels
+func newStaticLabelsStage(configs interface{}) (Stage, error) {
+	cfgs := StaticLabelConfig{}
+	err := mapstructure.WeakDecode(configs, &cfgs)
+	if err != nil {
+		return nil, err
+	}
+	err = validateStaticLabelConfig(cfgs)
+	if err != nil {
+		return nil, err
+	}
+	labels := make(model.LabelSet, len(cfgs))
+	for name, value := range cfgs {
+		labels[model.LabelName(name)] = model.LabelValue(value)
+	}
+	return &staticLabelsStage{
+		labels: labels,
+	}, nil
+}
+
+// staticLabelsStage sets fixed labels on all the entries
+type staticLabelsStage struct {
+	labels model.LabelSet
+}
+
+// Process implements Stage
+func (s *staticLabelsStage) Process(labels model.LabelSet, extracted map[string]interface{}, t *time.Time, entry *string) {
+	for name, value := range s.labels {
+		labels[name] = value
+	}
+}
+
+// Name implements Stage
+func (s *staticLabelsStage) Name() string {
+	return StageTypeStaticLabels
+}
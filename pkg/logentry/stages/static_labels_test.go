@@ -0,0 +1,44 @@
+package stages
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+var testStaticLabelsYaml = `
+pipeline_stages:
+- static_labels:
+    pipeline: audit
+    version: 2
+`
+
+func TestStaticLabelsStage(t *testing.T) {
+	pl, err := NewPipeline(util.Logger, loadConfig(testStaticLabelsYaml), nil, prometheus.DefaultRegisterer)
+	require.NoError(t, err)
+
+	lbls := model.LabelSet{"job": "loki", "pipeline": "default"}
+	ts := time.Now()
+	entry := "line"
+	pl.Process(lbls, map[string]interface{}{}, &ts, &entry)
+	require.Equal(t, model.LabelSet{"job": "loki", "pipeline": "audit", "version": "2"}, lbls)
+}
+
+func TestStaticLabelsStage_Validation(t *testing.T) {
+	for _, tc := range []struct {
+		config map[string]interface{}
+		err    string
+	}{
+		{map[string]interface{}{}, ErrEmptyStaticLabelStageConfig},
+		{map[string]interface{}{"invalid-name": "audit"}, fmt.Sprintf(ErrInvalidLabelName, "invalid-name")},
+		{map[string]interface{}{"pipeline": ""}, fmt.Sprintf(ErrInvalidStaticLabelValue, "pipeline", "")},
+	} {
+		_, err := newStaticLabelsStage(tc.config)
+		require.EqualError(t, err, tc.err)
+	}
+}
@@ -14,8 +14,8 @@ import (
 )
 
 const (
-	ErrTenantStageEmptySourceOrValue        = "source or value config are required"
-	ErrTenantStageConflictingSourceAndValue = "source and value are mutually exclusive: you should set source or value but not both"
+	ErrTenantStageEmptySourceOrValue        = "source, value or label config are required"
+	ErrTenantStageConflictingSourceAndValue = "source, value and label are mutually exclusive: you should set only one of them"
 )
 
 type tenantStage struct {
@@ -26,22 +26,30 @@ type tenantStage struct {
 type TenantConfig struct {
 	Source string `mapstructure:"source"`
 	Value  string `mapstructure:"value"`
+	Label  string `mapstructure:"label"`
 }
 
 // validateTenantConfig validates the tenant stage configuration
 func validateTenantConfig(c TenantConfig) error {
-	if c.Source == "" && c.Value == "" {
+	set := 0
+	for _, option := range []string{c.Source, c.Value, c.Label} {
+		if option != "" {
+			set++
+		}
+	}
+
+	if set == 0 {
 		return errors.New(ErrTenantStageEmptySourceOrValue)
 	}
 
-	if c.Source != "" && c.Value != "" {
+	if set > 1 {
 		return errors.New(ErrTenantStageConflictingSourceAndValue)
 	}
 
 	return nil
 }
 
-// newTenantStage creates a new tenant stage to override the tenant ID from extracted data
+// newTenantStage creates a new tenant stage to override the tenant ID from extracted data or labels
 func newTenantStage(logger log.Logger, configs interface{}) (*tenantStage, error) {
 	cfg := TenantConfig{}
 	err := mapstructure.Decode(configs, &cfg)
@@ -64,14 +72,17 @@ func newTenantStage(logger log.Logger, configs interface{}) (*tenantStage, error
 func (s *tenantStage) Process(labels model.LabelSet, extracted map[string]interface{}, t *time.Time, entry *string) {
 	var tenantID string
 
-	// Get tenant ID from source or configured value
-	if s.cfg.Source != "" {
+	// Get tenant ID from source, label or configured value
+	switch {
+	case s.cfg.Source != "":
 		tenantID = s.getTenantFromSourceField(extracted)
-	} else {
+	case s.cfg.Label != "":
+		tenantID = string(labels[model.LabelName(s.cfg.Label)])
+	default:
 		tenantID = s.cfg.Value
 	}
 
-	// Skip an empty tenant ID (ie. failed to get the tenant from the source)
+	// Skip an empty tenant ID (ie. failed to get the tenant from the source or the label)
 	if tenantID == "" {
 		return
 	}
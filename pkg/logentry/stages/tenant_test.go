@@ -74,6 +74,12 @@ func TestTenantStage_Validation(t *testing.T) {
 			},
 			expectedErr: nil,
 		},
+		"should pass on label config option set": {
+			config: &TenantConfig{
+				Label: "namespace",
+			},
+			expectedErr: nil,
+		},
 		"should fail on missing source and value": {
 			config:      &TenantConfig{},
 			expectedErr: lokiutil.StringRef(ErrTenantStageEmptySourceOrValue),
@@ -97,6 +103,13 @@ func TestTenantStage_Validation(t *testing.T) {
 			},
 			expectedErr: lokiutil.StringRef(ErrTenantStageConflictingSourceAndValue),
 		},
+		"should fail on both value and label set": {
+			config: &TenantConfig{
+				Value: "team-a",
+				Label: "namespace",
+			},
+			expectedErr: lokiutil.StringRef(ErrTenantStageConflictingSourceAndValue),
+		},
 	}
 
 	for testName, testData := range tests {
@@ -161,6 +174,18 @@ func TestTenantStage_Process(t *testing.T) {
 			inputExtracted: map[string]interface{}{},
 			expectedTenant: lokiutil.StringRef("bar"),
 		},
+		"should set the tenant from the value of the label": {
+			config:         &TenantConfig{Label: "namespace"},
+			inputLabels:    model.LabelSet{"namespace": "team-a"},
+			inputExtracted: map[string]interface{}{},
+			expectedTenant: lokiutil.StringRef("team-a"),
+		},
+		"should not override the tenant if the label is missing": {
+			config:         &TenantConfig{Label: "namespace"},
+			inputLabels:    model.LabelSet{client.ReservedLabelTenantID: "foo"},
+			inputExtracted: map[string]interface{}{},
+			expectedTenant: lokiutil.StringRef("foo"),
+		},
 		"should override the tenant with the configured static value": {
 			config:         &TenantConfig{Value: "bar"},
 			inputLabels:    model.LabelSet{client.ReservedLabelTenantID: "foo"},
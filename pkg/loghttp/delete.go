@@ -0,0 +1,60 @@
+package loghttp
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+
+	"github.com/famarks/loki/pkg/logql"
+)
+
+var errDeleteQueryRequired = errors.New("query is required, it must be a stream selector")
+
+// DeleteRequest is a request to delete the log entries of the streams matching Query between Start and End.
+type DeleteRequest struct {
+	Query string
+	Start time.Time
+	End   time.Time
+}
+
+// DeleteRequestResponse is a delete request of a tenant as listed by the deletion API.
+type DeleteRequestResponse struct {
+	RequestID string     `json:"request_id"`
+	Query     string     `json:"query"`
+	StartTime model.Time `json:"start_time"`
+	EndTime   model.Time `json:"end_time"`
+	CreatedAt model.Time `json:"created_at"`
+	Status    string     `json:"status"`
+}
+
+// ParseDeleteRequest parses a DeleteRequest from an http request.
+// Start defaults to the unix epoch and End to now, deleting everything ingested so far for the selected streams.
+func ParseDeleteRequest(r *http.Request) (*DeleteRequest, error) {
+	var result DeleteRequest
+	var err error
+
+	result.Query = query(r)
+	if result.Query == "" {
+		return nil, errDeleteQueryRequired
+	}
+	if _, err := logql.ParseMatchers(result.Query); err != nil {
+		return nil, err
+	}
+
+	result.Start, err = parseTimestamp(r.Form.Get("start"), time.Unix(0, 0))
+	if err != nil {
+		return nil, err
+	}
+	result.End, err = parseTimestamp(r.Form.Get("end"), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if result.End.Before(result.Start) || result.Start.Equal(result.End) {
+		return nil, errEndBeforeStart
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,67 @@
+package loghttp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDeleteRequest(t *testing.T) {
+	for _, tc := range []struct {
+		desc      string
+		form      url.Values
+		shouldErr bool
+		expected  *DeleteRequest
+	}{
+		{
+			"valid",
+			url.Values{
+				"query": []string{`{app="foo"}`},
+				"start": []string{"1000"},
+				"end":   []string{"2000"},
+			},
+			false,
+			&DeleteRequest{Query: `{app="foo"}`, Start: time.Unix(1000, 0), End: time.Unix(2000, 0)},
+		},
+		{
+			"missing query",
+			url.Values{"start": []string{"1000"}, "end": []string{"2000"}},
+			true,
+			nil,
+		},
+		{
+			"not a selector",
+			url.Values{"query": []string{`{app="foo"} |= "bar"`}},
+			true,
+			nil,
+		},
+		{
+			"end before start",
+			url.Values{
+				"query": []string{`{app="foo"}`},
+				"start": []string{"2000"},
+				"end":   []string{"1000"},
+			},
+			true,
+			nil,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			out, err := ParseDeleteRequest(withForm(tc.form))
+			if tc.shouldErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, out)
+		})
+	}
+
+	// start and end default to the unix epoch and now.
+	out, err := ParseDeleteRequest(withForm(url.Values{"query": []string{`{app="foo"}`}}))
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(0, 0), out.Start)
+	require.WithinDuration(t, time.Now(), out.End, time.Minute)
+}
@@ -6,6 +6,7 @@ package logql
 import __yyfmt__ "fmt"
 
 //line pkg/logql/expr.y:2
+
 import (
 	"github.com/famarks/loki/pkg/logql/log"
 	"github.com/prometheus/prometheus/pkg/labels"
@@ -106,21 +107,22 @@ const STDDEV_OVER_TIME = 57395
 const QUANTILE_OVER_TIME = 57396
 const DURATION_CONV = 57397
 const DURATION_SECONDS_CONV = 57398
-const OR = 57399
-const AND = 57400
-const UNLESS = 57401
-const CMP_EQ = 57402
-const NEQ = 57403
-const LT = 57404
-const LTE = 57405
-const GT = 57406
-const GTE = 57407
-const ADD = 57408
-const SUB = 57409
-const MUL = 57410
-const DIV = 57411
-const MOD = 57412
-const POW = 57413
+const UNPACK = 57399
+const OR = 57400
+const AND = 57401
+const UNLESS = 57402
+const CMP_EQ = 57403
+const NEQ = 57404
+const LT = 57405
+const LTE = 57406
+const GT = 57407
+const GTE = 57408
+const ADD = 57409
+const SUB = 57410
+const MUL = 57411
+const DIV = 57412
+const MOD = 57413
+const POW = 57414
 
 var exprToknames = [...]string{
 	"$end",
@@ -179,6 +181,7 @@ var exprToknames = [...]string{
 	"QUANTILE_OVER_TIME",
 	"DURATION_CONV",
 	"DURATION_SECONDS_CONV",
+	"UNPACK",
 	"OR",
 	"AND",
 	"UNLESS",
@@ -195,13 +198,14 @@ var exprToknames = [...]string{
 	"MOD",
 	"POW",
 }
+
 var exprStatenames = [...]string{}
 
 const exprEofCode = 1
 const exprErrCode = 2
 const exprInitialStackSize = 16
 
-//line pkg/logql/expr.y:345
+//line pkg/logql/expr.y:346
 
 //line yacctab:1
 var exprExca = [...]int{
@@ -212,187 +216,186 @@ var exprExca = [...]int{
 
 const exprPrivate = 57344
 
-const exprLast = 396
+const exprLast = 398
 
 var exprAct = [...]int{
-
-	70, 171, 53, 153, 145, 4, 179, 100, 63, 2,
-	52, 45, 61, 56, 5, 217, 120, 214, 235, 66,
-	14, 40, 41, 42, 43, 44, 45, 249, 11, 42,
-	43, 44, 45, 252, 76, 235, 6, 213, 256, 244,
+	70, 172, 53, 154, 146, 4, 180, 101, 63, 2,
+	52, 45, 61, 56, 5, 218, 121, 215, 250, 66,
+	14, 40, 41, 42, 43, 44, 45, 76, 11, 42,
+	43, 44, 45, 226, 236, 257, 6, 214, 228, 236,
 	17, 18, 28, 29, 31, 32, 30, 33, 34, 35,
-	36, 19, 20, 214, 225, 91, 116, 118, 119, 227,
+	36, 19, 20, 237, 253, 91, 117, 119, 120, 245,
 	94, 21, 22, 23, 24, 25, 26, 27, 92, 215,
-	214, 224, 214, 247, 59, 124, 155, 118, 119, 15,
-	16, 57, 58, 122, 129, 176, 130, 131, 132, 133,
-	134, 135, 136, 137, 138, 139, 140, 141, 142, 143,
-	69, 128, 71, 72, 173, 117, 71, 72, 150, 46,
-	47, 50, 51, 48, 49, 40, 41, 42, 43, 44,
-	45, 60, 162, 59, 161, 156, 159, 160, 157, 158,
-	57, 58, 178, 172, 225, 181, 170, 11, 174, 226,
-	175, 59, 111, 110, 127, 123, 126, 68, 57, 58,
-	186, 182, 183, 184, 37, 38, 39, 46, 47, 50,
-	51, 48, 49, 40, 41, 42, 43, 44, 45, 209,
-	60, 173, 211, 177, 216, 91, 219, 222, 94, 169,
-	115, 212, 187, 223, 122, 220, 210, 167, 60, 167,
-	228, 38, 39, 46, 47, 50, 51, 48, 49, 40,
-	41, 42, 43, 44, 45, 255, 213, 251, 106, 232,
-	106, 221, 167, 250, 233, 91, 236, 240, 106, 234,
-	166, 241, 243, 91, 147, 215, 103, 125, 103, 185,
-	59, 74, 147, 246, 168, 11, 103, 57, 58, 73,
-	242, 214, 248, 6, 165, 253, 164, 17, 18, 28,
-	29, 31, 32, 30, 33, 34, 35, 36, 19, 20,
-	173, 106, 148, 146, 230, 231, 238, 239, 21, 22,
-	23, 24, 25, 26, 27, 59, 170, 60, 163, 103,
-	151, 59, 57, 58, 149, 59, 15, 16, 57, 58,
-	113, 218, 57, 58, 75, 106, 106, 97, 99, 98,
-	121, 104, 105, 217, 112, 173, 144, 114, 11, 147,
-	147, 173, 109, 103, 103, 55, 123, 192, 101, 164,
-	193, 191, 60, 189, 3, 163, 190, 188, 60, 254,
-	245, 62, 60, 77, 78, 79, 80, 81, 82, 83,
-	84, 85, 86, 87, 88, 89, 90, 148, 146, 146,
-	106, 207, 152, 204, 208, 206, 205, 203, 201, 96,
-	198, 202, 200, 199, 197, 180, 195, 95, 103, 196,
-	194, 229, 65, 67, 154, 67, 154, 54, 107, 102,
-	108, 93, 10, 9, 13, 8, 97, 99, 98, 237,
-	104, 105, 12, 7, 64, 1,
+	214, 225, 215, 248, 215, 125, 156, 119, 120, 177,
+	15, 16, 112, 123, 130, 242, 131, 132, 133, 134,
+	135, 136, 137, 138, 139, 140, 141, 142, 143, 144,
+	59, 71, 72, 239, 240, 215, 118, 57, 58, 151,
+	46, 47, 50, 51, 48, 49, 40, 41, 42, 43,
+	44, 45, 111, 163, 11, 162, 157, 160, 161, 158,
+	159, 129, 124, 179, 173, 226, 182, 216, 128, 175,
+	227, 176, 59, 69, 127, 71, 72, 68, 60, 57,
+	58, 187, 183, 184, 185, 37, 38, 39, 46, 47,
+	50, 51, 48, 49, 40, 41, 42, 43, 44, 45,
+	210, 178, 174, 212, 170, 217, 91, 220, 223, 94,
+	116, 59, 213, 188, 224, 123, 221, 211, 57, 58,
+	60, 229, 38, 39, 46, 47, 50, 51, 48, 49,
+	40, 41, 42, 43, 44, 45, 122, 107, 168, 107,
+	168, 174, 256, 168, 11, 234, 91, 255, 252, 251,
+	235, 148, 124, 244, 91, 104, 216, 104, 126, 60,
+	233, 59, 222, 241, 247, 169, 11, 74, 57, 58,
+	73, 243, 230, 249, 6, 155, 254, 167, 17, 18,
+	28, 29, 31, 32, 30, 33, 34, 35, 36, 19,
+	20, 174, 107, 193, 166, 165, 194, 192, 165, 21,
+	22, 23, 24, 25, 26, 27, 164, 59, 171, 60,
+	104, 152, 171, 59, 57, 58, 150, 59, 15, 16,
+	57, 58, 145, 219, 57, 58, 110, 75, 97, 99,
+	98, 107, 105, 106, 218, 231, 232, 55, 107, 107,
+	246, 3, 181, 174, 100, 148, 114, 174, 62, 104,
+	186, 65, 148, 148, 67, 60, 104, 104, 67, 155,
+	113, 60, 107, 115, 102, 60, 77, 78, 79, 80,
+	81, 82, 83, 84, 85, 86, 87, 88, 89, 90,
+	104, 153, 96, 95, 149, 147, 190, 54, 164, 191,
+	189, 149, 147, 147, 108, 103, 109, 93, 97, 99,
+	98, 208, 105, 106, 209, 207, 205, 10, 202, 206,
+	204, 203, 201, 199, 100, 196, 200, 198, 197, 195,
+	9, 13, 8, 238, 12, 7, 64, 1,
 }
-var exprPact = [...]int{
 
-	13, -1000, 97, -1000, -1000, 271, 13, -1000, -1000, -1000,
-	-1000, 370, 124, 77, -1000, 232, 224, -1000, -1000, -1000,
+var exprPact = [...]int{
+	13, -1000, 97, -1000, -1000, 263, 13, -1000, -1000, -1000,
+	-1000, 319, 124, 120, -1000, 233, 230, -1000, -1000, -1000,
 	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
-	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -6, -6, -6,
-	-6, -6, -6, -6, -6, -6, -6, -6, -6, -6,
-	-6, -6, 271, -1000, 109, 345, 306, -1000, -1000, -1000,
-	-1000, 119, 118, 97, 288, 164, -1000, 44, 293, 220,
-	123, 121, 78, -1000, -1000, 13, -1000, 13, 13, 13,
+	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -13, -13, -13,
+	-13, -13, -13, -13, -13, -13, -13, -13, -13, -13,
+	-13, -13, 263, -1000, 86, 327, 290, -1000, -1000, -1000,
+	-1000, 98, 58, 97, 314, 164, -1000, 44, 199, 221,
+	121, 115, 108, -1000, -1000, 13, -1000, 13, 13, 13,
 	13, 13, 13, 13, 13, 13, 13, 13, 13, 13,
-	13, -1000, 300, -1000, 290, -1000, -1000, -1000, -1000, 278,
-	-1000, -1000, -1000, 203, 274, 371, 64, -1000, -1000, -1000,
-	-1000, -1000, -1000, -1000, 368, -1000, 272, 240, 238, 214,
-	210, 160, 127, 122, 61, 154, 13, 360, 360, 133,
-	49, 49, -39, -39, -60, -60, -60, -60, -45, -45,
-	-45, -45, -45, -45, -1000, 290, 203, 203, 203, -1000,
-	205, -1000, 131, -1000, 170, 319, 313, 362, 356, 354,
-	349, 347, -1000, -1000, -1000, -1000, -1000, -1000, 81, 122,
-	261, 28, 60, 256, 267, 187, 81, 13, 47, 115,
-	-1000, 35, 213, 290, 291, -1000, 369, 259, -1000, -1000,
+	13, -1000, 286, -1000, 303, -1000, -1000, -1000, -1000, 280,
+	-1000, -1000, -1000, -1000, 204, 275, 324, 64, -1000, -1000,
+	-1000, -1000, -1000, -1000, -1000, 323, -1000, 270, 262, 258,
+	241, 211, 155, 273, 109, 55, 152, 13, 307, 307,
+	133, 49, 49, -40, -40, -61, -61, -61, -61, -46,
+	-46, -46, -46, -46, -46, -1000, 303, 204, 204, 204,
+	-1000, 296, -1000, 132, -1000, 171, 352, 259, 381, 379,
+	374, 372, 367, -1000, -1000, -1000, -1000, -1000, -1000, 76,
+	109, 167, 28, 128, 257, 269, 208, 76, 13, 47,
+	116, -1000, 14, 202, 303, 304, -1000, 240, 300, -1000,
 	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
 	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
-	185, -27, 261, -1000, 203, -1000, 26, 211, 208, 197,
-	216, -1000, -1000, 15, -1000, 325, -1000, -1000, -1000, -1000,
-	-1000, -1000, 81, -27, 290, -1000, -1000, 50, -1000, -1000,
-	-17, 204, 198, 9, 81, -1000, -1000, 324, -27, -32,
-	-1000, -1000, 196, -1000, 14, -1000, -1000,
+	-1000, 206, -27, 167, -1000, 204, -1000, 25, 48, 224,
+	61, 217, -1000, -1000, 35, -1000, 305, -1000, -1000, -1000,
+	-1000, -1000, -1000, 76, -27, 303, -1000, -1000, 50, -1000,
+	-1000, -26, 210, 209, 30, 76, -1000, -1000, 212, -27,
+	-32, -1000, -1000, 203, -1000, 11, -1000, -1000,
 }
-var exprPgo = [...]int{
 
-	0, 395, 8, 13, 0, 6, 324, 5, 16, 7,
-	394, 393, 392, 389, 14, 385, 384, 383, 382, 294,
-	381, 10, 2, 380, 379, 378, 4, 377, 367, 359,
-	3, 352, 1, 318,
+var exprPgo = [...]int{
+	0, 397, 8, 13, 0, 6, 311, 5, 16, 7,
+	396, 395, 394, 393, 14, 392, 391, 390, 377, 297,
+	367, 10, 2, 366, 365, 364, 4, 357, 353, 352,
+	3, 351, 1, 334,
 }
-var exprR1 = [...]int{
 
+var exprR1 = [...]int{
 	0, 1, 2, 2, 7, 7, 7, 7, 7, 6,
 	6, 6, 8, 8, 8, 8, 8, 8, 8, 8,
 	8, 8, 8, 8, 8, 8, 32, 32, 32, 13,
 	13, 11, 11, 11, 11, 15, 15, 15, 15, 15,
 	3, 3, 3, 3, 14, 14, 14, 10, 10, 9,
 	9, 9, 9, 21, 21, 22, 22, 22, 22, 22,
-	27, 27, 20, 20, 20, 28, 30, 30, 31, 31,
-	31, 29, 26, 26, 26, 26, 26, 26, 26, 26,
-	33, 33, 25, 25, 25, 25, 25, 25, 25, 23,
-	23, 23, 23, 23, 23, 23, 24, 24, 24, 24,
-	24, 24, 24, 17, 17, 17, 17, 17, 17, 17,
-	17, 17, 17, 17, 17, 17, 17, 17, 19, 19,
-	18, 18, 18, 16, 16, 16, 16, 16, 16, 16,
-	16, 16, 12, 12, 12, 12, 12, 12, 12, 12,
-	12, 12, 12, 5, 5, 4, 4,
+	27, 27, 20, 20, 20, 20, 28, 30, 30, 31,
+	31, 31, 29, 26, 26, 26, 26, 26, 26, 26,
+	26, 33, 33, 25, 25, 25, 25, 25, 25, 25,
+	23, 23, 23, 23, 23, 23, 23, 24, 24, 24,
+	24, 24, 24, 24, 17, 17, 17, 17, 17, 17,
+	17, 17, 17, 17, 17, 17, 17, 17, 17, 19,
+	19, 18, 18, 18, 16, 16, 16, 16, 16, 16,
+	16, 16, 16, 12, 12, 12, 12, 12, 12, 12,
+	12, 12, 12, 12, 5, 5, 4, 4,
 }
-var exprR2 = [...]int{
 
+var exprR2 = [...]int{
 	0, 1, 1, 1, 1, 1, 1, 1, 3, 1,
 	2, 3, 2, 4, 3, 5, 3, 5, 3, 5,
 	4, 6, 3, 4, 3, 2, 3, 6, 3, 1,
 	1, 4, 6, 5, 7, 4, 5, 5, 6, 7,
 	1, 1, 1, 1, 3, 3, 3, 1, 3, 3,
 	3, 3, 3, 1, 2, 1, 2, 2, 2, 2,
-	2, 3, 1, 1, 2, 2, 3, 3, 1, 3,
-	3, 2, 1, 1, 1, 3, 2, 3, 3, 3,
-	1, 1, 3, 3, 3, 3, 3, 3, 3, 3,
+	2, 3, 1, 1, 2, 1, 2, 3, 3, 1,
+	3, 3, 2, 1, 1, 1, 3, 2, 3, 3,
+	3, 1, 1, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 3, 3, 4, 4, 4, 4, 4, 4, 4,
-	4, 4, 4, 4, 4, 4, 4, 4, 0, 1,
-	1, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+	3, 3, 3, 3, 4, 4, 4, 4, 4, 4,
+	4, 4, 4, 4, 4, 4, 4, 4, 4, 0,
+	1, 1, 2, 2, 1, 1, 1, 1, 1, 1,
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
-	1, 1, 1, 1, 3, 4, 4,
+	1, 1, 1, 1, 1, 3, 4, 4,
 }
-var exprChk = [...]int{
 
+var exprChk = [...]int{
 	-1000, -1, -2, -6, -7, -14, 23, -11, -15, -17,
-	-18, 15, -12, -16, 7, 66, 67, 27, 28, 38,
+	-18, 15, -12, -16, 7, 67, 68, 27, 28, 38,
 	39, 48, 49, 50, 51, 52, 53, 54, 29, 30,
-	33, 31, 32, 34, 35, 36, 37, 57, 58, 59,
-	66, 67, 68, 69, 70, 71, 60, 61, 64, 65,
-	62, 63, -21, -22, -27, 44, -3, 21, 22, 14,
-	61, -7, -6, -2, -10, 2, -9, 5, 23, 23,
+	33, 31, 32, 34, 35, 36, 37, 58, 59, 60,
+	67, 68, 69, 70, 71, 72, 61, 62, 65, 66,
+	63, 64, -21, -22, -27, 44, -3, 21, 22, 14,
+	62, -7, -6, -2, -10, 2, -9, 5, 23, 23,
 	-4, 25, 26, 7, 7, -19, 40, -19, -19, -19,
 	-19, -19, -19, -19, -19, -19, -19, -19, -19, -19,
 	-19, -22, -3, -20, -26, -28, -29, 41, 43, 42,
-	-9, -33, -24, 23, 45, 46, 5, -25, -23, 6,
-	24, 24, 16, 2, 19, 16, 12, 61, 13, 14,
-	-8, 7, -14, 23, -7, 7, 23, 23, 23, -2,
+	57, -9, -33, -24, 23, 45, 46, 5, -25, -23,
+	6, 24, 24, 16, 2, 19, 16, 12, 62, 13,
+	14, -8, 7, -14, 23, -7, 7, 23, 23, 23,
 	-2, -2, -2, -2, -2, -2, -2, -2, -2, -2,
-	-2, -2, -2, -2, 6, -26, 58, 19, 57, 6,
-	-26, 6, -31, -30, 5, 12, 61, 64, 65, 62,
-	63, 60, -9, 6, 6, 6, 6, 2, 24, 19,
-	9, -32, -21, 44, -14, -8, 24, 19, -7, -5,
-	5, -5, -26, -26, -26, 24, 19, 12, 8, 4,
+	-2, -2, -2, -2, -2, 6, -26, 59, 19, 58,
+	6, -26, 6, -31, -30, 5, 12, 62, 65, 66,
+	63, 64, 61, -9, 6, 6, 6, 6, 2, 24,
+	19, 9, -32, -21, 44, -14, -8, 24, 19, -7,
+	-5, 5, -5, -26, -26, -26, 24, 19, 12, 8,
+	4, 7, 8, 4, 7, 8, 4, 7, 8, 4,
 	7, 8, 4, 7, 8, 4, 7, 8, 4, 7,
-	8, 4, 7, 8, 4, 7, 8, 4, 7, -4,
-	-8, -32, -21, 9, 44, 9, -32, 47, 24, -32,
-	-21, 24, -4, -7, 24, 19, 24, 24, -30, 2,
-	5, 6, 24, -32, -26, 9, 5, -13, 55, 56,
-	9, 24, 24, -32, 24, 5, -4, 23, -32, 44,
-	9, 9, 24, -4, 5, 9, 24,
+	-4, -8, -32, -21, 9, 44, 9, -32, 47, 24,
+	-32, -21, 24, -4, -7, 24, 19, 24, 24, -30,
+	2, 5, 6, 24, -32, -26, 9, 5, -13, 55,
+	56, 9, 24, 24, -32, 24, 5, -4, 23, -32,
+	44, 9, 9, 24, -4, 5, 9, 24,
 }
-var exprDef = [...]int{
 
+var exprDef = [...]int{
 	0, -2, 1, 2, 3, 9, 0, 4, 5, 6,
-	7, 0, 0, 0, 120, 0, 0, 132, 133, 134,
-	135, 136, 137, 138, 139, 140, 141, 142, 123, 124,
-	125, 126, 127, 128, 129, 130, 131, 118, 118, 118,
-	118, 118, 118, 118, 118, 118, 118, 118, 118, 118,
-	118, 118, 10, 53, 55, 0, 0, 40, 41, 42,
+	7, 0, 0, 0, 121, 0, 0, 133, 134, 135,
+	136, 137, 138, 139, 140, 141, 142, 143, 124, 125,
+	126, 127, 128, 129, 130, 131, 132, 119, 119, 119,
+	119, 119, 119, 119, 119, 119, 119, 119, 119, 119,
+	119, 119, 10, 53, 55, 0, 0, 40, 41, 42,
 	43, 3, 2, 0, 0, 0, 47, 0, 0, 0,
-	0, 0, 0, 121, 122, 0, 119, 0, 0, 0,
+	0, 0, 0, 122, 123, 0, 120, 0, 0, 0,
 	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
 	0, 54, 0, 56, 57, 58, 59, 62, 63, 0,
-	72, 73, 74, 0, 0, 0, 0, 80, 81, 60,
-	8, 11, 44, 45, 0, 46, 0, 0, 0, 0,
-	0, 0, 0, 0, 3, 120, 0, 0, 0, 103,
+	65, 73, 74, 75, 0, 0, 0, 0, 81, 82,
+	60, 8, 11, 44, 45, 0, 46, 0, 0, 0,
+	0, 0, 0, 0, 0, 3, 121, 0, 0, 0,
 	104, 105, 106, 107, 108, 109, 110, 111, 112, 113,
-	114, 115, 116, 117, 61, 76, 0, 0, 0, 64,
-	0, 65, 71, 68, 0, 0, 0, 0, 0, 0,
-	0, 0, 48, 49, 50, 51, 52, 25, 31, 0,
-	12, 0, 0, 0, 0, 0, 35, 0, 3, 0,
-	143, 0, 77, 78, 79, 75, 0, 0, 87, 94,
-	101, 86, 93, 100, 82, 89, 96, 83, 90, 97,
-	84, 91, 98, 85, 92, 99, 88, 95, 102, 33,
-	0, 14, 22, 16, 0, 18, 0, 0, 0, 0,
-	0, 24, 37, 3, 36, 0, 145, 146, 69, 70,
-	66, 67, 32, 23, 28, 20, 26, 0, 29, 30,
-	13, 0, 0, 0, 38, 144, 34, 0, 15, 0,
-	17, 19, 0, 39, 0, 21, 27,
+	114, 115, 116, 117, 118, 61, 77, 0, 0, 0,
+	64, 0, 66, 72, 69, 0, 0, 0, 0, 0,
+	0, 0, 0, 48, 49, 50, 51, 52, 25, 31,
+	0, 12, 0, 0, 0, 0, 0, 35, 0, 3,
+	0, 144, 0, 78, 79, 80, 76, 0, 0, 88,
+	95, 102, 87, 94, 101, 83, 90, 97, 84, 91,
+	98, 85, 92, 99, 86, 93, 100, 89, 96, 103,
+	33, 0, 14, 22, 16, 0, 18, 0, 0, 0,
+	0, 0, 24, 37, 3, 36, 0, 146, 147, 70,
+	71, 67, 68, 32, 23, 28, 20, 26, 0, 29,
+	30, 13, 0, 0, 0, 38, 145, 34, 0, 15,
+	0, 17, 19, 0, 39, 0, 21, 27,
 }
-var exprTok1 = [...]int{
 
+var exprTok1 = [...]int{
 	1,
 }
-var exprTok2 = [...]int{
 
+var exprTok2 = [...]int{
 	2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
 	12, 13, 14, 15, 16, 17, 18, 19, 20, 21,
 	22, 23, 24, 25, 26, 27, 28, 29, 30, 31,
@@ -400,7 +403,9 @@ var exprTok2 = [...]int{
 	42, 43, 44, 45, 46, 47, 48, 49, 50, 51,
 	52, 53, 54, 55, 56, 57, 58, 59, 60, 61,
 	62, 63, 64, 65, 66, 67, 68, 69, 70, 71,
+	72,
 }
+
 var exprTok3 = [...]int{
 	0,
 }
@@ -1120,76 +1125,76 @@ exprdefault:
 			exprVAL.LabelParser = newLabelParserExpr(OpParserTypeRegexp, exprDollar[2].str)
 		}
 	case 65:
+		exprDollar = exprS[exprpt-1 : exprpt+1]
+//line pkg/logql/expr.y:216
+		{
+			exprVAL.LabelParser = newLabelParserExpr(OpParserTypeUnpack, "")
+		}
+	case 66:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line pkg/logql/expr.y:218
+//line pkg/logql/expr.y:219
 		{
 			exprVAL.LineFormatExpr = newLineFmtExpr(exprDollar[2].str)
 		}
-	case 66:
+	case 67:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line pkg/logql/expr.y:221
+//line pkg/logql/expr.y:222
 		{
 			exprVAL.LabelFormat = log.NewRenameLabelFmt(exprDollar[1].str, exprDollar[3].str)
 		}
-	case 67:
+	case 68:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line pkg/logql/expr.y:222
+//line pkg/logql/expr.y:223
 		{
 			exprVAL.LabelFormat = log.NewTemplateLabelFmt(exprDollar[1].str, exprDollar[3].str)
 		}
-	case 68:
+	case 69:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line pkg/logql/expr.y:226
+//line pkg/logql/expr.y:227
 		{
 			exprVAL.LabelsFormat = []log.LabelFmt{exprDollar[1].LabelFormat}
 		}
-	case 69:
+	case 70:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line pkg/logql/expr.y:227
+//line pkg/logql/expr.y:228
 		{
 			exprVAL.LabelsFormat = append(exprDollar[1].LabelsFormat, exprDollar[3].LabelFormat)
 		}
-	case 71:
+	case 72:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line pkg/logql/expr.y:231
+//line pkg/logql/expr.y:232
 		{
 			exprVAL.LabelFormatExpr = newLabelFmtExpr(exprDollar[2].LabelsFormat)
 		}
-	case 72:
-		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line pkg/logql/expr.y:234
-		{
-			exprVAL.LabelFilter = log.NewStringLabelFilter(exprDollar[1].Matcher)
-		}
 	case 73:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:235
 		{
-			exprVAL.LabelFilter = exprDollar[1].UnitFilter
+			exprVAL.LabelFilter = log.NewStringLabelFilter(exprDollar[1].Matcher)
 		}
 	case 74:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:236
 		{
-			exprVAL.LabelFilter = exprDollar[1].NumberFilter
+			exprVAL.LabelFilter = exprDollar[1].UnitFilter
 		}
 	case 75:
-		exprDollar = exprS[exprpt-3 : exprpt+1]
+		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:237
 		{
-			exprVAL.LabelFilter = exprDollar[2].LabelFilter
+			exprVAL.LabelFilter = exprDollar[1].NumberFilter
 		}
 	case 76:
-		exprDollar = exprS[exprpt-2 : exprpt+1]
+		exprDollar = exprS[exprpt-3 : exprpt+1]
 //line pkg/logql/expr.y:238
 		{
-			exprVAL.LabelFilter = log.NewAndLabelFilter(exprDollar[1].LabelFilter, exprDollar[2].LabelFilter)
+			exprVAL.LabelFilter = exprDollar[2].LabelFilter
 		}
 	case 77:
-		exprDollar = exprS[exprpt-3 : exprpt+1]
+		exprDollar = exprS[exprpt-2 : exprpt+1]
 //line pkg/logql/expr.y:239
 		{
-			exprVAL.LabelFilter = log.NewAndLabelFilter(exprDollar[1].LabelFilter, exprDollar[3].LabelFilter)
+			exprVAL.LabelFilter = log.NewAndLabelFilter(exprDollar[1].LabelFilter, exprDollar[2].LabelFilter)
 		}
 	case 78:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
@@ -1201,55 +1206,55 @@ exprdefault:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 //line pkg/logql/expr.y:241
 		{
-			exprVAL.LabelFilter = log.NewOrLabelFilter(exprDollar[1].LabelFilter, exprDollar[3].LabelFilter)
+			exprVAL.LabelFilter = log.NewAndLabelFilter(exprDollar[1].LabelFilter, exprDollar[3].LabelFilter)
 		}
 	case 80:
-		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line pkg/logql/expr.y:245
+		exprDollar = exprS[exprpt-3 : exprpt+1]
+//line pkg/logql/expr.y:242
 		{
-			exprVAL.UnitFilter = exprDollar[1].DurationFilter
+			exprVAL.LabelFilter = log.NewOrLabelFilter(exprDollar[1].LabelFilter, exprDollar[3].LabelFilter)
 		}
 	case 81:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:246
 		{
-			exprVAL.UnitFilter = exprDollar[1].BytesFilter
+			exprVAL.UnitFilter = exprDollar[1].DurationFilter
 		}
 	case 82:
-		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line pkg/logql/expr.y:249
+		exprDollar = exprS[exprpt-1 : exprpt+1]
+//line pkg/logql/expr.y:247
 		{
-			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterGreaterThan, exprDollar[1].str, exprDollar[3].duration)
+			exprVAL.UnitFilter = exprDollar[1].BytesFilter
 		}
 	case 83:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 //line pkg/logql/expr.y:250
 		{
-			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterGreaterThanOrEqual, exprDollar[1].str, exprDollar[3].duration)
+			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterGreaterThan, exprDollar[1].str, exprDollar[3].duration)
 		}
 	case 84:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 //line pkg/logql/expr.y:251
 		{
-			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterLesserThan, exprDollar[1].str, exprDollar[3].duration)
+			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterGreaterThanOrEqual, exprDollar[1].str, exprDollar[3].duration)
 		}
 	case 85:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 //line pkg/logql/expr.y:252
 		{
-			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterLesserThanOrEqual, exprDollar[1].str, exprDollar[3].duration)
+			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterLesserThan, exprDollar[1].str, exprDollar[3].duration)
 		}
 	case 86:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 //line pkg/logql/expr.y:253
 		{
-			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterNotEqual, exprDollar[1].str, exprDollar[3].duration)
+			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterLesserThanOrEqual, exprDollar[1].str, exprDollar[3].duration)
 		}
 	case 87:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 //line pkg/logql/expr.y:254
 		{
-			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterEqual, exprDollar[1].str, exprDollar[3].duration)
+			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterNotEqual, exprDollar[1].str, exprDollar[3].duration)
 		}
 	case 88:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
@@ -1259,39 +1264,39 @@ exprdefault:
 		}
 	case 89:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line pkg/logql/expr.y:259
+//line pkg/logql/expr.y:256
 		{
-			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterGreaterThan, exprDollar[1].str, exprDollar[3].bytes)
+			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterEqual, exprDollar[1].str, exprDollar[3].duration)
 		}
 	case 90:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 //line pkg/logql/expr.y:260
 		{
-			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterGreaterThanOrEqual, exprDollar[1].str, exprDollar[3].bytes)
+			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterGreaterThan, exprDollar[1].str, exprDollar[3].bytes)
 		}
 	case 91:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 //line pkg/logql/expr.y:261
 		{
-			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterLesserThan, exprDollar[1].str, exprDollar[3].bytes)
+			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterGreaterThanOrEqual, exprDollar[1].str, exprDollar[3].bytes)
 		}
 	case 92:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 //line pkg/logql/expr.y:262
 		{
-			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterLesserThanOrEqual, exprDollar[1].str, exprDollar[3].bytes)
+			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterLesserThan, exprDollar[1].str, exprDollar[3].bytes)
 		}
 	case 93:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 //line pkg/logql/expr.y:263
 		{
-			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterNotEqual, exprDollar[1].str, exprDollar[3].bytes)
+			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterLesserThanOrEqual, exprDollar[1].str, exprDollar[3].bytes)
 		}
 	case 94:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 //line pkg/logql/expr.y:264
 		{
-			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterEqual, exprDollar[1].str, exprDollar[3].bytes)
+			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterNotEqual, exprDollar[1].str, exprDollar[3].bytes)
 		}
 	case 95:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
@@ -1301,39 +1306,39 @@ exprdefault:
 		}
 	case 96:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line pkg/logql/expr.y:269
+//line pkg/logql/expr.y:266
 		{
-			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterGreaterThan, exprDollar[1].str, mustNewFloat(exprDollar[3].str))
+			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterEqual, exprDollar[1].str, exprDollar[3].bytes)
 		}
 	case 97:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 //line pkg/logql/expr.y:270
 		{
-			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterGreaterThanOrEqual, exprDollar[1].str, mustNewFloat(exprDollar[3].str))
+			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterGreaterThan, exprDollar[1].str, mustNewFloat(exprDollar[3].str))
 		}
 	case 98:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 //line pkg/logql/expr.y:271
 		{
-			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterLesserThan, exprDollar[1].str, mustNewFloat(exprDollar[3].str))
+			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterGreaterThanOrEqual, exprDollar[1].str, mustNewFloat(exprDollar[3].str))
 		}
 	case 99:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 //line pkg/logql/expr.y:272
 		{
-			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterLesserThanOrEqual, exprDollar[1].str, mustNewFloat(exprDollar[3].str))
+			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterLesserThan, exprDollar[1].str, mustNewFloat(exprDollar[3].str))
 		}
 	case 100:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 //line pkg/logql/expr.y:273
 		{
-			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterNotEqual, exprDollar[1].str, mustNewFloat(exprDollar[3].str))
+			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterLesserThanOrEqual, exprDollar[1].str, mustNewFloat(exprDollar[3].str))
 		}
 	case 101:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
 //line pkg/logql/expr.y:274
 		{
-			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterEqual, exprDollar[1].str, mustNewFloat(exprDollar[3].str))
+			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterNotEqual, exprDollar[1].str, mustNewFloat(exprDollar[3].str))
 		}
 	case 102:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
@@ -1342,266 +1347,272 @@ exprdefault:
 			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterEqual, exprDollar[1].str, mustNewFloat(exprDollar[3].str))
 		}
 	case 103:
-		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line pkg/logql/expr.y:281
+		exprDollar = exprS[exprpt-3 : exprpt+1]
+//line pkg/logql/expr.y:276
 		{
-			exprVAL.BinOpExpr = mustNewBinOpExpr("or", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
+			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterEqual, exprDollar[1].str, mustNewFloat(exprDollar[3].str))
 		}
 	case 104:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
 //line pkg/logql/expr.y:282
 		{
-			exprVAL.BinOpExpr = mustNewBinOpExpr("and", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
+			exprVAL.BinOpExpr = mustNewBinOpExpr("or", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
 	case 105:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
 //line pkg/logql/expr.y:283
 		{
-			exprVAL.BinOpExpr = mustNewBinOpExpr("unless", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
+			exprVAL.BinOpExpr = mustNewBinOpExpr("and", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
 	case 106:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
 //line pkg/logql/expr.y:284
 		{
-			exprVAL.BinOpExpr = mustNewBinOpExpr("+", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
+			exprVAL.BinOpExpr = mustNewBinOpExpr("unless", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
 	case 107:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
 //line pkg/logql/expr.y:285
 		{
-			exprVAL.BinOpExpr = mustNewBinOpExpr("-", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
+			exprVAL.BinOpExpr = mustNewBinOpExpr("+", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
 	case 108:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
 //line pkg/logql/expr.y:286
 		{
-			exprVAL.BinOpExpr = mustNewBinOpExpr("*", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
+			exprVAL.BinOpExpr = mustNewBinOpExpr("-", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
 	case 109:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
 //line pkg/logql/expr.y:287
 		{
-			exprVAL.BinOpExpr = mustNewBinOpExpr("/", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
+			exprVAL.BinOpExpr = mustNewBinOpExpr("*", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
 	case 110:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
 //line pkg/logql/expr.y:288
 		{
-			exprVAL.BinOpExpr = mustNewBinOpExpr("%", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
+			exprVAL.BinOpExpr = mustNewBinOpExpr("/", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
 	case 111:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
 //line pkg/logql/expr.y:289
 		{
-			exprVAL.BinOpExpr = mustNewBinOpExpr("^", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
+			exprVAL.BinOpExpr = mustNewBinOpExpr("%", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
 	case 112:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
 //line pkg/logql/expr.y:290
 		{
-			exprVAL.BinOpExpr = mustNewBinOpExpr("==", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
+			exprVAL.BinOpExpr = mustNewBinOpExpr("^", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
 	case 113:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
 //line pkg/logql/expr.y:291
 		{
-			exprVAL.BinOpExpr = mustNewBinOpExpr("!=", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
+			exprVAL.BinOpExpr = mustNewBinOpExpr("==", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
 	case 114:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
 //line pkg/logql/expr.y:292
 		{
-			exprVAL.BinOpExpr = mustNewBinOpExpr(">", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
+			exprVAL.BinOpExpr = mustNewBinOpExpr("!=", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
 	case 115:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
 //line pkg/logql/expr.y:293
 		{
-			exprVAL.BinOpExpr = mustNewBinOpExpr(">=", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
+			exprVAL.BinOpExpr = mustNewBinOpExpr(">", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
 	case 116:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
 //line pkg/logql/expr.y:294
 		{
-			exprVAL.BinOpExpr = mustNewBinOpExpr("<", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
+			exprVAL.BinOpExpr = mustNewBinOpExpr(">=", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
 	case 117:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
 //line pkg/logql/expr.y:295
 		{
-			exprVAL.BinOpExpr = mustNewBinOpExpr("<=", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
+			exprVAL.BinOpExpr = mustNewBinOpExpr("<", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
 	case 118:
-		exprDollar = exprS[exprpt-0 : exprpt+1]
-//line pkg/logql/expr.y:299
+		exprDollar = exprS[exprpt-4 : exprpt+1]
+//line pkg/logql/expr.y:296
 		{
-			exprVAL.BinOpModifier = BinOpOptions{}
+			exprVAL.BinOpExpr = mustNewBinOpExpr("<=", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
 	case 119:
-		exprDollar = exprS[exprpt-1 : exprpt+1]
+		exprDollar = exprS[exprpt-0 : exprpt+1]
 //line pkg/logql/expr.y:300
 		{
-			exprVAL.BinOpModifier = BinOpOptions{ReturnBool: true}
+			exprVAL.BinOpModifier = BinOpOptions{}
 		}
 	case 120:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line pkg/logql/expr.y:304
+//line pkg/logql/expr.y:301
 		{
-			exprVAL.LiteralExpr = mustNewLiteralExpr(exprDollar[1].str, false)
+			exprVAL.BinOpModifier = BinOpOptions{ReturnBool: true}
 		}
 	case 121:
-		exprDollar = exprS[exprpt-2 : exprpt+1]
+		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:305
 		{
-			exprVAL.LiteralExpr = mustNewLiteralExpr(exprDollar[2].str, false)
+			exprVAL.LiteralExpr = mustNewLiteralExpr(exprDollar[1].str, false)
 		}
 	case 122:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
 //line pkg/logql/expr.y:306
 		{
-			exprVAL.LiteralExpr = mustNewLiteralExpr(exprDollar[2].str, true)
+			exprVAL.LiteralExpr = mustNewLiteralExpr(exprDollar[2].str, false)
 		}
 	case 123:
-		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line pkg/logql/expr.y:310
+		exprDollar = exprS[exprpt-2 : exprpt+1]
+//line pkg/logql/expr.y:307
 		{
-			exprVAL.VectorOp = OpTypeSum
+			exprVAL.LiteralExpr = mustNewLiteralExpr(exprDollar[2].str, true)
 		}
 	case 124:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:311
 		{
-			exprVAL.VectorOp = OpTypeAvg
+			exprVAL.VectorOp = OpTypeSum
 		}
 	case 125:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:312
 		{
-			exprVAL.VectorOp = OpTypeCount
+			exprVAL.VectorOp = OpTypeAvg
 		}
 	case 126:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:313
 		{
-			exprVAL.VectorOp = OpTypeMax
+			exprVAL.VectorOp = OpTypeCount
 		}
 	case 127:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:314
 		{
-			exprVAL.VectorOp = OpTypeMin
+			exprVAL.VectorOp = OpTypeMax
 		}
 	case 128:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:315
 		{
-			exprVAL.VectorOp = OpTypeStddev
+			exprVAL.VectorOp = OpTypeMin
 		}
 	case 129:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:316
 		{
-			exprVAL.VectorOp = OpTypeStdvar
+			exprVAL.VectorOp = OpTypeStddev
 		}
 	case 130:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:317
 		{
-			exprVAL.VectorOp = OpTypeBottomK
+			exprVAL.VectorOp = OpTypeStdvar
 		}
 	case 131:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:318
 		{
-			exprVAL.VectorOp = OpTypeTopK
+			exprVAL.VectorOp = OpTypeBottomK
 		}
 	case 132:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line pkg/logql/expr.y:322
+//line pkg/logql/expr.y:319
 		{
-			exprVAL.RangeOp = OpRangeTypeCount
+			exprVAL.VectorOp = OpTypeTopK
 		}
 	case 133:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:323
 		{
-			exprVAL.RangeOp = OpRangeTypeRate
+			exprVAL.RangeOp = OpRangeTypeCount
 		}
 	case 134:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:324
 		{
-			exprVAL.RangeOp = OpRangeTypeBytes
+			exprVAL.RangeOp = OpRangeTypeRate
 		}
 	case 135:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:325
 		{
-			exprVAL.RangeOp = OpRangeTypeBytesRate
+			exprVAL.RangeOp = OpRangeTypeBytes
 		}
 	case 136:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:326
 		{
-			exprVAL.RangeOp = OpRangeTypeAvg
+			exprVAL.RangeOp = OpRangeTypeBytesRate
 		}
 	case 137:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:327
 		{
-			exprVAL.RangeOp = OpRangeTypeSum
+			exprVAL.RangeOp = OpRangeTypeAvg
 		}
 	case 138:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:328
 		{
-			exprVAL.RangeOp = OpRangeTypeMin
+			exprVAL.RangeOp = OpRangeTypeSum
 		}
 	case 139:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:329
 		{
-			exprVAL.RangeOp = OpRangeTypeMax
+			exprVAL.RangeOp = OpRangeTypeMin
 		}
 	case 140:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:330
 		{
-			exprVAL.RangeOp = OpRangeTypeStdvar
+			exprVAL.RangeOp = OpRangeTypeMax
 		}
 	case 141:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:331
 		{
-			exprVAL.RangeOp = OpRangeTypeStddev
+			exprVAL.RangeOp = OpRangeTypeStdvar
 		}
 	case 142:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:332
 		{
-			exprVAL.RangeOp = OpRangeTypeQuantile
+			exprVAL.RangeOp = OpRangeTypeStddev
 		}
 	case 143:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line pkg/logql/expr.y:337
+//line pkg/logql/expr.y:333
 		{
-			exprVAL.Labels = []string{exprDollar[1].str}
+			exprVAL.RangeOp = OpRangeTypeQuantile
 		}
 	case 144:
-		exprDollar = exprS[exprpt-3 : exprpt+1]
+		exprDollar = exprS[exprpt-1 : exprpt+1]
 //line pkg/logql/expr.y:338
 		{
-			exprVAL.Labels = append(exprDollar[1].Labels, exprDollar[3].str)
+			exprVAL.Labels = []string{exprDollar[1].str}
 		}
 	case 145:
-		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line pkg/logql/expr.y:342
+		exprDollar = exprS[exprpt-3 : exprpt+1]
+//line pkg/logql/expr.y:339
 		{
-			exprVAL.Grouping = &grouping{without: false, groups: exprDollar[3].Labels}
+			exprVAL.Labels = append(exprDollar[1].Labels, exprDollar[3].str)
 		}
 	case 146:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
 //line pkg/logql/expr.y:343
+		{
+			exprVAL.Grouping = &grouping{without: false, groups: exprDollar[3].Labels}
+		}
+	case 147:
+		exprDollar = exprS[exprpt-4 : exprpt+1]
+//line pkg/logql/expr.y:344
 		{
 			exprVAL.Grouping = &grouping{without: true, groups: exprDollar[3].Labels}
 		}
@@ -16,12 +16,16 @@ import (
 const (
 	jsonSpacer      = "_"
 	duplicateSuffix = "_extracted"
+
+	// PackedEntryKey is the key of the original log line in the lines packed by the pack stage of promtail.
+	PackedEntryKey = "_entry"
 )
 
 var (
 	_ Stage = &JSONParser{}
 	_ Stage = &RegexpParser{}
 	_ Stage = &LogfmtParser{}
+	_ Stage = &UnpackParser{}
 
 	errMissingCapture = errors.New("at least one named capture must be supplied")
 )
@@ -173,3 +177,33 @@ func (l *LogfmtParser) Process(line []byte, lbs *LabelsBuilder) ([]byte, bool) {
 	}
 	return line, true
 }
+
+type UnpackParser struct{}
+
+// NewUnpackParser creates a parser that unpacks the lines packed by the pack stage of promtail: the string properties
+// of the json log line are added as labels and the line is replaced by the original one.
+func NewUnpackParser() *UnpackParser {
+	return &UnpackParser{}
+}
+
+func (u *UnpackParser) Process(line []byte, lbs *LabelsBuilder) ([]byte, bool) {
+	data := map[string]interface{}{}
+	err := jsoniter.ConfigFastest.Unmarshal(line, &data)
+	if err != nil {
+		lbs.SetErr(errJSON)
+		return line, true
+	}
+	add := addLabel(lbs)
+	for key, val := range data {
+		value, ok := val.(string)
+		if !ok {
+			continue
+		}
+		if key == PackedEntryKey {
+			line = []byte(value)
+			continue
+		}
+		add(key, value)
+	}
+	return line, true
+}
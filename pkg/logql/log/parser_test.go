@@ -290,6 +290,69 @@ func Test_logfmtParser_Parse(t *testing.T) {
 	}
 }
 
+func Test_unpackParser_Parse(t *testing.T) {
+	tests := []struct {
+		name string
+		line []byte
+		lbs  labels.Labels
+
+		wantLbs  labels.Labels
+		wantLine []byte
+	}{
+		{
+			"packed",
+			[]byte(`{"pod":"loki-0","container":"loki","_entry":"level=info msg=\"started\""}`),
+			labels.Labels{},
+			labels.Labels{
+				{Name: "pod", Value: "loki-0"},
+				{Name: "container", Value: "loki"},
+			},
+			[]byte(`level=info msg="started"`),
+		},
+		{
+			"without entry",
+			[]byte(`{"pod":"loki-0","counter":1}`),
+			labels.Labels{},
+			labels.Labels{
+				{Name: "pod", Value: "loki-0"},
+			},
+			[]byte(`{"pod":"loki-0","counter":1}`),
+		},
+		{
+			"duplicate extraction",
+			[]byte(`{"app":"foo","_entry":"bar"}`),
+			labels.Labels{
+				{Name: "app", Value: "loki"},
+			},
+			labels.Labels{
+				{Name: "app", Value: "loki"},
+				{Name: "app_extracted", Value: "foo"},
+			},
+			[]byte(`bar`),
+		},
+		{
+			"errors",
+			[]byte(`not packed`),
+			labels.Labels{},
+			labels.Labels{
+				{Name: ErrorLabel, Value: errJSON},
+			},
+			[]byte(`not packed`),
+		},
+	}
+	for _, tt := range tests {
+		p := NewUnpackParser()
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewLabelsBuilder()
+			b.Reset(tt.lbs)
+			line, _ := p.Process(tt.line, b)
+			sort.Sort(tt.wantLbs)
+			require.Equal(t, tt.wantLbs, b.Labels())
+			require.Equal(t, string(tt.wantLine), string(line))
+		})
+	}
+}
+
 func Test_sanitizeKey(t *testing.T) {
 	tests := []struct {
 		key  string
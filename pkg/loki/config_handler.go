@@ -0,0 +1,71 @@
+package loki
+
+import (
+	"net/http"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"gopkg.in/yaml.v2"
+
+	"github.com/famarks/loki/pkg/util"
+)
+
+// maskedSecret replaces the credentials in the rendered configuration, like flagext.Secret does.
+const maskedSecret = "********"
+
+// configHandler renders the configuration Loki runs with, or only its values differing from the defaults with
+// `?mode=diff`. The credentials are masked.
+func (t *Loki) configHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := redactedConfig(t.cfg)
+	var output interface{} = cfg
+
+	switch r.URL.Query().Get("mode") {
+	case "diff":
+		var defaultCfg Config
+		flagext.DefaultValues(&defaultCfg)
+
+		defaultCfgObj, err := util.YAMLMarshalUnmarshal(defaultCfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		actualCfgObj, err := util.YAMLMarshalUnmarshal(cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		output = util.DiffConfig(defaultCfgObj, actualCfgObj)
+	case "":
+	default:
+		http.Error(w, "invalid mode, must be empty or diff", http.StatusBadRequest)
+		return
+	}
+
+	out, err := yaml.Marshal(output)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/yaml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(out)
+}
+
+// redactedConfig returns a copy of the config whose credentials which aren't flagext.Secret values, and so aren't
+// masked when marshalled, are masked.
+func redactedConfig(cfg Config) Config {
+	for _, secret := range []*string{
+		&cfg.StorageConfig.AWSStorageConfig.S3Config.SecretAccessKey,
+		&cfg.StorageConfig.Swift.Password,
+		&cfg.Ruler.StoreConfig.S3.SecretAccessKey,
+		&cfg.Ruler.StoreConfig.Swift.Password,
+		&cfg.Distributor.DistributorRing.KVStore.Consul.ACLToken,
+		&cfg.Ingester.LifecyclerConfig.RingConfig.KVStore.Consul.ACLToken,
+		&cfg.Ruler.Ring.KVStore.Consul.ACLToken,
+		&cfg.CompactorConfig.ShardingRing.KVStore.Consul.ACLToken,
+	} {
+		if *secret != "" {
+			*secret = maskedSecret
+		}
+	}
+	return cfg
+}
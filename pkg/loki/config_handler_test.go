@@ -0,0 +1,61 @@
+package loki
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfigHandler(t *testing.T) {
+	loki := &Loki{}
+	flagext.DefaultValues(&loki.cfg)
+	loki.cfg.Target = Querier
+	loki.cfg.LimitsConfig.MaxEntriesLimitPerQuery = 10
+
+	get := func(target string) (int, string) {
+		w := httptest.NewRecorder()
+		loki.configHandler(w, httptest.NewRequest("GET", target, nil))
+		return w.Code, w.Body.String()
+	}
+
+	code, body := get("/config")
+	require.Equal(t, http.StatusOK, code)
+	var cfg map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(body), &cfg))
+	require.Equal(t, Querier, cfg["target"])
+	require.Contains(t, cfg, "ingester")
+
+	code, body = get("/config?mode=diff")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "limits_config:\n  max_entries_limit_per_query: 10\ntarget: querier\n", body)
+
+	code, _ = get("/config?mode=foo")
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestConfigHandler_MasksCredentials(t *testing.T) {
+	loki := &Loki{}
+	flagext.DefaultValues(&loki.cfg)
+	loki.cfg.StorageConfig.AWSStorageConfig.S3Config.SecretAccessKey = "s3-secret"
+	loki.cfg.StorageConfig.Swift.Password = "swift-secret"
+	loki.cfg.Ruler.StoreConfig.S3.SecretAccessKey = "ruler-s3-secret"
+	loki.cfg.Ingester.LifecyclerConfig.RingConfig.KVStore.Consul.ACLToken = "consul-secret"
+	require.NoError(t, loki.cfg.StorageConfig.AzureStorageConfig.AccountKey.Set("azure-secret"))
+
+	for _, target := range []string{"/config", "/config?mode=diff"} {
+		w := httptest.NewRecorder()
+		loki.configHandler(w, httptest.NewRequest("GET", target, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		for _, secret := range []string{"s3-secret", "swift-secret", "ruler-s3-secret", "consul-secret", "azure-secret"} {
+			require.NotContains(t, w.Body.String(), secret)
+		}
+		require.Contains(t, w.Body.String(), "secret_access_key: '********'")
+	}
+
+	// the config Loki runs with is left as is.
+	require.Equal(t, "s3-secret", loki.cfg.StorageConfig.AWSStorageConfig.S3Config.SecretAccessKey)
+}
@@ -40,6 +40,7 @@ import (
 	"github.com/famarks/loki/pkg/ruler"
 	"github.com/famarks/loki/pkg/storage"
 	"github.com/famarks/loki/pkg/tracing"
+	"github.com/famarks/loki/pkg/usagereport"
 	serverutil "github.com/famarks/loki/pkg/util/server"
 	"github.com/famarks/loki/pkg/util/validation"
 )
@@ -68,6 +69,7 @@ type Config struct {
 	MemberlistKV     memberlist.KVConfig         `yaml:"memberlist"`
 	Tracing          tracing.Config              `yaml:"tracing"`
 	CompactorConfig  compactor.Config            `yaml:"compactor,omitempty"`
+	UsageReport      usagereport.Config          `yaml:"usage_report"`
 }
 
 // RegisterFlags registers flag.
@@ -96,6 +98,7 @@ func (c *Config) RegisterFlags(f *flag.FlagSet) {
 	c.MemberlistKV.RegisterFlags(f, "")
 	c.Tracing.RegisterFlags(f)
 	c.CompactorConfig.RegisterFlags(f)
+	c.UsageReport.RegisterFlags(f)
 }
 
 // Clone takes advantage of pass-by-value semantics to return a distinct *Config.
@@ -115,15 +118,30 @@ func (c *Config) Validate(log log.Logger) error {
 	if err := c.StorageConfig.Validate(); err != nil {
 		return errors.Wrap(err, "invalid storage config")
 	}
+	if err := c.StorageConfig.ValidateColdTier(c.SchemaConfig.SchemaConfig, c.CompactorConfig.SharedStoreType, c.StorageConfig.BoltDBShipperConfig.SharedStoreType, c.StorageConfig.TSDBShipperConfig.SharedStoreType); err != nil {
+		return errors.Wrap(err, "invalid storage config")
+	}
 	if err := c.QueryRange.Validate(log); err != nil {
 		return errors.Wrap(err, "invalid queryrange config")
 	}
 	if err := c.TableManager.Validate(); err != nil {
 		return errors.Wrap(err, "invalid tablemanager config")
 	}
+	if err := c.IngesterClient.Validate(log); err != nil {
+		return errors.Wrap(err, "invalid ingester client config")
+	}
 	if err := c.Ruler.Validate(); err != nil {
 		return errors.Wrap(err, "invalid ruler config")
 	}
+	if err := c.LimitsConfig.Validate(); err != nil {
+		return errors.Wrap(err, "invalid limits config")
+	}
+	if err := c.CompactorConfig.Validate(); err != nil {
+		return errors.Wrap(err, "invalid compactor config")
+	}
+	if err := c.UsageReport.Validate(); err != nil {
+		return errors.Wrap(err, "invalid usage report config")
+	}
 	return nil
 }
 
@@ -153,7 +171,8 @@ type Loki struct {
 	memberlistKV    *memberlist.KVInitService
 	compactor       *compactor.Compactor
 
-	httpAuthMiddleware middleware.Interface
+	httpAuthMiddleware  middleware.Interface
+	runtimeConfigLoader *runtimeConfigLoader
 }
 
 // New makes a new Loki.
@@ -166,6 +185,9 @@ func New(cfg Config) (*Loki, error) {
 	if err := loki.setupModuleManager(); err != nil {
 		return nil, err
 	}
+	if err := loki.cfg.StorageConfig.InjectS3Compatible(); err != nil {
+		return nil, err
+	}
 	storage.RegisterCustomIndexClients(&loki.cfg.StorageConfig, prometheus.DefaultRegisterer)
 
 	return loki, nil
@@ -208,6 +230,10 @@ func (t *Loki) Run() error {
 
 	t.serviceMap = serviceMap
 	t.server.HTTP.Handle("/services", http.HandlerFunc(t.servicesHandler))
+	t.server.HTTP.Handle("/discards", t.httpAuthMiddleware.Wrap(validation.Discards))
+	t.server.HTTP.Handle("/runtime_config", http.HandlerFunc(t.runtimeConfigHandler))
+	t.server.HTTP.Handle("/config", http.HandlerFunc(t.configHandler))
+	t.server.HTTP.Handle("/memberlist", http.HandlerFunc(t.memberlistHandler))
 
 	// get all services, create service manager and tell it to start
 	var servs []services.Service
@@ -335,22 +361,27 @@ func (t *Loki) setupModuleManager() error {
 	mm.RegisterModule(Ruler, t.initRuler)
 	mm.RegisterModule(TableManager, t.initTableManager)
 	mm.RegisterModule(Compactor, t.initCompactor)
+	mm.RegisterModule(IndexGateway, t.initIndexGateway)
+	mm.RegisterModule(OverridesExporter, t.initOverridesExporter)
+	mm.RegisterModule(UsageReport, t.initUsageReport, modules.UserInvisibleModule)
 	mm.RegisterModule(All, nil)
 
 	// Add dependencies
 	deps := map[string][]string{
-		Ring:            {RuntimeConfig, Server, MemberlistKV},
-		Overrides:       {RuntimeConfig},
-		Distributor:     {Ring, Server, Overrides},
-		Store:           {Overrides},
-		Ingester:        {Store, Server, MemberlistKV},
-		Querier:         {Store, Ring, Server, IngesterQuerier},
-		QueryFrontend:   {Server, Overrides},
-		Ruler:           {Ring, Server, Store, RulerStorage, IngesterQuerier},
-		TableManager:    {Server},
-		Compactor:       {Server},
-		IngesterQuerier: {Ring},
-		All:             {Querier, Ingester, Distributor, TableManager, Ruler},
+		Ring:              {RuntimeConfig, Server, MemberlistKV},
+		Overrides:         {RuntimeConfig},
+		Distributor:       {Ring, Server, Overrides, UsageReport},
+		Store:             {Overrides},
+		Ingester:          {Store, Server, MemberlistKV, UsageReport},
+		Querier:           {Store, Ring, Server, IngesterQuerier, UsageReport},
+		QueryFrontend:     {Server, Overrides, UsageReport},
+		Ruler:             {Ring, Server, Store, RulerStorage, IngesterQuerier, UsageReport},
+		TableManager:      {Server, UsageReport},
+		Compactor:         {Server, Overrides, MemberlistKV, UsageReport},
+		IndexGateway:      {Server, UsageReport},
+		OverridesExporter: {RuntimeConfig, Server, UsageReport},
+		IngesterQuerier:   {Ring},
+		All:               {Querier, Ingester, Distributor, TableManager, Ruler},
 	}
 
 	// Add IngesterQuerier as a dependency for store when target is either ingester or querier.
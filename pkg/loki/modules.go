@@ -11,11 +11,13 @@ import (
 
 	"github.com/famarks/loki/pkg/ruler/manager"
 	"github.com/famarks/loki/pkg/storage/stores/shipper/compactor"
+	"github.com/famarks/loki/pkg/storage/stores/shipper/indexgateway"
+	"github.com/famarks/loki/pkg/storage/stores/tsdb"
 
 	"github.com/cortexproject/cortex/pkg/chunk"
 	"github.com/cortexproject/cortex/pkg/chunk/cache"
+	grpc_store "github.com/cortexproject/cortex/pkg/chunk/grpc"
 	"github.com/cortexproject/cortex/pkg/chunk/storage"
-	cortex_storage "github.com/cortexproject/cortex/pkg/chunk/storage"
 	chunk_util "github.com/cortexproject/cortex/pkg/chunk/util"
 	"github.com/cortexproject/cortex/pkg/cortex"
 	cortex_querier "github.com/cortexproject/cortex/pkg/querier"
@@ -44,6 +46,7 @@ import (
 	"github.com/famarks/loki/pkg/ruler"
 	loki_storage "github.com/famarks/loki/pkg/storage"
 	"github.com/famarks/loki/pkg/storage/stores/shipper"
+	"github.com/famarks/loki/pkg/usagereport"
 	serverutil "github.com/famarks/loki/pkg/util/server"
 	"github.com/famarks/loki/pkg/util/validation"
 )
@@ -52,22 +55,25 @@ const maxChunkAgeForTableManager = 12 * time.Hour
 
 // The various modules that make up Loki.
 const (
-	Ring            string = "ring"
-	RuntimeConfig   string = "runtime-config"
-	Overrides       string = "overrides"
-	Server          string = "server"
-	Distributor     string = "distributor"
-	Ingester        string = "ingester"
-	Querier         string = "querier"
-	IngesterQuerier string = "ingester-querier"
-	QueryFrontend   string = "query-frontend"
-	RulerStorage    string = "ruler-storage"
-	Ruler           string = "ruler"
-	Store           string = "store"
-	TableManager    string = "table-manager"
-	MemberlistKV    string = "memberlist-kv"
-	Compactor       string = "compactor"
-	All             string = "all"
+	Ring              string = "ring"
+	RuntimeConfig     string = "runtime-config"
+	Overrides         string = "overrides"
+	Server            string = "server"
+	Distributor       string = "distributor"
+	Ingester          string = "ingester"
+	Querier           string = "querier"
+	IngesterQuerier   string = "ingester-querier"
+	QueryFrontend     string = "query-frontend"
+	RulerStorage      string = "ruler-storage"
+	Ruler             string = "ruler"
+	Store             string = "store"
+	TableManager      string = "table-manager"
+	MemberlistKV      string = "memberlist-kv"
+	Compactor         string = "compactor"
+	IndexGateway      string = "index-gateway"
+	OverridesExporter string = "overrides-exporter"
+	UsageReport       string = "usage-report"
+	All               string = "all"
 )
 
 func (t *Loki) initServer() (services.Service, error) {
@@ -119,7 +125,8 @@ func (t *Loki) initRuntimeConfig() (services.Service, error) {
 		return nil, nil
 	}
 
-	t.cfg.RuntimeConfig.Loader = loadRuntimeConfig
+	t.runtimeConfigLoader = &runtimeConfigLoader{}
+	t.cfg.RuntimeConfig.Loader = t.runtimeConfigLoader.load
 
 	// make sure to set default limits before we start loading configuration into memory
 	validation.SetDefaultLimitsForYAMLUnmarshalling(t.cfg.LimitsConfig)
@@ -135,6 +142,20 @@ func (t *Loki) initOverrides() (_ services.Service, err error) {
 	return nil, err
 }
 
+func (t *Loki) initOverridesExporter() (services.Service, error) {
+	// The limits are exported when the metrics are collected, so there is no service to run.
+	exporter := validation.NewOverridesExporter(t.cfg.LimitsConfig, allTenantLimitsFromRuntimeConfig(t.runtimeConfig))
+	prometheus.MustRegister(exporter)
+	return nil, nil
+}
+
+func (t *Loki) initUsageReport() (services.Service, error) {
+	if !t.cfg.UsageReport.Enabled {
+		return nil, nil
+	}
+	return usagereport.NewReporter(t.cfg.UsageReport, t.usageReport(), t.usageReportStats, prometheus.DefaultRegisterer, util.Logger), nil
+}
+
 func (t *Loki) initDistributor() (services.Service, error) {
 	t.cfg.Distributor.DistributorRing.KVStore.Multi.ConfigProvider = multiClientRuntimeConfigChannel(t.runtimeConfig)
 	t.cfg.Distributor.DistributorRing.KVStore.MemberlistKV = t.memberlistKV.GetMemberlistKV
@@ -149,8 +170,14 @@ func (t *Loki) initDistributor() (services.Service, error) {
 		t.httpAuthMiddleware,
 	).Wrap(http.HandlerFunc(t.distributor.PushHandler))
 
+	remoteWriteHandler := middleware.Merge(
+		serverutil.RecoveryHTTPMiddleware,
+		t.httpAuthMiddleware,
+	).Wrap(http.HandlerFunc(t.distributor.RemoteWriteHandler))
+
 	t.server.HTTP.Handle("/api/prom/push", pushHandler)
 	t.server.HTTP.Handle("/loki/api/v1/push", pushHandler)
+	t.server.HTTP.Handle("/loki/api/v1/remote_write", remoteWriteHandler)
 	return t.distributor, nil
 }
 
@@ -174,21 +201,26 @@ func (t *Loki) initQuerier() (services.Service, error) {
 		serverutil.NewPrepopulateMiddleware(),
 		serverutil.ResponseJSONMiddleware(),
 	)
-	t.server.HTTP.Handle("/loki/api/v1/query_range", httpMiddleware.Wrap(http.HandlerFunc(t.querier.RangeQueryHandler)))
-	t.server.HTTP.Handle("/loki/api/v1/query", httpMiddleware.Wrap(http.HandlerFunc(t.querier.InstantQueryHandler)))
+	// The tail requests are long lived websockets, which aren't accounted in the per tenant metrics.
+	queryMiddleware := middleware.Merge(
+		httpMiddleware,
+		querier.NewTenantMetrics(t.cfg.Querier.PerTenantMetricsMaxTenants, prometheus.DefaultRegisterer),
+	)
+	t.server.HTTP.Handle("/loki/api/v1/query_range", queryMiddleware.Wrap(http.HandlerFunc(t.querier.RangeQueryHandler)))
+	t.server.HTTP.Handle("/loki/api/v1/query", queryMiddleware.Wrap(http.HandlerFunc(t.querier.InstantQueryHandler)))
 	// Prometheus compatibility requires `loki/api/v1/labels` however we already released `loki/api/v1/label`
 	// which is a little more consistent with `/loki/api/v1/label/{name}/values` so we are going to handle both paths.
-	t.server.HTTP.Handle("/loki/api/v1/label", httpMiddleware.Wrap(http.HandlerFunc(t.querier.LabelHandler)))
-	t.server.HTTP.Handle("/loki/api/v1/labels", httpMiddleware.Wrap(http.HandlerFunc(t.querier.LabelHandler)))
-	t.server.HTTP.Handle("/loki/api/v1/label/{name}/values", httpMiddleware.Wrap(http.HandlerFunc(t.querier.LabelHandler)))
+	t.server.HTTP.Handle("/loki/api/v1/label", queryMiddleware.Wrap(http.HandlerFunc(t.querier.LabelHandler)))
+	t.server.HTTP.Handle("/loki/api/v1/labels", queryMiddleware.Wrap(http.HandlerFunc(t.querier.LabelHandler)))
+	t.server.HTTP.Handle("/loki/api/v1/label/{name}/values", queryMiddleware.Wrap(http.HandlerFunc(t.querier.LabelHandler)))
 	t.server.HTTP.Handle("/loki/api/v1/tail", httpMiddleware.Wrap(http.HandlerFunc(t.querier.TailHandler)))
-	t.server.HTTP.Handle("/loki/api/v1/series", httpMiddleware.Wrap(http.HandlerFunc(t.querier.SeriesHandler)))
+	t.server.HTTP.Handle("/loki/api/v1/series", queryMiddleware.Wrap(http.HandlerFunc(t.querier.SeriesHandler)))
 
-	t.server.HTTP.Handle("/api/prom/query", httpMiddleware.Wrap(http.HandlerFunc(t.querier.LogQueryHandler)))
-	t.server.HTTP.Handle("/api/prom/label", httpMiddleware.Wrap(http.HandlerFunc(t.querier.LabelHandler)))
-	t.server.HTTP.Handle("/api/prom/label/{name}/values", httpMiddleware.Wrap(http.HandlerFunc(t.querier.LabelHandler)))
+	t.server.HTTP.Handle("/api/prom/query", queryMiddleware.Wrap(http.HandlerFunc(t.querier.LogQueryHandler)))
+	t.server.HTTP.Handle("/api/prom/label", queryMiddleware.Wrap(http.HandlerFunc(t.querier.LabelHandler)))
+	t.server.HTTP.Handle("/api/prom/label/{name}/values", queryMiddleware.Wrap(http.HandlerFunc(t.querier.LabelHandler)))
 	t.server.HTTP.Handle("/api/prom/tail", httpMiddleware.Wrap(http.HandlerFunc(t.querier.TailHandler)))
-	t.server.HTTP.Handle("/api/prom/series", httpMiddleware.Wrap(http.HandlerFunc(t.querier.SeriesHandler)))
+	t.server.HTTP.Handle("/api/prom/series", queryMiddleware.Wrap(http.HandlerFunc(t.querier.SeriesHandler)))
 	return worker, nil // ok if worker is nil here
 }
 
@@ -276,7 +308,7 @@ func (t *Loki) initStore() (_ services.Service, err error) {
 					Validity: t.cfg.StorageConfig.IndexCacheValidity - 1*time.Minute,
 				},
 			}
-		case Querier:
+		case Querier, Ruler:
 			// We do not want query to do any updates to index
 			t.cfg.StorageConfig.BoltDBShipperConfig.Mode = shipper.ModeReadOnly
 		default:
@@ -284,12 +316,34 @@ func (t *Loki) initStore() (_ services.Service, err error) {
 		}
 	}
 
-	chunkStore, err := cortex_storage.NewStore(t.cfg.StorageConfig.Config, t.cfg.ChunkStoreConfig, t.cfg.SchemaConfig.SchemaConfig, t.overrides, prometheus.DefaultRegisterer, nil, util.Logger)
+	// The tsdb index is shipped like the boltdb-shipper one, its mode is set for any target since the tsdb periods
+	// don't have to be current ones.
+	t.cfg.StorageConfig.TSDBShipperConfig.IngesterName = t.cfg.Ingester.LifecyclerConfig.ID
+	switch t.cfg.Target {
+	case Ingester:
+		t.cfg.StorageConfig.TSDBShipperConfig.Mode = shipper.ModeWriteOnly
+	case Querier, Ruler:
+		t.cfg.StorageConfig.TSDBShipperConfig.Mode = shipper.ModeReadOnly
+	default:
+		t.cfg.StorageConfig.TSDBShipperConfig.Mode = shipper.ModeReadWrite
+	}
+
+	// The S3 compatible stores otherwise fail in opaque ways at the first flush of the ingesters.
+	if err := loki_storage.CheckS3Connectivity(t.cfg.StorageConfig, t.cfg.SchemaConfig); err != nil {
+		return nil, err
+	}
+
+	chunkStore, err := loki_storage.NewChunkStore(t.cfg.StorageConfig, t.cfg.ChunkStoreConfig, t.cfg.SchemaConfig, t.overrides, prometheus.DefaultRegisterer)
 	if err != nil {
 		return
 	}
 
-	if loki_storage.UsingBoltdbShipper(t.cfg.SchemaConfig.Configs) {
+	if t.cfg.StorageConfig.WriteBehind.Enabled && (t.cfg.Target == Ingester || t.cfg.Target == All) {
+		// Only the ingesters write chunks, the queue bounds the concurrency of their writes and retries them.
+		chunkStore = loki_storage.NewWriteBehindStore(t.cfg.StorageConfig.WriteBehind, chunkStore, prometheus.DefaultRegisterer)
+	}
+
+	if loki_storage.UsingBoltdbShipper(t.cfg.SchemaConfig.Configs) || loki_storage.UsingTSDB(t.cfg.SchemaConfig.Configs) {
 		switch t.cfg.Target {
 		case Querier:
 			// Use AsyncStore to query both ingesters local store and chunk store for store queries.
@@ -301,11 +355,15 @@ func (t *Loki) initStore() (_ services.Service, err error) {
 			// ToDo: See if we can avoid doing this when not running loki in clustered mode.
 			t.cfg.Ingester.QueryStore = true
 			boltdbShipperConfigIdx := loki_storage.ActivePeriodConfig(t.cfg.SchemaConfig.Configs)
-			if t.cfg.SchemaConfig.Configs[boltdbShipperConfigIdx].IndexType != shipper.BoltDBShipperType {
+			if !loki_storage.IsShippedIndexType(t.cfg.SchemaConfig.Configs[boltdbShipperConfigIdx].IndexType) {
 				boltdbShipperConfigIdx++
 			}
+			resyncInterval := t.cfg.StorageConfig.BoltDBShipperConfig.ResyncInterval
+			if t.cfg.SchemaConfig.Configs[boltdbShipperConfigIdx].IndexType == tsdb.TSDBType {
+				resyncInterval = t.cfg.StorageConfig.TSDBShipperConfig.ResyncInterval
+			}
 			mlb, err := calculateMaxLookBack(t.cfg.SchemaConfig.Configs[boltdbShipperConfigIdx], t.cfg.Ingester.QueryStoreMaxLookBackPeriod,
-				t.cfg.Ingester.MaxChunkAge, t.cfg.StorageConfig.BoltDBShipperConfig.ResyncInterval)
+				t.cfg.Ingester.MaxChunkAge, resyncInterval)
 			if err != nil {
 				return nil, err
 			}
@@ -361,6 +419,7 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 		return
 	}
 	t.stopper = stopper
+	t.frontend.Wrap(querier.EnqueueTimeTripperware)
 	t.frontend.Wrap(tripperware)
 	frontend.RegisterFrontendServer(t.server.GRPC, t.frontend)
 
@@ -485,6 +544,7 @@ func (t *Loki) initRuler() (_ services.Service, err error) {
 		t.server.HTTP.Path("/api/prom/rules/{namespace}/{groupName}").Methods("GET").Handler(t.httpAuthMiddleware.Wrap(http.HandlerFunc(t.rulerAPI.GetRuleGroup)))
 		t.server.HTTP.Path("/api/prom/rules/{namespace}").Methods("POST").Handler(t.httpAuthMiddleware.Wrap(http.HandlerFunc(t.rulerAPI.CreateRuleGroup)))
 		t.server.HTTP.Path("/api/prom/rules/{namespace}/{groupName}").Methods("DELETE").Handler(t.httpAuthMiddleware.Wrap(http.HandlerFunc(t.rulerAPI.DeleteRuleGroup)))
+		t.server.HTTP.Path("/api/prom/rules/{namespace}").Methods("DELETE").Handler(t.httpAuthMiddleware.Wrap(http.HandlerFunc(t.rulerAPI.DeleteNamespace)))
 
 		// Ruler API Routes
 		t.server.HTTP.Path("/loki/api/v1/rules").Methods("GET").Handler(t.httpAuthMiddleware.Wrap(http.HandlerFunc(t.rulerAPI.ListRules)))
@@ -492,6 +552,7 @@ func (t *Loki) initRuler() (_ services.Service, err error) {
 		t.server.HTTP.Path("/loki/api/v1/rules/{namespace}/{groupName}").Methods("GET").Handler(t.httpAuthMiddleware.Wrap(http.HandlerFunc(t.rulerAPI.GetRuleGroup)))
 		t.server.HTTP.Path("/loki/api/v1/rules/{namespace}").Methods("POST").Handler(t.httpAuthMiddleware.Wrap(http.HandlerFunc(t.rulerAPI.CreateRuleGroup)))
 		t.server.HTTP.Path("/loki/api/v1/rules/{namespace}/{groupName}").Methods("DELETE").Handler(t.httpAuthMiddleware.Wrap(http.HandlerFunc(t.rulerAPI.DeleteRuleGroup)))
+		t.server.HTTP.Path("/loki/api/v1/rules/{namespace}").Methods("DELETE").Handler(t.httpAuthMiddleware.Wrap(http.HandlerFunc(t.rulerAPI.DeleteNamespace)))
 	}
 
 	return t.ruler, nil
@@ -508,15 +569,85 @@ func (t *Loki) initMemberlistKV() (services.Service, error) {
 }
 
 func (t *Loki) initCompactor() (services.Service, error) {
-	var err error
-	t.compactor, err = compactor.NewCompactor(t.cfg.CompactorConfig, t.cfg.StorageConfig.Config, prometheus.DefaultRegisterer)
+	t.cfg.CompactorConfig.ShardingRing.ListenPort = t.cfg.Server.GRPCListenPort
+	t.cfg.CompactorConfig.ShardingRing.KVStore.MemberlistKV = t.memberlistKV.GetMemberlistKV
+
+	objectClient, err := loki_storage.NewObjectClient(t.cfg.CompactorConfig.SharedStoreType, t.cfg.StorageConfig)
 	if err != nil {
 		return nil, err
 	}
 
+	var chunkClient chunk.Client
+	if t.cfg.CompactorConfig.RetentionEnabled || t.cfg.CompactorConfig.DeletionEnabled || t.cfg.CompactorConfig.ReencodingEncoding != "" ||
+		t.cfg.CompactorConfig.UsageEnabled || t.cfg.CompactorConfig.IntegrityCheckEnabled {
+		// the chunk clients of the chunk store register their metrics with a component label too.
+		chunkClientReg := prometheus.WrapRegistererWith(prometheus.Labels{"component": "compactor"}, prometheus.DefaultRegisterer)
+		chunkClient, err = loki_storage.NewChunkClient(t.cfg.CompactorConfig.SharedStoreType, t.cfg.StorageConfig, t.cfg.SchemaConfig.SchemaConfig, chunkClientReg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var coldObjectClient chunk.ObjectClient
+	if t.cfg.CompactorConfig.TieringEnabled {
+		if !t.cfg.StorageConfig.ColdTier.Enabled() {
+			return nil, errors.New("the cold tier of the storage config must be set with the tiering of the compactor enabled")
+		}
+		coldObjectClient, err = loki_storage.NewColdTierObjectClient(t.cfg.StorageConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	t.compactor, err = compactor.NewCompactor(t.cfg.CompactorConfig, objectClient, chunkClient, coldObjectClient, t.cfg.SchemaConfig.SchemaConfig, t.overrides, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.cfg.CompactorConfig.ShardingEnabled {
+		t.server.HTTP.Handle("/compactor/ring", t.compactor)
+	}
+
+	if t.cfg.CompactorConfig.UsageEnabled {
+		t.server.HTTP.Path("/compactor/usage").Methods("GET").Handler(t.httpAuthMiddleware.Wrap(http.HandlerFunc(t.compactor.UsageHandler)))
+	}
+
+	if t.cfg.CompactorConfig.IntegrityCheckEnabled {
+		t.server.HTTP.Path("/compactor/integrity").Methods("GET").Handler(t.httpAuthMiddleware.Wrap(http.HandlerFunc(t.compactor.IntegrityHandler)))
+	}
+
+	if t.cfg.CompactorConfig.DeletionEnabled {
+		httpMiddleware := middleware.Merge(
+			t.httpAuthMiddleware,
+			serverutil.NewPrepopulateMiddleware(),
+		)
+		t.server.HTTP.Path("/loki/api/v1/delete").Methods("POST").Handler(httpMiddleware.Wrap(http.HandlerFunc(t.compactor.AddDeleteRequestHandler)))
+		t.server.HTTP.Path("/loki/api/v1/delete").Methods("GET").Handler(httpMiddleware.Wrap(http.HandlerFunc(t.compactor.GetAllDeleteRequestsHandler)))
+		t.server.HTTP.Path("/loki/api/v1/delete").Methods("DELETE").Handler(httpMiddleware.Wrap(http.HandlerFunc(t.compactor.CancelDeleteRequestHandler)))
+	}
+
 	return t.compactor, nil
 }
 
+func (t *Loki) initIndexGateway() (services.Service, error) {
+	// the gateway only serves reads, the index is written by the ingesters.
+	t.cfg.StorageConfig.BoltDBShipperConfig.Mode = shipper.ModeReadOnly
+
+	objectClient, err := loki_storage.NewObjectClient(t.cfg.StorageConfig.BoltDBShipperConfig.SharedStoreType, t.cfg.StorageConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	shipperIndexClient, err := shipper.NewShipper(t.cfg.StorageConfig.BoltDBShipperConfig, objectClient, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, err
+	}
+
+	gateway := indexgateway.NewIndexGateway(shipperIndexClient)
+	grpc_store.RegisterGrpcStoreServer(t.server.GRPC, gateway)
+	return gateway, nil
+}
+
 func calculateMaxLookBack(pc chunk.PeriodConfig, maxLookBackConfig, maxChunkAge, querierResyncInterval time.Duration) (time.Duration, error) {
 	if pc.ObjectType != shipper.FilesystemObjectStoreType && maxLookBackConfig.Nanoseconds() != 0 {
 		return 0, errors.New("it is an error to specify a non zero `query_store_max_look_back_period` value when using any object store other than `filesystem`")
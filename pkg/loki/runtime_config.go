@@ -1,7 +1,11 @@
 package loki
 
 import (
+	"fmt"
 	"io"
+	"net/http"
+	"strings"
+	"sync"
 
 	"github.com/cortexproject/cortex/pkg/ring/kv"
 	"github.com/cortexproject/cortex/pkg/util/runtimeconfig"
@@ -28,9 +32,59 @@ func loadRuntimeConfig(r io.Reader) (interface{}, error) {
 		return nil, err
 	}
 
+	for userID, limits := range overrides.TenantLimits {
+		if limits == nil {
+			continue
+		}
+		if err := limits.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid overrides for tenant %s: %w", userID, err)
+		}
+	}
+
 	return overrides, nil
 }
 
+// runtimeConfigLoader loads the runtime config, keeping the error of the last load to report it.
+type runtimeConfigLoader struct {
+	mtx     sync.Mutex
+	lastErr error
+}
+
+func (l *runtimeConfigLoader) load(r io.Reader) (interface{}, error) {
+	config, err := loadRuntimeConfig(r)
+	l.mtx.Lock()
+	l.lastErr = err
+	l.mtx.Unlock()
+	return config, err
+}
+
+func (l *runtimeConfigLoader) lastError() error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.lastErr
+}
+
+// runtimeConfigHandler renders the runtime config currently loaded, preceded by the error of the last load when it
+// failed, the config loaded before being kept.
+func (t *Loki) runtimeConfigHandler(w http.ResponseWriter, _ *http.Request) {
+	if t.runtimeConfig == nil {
+		http.Error(w, "runtime config file is not configured", http.StatusNotFound)
+		return
+	}
+	out, err := yaml.Marshal(t.runtimeConfig.GetConfig())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	if err := t.runtimeConfigLoader.lastError(); err != nil {
+		fmt.Fprintf(w, "# The last reload failed, the values below were loaded before it: %s\n", strings.ReplaceAll(err.Error(), "\n", "\n# "))
+	}
+	_, _ = w.Write(out)
+}
+
 func tenantLimitsFromRuntimeConfig(c *runtimeconfig.Manager) validation.TenantLimits {
 	if c == nil {
 		return nil
@@ -45,6 +99,20 @@ func tenantLimitsFromRuntimeConfig(c *runtimeconfig.Manager) validation.TenantLi
 	}
 }
 
+func allTenantLimitsFromRuntimeConfig(c *runtimeconfig.Manager) validation.AllTenantLimits {
+	if c == nil {
+		return nil
+	}
+	return func() map[string]*validation.Limits {
+		cfg, ok := c.GetConfig().(*runtimeConfigValues)
+		if !ok || cfg == nil {
+			return nil
+		}
+
+		return cfg.TenantLimits
+	}
+}
+
 func multiClientRuntimeConfigChannel(manager *runtimeconfig.Manager) func() <-chan kv.MultiRuntimeConfig {
 	if manager == nil {
 		return nil
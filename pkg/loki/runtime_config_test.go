@@ -0,0 +1,67 @@
+package loki
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/cortexproject/cortex/pkg/util/runtimeconfig"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/util/validation"
+)
+
+func TestRuntimeConfigHandler(t *testing.T) {
+	t.Run("not configured", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		(&Loki{}).runtimeConfigHandler(w, httptest.NewRequest("GET", "/runtime_config", nil))
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	f, err := ioutil.TempFile("", "runtime-config")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte(`
+overrides:
+  tenant1:
+    max_streams_per_user: 100
+`), 0600))
+
+	var limits validation.Limits
+	flagext.DefaultValues(&limits)
+	validation.SetDefaultLimitsForYAMLUnmarshalling(limits)
+
+	loki := &Loki{runtimeConfigLoader: &runtimeConfigLoader{}}
+	loki.runtimeConfig, err = runtimeconfig.NewRuntimeConfigManager(runtimeconfig.ManagerConfig{
+		LoadPath:     f.Name(),
+		ReloadPeriod: 10 * time.Millisecond,
+		Loader:       loki.runtimeConfigLoader.load,
+	}, nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), loki.runtimeConfig))
+	defer services.StopAndAwaitTerminated(context.Background(), loki.runtimeConfig) //nolint:errcheck
+
+	get := func() string {
+		w := httptest.NewRecorder()
+		loki.runtimeConfigHandler(w, httptest.NewRequest("GET", "/runtime_config", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+	require.Contains(t, get(), "max_streams_per_user: 100\n")
+	require.NotContains(t, get(), "# The last reload failed")
+
+	// The values loaded before a parse error are kept, and the error reported.
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte("overrides: [\n"), 0600))
+	require.Eventually(t, func() bool {
+		return loki.runtimeConfigLoader.lastError() != nil
+	}, time.Second, 10*time.Millisecond)
+	out := get()
+	require.Contains(t, out, "# The last reload failed, the values below were loaded before it: yaml:")
+	require.Contains(t, out, "max_streams_per_user: 100\n")
+}
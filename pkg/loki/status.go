@@ -3,6 +3,12 @@ package loki
 import (
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/ring/kv"
 )
 
 func (t *Loki) servicesHandler(w http.ResponseWriter, r *http.Request) {
@@ -16,3 +22,61 @@ func (t *Loki) servicesHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// usingMemberlist returns whether one of the rings uses the memberlist KV, directly or in a multi KV.
+func (t *Loki) usingMemberlist() bool {
+	for _, cfg := range []kv.Config{
+		t.cfg.Ingester.LifecyclerConfig.RingConfig.KVStore,
+		t.cfg.Distributor.DistributorRing.KVStore,
+		t.cfg.Ruler.Ring.KVStore,
+		t.cfg.CompactorConfig.ShardingRing.KVStore,
+	} {
+		if cfg.Store == "memberlist" || (cfg.Store == "multi" && (cfg.Multi.Primary == "memberlist" || cfg.Multi.Secondary == "memberlist")) {
+			return true
+		}
+	}
+	return false
+}
+
+// memberlistHandler renders the members to join and the rings gossiped by the memberlist KV.
+func (t *Loki) memberlistHandler(w http.ResponseWriter, r *http.Request) {
+	if t.memberlistKV == nil || !t.usingMemberlist() {
+		http.Error(w, "memberlist KV is not used by any ring", http.StatusNotFound)
+		return
+	}
+	kvs, err := t.memberlistKV.GetMemberlistKV()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(200)
+	fmt.Fprintf(w, "Listening port: %d\n", kvs.GetListeningPort())
+	fmt.Fprintf(w, "Join members: %s\n", strings.Join(t.cfg.MemberlistKV.JoinMembers, ", "))
+
+	codec := ring.GetCodec()
+	for _, key := range kvs.List("") {
+		fmt.Fprintf(w, "\nKey: %s\n", key)
+		value, err := kvs.Get(key, codec)
+		if err != nil {
+			fmt.Fprintf(w, "  error: %s\n", err)
+			continue
+		}
+		desc, ok := value.(*ring.Desc)
+		if !ok || desc == nil {
+			continue
+		}
+
+		ids := make([]string, 0, len(desc.Ingesters))
+		for id := range desc.Ingesters {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			instance := desc.Ingesters[id]
+			heartbeat := time.Since(time.Unix(instance.Timestamp, 0)).Truncate(time.Second)
+			fmt.Fprintf(w, "  %s\t%s\t%s\tlast heartbeat %s ago\n", id, instance.Addr, instance.State, heartbeat)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package loki
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
+	"github.com/cortexproject/cortex/pkg/ring/kv/memberlist"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemberlistHandler(t *testing.T) {
+	loki := &Loki{}
+	get := func() (int, string) {
+		w := httptest.NewRecorder()
+		loki.memberlistHandler(w, httptest.NewRequest("GET", "/memberlist", nil))
+		return w.Code, w.Body.String()
+	}
+
+	code, _ := get()
+	require.Equal(t, http.StatusNotFound, code)
+
+	loki.cfg.MemberlistKV.RegisterFlags(flag.NewFlagSet("test", flag.PanicOnError), "")
+	loki.cfg.MemberlistKV.TCPTransport.BindAddrs = []string{"127.0.0.1"}
+	loki.cfg.MemberlistKV.TCPTransport.BindPort = 0
+	loki.cfg.MemberlistKV.Codecs = []codec.Codec{ring.GetCodec()}
+	loki.cfg.Distributor.DistributorRing.KVStore.Store = "memberlist"
+	loki.memberlistKV = memberlist.NewKVInitService(&loki.cfg.MemberlistKV, log.NewNopLogger())
+
+	kvs, err := loki.memberlistKV.GetMemberlistKV()
+	require.NoError(t, err)
+	defer services.StopAndAwaitTerminated(context.Background(), kvs) //nolint:errcheck
+	require.NoError(t, kvs.AwaitRunning(context.Background()))
+
+	require.NoError(t, kvs.CAS(context.Background(), "distributor", ring.GetCodec(), func(in interface{}) (interface{}, bool, error) {
+		desc := ring.NewDesc()
+		desc.AddIngester("distributor-1", "10.0.0.1:9095", "", nil, ring.ACTIVE, time.Now())
+		return desc, true, nil
+	}))
+
+	code, body := get()
+	require.Equal(t, http.StatusOK, code)
+	require.Contains(t, body, "Join members: \n")
+	require.Contains(t, body, "Key: distributor\n  distributor-1\t10.0.0.1:9095\tACTIVE\tlast heartbeat 0s ago\n")
+}
@@ -0,0 +1,56 @@
+package loki
+
+import (
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/famarks/loki/pkg/storage"
+	"github.com/famarks/loki/pkg/usagereport"
+)
+
+// usageReportCounters are the counters summed over the tenants in the usage reports, for the ingest volume.
+var usageReportCounters = []string{
+	"loki_distributor_bytes_received_total",
+	"loki_distributor_lines_received_total",
+	"loki_ingester_chunks_stored_total",
+	"loki_ingester_chunk_stored_bytes_total",
+}
+
+// usageReport returns the part of the usage report coming from the config, which doesn't change while running.
+func (t *Loki) usageReport() usagereport.Report {
+	report := usagereport.Report{
+		Target: t.cfg.Target,
+		Features: map[string]bool{
+			"auth":                     t.cfg.AuthEnabled,
+			"boltdb_shipper":           storage.UsingBoltdbShipper(t.cfg.SchemaConfig.Configs),
+			"tsdb":                     storage.UsingTSDB(t.cfg.SchemaConfig.Configs),
+			"memberlist":               t.usingMemberlist(),
+			"runtime_config":           t.cfg.RuntimeConfig.LoadPath != "",
+			"grpc_tls":                 t.cfg.Server.GRPCTLSConfig.TLSCertPath != "",
+			"tracing":                  t.cfg.Tracing.Enabled,
+			"query_sharding":           t.cfg.QueryRange.ShardedQueries,
+			"results_cache":            t.cfg.QueryRange.CacheResults,
+			"per_tenant_query_metrics": t.cfg.Querier.PerTenantMetricsMaxTenants > 0,
+			"ruler_remote_write":       t.cfg.Ruler.RemoteWrite.Enabled,
+			"retention":                t.cfg.CompactorConfig.RetentionEnabled,
+		},
+	}
+	if t.cfg.Target == Ingester || t.cfg.Target == All {
+		report.ChunkEncoding = t.cfg.Ingester.ChunkEncoding
+	}
+	return report
+}
+
+// usageReportStats returns the stats of the usage reports, at the time of each report.
+func (t *Loki) usageReportStats() map[string]float64 {
+	stats, err := usagereport.SumCounters(prometheus.DefaultGatherer, usageReportCounters...)
+	if err != nil {
+		level.Warn(util.Logger).Log("msg", "failed to gather the metrics of the usage report", "err", err)
+		stats = map[string]float64{}
+	}
+	if t.ring != nil {
+		stats["ingesters"] = float64(t.ring.IngesterCount())
+	}
+	return stats
+}
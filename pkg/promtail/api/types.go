@@ -24,6 +24,12 @@ func (e EntryHandlerFunc) Handle(labels model.LabelSet, time time.Time, entry st
 	return e(labels, time, entry)
 }
 
+// EntryFlusher is implemented by the entry handlers holding back entries, like the pipelines joining lines, to send
+// the entries held back when no more entries are coming.
+type EntryFlusher interface {
+	Flush() error
+}
+
 // EntryMiddleware is something that takes on EntryHandler and produces another.
 type EntryMiddleware interface {
 	Wrap(next EntryHandler) EntryHandler
@@ -78,6 +78,16 @@ var (
 		Name:      "batch_retries_total",
 		Help:      "Number of times batches has had to be retried.",
 	}, []string{HostLabel})
+	walBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "promtail",
+		Name:      "wal_bytes",
+		Help:      "Size of the batches spooled on disk, waiting to be sent.",
+	}, []string{HostLabel})
+	lastPush = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "promtail",
+		Name:      "last_push_timestamp_seconds",
+		Help:      "Unix timestamp of the last batch successfully sent.",
+	}, []string{HostLabel})
 	streamLag *metric.Gauges
 
 	countersWithHost = []*prometheus.CounterVec{
@@ -95,6 +105,8 @@ func init() {
 	prometheus.MustRegister(droppedEntries)
 	prometheus.MustRegister(requestDuration)
 	prometheus.MustRegister(batchRetries)
+	prometheus.MustRegister(walBytes)
+	prometheus.MustRegister(lastPush)
 	var err error
 	streamLag, err = metric.NewGauges("promtail_stream_lag_seconds",
 		"Difference between current time and last batch timestamp for successful sends",
@@ -123,6 +135,7 @@ type client struct {
 	once    sync.Once
 	entries chan entry
 	wg      sync.WaitGroup
+	wal     *wal
 
 	externalLabels model.LabelSet
 }
@@ -160,6 +173,16 @@ func New(cfg Config, logger log.Logger) (Client, error) {
 
 	c.client.Timeout = cfg.Timeout
 
+	if cfg.WAL.Enabled {
+		if err := cfg.WAL.Validate(); err != nil {
+			return nil, err
+		}
+		c.wal, err = newWAL(cfg.WAL, cfg.URL.Host, c.logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Initialize counters to 0 so the metrics are exported before the first
 	// occurrence of incrementing to avoid missing metrics.
 	for _, counter := range countersWithHost {
@@ -168,6 +191,10 @@ func New(cfg Config, logger log.Logger) (Client, error) {
 
 	c.wg.Add(1)
 	go c.run()
+	if c.wal != nil {
+		c.wg.Add(1)
+		go c.replay()
+	}
 	return c, nil
 }
 
@@ -246,17 +273,25 @@ func (c *client) sendBatch(tenantID string, batch *batch) {
 	bufBytes := float64(len(buf))
 	encodedBytes.WithLabelValues(c.cfg.URL.Host).Add(bufBytes)
 
+	// The batches spooled on disk are sent first, for the entries of the streams to be sent in order.
+	if c.wal != nil && c.wal.pending() {
+		c.spool(tenantID, buf, entriesCount)
+		return
+	}
+
 	ctx := context.Background()
 	backoff := util.NewBackoff(ctx, c.cfg.BackoffConfig)
 	var status int
+	var retryAfter time.Duration
 	for backoff.Ongoing() {
 		start := time.Now()
-		status, err = c.send(ctx, tenantID, buf)
+		status, retryAfter, err = c.send(ctx, tenantID, buf)
 		requestDuration.WithLabelValues(strconv.Itoa(status), c.cfg.URL.Host).Observe(time.Since(start).Seconds())
 
 		if err == nil {
 			sentBytes.WithLabelValues(c.cfg.URL.Host).Add(bufBytes)
 			sentEntries.WithLabelValues(c.cfg.URL.Host).Add(float64(entriesCount))
+			lastPush.WithLabelValues(c.cfg.URL.Host).SetToCurrentTime()
 			for _, s := range batch.streams {
 				lbls, err := parser.ParseMetric(s.Labels)
 				if err != nil {
@@ -280,29 +315,126 @@ func (c *client) sendBatch(tenantID string, batch *batch) {
 			return
 		}
 
-		// Only retry 429s, 500s and connection-level errors.
-		if status > 0 && status != 429 && status/100 != 5 {
+		if !retriable(status) {
 			break
 		}
 
 		level.Warn(c.logger).Log("msg", "error sending batch, will retry", "status", status, "error", err)
 		batchRetries.WithLabelValues(c.cfg.URL.Host).Inc()
-		backoff.Wait()
+
+		// Honour the Retry-After hint sent along with 429s, without ever waiting
+		// longer than the configured max backoff.
+		delay := backoff.NextDelay()
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if delay > c.cfg.BackoffConfig.MaxBackoff {
+			delay = c.cfg.BackoffConfig.MaxBackoff
+		}
+		if backoff.Ongoing() {
+			time.Sleep(delay)
+		}
 	}
 
 	if err != nil {
+		if c.wal != nil && retriable(status) {
+			level.Warn(c.logger).Log("msg", "error sending batch after all retries, spooling it to the WAL", "status", status, "error", err)
+			c.spool(tenantID, buf, entriesCount)
+			return
+		}
 		level.Error(c.logger).Log("msg", "final error sending batch", "status", status, "error", err)
 		droppedBytes.WithLabelValues(c.cfg.URL.Host).Add(bufBytes)
 		droppedEntries.WithLabelValues(c.cfg.URL.Host).Add(float64(entriesCount))
 	}
 }
 
-func (c *client) send(ctx context.Context, tenantID string, buf []byte) (int, error) {
+// retriable returns whether a push failing with the status code should be retried: only 429s, 500s and
+// connection-level errors are.
+func retriable(status int) bool {
+	return status <= 0 || status == 429 || status/100 == 5
+}
+
+// spool writes the batch to the WAL, to be sent by replay.
+func (c *client) spool(tenantID string, buf []byte, entriesCount int) {
+	if err := c.wal.append(tenantID, buf, entriesCount); err != nil {
+		level.Error(c.logger).Log("msg", "error spooling batch to the WAL", "error", err)
+		droppedBytes.WithLabelValues(c.cfg.URL.Host).Add(float64(len(buf)))
+		droppedEntries.WithLabelValues(c.cfg.URL.Host).Add(float64(entriesCount))
+	}
+}
+
+// replay sends the batches spooled to the WAL in order, retrying the oldest one until Loki can be reached again.
+func (c *client) replay() {
+	defer c.wg.Done()
+
+	// The batches are retried for as long as it takes, they are only dropped when the WAL is full.
+	backoff := util.NewBackoff(context.Background(), util.BackoffConfig{
+		MinBackoff: c.cfg.BackoffConfig.MinBackoff,
+		MaxBackoff: c.cfg.BackoffConfig.MaxBackoff,
+	})
+	for {
+		f, ok := c.wal.oldest()
+		if !ok {
+			select {
+			case <-c.quit:
+				return
+			case <-c.wal.notify:
+			}
+			continue
+		}
+
+		tenantID, buf, err := c.wal.read(f)
+		if err != nil {
+			level.Error(c.logger).Log("msg", "error reading batch from the WAL", "file", f.name, "error", err)
+			droppedEntries.WithLabelValues(c.cfg.URL.Host).Add(float64(f.entries))
+			c.wal.remove(f)
+			continue
+		}
+
+		start := time.Now()
+		status, retryAfter, err := c.send(context.Background(), tenantID, buf)
+		requestDuration.WithLabelValues(strconv.Itoa(status), c.cfg.URL.Host).Observe(time.Since(start).Seconds())
+		if err == nil {
+			sentBytes.WithLabelValues(c.cfg.URL.Host).Add(float64(len(buf)))
+			sentEntries.WithLabelValues(c.cfg.URL.Host).Add(float64(f.entries))
+			lastPush.WithLabelValues(c.cfg.URL.Host).SetToCurrentTime()
+			c.wal.remove(f)
+			backoff.Reset()
+			continue
+		}
+		if !retriable(status) {
+			level.Error(c.logger).Log("msg", "final error sending batch from the WAL", "status", status, "error", err)
+			droppedBytes.WithLabelValues(c.cfg.URL.Host).Add(float64(len(buf)))
+			droppedEntries.WithLabelValues(c.cfg.URL.Host).Add(float64(f.entries))
+			c.wal.remove(f)
+			continue
+		}
+
+		level.Warn(c.logger).Log("msg", "error sending batch from the WAL, will retry", "status", status, "error", err)
+		batchRetries.WithLabelValues(c.cfg.URL.Host).Inc()
+		delay := backoff.NextDelay()
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if delay > c.cfg.BackoffConfig.MaxBackoff {
+			delay = c.cfg.BackoffConfig.MaxBackoff
+		}
+		select {
+		case <-c.quit:
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// send pushes buf to Loki, returning the response status code and the
+// Retry-After delay suggested by the server, if any.
+func (c *client) send(ctx context.Context, tenantID string, buf []byte) (int, time.Duration, error) {
 	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
 	defer cancel()
 	req, err := http.NewRequest("POST", c.cfg.URL.String(), bytes.NewReader(buf))
 	if err != nil {
-		return -1, err
+		return -1, 0, err
 	}
 	req = req.WithContext(ctx)
 	req.Header.Set("Content-Type", contentType)
@@ -316,7 +448,7 @@ func (c *client) send(ctx context.Context, tenantID string, buf []byte) (int, er
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return -1, err
+		return -1, 0, err
 	}
 	defer helpers.LogError("closing response body", resp.Body.Close)
 
@@ -328,7 +460,17 @@ func (c *client) send(ctx context.Context, tenantID string, buf []byte) (int, er
 		}
 		err = fmt.Errorf("server returned HTTP status %s (%d): %s", resp.Status, resp.StatusCode, line)
 	}
-	return resp.StatusCode, err
+	return resp.StatusCode, parseRetryAfter(resp.Header.Get("Retry-After")), err
+}
+
+// parseRetryAfter parses a Retry-After header expressed in seconds, returning
+// 0 if the header is missing or malformed.
+func parseRetryAfter(v string) time.Duration {
+	seconds, err := strconv.Atoi(v)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
 }
 
 func (c *client) getTenantID(labels model.LabelSet) string {
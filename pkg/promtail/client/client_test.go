@@ -324,3 +324,15 @@ func createServerHandler(receivedReqsChan chan receivedReq, status int) http.Han
 		rw.WriteHeader(status)
 	})
 }
+
+func TestParseRetryAfter(t *testing.T) {
+	for v, expected := range map[string]time.Duration{
+		"":     0,
+		"3":    3 * time.Second,
+		"0":    0,
+		"-1":   0,
+		"soon": 0,
+	} {
+		assert.Equal(t, expected, parseRetryAfter(v), v)
+	}
+}
@@ -37,6 +37,9 @@ type Config struct {
 	// The tenant ID to use when pushing logs to Loki (empty string means
 	// single tenant mode)
 	TenantID string `yaml:"tenant_id"`
+
+	// The batches spooled on disk when Loki cannot be reached
+	WAL WALConfig `yaml:"wal"`
 }
 
 // RegisterFlags with prefix registers flags where every name is prefixed by
@@ -53,6 +56,8 @@ func (c *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.Var(&c.ExternalLabels, prefix+"client.external-labels", "list of external labels to add to each log (e.g: --client.external-labels=lb1=v1,lb2=v2)")
 
 	f.StringVar(&c.TenantID, prefix+"client.tenant-id", "", "Tenant ID to use when pushing logs to Loki.")
+
+	c.WAL.RegisterFlagsWithPrefix(prefix, f)
 }
 
 // RegisterFlags registers flags.
@@ -78,6 +83,9 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 			BatchSize: BatchSize,
 			BatchWait: BatchWait,
 			Timeout:   Timeout,
+			WAL: WALConfig{
+				MaxSize: WALMaxSize,
+			},
 		}
 	}
 
@@ -16,6 +16,9 @@ import (
 	lokiflag "github.com/famarks/loki/pkg/util/flagext"
 )
 
+// timeFormat is the format of the timestamps printed, precise enough to check the timestamps parsed by the pipelines.
+const timeFormat = "2006-01-02T15:04:05.000000000-0700"
+
 var (
 	yellow = color.New(color.FgYellow)
 	blue   = color.New(color.FgBlue)
@@ -61,7 +64,7 @@ func (*logger) Stop() {}
 func (l *logger) Handle(labels model.LabelSet, time time.Time, entry string) error {
 	l.Lock()
 	defer l.Unlock()
-	fmt.Fprint(l.Writer, blue.Sprint(time.Format("2006-01-02T15:04:05")))
+	fmt.Fprint(l.Writer, blue.Sprint(time.Format(timeFormat)))
 	fmt.Fprint(l.Writer, "\t")
 	fmt.Fprint(l.Writer, yellow.Sprint(labels.String()))
 	fmt.Fprint(l.Writer, "\t")
@@ -2,6 +2,8 @@ package client
 
 import (
 	"errors"
+	"fmt"
+	"path/filepath"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -20,6 +22,10 @@ func NewMulti(logger log.Logger, externalLabels flagext.LabelSet, cfgs ...Config
 		return nil, errors.New("at least one client config should be provided")
 	}
 
+	if err := validateMulti(cfgs); err != nil {
+		return nil, err
+	}
+
 	clients := make([]Client, 0, len(cfgs))
 	for _, cfg := range cfgs {
 		// Merge the provided external labels from the single client config/command line with each client config from
@@ -31,6 +37,8 @@ func NewMulti(logger log.Logger, externalLabels flagext.LabelSet, cfgs ...Config
 		cfg.ExternalLabels = flagext.LabelSet{LabelSet: externalLabels.Merge(cfg.ExternalLabels.LabelSet)}
 		client, err := New(cfg, logger)
 		if err != nil {
+			// Stop the clients already started, which would keep sending their batches otherwise.
+			MultiClient(clients).Stop()
 			return nil, err
 		}
 		clients = append(clients, client)
@@ -38,6 +46,30 @@ func NewMulti(logger log.Logger, externalLabels flagext.LabelSet, cfgs ...Config
 	return MultiClient(clients), nil
 }
 
+// validateMulti validates that the clients don't push the same entries twice, as the clients pushing to the same URL
+// with the same tenant ID would, nor share a WAL.
+func validateMulti(cfgs []Config) error {
+	pushes := map[string]struct{}{}
+	wals := map[string]struct{}{}
+	for _, cfg := range cfgs {
+		if cfg.URL.URL != nil {
+			push := fmt.Sprintf("%s %s", cfg.URL.String(), cfg.TenantID)
+			if _, ok := pushes[push]; ok {
+				return fmt.Errorf("duplicate client config for the URL %s and the tenant ID %q, the entries would be pushed twice", cfg.URL.String(), cfg.TenantID)
+			}
+			pushes[push] = struct{}{}
+		}
+		if cfg.WAL.Enabled {
+			dir := filepath.Clean(cfg.WAL.Dir)
+			if _, ok := wals[dir]; ok {
+				return fmt.Errorf("the client WAL directory %s is shared by several clients, it must be unique per client", dir)
+			}
+			wals[dir] = struct{}{}
+		}
+	}
+	return nil
+}
+
 // Handle Implements api.EntryHandler
 func (m MultiClient) Handle(labels model.LabelSet, time time.Time, entry string) error {
 	var result util.MultiError
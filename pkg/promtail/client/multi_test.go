@@ -77,6 +77,42 @@ func TestNewMulti(t *testing.T) {
 	}
 }
 
+func TestNewMulti_Duplicates(t *testing.T) {
+	prod, _ := url.Parse("http://loki:3100/loki/api/v1/push")
+	test, _ := url.Parse("http://loki-test:3100/loki/api/v1/push")
+
+	for name, tc := range map[string]struct {
+		cfgs []Config
+		err  string
+	}{
+		"different URLs": {
+			cfgs: []Config{{URL: flagext.URLValue{URL: prod}}, {URL: flagext.URLValue{URL: test}}},
+		},
+		"same URL with different tenant IDs": {
+			cfgs: []Config{{URL: flagext.URLValue{URL: prod}, TenantID: "a"}, {URL: flagext.URLValue{URL: prod}, TenantID: "b"}},
+		},
+		"same URL and tenant ID": {
+			cfgs: []Config{{URL: flagext.URLValue{URL: prod}, TenantID: "a"}, {URL: flagext.URLValue{URL: prod}, TenantID: "a"}},
+			err:  `duplicate client config for the URL http://loki:3100/loki/api/v1/push and the tenant ID "a", the entries would be pushed twice`,
+		},
+		"shared WAL directory": {
+			cfgs: []Config{
+				{URL: flagext.URLValue{URL: prod}, WAL: WALConfig{Enabled: true, Dir: "/wal"}},
+				{URL: flagext.URLValue{URL: test}, WAL: WALConfig{Enabled: true, Dir: "/wal/"}},
+			},
+			err: "the client WAL directory /wal is shared by several clients, it must be unique per client",
+		},
+	} {
+		err := validateMulti(tc.cfgs)
+		if tc.err == "" && err != nil {
+			t.Fatalf("%s: expected err: nil got:%v", name, err)
+		}
+		if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Fatalf("%s: expected err: %s got:%v", name, tc.err, err)
+		}
+	}
+}
+
 func TestMultiClient_Stop(t *testing.T) {
 	var stopped int
 
@@ -0,0 +1,213 @@
+package client
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+
+	lokiflag "github.com/famarks/loki/pkg/util/flagext"
+)
+
+const (
+	walFileSuffix = ".batch"
+	walTmpSuffix  = ".tmp"
+
+	// WALMaxSize is the default max size of the batches spooled on disk.
+	WALMaxSize = 1024 * 1024 * 1024
+)
+
+// WALConfig describes the configuration of the batches spooled on disk when Loki cannot be reached.
+type WALConfig struct {
+	Enabled bool              `yaml:"enabled"`
+	Dir     string            `yaml:"dir"`
+	MaxSize lokiflag.ByteSize `yaml:"max_size"`
+}
+
+// RegisterFlagsWithPrefix registers flags where every name is prefixed by prefix.
+func (c *WALConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	c.MaxSize = WALMaxSize
+	f.BoolVar(&c.Enabled, prefix+"client.wal.enabled", false, "Whether to spool the batches on disk when they cannot be sent after all retries, instead of dropping them.")
+	f.StringVar(&c.Dir, prefix+"client.wal.dir", "wal", "Directory of the batches spooled on disk, which must be unique per client.")
+	f.Var(&c.MaxSize, prefix+"client.wal.max-size", "Maximum size of the batches spooled on disk, the oldest batches being dropped over it.")
+}
+
+// Validate validates the WALConfig.
+func (c *WALConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Dir == "" {
+		return errors.New("client WAL needs a directory")
+	}
+	if c.MaxSize <= 0 {
+		return errors.New("client WAL max size must be greater than zero")
+	}
+	return nil
+}
+
+// walFile is a batch spooled on disk.
+type walFile struct {
+	name    string
+	size    int64
+	entries int
+}
+
+// wal spools on disk the batches which cannot be sent, to be replayed in order when Loki can be reached again. The
+// batches are stored in a file each, named after their sequence number and their number of entries.
+type wal struct {
+	logger  log.Logger
+	dir     string
+	maxSize int64
+	host    string
+
+	mtx    sync.Mutex
+	files  []walFile
+	size   int64
+	next   uint64
+	notify chan struct{}
+}
+
+// newWAL opens the WAL in the directory, loading the batches spooled before promtail restarted.
+func newWAL(cfg WALConfig, host string, logger log.Logger) (*wal, error) {
+	if err := os.MkdirAll(cfg.Dir, 0750); err != nil {
+		return nil, errors.Wrap(err, "creating the client WAL directory")
+	}
+	infos, err := ioutil.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading the client WAL directory")
+	}
+
+	w := &wal{
+		logger:  log.With(logger, "component", "wal"),
+		dir:     cfg.Dir,
+		maxSize: int64(cfg.MaxSize),
+		host:    host,
+		notify:  make(chan struct{}, 1),
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if strings.HasSuffix(name, walTmpSuffix) {
+			// A batch being written when promtail stopped.
+			_ = os.Remove(filepath.Join(w.dir, name))
+			continue
+		}
+		var seq uint64
+		var entries int
+		if _, err := fmt.Sscanf(name, "%020d-%d"+walFileSuffix, &seq, &entries); err != nil {
+			level.Warn(w.logger).Log("msg", "ignoring unknown file in the client WAL directory", "file", name)
+			continue
+		}
+		w.files = append(w.files, walFile{name: name, size: info.Size(), entries: entries})
+		w.size += info.Size()
+		if seq >= w.next {
+			w.next = seq + 1
+		}
+	}
+	// The names are zero padded, they sort in sequence order.
+	sort.Slice(w.files, func(i, j int) bool { return w.files[i].name < w.files[j].name })
+	walBytes.WithLabelValues(host).Set(float64(w.size))
+	return w, nil
+}
+
+// pending returns whether batches are spooled, the new batches having to be spooled after them to be sent in order.
+func (w *wal) pending() bool {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return len(w.files) > 0
+}
+
+// append spools a batch, dropping the oldest batches when the WAL would grow over its max size.
+func (w *wal) append(tenantID string, buf []byte, entries int) error {
+	record := make([]byte, 4, 4+len(tenantID)+len(buf))
+	binary.BigEndian.PutUint32(record, uint32(len(tenantID)))
+	record = append(record, tenantID...)
+	record = append(record, buf...)
+	if int64(len(record)) > w.maxSize {
+		return errors.New("batch is larger than the client WAL max size")
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	for w.size+int64(len(record)) > w.maxSize && len(w.files) > 0 {
+		oldest := w.files[0]
+		level.Warn(w.logger).Log("msg", "client WAL is full, dropping the oldest batch", "file", oldest.name)
+		droppedBytes.WithLabelValues(w.host).Add(float64(oldest.size))
+		droppedEntries.WithLabelValues(w.host).Add(float64(oldest.entries))
+		w.removeLocked(oldest)
+	}
+
+	f := walFile{name: fmt.Sprintf("%020d-%d"+walFileSuffix, w.next, entries), size: int64(len(record)), entries: entries}
+	path := filepath.Join(w.dir, f.name)
+	if err := ioutil.WriteFile(path+walTmpSuffix, record, 0640); err != nil {
+		return err
+	}
+	if err := os.Rename(path+walTmpSuffix, path); err != nil {
+		return err
+	}
+	w.next++
+	w.files = append(w.files, f)
+	w.size += f.size
+	walBytes.WithLabelValues(w.host).Set(float64(w.size))
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// oldest returns the oldest batch spooled, if any.
+func (w *wal) oldest() (walFile, bool) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if len(w.files) == 0 {
+		return walFile{}, false
+	}
+	return w.files[0], true
+}
+
+// read reads the tenant ID and the encoded batch of a spooled batch.
+func (w *wal) read(f walFile) (string, []byte, error) {
+	record, err := ioutil.ReadFile(filepath.Join(w.dir, f.name))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(record) < 4 || uint32(len(record)-4) < binary.BigEndian.Uint32(record) {
+		return "", nil, errors.Errorf("corrupted batch in the client WAL: %s", f.name)
+	}
+	n := 4 + binary.BigEndian.Uint32(record)
+	return string(record[4:n]), record[n:], nil
+}
+
+// remove removes a spooled batch, once sent or dropped.
+func (w *wal) remove(f walFile) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.removeLocked(f)
+}
+
+func (w *wal) removeLocked(f walFile) {
+	for i := range w.files {
+		if w.files[i].name != f.name {
+			continue
+		}
+		w.files = append(w.files[:i], w.files[i+1:]...)
+		w.size -= f.size
+		walBytes.WithLabelValues(w.host).Set(float64(w.size))
+		if err := os.Remove(filepath.Join(w.dir, f.name)); err != nil {
+			level.Warn(w.logger).Log("msg", "failed to remove batch from the client WAL", "file", f.name, "err", err)
+		}
+		return
+	}
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/logproto"
+)
+
+func TestWAL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := newWAL(WALConfig{Enabled: true, Dir: dir, MaxSize: 30}, "localhost", log.NewNopLogger())
+	require.NoError(t, err)
+	require.False(t, w.pending())
+
+	require.NoError(t, w.append("tenant-1", []byte("batch-1"), 1))
+	require.NoError(t, w.append("", []byte("batch-2"), 2))
+	require.True(t, w.pending())
+
+	// the WAL is reloaded in order after a restart, the leftovers of an interrupted write being removed
+	require.NoError(t, ioutil.WriteFile(dir+"/00000000000000000002-1.batch.tmp", []byte("partial"), 0640))
+	w, err = newWAL(WALConfig{Enabled: true, Dir: dir, MaxSize: 30}, "localhost", log.NewNopLogger())
+	require.NoError(t, err)
+	require.Equal(t, int64(30), w.size)
+	require.Equal(t, uint64(2), w.next)
+
+	f, ok := w.oldest()
+	require.True(t, ok)
+	require.Equal(t, 1, f.entries)
+	tenantID, buf, err := w.read(f)
+	require.NoError(t, err)
+	require.Equal(t, "tenant-1", tenantID)
+	require.Equal(t, []byte("batch-1"), buf)
+
+	// the oldest batch is dropped to make room for the new one
+	droppedEntries.Reset()
+	require.NoError(t, w.append("", []byte("batch-3"), 3))
+	require.Equal(t, float64(1), testutil.ToFloat64(droppedEntries.WithLabelValues("localhost")))
+	f, _ = w.oldest()
+	tenantID, buf, err = w.read(f)
+	require.NoError(t, err)
+	require.Equal(t, "", tenantID)
+	require.Equal(t, []byte("batch-2"), buf)
+
+	w.remove(f)
+	f, _ = w.oldest()
+	require.Equal(t, 3, f.entries)
+	w.remove(f)
+	require.False(t, w.pending())
+	infos, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, infos)
+
+	require.EqualError(t, w.append("", make([]byte, 30), 1), "batch is larger than the client WAL max size")
+}
+
+func TestClient_WAL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Loki is down until up is set
+	var up int32
+	received := make(chan logproto.PushRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.LoadInt32(&up) == 0 {
+			rw.WriteHeader(503)
+			return
+		}
+		var pushReq logproto.PushRequest
+		if err := util.ParseProtoReader(req.Context(), req.Body, int(req.ContentLength), math.MaxInt32, &pushReq, util.RawSnappy); err != nil {
+			rw.WriteHeader(500)
+			return
+		}
+		received <- pushReq
+	}))
+	defer server.Close()
+
+	serverURL := flagext.URLValue{}
+	require.NoError(t, serverURL.Set(server.URL))
+	c, err := New(Config{
+		URL:           serverURL,
+		BatchWait:     10 * time.Millisecond,
+		BatchSize:     10,
+		BackoffConfig: util.BackoffConfig{MinBackoff: 1 * time.Millisecond, MaxBackoff: 2 * time.Millisecond, MaxRetries: 2},
+		Timeout:       1 * time.Second,
+		WAL:           WALConfig{Enabled: true, Dir: dir, MaxSize: WALMaxSize},
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+	defer c.Stop()
+
+	// the batches failing after all retries are spooled, the next ones after them
+	for _, e := range logEntries[:3] {
+		require.NoError(t, c.Handle(e.labels, e.Timestamp, e.Line))
+	}
+	require.Eventually(t, func() bool {
+		infos, err := ioutil.ReadDir(dir)
+		return err == nil && len(infos) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	// the batches are replayed in order once Loki is up
+	atomic.StoreInt32(&up, 1)
+	for _, expected := range [][]logproto.Entry{
+		{logEntries[0].Entry, logEntries[1].Entry},
+		{logEntries[2].Entry},
+	} {
+		select {
+		case req := <-received:
+			require.Equal(t, []logproto.Stream{{Labels: "{}", Entries: expected}}, req.Streams)
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for the batches to be replayed")
+		}
+	}
+	require.Eventually(t, func() bool {
+		infos, err := ioutil.ReadDir(dir)
+		return err == nil && len(infos) == 0
+	}, time.Second, 10*time.Millisecond)
+}
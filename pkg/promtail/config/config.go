@@ -4,6 +4,7 @@ import (
 	"flag"
 
 	"github.com/famarks/loki/pkg/promtail/client"
+	"github.com/famarks/loki/pkg/promtail/limit"
 	"github.com/famarks/loki/pkg/promtail/positions"
 	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
 	"github.com/famarks/loki/pkg/promtail/server"
@@ -19,6 +20,7 @@ type Config struct {
 	PositionsConfig positions.Config      `yaml:"positions,omitempty"`
 	ScrapeConfig    []scrapeconfig.Config `yaml:"scrape_configs,omitempty"`
 	TargetConfig    file.Config           `yaml:"target_config,omitempty"`
+	LimitsConfig    limit.Config          `yaml:"limits_config,omitempty"`
 }
 
 // RegisterFlags with prefix registers flags where every name is prefixed by
@@ -28,6 +30,7 @@ func (c *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	c.ClientConfig.RegisterFlagsWithPrefix(prefix, f)
 	c.PositionsConfig.RegisterFlagsWithPrefix(prefix, f)
 	c.TargetConfig.RegisterFlagsWithPrefix(prefix, f)
+	c.LimitsConfig.RegisterFlagsWithPrefix(prefix, f)
 }
 
 // RegisterFlags registers flags.
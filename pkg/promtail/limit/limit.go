@@ -0,0 +1,265 @@
+package limit
+
+import (
+	"context"
+	"flag"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"golang.org/x/time/rate"
+
+	"github.com/famarks/loki/pkg/promtail/client"
+	"github.com/famarks/loki/pkg/util/flagext"
+)
+
+const (
+	// QueueFullBlock blocks the readers of the targets until the queue has room.
+	QueueFullBlock = "block"
+	// QueueFullDropOldest drops the oldest batch queued to make room.
+	QueueFullDropOldest = "drop_oldest"
+
+	// batchSize is the size of the batches of entries queued.
+	batchSize = client.BatchSize
+)
+
+var (
+	queuedBatches = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "promtail",
+		Name:      "limits_queued_batches",
+		Help:      "Number of batches queued ahead of the clients.",
+	})
+	queuedBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "promtail",
+		Name:      "limits_queued_bytes",
+		Help:      "Size of the lines queued ahead of the clients.",
+	})
+	droppedEntries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "limits_dropped_entries_total",
+		Help:      "Number of entries dropped because the queue was full.",
+	})
+	droppedBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "limits_dropped_bytes_total",
+		Help:      "Size of the lines dropped because the queue was full.",
+	})
+	blockedSeconds = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "limits_blocked_seconds_total",
+		Help:      "Time the readers of the targets were blocked because the queue was full.",
+	})
+)
+
+// Config describes the limits shared by all the targets of promtail, on the entries sent to the clients.
+type Config struct {
+	Enabled           bool             `yaml:"enabled"`
+	MaxBytesPerSecond flagext.ByteSize `yaml:"max_bytes_per_second"`
+	MaxBurstBytes     flagext.ByteSize `yaml:"max_burst_bytes"`
+	MaxQueuedBatches  int              `yaml:"max_queued_batches"`
+	MaxQueuedBytes    flagext.ByteSize `yaml:"max_queued_bytes"`
+	QueueFullAction   string           `yaml:"queue_full_action"`
+}
+
+// RegisterFlagsWithPrefix registers flags where every name is prefixed by prefix.
+func (c *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&c.Enabled, prefix+"limits.enabled", false, "Whether to limit the entries sent to the clients by all the targets.")
+	f.Var(&c.MaxBytesPerSecond, prefix+"limits.max-bytes-per-second", "Maximum rate of the lines sent to the clients, 0 for no limit.")
+	f.Var(&c.MaxBurstBytes, prefix+"limits.max-burst-bytes", "Maximum burst of the lines sent to the clients, the max bytes per second if 0.")
+	f.IntVar(&c.MaxQueuedBatches, prefix+"limits.max-queued-batches", 10, "Maximum number of batches of entries queued ahead of the clients.")
+	f.Var(&c.MaxQueuedBytes, prefix+"limits.max-queued-bytes", "Maximum size of the lines queued ahead of the clients, 0 for no limit.")
+	f.StringVar(&c.QueueFullAction, prefix+"limits.queue-full-action", QueueFullBlock, "What to do when the queue is full, block the readers or drop_oldest batch.")
+}
+
+// RegisterFlags registers flags.
+func (c *Config) RegisterFlags(f *flag.FlagSet) {
+	c.RegisterFlagsWithPrefix("", f)
+}
+
+// Validate validates the Config.
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.MaxQueuedBatches <= 0 {
+		return errors.New("limits max queued batches must be greater than zero")
+	}
+	if c.QueueFullAction != QueueFullBlock && c.QueueFullAction != QueueFullDropOldest {
+		return errors.Errorf("invalid limits queue full action %q, must be %s or %s", c.QueueFullAction, QueueFullBlock, QueueFullDropOldest)
+	}
+	return nil
+}
+
+type entry struct {
+	labels model.LabelSet
+	time   time.Time
+	line   string
+}
+
+type queuedBatch struct {
+	entries []entry
+	bytes   int
+}
+
+// limiter queues the entries of all the targets in batches ahead of the clients, sending them at the max rate. When
+// the queue is full, it blocks the readers or drops the oldest batch.
+type limiter struct {
+	cfg    Config
+	next   client.Client
+	logger log.Logger
+	rate   *rate.Limiter
+
+	mtx      sync.Mutex
+	cond     *sync.Cond
+	batches  []*queuedBatch
+	bytes    int
+	stopping bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New returns the client limiting the entries sent to next, or next itself when the limits are disabled.
+func New(cfg Config, next client.Client, logger log.Logger) (client.Client, error) {
+	if !cfg.Enabled {
+		return next, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &limiter{
+		cfg:    cfg,
+		next:   next,
+		logger: log.With(logger, "component", "limits"),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	l.cond = sync.NewCond(&l.mtx)
+	if cfg.MaxBytesPerSecond > 0 {
+		burst := cfg.MaxBurstBytes
+		if burst == 0 {
+			burst = cfg.MaxBytesPerSecond
+		}
+		l.rate = rate.NewLimiter(rate.Limit(cfg.MaxBytesPerSecond), int(burst))
+	}
+	go l.run()
+	return l, nil
+}
+
+// Handle implements api.EntryHandler, queuing the entry.
+func (l *limiter) Handle(labels model.LabelSet, t time.Time, line string) error {
+	size := len(line)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	for !l.fits(size) && !l.stopping {
+		if l.cfg.QueueFullAction == QueueFullDropOldest {
+			l.dropOldest()
+			continue
+		}
+		start := time.Now()
+		l.cond.Wait()
+		blockedSeconds.Add(time.Since(start).Seconds())
+	}
+	if l.stopping {
+		return errors.New("limits stopped, entry not sent")
+	}
+
+	if n := len(l.batches); n > 0 && l.batches[n-1].bytes+size <= batchSize {
+		l.batches[n-1].entries = append(l.batches[n-1].entries, entry{labels, t, line})
+		l.batches[n-1].bytes += size
+	} else {
+		l.batches = append(l.batches, &queuedBatch{entries: []entry{{labels, t, line}}, bytes: size})
+	}
+	l.bytes += size
+	l.updateMetrics()
+	l.cond.Broadcast()
+	return nil
+}
+
+// fits returns whether an entry of the size can be queued. An entry is always queued when the queue is empty, for the
+// entries larger than the limits to be sent.
+func (l *limiter) fits(size int) bool {
+	n := len(l.batches)
+	if n == 0 {
+		return true
+	}
+	if l.cfg.MaxQueuedBytes > 0 && l.bytes+size > int(l.cfg.MaxQueuedBytes) {
+		return false
+	}
+	return l.batches[n-1].bytes+size <= batchSize || n < l.cfg.MaxQueuedBatches
+}
+
+func (l *limiter) dropOldest() {
+	oldest := l.batches[0]
+	l.batches = l.batches[1:]
+	l.bytes -= oldest.bytes
+	droppedEntries.Add(float64(len(oldest.entries)))
+	droppedBytes.Add(float64(oldest.bytes))
+	level.Warn(l.logger).Log("msg", "queue is full, dropping the oldest batch", "entries", len(oldest.entries), "bytes", oldest.bytes)
+	l.updateMetrics()
+}
+
+func (l *limiter) updateMetrics() {
+	queuedBatches.Set(float64(len(l.batches)))
+	queuedBytes.Set(float64(l.bytes))
+}
+
+// run sends the batches queued to the clients, at the max rate, until stopped and all the batches are sent.
+func (l *limiter) run() {
+	defer close(l.done)
+
+	for {
+		l.mtx.Lock()
+		for len(l.batches) == 0 && !l.stopping {
+			l.cond.Wait()
+		}
+		if len(l.batches) == 0 {
+			l.mtx.Unlock()
+			return
+		}
+		batch := l.batches[0]
+		l.batches = l.batches[1:]
+		l.bytes -= batch.bytes
+		l.updateMetrics()
+		l.cond.Broadcast()
+		l.mtx.Unlock()
+
+		for _, e := range batch.entries {
+			if l.rate != nil {
+				n := len(e.line)
+				if n > l.rate.Burst() {
+					n = l.rate.Burst()
+				}
+				// Once stopping, the entries left are sent right away.
+				_ = l.rate.WaitN(l.ctx, n)
+			}
+			if err := l.next.Handle(e.labels, e.time, e.line); err != nil {
+				level.Error(l.logger).Log("msg", "error sending entry", "err", err)
+			}
+		}
+	}
+}
+
+// Stop implements client.Client, sending the entries queued before stopping the clients. The readers blocked are
+// released, their entries not being sent.
+func (l *limiter) Stop() {
+	l.mtx.Lock()
+	l.stopping = true
+	l.cond.Broadcast()
+	l.mtx.Unlock()
+
+	l.cancel()
+	<-l.done
+	l.next.Stop()
+}
@@ -0,0 +1,162 @@
+package limit
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/promtail/client/fake"
+)
+
+// recorder is a client recording the lines sent, blocked until released.
+type recorder struct {
+	fake.Client
+	mtx     sync.Mutex
+	lines   []string
+	release chan struct{}
+	stopped bool
+}
+
+func newRecorder(blocked bool) *recorder {
+	r := &recorder{release: make(chan struct{})}
+	if !blocked {
+		close(r.release)
+	}
+	r.OnHandleEntry = func(_ model.LabelSet, _ time.Time, line string) error {
+		<-r.release
+		r.mtx.Lock()
+		defer r.mtx.Unlock()
+		r.lines = append(r.lines, line)
+		return nil
+	}
+	r.OnStop = func() {
+		r.mtx.Lock()
+		defer r.mtx.Unlock()
+		r.stopped = true
+	}
+	return r
+}
+
+func (r *recorder) get() []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return append([]string(nil), r.lines...)
+}
+
+// line returns a line of the size with its first character set, for the lines to be told apart.
+func line(c string, size int) string {
+	return c + strings.Repeat(".", size-1)
+}
+
+func TestLimits_Disabled(t *testing.T) {
+	next := newRecorder(false)
+	c, err := New(Config{}, next, util.Logger)
+	require.NoError(t, err)
+	require.Equal(t, next, c)
+}
+
+func TestLimits_Validation(t *testing.T) {
+	_, err := New(Config{Enabled: true, QueueFullAction: QueueFullBlock}, newRecorder(false), util.Logger)
+	require.EqualError(t, err, "limits max queued batches must be greater than zero")
+
+	_, err = New(Config{Enabled: true, MaxQueuedBatches: 1, QueueFullAction: "drop"}, newRecorder(false), util.Logger)
+	require.EqualError(t, err, `invalid limits queue full action "drop", must be block or drop_oldest`)
+}
+
+func TestLimits_QueueFull(t *testing.T) {
+	for _, tc := range []struct {
+		action   string
+		expected []string
+	}{
+		// the reader is blocked until the queue has room
+		{QueueFullBlock, []string{"a", "b", "c"}},
+		// the oldest batch queued is dropped
+		{QueueFullDropOldest, []string{"a", "c"}},
+	} {
+		t.Run(tc.action, func(t *testing.T) {
+			next := newRecorder(true)
+			c, err := New(Config{Enabled: true, MaxQueuedBatches: 1, QueueFullAction: tc.action}, next, util.Logger)
+			require.NoError(t, err)
+			l := c.(*limiter)
+
+			// the lines do not fit in a batch together
+			size := batchSize/2 + 1
+			require.NoError(t, c.Handle(model.LabelSet{}, time.Now(), line("a", size)))
+			// a is being sent, b is queued
+			require.Eventually(t, func() bool {
+				l.mtx.Lock()
+				defer l.mtx.Unlock()
+				return len(l.batches) == 0
+			}, time.Second, time.Millisecond)
+			require.NoError(t, c.Handle(model.LabelSet{}, time.Now(), line("b", size)))
+
+			handled := make(chan struct{})
+			go func() {
+				require.NoError(t, c.Handle(model.LabelSet{}, time.Now(), line("c", size)))
+				close(handled)
+			}()
+			if tc.action == QueueFullBlock {
+				select {
+				case <-handled:
+					t.Fatal("Expected the reader to be blocked while the queue is full")
+				case <-time.After(50 * time.Millisecond):
+				}
+			} else {
+				<-handled
+			}
+
+			close(next.release)
+			<-handled
+			c.Stop()
+
+			var sent []string
+			for _, l := range next.get() {
+				sent = append(sent, l[:1])
+			}
+			require.Equal(t, tc.expected, sent)
+			require.True(t, next.stopped)
+		})
+	}
+}
+
+func TestLimits_MaxQueuedBytes(t *testing.T) {
+	next := newRecorder(true)
+	c, err := New(Config{Enabled: true, MaxQueuedBatches: 10, MaxQueuedBytes: 100, QueueFullAction: QueueFullDropOldest}, next, util.Logger)
+	require.NoError(t, err)
+	l := c.(*limiter)
+
+	require.NoError(t, c.Handle(model.LabelSet{}, time.Now(), line("a", 10)))
+	require.Eventually(t, func() bool {
+		l.mtx.Lock()
+		defer l.mtx.Unlock()
+		return len(l.batches) == 0
+	}, time.Second, time.Millisecond)
+	// the lines are queued in the same batch, dropped together once over the max bytes
+	require.NoError(t, c.Handle(model.LabelSet{}, time.Now(), line("b", 60)))
+	require.NoError(t, c.Handle(model.LabelSet{}, time.Now(), line("c", 30)))
+	require.NoError(t, c.Handle(model.LabelSet{}, time.Now(), line("d", 20)))
+
+	close(next.release)
+	c.Stop()
+	require.Equal(t, []string{line("a", 10), line("d", 20)}, next.get())
+}
+
+func TestLimits_MaxBytesPerSecond(t *testing.T) {
+	next := newRecorder(false)
+	c, err := New(Config{Enabled: true, MaxBytesPerSecond: 10000, MaxBurstBytes: 1000, MaxQueuedBatches: 1, QueueFullAction: QueueFullBlock}, next, util.Logger)
+	require.NoError(t, err)
+
+	start := time.Now()
+	for _, c1 := range []string{"a", "b", "c"} {
+		require.NoError(t, c.Handle(model.LabelSet{}, time.Now(), line(c1, 1000)))
+	}
+	// the burst is sent right away, the following lines at 10KB/s
+	require.Eventually(t, func() bool { return len(next.get()) == 3 }, 2*time.Second, time.Millisecond)
+	require.True(t, time.Since(start) >= 150*time.Millisecond, "sent in %s", time.Since(start))
+	c.Stop()
+}
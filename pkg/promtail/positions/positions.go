@@ -182,8 +182,12 @@ func (p *positions) cleanup() {
 	toRemove := []string{}
 	for k := range p.positions {
 		// If the position file is prefixed with journal, it's a
-		// JournalTarget cursor and not a file on disk.
-		if strings.HasPrefix(k, "journal-") {
+		// JournalTarget cursor and not a file on disk. If it's prefixed
+		// with windows, it's a WindowsTarget bookmark. If it's prefixed with
+		// docker, it's the timestamp of the last line read of a container, and
+		// with cloudflare the end of the last time range pulled of a zone.
+		if strings.HasPrefix(k, "journal-") || strings.HasPrefix(k, "windows-") || strings.HasPrefix(k, "docker-") ||
+			strings.HasPrefix(k, "cloudflare-") {
 			continue
 		}
 
@@ -8,6 +8,7 @@ import (
 
 	"github.com/famarks/loki/pkg/promtail/client"
 	"github.com/famarks/loki/pkg/promtail/config"
+	"github.com/famarks/loki/pkg/promtail/limit"
 	"github.com/famarks/loki/pkg/promtail/server"
 	"github.com/famarks/loki/pkg/promtail/targets"
 )
@@ -66,11 +67,20 @@ func New(cfg config.Config, dryRun bool, opts ...Option) (*Promtail, error) {
 			return nil, err
 		}
 		cfg.PositionsConfig.ReadOnly = true
+		if cfg.TargetConfig.Stdin {
+			// the lines piped are printed and promtail stops, there is nothing to serve
+			cfg.ServerConfig.Disable = true
+		}
 	} else {
 		promtail.client, err = client.NewMulti(promtail.logger, cfg.ClientConfig.ExternalLabels, cfg.ClientConfigs...)
 		if err != nil {
 			return nil, err
 		}
+		// the limits are shared by all the targets, sending their entries through the limited client
+		promtail.client, err = limit.New(cfg.LimitsConfig, promtail.client, promtail.logger)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	tms, err := targets.NewTargetManagers(promtail, promtail.logger, cfg.PositionsConfig, promtail.client, cfg.ScrapeConfig, &cfg.TargetConfig)
@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"time"
 
+	promconfig "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/discovery"
 	"github.com/prometheus/prometheus/discovery/azure"
@@ -24,17 +25,25 @@ import (
 	"github.com/weaveworks/common/server"
 
 	"github.com/famarks/loki/pkg/logentry/stages"
+	util_server "github.com/famarks/loki/pkg/util/server"
 )
 
 // Config describes a job to scrape.
 type Config struct {
-	JobName                string                 `yaml:"job_name,omitempty"`
-	PipelineStages         stages.PipelineStages  `yaml:"pipeline_stages,omitempty"`
-	JournalConfig          *JournalTargetConfig   `yaml:"journal,omitempty"`
-	SyslogConfig           *SyslogTargetConfig    `yaml:"syslog,omitempty"`
-	PushConfig             *PushTargetConfig      `yaml:"loki_push_api,omitempty"`
-	RelabelConfigs         []*relabel.Config      `yaml:"relabel_configs,omitempty"`
-	ServiceDiscoveryConfig ServiceDiscoveryConfig `yaml:",inline"`
+	JobName                string                      `yaml:"job_name,omitempty"`
+	PipelineStages         stages.PipelineStages       `yaml:"pipeline_stages,omitempty"`
+	JournalConfig          *JournalTargetConfig        `yaml:"journal,omitempty"`
+	SyslogConfig           *SyslogTargetConfig         `yaml:"syslog,omitempty"`
+	PushConfig             *PushTargetConfig           `yaml:"loki_push_api,omitempty"`
+	WindowsConfig          *WindowsEventsTargetConfig  `yaml:"windows_events,omitempty"`
+	KafkaConfig            *KafkaTargetConfig          `yaml:"kafka,omitempty"`
+	GcplogConfig           *GcplogTargetConfig         `yaml:"gcplog,omitempty"`
+	DockerSDConfigs        []*DockerSDConfig           `yaml:"docker_sd_configs,omitempty"`
+	HerokuDrainConfig      *HerokuDrainTargetConfig    `yaml:"heroku_drain,omitempty"`
+	CloudflareConfig       *CloudflareConfig           `yaml:"cloudflare,omitempty"`
+	AzureEventHubsConfig   *AzureEventHubsTargetConfig `yaml:"azure_event_hubs,omitempty"`
+	RelabelConfigs         []*relabel.Config           `yaml:"relabel_configs,omitempty"`
+	ServiceDiscoveryConfig ServiceDiscoveryConfig      `yaml:",inline"`
 }
 
 type ServiceDiscoveryConfig struct {
@@ -138,6 +147,15 @@ type JournalTargetConfig struct {
 	// of the journal.
 	Labels model.LabelSet `yaml:"labels"`
 
+	// FieldLabels optionally maps label names to journal fields, the labels
+	// being set to the value of the field of each record which has it.
+	FieldLabels map[model.LabelName]string `yaml:"field_labels"`
+
+	// Matches is a space separated list of FIELD=value matches filtering the
+	// records read from the journal. Matches of the same field are ORed,
+	// matches of different fields ANDed.
+	Matches string `yaml:"matches"`
+
 	// Path to a directory to read journal entries from. Defaults to system path
 	// if empty.
 	Path string `yaml:"path"`
@@ -148,6 +166,13 @@ type SyslogTargetConfig struct {
 	// ListenAddress is the address to listen on for syslog messages.
 	ListenAddress string `yaml:"listen_address"`
 
+	// ListenProtocol is the protocol to listen on for syslog messages, tcp or udp. Defaults to tcp.
+	ListenProtocol string `yaml:"listen_protocol"`
+
+	// TLSConfig enables TLS on the tcp connections when its certificate and key are set, the client certificates being
+	// required when its CA is set.
+	TLSConfig promconfig.TLSConfig `yaml:"tls_config,omitempty"`
+
 	// IdleTimeout is the idle timeout for tcp connections.
 	IdleTimeout time.Duration `yaml:"idle_timeout"`
 
@@ -158,6 +183,202 @@ type SyslogTargetConfig struct {
 
 	// Labels optionally holds labels to associate with each record read from syslog.
 	Labels model.LabelSet `yaml:"labels"`
+
+	// UseIncomingTimestamp sets the timestamp to the incoming syslog messages
+	// timestamp if it's set.
+	UseIncomingTimestamp bool `yaml:"use_incoming_timestamp"`
+}
+
+// WindowsEventsTargetConfig describes a scrape config that subscribes to events of the Windows Event Log.
+type WindowsEventsTargetConfig struct {
+	// EventlogName is the name of the channel to subscribe to, like Application, Security or
+	// Microsoft-Windows-Sysmon/Operational.
+	EventlogName string `yaml:"eventlog_name"`
+
+	// Query is the XPath query selecting the events of the channel, like *[System[(Level=1 or Level=2)]], all the
+	// events by default. A structured XML query, starting with <QueryList>, can select the events of several channels
+	// without EventlogName.
+	Query string `yaml:"xpath_query"`
+
+	// PollInterval is how often the subscription is checked for new events, when they are not signaled.
+	PollInterval time.Duration `yaml:"poll_interval"`
+
+	// ExcludeEventData excludes the EventData of the events from the log lines.
+	ExcludeEventData bool `yaml:"exclude_event_data"`
+
+	// ExcludeUserData excludes the UserData of the events from the log lines.
+	ExcludeUserData bool `yaml:"exclude_user_data"`
+
+	// UseIncomingTimestamp sets the timestamp of the entries to the time the events were created.
+	UseIncomingTimestamp bool `yaml:"use_incoming_timestamp"`
+
+	// Labels optionally holds labels to associate with each event.
+	Labels model.LabelSet `yaml:"labels"`
+}
+
+// KafkaTargetConfig describes a scrape config that consumes messages from Kafka topics.
+type KafkaTargetConfig struct {
+	// Brokers are the addresses of the Kafka brokers to bootstrap from, like localhost:9092.
+	Brokers []string `yaml:"brokers"`
+
+	// Topics are the topics to consume.
+	Topics []string `yaml:"topics"`
+
+	// GroupID is the consumer group the topics are consumed in. Defaults to promtail.
+	GroupID string `yaml:"group_id"`
+
+	// Version is the version of Kafka the brokers run, like 2.1.0. Defaults to 2.1.0.
+	Version string `yaml:"version"`
+
+	// Assignor is the strategy assigning the partitions to the members of the consumer group, range, roundrobin or
+	// sticky. Defaults to range.
+	Assignor string `yaml:"assignor"`
+
+	// Authentication configures how to authenticate to the brokers.
+	Authentication KafkaAuthentication `yaml:"authentication"`
+
+	// Labels optionally holds labels to associate with each message.
+	Labels model.LabelSet `yaml:"labels"`
+
+	// UseIncomingTimestamp sets the timestamp of the entries to the timestamp of the messages.
+	UseIncomingTimestamp bool `yaml:"use_incoming_timestamp"`
+}
+
+// KafkaAuthenticationType is the type of the authentication to the Kafka brokers.
+type KafkaAuthenticationType string
+
+// Types of the authentication to the Kafka brokers.
+const (
+	KafkaAuthenticationTypeNone = KafkaAuthenticationType("none")
+	KafkaAuthenticationTypeSSL  = KafkaAuthenticationType("ssl")
+	KafkaAuthenticationTypeSASL = KafkaAuthenticationType("sasl")
+)
+
+// KafkaAuthentication describes how to authenticate to the Kafka brokers.
+type KafkaAuthentication struct {
+	// Type is none, ssl or sasl. Defaults to none.
+	Type KafkaAuthenticationType `yaml:"type"`
+
+	// TLSConfig is used by the ssl authentication, and by the sasl one when it uses TLS.
+	TLSConfig promconfig.TLSConfig `yaml:"tls_config,omitempty"`
+
+	// SASLConfig is used by the sasl authentication.
+	SASLConfig KafkaSASLConfig `yaml:"sasl_config,omitempty"`
+}
+
+// KafkaSASLConfig describes the SASL authentication to the Kafka brokers.
+type KafkaSASLConfig struct {
+	// Mechanism is PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512. Defaults to PLAIN.
+	Mechanism string `yaml:"mechanism"`
+
+	User     string            `yaml:"user"`
+	Password promconfig.Secret `yaml:"password"`
+
+	// UseTLS connects to the brokers over TLS, configured by the tls_config of the authentication.
+	UseTLS bool `yaml:"use_tls"`
+}
+
+// AzureEventHubsTargetConfig describes a scrape config that consumes the events of Azure Event Hubs with their Kafka
+// endpoint, in a consumer group.
+type AzureEventHubsTargetConfig struct {
+	// FullyQualifiedNamespace is the Event Hubs namespace, like my-namespace.servicebus.windows.net. The port defaults
+	// to 9093.
+	FullyQualifiedNamespace string `yaml:"fully_qualified_namespace"`
+
+	// ConnectionString is the connection string of the namespace, or of a shared access policy of the event hubs.
+	ConnectionString promconfig.Secret `yaml:"connection_string"`
+
+	// EventHubs are the event hubs to consume.
+	EventHubs []string `yaml:"event_hubs"`
+
+	// GroupID is the consumer group the event hubs are consumed in, their offsets being checkpointed in it. Defaults to
+	// promtail.
+	GroupID string `yaml:"group_id"`
+
+	// Labels optionally holds labels to associate with each event.
+	Labels model.LabelSet `yaml:"labels"`
+
+	// UseIncomingTimestamp sets the timestamp of the entries to the time of the records of the events of the Azure
+	// resource logs, to the timestamp of the events otherwise.
+	UseIncomingTimestamp bool `yaml:"use_incoming_timestamp"`
+}
+
+// GcplogTargetConfig describes a scrape config that pulls the LogEntry messages of Google Cloud Logging from a Pub/Sub
+// subscription.
+type GcplogTargetConfig struct {
+	// ProjectID is the GCP project of the subscription.
+	ProjectID string `yaml:"project_id"`
+
+	// Subscription is the Pub/Sub subscription of the topic the logs are routed to by a sink.
+	Subscription string `yaml:"subscription"`
+
+	// CredentialsFile is the service account key file used to pull, the default application credentials being used
+	// when empty.
+	CredentialsFile string `yaml:"credentials_file"`
+
+	// MaxMessages is the maximum number of messages pulled at once. Defaults to 100.
+	MaxMessages int `yaml:"max_messages"`
+
+	// Labels optionally holds labels to associate with each log entry.
+	Labels model.LabelSet `yaml:"labels"`
+
+	// UseIncomingTimestamp sets the timestamp of the entries to the timestamp of the log entries.
+	UseIncomingTimestamp bool `yaml:"use_incoming_timestamp"`
+}
+
+// DockerSDConfig describes the discovery of the running containers of a Docker daemon, to read their logs.
+type DockerSDConfig struct {
+	HTTPClientConfig promconfig.HTTPClientConfig `yaml:",inline"`
+
+	// Host is the address of the Docker daemon. Defaults to unix:///var/run/docker.sock.
+	Host string `yaml:"host"`
+
+	// Filters restrict the containers discovered, like the filters of the docker ps command.
+	Filters []DockerFilter `yaml:"filters"`
+
+	// RefreshInterval is how often the containers are listed. Defaults to 5s.
+	RefreshInterval model.Duration `yaml:"refresh_interval"`
+}
+
+// DockerFilter is a filter of the containers listed, like name=web or label=app.
+type DockerFilter struct {
+	Name   string   `yaml:"name"`
+	Values []string `yaml:"values"`
+}
+
+// CloudflareConfig describes a job that pulls the HTTP request logs of a Cloudflare zone with the Logpull API.
+type CloudflareConfig struct {
+	// APIToken is the token of the API, with the Zone Logs Read permission.
+	APIToken promconfig.Secret `yaml:"api_token"`
+
+	// ZoneID is the ID of the zone to pull the logs of.
+	ZoneID string `yaml:"zone_id"`
+
+	// PullRange is the time range pulled at once, every pull range. Defaults to 1m.
+	PullRange model.Duration `yaml:"pull_range"`
+
+	// Fields are the fields of the logs pulled. Defaults to a set of the main fields of the requests.
+	Fields []string `yaml:"fields"`
+
+	// Labels optionally holds labels to associate with each log line pulled.
+	Labels model.LabelSet `yaml:"labels"`
+}
+
+// HerokuDrainTargetConfig describes a scrape config that listens for the logs of a Heroku Logplex HTTPS drain.
+type HerokuDrainTargetConfig struct {
+	// Server is the weaveworks server config for listening connections
+	Server server.Config `yaml:"server"`
+
+	// Labels optionally holds labels to associate with each log line received.
+	Labels model.LabelSet `yaml:"labels"`
+
+	// DrainTokens holds the labels to associate with the log lines of each drain token. When set, the drains with
+	// other tokens are rejected.
+	DrainTokens map[string]model.LabelSet `yaml:"drain_tokens"`
+
+	// UseIncomingTimestamp sets the timestamp to the incoming syslog messages
+	// timestamp if it's set.
+	UseIncomingTimestamp bool `yaml:"use_incoming_timestamp"`
 }
 
 // PushTargetConfig describes a scrape config that listens for Loki push messages.
@@ -170,6 +391,9 @@ type PushTargetConfig struct {
 
 	// If promtail should maintain the incoming log timestamp or replace it with the current time.
 	KeepTimestamp bool `yaml:"use_incoming_timestamp"`
+
+	// BasicAuth configures the credentials required to push, basic auth being disabled when the username is empty.
+	BasicAuth util_server.BasicAuthConfig `yaml:"basic_auth"`
 }
 
 // DefaultScrapeConfig is the default Config.
@@ -116,3 +116,35 @@ func TestLoadConfig(t *testing.T) {
 		panic(err)
 	}
 }
+
+var consulAndDockerSwarmYaml = `
+job_name: services
+consul_sd_configs:
+- server: consul:8500
+  services: [api]
+dockerswarm_sd_configs:
+- host: unix:///var/run/docker.sock
+  role: tasks
+relabel_configs:
+- source_labels: [__meta_dockerswarm_task_container_id]
+  target_label: __path__
+  replacement: /var/lib/docker/containers/$1/$1-json.log
+`
+
+func TestLoadConsulAndDockerSwarmConfig(t *testing.T) {
+	var config Config
+	err := yaml.Unmarshal([]byte(consulAndDockerSwarmYaml), &config)
+	require.NoError(t, err)
+
+	require.True(t, config.HasServiceDiscoveryConfig())
+	require.Len(t, config.ServiceDiscoveryConfig.ConsulSDConfigs, 1)
+	require.Equal(t, "consul:8500", config.ServiceDiscoveryConfig.ConsulSDConfigs[0].Server)
+	require.Equal(t, []string{"api"}, config.ServiceDiscoveryConfig.ConsulSDConfigs[0].Services)
+	require.Len(t, config.ServiceDiscoveryConfig.DockerSwarmSDConfigs, 1)
+	require.Equal(t, "tasks", config.ServiceDiscoveryConfig.DockerSwarmSDConfigs[0].Role)
+
+	configs := config.ServiceDiscoveryConfig.Configs()
+	require.Len(t, configs, 2)
+	require.Equal(t, "consul", configs[0].Name())
+	require.Equal(t, "dockerswarm", configs[1].Name())
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"net/http"
@@ -21,7 +22,9 @@ import (
 
 	"github.com/famarks/loki/pkg/promtail/server/ui"
 	"github.com/famarks/loki/pkg/promtail/targets"
+	"github.com/famarks/loki/pkg/promtail/targets/file"
 	"github.com/famarks/loki/pkg/promtail/targets/target"
+	util_server "github.com/famarks/loki/pkg/util/server"
 )
 
 var (
@@ -49,6 +52,8 @@ type Config struct {
 	ExternalURL       string `yaml:"external_url"`
 	HealthCheckTarget *bool  `yaml:"health_check_target"`
 	Disable           bool   `yaml:"disable"`
+
+	BasicAuth util_server.BasicAuthConfig `yaml:"basic_auth"`
 }
 
 // RegisterFlags with prefix registers flags where every name is prefixed by
@@ -58,6 +63,7 @@ func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	cfg.Config.RegisterFlags(f)
 
 	f.BoolVar(&cfg.Disable, prefix+"server.disable", false, "Disable the http and grpc server.")
+	cfg.BasicAuth.RegisterFlagsWithPrefix(prefix+"server.", f)
 }
 
 // RegisterFlags adds the flags required to config this to the given FlagSet
@@ -70,6 +76,13 @@ func New(cfg Config, log log.Logger, tms *targets.TargetManagers) (Server, error
 	if cfg.Disable {
 		return newNoopServer(log), nil
 	}
+	basicAuth, err := util_server.NewBasicAuth(cfg.BasicAuth)
+	if err != nil {
+		return nil, err
+	}
+	if basicAuth != nil {
+		basicAuth.AddToServerConfig(&cfg.Config)
+	}
 	wws, err := serverww.New(cfg.Config)
 	if err != nil {
 		return nil, err
@@ -99,6 +112,7 @@ func New(cfg Config, log log.Logger, tms *targets.TargetManagers) (Server, error
 	serv.HTTP.PathPrefix("/static/").Handler(http.FileServer(ui.Assets))
 	serv.HTTP.Path("/service-discovery").Handler(http.HandlerFunc(serv.serviceDiscovery))
 	serv.HTTP.Path("/targets").Handler(http.HandlerFunc(serv.targets))
+	serv.HTTP.Path("/positions").Handler(http.HandlerFunc(serv.positions))
 	return serv, nil
 
 }
@@ -204,6 +218,43 @@ func (s *server) targets(rw http.ResponseWriter, req *http.Request) {
 	})
 }
 
+// jobFileStatus is how far a file of a job has been read.
+type jobFileStatus struct {
+	Job string `json:"job"`
+	file.FileStatus
+}
+
+// positions serves the read positions and the lag of the files tailed, sorted by job and path.
+func (s *server) positions(rw http.ResponseWriter, _ *http.Request) {
+	var jobs []string
+	activeTargets := s.tms.ActiveTargets()
+	for job := range activeTargets {
+		jobs = append(jobs, job)
+	}
+	sort.Strings(jobs)
+
+	files := []jobFileStatus{}
+	for _, job := range jobs {
+		var statuses []file.FileStatus
+		for _, t := range activeTargets[job] {
+			if ft, ok := t.(*file.FileTarget); ok {
+				statuses = append(statuses, ft.FileStatuses()...)
+			}
+		}
+		sort.Slice(statuses, func(i, j int) bool { return statuses[i].Path < statuses[j].Path })
+		for _, status := range statuses {
+			files = append(files, jobFileStatus{Job: job, FileStatus: status})
+		}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(struct {
+		Files []jobFileStatus `json:"files"`
+	}{files}); err != nil {
+		level.Error(s.log).Log("msg", "error writing positions", "error", err)
+	}
+}
+
 // ready serves the ready endpoint
 func (s *server) ready(rw http.ResponseWriter, _ *http.Request) {
 	if s.healthCheckTarget && !s.tms.Ready() {
@@ -0,0 +1,164 @@
+package azureeventhubs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/relabel"
+
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+	"github.com/famarks/loki/pkg/promtail/targets/kafka"
+	"github.com/famarks/loki/pkg/promtail/targets/target"
+)
+
+const (
+	// kafkaPort is the port of the Kafka endpoint of the Event Hubs namespaces.
+	kafkaPort = "9093"
+	// kafkaVersion is the version of Kafka the Kafka endpoint of Event Hubs is compatible with.
+	kafkaVersion = "1.0.0"
+	// connectionStringUser is the SASL user authenticating with the connection string as password.
+	connectionStringUser = "$ConnectionString"
+)
+
+// eventHubsLabels copies the internal labels of the messages consumed to the ones of the events, before the
+// relabeling of the job.
+var eventHubsLabels = []*relabel.Config{
+	copyLabel("__kafka_topic", "__azure_event_hubs_event_hub"),
+	copyLabel("__kafka_partition", "__azure_event_hubs_partition"),
+	copyLabel("__kafka_group_id", "__azure_event_hubs_group_id"),
+	copyLabel("__kafka_message_key", "__azure_event_hubs_partition_key"),
+}
+
+func copyLabel(source, target model.LabelName) *relabel.Config {
+	return &relabel.Config{
+		SourceLabels: model.LabelNames{source},
+		Separator:    ";",
+		Regex:        relabel.MustNewRegexp("(.+)"),
+		Replacement:  "$1",
+		TargetLabel:  string(target),
+		Action:       relabel.Replace,
+	}
+}
+
+// AzureEventHubsTarget consumes the events of Azure Event Hubs with their Kafka endpoint, the records of the Azure
+// resource logs being sent as separate lines.
+// nolint:golint
+type AzureEventHubsTarget struct {
+	*kafka.KafkaTarget
+}
+
+// NewAzureEventHubsTarget joins the consumer group of the config and starts consuming its event hubs.
+func NewAzureEventHubsTarget(
+	logger log.Logger,
+	handler api.EntryHandler,
+	jobName string,
+	relabelConfig []*relabel.Config,
+	config *scrapeconfig.AzureEventHubsTargetConfig,
+) (*AzureEventHubsTarget, error) {
+	kafkaConfig, err := newKafkaConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid azure_event_hubs config of job %s: %w", jobName, err)
+	}
+	t, err := kafka.NewKafkaTarget(logger, &recordsHandler{handler: handler, config: config}, jobName,
+		append(append([]*relabel.Config(nil), eventHubsLabels...), relabelConfig...), kafkaConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &AzureEventHubsTarget{KafkaTarget: t}, nil
+}
+
+// newKafkaConfig returns the config of the consumption of the event hubs with their Kafka endpoint, authenticating
+// with the connection string over TLS.
+func newKafkaConfig(config *scrapeconfig.AzureEventHubsTargetConfig) (*scrapeconfig.KafkaTargetConfig, error) {
+	if config.FullyQualifiedNamespace == "" {
+		return nil, errors.New("no fully_qualified_namespace")
+	}
+	if config.ConnectionString == "" {
+		return nil, errors.New("no connection_string")
+	}
+	if len(config.EventHubs) == 0 {
+		return nil, errors.New("no event_hubs")
+	}
+
+	broker := config.FullyQualifiedNamespace
+	if _, _, err := net.SplitHostPort(broker); err != nil {
+		broker = net.JoinHostPort(broker, kafkaPort)
+	}
+	return &scrapeconfig.KafkaTargetConfig{
+		Brokers: []string{broker},
+		Topics:  config.EventHubs,
+		GroupID: config.GroupID,
+		Version: kafkaVersion,
+		Authentication: scrapeconfig.KafkaAuthentication{
+			Type: scrapeconfig.KafkaAuthenticationTypeSASL,
+			SASLConfig: scrapeconfig.KafkaSASLConfig{
+				Mechanism: sarama.SASLTypePlaintext,
+				User:      connectionStringUser,
+				Password:  config.ConnectionString,
+				UseTLS:    true,
+			},
+		},
+		Labels:               config.Labels,
+		UseIncomingTimestamp: config.UseIncomingTimestamp,
+	}, nil
+}
+
+// Type returns AzureEventHubsTargetType.
+func (t *AzureEventHubsTarget) Type() target.TargetType {
+	return target.AzureEventHubsTargetType
+}
+
+// resourceLogs are the events of the Azure resource logs, batches of records.
+type resourceLogs struct {
+	Records []json.RawMessage `json:"records"`
+}
+
+// recordsHandler sends the records of the events of the Azure resource logs as separate lines, the other events being
+// sent as is.
+type recordsHandler struct {
+	handler api.EntryHandler
+	config  *scrapeconfig.AzureEventHubsTargetConfig
+}
+
+// Handle implements api.EntryHandler.
+func (h *recordsHandler) Handle(labels model.LabelSet, t time.Time, entry string) error {
+	var logs resourceLogs
+	if !strings.HasPrefix(strings.TrimSpace(entry), "{") || json.Unmarshal([]byte(entry), &logs) != nil || len(logs.Records) == 0 {
+		return h.handler.Handle(labels, t, entry)
+	}
+
+	var lastErr error
+	for _, record := range logs.Records {
+		ts := t
+		if h.config.UseIncomingTimestamp {
+			ts = recordTime(record, t)
+		}
+		if err := h.handler.Handle(labels.Clone(), ts, string(record)); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// recordTime returns the time of the record, the time of the event when it has none.
+func recordTime(record json.RawMessage, t time.Time) time.Time {
+	var r struct {
+		Time string `json:"time"`
+	}
+	if err := json.Unmarshal(record, &r); err != nil || r.Time == "" {
+		return t
+	}
+	ts, err := time.Parse(time.RFC3339Nano, r.Time)
+	if err != nil {
+		return t
+	}
+	return ts
+}
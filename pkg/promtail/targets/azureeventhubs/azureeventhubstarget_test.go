@@ -0,0 +1,106 @@
+package azureeventhubs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+)
+
+const resourceLogsEvent = `{"records": [
+  {"time": "2020-10-15T12:00:00.1234567Z", "category": "AuditEvent", "operationName": "VaultGet"},
+  {"time": "2020-10-15T12:00:01Z", "category": "AuditEvent", "operationName": "SecretGet"}
+]}`
+
+type handledEntry struct {
+	labels model.LabelSet
+	time   time.Time
+	line   string
+}
+
+func TestRecordsHandler(t *testing.T) {
+	var handled []handledEntry
+	handler := api.EntryHandlerFunc(func(labels model.LabelSet, time time.Time, entry string) error {
+		handled = append(handled, handledEntry{labels, time, entry})
+		if entry == "fails" {
+			return errors.New("full")
+		}
+		return nil
+	})
+	ls := model.LabelSet{"job": "azure"}
+	now := time.Now()
+
+	h := &recordsHandler{handler: handler, config: &scrapeconfig.AzureEventHubsTargetConfig{UseIncomingTimestamp: true}}
+	require.NoError(t, h.Handle(ls, now, resourceLogsEvent))
+	require.NoError(t, h.Handle(ls, now, `{"message": "not resource logs"}`))
+	require.NoError(t, h.Handle(ls, now, "plain text"))
+	require.Error(t, h.Handle(ls, now, "fails"))
+	require.Equal(t, []handledEntry{
+		{ls, time.Date(2020, 10, 15, 12, 0, 0, 123456700, time.UTC), `{"time": "2020-10-15T12:00:00.1234567Z", "category": "AuditEvent", "operationName": "VaultGet"}`},
+		{ls, time.Date(2020, 10, 15, 12, 0, 1, 0, time.UTC), `{"time": "2020-10-15T12:00:01Z", "category": "AuditEvent", "operationName": "SecretGet"}`},
+		{ls, now, `{"message": "not resource logs"}`},
+		{ls, now, "plain text"},
+		{ls, now, "fails"},
+	}, handled)
+
+	// the records are timestamped with the time of the event when not using the incoming timestamp
+	handled = nil
+	h = &recordsHandler{handler: handler, config: &scrapeconfig.AzureEventHubsTargetConfig{}}
+	require.NoError(t, h.Handle(ls, now, resourceLogsEvent))
+	require.Len(t, handled, 2)
+	require.Equal(t, now, handled[0].time)
+	require.Equal(t, now, handled[1].time)
+}
+
+func TestNewKafkaConfig(t *testing.T) {
+	c, err := newKafkaConfig(&scrapeconfig.AzureEventHubsTargetConfig{
+		FullyQualifiedNamespace: "my-namespace.servicebus.windows.net",
+		ConnectionString:        "Endpoint=sb://my-namespace.servicebus.windows.net/;SharedAccessKeyName=promtail;SharedAccessKey=key",
+		EventHubs:               []string{"insights-logs-auditevent"},
+		Labels:                  model.LabelSet{"job": "azure"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"my-namespace.servicebus.windows.net:9093"}, c.Brokers)
+	require.Equal(t, []string{"insights-logs-auditevent"}, c.Topics)
+	require.Equal(t, scrapeconfig.KafkaAuthenticationTypeSASL, c.Authentication.Type)
+	require.Equal(t, "$ConnectionString", c.Authentication.SASLConfig.User)
+	require.True(t, c.Authentication.SASLConfig.UseTLS)
+	require.Equal(t, model.LabelSet{"job": "azure"}, c.Labels)
+
+	c, err = newKafkaConfig(&scrapeconfig.AzureEventHubsTargetConfig{
+		FullyQualifiedNamespace: "localhost:9092",
+		ConnectionString:        "secret",
+		EventHubs:               []string{"logs"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"localhost:9092"}, c.Brokers)
+
+	for _, cfg := range []scrapeconfig.AzureEventHubsTargetConfig{
+		{ConnectionString: "secret", EventHubs: []string{"logs"}},
+		{FullyQualifiedNamespace: "localhost", EventHubs: []string{"logs"}},
+		{FullyQualifiedNamespace: "localhost", ConnectionString: "secret"},
+	} {
+		cfg := cfg
+		_, err := newKafkaConfig(&cfg)
+		require.Error(t, err)
+	}
+}
+
+func TestEventHubsLabels(t *testing.T) {
+	processed := relabel.Process(labels.FromStrings(
+		"__kafka_topic", "insights-logs-auditevent",
+		"__kafka_partition", "3",
+		"__kafka_group_id", "promtail",
+	), eventHubsLabels...)
+	require.Equal(t, "insights-logs-auditevent", processed.Get("__azure_event_hubs_event_hub"))
+	require.Equal(t, "3", processed.Get("__azure_event_hubs_partition"))
+	require.Equal(t, "promtail", processed.Get("__azure_event_hubs_group_id"))
+	require.False(t, processed.Has("__azure_event_hubs_partition_key"))
+}
@@ -0,0 +1,85 @@
+package azureeventhubs
+
+import (
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/famarks/loki/pkg/logentry/stages"
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+	"github.com/famarks/loki/pkg/promtail/targets/target"
+)
+
+// AzureEventHubsTargetManager manages a series of AzureEventHubsTargets.
+// nolint:golint
+type AzureEventHubsTargetManager struct {
+	logger  log.Logger
+	targets map[string]*AzureEventHubsTarget
+}
+
+// NewAzureEventHubsTargetManager creates a new AzureEventHubsTargetManager.
+func NewAzureEventHubsTargetManager(
+	logger log.Logger,
+	client api.EntryHandler,
+	scrapeConfigs []scrapeconfig.Config,
+) (*AzureEventHubsTargetManager, error) {
+
+	tm := &AzureEventHubsTargetManager{
+		logger:  logger,
+		targets: make(map[string]*AzureEventHubsTarget),
+	}
+
+	for _, cfg := range scrapeConfigs {
+		registerer := prometheus.DefaultRegisterer
+		pipeline, err := stages.NewPipeline(log.With(logger, "component", "azure_event_hubs_pipeline"), cfg.PipelineStages, &cfg.JobName, registerer)
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := NewAzureEventHubsTarget(logger, pipeline.Wrap(client), cfg.JobName, cfg.RelabelConfigs, cfg.AzureEventHubsConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		tm.targets[cfg.JobName] = t
+	}
+
+	return tm, nil
+}
+
+// Ready returns true if at least one AzureEventHubsTarget is also ready.
+func (tm *AzureEventHubsTargetManager) Ready() bool {
+	for _, t := range tm.targets {
+		if t.Ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// Stop stops the AzureEventHubsTargetManager and all of its AzureEventHubsTargets.
+func (tm *AzureEventHubsTargetManager) Stop() {
+	for _, t := range tm.targets {
+		if err := t.Stop(); err != nil {
+			level.Error(tm.logger).Log("msg", "error stopping AzureEventHubsTarget", "err", err.Error())
+		}
+	}
+}
+
+// ActiveTargets returns the list of AzureEventHubsTargets where Event Hubs events
+// are being consumed. ActiveTargets is an alias to AllTargets as
+// AzureEventHubsTargets cannot be deactivated, only stopped.
+func (tm *AzureEventHubsTargetManager) ActiveTargets() map[string][]target.Target {
+	return tm.AllTargets()
+}
+
+// AllTargets returns the list of all targets where Event Hubs events
+// are currently being consumed.
+func (tm *AzureEventHubsTargetManager) AllTargets() map[string][]target.Target {
+	result := make(map[string][]target.Target, len(tm.targets))
+	for k, v := range tm.targets {
+		result[k] = []target.Target{v}
+	}
+	return result
+}
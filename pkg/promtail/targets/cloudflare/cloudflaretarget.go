@@ -0,0 +1,350 @@
+package cloudflare
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"go.uber.org/atomic"
+
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/positions"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+	"github.com/famarks/loki/pkg/promtail/targets/target"
+)
+
+var (
+	cloudflareEntries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "cloudflare_target_entries_total",
+		Help:      "Total number of log lines pulled from Cloudflare.",
+	}, []string{"zone_id"})
+	cloudflareLastEnd = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "promtail",
+		Name:      "cloudflare_target_last_requested_end_timestamp",
+		Help:      "The end of the last time range pulled from Cloudflare, in seconds since the epoch.",
+	}, []string{"zone_id"})
+)
+
+const (
+	defaultEndpoint  = "https://api.cloudflare.com/client/v4"
+	defaultPullRange = time.Minute
+	dataset          = "http_requests"
+
+	// delay is the time after which the logs of the requests are available with the Logpull API.
+	delay = time.Minute
+	// maxRange and maxRetention are the largest time range that can be pulled at once and how old the logs that can
+	// be pulled can be.
+	maxRange     = time.Hour
+	maxRetention = 7 * 24 * time.Hour
+	// timestampField is the field of the time of the requests, always pulled.
+	timestampField = "EdgeStartTimestamp"
+)
+
+var defaultFields = []string{
+	"ClientIP", "ClientRequestHost", "ClientRequestMethod", "ClientRequestURI", "EdgeEndTimestamp",
+	"EdgeResponseBytes", "EdgeRequestHost", "EdgeResponseStatus", "EdgeStartTimestamp", "RayID",
+}
+
+// CloudflareTarget pulls the HTTP request logs of a Cloudflare zone every pull range, saving the end of the last time
+// range pulled for the logs to be pulled from there when restarted.
+// nolint:golint
+type CloudflareTarget struct {
+	logger    log.Logger
+	handler   api.EntryHandler
+	positions positions.Positions
+	jobName   string
+	config    *scrapeconfig.CloudflareConfig
+	// labels are the labels of the log lines once relabeled, nil when dropped.
+	labels model.LabelSet
+
+	endpoint string
+	client   *http.Client
+	ready    atomic.Bool
+
+	mtx sync.Mutex
+	err error
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewCloudflareTarget starts pulling the logs of the zone of the config.
+func NewCloudflareTarget(
+	logger log.Logger,
+	handler api.EntryHandler,
+	positions positions.Positions,
+	jobName string,
+	relabelConfig []*relabel.Config,
+	config *scrapeconfig.CloudflareConfig,
+) (*CloudflareTarget, error) {
+	return newCloudflareTarget(logger, handler, positions, jobName, relabelConfig, config, defaultEndpoint)
+}
+
+func newCloudflareTarget(
+	logger log.Logger,
+	handler api.EntryHandler,
+	positions positions.Positions,
+	jobName string,
+	relabelConfig []*relabel.Config,
+	config *scrapeconfig.CloudflareConfig,
+	endpoint string,
+) (*CloudflareTarget, error) {
+	if config.ZoneID == "" || config.APIToken == "" {
+		return nil, fmt.Errorf("the zone_id and the api_token of the cloudflare config of job %s are required", jobName)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &CloudflareTarget{
+		logger:    log.With(logger, "job", jobName, "zone_id", config.ZoneID),
+		handler:   handler,
+		positions: positions,
+		jobName:   jobName,
+		config:    config,
+		labels:    zoneLabels(config, relabelConfig),
+		endpoint:  endpoint,
+		client:    &http.Client{Timeout: maxRange / 2},
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	t.wg.Add(1)
+	go t.run()
+	return t, nil
+}
+
+// zoneLabels returns the labels of the log lines of the zone once relabeled, without the internal ones. The labels are
+// nil when the relabeling drops them.
+func zoneLabels(config *scrapeconfig.CloudflareConfig, relabelConfig []*relabel.Config) model.LabelSet {
+	lb := labels.NewBuilder(nil)
+	for k, v := range config.Labels {
+		lb.Set(string(k), string(v))
+	}
+	lb.Set("__cloudflare_zone_id", config.ZoneID)
+	lb.Set("__cloudflare_dataset", dataset)
+
+	processed := relabel.Process(lb.Labels(), relabelConfig...)
+	if processed == nil {
+		return nil
+	}
+	ls := make(model.LabelSet, len(processed))
+	for _, l := range processed {
+		if strings.HasPrefix(l.Name, "__") {
+			continue
+		}
+		ls[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+	}
+	return ls
+}
+
+func (t *CloudflareTarget) positionKey() string {
+	return "cloudflare-" + t.config.ZoneID
+}
+
+func (t *CloudflareTarget) pullRange() time.Duration {
+	if t.config.PullRange <= 0 {
+		return defaultPullRange
+	}
+	return time.Duration(t.config.PullRange)
+}
+
+func (t *CloudflareTarget) fields() []string {
+	fields := t.config.Fields
+	if len(fields) == 0 {
+		fields = defaultFields
+	}
+	for _, f := range fields {
+		if f == timestampField {
+			return fields
+		}
+	}
+	return append(append([]string(nil), fields...), timestampField)
+}
+
+// start returns the start of the next time range to pull, the end of the last one pulled.
+func (t *CloudflareTarget) start(now time.Time) time.Time {
+	oldest := now.Add(-maxRetention).Add(delay)
+	if pos := t.positions.GetString(t.positionKey()); pos != "" {
+		if ns, err := strconv.ParseInt(pos, 10, 64); err == nil {
+			start := time.Unix(0, ns)
+			if start.Before(oldest) {
+				level.Warn(t.logger).Log("msg", "the logs saved are too old to be pulled, pulling the oldest ones", "position", start)
+				return oldest
+			}
+			return start
+		}
+		level.Warn(t.logger).Log("msg", "ignoring invalid position", "position", pos)
+	}
+	return now.Add(-delay).Add(-t.pullRange())
+}
+
+func (t *CloudflareTarget) run() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(t.pullRange())
+	defer ticker.Stop()
+	for {
+		// the ranges are pulled right away until caught up
+		for t.ctx.Err() == nil {
+			pulled, err := t.pull(time.Now())
+			t.mtx.Lock()
+			t.err = err
+			t.mtx.Unlock()
+			t.ready.Store(err == nil)
+			if err != nil {
+				if t.ctx.Err() == nil {
+					level.Error(t.logger).Log("msg", "error pulling logs, the range will be pulled again", "err", err)
+				}
+				break
+			}
+			if pulled < maxRange {
+				break
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-t.ctx.Done():
+			return
+		}
+	}
+}
+
+// pull pulls the logs of the next time range available and returns its duration, saving its end once sent.
+func (t *CloudflareTarget) pull(now time.Time) (time.Duration, error) {
+	start := t.start(now)
+	end := now.Add(-delay)
+	if end.Sub(start) > maxRange {
+		end = start.Add(maxRange)
+	}
+	if !end.After(start) {
+		return 0, nil
+	}
+
+	if err := t.pullLogs(start, end); err != nil {
+		return 0, err
+	}
+	t.positions.PutString(t.positionKey(), strconv.FormatInt(end.UnixNano(), 10))
+	cloudflareLastEnd.WithLabelValues(t.config.ZoneID).Set(float64(end.Unix()))
+	return end.Sub(start), nil
+}
+
+// pullLogs sends the logs of the requests of the time range, pulled as JSON lines.
+func (t *CloudflareTarget) pullLogs(start, end time.Time) error {
+	q := url.Values{}
+	q.Set("start", strconv.FormatInt(start.UnixNano(), 10))
+	q.Set("end", strconv.FormatInt(end.UnixNano(), 10))
+	q.Set("fields", strings.Join(t.fields(), ","))
+	q.Set("timestamps", "rfc3339")
+	u := fmt.Sprintf("%s/zones/%s/logs/received?%s", t.endpoint, url.PathEscape(t.config.ZoneID), q.Encode())
+
+	req, err := http.NewRequestWithContext(t.ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+string(t.config.APIToken))
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %s pulling logs: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	br := bufio.NewReader(resp.Body)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			if err := t.handle(line); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// handle sends the log line, timestamped with the time of the request.
+func (t *CloudflareTarget) handle(line []byte) error {
+	cloudflareEntries.WithLabelValues(t.config.ZoneID).Inc()
+	if t.labels == nil {
+		return nil
+	}
+
+	ts := time.Now()
+	var fields map[string]interface{}
+	if err := json.Unmarshal(line, &fields); err == nil {
+		if s, ok := fields[timestampField].(string); ok {
+			if parsed, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				ts = parsed
+			}
+		}
+	}
+	return t.handler.Handle(t.labels.Clone(), ts, strings.TrimRight(string(line), "\r\n"))
+}
+
+// Type returns CloudflareTargetType.
+func (t *CloudflareTarget) Type() target.TargetType {
+	return target.CloudflareTargetType
+}
+
+// Ready indicates whether or not the last pull succeeded.
+func (t *CloudflareTarget) Ready() bool {
+	return t.ready.Load()
+}
+
+// DiscoveredLabels returns the set of labels discovered by the CloudflareTarget, which
+// is always nil. Implements Target.
+func (t *CloudflareTarget) DiscoveredLabels() model.LabelSet {
+	return nil
+}
+
+// Labels returns the set of labels that statically apply to all log entries
+// produced by the CloudflareTarget.
+func (t *CloudflareTarget) Labels() model.LabelSet {
+	return t.config.Labels
+}
+
+// Details returns target-specific details, the end of the last range pulled and the last error.
+func (t *CloudflareTarget) Details() interface{} {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	details := map[string]string{
+		"zone_id":  t.config.ZoneID,
+		"position": t.positions.GetString(t.positionKey()),
+		"error":    "",
+	}
+	if t.err != nil {
+		details["error"] = t.err.Error()
+	}
+	return details
+}
+
+// Stop stops pulling, the end of the last range pulled being kept.
+func (t *CloudflareTarget) Stop() error {
+	t.cancel()
+	t.wg.Wait()
+	return nil
+}
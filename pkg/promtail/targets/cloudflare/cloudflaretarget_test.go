@@ -0,0 +1,147 @@
+package cloudflare
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/positions"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+)
+
+// fakeLogpull serves a log line per request pulled, recording the time ranges requested.
+type fakeLogpull struct {
+	mtx    sync.Mutex
+	ranges [][2]int64
+	fails  bool
+}
+
+func (f *fakeLogpull) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if r.URL.Path != "/zones/zone-1/logs/received" || r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if f.fails {
+		http.Error(w, `{"success":false}`, http.StatusBadRequest)
+		return
+	}
+	start, _ := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+	end, _ := strconv.ParseInt(r.URL.Query().Get("end"), 10, 64)
+	f.ranges = append(f.ranges, [2]int64{start, end})
+	_, _ = fmt.Fprintf(w, `{"RayID":"%d","EdgeStartTimestamp":"2020-10-15T12:00:00Z","fields":%q}`+"\n", len(f.ranges), r.URL.Query().Get("fields"))
+}
+
+func (f *fakeLogpull) requested() [][2]int64 {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return append([][2]int64(nil), f.ranges...)
+}
+
+func newPositions(t *testing.T) (positions.Positions, func()) {
+	dir, err := ioutil.TempDir("", "cloudflare")
+	require.NoError(t, err)
+	ps, err := positions.New(util.Logger, positions.Config{
+		SyncPeriod:    10 * time.Second,
+		PositionsFile: filepath.Join(dir, "positions.yml"),
+	})
+	require.NoError(t, err)
+	return ps, func() {
+		ps.Stop()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestCloudflareTarget_Pull(t *testing.T) {
+	fake := &fakeLogpull{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	ps, cleanup := newPositions(t)
+	defer cleanup()
+
+	var mtx sync.Mutex
+	var lines []string
+	handler := api.EntryHandlerFunc(func(labels model.LabelSet, ts time.Time, line string) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+		require.Equal(t, model.LabelSet{"job": "cloudflare", "zone": "zone-1"}, labels)
+		require.Equal(t, time.Date(2020, 10, 15, 12, 0, 0, 0, time.UTC), ts)
+		lines = append(lines, line)
+		return nil
+	})
+	config := &scrapeconfig.CloudflareConfig{
+		APIToken:  "token",
+		ZoneID:    "zone-1",
+		PullRange: model.Duration(30 * time.Minute),
+		Fields:    []string{"RayID", "ClientIP"},
+		Labels:    model.LabelSet{"job": "cloudflare"},
+	}
+	tgt, err := newCloudflareTarget(util.Logger, handler, ps, "cloudflare", []*relabel.Config{
+		{
+			SourceLabels: model.LabelNames{"__cloudflare_zone_id"},
+			Regex:        relabel.MustNewRegexp("(.*)"),
+			Replacement:  "$1",
+			TargetLabel:  "zone",
+			Action:       relabel.Replace,
+		},
+	}, config, server.URL)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, tgt.Stop()) }()
+	require.Eventually(t, func() bool { return len(fake.requested()) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// the first range pulled is the last pull range available
+	requested := fake.requested()
+	require.Equal(t, int64(30*time.Minute), requested[0][1]-requested[0][0])
+	require.InDelta(t, time.Now().Add(-delay).UnixNano(), requested[0][1], float64(5*time.Second))
+	require.Eventually(t, func() bool {
+		return ps.GetString("cloudflare-zone-1") == strconv.FormatInt(requested[0][1], 10)
+	}, 5*time.Second, 10*time.Millisecond)
+	mtx.Lock()
+	require.Equal(t, `{"RayID":"1","EdgeStartTimestamp":"2020-10-15T12:00:00Z","fields":"RayID,ClientIP,EdgeStartTimestamp"}`, lines[0])
+	mtx.Unlock()
+
+	// the ranges are pulled from the position saved, the next pull being in 30m, by ranges of at most an hour until caught up
+	now := time.Now()
+	ps.PutString("cloudflare-zone-1", strconv.FormatInt(now.Add(-150*time.Minute).UnixNano(), 10))
+	pulled, err := tgt.pull(now)
+	require.NoError(t, err)
+	require.Equal(t, maxRange, pulled)
+	pulled, err = tgt.pull(now)
+	require.NoError(t, err)
+	require.Equal(t, maxRange, pulled)
+	pulled, err = tgt.pull(now)
+	require.NoError(t, err)
+	require.Equal(t, 29*time.Minute, pulled)
+	require.Equal(t, strconv.FormatInt(now.Add(-delay).UnixNano(), 10), ps.GetString("cloudflare-zone-1"))
+	pulled, err = tgt.pull(now)
+	require.NoError(t, err)
+	require.Equal(t, time.Duration(0), pulled)
+
+	// the position is kept when the pull fails
+	fake.mtx.Lock()
+	fake.fails = true
+	fake.mtx.Unlock()
+	_, err = tgt.pull(now.Add(time.Minute))
+	require.Error(t, err)
+	require.Equal(t, strconv.FormatInt(now.Add(-delay).UnixNano(), 10), ps.GetString("cloudflare-zone-1"))
+}
+
+func TestNewCloudflareTarget_InvalidConfig(t *testing.T) {
+	_, err := NewCloudflareTarget(util.Logger, nil, nil, "cloudflare", nil, &scrapeconfig.CloudflareConfig{ZoneID: "zone-1"})
+	require.Error(t, err)
+}
@@ -0,0 +1,87 @@
+package cloudflare
+
+import (
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/famarks/loki/pkg/logentry/stages"
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/positions"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+	"github.com/famarks/loki/pkg/promtail/targets/target"
+)
+
+// CloudflareTargetManager manages a series of CloudflareTargets.
+// nolint:golint
+type CloudflareTargetManager struct {
+	logger  log.Logger
+	targets map[string]*CloudflareTarget
+}
+
+// NewCloudflareTargetManager creates a new CloudflareTargetManager.
+func NewCloudflareTargetManager(
+	logger log.Logger,
+	positions positions.Positions,
+	client api.EntryHandler,
+	scrapeConfigs []scrapeconfig.Config,
+) (*CloudflareTargetManager, error) {
+
+	tm := &CloudflareTargetManager{
+		logger:  logger,
+		targets: make(map[string]*CloudflareTarget),
+	}
+
+	for _, cfg := range scrapeConfigs {
+		registerer := prometheus.DefaultRegisterer
+		pipeline, err := stages.NewPipeline(log.With(logger, "component", "cloudflare_pipeline"), cfg.PipelineStages, &cfg.JobName, registerer)
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := NewCloudflareTarget(logger, pipeline.Wrap(client), positions, cfg.JobName, cfg.RelabelConfigs, cfg.CloudflareConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		tm.targets[cfg.JobName] = t
+	}
+
+	return tm, nil
+}
+
+// Ready returns true if at least one CloudflareTarget is also ready.
+func (tm *CloudflareTargetManager) Ready() bool {
+	for _, t := range tm.targets {
+		if t.Ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// Stop stops the CloudflareTargetManager and all of its CloudflareTargets.
+func (tm *CloudflareTargetManager) Stop() {
+	for _, t := range tm.targets {
+		if err := t.Stop(); err != nil {
+			level.Error(t.logger).Log("msg", "error stopping CloudflareTarget", "err", err.Error())
+		}
+	}
+}
+
+// ActiveTargets returns the list of CloudflareTargets where log entries
+// are being pulled. ActiveTargets is an alias to AllTargets as
+// CloudflareTargets cannot be deactivated, only stopped.
+func (tm *CloudflareTargetManager) ActiveTargets() map[string][]target.Target {
+	return tm.AllTargets()
+}
+
+// AllTargets returns the list of all targets where log entries
+// are currently being pulled.
+func (tm *CloudflareTargetManager) AllTargets() map[string][]target.Target {
+	result := make(map[string][]target.Target, len(tm.targets))
+	for k, v := range tm.targets {
+		result[k] = []target.Target{v}
+	}
+	return result
+}
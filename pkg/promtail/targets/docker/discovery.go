@@ -0,0 +1,140 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/go-kit/kit/log"
+	promconfig "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/refresh"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/util/strutil"
+
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+)
+
+const (
+	dockerLabel                 = model.MetaLabelPrefix + "docker_"
+	dockerLabelContainerPrefix  = dockerLabel + "container_"
+	dockerLabelContainerID      = dockerLabelContainerPrefix + "id"
+	dockerLabelContainerName    = dockerLabelContainerPrefix + "name"
+	dockerLabelContainerImage   = dockerLabelContainerPrefix + "image"
+	dockerLabelNetworkMode      = dockerLabelContainerPrefix + "network_mode"
+	dockerLabelContainerLabel   = dockerLabelContainerPrefix + "label_"
+	dockerLabelLogStream        = dockerLabelContainerPrefix + "log_stream"
+	defaultHost                 = "unix:///var/run/docker.sock"
+	defaultRefreshInterval      = 5 * time.Second
+	discoveryMechanismName      = "docker"
+	discoveryHTTPClientUserName = "docker_sd"
+)
+
+// sdConfig implements discovery.Config for the discovery of the containers of a Docker daemon, sharing the client of
+// the daemon with the targets reading the logs of the containers.
+type sdConfig struct {
+	config *scrapeconfig.DockerSDConfig
+	client client.APIClient
+}
+
+// Name returns the name of the Config.
+func (c *sdConfig) Name() string { return discoveryMechanismName }
+
+// NewDiscoverer returns a Discoverer for the Config.
+func (c *sdConfig) NewDiscoverer(opts discovery.DiscovererOptions) (discovery.Discoverer, error) {
+	return newDiscovery(c.config, c.client, opts.Logger), nil
+}
+
+func host(config *scrapeconfig.DockerSDConfig) string {
+	if config.Host == "" {
+		return defaultHost
+	}
+	return config.Host
+}
+
+func refreshInterval(config *scrapeconfig.DockerSDConfig) time.Duration {
+	if config.RefreshInterval <= 0 {
+		return defaultRefreshInterval
+	}
+	return time.Duration(config.RefreshInterval)
+}
+
+// newClient returns a client of the Docker daemon of the config.
+func newClient(config *scrapeconfig.DockerSDConfig) (*client.Client, error) {
+	hostURL, err := url.Parse(host(config))
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []client.Opt{
+		client.WithHost(host(config)),
+		client.WithAPIVersionNegotiation(),
+	}
+
+	// There are other protocols than HTTP supported by the Docker daemon, like
+	// unix, which are not supported by the HTTP client. Passing HTTP client
+	// options to the Docker client makes those non-HTTP requests fail.
+	if hostURL.Scheme == "http" || hostURL.Scheme == "https" {
+		rt, err := promconfig.NewRoundTripperFromConfig(config.HTTPClientConfig, discoveryHTTPClientUserName, false, false)
+		if err != nil {
+			return nil, err
+		}
+		// no timeout as the logs of the containers are followed, the requests are bounded by their contexts
+		opts = append(opts,
+			client.WithHTTPClient(&http.Client{Transport: rt}),
+			client.WithScheme(hostURL.Scheme),
+		)
+	}
+	return client.NewClientWithOpts(opts...)
+}
+
+// newDiscovery returns a Discoverer listing the running containers every refresh interval.
+func newDiscovery(config *scrapeconfig.DockerSDConfig, c client.APIClient, logger log.Logger) discovery.Discoverer {
+	args := filters.NewArgs()
+	for _, f := range config.Filters {
+		for _, v := range f.Values {
+			args.Add(f.Name, v)
+		}
+	}
+	source := host(config)
+	interval := refreshInterval(config)
+
+	return refresh.NewDiscovery(logger, discoveryMechanismName, interval, func(ctx context.Context) ([]*targetgroup.Group, error) {
+		ctx, cancel := context.WithTimeout(ctx, interval)
+		defer cancel()
+		containers, err := c.ContainerList(ctx, types.ContainerListOptions{Filters: args})
+		if err != nil {
+			return nil, fmt.Errorf("error listing containers: %w", err)
+		}
+		return []*targetgroup.Group{containersGroup(source, containers)}, nil
+	})
+}
+
+// containersGroup returns the group of the containers listed, labeled for the relabeling.
+func containersGroup(source string, containers []types.Container) *targetgroup.Group {
+	tg := &targetgroup.Group{Source: source}
+	for _, c := range containers {
+		ls := model.LabelSet{
+			dockerLabelContainerID:    model.LabelValue(c.ID),
+			dockerLabelContainerImage: model.LabelValue(c.Image),
+		}
+		if len(c.Names) > 0 {
+			ls[dockerLabelContainerName] = model.LabelValue(strings.TrimPrefix(c.Names[0], "/"))
+		}
+		if c.HostConfig.NetworkMode != "" {
+			ls[dockerLabelNetworkMode] = model.LabelValue(c.HostConfig.NetworkMode)
+		}
+		for k, v := range c.Labels {
+			ls[model.LabelName(dockerLabelContainerLabel+strutil.SanitizeLabelName(k))] = model.LabelValue(v)
+		}
+		tg.Targets = append(tg.Targets, ls)
+	}
+	return tg
+}
@@ -0,0 +1,312 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"go.uber.org/atomic"
+
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/positions"
+	"github.com/famarks/loki/pkg/promtail/targets/target"
+)
+
+var (
+	dockerEntries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "docker_target_entries_total",
+		Help:      "Total number of lines read from the logs of Docker containers.",
+	}, []string{"stream"})
+	dockerParsingErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "docker_target_parsing_errors_total",
+		Help:      "Total number of lines of the logs of Docker containers without a timestamp, sent with the current time.",
+	}, []string{"stream"})
+)
+
+const (
+	stdoutStream = "stdout"
+	stderrStream = "stderr"
+
+	// retryBackoff is the time to wait before following the logs again once they end, like when the container stops.
+	retryBackoff = 5 * time.Second
+)
+
+// positionKey returns the key of the position of the container, the timestamp of the last line read.
+func positionKey(containerID string) string {
+	return "docker-" + containerID
+}
+
+// formatPosition formats the timestamp as expected by the since parameter of the logs of the Docker API.
+func formatPosition(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+func parsePosition(s string) (time.Time, error) {
+	parts := strings.SplitN(s, ".", 2)
+	sec, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var nsec int64
+	if len(parts) == 2 {
+		if nsec, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.Unix(sec, nsec), nil
+}
+
+// DockerTarget follows the logs of a Docker container, sending the lines of its stdout and stderr with the labels of
+// their stream.
+// nolint:golint
+type DockerTarget struct {
+	logger           log.Logger
+	handler          api.EntryHandler
+	positions        positions.Positions
+	client           client.APIClient
+	containerID      string
+	discoveredLabels model.LabelSet
+	// streams are the labels of the entries of each stream read, once relabeled.
+	streams map[string]model.LabelSet
+
+	running atomic.Bool
+	mtx     sync.Mutex
+	err     error
+	last    time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDockerTarget starts following the logs of the container from the position saved, the streams without labels not
+// being read.
+func NewDockerTarget(
+	logger log.Logger,
+	handler api.EntryHandler,
+	positions positions.Positions,
+	client client.APIClient,
+	containerID string,
+	discoveredLabels model.LabelSet,
+	streams map[string]model.LabelSet,
+) (*DockerTarget, error) {
+	var last time.Time
+	if pos := positions.GetString(positionKey(containerID)); pos != "" {
+		var err error
+		if last, err = parsePosition(pos); err != nil {
+			return nil, fmt.Errorf("invalid position %q of container %s: %w", pos, containerID, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &DockerTarget{
+		logger:           log.With(logger, "container", containerID),
+		handler:          handler,
+		positions:        positions,
+		client:           client,
+		containerID:      containerID,
+		discoveredLabels: discoveredLabels,
+		streams:          streams,
+		last:             last,
+		ctx:              ctx,
+		cancel:           cancel,
+	}
+	t.wg.Add(1)
+	go t.run()
+	return t, nil
+}
+
+func (t *DockerTarget) run() {
+	defer t.wg.Done()
+
+	for {
+		err := t.follow()
+		if t.ctx.Err() != nil {
+			return
+		}
+		t.running.Store(false)
+		t.mtx.Lock()
+		t.err = err
+		t.mtx.Unlock()
+		if err != nil {
+			level.Error(t.logger).Log("msg", "error following the logs of the container", "err", err)
+		}
+		select {
+		case <-time.After(retryBackoff):
+		case <-t.ctx.Done():
+			return
+		}
+	}
+}
+
+// follow reads the logs of the container until they end, skipping the lines up to the position saved.
+func (t *DockerTarget) follow() error {
+	info, err := t.client.ContainerInspect(t.ctx, t.containerID)
+	if err != nil {
+		return err
+	}
+
+	t.mtx.Lock()
+	since := t.last
+	t.mtx.Unlock()
+	opts := types.ContainerLogsOptions{
+		ShowStdout: t.streams[stdoutStream] != nil,
+		ShowStderr: t.streams[stderrStream] != nil,
+		Follow:     true,
+		Timestamps: true,
+	}
+	if !since.IsZero() {
+		opts.Since = formatPosition(since)
+	}
+	logs, err := t.client.ContainerLogs(t.ctx, t.containerID, opts)
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+	t.running.Store(true)
+	t.mtx.Lock()
+	t.err = nil
+	t.mtx.Unlock()
+
+	// the logs of a container with a TTY are its raw output, the others multiplexing stdout and stderr in frames
+	if info.Config != nil && info.Config.Tty {
+		return t.read(logs, stdoutStream, since)
+	}
+
+	stdout, stdoutWriter := io.Pipe()
+	stderr, stderrWriter := io.Pipe()
+	go func() {
+		_, err := stdcopy.StdCopy(stdoutWriter, stderrWriter, logs)
+		stdoutWriter.CloseWithError(err)
+		stderrWriter.CloseWithError(err)
+	}()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		errs[0] = t.read(stdout, stdoutStream, since)
+	}()
+	go func() {
+		defer wg.Done()
+		errs[1] = t.read(stderr, stderrStream, since)
+	}()
+	wg.Wait()
+	if errs[0] != nil {
+		return errs[0]
+	}
+	return errs[1]
+}
+
+// read sends the lines of the stream read after since, each line being prefixed with its timestamp.
+func (t *DockerTarget) read(r io.Reader, stream string, since time.Time) error {
+	ls := t.streams[stream]
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" && ls != nil {
+			t.handleLine(ls, stream, line, since)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (t *DockerTarget) handleLine(ls model.LabelSet, stream, line string, since time.Time) {
+	line = strings.TrimRight(line, "\r\n")
+	ts := time.Now()
+	if i := strings.IndexByte(line, ' '); i > 0 {
+		if parsed, err := time.Parse(time.RFC3339Nano, line[:i]); err == nil {
+			ts, line = parsed, line[i+1:]
+		} else {
+			dockerParsingErrors.WithLabelValues(stream).Inc()
+		}
+	} else {
+		dockerParsingErrors.WithLabelValues(stream).Inc()
+	}
+	// the since parameter of the logs only has the precision of the second for some log drivers
+	if !since.IsZero() && !ts.After(since) {
+		return
+	}
+
+	dockerEntries.WithLabelValues(stream).Inc()
+	if err := t.handler.Handle(ls.Clone(), ts, line); err != nil {
+		level.Error(t.logger).Log("msg", "error handling line", "stream", stream, "err", err)
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if ts.After(t.last) {
+		t.last = ts
+		t.positions.PutString(positionKey(t.containerID), formatPosition(ts))
+	}
+}
+
+// Type returns DockerTargetType.
+func (t *DockerTarget) Type() target.TargetType {
+	return target.DockerTargetType
+}
+
+// Ready indicates whether or not the logs of the container are being followed.
+func (t *DockerTarget) Ready() bool {
+	return t.running.Load()
+}
+
+// DiscoveredLabels returns the labels of the container discovered, before the relabeling.
+func (t *DockerTarget) DiscoveredLabels() model.LabelSet {
+	return t.discoveredLabels
+}
+
+// Labels returns the labels of the entries of the container, the ones of stdout when it is read.
+func (t *DockerTarget) Labels() model.LabelSet {
+	if ls := t.streams[stdoutStream]; ls != nil {
+		return ls
+	}
+	return t.streams[stderrStream]
+}
+
+// Details returns target-specific details, the timestamp of the last line read and the last error.
+func (t *DockerTarget) Details() interface{} {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	details := map[string]string{
+		"id":       t.containerID,
+		"position": "",
+		"running":  strconv.FormatBool(t.running.Load()),
+		"error":    "",
+	}
+	if !t.last.IsZero() {
+		details["position"] = t.last.UTC().Format(time.RFC3339Nano)
+	}
+	if t.err != nil {
+		details["error"] = t.err.Error()
+	}
+	return details
+}
+
+// Stop stops following the logs of the container, its position being kept.
+func (t *DockerTarget) Stop() {
+	t.cancel()
+	t.wg.Wait()
+}
@@ -0,0 +1,245 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/positions"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+)
+
+// fakeDaemon serves the containers and the logs of the Docker API, recording the since parameter of the logs
+// requested.
+type fakeDaemon struct {
+	mtx        sync.Mutex
+	containers []types.Container
+	stdout     []string
+	stderr     []string
+	sinces     []string
+}
+
+func (d *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	w.Header().Set("API-Version", "1.40")
+	switch {
+	case r.URL.Path == "/_ping":
+		_, _ = w.Write([]byte("OK"))
+	case strings.HasSuffix(r.URL.Path, "/containers/json"):
+		_ = json.NewEncoder(w).Encode(d.containers)
+	case strings.HasSuffix(r.URL.Path, "/logs"):
+		d.sinces = append(d.sinces, r.URL.Query().Get("since"))
+		if r.URL.Query().Get("stdout") == "1" {
+			_, _ = fmt.Fprint(stdcopy.NewStdWriter(w, stdcopy.Stdout), strings.Join(d.stdout, ""))
+		}
+		if r.URL.Query().Get("stderr") == "1" {
+			_, _ = fmt.Fprint(stdcopy.NewStdWriter(w, stdcopy.Stderr), strings.Join(d.stderr, ""))
+		}
+	case strings.HasSuffix(r.URL.Path, "/json"):
+		id := strings.TrimSuffix(r.URL.Path[strings.Index(r.URL.Path, "/containers/")+len("/containers/"):], "/json")
+		for _, c := range d.containers {
+			if c.ID == id {
+				_ = json.NewEncoder(w).Encode(types.ContainerJSON{
+					ContainerJSONBase: &types.ContainerJSONBase{ID: id},
+					Config:            &container.Config{},
+				})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"message": "No such container"}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (d *fakeDaemon) logsSinces() []string {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return append([]string(nil), d.sinces...)
+}
+
+type handledEntry struct {
+	labels model.LabelSet
+	time   time.Time
+	line   string
+}
+
+type entries struct {
+	mtx     sync.Mutex
+	entries []handledEntry
+}
+
+func (e *entries) Handle(labels model.LabelSet, time time.Time, line string) error {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	e.entries = append(e.entries, handledEntry{labels, time, line})
+	return nil
+}
+
+func (e *entries) get() []handledEntry {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	return append([]handledEntry(nil), e.entries...)
+}
+
+func newPositions(t *testing.T) (positions.Positions, func()) {
+	dir, err := ioutil.TempDir("", "docker")
+	require.NoError(t, err)
+	ps, err := positions.New(util.Logger, positions.Config{
+		SyncPeriod:    10 * time.Second,
+		PositionsFile: filepath.Join(dir, "positions.yml"),
+	})
+	require.NoError(t, err)
+	return ps, func() {
+		ps.Stop()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestDockerTarget(t *testing.T) {
+	daemon := &fakeDaemon{
+		containers: []types.Container{{ID: "abc"}},
+		stdout: []string{
+			"2020-11-05T10:15:30.000000001Z line 1\n",
+			"2020-11-05T10:15:32.000000001Z line 3\n",
+		},
+		stderr: []string{"2020-11-05T10:15:31.000000001Z line 2\n"},
+	}
+	server := httptest.NewServer(daemon)
+	defer server.Close()
+	c, err := newClient(&scrapeconfig.DockerSDConfig{Host: server.URL})
+	require.NoError(t, err)
+	ps, cleanup := newPositions(t)
+	defer cleanup()
+
+	handler := &entries{}
+	streams := map[string]model.LabelSet{
+		stdoutStream: {"job": "docker", "stream": "stdout"},
+		stderrStream: {"job": "docker", "stream": "stderr"},
+	}
+	tgt, err := NewDockerTarget(util.Logger, handler, ps, c, "abc", nil, streams)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(handler.get()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	tgt.Stop()
+
+	got := handler.get()
+	require.ElementsMatch(t, []handledEntry{
+		{streams[stdoutStream], time.Date(2020, 11, 5, 10, 15, 30, 1, time.UTC), "line 1"},
+		{streams[stderrStream], time.Date(2020, 11, 5, 10, 15, 31, 1, time.UTC), "line 2"},
+		{streams[stdoutStream], time.Date(2020, 11, 5, 10, 15, 32, 1, time.UTC), "line 3"},
+	}, got)
+	require.Equal(t, "1604571332.000000001", ps.GetString(positionKey("abc")))
+	require.Equal(t, []string{""}, daemon.logsSinces())
+
+	// the logs are read again from the position, the lines at the position being skipped
+	daemon.stdout = append(daemon.stdout, "2020-11-05T10:15:33.000000001Z line 4\n")
+	handler = &entries{}
+	tgt, err = NewDockerTarget(util.Logger, handler, ps, c, "abc", nil, map[string]model.LabelSet{
+		stdoutStream: streams[stdoutStream],
+	})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(handler.get()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, "line 4", handler.get()[0].line)
+	require.Equal(t, "1604571333.000000001", ps.GetString(positionKey("abc")))
+	require.Equal(t, "2020-11-05T10:15:33.000000001Z", tgt.Details().(map[string]string)["position"])
+	tgt.Stop()
+	require.Equal(t, "1604571332.000000001", daemon.logsSinces()[1])
+}
+
+func TestTargetSyncer(t *testing.T) {
+	daemon := &fakeDaemon{containers: []types.Container{{ID: "abc"}}}
+	server := httptest.NewServer(daemon)
+	defer server.Close()
+	c, err := newClient(&scrapeconfig.DockerSDConfig{Host: server.URL})
+	require.NoError(t, err)
+	ps, cleanup := newPositions(t)
+	defer cleanup()
+
+	s := &targetSyncer{
+		logger:       util.Logger,
+		positions:    ps,
+		entryHandler: api.EntryHandlerFunc(func(model.LabelSet, time.Time, string) error { return nil }),
+		relabelConfig: []*relabel.Config{
+			{
+				SourceLabels: model.LabelNames{dockerLabelContainerName},
+				Regex:        relabel.MustNewRegexp("(.*)"),
+				Replacement:  "$1",
+				TargetLabel:  "container",
+				Action:       relabel.Replace,
+			},
+			{
+				SourceLabels: model.LabelNames{dockerLabelContainerName, dockerLabelLogStream},
+				Separator:    ";",
+				Regex:        relabel.MustNewRegexp("(skipped;.*|.*;stderr)"),
+				Action:       relabel.Drop,
+			},
+		},
+		clients: map[string]client.APIClient{server.URL: c},
+		targets: map[string]*DockerTarget{},
+	}
+	defer s.stop()
+
+	s.sync([]*targetgroup.Group{containersGroup(server.URL, []types.Container{
+		{ID: "abc", Names: []string{"/web"}},
+		{ID: "def", Names: []string{"/skipped"}},
+	})})
+	require.Len(t, s.activeTargets(), 1)
+	require.Equal(t, model.LabelSet{"container": "web"}, s.activeTargets()[0].Labels())
+	require.Equal(t, map[string]model.LabelSet{stdoutStream: {"container": "web"}, stderrStream: nil}, s.targets["abc"].streams)
+	require.Len(t, s.droppedTargets(), 1)
+
+	// the position of a container removed is forgotten
+	ps.PutString(positionKey("abc"), "1604571332.000000001")
+	daemon.mtx.Lock()
+	daemon.containers = nil
+	daemon.mtx.Unlock()
+	s.sync([]*targetgroup.Group{containersGroup(server.URL, nil)})
+	require.Len(t, s.activeTargets(), 0)
+	require.Equal(t, "", ps.GetString(positionKey("abc")))
+}
+
+func TestContainersGroup(t *testing.T) {
+	tg := containersGroup("unix:///var/run/docker.sock", []types.Container{
+		{
+			ID:     "abc",
+			Names:  []string{"/web"},
+			Image:  "nginx:latest",
+			Labels: map[string]string{"com.docker.compose.service": "web"},
+			HostConfig: struct {
+				NetworkMode string `json:",omitempty"`
+			}{NetworkMode: "bridge"},
+		},
+	})
+	require.Equal(t, &targetgroup.Group{
+		Source: "unix:///var/run/docker.sock",
+		Targets: []model.LabelSet{{
+			"__meta_docker_container_id":                               "abc",
+			"__meta_docker_container_name":                             "web",
+			"__meta_docker_container_image":                            "nginx:latest",
+			"__meta_docker_container_network_mode":                     "bridge",
+			"__meta_docker_container_label_com_docker_compose_service": "web",
+		}},
+	}, tg)
+}
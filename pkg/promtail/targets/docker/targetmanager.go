@@ -0,0 +1,268 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/client"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+
+	"github.com/famarks/loki/pkg/helpers"
+	"github.com/famarks/loki/pkg/logentry/stages"
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/positions"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+	"github.com/famarks/loki/pkg/promtail/targets/target"
+)
+
+// DockerTargetManager manages a set of DockerTargets, one per container discovered.
+// nolint:golint
+type DockerTargetManager struct {
+	logger  log.Logger
+	quit    context.CancelFunc
+	syncers map[string]*targetSyncer
+	manager *discovery.Manager
+}
+
+// NewDockerTargetManager creates a new DockerTargetManager discovering the containers of the docker_sd_configs.
+func NewDockerTargetManager(
+	logger log.Logger,
+	positions positions.Positions,
+	handler api.EntryHandler,
+	scrapeConfigs []scrapeconfig.Config,
+) (*DockerTargetManager, error) {
+	ctx, quit := context.WithCancel(context.Background())
+	tm := &DockerTargetManager{
+		logger:  logger,
+		quit:    quit,
+		syncers: map[string]*targetSyncer{},
+		manager: discovery.NewManager(ctx, log.With(logger, "component", "docker_discovery")),
+	}
+
+	configs := map[string]discovery.Configs{}
+	for _, cfg := range scrapeConfigs {
+		if len(cfg.DockerSDConfigs) == 0 {
+			continue
+		}
+
+		registerer := prometheus.DefaultRegisterer
+		pipeline, err := stages.NewPipeline(log.With(logger, "component", "docker_pipeline"), cfg.PipelineStages, &cfg.JobName, registerer)
+		if err != nil {
+			quit()
+			return nil, err
+		}
+
+		s := &targetSyncer{
+			logger:        log.With(logger, "job", cfg.JobName),
+			positions:     positions,
+			entryHandler:  pipeline.Wrap(handler),
+			relabelConfig: cfg.RelabelConfigs,
+			clients:       map[string]client.APIClient{},
+			targets:       map[string]*DockerTarget{},
+		}
+		for _, sd := range cfg.DockerSDConfigs {
+			c, err := newClient(sd)
+			if err != nil {
+				quit()
+				return nil, fmt.Errorf("invalid docker_sd_config of job %s: %w", cfg.JobName, err)
+			}
+			s.clients[host(sd)] = c
+			configs[cfg.JobName] = append(configs[cfg.JobName], &sdConfig{config: sd, client: c})
+		}
+		tm.syncers[cfg.JobName] = s
+	}
+
+	go tm.run()
+	go helpers.LogError("running docker target manager", tm.manager.Run)
+
+	return tm, tm.manager.ApplyConfig(configs)
+}
+
+func (tm *DockerTargetManager) run() {
+	for targetGroups := range tm.manager.SyncCh() {
+		for jobName, groups := range targetGroups {
+			tm.syncers[jobName].sync(groups)
+		}
+	}
+}
+
+// Ready returns true if at least one DockerTarget is following the logs of its container.
+func (tm *DockerTargetManager) Ready() bool {
+	for _, s := range tm.syncers {
+		if s.ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// Stop stops the DockerTargetManager and all of its DockerTargets.
+func (tm *DockerTargetManager) Stop() {
+	tm.quit()
+	for _, s := range tm.syncers {
+		s.stop()
+	}
+}
+
+// ActiveTargets returns the list of DockerTargets where the logs are being read from.
+func (tm *DockerTargetManager) ActiveTargets() map[string][]target.Target {
+	result := map[string][]target.Target{}
+	for jobName, s := range tm.syncers {
+		result[jobName] = append(result[jobName], s.activeTargets()...)
+	}
+	return result
+}
+
+// AllTargets returns all DockerTargets, active and dropped.
+func (tm *DockerTargetManager) AllTargets() map[string][]target.Target {
+	result := map[string][]target.Target{}
+	for jobName, s := range tm.syncers {
+		result[jobName] = append(result[jobName], s.activeTargets()...)
+		result[jobName] = append(result[jobName], s.droppedTargets()...)
+	}
+	return result
+}
+
+// targetSyncer syncs the DockerTargets of a job with the containers discovered.
+type targetSyncer struct {
+	logger        log.Logger
+	positions     positions.Positions
+	entryHandler  api.EntryHandler
+	relabelConfig []*relabel.Config
+	// clients are the clients of the Docker daemons by host, the source of their target groups.
+	clients map[string]client.APIClient
+
+	mtx     sync.Mutex
+	targets map[string]*DockerTarget
+	dropped []target.Target
+}
+
+// sync starts a DockerTarget for each container discovered and stops the ones of the containers not running anymore.
+func (s *targetSyncer) sync(groups []*targetgroup.Group) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	containers := map[string]struct{}{}
+	dropped := []target.Target{}
+
+	for _, group := range groups {
+		c, ok := s.clients[group.Source]
+		if !ok {
+			continue
+		}
+		for _, t := range group.Targets {
+			discoveredLabels := group.Labels.Merge(t)
+			id := string(discoveredLabels[dockerLabelContainerID])
+			if id == "" {
+				continue
+			}
+
+			streams := map[string]model.LabelSet{
+				stdoutStream: s.streamLabels(discoveredLabels, stdoutStream),
+				stderrStream: s.streamLabels(discoveredLabels, stderrStream),
+			}
+			if streams[stdoutStream] == nil && streams[stderrStream] == nil {
+				dropped = append(dropped, target.NewDroppedTarget("dropping target, no labels", discoveredLabels))
+				level.Debug(s.logger).Log("msg", "dropping target, no labels", "container", id)
+				continue
+			}
+
+			containers[id] = struct{}{}
+			if _, ok := s.targets[id]; ok {
+				continue
+			}
+
+			level.Info(s.logger).Log("msg", "Adding target", "container", id)
+			t, err := NewDockerTarget(s.logger, s.entryHandler, s.positions, c, id, discoveredLabels, streams)
+			if err != nil {
+				dropped = append(dropped, target.NewDroppedTarget(fmt.Sprintf("Failed to create target: %s", err.Error()), discoveredLabels))
+				level.Error(s.logger).Log("msg", "Failed to create target", "container", id, "error", err)
+				continue
+			}
+			s.targets[id] = t
+		}
+	}
+
+	for id, t := range s.targets {
+		if _, ok := containers[id]; ok {
+			continue
+		}
+		level.Info(s.logger).Log("msg", "Removing target", "container", id)
+		t.Stop()
+		delete(s.targets, id)
+		// the position is kept while the container exists, for its logs to be read from it when it is restarted
+		if _, err := t.client.ContainerInspect(context.Background(), id); client.IsErrNotFound(err) {
+			s.positions.Remove(positionKey(id))
+		}
+	}
+	s.dropped = dropped
+}
+
+// streamLabels returns the labels of the entries of the stream of the container once relabeled, without the internal
+// ones. The labels are nil when the relabeling drops the stream.
+func (s *targetSyncer) streamLabels(discoveredLabels model.LabelSet, stream string) model.LabelSet {
+	lb := labels.NewBuilder(nil)
+	for k, v := range discoveredLabels {
+		lb.Set(string(k), string(v))
+	}
+	lb.Set(dockerLabelLogStream, stream)
+
+	processed := relabel.Process(lb.Labels(), s.relabelConfig...)
+	if processed == nil {
+		return nil
+	}
+	ls := make(model.LabelSet, len(processed))
+	for _, l := range processed {
+		if strings.HasPrefix(l.Name, "__") {
+			continue
+		}
+		ls[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+	}
+	return ls
+}
+
+func (s *targetSyncer) activeTargets() []target.Target {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	actives := []target.Target{}
+	for _, t := range s.targets {
+		actives = append(actives, t)
+	}
+	return actives
+}
+
+func (s *targetSyncer) droppedTargets() []target.Target {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]target.Target(nil), s.dropped...)
+}
+
+func (s *targetSyncer) ready() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, t := range s.targets {
+		if t.Ready() {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *targetSyncer) stop() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for id, t := range s.targets {
+		level.Info(s.logger).Log("msg", "Removing target", "container", id)
+		t.Stop()
+		delete(s.targets, id)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"flag"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/bmatcuk/doublestar"
@@ -33,6 +35,11 @@ var (
 		Name:      "file_bytes_total",
 		Help:      "Number of bytes total.",
 	}, []string{"path"})
+	fileLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "promtail",
+		Name:      "file_lag_seconds",
+		Help:      "Time since the file was last read up to its end.",
+	}, []string{"path"})
 	readLines = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "promtail",
 		Name:      "read_lines_total",
@@ -89,7 +96,9 @@ type FileTarget struct {
 	quit    chan struct{}
 	done    chan struct{}
 
-	tails map[string]*tailer
+	// tailsMtx guards the changes of the tailers, read outside of the run goroutine.
+	tailsMtx sync.RWMutex
+	tails    map[string]*tailer
 
 	targetConfig *Config
 }
@@ -127,6 +136,8 @@ func NewFileTarget(logger log.Logger, handler api.EntryHandler, positions positi
 
 // Ready if at least one file is being tailed
 func (t *FileTarget) Ready() bool {
+	t.tailsMtx.RLock()
+	defer t.tailsMtx.RUnlock()
 	return len(t.tails) > 0
 }
 
@@ -153,6 +164,8 @@ func (t *FileTarget) Labels() model.LabelSet {
 
 // Details implements a Target
 func (t *FileTarget) Details() interface{} {
+	t.tailsMtx.RLock()
+	defer t.tailsMtx.RUnlock()
 	files := map[string]int64{}
 	for fileName := range t.tails {
 		files[fileName], _ = t.positions.Get(fileName)
@@ -160,6 +173,18 @@ func (t *FileTarget) Details() interface{} {
 	return files
 }
 
+// FileStatuses returns how far the files tailed have been read, sorted by path.
+func (t *FileTarget) FileStatuses() []FileStatus {
+	t.tailsMtx.RLock()
+	statuses := make([]FileStatus, 0, len(t.tails))
+	for _, tailer := range t.tails {
+		statuses = append(statuses, tailer.status())
+	}
+	t.tailsMtx.RUnlock()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Path < statuses[j].Path })
+	return statuses
+}
+
 func (t *FileTarget) run() {
 	defer func() {
 		helpers.LogError("closing watcher", t.watcher.Close)
@@ -305,7 +330,9 @@ func (t *FileTarget) startTailing(ps []string) {
 			level.Error(t.logger).Log("msg", "failed to start tailer", "error", err, "filename", p)
 			continue
 		}
+		t.tailsMtx.Lock()
 		t.tails[p] = tailer
+		t.tailsMtx.Unlock()
 	}
 }
 
@@ -316,7 +343,9 @@ func (t *FileTarget) stopTailingAndRemovePosition(ps []string) {
 		if tailer, ok := t.tails[p]; ok {
 			tailer.stop()
 			t.positions.Remove(tailer.path)
+			t.tailsMtx.Lock()
 			delete(t.tails, p)
+			t.tailsMtx.Unlock()
 		}
 		if h, ok := t.handler.(api.InstrumentedEntryHandler); ok {
 			h.UnregisterLatencyMetric(model.LabelSet{model.LabelName(client.LatencyLabel): model.LabelValue(p)})
@@ -333,9 +362,11 @@ func (t *FileTarget) pruneStoppedTailers() {
 			toRemove = append(toRemove, k)
 		}
 	}
+	t.tailsMtx.Lock()
 	for _, tr := range toRemove {
 		delete(t.tails, tr)
 	}
+	t.tailsMtx.Unlock()
 }
 
 func toStopTailing(nt []string, et map[string]*tailer) []string {
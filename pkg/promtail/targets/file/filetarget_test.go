@@ -635,6 +635,75 @@ func TestFileTargetSync(t *testing.T) {
 
 }
 
+func TestFileStatuses(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+
+	testutils.InitRandom()
+	dirName := "/tmp/" + testutils.RandName()
+	positionsFileName := dirName + "/positions.yml"
+	logFile := dirName + "/test.log"
+
+	err := os.MkdirAll(dirName, 0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dirName) }()
+
+	ps, err := positions.New(logger, positions.Config{
+		SyncPeriod:    10 * time.Second,
+		PositionsFile: positionsFileName,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &testutils.TestClient{
+		Log:      logger,
+		Messages: make([]*testutils.Entry, 0),
+	}
+
+	if err = ioutil.WriteFile(logFile, []byte("test\ntest\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := NewFileTarget(logger, client, ps, logFile, nil, nil, &Config{
+		SyncPeriod: 10 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	countdown := 10000
+	for len(client.Messages) != 2 && countdown > 0 {
+		time.Sleep(1 * time.Millisecond)
+		countdown--
+	}
+
+	tailer := target.tails[logFile]
+	if err = tailer.markPositionAndSize(); err != nil {
+		t.Fatal(err)
+	}
+	statuses := target.FileStatuses()
+	expected := FileStatus{Path: logFile, Position: 10, Size: 10}
+	if len(statuses) != 1 || statuses[0] != expected {
+		t.Error("Expected the file to be read up to its end, got", statuses)
+	}
+
+	// A file behind reports the bytes left to read and the time since it was last read up to its end.
+	tailer.posAndSizeMtx.Lock()
+	tailer.size = 30
+	tailer.caughtUp = time.Now().Add(-time.Minute)
+	tailer.posAndSizeMtx.Unlock()
+	statuses = target.FileStatuses()
+	if len(statuses) != 1 || statuses[0].LagBytes != 20 || statuses[0].LagSeconds < 60 {
+		t.Error("Expected the file to lag 20 bytes and at least 60 seconds, got", statuses)
+	}
+
+	target.Stop()
+	ps.Stop()
+}
+
 func TestToStopTailing(t *testing.T) {
 	nt := []string{"file1", "file2", "file3", "file4", "file5", "file6", "file7", "file11", "file12", "file15"}
 	et := make(map[string]*tailer, 15)
@@ -0,0 +1,94 @@
+package file
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"gopkg.in/yaml.v2"
+
+	"github.com/famarks/loki/pkg/promtail/positions"
+	"github.com/famarks/loki/pkg/promtail/targets/testutils"
+)
+
+var swarmRelabelConfigs = `
+- source_labels: [__meta_dockerswarm_node_hostname]
+  target_label: __host__
+- source_labels: [__meta_dockerswarm_task_container_id]
+  target_label: __path__
+  replacement: /var/lib/docker/containers/$1/$1-json.log
+- source_labels: [__meta_dockerswarm_service_name]
+  target_label: service
+`
+
+func TestTargetSyncer_ServiceDiscoveryLabels(t *testing.T) {
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	testutils.InitRandom()
+	dirName := "/tmp/" + testutils.RandName()
+	if err := os.MkdirAll(dirName, 0750); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dirName) }()
+
+	ps, err := positions.New(logger, positions.Config{
+		SyncPeriod:    10 * time.Second,
+		PositionsFile: dirName + "/positions.yml",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Stop()
+
+	var relabelConfigs []*relabel.Config
+	if err := yaml.UnmarshalStrict([]byte(swarmRelabelConfigs), &relabelConfigs); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &targetSyncer{
+		log:           logger,
+		positions:     ps,
+		relabelConfig: relabelConfigs,
+		targets:       map[string]*FileTarget{},
+		hostname:      "node1",
+		entryHandler:  &testutils.TestClient{Log: logger},
+		targetConfig:  &Config{SyncPeriod: 10 * time.Second},
+	}
+	defer s.stop()
+
+	task := func(node, container, address string) model.LabelSet {
+		return model.LabelSet{
+			"__address__":                          model.LabelValue(address),
+			"__meta_dockerswarm_node_hostname":     model.LabelValue(node),
+			"__meta_dockerswarm_task_container_id": model.LabelValue(container),
+			"__meta_dockerswarm_service_name":      "api",
+		}
+	}
+	s.sync([]*targetgroup.Group{{
+		Source: "DockerSwarm",
+		Targets: []model.LabelSet{
+			task("node1", "abc", "10.0.0.1:80"),
+			// the same task attached to another network
+			task("node1", "abc", "10.0.1.1:80"),
+			task("node2", "def", "10.0.0.2:80"),
+		},
+	}})
+
+	active := s.ActiveTargets()
+	if len(active) != 1 {
+		t.Fatal("Expected one target for the task of the node, got", len(active))
+	}
+	if labels := active[0].Labels(); !labels.Equal(model.LabelSet{"service": "api"}) {
+		t.Error("Expected the target to be labeled with its service, got", labels)
+	}
+	if path := s.targets[model.LabelSet{"service": "api"}.String()].path; path != "/var/lib/docker/containers/abc/abc-json.log" {
+		t.Error("Expected the path of the container logs, got", path)
+	}
+	if dropped := s.DroppedTargets(); len(dropped) != 2 {
+		t.Error("Expected the duplicate target and the target of the other node to be dropped, got", len(dropped))
+	}
+}
@@ -25,7 +25,11 @@ type tailer struct {
 	tail *tail.Tail
 
 	posAndSizeMtx sync.Mutex
-	stopOnce      sync.Once
+	pos           int64
+	size          int64
+	// caughtUp is when the file was last read up to its end.
+	caughtUp time.Time
+	stopOnce sync.Once
 
 	running *atomic.Bool
 	posquit chan struct{}
@@ -70,6 +74,9 @@ func newTailer(logger log.Logger, handler api.EntryHandler, positions positions.
 		positions: positions,
 		path:      path,
 		tail:      tail,
+		pos:       pos,
+		size:      fi.Size(),
+		caughtUp:  time.Now(),
 		running:   atomic.NewBool(false),
 		posquit:   make(chan struct{}),
 		posdone:   make(chan struct{}),
@@ -177,9 +184,39 @@ func (t *tailer) markPositionAndSize() error {
 	readBytes.WithLabelValues(t.path).Set(float64(pos))
 	t.positions.Put(t.path, pos)
 
+	t.pos, t.size = pos, size
+	if pos >= size {
+		t.caughtUp = time.Now()
+	}
+	fileLag.WithLabelValues(t.path).Set(time.Since(t.caughtUp).Seconds())
+
 	return nil
 }
 
+// FileStatus is how far a file has been read.
+type FileStatus struct {
+	Path     string `json:"path"`
+	Position int64  `json:"position"`
+	Size     int64  `json:"size"`
+	// LagBytes is the number of bytes left to read.
+	LagBytes int64 `json:"lag_bytes"`
+	// LagSeconds is the time since the file was last read up to its end, estimating how late the lines being read are.
+	LagSeconds float64 `json:"lag_seconds"`
+}
+
+// status returns how far the file has been read, as of the last time the position was marked.
+func (t *tailer) status() FileStatus {
+	t.posAndSizeMtx.Lock()
+	defer t.posAndSizeMtx.Unlock()
+
+	status := FileStatus{Path: t.path, Position: t.pos, Size: t.size}
+	if t.pos < t.size {
+		status.LagBytes = t.size - t.pos
+		status.LagSeconds = time.Since(t.caughtUp).Seconds()
+	}
+	return status
+}
+
 func (t *tailer) stop() {
 	// stop can be called by two separate threads in filetarget, to avoid a panic closing channels more than once
 	// we wrap the stop in a sync.Once.
@@ -216,5 +253,6 @@ func (t *tailer) cleanupMetrics() {
 	readLines.DeleteLabelValues(t.path)
 	readBytes.DeleteLabelValues(t.path)
 	totalBytes.DeleteLabelValues(t.path)
+	fileLag.DeleteLabelValues(t.path)
 	logLengthHistogram.DeleteLabelValues(t.path)
 }
@@ -0,0 +1,73 @@
+package gcplog
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/prometheus/prometheus/util/strutil"
+
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+)
+
+// LogEntry is the part of a LogEntry of Google Cloud Logging, as routed to Pub/Sub by a sink, used to label it.
+// See https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry.
+type LogEntry struct {
+	LogName  string `json:"logName"`
+	Resource struct {
+		Type   string            `json:"type"`
+		Labels map[string]string `json:"labels"`
+	} `json:"resource"`
+	Timestamp string `json:"timestamp"`
+	Severity  string `json:"severity"`
+}
+
+// ParseLogEntry parses the JSON of a LogEntry.
+func ParseLogEntry(data []byte) (*LogEntry, error) {
+	e := &LogEntry{}
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// timestamp returns the time of the entry when configured to, the current time otherwise.
+func timestamp(cfg *scrapeconfig.GcplogTargetConfig, e *LogEntry) time.Time {
+	if cfg.UseIncomingTimestamp {
+		if ts, err := time.Parse(time.RFC3339Nano, e.Timestamp); err == nil {
+			return ts
+		}
+	}
+	return time.Now()
+}
+
+// entryLabels returns the labels of the entry once relabeled, without the internal ones. The labels are nil when the
+// relabeling drops the entry.
+func entryLabels(cfg *scrapeconfig.GcplogTargetConfig, relabelConfig []*relabel.Config, e *LogEntry) model.LabelSet {
+	lb := labels.NewBuilder(nil)
+	for k, v := range cfg.Labels {
+		lb.Set(string(k), string(v))
+	}
+	lb.Set("__gcp_logname", e.LogName)
+	lb.Set("__gcp_resource_type", e.Resource.Type)
+	lb.Set("__gcp_severity", e.Severity)
+	for k, v := range e.Resource.Labels {
+		lb.Set("__gcp_resource_labels_"+strutil.SanitizeLabelName(k), v)
+	}
+
+	processed := relabel.Process(lb.Labels(), relabelConfig...)
+	if processed == nil {
+		return nil
+	}
+	ls := make(model.LabelSet, len(processed))
+	for _, l := range processed {
+		if strings.HasPrefix(l.Name, "__") {
+			continue
+		}
+		ls[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+	}
+	return ls
+}
@@ -0,0 +1,237 @@
+package gcplog
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"go.uber.org/atomic"
+	"google.golang.org/api/option"
+	pubsub "google.golang.org/api/pubsub/v1"
+
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+	"github.com/famarks/loki/pkg/promtail/targets/target"
+)
+
+var (
+	gcplogEntries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "gcplog_target_entries_total",
+		Help:      "Total number of log entries pulled from Pub/Sub.",
+	}, []string{"job"})
+	gcplogParsingErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "gcplog_target_parsing_errors_total",
+		Help:      "Total number of messages pulled from Pub/Sub that are not log entries, acknowledged without being sent.",
+	}, []string{"job"})
+)
+
+const (
+	defaultMaxMessages = 100
+
+	// retryBackoff is the time to wait before pulling again after a failure.
+	retryBackoff = 5 * time.Second
+)
+
+// GcplogTarget pulls the log entries of Google Cloud Logging from a Pub/Sub subscription.
+// nolint:golint
+type GcplogTarget struct {
+	logger        log.Logger
+	handler       api.EntryHandler
+	jobName       string
+	relabelConfig []*relabel.Config
+	config        *scrapeconfig.GcplogTargetConfig
+
+	subscriptions *pubsub.ProjectsSubscriptionsService
+	ready         atomic.Bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewGcplogTarget starts pulling the log entries of the subscription of the config.
+func NewGcplogTarget(
+	logger log.Logger,
+	handler api.EntryHandler,
+	jobName string,
+	relabelConfig []*relabel.Config,
+	config *scrapeconfig.GcplogTargetConfig,
+) (*GcplogTarget, error) {
+	if config.ProjectID == "" || config.Subscription == "" {
+		return nil, fmt.Errorf("the project_id and the subscription of the gcplog of job %s are required", jobName)
+	}
+	opts := []option.ClientOption{option.WithScopes(pubsub.PubsubScope)}
+	if config.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(config.CredentialsFile))
+	}
+	return newGcplogTarget(logger, handler, jobName, relabelConfig, config, opts...)
+}
+
+func newGcplogTarget(
+	logger log.Logger,
+	handler api.EntryHandler,
+	jobName string,
+	relabelConfig []*relabel.Config,
+	config *scrapeconfig.GcplogTargetConfig,
+	opts ...option.ClientOption,
+) (*GcplogTarget, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	service, err := pubsub.NewService(ctx, opts...)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("unable to create the pubsub client of job %s: %w", jobName, err)
+	}
+
+	t := &GcplogTarget{
+		logger:        log.With(logger, "job", jobName, "subscription", config.Subscription),
+		handler:       handler,
+		jobName:       jobName,
+		relabelConfig: relabelConfig,
+		config:        config,
+		subscriptions: pubsub.NewProjectsSubscriptionsService(service),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+	t.wg.Add(1)
+	go t.run()
+	return t, nil
+}
+
+func (t *GcplogTarget) subscription() string {
+	return fmt.Sprintf("projects/%s/subscriptions/%s", t.config.ProjectID, t.config.Subscription)
+}
+
+func (t *GcplogTarget) run() {
+	defer t.wg.Done()
+
+	for t.ctx.Err() == nil {
+		if err := t.pull(); err != nil {
+			if t.ctx.Err() != nil {
+				return
+			}
+			t.ready.Store(false)
+			level.Error(t.logger).Log("msg", "error pulling log entries", "err", err)
+			select {
+			case <-time.After(retryBackoff):
+			case <-t.ctx.Done():
+			}
+		}
+	}
+}
+
+// pull pulls a batch of messages. The messages handed off are acknowledged, the others being made available again
+// right away for them to be pulled again.
+func (t *GcplogTarget) pull() error {
+	maxMessages := t.config.MaxMessages
+	if maxMessages <= 0 {
+		maxMessages = defaultMaxMessages
+	}
+	resp, err := t.subscriptions.Pull(t.subscription(), &pubsub.PullRequest{MaxMessages: int64(maxMessages)}).Context(t.ctx).Do()
+	if err != nil {
+		return err
+	}
+	t.ready.Store(true)
+
+	var acks, nacks []string
+	for _, m := range resp.ReceivedMessages {
+		if err := t.handle(m.Message); err != nil {
+			level.Error(t.logger).Log("msg", "error handling log entry, it will be pulled again", "message_id", m.Message.MessageId, "err", err)
+			nacks = append(nacks, m.AckId)
+			continue
+		}
+		acks = append(acks, m.AckId)
+	}
+
+	var errs []error
+	if len(acks) > 0 {
+		if _, err := t.subscriptions.Acknowledge(t.subscription(), &pubsub.AcknowledgeRequest{AckIds: acks}).Context(t.ctx).Do(); err != nil {
+			errs = append(errs, fmt.Errorf("unable to acknowledge the log entries sent, they will be sent again: %w", err))
+		}
+	}
+	if len(nacks) > 0 {
+		nack := &pubsub.ModifyAckDeadlineRequest{AckIds: nacks, AckDeadlineSeconds: 0, ForceSendFields: []string{"AckDeadlineSeconds"}}
+		if _, err := t.subscriptions.ModifyAckDeadline(t.subscription(), nack).Context(t.ctx).Do(); err != nil {
+			errs = append(errs, fmt.Errorf("unable to release the log entries not sent: %w", err))
+		}
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// handle sends the log entry of the message. The messages that are not log entries are dropped without error, for
+// them to be acknowledged.
+func (t *GcplogTarget) handle(m *pubsub.PubsubMessage) error {
+	if m == nil {
+		return nil
+	}
+	data, err := base64.StdEncoding.DecodeString(m.Data)
+	if err != nil {
+		t.dropMessage(m, err)
+		return nil
+	}
+	e, err := ParseLogEntry(data)
+	if err != nil {
+		t.dropMessage(m, err)
+		return nil
+	}
+
+	gcplogEntries.WithLabelValues(t.jobName).Inc()
+	ls := entryLabels(t.config, t.relabelConfig, e)
+	if len(ls) == 0 {
+		return nil
+	}
+	return t.handler.Handle(ls, timestamp(t.config, e), string(data))
+}
+
+func (t *GcplogTarget) dropMessage(m *pubsub.PubsubMessage, err error) {
+	gcplogParsingErrors.WithLabelValues(t.jobName).Inc()
+	level.Warn(t.logger).Log("msg", "dropping message that is not a log entry", "message_id", m.MessageId, "err", err)
+}
+
+// Type returns GcplogTargetType.
+func (t *GcplogTarget) Type() target.TargetType {
+	return target.GcplogTargetType
+}
+
+// Ready indicates whether or not the last pull succeeded.
+func (t *GcplogTarget) Ready() bool {
+	return t.ready.Load()
+}
+
+// DiscoveredLabels returns the set of labels discovered by the GcplogTarget, which
+// is always nil. Implements Target.
+func (t *GcplogTarget) DiscoveredLabels() model.LabelSet {
+	return nil
+}
+
+// Labels returns the set of labels that statically apply to all log entries
+// produced by the GcplogTarget.
+func (t *GcplogTarget) Labels() model.LabelSet {
+	return t.config.Labels
+}
+
+// Details returns target-specific details.
+func (t *GcplogTarget) Details() interface{} {
+	return map[string]string{
+		"subscription": t.subscription(),
+	}
+}
+
+// Stop stops pulling, the messages not acknowledged yet being pulled again by the next subscriber.
+func (t *GcplogTarget) Stop() error {
+	t.cancel()
+	t.wg.Wait()
+	return nil
+}
@@ -0,0 +1,156 @@
+package gcplog
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	pubsub "google.golang.org/api/pubsub/v1"
+
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+)
+
+const sampleLogEntry = `{
+  "insertId": "1x2y3z",
+  "logName": "projects/my-project/logs/cloudaudit.googleapis.com%2Factivity",
+  "resource": {
+    "type": "gcs_bucket",
+    "labels": {"bucket_name": "my-bucket", "location": "europe-west1", "project_id": "my-project"}
+  },
+  "severity": "NOTICE",
+  "timestamp": "2020-11-05T10:15:30.123456Z",
+  "protoPayload": {"methodName": "storage.buckets.create"}
+}`
+
+// fakePubsub serves a batch of messages once and records the acknowledgements.
+type fakePubsub struct {
+	mtx      sync.Mutex
+	messages []*pubsub.ReceivedMessage
+	acked    []string
+	nacked   []string
+}
+
+func (f *fakePubsub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/v1/projects/my-project/subscriptions/logs:pull"):
+		_ = json.NewEncoder(w).Encode(&pubsub.PullResponse{ReceivedMessages: f.messages})
+		f.messages = nil
+	case strings.HasSuffix(r.URL.Path, ":acknowledge"):
+		req := &pubsub.AcknowledgeRequest{}
+		_ = json.NewDecoder(r.Body).Decode(req)
+		f.acked = append(f.acked, req.AckIds...)
+		_, _ = w.Write([]byte("{}"))
+	case strings.HasSuffix(r.URL.Path, ":modifyAckDeadline"):
+		req := &pubsub.ModifyAckDeadlineRequest{}
+		_ = json.NewDecoder(r.Body).Decode(req)
+		f.nacked = append(f.nacked, req.AckIds...)
+		_, _ = w.Write([]byte("{}"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakePubsub) acks() ([]string, []string) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.acked, f.nacked
+}
+
+func message(ackID, data string) *pubsub.ReceivedMessage {
+	return &pubsub.ReceivedMessage{
+		AckId:   ackID,
+		Message: &pubsub.PubsubMessage{MessageId: ackID, Data: base64.StdEncoding.EncodeToString([]byte(data))},
+	}
+}
+
+func TestGcplogTarget(t *testing.T) {
+	fake := &fakePubsub{messages: []*pubsub.ReceivedMessage{
+		message("1", sampleLogEntry),
+		message("2", "not a log entry"),
+		message("3", strings.Replace(sampleLogEntry, "my-bucket", "fails", 1)),
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	var mtx sync.Mutex
+	var handled []string
+	handler := api.EntryHandlerFunc(func(labels model.LabelSet, time time.Time, entry string) error {
+		if labels["bucket"] == "fails" {
+			return errors.New("full")
+		}
+		mtx.Lock()
+		defer mtx.Unlock()
+		require.Equal(t, model.LabelSet{"job": "gcplog", "resource_type": "gcs_bucket", "bucket": "my-bucket"}, labels)
+		handled = append(handled, entry)
+		return nil
+	})
+	config := &scrapeconfig.GcplogTargetConfig{
+		ProjectID:    "my-project",
+		Subscription: "logs",
+		Labels:       model.LabelSet{"job": "gcplog"},
+	}
+	tgt, err := newGcplogTarget(util.Logger, handler, "gcplog", []*relabel.Config{
+		{
+			SourceLabels: model.LabelNames{"__gcp_resource_type"},
+			Regex:        relabel.MustNewRegexp("(.*)"),
+			Replacement:  "$1",
+			TargetLabel:  "resource_type",
+			Action:       relabel.Replace,
+		},
+		{
+			SourceLabels: model.LabelNames{"__gcp_resource_labels_bucket_name"},
+			Regex:        relabel.MustNewRegexp("(.*)"),
+			Replacement:  "$1",
+			TargetLabel:  "bucket",
+			Action:       relabel.Replace,
+		},
+	}, config, option.WithEndpoint(server.URL+"/"), option.WithoutAuthentication())
+	require.NoError(t, err)
+	defer func() { require.NoError(t, tgt.Stop()) }()
+
+	require.Eventually(t, func() bool {
+		acked, nacked := fake.acks()
+		return len(acked) == 2 && len(nacked) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the message that is not a log entry is acknowledged, the one not handed off is released to be pulled again
+	acked, nacked := fake.acks()
+	require.Equal(t, []string{"1", "2"}, acked)
+	require.Equal(t, []string{"3"}, nacked)
+	mtx.Lock()
+	require.Equal(t, []string{sampleLogEntry}, handled)
+	mtx.Unlock()
+	require.True(t, tgt.Ready())
+	require.Equal(t, map[string]string{"subscription": "projects/my-project/subscriptions/logs"}, tgt.Details())
+}
+
+func TestTimestamp(t *testing.T) {
+	e, err := ParseLogEntry([]byte(sampleLogEntry))
+	require.NoError(t, err)
+
+	ts := timestamp(&scrapeconfig.GcplogTargetConfig{UseIncomingTimestamp: true}, e)
+	require.Equal(t, time.Date(2020, 11, 5, 10, 15, 30, 123456000, time.UTC), ts)
+
+	ts = timestamp(&scrapeconfig.GcplogTargetConfig{}, e)
+	require.WithinDuration(t, time.Now(), ts, time.Minute)
+}
+
+func TestNewGcplogTarget_InvalidConfig(t *testing.T) {
+	_, err := NewGcplogTarget(util.Logger, nil, "gcplog", nil, &scrapeconfig.GcplogTargetConfig{ProjectID: "my-project"})
+	require.Error(t, err)
+}
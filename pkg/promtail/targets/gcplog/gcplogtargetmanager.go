@@ -0,0 +1,85 @@
+package gcplog
+
+import (
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/famarks/loki/pkg/logentry/stages"
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+	"github.com/famarks/loki/pkg/promtail/targets/target"
+)
+
+// GcplogTargetManager manages a series of GcplogTargets.
+// nolint:golint
+type GcplogTargetManager struct {
+	logger  log.Logger
+	targets map[string]*GcplogTarget
+}
+
+// NewGcplogTargetManager creates a new GcplogTargetManager.
+func NewGcplogTargetManager(
+	logger log.Logger,
+	client api.EntryHandler,
+	scrapeConfigs []scrapeconfig.Config,
+) (*GcplogTargetManager, error) {
+
+	tm := &GcplogTargetManager{
+		logger:  logger,
+		targets: make(map[string]*GcplogTarget),
+	}
+
+	for _, cfg := range scrapeConfigs {
+		registerer := prometheus.DefaultRegisterer
+		pipeline, err := stages.NewPipeline(log.With(logger, "component", "gcplog_pipeline"), cfg.PipelineStages, &cfg.JobName, registerer)
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := NewGcplogTarget(logger, pipeline.Wrap(client), cfg.JobName, cfg.RelabelConfigs, cfg.GcplogConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		tm.targets[cfg.JobName] = t
+	}
+
+	return tm, nil
+}
+
+// Ready returns true if at least one GcplogTarget is also ready.
+func (tm *GcplogTargetManager) Ready() bool {
+	for _, t := range tm.targets {
+		if t.Ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// Stop stops the GcplogTargetManager and all of its GcplogTargets.
+func (tm *GcplogTargetManager) Stop() {
+	for _, t := range tm.targets {
+		if err := t.Stop(); err != nil {
+			level.Error(t.logger).Log("msg", "error stopping GcplogTarget", "err", err.Error())
+		}
+	}
+}
+
+// ActiveTargets returns the list of GcplogTargets where log entries
+// are being pulled. ActiveTargets is an alias to AllTargets as
+// GcplogTargets cannot be deactivated, only stopped.
+func (tm *GcplogTargetManager) ActiveTargets() map[string][]target.Target {
+	return tm.AllTargets()
+}
+
+// AllTargets returns the list of all targets where log entries
+// are currently being pulled.
+func (tm *GcplogTargetManager) AllTargets() map[string][]target.Target {
+	result := make(map[string][]target.Target, len(tm.targets))
+	for k, v := range tm.targets {
+		result[k] = []target.Target{v}
+	}
+	return result
+}
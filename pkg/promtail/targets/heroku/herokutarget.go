@@ -0,0 +1,226 @@
+package heroku
+
+import (
+	"flag"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/imdario/mergo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/weaveworks/common/server"
+
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+	"github.com/famarks/loki/pkg/promtail/targets/target"
+)
+
+const (
+	// drainTokenHeader is the header holding the token of the drain the logs are sent by.
+	drainTokenHeader = "Logplex-Drain-Token"
+)
+
+var (
+	herokuEntries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "heroku_drain_target_entries_total",
+		Help:      "Total number of log lines received from Heroku drains.",
+	}, []string{"job"})
+	herokuParsingErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "heroku_drain_target_parsing_errors_total",
+		Help:      "Total number of requests of Heroku drains rejected as invalid.",
+	}, []string{"job"})
+)
+
+// HerokuTarget receives the logs of Heroku apps through a Logplex HTTPS drain.
+// nolint:golint
+type HerokuTarget struct {
+	logger        log.Logger
+	handler       api.EntryHandler
+	config        *scrapeconfig.HerokuDrainTargetConfig
+	relabelConfig []*relabel.Config
+	jobName       string
+	server        *server.Server
+}
+
+// NewHerokuTarget starts the server receiving the logs of the drains of the config.
+func NewHerokuTarget(logger log.Logger,
+	handler api.EntryHandler,
+	relabel []*relabel.Config,
+	jobName string,
+	config *scrapeconfig.HerokuDrainTargetConfig) (*HerokuTarget, error) {
+
+	ht := &HerokuTarget{
+		logger:        logger,
+		handler:       handler,
+		relabelConfig: relabel,
+		jobName:       jobName,
+		config:        config,
+	}
+
+	// Bit of a chicken and egg problem trying to register the defaults and apply overrides from the loaded config.
+	// First create an empty config and set defaults.
+	defaults := server.Config{}
+	defaults.RegisterFlags(flag.NewFlagSet("empty", flag.ContinueOnError))
+	// Then apply any config values loaded as overrides to the defaults.
+	if err := mergo.Merge(&defaults, config.Server, mergo.WithOverride); err != nil {
+		level.Error(logger).Log("msg", "failed to parse configs and override defaults when configuring heroku drain server", "err", err)
+	}
+	// The merge won't overwrite with a zero value but in the case of ports 0 value
+	// indicates the desire for a random port so reset these to zero if the incoming config val is 0
+	if config.Server.HTTPListenPort == 0 {
+		defaults.HTTPListenPort = 0
+	}
+	if config.Server.GRPCListenPort == 0 {
+		defaults.GRPCListenPort = 0
+	}
+	// Set the config to the new combined config.
+	config.Server = defaults
+
+	err := ht.run()
+	if err != nil {
+		return nil, err
+	}
+
+	return ht, nil
+}
+
+func (t *HerokuTarget) run() error {
+	level.Info(t.logger).Log("msg", "starting heroku drain server", "job", t.jobName)
+	// To prevent metric collisions because all metrics are going to be registered in the global Prometheus registry.
+	t.config.Server.MetricsNamespace = "promtail_" + t.jobName
+
+	// We don't want the /debug and /metrics endpoints running
+	t.config.Server.RegisterInstrumentation = false
+
+	util.InitLogger(&t.config.Server)
+
+	srv, err := server.New(t.config.Server)
+	if err != nil {
+		return err
+	}
+
+	t.server = srv
+	t.server.HTTP.Handle("/heroku/api/v1/drain", http.HandlerFunc(t.handle))
+
+	go func() {
+		err := srv.Run()
+		if err != nil {
+			level.Error(t.logger).Log("msg", "Heroku drain server shutdown with error", "err", err)
+		}
+	}()
+
+	return nil
+}
+
+func (t *HerokuTarget) handle(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get(drainTokenHeader)
+	tokenLabels, ok := t.config.DrainTokens[token]
+	if len(t.config.DrainTokens) > 0 && !ok {
+		level.Warn(t.logger).Log("msg", "rejecting logs of an unknown drain", "token", token)
+		http.Error(w, "unknown drain token", http.StatusUnauthorized)
+		return
+	}
+
+	msgs, err := parseFrames(r.Body)
+	if err != nil {
+		herokuParsingErrors.WithLabelValues(t.jobName).Inc()
+		level.Warn(t.logger).Log("msg", "failed to parse incoming drain request", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var lastErr error
+	for _, msg := range msgs {
+		herokuEntries.WithLabelValues(t.jobName).Inc()
+		lb := labels.NewBuilder(nil)
+
+		// Add configured labels
+		for k, v := range t.config.Labels {
+			lb.Set(string(k), string(v))
+		}
+		for k, v := range tokenLabels {
+			lb.Set(string(k), string(v))
+		}
+
+		// Add the internal labels of the message
+		lb.Set("__heroku_drain_token", token)
+		lb.Set("__heroku_drain_host", msg.Hostname)
+		lb.Set("__heroku_drain_app", msg.AppName)
+		lb.Set("__heroku_drain_proc", msg.ProcID)
+		lb.Set("__heroku_drain_log_id", msg.MsgID)
+
+		// Apply relabeling
+		processed := relabel.Process(lb.Labels(), t.relabelConfig...)
+		if len(processed) == 0 {
+			continue
+		}
+
+		// Convert to model.LabelSet
+		filtered := model.LabelSet{}
+		for i := range processed {
+			if strings.HasPrefix(processed[i].Name, "__") {
+				continue
+			}
+			filtered[model.LabelName(processed[i].Name)] = model.LabelValue(processed[i].Value)
+		}
+
+		ts := time.Now()
+		if t.config.UseIncomingTimestamp {
+			ts = msg.Timestamp
+		}
+		if err := t.handler.Handle(filtered, ts, msg.Message); err != nil {
+			lastErr = err
+		}
+	}
+
+	if lastErr != nil {
+		level.Warn(t.logger).Log("msg", "at least one entry in the drain request failed to process", "err", lastErr.Error())
+		http.Error(w, lastErr.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Type returns HerokuDrainTargetType.
+func (t *HerokuTarget) Type() target.TargetType {
+	return target.HerokuDrainTargetType
+}
+
+// Ready indicates whether or not the HerokuTarget target is ready to be read from.
+func (t *HerokuTarget) Ready() bool {
+	return true
+}
+
+// DiscoveredLabels returns the set of labels discovered by the HerokuTarget, which
+// is always nil. Implements Target.
+func (t *HerokuTarget) DiscoveredLabels() model.LabelSet {
+	return nil
+}
+
+// Labels returns the set of labels that statically apply to all log entries
+// produced by the HerokuTarget.
+func (t *HerokuTarget) Labels() model.LabelSet {
+	return t.config.Labels
+}
+
+// Details returns target-specific details.
+func (t *HerokuTarget) Details() interface{} {
+	return map[string]string{}
+}
+
+// Stop shuts down the HerokuTarget.
+func (t *HerokuTarget) Stop() error {
+	level.Info(t.logger).Log("msg", "stopping heroku drain server", "job", t.jobName)
+	t.server.Shutdown()
+	return nil
+}
@@ -0,0 +1,152 @@
+package heroku
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/server"
+
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+	"github.com/famarks/loki/pkg/promtail/targets/testutils"
+)
+
+func frames(msgs ...string) string {
+	var sb strings.Builder
+	for _, m := range msgs {
+		fmt.Fprintf(&sb, "%d %s", len(m), m)
+	}
+	return sb.String()
+}
+
+func TestHerokuTarget(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+
+	eh := &testutils.TestClient{
+		Log:      logger,
+		Messages: make([]*testutils.Entry, 0),
+	}
+
+	// Get a randomly available port by open and closing a TCP socket
+	addr, err := net.ResolveTCPAddr("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l, err := net.ListenTCP("tcp", addr)
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	defaults := server.Config{}
+	defaults.RegisterFlags(flag.NewFlagSet("empty", flag.ContinueOnError))
+	defaults.HTTPListenAddress = "127.0.0.1"
+	defaults.HTTPListenPort = port
+	defaults.GRPCListenAddress = "127.0.0.1"
+	defaults.GRPCListenPort = 0 // Not testing GRPC, a random port will be assigned
+
+	config := &scrapeconfig.HerokuDrainTargetConfig{
+		Server: defaults,
+		Labels: model.LabelSet{"job": "heroku"},
+		DrainTokens: map[string]model.LabelSet{
+			"d.a1b2": {"app": "my-app"},
+		},
+		UseIncomingTimestamp: true,
+	}
+	rlbl := []*relabel.Config{
+		{
+			SourceLabels: model.LabelNames{"__heroku_drain_proc"},
+			Regex:        relabel.MustNewRegexp("(.*)"),
+			Replacement:  "$1",
+			TargetLabel:  "proc",
+			Action:       relabel.Replace,
+		},
+		{
+			SourceLabels: model.LabelNames{"__heroku_drain_app"},
+			Regex:        relabel.MustNewRegexp("heroku"),
+			Action:       relabel.Drop,
+		},
+	}
+	ht, err := NewHerokuTarget(logger, eh, rlbl, "heroku", config)
+	require.NoError(t, err)
+	defer func() { _ = ht.Stop() }()
+
+	url := "http://127.0.0.1:" + strconv.Itoa(port) + "/heroku/api/v1/drain"
+	body := frames(
+		"<190>1 2020-10-15T12:00:00.123456+00:00 host app web.1 - Started GET \"/\"\n",
+		"<158>1 2020-10-15T12:00:01+00:00 host heroku router - at=info method=GET\n",
+		"<190>1 2020-10-15T12:00:02+00:00 host app worker.1 - Job done\n",
+	)
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/logplex-1")
+	req.Header.Set(drainTokenHeader, "d.a1b2")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, res.StatusCode)
+	_ = res.Body.Close()
+
+	require.Equal(t, 2, len(eh.Messages))
+	require.Equal(t, model.LabelSet{"job": "heroku", "app": "my-app", "proc": "web.1"}, eh.Messages[0].Labels)
+	require.Equal(t, "Started GET \"/\"", eh.Messages[0].Log)
+	require.Equal(t, time.Date(2020, 10, 15, 12, 0, 0, 123456000, time.UTC), eh.Messages[0].Time.UTC())
+	require.Equal(t, model.LabelSet{"job": "heroku", "app": "my-app", "proc": "worker.1"}, eh.Messages[1].Labels)
+
+	// the drains with other tokens are rejected
+	req, err = http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set(drainTokenHeader, "d.unknown")
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	_ = res.Body.Close()
+	require.Equal(t, 2, len(eh.Messages))
+}
+
+func TestParseFrames(t *testing.T) {
+	msgs, err := parseFrames(strings.NewReader(frames(
+		"<40>1 2012-11-30T06:45:29+00:00 host app web.3 - State changed from starting to up",
+		"<40>1 2012-11-30T06:45:30+00:00 host app - - \n",
+	)))
+	require.NoError(t, err)
+	require.Equal(t, []logplexMessage{
+		{
+			Timestamp: time.Date(2012, 11, 30, 6, 45, 29, 0, time.UTC),
+			Hostname:  "host",
+			AppName:   "app",
+			ProcID:    "web.3",
+			Message:   "State changed from starting to up",
+		},
+		{
+			Timestamp: time.Date(2012, 11, 30, 6, 45, 30, 0, time.UTC),
+			Hostname:  "host",
+			AppName:   "app",
+		},
+	}, utcMessages(msgs))
+
+	for _, body := range []string{
+		"abc <40>1",
+		"100 <40>1 2012-11-30T06:45:29+00:00 host app web.3 - truncated",
+		frames("<40>1 2012-11-30T06:45:29+00:00 host"),
+		frames("40 2012-11-30T06:45:29+00:00 host app web.3 - no priority"),
+		frames("<40>1 yesterday host app web.3 - invalid timestamp"),
+	} {
+		_, err := parseFrames(strings.NewReader(body))
+		require.Error(t, err, body)
+	}
+}
+
+func utcMessages(msgs []logplexMessage) []logplexMessage {
+	for i := range msgs {
+		msgs[i].Timestamp = msgs[i].Timestamp.UTC()
+	}
+	return msgs
+}
@@ -0,0 +1,112 @@
+package heroku
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/famarks/loki/pkg/logentry/stages"
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+	"github.com/famarks/loki/pkg/promtail/targets/target"
+)
+
+// HerokuTargetManager manages a series of HerokuTargets.
+// nolint:golint
+type HerokuTargetManager struct {
+	logger  log.Logger
+	targets map[string]*HerokuTarget
+}
+
+// NewHerokuTargetManager creates a new HerokuTargetManager.
+func NewHerokuTargetManager(
+	logger log.Logger,
+	client api.EntryHandler,
+	scrapeConfigs []scrapeconfig.Config,
+) (*HerokuTargetManager, error) {
+
+	tm := &HerokuTargetManager{
+		logger:  logger,
+		targets: make(map[string]*HerokuTarget),
+	}
+
+	if err := validateJobName(scrapeConfigs); err != nil {
+		return nil, err
+	}
+
+	for _, cfg := range scrapeConfigs {
+		registerer := prometheus.DefaultRegisterer
+		pipeline, err := stages.NewPipeline(log.With(logger, "component", "heroku_drain_pipeline_"+cfg.JobName), cfg.PipelineStages, &cfg.JobName, registerer)
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := NewHerokuTarget(logger, pipeline.Wrap(client), cfg.RelabelConfigs, cfg.JobName, cfg.HerokuDrainConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		tm.targets[cfg.JobName] = t
+	}
+
+	return tm, nil
+}
+
+func validateJobName(scrapeConfigs []scrapeconfig.Config) error {
+	jobNames := map[string]struct{}{}
+	for i, cfg := range scrapeConfigs {
+		if cfg.JobName == "" {
+			return errors.New("`job_name` must be defined for the `heroku_drain` scrape_config with a " +
+				"unique name to properly register metrics, " +
+				"at least one `heroku_drain` scrape_config has no `job_name` defined")
+		}
+		if _, ok := jobNames[cfg.JobName]; ok {
+			return fmt.Errorf("`job_name` must be unique for each `heroku_drain` scrape_config, "+
+				"a duplicate `job_name` of %s was found", cfg.JobName)
+		}
+		jobNames[cfg.JobName] = struct{}{}
+
+		scrapeConfigs[i].JobName = strings.Replace(cfg.JobName, " ", "_", -1)
+	}
+	return nil
+}
+
+// Ready returns true if at least one HerokuTarget is also ready.
+func (tm *HerokuTargetManager) Ready() bool {
+	for _, t := range tm.targets {
+		if t.Ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// Stop stops the HerokuTargetManager and all of its HerokuTargets.
+func (tm *HerokuTargetManager) Stop() {
+	for _, t := range tm.targets {
+		if err := t.Stop(); err != nil {
+			level.Error(t.logger).Log("msg", "error stopping HerokuTarget", "err", err.Error())
+		}
+	}
+}
+
+// ActiveTargets returns the list of HerokuTargets where Heroku drain data
+// is being read. ActiveTargets is an alias to AllTargets as
+// HerokuTargets cannot be deactivated, only stopped.
+func (tm *HerokuTargetManager) ActiveTargets() map[string][]target.Target {
+	return tm.AllTargets()
+}
+
+// AllTargets returns the list of all targets where Heroku drain data
+// is currently being read.
+func (tm *HerokuTargetManager) AllTargets() map[string][]target.Target {
+	result := make(map[string][]target.Target, len(tm.targets))
+	for k, v := range tm.targets {
+		result[k] = []target.Target{v}
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package heroku
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxFrameSize bounds the size of a frame read, Logplex truncating the lines to 10KB.
+const maxFrameSize = 64 * 1024
+
+// logplexMessage is a syslog message of a Logplex drain. The messages are like the RFC5424 ones, without the
+// structured data, like "<40>1 2012-11-30T06:45:29+00:00 host app web.3 - State changed from starting to up".
+type logplexMessage struct {
+	Timestamp time.Time
+	Hostname  string
+	AppName   string
+	ProcID    string
+	MsgID     string
+	Message   string
+}
+
+// parseFrames parses the octet counted frames of the body of a drain request, each frame being a message prefixed
+// with its length.
+func parseFrames(r io.Reader) ([]logplexMessage, error) {
+	br := bufio.NewReader(r)
+	var msgs []logplexMessage
+	for {
+		prefix, err := br.ReadString(' ')
+		if err == io.EOF && strings.TrimSpace(prefix) == "" {
+			return msgs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid frame length: %w", err)
+		}
+		// frames may be separated by new lines
+		size, err := strconv.Atoi(strings.TrimSpace(prefix))
+		if err != nil || size <= 0 || size > maxFrameSize {
+			return nil, fmt.Errorf("invalid frame length %q", strings.TrimSpace(prefix))
+		}
+		frame := make([]byte, size)
+		if _, err := io.ReadFull(br, frame); err != nil {
+			return nil, fmt.Errorf("truncated frame: %w", err)
+		}
+		msg, err := parseMessage(string(frame))
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+// parseMessage parses a message, "-" being the nil value of its fields.
+func parseMessage(frame string) (logplexMessage, error) {
+	fields := strings.SplitN(frame, " ", 7)
+	if len(fields) < 6 || !strings.HasPrefix(fields[0], "<") || !strings.HasSuffix(fields[0], ">1") {
+		return logplexMessage{}, errors.New("invalid message header")
+	}
+	ts, err := time.Parse(time.RFC3339Nano, fields[1])
+	if err != nil {
+		return logplexMessage{}, fmt.Errorf("invalid message timestamp: %w", err)
+	}
+	msg := logplexMessage{
+		Timestamp: ts,
+		Hostname:  nilValue(fields[2]),
+		AppName:   nilValue(fields[3]),
+		ProcID:    nilValue(fields[4]),
+		MsgID:     nilValue(fields[5]),
+	}
+	if len(fields) == 7 {
+		msg.Message = strings.TrimRight(fields[6], "\r\n")
+	}
+	return msg, nil
+}
+
+func nilValue(field string) string {
+	if field == "-" {
+		return ""
+	}
+	return field
+}
@@ -96,6 +96,10 @@ type JournalTarget struct {
 	config        *scrapeconfig.JournalTargetConfig
 	labels        model.LabelSet
 
+	// skipCursor is the cursor of the last entry read before a restart, which
+	// is read again when starting from it.
+	skipCursor string
+
 	r     journalReader
 	until chan time.Time
 }
@@ -167,12 +171,19 @@ func journalTargetWithReader(
 		return nil, errors.Wrap(err, "parsing journal reader 'max_age' config value")
 	}
 
+	matches, err := buildMatches(targetConfig.Matches)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing journal reader 'matches' config value")
+	}
+
 	cfg := t.generateJournalConfig(journalConfigBuilder{
 		JournalPath: targetConfig.Path,
 		Position:    position,
+		Matches:     matches,
 		MaxAge:      maxAge,
 		EntryFunc:   entryFunc,
 	})
+	t.skipCursor = cfg.Cursor
 	t.r, err = readerFunc(cfg)
 	if err != nil {
 		return nil, errors.Wrap(err, "creating journal reader")
@@ -191,6 +202,7 @@ func journalTargetWithReader(
 type journalConfigBuilder struct {
 	JournalPath string
 	Position    string
+	Matches     []sdjournal.Match
 	MaxAge      time.Duration
 	EntryFunc   journalEntryFunc
 }
@@ -204,6 +216,7 @@ func (t *JournalTarget) generateJournalConfig(
 
 	cfg := sdjournal.JournalReaderConfig{
 		Path:      cb.JournalPath,
+		Matches:   cb.Matches,
 		Formatter: t.formatter,
 	}
 
@@ -242,6 +255,20 @@ func (t *JournalTarget) generateJournalConfig(
 }
 
 func (t *JournalTarget) formatter(entry *sdjournal.JournalEntry) (string, error) {
+	// Seeking the saved cursor positions the reader on the entry already sent
+	// before the restart, skip it.
+	if t.skipCursor != "" {
+		skip := entry.Cursor == t.skipCursor
+		t.skipCursor = ""
+		if skip {
+			return journalEmptyStr, nil
+		}
+	}
+
+	// The position is saved for all the entries read, including the ones
+	// dropped, for them not to be read again on restart.
+	t.positions.PutString(t.positionPath, entry.Cursor)
+
 	ts := time.Unix(0, int64(entry.RealtimeTimestamp)*int64(time.Microsecond))
 
 	var msg string
@@ -271,6 +298,13 @@ func (t *JournalTarget) formatter(entry *sdjournal.JournalEntry) (string, error)
 		entryLabels[string(k)] = string(v)
 	}
 
+	// Add the labels of the fields
+	for k, field := range t.config.FieldLabels {
+		if v, ok := entry.Fields[field]; ok {
+			entryLabels[string(k)] = v
+		}
+	}
+
 	processedLabels := relabel.Process(labels.FromMap(entryLabels), t.relabelConfig...)
 
 	processedLabelsMap := processedLabels.Map()
@@ -287,7 +321,6 @@ func (t *JournalTarget) formatter(entry *sdjournal.JournalEntry) (string, error)
 		return journalEmptyStr, nil
 	}
 
-	t.positions.PutString(t.positionPath, entry.Cursor)
 	err := t.handler.Handle(labels, ts, msg)
 	return journalEmptyStr, err
 }
@@ -329,6 +362,19 @@ func (t *JournalTarget) Stop() error {
 	return t.r.Close()
 }
 
+// buildMatches parses the space separated FIELD=value matches of the config.
+func buildMatches(input string) ([]sdjournal.Match, error) {
+	var matches []sdjournal.Match
+	for _, m := range strings.Fields(input) {
+		fv := strings.SplitN(m, "=", 2)
+		if len(fv) != 2 || fv[0] == "" {
+			return nil, fmt.Errorf("invalid match %q, must be FIELD=value", m)
+		}
+		matches = append(matches, sdjournal.Match{Field: fv[0], Value: fv[1]})
+	}
+	return matches, nil
+}
+
 func makeJournalFields(fields map[string]string) map[string]string {
 	result := make(map[string]string, len(fields))
 	for k, v := range fields {
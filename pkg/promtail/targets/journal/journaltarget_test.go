@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/coreos/go-systemd/sdjournal"
+	"github.com/prometheus/common/model"
 
 	"gopkg.in/yaml.v2"
 
@@ -317,3 +318,101 @@ func Test_MakeJournalFields(t *testing.T) {
 	}
 	assert.Equal(t, expectedFields, receivedFields)
 }
+
+func TestJournalTarget_Matches(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+
+	testutils.InitRandom()
+	dirName := "/tmp/" + testutils.RandName()
+	positionsFileName := dirName + "/positions.yml"
+
+	ps, err := positions.New(logger, positions.Config{
+		SyncPeriod:    10 * time.Second,
+		PositionsFile: positionsFileName,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &testutils.TestClient{
+		Log:      logger,
+		Messages: make([]*testutils.Entry, 0),
+	}
+
+	cfg := scrapeconfig.JournalTargetConfig{
+		Matches: "_SYSTEMD_UNIT=foo.service  PRIORITY=3",
+	}
+
+	jt, err := journalTargetWithReader(logger, client, ps, "test", nil,
+		&cfg, newMockJournalReader, newMockJournalEntry(nil))
+	require.NoError(t, err)
+
+	r := jt.r.(*mockJournalReader)
+	require.Equal(t, []sdjournal.Match{
+		{Field: "_SYSTEMD_UNIT", Value: "foo.service"},
+		{Field: "PRIORITY", Value: "3"},
+	}, r.config.Matches)
+
+	cfg.Matches = "_SYSTEMD_UNIT"
+	_, err = journalTargetWithReader(logger, client, ps, "test", nil,
+		&cfg, newMockJournalReader, newMockJournalEntry(nil))
+	require.EqualError(t, err, `parsing journal reader 'matches' config value: invalid match "_SYSTEMD_UNIT", must be FIELD=value`)
+}
+
+func TestJournalTarget_FieldLabelsAndCursor(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+
+	testutils.InitRandom()
+	dirName := "/tmp/" + testutils.RandName()
+	positionsFileName := dirName + "/positions.yml"
+
+	ps, err := positions.New(logger, positions.Config{
+		SyncPeriod:    10 * time.Second,
+		PositionsFile: positionsFileName,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps.PutString("journal-test", "cursor-1")
+
+	client := &testutils.TestClient{
+		Log:      logger,
+		Messages: make([]*testutils.Entry, 0),
+	}
+
+	cfg := scrapeconfig.JournalTargetConfig{
+		FieldLabels: map[model.LabelName]string{"unit": "_SYSTEMD_UNIT"},
+	}
+
+	journalEntry := newMockJournalEntry(&sdjournal.JournalEntry{
+		Cursor:            "cursor-1",
+		RealtimeTimestamp: uint64(time.Now().UnixNano() / int64(time.Microsecond)),
+	})
+
+	jt, err := journalTargetWithReader(logger, client, ps, "test", nil,
+		&cfg, newMockJournalReader, journalEntry)
+	require.NoError(t, err)
+
+	r := jt.r.(*mockJournalReader)
+	require.Equal(t, "cursor-1", r.config.Cursor)
+
+	ts := uint64(time.Now().UnixNano() / int64(time.Microsecond))
+	for _, entry := range []*sdjournal.JournalEntry{
+		// the entry of the saved cursor is read again, and skipped
+		{Cursor: "cursor-1", RealtimeTimestamp: ts, Fields: map[string]string{"MESSAGE": "sent", "_SYSTEMD_UNIT": "foo.service"}},
+		{Cursor: "cursor-2", RealtimeTimestamp: ts, Fields: map[string]string{"MESSAGE": "ping", "_SYSTEMD_UNIT": "foo.service"}},
+		// entries without labels are dropped, their cursor saved
+		{Cursor: "cursor-3", RealtimeTimestamp: ts, Fields: map[string]string{"MESSAGE": "no unit"}},
+	} {
+		_, err := r.config.Formatter(entry)
+		require.NoError(t, err)
+	}
+
+	require.Len(t, client.Messages, 1)
+	require.Equal(t, "ping", client.Messages[0].Log)
+	require.Equal(t, model.LabelSet{"unit": "foo.service"}, client.Messages[0].Labels)
+	require.Equal(t, "cursor-3", ps.GetString("journal-test"))
+	require.NoError(t, jt.Stop())
+}
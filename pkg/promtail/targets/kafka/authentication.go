@@ -0,0 +1,95 @@
+package kafka
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+
+	"github.com/Shopify/sarama"
+	promconfig "github.com/prometheus/common/config"
+	"github.com/xdg/scram"
+
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+)
+
+// configureAuthentication sets up the TLS and SASL settings of the sarama config from the authentication of the target.
+func configureAuthentication(config *sarama.Config, auth scrapeconfig.KafkaAuthentication) error {
+	switch auth.Type {
+	case "", scrapeconfig.KafkaAuthenticationTypeNone:
+		return nil
+	case scrapeconfig.KafkaAuthenticationTypeSSL:
+		return configureTLS(config, auth.TLSConfig)
+	case scrapeconfig.KafkaAuthenticationTypeSASL:
+		return configureSASL(config, auth)
+	default:
+		return fmt.Errorf("unsupported authentication type %q, must be %s, %s or %s", auth.Type,
+			scrapeconfig.KafkaAuthenticationTypeNone, scrapeconfig.KafkaAuthenticationTypeSSL, scrapeconfig.KafkaAuthenticationTypeSASL)
+	}
+}
+
+func configureTLS(config *sarama.Config, cfg promconfig.TLSConfig) error {
+	tlsConfig, err := promconfig.NewTLSConfig(&cfg)
+	if err != nil {
+		return err
+	}
+	config.Net.TLS.Enable = true
+	config.Net.TLS.Config = tlsConfig
+	return nil
+}
+
+func configureSASL(config *sarama.Config, auth scrapeconfig.KafkaAuthentication) error {
+	sasl := auth.SASLConfig
+	config.Net.SASL.Enable = true
+	config.Net.SASL.User = sasl.User
+	config.Net.SASL.Password = string(sasl.Password)
+
+	switch sasl.Mechanism {
+	case "", sarama.SASLTypePlaintext:
+		config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case sarama.SASLTypeSCRAMSHA256:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hash: sha256.New}
+		}
+	case sarama.SASLTypeSCRAMSHA512:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hash: sha512.New}
+		}
+	default:
+		return fmt.Errorf("unsupported SASL mechanism %q, must be %s, %s or %s", sasl.Mechanism,
+			sarama.SASLTypePlaintext, sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512)
+	}
+
+	if sasl.UseTLS {
+		return configureTLS(config, auth.TLSConfig)
+	}
+	return nil
+}
+
+// scramClient implements the SCRAM exchange of the SASL authentication for sarama.
+type scramClient struct {
+	hash         func() hash.Hash
+	conversation *scram.ClientConversation
+}
+
+// Begin implements sarama.SCRAMClient.
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	client, err := scram.HashGeneratorFcn(c.hash).NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.conversation = client.NewConversation()
+	return nil
+}
+
+// Step implements sarama.SCRAMClient.
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.conversation.Step(challenge)
+}
+
+// Done implements sarama.SCRAMClient.
+func (c *scramClient) Done() bool {
+	return c.conversation.Done()
+}
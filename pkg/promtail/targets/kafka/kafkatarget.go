@@ -0,0 +1,347 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/prometheus/prometheus/util/strutil"
+
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+	"github.com/famarks/loki/pkg/promtail/targets/target"
+)
+
+var (
+	kafkaMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "kafka_target_messages_total",
+		Help:      "Total number of messages consumed from Kafka.",
+	}, []string{"job", "topic"})
+	kafkaHandOffFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "kafka_target_hand_off_failures_total",
+		Help:      "Total number of messages of Kafka that failed to be handed off and are consumed again.",
+	}, []string{"job", "topic"})
+)
+
+const (
+	defaultGroupID = "promtail"
+	defaultVersion = "2.1.0"
+
+	// retryBackoff is the time to wait before joining the consumer group again after a failure.
+	retryBackoff = 2 * time.Second
+)
+
+// KafkaTarget consumes the messages of Kafka topics in a consumer group.
+// nolint:golint
+type KafkaTarget struct {
+	logger        log.Logger
+	handler       api.EntryHandler
+	jobName       string
+	relabelConfig []*relabel.Config
+	config        *scrapeconfig.KafkaTargetConfig
+
+	group  sarama.ConsumerGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mtx sync.RWMutex
+	// memberID and claims are the ones of the current session, empty out of a session.
+	memberID string
+	claims   map[string][]int32
+	// cancelSession ends the current session, for the consumption to restart from the last committed offsets.
+	cancelSession context.CancelFunc
+}
+
+// NewKafkaTarget joins the consumer group of the config and starts consuming its topics.
+func NewKafkaTarget(
+	logger log.Logger,
+	handler api.EntryHandler,
+	jobName string,
+	relabelConfig []*relabel.Config,
+	config *scrapeconfig.KafkaTargetConfig,
+) (*KafkaTarget, error) {
+	saramaConfig, err := newSaramaConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kafka config of job %s: %w", jobName, err)
+	}
+	group, err := sarama.NewConsumerGroup(config.Brokers, groupID(config), saramaConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the kafka consumer group of job %s: %w", jobName, err)
+	}
+
+	t := newKafkaTarget(logger, handler, jobName, relabelConfig, config)
+	t.group = group
+	t.wg.Add(2)
+	go t.logErrors()
+	go t.run()
+	return t, nil
+}
+
+func newKafkaTarget(
+	logger log.Logger,
+	handler api.EntryHandler,
+	jobName string,
+	relabelConfig []*relabel.Config,
+	config *scrapeconfig.KafkaTargetConfig,
+) *KafkaTarget {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &KafkaTarget{
+		logger:        log.With(logger, "job", jobName, "group_id", groupID(config)),
+		handler:       handler,
+		jobName:       jobName,
+		relabelConfig: relabelConfig,
+		config:        config,
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+func groupID(config *scrapeconfig.KafkaTargetConfig) string {
+	if config.GroupID == "" {
+		return defaultGroupID
+	}
+	return config.GroupID
+}
+
+func newSaramaConfig(config *scrapeconfig.KafkaTargetConfig) (*sarama.Config, error) {
+	if len(config.Brokers) == 0 {
+		return nil, errors.New("no brokers")
+	}
+	if len(config.Topics) == 0 {
+		return nil, errors.New("no topics")
+	}
+
+	c := sarama.NewConfig()
+	version := config.Version
+	if version == "" {
+		version = defaultVersion
+	}
+	var err error
+	if c.Version, err = sarama.ParseKafkaVersion(version); err != nil {
+		return nil, err
+	}
+
+	switch config.Assignor {
+	case "", sarama.RangeBalanceStrategyName:
+		c.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRange
+	case sarama.RoundRobinBalanceStrategyName:
+		c.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
+	case sarama.StickyBalanceStrategyName:
+		c.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategySticky
+	default:
+		return nil, fmt.Errorf("unsupported assignor %q, must be %s, %s or %s", config.Assignor,
+			sarama.RangeBalanceStrategyName, sarama.RoundRobinBalanceStrategyName, sarama.StickyBalanceStrategyName)
+	}
+
+	// the offsets marked once the messages are handed off are committed every Consumer.Offsets.CommitInterval and
+	// when the session ends
+	c.Consumer.Return.Errors = true
+
+	if err := configureAuthentication(c, config.Authentication); err != nil {
+		return nil, err
+	}
+	return c, c.Validate()
+}
+
+// run consumes the topics in sessions of the consumer group until the target is stopped, a session ending on a
+// rebalance or when a message could not be handed off.
+func (t *KafkaTarget) run() {
+	defer t.wg.Done()
+
+	for {
+		ctx, cancel := context.WithCancel(t.ctx)
+		t.mtx.Lock()
+		t.cancelSession = cancel
+		t.mtx.Unlock()
+
+		err := t.group.Consume(ctx, t.config.Topics, t)
+		failed := ctx.Err() != nil
+		cancel()
+		if t.ctx.Err() != nil {
+			return
+		}
+		if err == nil && !failed {
+			continue
+		}
+		if err != nil {
+			level.Error(t.logger).Log("msg", "error consuming the topics", "err", err)
+		}
+		select {
+		case <-time.After(retryBackoff):
+		case <-t.ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *KafkaTarget) logErrors() {
+	defer t.wg.Done()
+	for err := range t.group.Errors() {
+		level.Warn(t.logger).Log("msg", "error of the consumer group", "err", err)
+	}
+}
+
+// Setup implements sarama.ConsumerGroupHandler.
+func (t *KafkaTarget) Setup(session sarama.ConsumerGroupSession) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.memberID = session.MemberID()
+	t.claims = session.Claims()
+	level.Info(t.logger).Log("msg", "joined the consumer group", "member_id", t.memberID, "claims", fmt.Sprint(t.claims))
+	return nil
+}
+
+// Cleanup implements sarama.ConsumerGroupHandler.
+func (t *KafkaTarget) Cleanup(session sarama.ConsumerGroupSession) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.memberID = ""
+	t.claims = nil
+	return nil
+}
+
+// ConsumeClaim implements sarama.ConsumerGroupHandler. The messages are marked to be committed only once handed off,
+// the session ending on the first failure for the messages to be consumed again from the last committed offset.
+func (t *KafkaTarget) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		kafkaMessages.WithLabelValues(t.jobName, msg.Topic).Inc()
+
+		ls := t.messageLabels(session.MemberID(), msg)
+		if len(ls) == 0 {
+			// dropped by the relabeling
+			session.MarkMessage(msg, "")
+			continue
+		}
+		if err := t.handler.Handle(ls, t.timestamp(msg), string(msg.Value)); err != nil {
+			kafkaHandOffFailures.WithLabelValues(t.jobName, msg.Topic).Inc()
+			level.Error(t.logger).Log("msg", "error handling message, consuming it again", "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset, "err", err)
+			t.restartSession()
+			return nil
+		}
+		session.MarkMessage(msg, "")
+	}
+	return nil
+}
+
+func (t *KafkaTarget) restartSession() {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	if t.cancelSession != nil {
+		t.cancelSession()
+	}
+}
+
+func (t *KafkaTarget) timestamp(msg *sarama.ConsumerMessage) time.Time {
+	if t.config.UseIncomingTimestamp && !msg.Timestamp.IsZero() {
+		return msg.Timestamp
+	}
+	return time.Now()
+}
+
+// messageLabels returns the labels of the message once relabeled, without the internal ones. The labels are nil when
+// the relabeling drops the message.
+func (t *KafkaTarget) messageLabels(memberID string, msg *sarama.ConsumerMessage) model.LabelSet {
+	lb := labels.NewBuilder(nil)
+	for k, v := range t.config.Labels {
+		lb.Set(string(k), string(v))
+	}
+	lb.Set("__kafka_topic", msg.Topic)
+	lb.Set("__kafka_partition", strconv.Itoa(int(msg.Partition)))
+	lb.Set("__kafka_group_id", groupID(t.config))
+	lb.Set("__kafka_member_id", memberID)
+	if len(msg.Key) > 0 {
+		lb.Set("__kafka_message_key", string(msg.Key))
+	}
+	for _, h := range msg.Headers {
+		if h == nil || len(h.Key) == 0 {
+			continue
+		}
+		lb.Set("__kafka_message_header_"+strutil.SanitizeLabelName(string(h.Key)), string(h.Value))
+	}
+
+	processed := relabel.Process(lb.Labels(), t.relabelConfig...)
+	if processed == nil {
+		return nil
+	}
+	ls := make(model.LabelSet, len(processed))
+	for _, l := range processed {
+		if strings.HasPrefix(l.Name, "__") {
+			continue
+		}
+		ls[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+	}
+	return ls
+}
+
+// Type returns KafkaTargetType.
+func (t *KafkaTarget) Type() target.TargetType {
+	return target.KafkaTargetType
+}
+
+// Ready indicates whether or not the target is a member of the consumer group.
+func (t *KafkaTarget) Ready() bool {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	return t.memberID != ""
+}
+
+// DiscoveredLabels returns the set of labels discovered by the KafkaTarget, which
+// is always nil. Implements Target.
+func (t *KafkaTarget) DiscoveredLabels() model.LabelSet {
+	return nil
+}
+
+// Labels returns the set of labels that statically apply to all log entries
+// produced by the KafkaTarget.
+func (t *KafkaTarget) Labels() model.LabelSet {
+	return t.config.Labels
+}
+
+// Details returns target-specific details, the partitions claimed by topic.
+func (t *KafkaTarget) Details() interface{} {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	details := map[string]string{
+		"brokers":   strings.Join(t.config.Brokers, ","),
+		"group_id":  groupID(t.config),
+		"member_id": t.memberID,
+	}
+	topics := make([]string, 0, len(t.claims))
+	for topic := range t.claims {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	for _, topic := range topics {
+		partitions := make([]string, 0, len(t.claims[topic]))
+		for _, p := range t.claims[topic] {
+			partitions = append(partitions, strconv.Itoa(int(p)))
+		}
+		details["topic_"+topic] = strings.Join(partitions, ",")
+	}
+	return details
+}
+
+// Stop leaves the consumer group, committing the offsets of the messages handed off.
+func (t *KafkaTarget) Stop() error {
+	t.cancel()
+	err := t.group.Close()
+	t.wg.Wait()
+	return err
+}
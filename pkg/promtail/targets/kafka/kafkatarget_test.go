@@ -0,0 +1,184 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+)
+
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	marked []int64
+}
+
+func (s *fakeSession) MemberID() string { return "member-1" }
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func newFakeClaim(msgs ...*sarama.ConsumerMessage) *fakeClaim {
+	c := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, len(msgs))}
+	for _, m := range msgs {
+		c.messages <- m
+	}
+	close(c.messages)
+	return c
+}
+
+type handledEntry struct {
+	labels model.LabelSet
+	time   time.Time
+	line   string
+}
+
+func TestKafkaTarget_ConsumeClaim(t *testing.T) {
+	var handled []handledEntry
+	handler := api.EntryHandlerFunc(func(labels model.LabelSet, time time.Time, entry string) error {
+		handled = append(handled, handledEntry{labels, time, entry})
+		return nil
+	})
+	config := &scrapeconfig.KafkaTargetConfig{
+		Brokers:              []string{"localhost:9092"},
+		Topics:               []string{"logs"},
+		Labels:               model.LabelSet{"job": "kafka"},
+		UseIncomingTimestamp: true,
+	}
+	tgt := newKafkaTarget(util.Logger, handler, "kafka", []*relabel.Config{
+		{
+			SourceLabels: model.LabelNames{"__kafka_topic"},
+			Regex:        relabel.MustNewRegexp("(.*)"),
+			Replacement:  "$1",
+			TargetLabel:  "topic",
+			Action:       relabel.Replace,
+		},
+		{
+			SourceLabels: model.LabelNames{"__kafka_message_header_app_name"},
+			Regex:        relabel.MustNewRegexp("(.*)"),
+			Replacement:  "$1",
+			TargetLabel:  "app",
+			Action:       relabel.Replace,
+		},
+		{
+			SourceLabels: model.LabelNames{"__kafka_message_key"},
+			Regex:        relabel.MustNewRegexp("drop"),
+			Action:       relabel.Drop,
+		},
+	}, config)
+
+	ts := time.Unix(1600000000, 0)
+	session := &fakeSession{}
+	claim := newFakeClaim(
+		&sarama.ConsumerMessage{
+			Topic: "logs", Partition: 1, Offset: 10, Timestamp: ts, Key: []byte("key"), Value: []byte("line 1"),
+			Headers: []*sarama.RecordHeader{{Key: []byte("app-name"), Value: []byte("web")}},
+		},
+		&sarama.ConsumerMessage{Topic: "logs", Partition: 1, Offset: 11, Key: []byte("drop"), Value: []byte("dropped")},
+		&sarama.ConsumerMessage{Topic: "logs", Partition: 1, Offset: 12, Timestamp: ts, Value: []byte("line 2")},
+	)
+	require.NoError(t, tgt.ConsumeClaim(session, claim))
+
+	require.Equal(t, []int64{10, 11, 12}, session.marked)
+	require.Equal(t, []handledEntry{
+		{model.LabelSet{"job": "kafka", "topic": "logs", "app": "web"}, ts, "line 1"},
+		{model.LabelSet{"job": "kafka", "topic": "logs"}, ts, "line 2"},
+	}, handled)
+}
+
+func TestKafkaTarget_ConsumeClaimHandOffFailure(t *testing.T) {
+	calls := 0
+	handler := api.EntryHandlerFunc(func(labels model.LabelSet, time time.Time, entry string) error {
+		calls++
+		if entry == "fails" {
+			return errors.New("full")
+		}
+		return nil
+	})
+	tgt := newKafkaTarget(util.Logger, handler, "kafka", nil, &scrapeconfig.KafkaTargetConfig{
+		Labels: model.LabelSet{"job": "kafka"},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tgt.cancelSession = cancel
+
+	session := &fakeSession{}
+	claim := newFakeClaim(
+		&sarama.ConsumerMessage{Topic: "logs", Offset: 1, Value: []byte("ok")},
+		&sarama.ConsumerMessage{Topic: "logs", Offset: 2, Value: []byte("fails")},
+		&sarama.ConsumerMessage{Topic: "logs", Offset: 3, Value: []byte("ok")},
+	)
+	require.NoError(t, tgt.ConsumeClaim(session, claim))
+
+	// the failed message is not committed, the session ending for it to be consumed again
+	require.Equal(t, []int64{1}, session.marked)
+	require.Equal(t, 2, calls)
+	require.Error(t, ctx.Err())
+}
+
+func TestNewSaramaConfig(t *testing.T) {
+	c, err := newSaramaConfig(&scrapeconfig.KafkaTargetConfig{
+		Brokers: []string{"localhost:9092"},
+		Topics:  []string{"logs"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, sarama.V2_1_0_0, c.Version)
+	require.Equal(t, sarama.BalanceStrategyRange, c.Consumer.Group.Rebalance.Strategy)
+	require.False(t, c.Net.TLS.Enable)
+	require.False(t, c.Net.SASL.Enable)
+
+	c, err = newSaramaConfig(&scrapeconfig.KafkaTargetConfig{
+		Brokers:  []string{"localhost:9092"},
+		Topics:   []string{"logs"},
+		Version:  "1.1.0",
+		Assignor: "sticky",
+		Authentication: scrapeconfig.KafkaAuthentication{
+			Type: scrapeconfig.KafkaAuthenticationTypeSASL,
+			SASLConfig: scrapeconfig.KafkaSASLConfig{
+				Mechanism: sarama.SASLTypeSCRAMSHA512,
+				User:      "user",
+				Password:  "pass",
+				UseTLS:    true,
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, sarama.V1_1_0_0, c.Version)
+	require.Equal(t, sarama.BalanceStrategySticky, c.Consumer.Group.Rebalance.Strategy)
+	require.True(t, c.Net.TLS.Enable)
+	require.True(t, c.Net.SASL.Enable)
+	require.Equal(t, sarama.SASLMechanism(sarama.SASLTypeSCRAMSHA512), c.Net.SASL.Mechanism)
+	require.NoError(t, c.Net.SASL.SCRAMClientGeneratorFunc().Begin("user", "pass", ""))
+
+	for _, cfg := range []scrapeconfig.KafkaTargetConfig{
+		{Topics: []string{"logs"}},
+		{Brokers: []string{"localhost:9092"}},
+		{Brokers: []string{"localhost:9092"}, Topics: []string{"logs"}, Version: "x"},
+		{Brokers: []string{"localhost:9092"}, Topics: []string{"logs"}, Assignor: "x"},
+		{Brokers: []string{"localhost:9092"}, Topics: []string{"logs"}, Authentication: scrapeconfig.KafkaAuthentication{Type: "x"}},
+		{Brokers: []string{"localhost:9092"}, Topics: []string{"logs"}, Authentication: scrapeconfig.KafkaAuthentication{
+			Type:       scrapeconfig.KafkaAuthenticationTypeSASL,
+			SASLConfig: scrapeconfig.KafkaSASLConfig{Mechanism: "x"},
+		}},
+	} {
+		cfg := cfg
+		_, err := newSaramaConfig(&cfg)
+		require.Error(t, err)
+	}
+}
@@ -0,0 +1,85 @@
+package kafka
+
+import (
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/famarks/loki/pkg/logentry/stages"
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+	"github.com/famarks/loki/pkg/promtail/targets/target"
+)
+
+// KafkaTargetManager manages a series of KafkaTargets.
+// nolint:golint
+type KafkaTargetManager struct {
+	logger  log.Logger
+	targets map[string]*KafkaTarget
+}
+
+// NewKafkaTargetManager creates a new KafkaTargetManager.
+func NewKafkaTargetManager(
+	logger log.Logger,
+	client api.EntryHandler,
+	scrapeConfigs []scrapeconfig.Config,
+) (*KafkaTargetManager, error) {
+
+	tm := &KafkaTargetManager{
+		logger:  logger,
+		targets: make(map[string]*KafkaTarget),
+	}
+
+	for _, cfg := range scrapeConfigs {
+		registerer := prometheus.DefaultRegisterer
+		pipeline, err := stages.NewPipeline(log.With(logger, "component", "kafka_pipeline"), cfg.PipelineStages, &cfg.JobName, registerer)
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := NewKafkaTarget(logger, pipeline.Wrap(client), cfg.JobName, cfg.RelabelConfigs, cfg.KafkaConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		tm.targets[cfg.JobName] = t
+	}
+
+	return tm, nil
+}
+
+// Ready returns true if at least one KafkaTarget is also ready.
+func (tm *KafkaTargetManager) Ready() bool {
+	for _, t := range tm.targets {
+		if t.Ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// Stop stops the KafkaTargetManager and all of its KafkaTargets.
+func (tm *KafkaTargetManager) Stop() {
+	for _, t := range tm.targets {
+		if err := t.Stop(); err != nil {
+			level.Error(t.logger).Log("msg", "error stopping KafkaTarget", "err", err.Error())
+		}
+	}
+}
+
+// ActiveTargets returns the list of KafkaTargets where Kafka messages
+// are being consumed. ActiveTargets is an alias to AllTargets as
+// KafkaTargets cannot be deactivated, only stopped.
+func (tm *KafkaTargetManager) ActiveTargets() map[string][]target.Target {
+	return tm.AllTargets()
+}
+
+// AllTargets returns the list of all targets where Kafka messages
+// are currently being consumed.
+func (tm *KafkaTargetManager) AllTargets() map[string][]target.Target {
+	result := make(map[string][]target.Target, len(tm.targets))
+	for k, v := range tm.targets {
+		result[k] = []target.Target{v}
+	}
+	return result
+}
@@ -14,12 +14,15 @@ import (
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/pkg/relabel"
 	"github.com/weaveworks/common/server"
+	"github.com/weaveworks/common/user"
 
 	"github.com/famarks/loki/pkg/distributor"
 	"github.com/famarks/loki/pkg/logql"
 	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/client"
 	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
 	"github.com/famarks/loki/pkg/promtail/targets/target"
+	util_server "github.com/famarks/loki/pkg/util/server"
 )
 
 type PushTarget struct {
@@ -82,6 +85,14 @@ func (t *PushTarget) run() error {
 
 	util.InitLogger(&t.config.Server)
 
+	basicAuth, err := util_server.NewBasicAuth(t.config.BasicAuth)
+	if err != nil {
+		return err
+	}
+	if basicAuth != nil {
+		basicAuth.AddToServerConfig(&t.config.Server)
+	}
+
 	srv, err := server.New(t.config.Server)
 	if err != nil {
 		return err
@@ -101,12 +112,14 @@ func (t *PushTarget) run() error {
 }
 
 func (t *PushTarget) handle(w http.ResponseWriter, r *http.Request) {
-	req, err := distributor.ParseRequest(r)
+	req, err := distributor.ParseRequest(r, distributor.DefaultMaxPushBodySize)
 	if err != nil {
 		level.Warn(t.logger).Log("msg", "failed to parse incoming push request", "err", err.Error())
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	// The tenant of the request is kept, for the entries to be forwarded to the same tenant when relaying.
+	tenantID := r.Header.Get(user.OrgIDHeaderName)
 	var lastErr error
 	for _, stream := range req.Streams {
 		matchers, err := logql.ParseMatchers(stream.Labels)
@@ -129,9 +142,9 @@ func (t *PushTarget) handle(w http.ResponseWriter, r *http.Request) {
 
 		// Apply relabeling
 		processed := relabel.Process(lb.Labels(), t.relabelConfig...)
-		if processed == nil || len(processed) == 0 {
-			w.WriteHeader(http.StatusNoContent)
-			return
+		if len(processed) == 0 {
+			// The stream is dropped, the other streams of the request are still processed.
+			continue
 		}
 
 		// Convert to model.LabelSet
@@ -142,6 +155,9 @@ func (t *PushTarget) handle(w http.ResponseWriter, r *http.Request) {
 			}
 			filtered[model.LabelName(processed[i].Name)] = model.LabelValue(processed[i].Value)
 		}
+		if tenantID != "" {
+			filtered[client.ReservedLabelTenantID] = model.LabelValue(tenantID)
+		}
 
 		for _, entry := range stream.Entries {
 			var err error
@@ -3,8 +3,10 @@ package lokipush
 import (
 	"flag"
 	"net"
+	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,6 +20,7 @@ import (
 	"github.com/famarks/loki/pkg/promtail/client"
 	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
 	"github.com/famarks/loki/pkg/promtail/targets/testutils"
+	util_server "github.com/famarks/loki/pkg/util/server"
 )
 
 func TestPushTarget(t *testing.T) {
@@ -114,3 +117,137 @@ func TestPushTarget(t *testing.T) {
 	_ = pt.Stop()
 
 }
+
+func TestPushTarget_Relay(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+
+	eh := &testutils.TestClient{
+		Log:      logger,
+		Messages: make([]*testutils.Entry, 0),
+	}
+
+	// Get a randomly available port by open and closing a TCP socket
+	addr, err := net.ResolveTCPAddr("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l, err := net.ListenTCP("tcp", addr)
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	defaults := server.Config{}
+	defaults.RegisterFlags(flag.NewFlagSet("empty", flag.ContinueOnError))
+	defaults.HTTPListenAddress = "127.0.0.1"
+	defaults.HTTPListenPort = port
+	defaults.GRPCListenAddress = "127.0.0.1"
+	defaults.GRPCListenPort = 0
+
+	config := &scrapeconfig.PushTargetConfig{
+		Server: defaults,
+		Labels: model.LabelSet{"pushserver": "pushserver1"},
+	}
+	rlbl := []*relabel.Config{
+		{
+			SourceLabels: model.LabelNames{"stream"},
+			Regex:        relabel.MustNewRegexp("dropped"),
+			Action:       relabel.Drop,
+		},
+	}
+	pt, err := NewPushTarget(logger, eh, rlbl, "relay", config)
+	require.NoError(t, err)
+	defer func() { _ = pt.Stop() }()
+
+	serverURL := flagext.URLValue{}
+	err = serverURL.Set("http://127.0.0.1:" + strconv.Itoa(port) + "/loki/api/v1/push")
+	require.NoError(t, err)
+	pc, err := client.New(client.Config{
+		URL:       serverURL,
+		Timeout:   1 * time.Second,
+		BatchWait: 1 * time.Second,
+		BatchSize: 100 * 1024,
+		TenantID:  "tenant1",
+	}, logger)
+	require.NoError(t, err)
+	defer pc.Stop()
+
+	// The entries of the stream dropped are sent in the same request as the ones kept
+	require.NoError(t, pc.Handle(model.LabelSet{"stream": "dropped"}, time.Unix(1, 0), "dropped"))
+	require.NoError(t, pc.Handle(model.LabelSet{"stream": "kept"}, time.Unix(2, 0), "kept"))
+
+	countdown := 10000
+	for len(eh.Messages) != 1 && countdown > 0 {
+		time.Sleep(1 * time.Millisecond)
+		countdown--
+	}
+	require.Equal(t, 1, len(eh.Messages))
+
+	// The tenant of the request is kept for the entries to be forwarded to it
+	require.Equal(t, model.LabelSet{
+		"pushserver":                 "pushserver1",
+		"stream":                     "kept",
+		client.ReservedLabelTenantID: "tenant1",
+	}, eh.Messages[0].Labels)
+	require.Equal(t, "kept", eh.Messages[0].Log)
+}
+
+func TestPushTarget_BasicAuth(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+
+	eh := &testutils.TestClient{
+		Log:      logger,
+		Messages: make([]*testutils.Entry, 0),
+	}
+
+	// Get a randomly available port by open and closing a TCP socket
+	addr, err := net.ResolveTCPAddr("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l, err := net.ListenTCP("tcp", addr)
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	defaults := server.Config{}
+	defaults.RegisterFlags(flag.NewFlagSet("empty", flag.ContinueOnError))
+	defaults.HTTPListenAddress = "127.0.0.1"
+	defaults.HTTPListenPort = port
+	defaults.GRPCListenAddress = "127.0.0.1"
+	defaults.GRPCListenPort = 0
+
+	config := &scrapeconfig.PushTargetConfig{
+		Server: defaults,
+		Labels: model.LabelSet{"pushserver": "pushserver1"},
+		BasicAuth: util_server.BasicAuthConfig{
+			Username: "promtail",
+			Password: flagext.Secret{Value: "secret"},
+		},
+	}
+	pt, err := NewPushTarget(logger, eh, nil, "auth", config)
+	require.NoError(t, err)
+	defer func() { _ = pt.Stop() }()
+
+	body := `{"streams":[{"stream":{"stream":"stream1"},"values":[["1","line"]]}]}`
+	for _, tc := range []struct {
+		username, password string
+		expected           int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"promtail", "wrong", http.StatusUnauthorized},
+		{"promtail", "secret", http.StatusNoContent},
+	} {
+		req, err := http.NewRequest("POST", "http://127.0.0.1:"+strconv.Itoa(port)+"/loki/api/v1/push", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if tc.username != "" {
+			req.SetBasicAuth(tc.username, tc.password)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		require.Equal(t, tc.expected, resp.StatusCode)
+	}
+
+	// Only the entry pushed with the credentials is received
+	require.Equal(t, 1, len(eh.Messages))
+	require.Equal(t, "line", eh.Messages[0].Log)
+}
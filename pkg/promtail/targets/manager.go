@@ -8,19 +8,33 @@ import (
 	"github.com/famarks/loki/pkg/promtail/api"
 	"github.com/famarks/loki/pkg/promtail/positions"
 	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+	"github.com/famarks/loki/pkg/promtail/targets/azureeventhubs"
+	"github.com/famarks/loki/pkg/promtail/targets/cloudflare"
+	"github.com/famarks/loki/pkg/promtail/targets/docker"
 	"github.com/famarks/loki/pkg/promtail/targets/file"
+	"github.com/famarks/loki/pkg/promtail/targets/gcplog"
+	"github.com/famarks/loki/pkg/promtail/targets/heroku"
 	"github.com/famarks/loki/pkg/promtail/targets/journal"
+	"github.com/famarks/loki/pkg/promtail/targets/kafka"
 	"github.com/famarks/loki/pkg/promtail/targets/lokipush"
 	"github.com/famarks/loki/pkg/promtail/targets/stdin"
 	"github.com/famarks/loki/pkg/promtail/targets/syslog"
 	"github.com/famarks/loki/pkg/promtail/targets/target"
+	"github.com/famarks/loki/pkg/promtail/targets/windows"
 )
 
 const (
-	FileScrapeConfigs    = "fileScrapeConfigs"
-	JournalScrapeConfigs = "journalScrapeConfigs"
-	SyslogScrapeConfigs  = "syslogScrapeConfigs"
-	PushScrapeConfigs    = "pushScrapeConfigs"
+	FileScrapeConfigs           = "fileScrapeConfigs"
+	JournalScrapeConfigs        = "journalScrapeConfigs"
+	SyslogScrapeConfigs         = "syslogScrapeConfigs"
+	PushScrapeConfigs           = "pushScrapeConfigs"
+	WindowsScrapeConfigs        = "windowsScrapeConfigs"
+	KafkaScrapeConfigs          = "kafkaScrapeConfigs"
+	GcplogScrapeConfigs         = "gcplogScrapeConfigs"
+	DockerScrapeConfigs         = "dockerScrapeConfigs"
+	HerokuScrapeConfigs         = "herokuScrapeConfigs"
+	CloudflareScrapeConfigs     = "cloudflareScrapeConfigs"
+	AzureEventHubsScrapeConfigs = "azureEventHubsScrapeConfigs"
 )
 
 type targetManager interface {
@@ -73,6 +87,20 @@ func NewTargetManagers(
 			targetScrapeConfigs[SyslogScrapeConfigs] = append(targetScrapeConfigs[SyslogScrapeConfigs], cfg)
 		case cfg.PushConfig != nil:
 			targetScrapeConfigs[PushScrapeConfigs] = append(targetScrapeConfigs[PushScrapeConfigs], cfg)
+		case cfg.WindowsConfig != nil:
+			targetScrapeConfigs[WindowsScrapeConfigs] = append(targetScrapeConfigs[WindowsScrapeConfigs], cfg)
+		case cfg.KafkaConfig != nil:
+			targetScrapeConfigs[KafkaScrapeConfigs] = append(targetScrapeConfigs[KafkaScrapeConfigs], cfg)
+		case cfg.GcplogConfig != nil:
+			targetScrapeConfigs[GcplogScrapeConfigs] = append(targetScrapeConfigs[GcplogScrapeConfigs], cfg)
+		case len(cfg.DockerSDConfigs) > 0:
+			targetScrapeConfigs[DockerScrapeConfigs] = append(targetScrapeConfigs[DockerScrapeConfigs], cfg)
+		case cfg.HerokuDrainConfig != nil:
+			targetScrapeConfigs[HerokuScrapeConfigs] = append(targetScrapeConfigs[HerokuScrapeConfigs], cfg)
+		case cfg.CloudflareConfig != nil:
+			targetScrapeConfigs[CloudflareScrapeConfigs] = append(targetScrapeConfigs[CloudflareScrapeConfigs], cfg)
+		case cfg.AzureEventHubsConfig != nil:
+			targetScrapeConfigs[AzureEventHubsScrapeConfigs] = append(targetScrapeConfigs[AzureEventHubsScrapeConfigs], cfg)
 		default:
 			return nil, errors.New("unknown scrape config")
 		}
@@ -123,6 +151,79 @@ func NewTargetManagers(
 				return nil, errors.Wrap(err, "failed to make Loki Push API target manager")
 			}
 			targetManagers = append(targetManagers, pushTargetManager)
+		case WindowsScrapeConfigs:
+			windowsTargetManager, err := windows.NewWindowsTargetManager(
+				logger,
+				positions,
+				client,
+				scrapeConfigs,
+			)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to make windows target manager")
+			}
+			targetManagers = append(targetManagers, windowsTargetManager)
+		case KafkaScrapeConfigs:
+			kafkaTargetManager, err := kafka.NewKafkaTargetManager(
+				logger,
+				client,
+				scrapeConfigs,
+			)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to make kafka target manager")
+			}
+			targetManagers = append(targetManagers, kafkaTargetManager)
+		case GcplogScrapeConfigs:
+			gcplogTargetManager, err := gcplog.NewGcplogTargetManager(
+				logger,
+				client,
+				scrapeConfigs,
+			)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to make gcplog target manager")
+			}
+			targetManagers = append(targetManagers, gcplogTargetManager)
+		case DockerScrapeConfigs:
+			dockerTargetManager, err := docker.NewDockerTargetManager(
+				logger,
+				positions,
+				client,
+				scrapeConfigs,
+			)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to make docker target manager")
+			}
+			targetManagers = append(targetManagers, dockerTargetManager)
+		case HerokuScrapeConfigs:
+			herokuTargetManager, err := heroku.NewHerokuTargetManager(
+				logger,
+				client,
+				scrapeConfigs,
+			)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to make Heroku drain target manager")
+			}
+			targetManagers = append(targetManagers, herokuTargetManager)
+		case CloudflareScrapeConfigs:
+			cloudflareTargetManager, err := cloudflare.NewCloudflareTargetManager(
+				logger,
+				positions,
+				client,
+				scrapeConfigs,
+			)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to make cloudflare target manager")
+			}
+			targetManagers = append(targetManagers, cloudflareTargetManager)
+		case AzureEventHubsScrapeConfigs:
+			azureEventHubsTargetManager, err := azureeventhubs.NewAzureEventHubsTargetManager(
+				logger,
+				client,
+				scrapeConfigs,
+			)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to make azure event hubs target manager")
+			}
+			targetManagers = append(targetManagers, azureEventHubsTargetManager)
 		default:
 			return nil, errors.New("unknown scrape config")
 		}
@@ -129,6 +129,7 @@ func newReaderTarget(logger log.Logger, in io.Reader, client api.EntryHandler, c
 
 func (t *readerTarget) read() {
 	defer t.cancel()
+	defer t.flush()
 
 	for {
 		if t.ctx.Err() != nil {
@@ -154,3 +155,13 @@ func (t *readerTarget) read() {
 		}
 	}
 }
+
+// flush sends the entries held back by the pipeline, like the last lines joined by a multiline stage, as no more lines
+// are coming.
+func (t *readerTarget) flush() {
+	if flusher, ok := t.out.(api.EntryFlusher); ok {
+		if err := flusher.Flush(); err != nil {
+			level.Error(t.logger).Log("msg", "error sending the lines held back by the pipeline", "err", err)
+		}
+	}
+}
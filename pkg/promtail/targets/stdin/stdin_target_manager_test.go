@@ -77,6 +77,18 @@ func Test_newReaderTarget(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"multiline pipeline",
+			bytes.NewReader([]byte("[1] foo\n  bar\n[2] baz\n  qux")),
+			scrapeconfig.Config{
+				PipelineStages: loadConfig(multilineConfig),
+			},
+			[]line{
+				{model.LabelSet{}, "[1] foo\n  bar"},
+				{model.LabelSet{}, "[2] baz\n  qux"},
+			},
+			false,
+		},
 		{
 			"default config",
 			bytes.NewReader([]byte("\nfoo\r\nbar")),
@@ -158,6 +170,13 @@ pipeline_stages:
     new_key:
 `
 
+var multilineConfig = `
+pipeline_stages:
+- multiline:
+    firstline: '^\['
+    max_wait_time: 1h
+`
+
 func loadConfig(yml string) stages.PipelineStages {
 	var config map[string]interface{}
 	err := yaml.Unmarshal([]byte(yml), &config)
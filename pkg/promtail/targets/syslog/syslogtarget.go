@@ -1,9 +1,13 @@
 package syslog
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"strings"
 	"sync"
@@ -17,6 +21,7 @@ import (
 	"github.com/mwitkow/go-conntrack"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	promconfig "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/pkg/relabel"
@@ -47,6 +52,14 @@ var (
 	defaultIdleTimeout = 120 * time.Second
 )
 
+const (
+	protocolTCP = "tcp"
+	protocolUDP = "udp"
+
+	// maxDatagramSize is the largest UDP payload, a syslog message being sent by datagram.
+	maxDatagramSize = 65535
+)
+
 // SyslogTarget listens to syslog messages.
 // nolint:golint
 type SyslogTarget struct {
@@ -55,8 +68,9 @@ type SyslogTarget struct {
 	config        *scrapeconfig.SyslogTargetConfig
 	relabelConfig []*relabel.Config
 
-	listener net.Listener
-	messages chan message
+	listener   net.Listener
+	packetConn net.PacketConn
+	messages   chan message
 
 	ctx             context.Context
 	ctxCancel       context.CancelFunc
@@ -64,8 +78,9 @@ type SyslogTarget struct {
 }
 
 type message struct {
-	labels  model.LabelSet
-	message string
+	labels    model.LabelSet
+	message   string
+	timestamp time.Time
 }
 
 // NewSyslogTarget configures a new SyslogTarget.
@@ -97,13 +112,32 @@ func NewSyslogTarget(
 }
 
 func (t *SyslogTarget) run() error {
-	l, err := net.Listen("tcp", t.config.ListenAddress)
-	l = conntrack.NewListener(l, conntrack.TrackWithName("syslog_target/"+t.config.ListenAddress))
+	switch protocol := t.protocol(); protocol {
+	case protocolTCP:
+		return t.runTCP()
+	case protocolUDP:
+		return t.runUDP()
+	default:
+		return fmt.Errorf("error setting up syslog target: unsupported protocol %q, must be %s or %s", protocol, protocolTCP, protocolUDP)
+	}
+}
+
+func (t *SyslogTarget) runTCP() error {
+	tlsConfig, err := newTLSConfig(t.config.TLSConfig)
+	if err != nil {
+		return fmt.Errorf("error setting up syslog target %w", err)
+	}
+
+	l, err := net.Listen(protocolTCP, t.config.ListenAddress)
 	if err != nil {
 		return fmt.Errorf("error setting up syslog target %w", err)
 	}
+	l = conntrack.NewListener(l, conntrack.TrackWithName("syslog_target/"+t.config.ListenAddress))
+	if tlsConfig != nil {
+		l = tls.NewListener(l, tlsConfig)
+	}
 	t.listener = l
-	level.Info(t.logger).Log("msg", "syslog listening on address", "address", t.ListenAddress().String())
+	level.Info(t.logger).Log("msg", "syslog listening on address", "address", t.ListenAddress().String(), "protocol", protocolTCP, "tls", tlsConfig != nil)
 
 	t.openConnections.Add(1)
 	go t.acceptConnections()
@@ -111,6 +145,80 @@ func (t *SyslogTarget) run() error {
 	return nil
 }
 
+func (t *SyslogTarget) runUDP() error {
+	c, err := net.ListenPacket(protocolUDP, t.config.ListenAddress)
+	if err != nil {
+		return fmt.Errorf("error setting up syslog target %w", err)
+	}
+	t.packetConn = c
+	level.Info(t.logger).Log("msg", "syslog listening on address", "address", t.ListenAddress().String(), "protocol", protocolUDP)
+
+	t.openConnections.Add(1)
+	go t.readDatagrams()
+
+	return nil
+}
+
+// newTLSConfig returns the server TLS config of the tcp listener, nil when no certificate is set.
+func newTLSConfig(cfg promconfig.TLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" && cfg.KeyFile == "" {
+		if cfg.CAFile != "" {
+			return nil, errors.New("the TLS CA requires a certificate and a key")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the TLS certificate and key: %w", err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	if cfg.CAFile != "" {
+		ca, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the TLS CA %s: %w", cfg.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("unable to use the TLS CA %s", cfg.CAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// readDatagrams reads the syslog messages sent over UDP, one message by datagram without framing.
+func (t *SyslogTarget) readDatagrams() {
+	defer t.openConnections.Done()
+
+	parser := rfc5424.NewParser()
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := t.packetConn.ReadFrom(buf)
+		if err != nil {
+			if t.ctx.Err() != nil {
+				level.Info(t.logger).Log("msg", "syslog server shutting down")
+				return
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				level.Warn(t.logger).Log("msg", "failed to read syslog datagram", "err", err)
+				continue
+			}
+			level.Error(t.logger).Log("msg", "failed to read syslog datagram. quiting", "err", err)
+			return
+		}
+
+		// the senders ending the datagrams with a newline frame them like over tcp
+		msg, err := parser.Parse(bytes.TrimRight(buf[:n], "\n"))
+		if err != nil {
+			t.handleMessageError(err)
+			continue
+		}
+		t.handleMessage(t.connectionLabels(ipFromAddr(addr)), msg)
+	}
+}
+
 func (t *SyslogTarget) acceptConnections() {
 	defer t.openConnections.Done()
 
@@ -158,7 +266,7 @@ func (t *SyslogTarget) handleConnection(cn net.Conn) {
 		_ = c.Close()
 	}()
 
-	connLabels := t.connectionLabels(c)
+	connLabels := t.connectionLabels(ipFromAddr(c.RemoteAddr()))
 
 	err := syslogparser.ParseStream(c, func(msg *syslog.Result) {
 		if err := msg.Error; err != nil {
@@ -231,35 +339,42 @@ func (t *SyslogTarget) handleMessage(connLabels labels.Labels, msg syslog.Messag
 		filtered[model.LabelName(lbl.Name)] = model.LabelValue(lbl.Value)
 	}
 
-	t.messages <- message{filtered, *rfc5424Msg.Message}
+	timestamp := time.Now()
+	if t.config.UseIncomingTimestamp && rfc5424Msg.Timestamp != nil {
+		timestamp = *rfc5424Msg.Timestamp
+	}
+
+	t.messages <- message{filtered, *rfc5424Msg.Message, timestamp}
 }
 
 func (t *SyslogTarget) messageSender() {
 	for msg := range t.messages {
-		if err := t.handler.Handle(msg.labels, time.Now(), msg.message); err != nil {
+		if err := t.handler.Handle(msg.labels, msg.timestamp, msg.message); err != nil {
 			level.Error(t.logger).Log("msg", "error handling line", "error", err)
 		}
 		syslogEntries.Inc()
 	}
 }
 
-func (t *SyslogTarget) connectionLabels(c net.Conn) labels.Labels {
+func (t *SyslogTarget) connectionLabels(remoteIP net.IP) labels.Labels {
 	lb := labels.NewBuilder(nil)
 	for k, v := range t.config.Labels {
 		lb.Set(string(k), string(v))
 	}
 
-	ip := ipFromConn(c).String()
+	ip := remoteIP.String()
 	lb.Set("__syslog_connection_ip_address", ip)
 	lb.Set("__syslog_connection_hostname", lookupAddr(ip))
 
 	return lb.Labels()
 }
 
-func ipFromConn(c net.Conn) net.IP {
-	switch addr := c.RemoteAddr().(type) {
+func ipFromAddr(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
 	case *net.TCPAddr:
 		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
 	}
 
 	return nil
@@ -300,7 +415,12 @@ func (t *SyslogTarget) Details() interface{} {
 // Stop shuts down the SyslogTarget.
 func (t *SyslogTarget) Stop() error {
 	t.ctxCancel()
-	err := t.listener.Close()
+	var err error
+	if t.listener != nil {
+		err = t.listener.Close()
+	} else {
+		err = t.packetConn.Close()
+	}
 	t.openConnections.Wait()
 	close(t.messages)
 	return err
@@ -308,7 +428,17 @@ func (t *SyslogTarget) Stop() error {
 
 // ListenAddress returns the address SyslogTarget is listening on.
 func (t *SyslogTarget) ListenAddress() net.Addr {
-	return t.listener.Addr()
+	if t.listener != nil {
+		return t.listener.Addr()
+	}
+	return t.packetConn.LocalAddr()
+}
+
+func (t *SyslogTarget) protocol() string {
+	if t.config.ListenProtocol == "" {
+		return protocolTCP
+	}
+	return t.config.ListenProtocol
 }
 
 func (t *SyslogTarget) idleTimeout() time.Duration {
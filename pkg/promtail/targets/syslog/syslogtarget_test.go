@@ -1,10 +1,19 @@
 package syslog
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math/big"
 	"net"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -12,6 +21,7 @@ import (
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	promconfig "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/pkg/relabel"
 	"github.com/stretchr/testify/require"
@@ -251,3 +261,106 @@ func TestSyslogTarget_IdleTimeout(t *testing.T) {
 	_, err = c.Read(buf)
 	require.EqualError(t, err, "EOF")
 }
+
+func TestSyslogTarget_UDP(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	client := &TestLabeledClient{log: logger}
+
+	tgt, err := NewSyslogTarget(logger, client, relabelConfig(t), &scrapeconfig.SyslogTargetConfig{
+		ListenAddress:        "127.0.0.1:0",
+		ListenProtocol:       "udp",
+		UseIncomingTimestamp: true,
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, tgt.Stop())
+	}()
+
+	c, err := net.Dial("udp", tgt.ListenAddress().String())
+	require.NoError(t, err)
+	defer c.Close()
+
+	// one message by datagram, with or without a trailing newline
+	_, err = fmt.Fprint(c, `<165>1 2018-10-11T22:14:15.003Z host5 e - id1 - First`)
+	require.NoError(t, err)
+	_, err = fmt.Fprint(c, "<165>1 2018-10-11T22:14:15.005Z host5 e - id2 - Second\n")
+	require.NoError(t, err)
+
+	require.Eventuallyf(t, func() bool {
+		return len(client.Messages()) == 2
+	}, time.Second, time.Millisecond, "Expected to receive 2 messages, got %d.", len(client.Messages()))
+
+	require.Equal(t, "First", client.Messages()[0].Message)
+	require.Equal(t, model.LabelValue("host5"), client.Messages()[0].Labels["hostname"])
+	require.Equal(t, time.Date(2018, 10, 11, 22, 14, 15, 3000000, time.UTC), client.Messages()[0].Timestamp.UTC())
+	require.Equal(t, "Second", client.Messages()[1].Message)
+}
+
+func TestSyslogTarget_TLS(t *testing.T) {
+	w := log.NewSyncWriter(os.Stderr)
+	logger := log.NewLogfmtLogger(w)
+	client := &TestLabeledClient{log: logger}
+
+	tempDir, err := ioutil.TempDir("", "syslog")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	certFile, keyFile := writeSelfSignedCert(t, tempDir)
+
+	tgt, err := NewSyslogTarget(logger, client, relabelConfig(t), &scrapeconfig.SyslogTargetConfig{
+		ListenAddress: "127.0.0.1:0",
+		TLSConfig:     promconfig.TLSConfig{CertFile: certFile, KeyFile: keyFile},
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, tgt.Stop())
+	}()
+
+	c, err := tls.Dial("tcp", tgt.ListenAddress().String(), &tls.Config{InsecureSkipVerify: true})
+	require.NoError(t, err)
+
+	err = writeMessagesToStream(c, []string{"<165>1 - - - - - - Encrypted"}, true)
+	require.NoError(t, err)
+	require.NoError(t, c.Close())
+
+	require.Eventuallyf(t, func() bool {
+		return len(client.Messages()) == 1
+	}, time.Second, time.Millisecond, "Expected to receive 1 message, got %d.", len(client.Messages()))
+	require.Equal(t, "Encrypted", client.Messages()[0].Message)
+}
+
+func TestSyslogTarget_InvalidConfig(t *testing.T) {
+	logger := log.NewNopLogger()
+	client := &TestLabeledClient{log: logger}
+
+	_, err := NewSyslogTarget(logger, client, nil, &scrapeconfig.SyslogTargetConfig{
+		ListenAddress:  "127.0.0.1:0",
+		ListenProtocol: "sctp",
+	})
+	require.Error(t, err)
+
+	_, err = NewSyslogTarget(logger, client, nil, &scrapeconfig.SyslogTargetConfig{
+		ListenAddress: "127.0.0.1:0",
+		TLSConfig:     promconfig.TLSConfig{CAFile: "ca.pem"},
+	})
+	require.Error(t, err)
+}
+
+func writeSelfSignedCert(t *testing.T, dir string) (string, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600))
+	return certFile, keyFile
+}
@@ -23,6 +23,27 @@ const (
 
 	// PushTargetType is a Loki push target
 	PushTargetType = TargetType("Push")
+
+	// WindowsTargetType is a Windows Event Log target
+	WindowsTargetType = TargetType("WindowsEventLog")
+
+	// KafkaTargetType is a Kafka target
+	KafkaTargetType = TargetType("Kafka")
+
+	// GcplogTargetType is a Google Cloud Logging target
+	GcplogTargetType = TargetType("Gcplog")
+
+	// DockerTargetType is a Docker container target
+	DockerTargetType = TargetType("Docker")
+
+	// HerokuDrainTargetType is a Heroku Logplex drain target
+	HerokuDrainTargetType = TargetType("HerokuDrain")
+
+	// CloudflareTargetType is a Cloudflare Logpull target
+	CloudflareTargetType = TargetType("Cloudflare")
+
+	// AzureEventHubsTargetType is an Azure Event Hubs target
+	AzureEventHubsTargetType = TargetType("AzureEventHubs")
 )
 
 // Target is a promtail scrape target
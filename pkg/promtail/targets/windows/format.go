@@ -0,0 +1,180 @@
+package windows
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/relabel"
+
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+)
+
+// Event is an event of the Windows Event Log, as rendered to XML by the EvtRender function.
+type Event struct {
+	Provider struct {
+		Name string `xml:"Name,attr"`
+	} `xml:"System>Provider"`
+	EventID     int    `xml:"System>EventID"`
+	Version     int    `xml:"System>Version"`
+	Level       int    `xml:"System>Level"`
+	Task        int    `xml:"System>Task"`
+	Opcode      int    `xml:"System>Opcode"`
+	Keywords    string `xml:"System>Keywords"`
+	TimeCreated struct {
+		SystemTime string `xml:"SystemTime,attr"`
+	} `xml:"System>TimeCreated"`
+	EventRecordID uint64 `xml:"System>EventRecordID"`
+	Execution     struct {
+		ProcessID uint32 `xml:"ProcessID,attr"`
+		ThreadID  uint32 `xml:"ThreadID,attr"`
+	} `xml:"System>Execution"`
+	Channel  string `xml:"System>Channel"`
+	Computer string `xml:"System>Computer"`
+	Security struct {
+		UserID string `xml:"UserID,attr"`
+	} `xml:"System>Security"`
+	EventData []struct {
+		Name  string `xml:"Name,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"EventData>Data"`
+	UserData struct {
+		InnerXML string `xml:",innerxml"`
+	} `xml:"UserData"`
+
+	// Message is the message of the event formatted by its provider, empty when the provider has none.
+	Message string `xml:"-"`
+}
+
+// line is the log line an event is rendered to.
+type line struct {
+	Source        string            `json:"source"`
+	Channel       string            `json:"channel"`
+	Computer      string            `json:"computer"`
+	EventID       int               `json:"event_id"`
+	Version       int               `json:"version,omitempty"`
+	Level         int               `json:"level"`
+	LevelText     string            `json:"levelText"`
+	Task          int               `json:"task,omitempty"`
+	Opcode        int               `json:"opCode,omitempty"`
+	Keywords      string            `json:"keywords,omitempty"`
+	TimeCreated   string            `json:"timeCreated"`
+	EventRecordID uint64            `json:"eventRecordID"`
+	ProcessID     uint32            `json:"processID,omitempty"`
+	ThreadID      uint32            `json:"threadID,omitempty"`
+	UserID        string            `json:"userID,omitempty"`
+	EventData     map[string]string `json:"event_data,omitempty"`
+	UserData      string            `json:"user_data,omitempty"`
+	Message       string            `json:"message,omitempty"`
+}
+
+// ParseEvent parses the XML rendering of an event.
+func ParseEvent(data []byte) (*Event, error) {
+	e := &Event{}
+	if err := xml.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// LevelText returns the name of the standard levels of the events.
+func LevelText(level int) string {
+	switch level {
+	case 0:
+		return "LogAlways"
+	case 1:
+		return "Critical"
+	case 2:
+		return "Error"
+	case 3:
+		return "Warning"
+	case 4:
+		return "Information"
+	case 5:
+		return "Verbose"
+	}
+	return strconv.Itoa(level)
+}
+
+// formatLine renders the event as a JSON log line.
+func formatLine(cfg *scrapeconfig.WindowsEventsTargetConfig, e *Event) (string, error) {
+	l := line{
+		Source:        e.Provider.Name,
+		Channel:       e.Channel,
+		Computer:      e.Computer,
+		EventID:       e.EventID,
+		Version:       e.Version,
+		Level:         e.Level,
+		LevelText:     LevelText(e.Level),
+		Task:          e.Task,
+		Opcode:        e.Opcode,
+		Keywords:      e.Keywords,
+		TimeCreated:   e.TimeCreated.SystemTime,
+		EventRecordID: e.EventRecordID,
+		ProcessID:     e.Execution.ProcessID,
+		ThreadID:      e.Execution.ThreadID,
+		UserID:        e.Security.UserID,
+		Message:       strings.TrimSpace(e.Message),
+	}
+	if !cfg.ExcludeEventData && len(e.EventData) > 0 {
+		l.EventData = make(map[string]string, len(e.EventData))
+		for i, d := range e.EventData {
+			// the data of the classic event sources are not named
+			name := d.Name
+			if name == "" {
+				name = strconv.Itoa(i)
+			}
+			l.EventData[name] = d.Value
+		}
+	}
+	if !cfg.ExcludeUserData {
+		l.UserData = strings.TrimSpace(e.UserData.InnerXML)
+	}
+
+	b, err := json.Marshal(l)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// timestamp returns the time the event was created when configured to, the current time otherwise.
+func timestamp(cfg *scrapeconfig.WindowsEventsTargetConfig, e *Event) time.Time {
+	if cfg.UseIncomingTimestamp {
+		if ts, err := time.Parse(time.RFC3339Nano, e.TimeCreated.SystemTime); err == nil {
+			return ts
+		}
+	}
+	return time.Now()
+}
+
+// eventLabels returns the labels of the event once relabeled, without the internal ones. The labels are nil when the
+// relabeling drops the event.
+func eventLabels(cfg *scrapeconfig.WindowsEventsTargetConfig, relabelConfig []*relabel.Config, e *Event) model.LabelSet {
+	lb := labels.NewBuilder(nil)
+	for k, v := range cfg.Labels {
+		lb.Set(string(k), string(v))
+	}
+	lb.Set("__windows_event_channel", e.Channel)
+	lb.Set("__windows_event_provider", e.Provider.Name)
+	lb.Set("__windows_event_level", LevelText(e.Level))
+	lb.Set("__windows_event_id", strconv.Itoa(e.EventID))
+	lb.Set("__windows_event_computer", e.Computer)
+
+	processed := relabel.Process(lb.Labels(), relabelConfig...)
+	if processed == nil {
+		return nil
+	}
+	ls := make(model.LabelSet, len(processed))
+	for _, l := range processed {
+		if strings.HasPrefix(l.Name, "__") {
+			continue
+		}
+		ls[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+	}
+	return ls
+}
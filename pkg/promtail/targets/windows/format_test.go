@@ -0,0 +1,129 @@
+package windows
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+)
+
+const sampleEvent = `<Event xmlns='http://schemas.microsoft.com/win/2004/08/events/event'>
+  <System>
+    <Provider Name='Microsoft-Windows-Security-Auditing' Guid='{54849625-5478-4994-a5ba-3e3b0328c30d}'/>
+    <EventID>4624</EventID>
+    <Version>2</Version>
+    <Level>0</Level>
+    <Task>12544</Task>
+    <Opcode>0</Opcode>
+    <Keywords>0x8020000000000000</Keywords>
+    <TimeCreated SystemTime='2020-11-05T10:15:30.1234567Z'/>
+    <EventRecordID>38261</EventRecordID>
+    <Execution ProcessID='652' ThreadID='7152'/>
+    <Channel>Security</Channel>
+    <Computer>host.example.com</Computer>
+    <Security/>
+  </System>
+  <EventData>
+    <Data Name='TargetUserName'>admin</Data>
+    <Data Name='LogonType'>2</Data>
+  </EventData>
+</Event>`
+
+func TestParseEvent(t *testing.T) {
+	e, err := ParseEvent([]byte(sampleEvent))
+	require.NoError(t, err)
+	require.Equal(t, "Microsoft-Windows-Security-Auditing", e.Provider.Name)
+	require.Equal(t, 4624, e.EventID)
+	require.Equal(t, "Security", e.Channel)
+	require.Equal(t, "host.example.com", e.Computer)
+	require.Equal(t, uint64(38261), e.EventRecordID)
+	require.Equal(t, uint32(652), e.Execution.ProcessID)
+	require.Len(t, e.EventData, 2)
+
+	_, err = ParseEvent([]byte("<Event>"))
+	require.Error(t, err)
+}
+
+func TestFormatLine(t *testing.T) {
+	e, err := ParseEvent([]byte(sampleEvent))
+	require.NoError(t, err)
+	e.Message = "An account was successfully logged on.\r\n"
+
+	l, err := formatLine(&scrapeconfig.WindowsEventsTargetConfig{}, e)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"source": "Microsoft-Windows-Security-Auditing",
+		"channel": "Security",
+		"computer": "host.example.com",
+		"event_id": 4624,
+		"version": 2,
+		"level": 0,
+		"levelText": "LogAlways",
+		"task": 12544,
+		"keywords": "0x8020000000000000",
+		"timeCreated": "2020-11-05T10:15:30.1234567Z",
+		"eventRecordID": 38261,
+		"processID": 652,
+		"threadID": 7152,
+		"event_data": {"TargetUserName": "admin", "LogonType": "2"},
+		"message": "An account was successfully logged on."
+	}`, l)
+
+	l, err = formatLine(&scrapeconfig.WindowsEventsTargetConfig{ExcludeEventData: true}, e)
+	require.NoError(t, err)
+	require.NotContains(t, l, "event_data")
+}
+
+func TestTimestamp(t *testing.T) {
+	e, err := ParseEvent([]byte(sampleEvent))
+	require.NoError(t, err)
+
+	ts := timestamp(&scrapeconfig.WindowsEventsTargetConfig{UseIncomingTimestamp: true}, e)
+	require.Equal(t, time.Date(2020, 11, 5, 10, 15, 30, 123456700, time.UTC), ts)
+
+	ts = timestamp(&scrapeconfig.WindowsEventsTargetConfig{}, e)
+	require.WithinDuration(t, time.Now(), ts, time.Minute)
+}
+
+func TestEventLabels(t *testing.T) {
+	e, err := ParseEvent([]byte(sampleEvent))
+	require.NoError(t, err)
+	cfg := &scrapeconfig.WindowsEventsTargetConfig{Labels: model.LabelSet{"job": "windows"}}
+
+	ls := eventLabels(cfg, []*relabel.Config{
+		{
+			SourceLabels: model.LabelNames{"__windows_event_channel"},
+			Regex:        relabel.MustNewRegexp("(.*)"),
+			Replacement:  "$1",
+			TargetLabel:  "channel",
+			Action:       relabel.Replace,
+		},
+		{
+			SourceLabels: model.LabelNames{"__windows_event_level"},
+			Regex:        relabel.MustNewRegexp("(.*)"),
+			Replacement:  "$1",
+			TargetLabel:  "level",
+			Action:       relabel.Replace,
+		},
+	}, e)
+	require.Equal(t, model.LabelSet{"job": "windows", "channel": "Security", "level": "LogAlways"}, ls)
+
+	ls = eventLabels(cfg, []*relabel.Config{
+		{
+			SourceLabels: model.LabelNames{"__windows_event_id"},
+			Regex:        relabel.MustNewRegexp("4624"),
+			Action:       relabel.Drop,
+		},
+	}, e)
+	require.Nil(t, ls)
+}
+
+func TestLevelText(t *testing.T) {
+	require.Equal(t, "Error", LevelText(2))
+	require.Equal(t, "Information", LevelText(4))
+	require.Equal(t, "16", LevelText(16))
+}
@@ -0,0 +1,308 @@
+package windows
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/relabel"
+	winsys "golang.org/x/sys/windows"
+
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/positions"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+	"github.com/famarks/loki/pkg/promtail/targets/target"
+)
+
+var (
+	windowsEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "windows_target_events_total",
+		Help:      "Total number of events read from the Windows Event Log.",
+	}, []string{"job"})
+	windowsEventErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "windows_target_event_errors_total",
+		Help:      "Total number of events of the Windows Event Log that could not be rendered.",
+	}, []string{"job"})
+)
+
+const (
+	defaultPollInterval = 3 * time.Second
+
+	// batchSize is the number of events fetched at once from the subscription.
+	batchSize = 100
+)
+
+// WindowsTarget subscribes to the events of a channel of the Windows Event Log.
+// nolint:golint
+type WindowsTarget struct {
+	logger        log.Logger
+	handler       api.EntryHandler
+	positions     positions.Positions
+	positionPath  string
+	jobName       string
+	relabelConfig []*relabel.Config
+	config        *scrapeconfig.WindowsEventsTargetConfig
+
+	signal       winsys.Handle
+	subscription evtHandle
+	bookmark     evtHandle
+	// publishers caches the metadata of the publishers of the events, to format their message.
+	publishers map[string]evtHandle
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewWindowsTarget subscribes to the events of the channel, resuming after the bookmark saved in the positions file.
+func NewWindowsTarget(
+	logger log.Logger,
+	handler api.EntryHandler,
+	positions positions.Positions,
+	jobName string,
+	relabelConfig []*relabel.Config,
+	config *scrapeconfig.WindowsEventsTargetConfig,
+) (*WindowsTarget, error) {
+	if config.EventlogName == "" && !isQueryList(config.Query) {
+		return nil, fmt.Errorf("the eventlog_name of the windows_events of job %s is required, unless the xpath_query is a <QueryList>", jobName)
+	}
+
+	t := &WindowsTarget{
+		logger:        log.With(logger, "job", jobName, "eventlog_name", config.EventlogName),
+		handler:       handler,
+		positions:     positions,
+		positionPath:  positionPath(jobName),
+		jobName:       jobName,
+		relabelConfig: relabelConfig,
+		config:        config,
+		publishers:    map[string]evtHandle{},
+		quit:          make(chan struct{}),
+	}
+
+	if err := t.subscribe(); err != nil {
+		t.close()
+		return nil, err
+	}
+
+	t.wg.Add(1)
+	go t.run()
+	return t, nil
+}
+
+// positionPath returns the key of the bookmark of the job in the positions file.
+func positionPath(jobName string) string {
+	return fmt.Sprintf("windows-%s", jobName)
+}
+
+func isQueryList(query string) bool {
+	return strings.HasPrefix(strings.TrimSpace(query), "<QueryList>")
+}
+
+func (t *WindowsTarget) subscribe() error {
+	var err error
+	// manual reset, reset once all the events signaled are read
+	t.signal, err = winsys.CreateEvent(nil, 1, 1, nil)
+	if err != nil {
+		return fmt.Errorf("unable to create the signal event: %w", err)
+	}
+
+	flags := uint32(evtSubscribeToFutureEvents)
+	saved := t.positions.GetString(t.positionPath)
+	t.bookmark, err = evtCreateBookmark(saved)
+	if err != nil && saved != "" {
+		level.Warn(t.logger).Log("msg", "invalid saved bookmark, subscribing to the future events", "err", err)
+		t.bookmark, err = evtCreateBookmark("")
+	}
+	if err != nil {
+		return fmt.Errorf("unable to create the bookmark: %w", err)
+	}
+	if saved != "" {
+		flags = evtSubscribeStartAfterBookmark
+	}
+
+	channel, query := t.config.EventlogName, t.config.Query
+	if query == "" {
+		query = "*"
+	}
+	if isQueryList(query) {
+		// the channels are the ones of the query
+		channel = ""
+	}
+	t.subscription, err = evtSubscribe(t.signal, channel, query, t.bookmark, flags)
+	if err != nil {
+		return fmt.Errorf("unable to subscribe to the events of %q matching %q: %w", t.config.EventlogName, query, err)
+	}
+	return nil
+}
+
+func (t *WindowsTarget) run() {
+	defer t.wg.Done()
+
+	interval := t.config.PollInterval
+	if interval == 0 {
+		interval = defaultPollInterval
+	}
+	for {
+		select {
+		case <-t.quit:
+			return
+		default:
+		}
+
+		if _, err := winsys.WaitForSingleObject(t.signal, uint32(interval/time.Millisecond)); err != nil {
+			level.Error(t.logger).Log("msg", "error waiting for events", "err", err)
+		}
+		if err := t.readEvents(); err != nil {
+			level.Error(t.logger).Log("msg", "error reading events", "err", err)
+		}
+	}
+}
+
+// readEvents reads all the available events and saves the bookmark of the last one.
+func (t *WindowsTarget) readEvents() error {
+	// reset before reading, for the events coming in while reading to signal it again
+	if err := winsys.ResetEvent(t.signal); err != nil {
+		return err
+	}
+	events := make([]evtHandle, batchSize)
+	for {
+		n, err := evtNext(t.subscription, events)
+		if err == errorNoMoreItems {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, h := range events[:n] {
+			t.handleEvent(h)
+			if err := evtUpdateBookmark(t.bookmark, h); err != nil {
+				level.Warn(t.logger).Log("msg", "unable to update the bookmark", "err", err)
+			}
+			evtClose(h)
+		}
+
+		bookmark, err := evtRender(t.bookmark, evtRenderBookmark)
+		if err != nil {
+			level.Warn(t.logger).Log("msg", "unable to render the bookmark", "err", err)
+			continue
+		}
+		t.positions.PutString(t.positionPath, bookmark)
+	}
+}
+
+func (t *WindowsTarget) handleEvent(h evtHandle) {
+	data, err := evtRender(h, evtRenderEventXML)
+	if err != nil {
+		level.Warn(t.logger).Log("msg", "unable to render event", "err", err)
+		windowsEventErrors.WithLabelValues(t.jobName).Inc()
+		return
+	}
+	e, err := ParseEvent([]byte(data))
+	if err != nil {
+		level.Warn(t.logger).Log("msg", "unable to parse event", "err", err)
+		windowsEventErrors.WithLabelValues(t.jobName).Inc()
+		return
+	}
+	e.Message = t.formatMessage(e.Provider.Name, h)
+
+	ls := eventLabels(t.config, t.relabelConfig, e)
+	if len(ls) == 0 {
+		return
+	}
+	line, err := formatLine(t.config, e)
+	if err != nil {
+		level.Warn(t.logger).Log("msg", "unable to format event", "err", err)
+		windowsEventErrors.WithLabelValues(t.jobName).Inc()
+		return
+	}
+	windowsEvents.WithLabelValues(t.jobName).Inc()
+	if err := t.handler.Handle(ls, timestamp(t.config, e), line); err != nil {
+		level.Error(t.logger).Log("msg", "error handling event", "err", err)
+	}
+}
+
+// formatMessage returns the message of the event, empty when its publisher has no metadata to format it.
+func (t *WindowsTarget) formatMessage(publisher string, h evtHandle) string {
+	metadata, ok := t.publishers[publisher]
+	if !ok {
+		var err error
+		if metadata, err = evtOpenPublisherMetadata(publisher); err != nil {
+			level.Debug(t.logger).Log("msg", "no metadata to format the messages of the publisher", "publisher", publisher, "err", err)
+		}
+		t.publishers[publisher] = metadata
+	}
+	if metadata == 0 {
+		return ""
+	}
+	msg, err := evtFormatMessage(metadata, h)
+	if err != nil {
+		level.Debug(t.logger).Log("msg", "unable to format the message of the event", "publisher", publisher, "err", err)
+		return ""
+	}
+	return msg
+}
+
+func (t *WindowsTarget) close() {
+	for _, h := range t.publishers {
+		if h != 0 {
+			evtClose(h)
+		}
+	}
+	if t.subscription != 0 {
+		evtClose(t.subscription)
+	}
+	if t.bookmark != 0 {
+		evtClose(t.bookmark)
+	}
+	if t.signal != 0 {
+		_ = winsys.CloseHandle(t.signal)
+	}
+}
+
+// Type returns WindowsTargetType.
+func (t *WindowsTarget) Type() target.TargetType {
+	return target.WindowsTargetType
+}
+
+// Ready indicates whether or not the target is subscribed to the events.
+func (t *WindowsTarget) Ready() bool {
+	return true
+}
+
+// DiscoveredLabels returns the set of labels discovered by the WindowsTarget, which
+// is always nil. Implements Target.
+func (t *WindowsTarget) DiscoveredLabels() model.LabelSet {
+	return nil
+}
+
+// Labels returns the set of labels that statically apply to all log entries
+// produced by the WindowsTarget.
+func (t *WindowsTarget) Labels() model.LabelSet {
+	return t.config.Labels
+}
+
+// Details returns target-specific details.
+func (t *WindowsTarget) Details() interface{} {
+	return map[string]string{
+		"eventlog_name": t.config.EventlogName,
+		"xpath_query":   t.config.Query,
+	}
+}
+
+// Stop unsubscribes from the events, the bookmark of the last event read being kept in the positions file.
+func (t *WindowsTarget) Stop() error {
+	close(t.quit)
+	// wakes up the reading of the events
+	_ = winsys.SetEvent(t.signal)
+	t.wg.Wait()
+	t.close()
+	return nil
+}
@@ -0,0 +1,48 @@
+// +build !windows
+
+package windows
+
+import (
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/positions"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+	"github.com/famarks/loki/pkg/promtail/targets/target"
+)
+
+// WindowsTargetManager manages a series of WindowsTargets.
+// nolint:golint
+type WindowsTargetManager struct{}
+
+// NewWindowsTargetManager returns nil as WindowsTargets are not supported
+// on this platform.
+func NewWindowsTargetManager(
+	logger log.Logger,
+	positions positions.Positions,
+	client api.EntryHandler,
+	scrapeConfigs []scrapeconfig.Config,
+) (*WindowsTargetManager, error) {
+	level.Warn(logger).Log("msg", "WARNING!!! Windows events target was configured but support for reading the Windows Event Log is only available on Windows!")
+	return &WindowsTargetManager{}, nil
+}
+
+// Ready always returns false for WindowsTargetManager on non-Windows
+// platforms.
+func (tm *WindowsTargetManager) Ready() bool {
+	return false
+}
+
+// Stop is a no-op on non-Windows platforms.
+func (tm *WindowsTargetManager) Stop() {}
+
+// ActiveTargets always returns nil on non-Windows platforms.
+func (tm *WindowsTargetManager) ActiveTargets() map[string][]target.Target {
+	return nil
+}
+
+// AllTargets always returns nil on non-Windows platforms.
+func (tm *WindowsTargetManager) AllTargets() map[string][]target.Target {
+	return nil
+}
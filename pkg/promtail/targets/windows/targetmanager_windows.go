@@ -0,0 +1,86 @@
+package windows
+
+import (
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/famarks/loki/pkg/logentry/stages"
+	"github.com/famarks/loki/pkg/promtail/api"
+	"github.com/famarks/loki/pkg/promtail/positions"
+	"github.com/famarks/loki/pkg/promtail/scrapeconfig"
+	"github.com/famarks/loki/pkg/promtail/targets/target"
+)
+
+// WindowsTargetManager manages a series of WindowsTargets.
+// nolint:golint
+type WindowsTargetManager struct {
+	logger  log.Logger
+	targets map[string]*WindowsTarget
+}
+
+// NewWindowsTargetManager creates a new WindowsTargetManager.
+func NewWindowsTargetManager(
+	logger log.Logger,
+	positions positions.Positions,
+	client api.EntryHandler,
+	scrapeConfigs []scrapeconfig.Config,
+) (*WindowsTargetManager, error) {
+	tm := &WindowsTargetManager{
+		logger:  logger,
+		targets: make(map[string]*WindowsTarget),
+	}
+
+	for _, cfg := range scrapeConfigs {
+		registerer := prometheus.DefaultRegisterer
+		pipeline, err := stages.NewPipeline(log.With(logger, "component", "windows_pipeline"), cfg.PipelineStages, &cfg.JobName, registerer)
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := NewWindowsTarget(logger, pipeline.Wrap(client), positions, cfg.JobName, cfg.RelabelConfigs, cfg.WindowsConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		tm.targets[cfg.JobName] = t
+	}
+
+	return tm, nil
+}
+
+// Ready returns true if at least one WindowsTarget is also ready.
+func (tm *WindowsTargetManager) Ready() bool {
+	for _, t := range tm.targets {
+		if t.Ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// Stop stops the WindowsTargetManager and all of its WindowsTargets.
+func (tm *WindowsTargetManager) Stop() {
+	for _, t := range tm.targets {
+		if err := t.Stop(); err != nil {
+			level.Error(t.logger).Log("msg", "error stopping WindowsTarget", "err", err.Error())
+		}
+	}
+}
+
+// ActiveTargets returns the list of WindowsTargets where Windows events
+// are being read. ActiveTargets is an alias to AllTargets as
+// WindowsTargets cannot be deactivated, only stopped.
+func (tm *WindowsTargetManager) ActiveTargets() map[string][]target.Target {
+	return tm.AllTargets()
+}
+
+// AllTargets returns the list of all targets where Windows events
+// are currently being read.
+func (tm *WindowsTargetManager) AllTargets() map[string][]target.Target {
+	result := make(map[string][]target.Target, len(tm.targets))
+	for k, v := range tm.targets {
+		result[k] = []target.Target{v}
+	}
+	return result
+}
@@ -0,0 +1,139 @@
+package windows
+
+import (
+	"syscall"
+	"unsafe"
+
+	winsys "golang.org/x/sys/windows"
+)
+
+// evtHandle is a handle of the Windows Event Log API.
+type evtHandle uintptr
+
+// Flags of the Windows Event Log API.
+const (
+	evtSubscribeToFutureEvents     = 1
+	evtSubscribeStartAfterBookmark = 3
+	evtRenderEventXML              = 1
+	evtRenderBookmark              = 2
+	evtFormatMessageEvent          = 1
+	errorInsufficientBuffer        = syscall.Errno(122)
+	errorNoMoreItems               = syscall.Errno(259)
+)
+
+var (
+	modwevtapi = winsys.NewLazySystemDLL("wevtapi.dll")
+
+	procEvtSubscribe             = modwevtapi.NewProc("EvtSubscribe")
+	procEvtNext                  = modwevtapi.NewProc("EvtNext")
+	procEvtRender                = modwevtapi.NewProc("EvtRender")
+	procEvtClose                 = modwevtapi.NewProc("EvtClose")
+	procEvtCreateBookmark        = modwevtapi.NewProc("EvtCreateBookmark")
+	procEvtUpdateBookmark        = modwevtapi.NewProc("EvtUpdateBookmark")
+	procEvtOpenPublisherMetadata = modwevtapi.NewProc("EvtOpenPublisherMetadata")
+	procEvtFormatMessage         = modwevtapi.NewProc("EvtFormatMessage")
+)
+
+func utf16PtrOrNil(s string) (*uint16, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return winsys.UTF16PtrFromString(s)
+}
+
+// evtSubscribe subscribes to the events of the channel matching the query, signaling the event when new ones are
+// available.
+func evtSubscribe(signal winsys.Handle, channel, query string, bookmark evtHandle, flags uint32) (evtHandle, error) {
+	channelPtr, err := utf16PtrOrNil(channel)
+	if err != nil {
+		return 0, err
+	}
+	queryPtr, err := utf16PtrOrNil(query)
+	if err != nil {
+		return 0, err
+	}
+	r, _, err := procEvtSubscribe.Call(0, uintptr(signal), uintptr(unsafe.Pointer(channelPtr)), uintptr(unsafe.Pointer(queryPtr)), uintptr(bookmark), 0, 0, uintptr(flags))
+	if r == 0 {
+		return 0, err
+	}
+	return evtHandle(r), nil
+}
+
+// evtNext returns the next events of the subscription, errorNoMoreItems once there are none left.
+func evtNext(subscription evtHandle, events []evtHandle) (int, error) {
+	var returned uint32
+	r, _, err := procEvtNext.Call(uintptr(subscription), uintptr(len(events)), uintptr(unsafe.Pointer(&events[0])), 0, 0, uintptr(unsafe.Pointer(&returned)))
+	if r == 0 {
+		return 0, err
+	}
+	return int(returned), nil
+}
+
+// evtRender renders the event or the bookmark to XML.
+func evtRender(h evtHandle, flags uint32) (string, error) {
+	buf := make([]uint16, 4096)
+	for {
+		var used, count uint32
+		r, _, err := procEvtRender.Call(0, uintptr(h), uintptr(flags), uintptr(len(buf)*2), uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&used)), uintptr(unsafe.Pointer(&count)))
+		if r != 0 {
+			return winsys.UTF16ToString(buf[:used/2]), nil
+		}
+		if err != errorInsufficientBuffer {
+			return "", err
+		}
+		buf = make([]uint16, used/2+1)
+	}
+}
+
+func evtClose(h evtHandle) {
+	_, _, _ = procEvtClose.Call(uintptr(h))
+}
+
+// evtCreateBookmark creates a bookmark from its XML rendering, or a new one when empty.
+func evtCreateBookmark(xml string) (evtHandle, error) {
+	xmlPtr, err := utf16PtrOrNil(xml)
+	if err != nil {
+		return 0, err
+	}
+	r, _, err := procEvtCreateBookmark.Call(uintptr(unsafe.Pointer(xmlPtr)))
+	if r == 0 {
+		return 0, err
+	}
+	return evtHandle(r), nil
+}
+
+func evtUpdateBookmark(bookmark, event evtHandle) error {
+	r, _, err := procEvtUpdateBookmark.Call(uintptr(bookmark), uintptr(event))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+func evtOpenPublisherMetadata(publisher string) (evtHandle, error) {
+	publisherPtr, err := winsys.UTF16PtrFromString(publisher)
+	if err != nil {
+		return 0, err
+	}
+	r, _, err := procEvtOpenPublisherMetadata.Call(0, uintptr(unsafe.Pointer(publisherPtr)), 0, 0, 0)
+	if r == 0 {
+		return 0, err
+	}
+	return evtHandle(r), nil
+}
+
+// evtFormatMessage returns the message of the event formatted with the metadata of its publisher.
+func evtFormatMessage(metadata, event evtHandle) (string, error) {
+	buf := make([]uint16, 1024)
+	for {
+		var used uint32
+		r, _, err := procEvtFormatMessage.Call(uintptr(metadata), uintptr(event), 0, 0, 0, evtFormatMessageEvent, uintptr(len(buf)), uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&used)))
+		if r != 0 {
+			return winsys.UTF16ToString(buf[:used]), nil
+		}
+		if err != errorInsufficientBuffer {
+			return "", err
+		}
+		buf = make([]uint16, used+1)
+	}
+}
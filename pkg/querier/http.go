@@ -59,6 +59,7 @@ func (q *Querier) RangeQueryHandler(w http.ResponseWriter, r *http.Request) {
 	)
 	query := q.engine.Query(params)
 	result, err := query.Exec(ctx)
+	recordQueryStatistics(ctx, result.Statistics)
 	if err != nil {
 		serverutil.WriteError(err, w)
 		return
@@ -99,6 +100,7 @@ func (q *Querier) InstantQueryHandler(w http.ResponseWriter, r *http.Request) {
 	)
 	query := q.engine.Query(params)
 	result, err := query.Exec(ctx)
+	recordQueryStatistics(ctx, result.Statistics)
 	if err != nil {
 		serverutil.WriteError(err, w)
 		return
@@ -157,6 +159,7 @@ func (q *Querier) LogQueryHandler(w http.ResponseWriter, r *http.Request) {
 	query := q.engine.Query(params)
 
 	result, err := query.Exec(ctx)
+	recordQueryStatistics(ctx, result.Statistics)
 	if err != nil {
 		serverutil.WriteError(err, w)
 		return
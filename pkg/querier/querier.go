@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
 	"github.com/prometheus/common/model"
 	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/user"
@@ -42,6 +44,7 @@ type Config struct {
 	IngesterQueryStoreMaxLookback time.Duration    `yaml:"-"`
 	Engine                        logql.EngineOpts `yaml:"engine,omitempty"`
 	MaxConcurrent                 int              `yaml:"max_concurrent"`
+	PerTenantMetricsMaxTenants    int              `yaml:"per_tenant_metrics_max_tenants"`
 }
 
 // RegisterFlags register flags.
@@ -52,6 +55,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.ExtraQueryDelay, "querier.extra-query-delay", 0, "Time to wait before sending more than the minimum successful query requests.")
 	f.DurationVar(&cfg.QueryIngestersWithin, "querier.query-ingesters-within", 0, "Maximum lookback beyond which queries are not sent to ingester. 0 means all queries are sent to ingester.")
 	f.IntVar(&cfg.MaxConcurrent, "querier.max-concurrent", 20, "The maximum number of concurrent queries.")
+	f.IntVar(&cfg.PerTenantMetricsMaxTenants, "querier.per-tenant-metrics-max-tenants", 100, "Maximum number of tenants with their own series in the per tenant metrics of the queries, the queries of the others being accounted to the \"other\" tenant. 0 disables the per tenant metrics.")
 }
 
 // Querier handlers queries.
@@ -85,6 +89,7 @@ func (q *Querier) SelectLogs(ctx context.Context, params logql.SelectLogParams)
 	}
 
 	ingesterQueryInterval, storeQueryInterval := q.buildQueryIntervals(params.Start, params.End)
+	logQueryIntervalsToSpan(ctx, ingesterQueryInterval, storeQueryInterval)
 
 	iters := []iter.EntryIterator{}
 	if ingesterQueryInterval != nil {
@@ -127,6 +132,7 @@ func (q *Querier) SelectSamples(ctx context.Context, params logql.SelectSamplePa
 	}
 
 	ingesterQueryInterval, storeQueryInterval := q.buildQueryIntervals(params.Start, params.End)
+	logQueryIntervalsToSpan(ctx, ingesterQueryInterval, storeQueryInterval)
 
 	iters := []iter.SampleIterator{}
 	if ingesterQueryInterval != nil {
@@ -161,6 +167,27 @@ func (q *Querier) SelectSamples(ctx context.Context, params logql.SelectSamplePa
 	return iter.NewHeapSampleIterator(ctx, iters), nil
 }
 
+// logQueryIntervalsToSpan logs the intervals queried from the ingesters and the store, to attribute the time spent
+// querying each of them.
+func logQueryIntervalsToSpan(ctx context.Context, ingesterQueryInterval, storeQueryInterval *interval) {
+	sp := opentracing.SpanFromContext(ctx)
+	if sp == nil {
+		return
+	}
+	if ingesterQueryInterval != nil {
+		sp.LogFields(
+			otlog.String("ingester_query_start", ingesterQueryInterval.start.String()),
+			otlog.String("ingester_query_end", ingesterQueryInterval.end.String()),
+		)
+	}
+	if storeQueryInterval != nil {
+		sp.LogFields(
+			otlog.String("store_query_start", storeQueryInterval.start.String()),
+			otlog.String("store_query_end", storeQueryInterval.end.String()),
+		)
+	}
+}
+
 func (q *Querier) buildQueryIntervals(queryStart, queryEnd time.Time) (*interval, *interval) {
 	// limitQueryInterval is a flag for whether store queries should be limited to start time of ingester queries.
 	limitQueryInterval := false
@@ -10,7 +10,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/distributor"
 	"github.com/cortexproject/cortex/pkg/ring"
 	ring_client "github.com/cortexproject/cortex/pkg/ring/client"
-	"github.com/cortexproject/cortex/pkg/util/grpcclient"
+	cortex_grpcclient "github.com/cortexproject/cortex/pkg/util/grpcclient"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
@@ -24,6 +24,7 @@ import (
 	"github.com/famarks/loki/pkg/logproto"
 	"github.com/famarks/loki/pkg/logql"
 	"github.com/famarks/loki/pkg/util"
+	"github.com/famarks/loki/pkg/util/grpcclient"
 )
 
 // querierClientMock is a mockable version of QuerierClient, used in querier
@@ -92,7 +93,9 @@ func mockIngesterClientConfig() client.Config {
 			RemoteTimeout:        1 * time.Second,
 		},
 		GRPCClientConfig: grpcclient.Config{
-			MaxRecvMsgSize: 1024,
+			Config: cortex_grpcclient.Config{
+				MaxRecvMsgSize: 1024,
+			},
 		},
 		RemoteTimeout: 1 * time.Second,
 	}
@@ -20,16 +20,27 @@ import (
 
 	"github.com/famarks/loki/pkg/loghttp"
 	"github.com/famarks/loki/pkg/logql"
+	"github.com/famarks/loki/pkg/storage/embeddedcache"
 )
 
 // Config is the configuration for the queryrange tripperware
 type Config struct {
-	queryrange.Config `yaml:",inline"`
+	queryrange.Config    `yaml:",inline"`
+	EmbeddedResultsCache embeddedcache.Config `yaml:"embedded_results_cache"`
 }
 
 // RegisterFlags adds the flags required to configure this flag set.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.Config.RegisterFlags(f)
+	cfg.EmbeddedResultsCache.RegisterFlagsWithPrefix("frontend.", "Results cache: ", f)
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate(log log.Logger) error {
+	if err := cfg.Config.Validate(log); err != nil {
+		return err
+	}
+	return cfg.EmbeddedResultsCache.Validate()
 }
 
 // Stopper gracefully shutdown resources created
@@ -353,9 +364,13 @@ func NewMetricTripperware(
 
 	var c cache.Cache
 	if cfg.CacheResults {
+		resultsCacheConfig, err := withEmbeddedResultsCache(cfg, log, registerer)
+		if err != nil {
+			return nil, nil, err
+		}
 		queryCacheMiddleware, cache, err := queryrange.NewResultsCacheMiddleware(
 			log,
-			cfg.ResultsCacheConfig,
+			resultsCacheConfig,
 			cacheKeyLimits{limits},
 			limits,
 			codec,
@@ -414,3 +429,21 @@ func NewMetricTripperware(
 		return next
 	}, c, nil
 }
+
+// withEmbeddedResultsCache returns the results cache config with the embedded cache as the first tier of the cache when
+// it is enabled, the other tiers being the ones of the Cortex cache config if any.
+func withEmbeddedResultsCache(cfg Config, log log.Logger, registerer prometheus.Registerer) (queryrange.ResultsCacheConfig, error) {
+	resultsCacheConfig := cfg.ResultsCacheConfig
+	if !cfg.EmbeddedResultsCache.Enabled {
+		return resultsCacheConfig, nil
+	}
+
+	c, err := cache.New(resultsCacheConfig.CacheConfig, registerer, log)
+	if err != nil {
+		return resultsCacheConfig, err
+	}
+	name := resultsCacheConfig.CacheConfig.Prefix + "embeddedcache"
+	embedded := cache.Instrument(name, embeddedcache.New(name, cfg.EmbeddedResultsCache, registerer), registerer)
+	resultsCacheConfig.CacheConfig.Cache = cache.NewTiered([]cache.Cache{embedded, c})
+	return resultsCacheConfig, nil
+}
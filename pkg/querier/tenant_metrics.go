@@ -0,0 +1,159 @@
+package querier
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/querier/frontend"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/user"
+
+	"github.com/famarks/loki/pkg/logql/stats"
+)
+
+const (
+	// enqueueTimeHeader is set by the query frontend to the time the requests are enqueued, for the queriers to observe
+	// the time they waited in the queue.
+	enqueueTimeHeader = "X-Loki-Enqueue-Time"
+
+	// otherTenant is the tenant the queries of the tenants over the limit of the per tenant metrics are accounted to.
+	otherTenant = "other"
+)
+
+// EnqueueTimeTripperware sets the enqueue time header of the requests sent to the queriers through the queue of the
+// query frontend.
+func EnqueueTimeTripperware(next http.RoundTripper) http.RoundTripper {
+	return frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r = r.Clone(r.Context())
+		r.Header.Set(enqueueTimeHeader, strconv.FormatInt(time.Now().UnixNano(), 10))
+		return next.RoundTrip(r)
+	})
+}
+
+// TenantMetrics is the middleware of the query handlers recording the per tenant metrics of the read path, to find
+// out the tenants saturating the queriers. Only the first maxTenants tenants querying have their own series, the
+// queries of the others being accounted to the "other" tenant, so that the number of series stays bounded.
+type TenantMetrics struct {
+	maxTenants int
+
+	mtx     sync.Mutex
+	tenants map[string]struct{}
+
+	queueDuration *prometheus.CounterVec
+	queries       *prometheus.CounterVec
+	chunks        *prometheus.CounterVec
+	bytes         *prometheus.CounterVec
+	resultBytes   *prometheus.CounterVec
+}
+
+// NewTenantMetrics makes a new TenantMetrics, no metrics being recorded when maxTenants is 0.
+func NewTenantMetrics(maxTenants int, r prometheus.Registerer) *TenantMetrics {
+	return &TenantMetrics{
+		maxTenants: maxTenants,
+		tenants:    map[string]struct{}{},
+		queueDuration: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "querier_tenant_queue_duration_seconds_total",
+			Help:      "Total time the queries of the tenant waited in the queue of the query frontend.",
+		}, []string{"tenant"}),
+		queries: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "querier_tenant_queries_total",
+			Help:      "Total number of queries of the tenant executed.",
+		}, []string{"tenant"}),
+		chunks: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "querier_tenant_chunks_processed_total",
+			Help:      "Total number of chunks processed by the queries of the tenant, downloaded from the store or matched in the ingesters.",
+		}, []string{"tenant"}),
+		bytes: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "querier_tenant_bytes_processed_total",
+			Help:      "Total number of bytes of the lines processed by the queries of the tenant.",
+		}, []string{"tenant"}),
+		resultBytes: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "querier_tenant_result_bytes_total",
+			Help:      "Total number of bytes of the responses to the queries of the tenant.",
+		}, []string{"tenant"}),
+	}
+}
+
+// Wrap implements middleware.Interface.
+func (m *TenantMetrics) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := user.ExtractOrgID(r.Context())
+		if err != nil || m.maxTenants <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tenant := m.tenant(userID)
+
+		if enqueued, err := strconv.ParseInt(r.Header.Get(enqueueTimeHeader), 10, 64); err == nil {
+			// The clock of the query frontend might be ahead of the one of the querier.
+			if d := time.Since(time.Unix(0, enqueued)); d > 0 {
+				m.queueDuration.WithLabelValues(tenant).Add(d.Seconds())
+			}
+		}
+
+		statistics := &queryStatistics{}
+		cw := &countingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r.WithContext(context.WithValue(r.Context(), queryStatisticsKey, statistics)))
+
+		m.queries.WithLabelValues(tenant).Inc()
+		m.chunks.WithLabelValues(tenant).Add(float64(statistics.chunks))
+		m.bytes.WithLabelValues(tenant).Add(float64(statistics.bytes))
+		m.resultBytes.WithLabelValues(tenant).Add(float64(cw.written))
+	})
+}
+
+// tenant returns the tenant label of the user, the "other" one when the limit of tenants is reached.
+func (m *TenantMetrics) tenant(userID string) string {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if _, ok := m.tenants[userID]; ok {
+		return userID
+	}
+	if len(m.tenants) >= m.maxTenants {
+		return otherTenant
+	}
+	m.tenants[userID] = struct{}{}
+	return userID
+}
+
+type queryStatisticsKeyType int
+
+const queryStatisticsKey queryStatisticsKeyType = 0
+
+// queryStatistics are the statistics of the queries of a request, recorded by the handlers.
+type queryStatistics struct {
+	chunks int64
+	bytes  int64
+}
+
+// recordQueryStatistics adds the statistics of a query to the ones of the request, if it records the per tenant
+// metrics.
+func recordQueryStatistics(ctx context.Context, result stats.Result) {
+	statistics, ok := ctx.Value(queryStatisticsKey).(*queryStatistics)
+	if !ok {
+		return
+	}
+	statistics.chunks += result.Store.TotalChunksDownloaded + result.Ingester.TotalChunksMatched
+	statistics.bytes += result.Summary.TotalBytesProcessed
+}
+
+type countingResponseWriter struct {
+	http.ResponseWriter
+	written int
+}
+
+func (w *countingResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += n
+	return n, err
+}
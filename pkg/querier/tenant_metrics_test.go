@@ -0,0 +1,68 @@
+package querier
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/querier/frontend"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/famarks/loki/pkg/logql/stats"
+)
+
+func TestTenantMetrics(t *testing.T) {
+	m := NewTenantMetrics(1, prometheus.NewRegistry())
+	handler := m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recordQueryStatistics(r.Context(), stats.Result{
+			Summary:  stats.Summary{TotalBytesProcessed: 100},
+			Store:    stats.Store{TotalChunksDownloaded: 2},
+			Ingester: stats.Ingester{TotalChunksMatched: 1},
+		})
+		_, _ = w.Write([]byte("result"))
+	}))
+
+	// The requests are sent through the tripperware of the query frontend.
+	var queued *http.Request
+	_, err := EnqueueTimeTripperware(frontend.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		queued = r
+		return nil, nil
+	})).RoundTrip(httptest.NewRequest("GET", "/loki/api/v1/query_range", nil))
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	for _, tenant := range []string{"tenant1", "tenant2", "tenant3", "tenant1"} {
+		req := queued.WithContext(user.InjectOrgID(queued.Context(), tenant))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// The tenants over the limit are accounted to the other tenant.
+	for tenant, queries := range map[string]float64{"tenant1": 2, otherTenant: 2} {
+		require.Equal(t, queries, testutil.ToFloat64(m.queries.WithLabelValues(tenant)))
+		require.Equal(t, 3*queries, testutil.ToFloat64(m.chunks.WithLabelValues(tenant)))
+		require.Equal(t, 100*queries, testutil.ToFloat64(m.bytes.WithLabelValues(tenant)))
+		require.Equal(t, float64(len("result"))*queries, testutil.ToFloat64(m.resultBytes.WithLabelValues(tenant)))
+		require.GreaterOrEqual(t, testutil.ToFloat64(m.queueDuration.WithLabelValues(tenant)), 0.01*queries)
+	}
+	require.Equal(t, 2, testutil.CollectAndCount(m.queries))
+
+	// The requests not sent by the query frontend have no queue duration.
+	req := httptest.NewRequest("GET", "/loki/api/v1/query_range", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(user.InjectOrgID(req.Context(), "tenant1")))
+	require.Equal(t, 3.0, testutil.ToFloat64(m.queries.WithLabelValues("tenant1")))
+	require.Less(t, testutil.ToFloat64(m.queueDuration.WithLabelValues("tenant1")), 1.0)
+
+	// No metrics are recorded when disabled.
+	m = NewTenantMetrics(0, prometheus.NewRegistry())
+	w := httptest.NewRecorder()
+	m.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recordQueryStatistics(r.Context(), stats.Result{})
+		_, _ = w.Write([]byte("result"))
+	})).ServeHTTP(w, req.WithContext(user.InjectOrgID(req.Context(), "tenant1")))
+	require.Equal(t, "result", w.Body.String())
+	require.Equal(t, 0, testutil.CollectAndCount(m.queries))
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/cortexproject/cortex/pkg/ruler"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
@@ -19,6 +20,7 @@ import (
 	"github.com/prometheus/prometheus/promql"
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/template"
 	"github.com/weaveworks/common/user"
 	yaml "gopkg.in/yaml.v3"
@@ -63,6 +65,14 @@ func engineQueryFunc(engine *logql.Engine, delay time.Duration) rules.QueryFunc
 
 }
 
+// lateQueryFunc observes how late the queries of the rules start, compared to their scheduled evaluation time.
+func lateQueryFunc(queryFunc rules.QueryFunc, lateness prometheus.Observer) rules.QueryFunc {
+	return rules.QueryFunc(func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		lateness.Observe(time.Since(t).Seconds())
+		return queryFunc(ctx, qs, t)
+	})
+}
+
 // MultiTenantManagerAdapter will wrap a MultiTenantManager which validates loki rules
 func MultiTenantManagerAdapter(mgr ruler.MultiTenantManager) ruler.MultiTenantManager {
 	return &MultiTenantManager{mgr}
@@ -80,9 +90,13 @@ func (m *MultiTenantManager) ValidateRuleGroup(grp rulefmt.RuleGroup) []error {
 
 func MemstoreTenantManager(
 	cfg ruler.Config,
+	remoteWriteCfg RemoteWriteConfig,
 	engine *logql.Engine,
 ) ruler.ManagerFactory {
-	var metrics *Metrics
+	var (
+		metrics            *Metrics
+		remoteWriteMetrics *RemoteWriteMetrics
+	)
 
 	return ruler.ManagerFactory(func(
 		ctx context.Context,
@@ -97,14 +111,32 @@ func MemstoreTenantManager(
 		if metrics == nil {
 			metrics = NewMetrics(prometheus.DefaultRegisterer)
 		}
+		if remoteWriteCfg.Enabled && remoteWriteMetrics == nil {
+			remoteWriteMetrics = NewRemoteWriteMetrics(prometheus.DefaultRegisterer)
+		}
 		logger = log.With(logger, "user", userID)
 		queryFunc := engineQueryFunc(engine, cfg.EvaluationDelay)
 		memStore := NewMemStore(userID, queryFunc, metrics, 5*time.Minute, log.With(logger, "subcomponent", "MemStore"))
 
+		// The samples of the recording rules are discarded unless they are remote written.
+		var (
+			appendable   storage.Appendable = NoopAppender{}
+			remoteWriter *RemoteWriter
+		)
+		if remoteWriteCfg.Enabled {
+			var err error
+			remoteWriter, err = NewRemoteWriter(remoteWriteCfg, userID, remoteWriteMetrics, log.With(logger, "subcomponent", "RemoteWriter"))
+			if err != nil {
+				level.Error(logger).Log("msg", "failed to create remote writer, the samples of the recording rules will be discarded", "err", err)
+			} else {
+				appendable = remoteWriter
+			}
+		}
+
 		mgr := rules.NewManager(&rules.ManagerOptions{
-			Appendable:      NoopAppender{},
+			Appendable:      appendable,
 			Queryable:       memStore,
-			QueryFunc:       queryFunc,
+			QueryFunc:       lateQueryFunc(queryFunc, metrics.Lateness.WithLabelValues(userID)),
 			Context:         user.InjectOrgID(ctx, userID),
 			ExternalURL:     cfg.ExternalURL.URL,
 			NotifyFunc:      ruler.SendAlerts(notifier, cfg.ExternalURL.URL.String()),
@@ -119,10 +151,24 @@ func MemstoreTenantManager(
 		// initialize memStore, bound to the manager's alerting rules
 		memStore.Start(mgr)
 
+		if remoteWriter != nil {
+			return &remoteWriteManager{Manager: mgr, remoteWriter: remoteWriter}
+		}
 		return mgr
 	})
 }
 
+// remoteWriteManager stops the remote writer of the tenant with its rules manager.
+type remoteWriteManager struct {
+	*rules.Manager
+	remoteWriter *RemoteWriter
+}
+
+func (m *remoteWriteManager) Stop() {
+	m.Manager.Stop()
+	m.remoteWriter.Stop()
+}
+
 type GroupLoader struct{}
 
 func (GroupLoader) Parse(query string) (parser.Expr, error) {
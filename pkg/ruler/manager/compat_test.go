@@ -1,12 +1,15 @@
 package manager
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/prometheus/prometheus/promql"
 	"github.com/stretchr/testify/require"
 )
 
@@ -265,3 +268,25 @@ groups:
 
 	}
 }
+
+type observer []float64
+
+func (o *observer) Observe(v float64) { *o = append(*o, v) }
+
+func TestLateQueryFunc(t *testing.T) {
+	var (
+		lateness observer
+		queried  time.Time
+	)
+	queryFunc := lateQueryFunc(func(_ context.Context, _ string, t time.Time) (promql.Vector, error) {
+		queried = t
+		return nil, nil
+	}, &lateness)
+
+	scheduled := time.Now().Add(-time.Minute)
+	_, err := queryFunc(context.Background(), `count_over_time({foo="bar"}[1m])`, scheduled)
+	require.NoError(t, err)
+	require.Equal(t, scheduled, queried)
+	require.Len(t, lateness, 1)
+	require.True(t, lateness[0] >= time.Minute.Seconds())
+}
@@ -46,6 +46,7 @@ type Metrics struct {
 	Evaluations *prometheus.CounterVec
 	Samples     prometheus.Gauge       // in memory samples
 	CacheHits   *prometheus.CounterVec // cache hits on in memory samples
+	Lateness    *prometheus.HistogramVec
 }
 
 func NewMetrics(r prometheus.Registerer) *Metrics {
@@ -53,14 +54,23 @@ func NewMetrics(r prometheus.Registerer) *Metrics {
 		Evaluations: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
 			Namespace: "loki",
 			Name:      "ruler_memory_for_state_evaluations_total",
+			Help:      "Number of queries evaluated to restore the for state of the alerting rules, by status.",
 		}, []string{"status", "tenant"}),
 		Samples: promauto.With(r).NewGauge(prometheus.GaugeOpts{
 			Namespace: "loki",
 			Name:      "ruler_memory_samples",
+			Help:      "Number of for state samples held in memory.",
 		}),
 		CacheHits: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
 			Namespace: "loki",
 			Name:      "ruler_memory_for_state_cache_hits_total",
+			Help:      "Number of for state samples restored from memory without a query.",
+		}, []string{"tenant"}),
+		Lateness: promauto.With(r).NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "loki",
+			Name:      "ruler_evaluation_lateness_seconds",
+			Help:      "Time between the scheduled evaluation of the rules and the start of their queries.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
 		}, []string{"tenant"}),
 	}
 }
@@ -0,0 +1,307 @@
+package manager
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	config_util "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/storage/remote"
+	"github.com/prometheus/prometheus/tsdb/wal"
+	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
+)
+
+// RemoteWriteConfig configures the Prometheus compatible endpoint the samples of the recording rules are written to.
+type RemoteWriteConfig struct {
+	Enabled          bool                         `yaml:"enabled"`
+	URL              flagext.URLValue             `yaml:"url"`
+	RemoteTimeout    time.Duration                `yaml:"remote_timeout"`
+	HTTPClientConfig config_util.HTTPClientConfig `yaml:"http_client_config"`
+	WALDir           string                       `yaml:"wal_dir"`
+	FlushPeriod      time.Duration                `yaml:"flush_period"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet.
+func (cfg *RemoteWriteConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "ruler.remote-write.enabled", false, "Remote write the samples of the recording rules, which are discarded otherwise.")
+	f.Var(&cfg.URL, "ruler.remote-write.url", "URL of the Prometheus compatible endpoint the samples of the recording rules are written to.")
+	f.DurationVar(&cfg.RemoteTimeout, "ruler.remote-write.timeout", 30*time.Second, "Timeout for the requests to the remote write endpoint.")
+	f.StringVar(&cfg.WALDir, "ruler.remote-write.wal-dir", "ruler-wal", "Directory of the write ahead log keeping the samples of each tenant until they are written.")
+	f.DurationVar(&cfg.FlushPeriod, "ruler.remote-write.flush-period", 15*time.Second, "Period at which the samples of the write ahead log are written to the remote write endpoint.")
+}
+
+// Validate checks the config is usable.
+func (cfg *RemoteWriteConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.URL.URL == nil {
+		return errors.New("remote write url must be configured when remote write is enabled")
+	}
+	if cfg.FlushPeriod <= 0 {
+		return errors.New("remote write flush period must be positive")
+	}
+	return cfg.HTTPClientConfig.Validate()
+}
+
+type RemoteWriteMetrics struct {
+	Samples  *prometheus.CounterVec // samples appended to the write ahead log
+	Requests *prometheus.CounterVec // requests sent to the endpoint, by status
+	Retries  *prometheus.CounterVec // requests retried
+}
+
+func NewRemoteWriteMetrics(r prometheus.Registerer) *RemoteWriteMetrics {
+	return &RemoteWriteMetrics{
+		Samples: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "ruler_remote_write_samples_total",
+			Help:      "Number of samples of the recording rules appended to the write ahead log.",
+		}, []string{"tenant"}),
+		Requests: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "ruler_remote_write_requests_total",
+			Help:      "Number of remote write requests, by status. The samples of the failed requests are dropped.",
+		}, []string{"status", "tenant"}),
+		Retries: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "ruler_remote_write_retries_total",
+			Help:      "Number of remote write requests retried, because of network errors or 5xx responses.",
+		}, []string{"tenant"}),
+	}
+}
+
+// RemoteWriter is the storage.Appendable of a tenant, logging the samples of its recording rules to a write ahead log
+// which is periodically written to the remote write endpoint. The segments of the log are deleted once written, the
+// samples not yet written being sent after a restart.
+type RemoteWriter struct {
+	userID  string
+	cfg     RemoteWriteConfig
+	client  remote.WriteClient
+	wal     *wal.WAL
+	backoff util.BackoffConfig
+	metrics *RemoteWriteMetrics
+	logger  log.Logger
+
+	// dirty is set when samples were logged to the current segment since it was created.
+	dirty *atomic.Bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRemoteWriter opens the write ahead log of the tenant, in a directory of the configured one, and starts writing it.
+func NewRemoteWriter(cfg RemoteWriteConfig, userID string, metrics *RemoteWriteMetrics, logger log.Logger) (*RemoteWriter, error) {
+	client, err := remote.NewWriteClient(userID, &remote.ClientConfig{
+		URL:              &config_util.URL{URL: cfg.URL.URL},
+		Timeout:          model.Duration(cfg.RemoteTimeout),
+		HTTPClientConfig: cfg.HTTPClientConfig,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating remote write client")
+	}
+	// The samples are written for the tenant, for multi tenant endpoints like Cortex.
+	if c, ok := client.(*remote.Client); ok {
+		c.Client.Transport = &orgIDRoundTripper{userID: userID, next: c.Client.Transport}
+	}
+
+	// The write ahead logs of the tenants would register the same metrics, so none are registered.
+	w, err := wal.New(log.With(logger, "subcomponent", "WAL"), nil, filepath.Join(cfg.WALDir, userID), false)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening remote write WAL")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rw := &RemoteWriter{
+		userID: userID,
+		cfg:    cfg,
+		client: client,
+		wal:    w,
+		backoff: util.BackoffConfig{
+			MinBackoff: 100 * time.Millisecond,
+			MaxBackoff: 30 * time.Second,
+		},
+		metrics: metrics,
+		logger:  logger,
+		dirty:   atomic.NewBool(false),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	rw.wg.Add(1)
+	go rw.run()
+	return rw, nil
+}
+
+// Appender implements storage.Appendable.
+func (rw *RemoteWriter) Appender(_ context.Context) storage.Appender {
+	return &remoteAppender{rw: rw}
+}
+
+// Stop stops writing and closes the write ahead log, the samples not yet written staying in it.
+func (rw *RemoteWriter) Stop() {
+	rw.cancel()
+	rw.wg.Wait()
+	if err := rw.wal.Close(); err != nil {
+		level.Error(rw.logger).Log("msg", "failed to close remote write WAL", "err", err)
+	}
+}
+
+func (rw *RemoteWriter) run() {
+	defer rw.wg.Done()
+
+	ticker := time.NewTicker(rw.cfg.FlushPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := rw.flush(); err != nil && rw.ctx.Err() == nil {
+				level.Error(rw.logger).Log("msg", "failed to flush remote write WAL", "err", err)
+			}
+		case <-rw.ctx.Done():
+			return
+		}
+	}
+}
+
+// flush writes the segments of the write ahead log, starting a new one when samples were logged to the current one.
+// The segments written are deleted.
+func (rw *RemoteWriter) flush() error {
+	if rw.dirty.CAS(true, false) {
+		if err := rw.wal.NextSegment(); err != nil {
+			return errors.Wrap(err, "creating WAL segment")
+		}
+	}
+	first, last, err := wal.Segments(rw.wal.Dir())
+	if err != nil {
+		return errors.Wrap(err, "listing WAL segments")
+	}
+	// The last segment is the one being written.
+	for i := first; i >= 0 && i < last; i++ {
+		if err := rw.writeSegment(i); err != nil {
+			return err
+		}
+		if err := rw.wal.Truncate(i + 1); err != nil {
+			return errors.Wrap(err, "truncating WAL")
+		}
+	}
+	return nil
+}
+
+func (rw *RemoteWriter) writeSegment(i int) error {
+	sr, err := wal.NewSegmentsRangeReader(wal.SegmentRange{Dir: rw.wal.Dir(), First: i, Last: i})
+	if err != nil {
+		return errors.Wrapf(err, "opening WAL segment %d", i)
+	}
+	defer sr.Close()
+
+	r := wal.NewReader(sr)
+	for r.Next() {
+		if err := rw.write(r.Record()); err != nil {
+			return err
+		}
+	}
+	if err := r.Err(); err != nil {
+		// The rest of a corrupted segment can't be read, so it's dropped with the segment.
+		level.Warn(rw.logger).Log("msg", "dropping the rest of corrupted remote write WAL segment", "segment", i, "err", err)
+	}
+	return nil
+}
+
+// write sends a request, retrying it until it succeeds or can't succeed. The error returned is the one of the context
+// when the writer is stopped.
+func (rw *RemoteWriter) write(req []byte) error {
+	backoff := util.NewBackoff(rw.ctx, rw.backoff)
+	for {
+		err := rw.client.Store(rw.ctx, req)
+		if err == nil {
+			rw.metrics.Requests.WithLabelValues(statusSuccess, rw.userID).Inc()
+			return nil
+		}
+		if _, ok := err.(remote.RecoverableError); !ok {
+			level.Warn(rw.logger).Log("msg", "dropping remote write request", "err", err)
+			rw.metrics.Requests.WithLabelValues(statusFailure, rw.userID).Inc()
+			return nil
+		}
+		if rw.ctx.Err() != nil {
+			return rw.ctx.Err()
+		}
+		level.Warn(rw.logger).Log("msg", "remote write request failed, will retry", "err", err)
+		rw.metrics.Retries.WithLabelValues(rw.userID).Inc()
+		backoff.Wait()
+		if !backoff.Ongoing() {
+			return backoff.Err()
+		}
+	}
+}
+
+// remoteAppender batches the samples of a rule group evaluation into a remote write request, logged on commit.
+type remoteAppender struct {
+	rw  *RemoteWriter
+	req prompb.WriteRequest
+}
+
+func (a *remoteAppender) Add(l labels.Labels, t int64, v float64) (uint64, error) {
+	ls := make([]prompb.Label, 0, len(l))
+	for _, lbl := range l {
+		ls = append(ls, prompb.Label{Name: lbl.Name, Value: lbl.Value})
+	}
+	a.req.Timeseries = append(a.req.Timeseries, prompb.TimeSeries{
+		Labels:  ls,
+		Samples: []prompb.Sample{{Value: v, Timestamp: t}},
+	})
+	return 0, nil
+}
+
+func (a *remoteAppender) AddFast(_ uint64, _ int64, _ float64) error {
+	return storage.ErrNotFound
+}
+
+func (a *remoteAppender) Commit() error {
+	if len(a.req.Timeseries) == 0 {
+		return nil
+	}
+	b, err := proto.Marshal(&a.req)
+	if err != nil {
+		return errors.Wrap(err, "encoding remote write request")
+	}
+	if err := a.rw.wal.Log(snappy.Encode(nil, b)); err != nil {
+		return errors.Wrap(err, "logging samples to remote write WAL")
+	}
+	a.rw.dirty.Store(true)
+	a.rw.metrics.Samples.WithLabelValues(a.rw.userID).Add(float64(len(a.req.Timeseries)))
+	a.req.Timeseries = nil
+	return nil
+}
+
+func (a *remoteAppender) Rollback() error {
+	a.req.Timeseries = nil
+	return nil
+}
+
+type orgIDRoundTripper struct {
+	userID string
+	next   http.RoundTripper
+}
+
+func (rt *orgIDRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(user.OrgIDHeaderName, rt.userID)
+	return rt.next.RoundTrip(req)
+}
@@ -0,0 +1,137 @@
+package manager
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/go-kit/kit/log"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+)
+
+type remoteWriteServer struct {
+	*httptest.Server
+
+	mtx      sync.Mutex
+	failures int // number of requests to fail with a 500
+	tenants  []string
+	series   []prompb.TimeSeries
+}
+
+func newRemoteWriteServer(t *testing.T) *remoteWriteServer {
+	s := &remoteWriteServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		if s.failures > 0 {
+			s.failures--
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+			return
+		}
+		compressed, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		b, err := snappy.Decode(nil, compressed)
+		require.NoError(t, err)
+		var writeReq prompb.WriteRequest
+		require.NoError(t, writeReq.Unmarshal(b))
+		s.tenants = append(s.tenants, req.Header.Get("X-Scope-OrgID"))
+		s.series = append(s.series, writeReq.Timeseries...)
+	}))
+	return s
+}
+
+func newTestRemoteWriter(t *testing.T, serverURL, dir string) *RemoteWriter {
+	u, err := url.Parse(serverURL)
+	require.NoError(t, err)
+	cfg := RemoteWriteConfig{
+		Enabled:       true,
+		URL:           flagext.URLValue{URL: u},
+		RemoteTimeout: time.Second,
+		WALDir:        dir,
+		// Flushes are triggered by the tests.
+		FlushPeriod: time.Hour,
+	}
+	require.NoError(t, cfg.Validate())
+	rw, err := NewRemoteWriter(cfg, "fake", NewRemoteWriteMetrics(prometheus.NewRegistry()), log.NewNopLogger())
+	require.NoError(t, err)
+	rw.backoff.MinBackoff = time.Millisecond
+	rw.backoff.MaxBackoff = time.Millisecond
+	return rw
+}
+
+func appendSample(t *testing.T, rw *RemoteWriter, name string, ts int64, v float64) {
+	app := rw.Appender(context.Background())
+	_, err := app.Add(labels.FromStrings(labels.MetricName, name), ts, v)
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+}
+
+func TestRemoteWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remote-write")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	server := newRemoteWriteServer(t)
+	defer server.Close()
+
+	rw := newTestRemoteWriter(t, server.URL, dir)
+	defer rw.Stop()
+
+	appendSample(t, rw, "foo", 1, 1)
+	appendSample(t, rw, "bar", 2, 2)
+	// Rolled back samples are not written.
+	app := rw.Appender(context.Background())
+	_, err = app.Add(labels.FromStrings(labels.MetricName, "baz"), 3, 3)
+	require.NoError(t, err)
+	require.NoError(t, app.Rollback())
+
+	// The 500s are retried.
+	server.failures = 2
+	require.NoError(t, rw.flush())
+
+	require.Equal(t, []string{"fake", "fake"}, server.tenants)
+	require.Equal(t, []prompb.TimeSeries{
+		{Labels: []prompb.Label{{Name: labels.MetricName, Value: "foo"}}, Samples: []prompb.Sample{{Value: 1, Timestamp: 1}}},
+		{Labels: []prompb.Label{{Name: labels.MetricName, Value: "bar"}}, Samples: []prompb.Sample{{Value: 2, Timestamp: 2}}},
+	}, server.series)
+
+	// The segments written are not written again.
+	require.NoError(t, rw.flush())
+	require.Len(t, server.series, 2)
+}
+
+func TestRemoteWriter_Restart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remote-write")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	server := newRemoteWriteServer(t)
+	defer server.Close()
+
+	rw := newTestRemoteWriter(t, server.URL, dir)
+	appendSample(t, rw, "foo", 1, 1)
+	rw.Stop()
+	require.Empty(t, server.series)
+
+	// The samples logged before the restart are written.
+	rw = newTestRemoteWriter(t, server.URL, dir)
+	defer rw.Stop()
+	require.NoError(t, rw.flush())
+	require.Equal(t, []prompb.TimeSeries{
+		{Labels: []prompb.Label{{Name: labels.MetricName, Value: "foo"}}, Samples: []prompb.Sample{{Value: 1, Timestamp: 1}}},
+	}, server.series)
+}
+
+func TestRemoteWriteConfig_Validate(t *testing.T) {
+	require.NoError(t, (&RemoteWriteConfig{}).Validate())
+	require.EqualError(t, (&RemoteWriteConfig{Enabled: true, FlushPeriod: time.Second}).Validate(), "remote write url must be configured when remote write is enabled")
+}
@@ -1,6 +1,7 @@
 package ruler
 
 import (
+	"flag"
 	"time"
 
 	"github.com/cortexproject/cortex/pkg/ruler"
@@ -15,6 +16,14 @@ import (
 
 type Config struct {
 	ruler.Config `yaml:",inline"`
+
+	RemoteWrite manager.RemoteWriteConfig `yaml:"remote_write"`
+}
+
+// RegisterFlags registers the flags of the embedded cortex config and of the remote write config.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	cfg.Config.RegisterFlags(f)
+	cfg.RemoteWrite.RegisterFlags(f)
 }
 
 // Override the embedded cortex variant which expects a cortex limits struct. Instead copy the relevant bits over.
@@ -22,6 +31,9 @@ func (cfg *Config) Validate() error {
 	if err := cfg.StoreConfig.Validate(); err != nil {
 		return errors.Wrap(err, "invalid storage config")
 	}
+	if err := cfg.RemoteWrite.Validate(); err != nil {
+		return errors.Wrap(err, "invalid remote write config")
+	}
 	return nil
 }
 
@@ -43,9 +55,10 @@ func NewRuler(cfg Config, engine *logql.Engine, reg prometheus.Registerer, logge
 		cfg.Config,
 		manager.MemstoreTenantManager(
 			cfg.Config,
+			cfg.RemoteWrite,
 			engine,
 		),
-		prometheus.DefaultRegisterer,
+		reg,
 		logger,
 	)
 
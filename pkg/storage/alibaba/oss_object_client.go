@@ -0,0 +1,257 @@
+package alibaba
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+const (
+	// ObjectStoreType is the object store type of Alibaba Cloud OSS.
+	ObjectStoreType = "alibaba"
+
+	// ecsMetadataURL is the URL of the STS credentials of the RAM roles of the ECS instances.
+	ecsMetadataURL = "http://100.100.100.200/latest/meta-data/ram/security-credentials/"
+	// expiryWindow is how long before their expiration the STS credentials are refreshed.
+	expiryWindow = 5 * time.Minute
+)
+
+var (
+	errNoBucket                 = errors.New("the alibaba.oss.bucket must be set")
+	errNoRegion                 = errors.New("the alibaba.oss.region must be set")
+	errRAMRoleWithAccessKey     = errors.New("the alibaba.oss.ram-role-name can't be set along with an access key")
+	errAccessKeyWithoutSecret   = errors.New("the alibaba.oss.access-key-id and alibaba.oss.access-key-secret must be set together")
+	errSecurityTokenWithoutKeys = errors.New("the alibaba.oss.security-token requires an access key")
+)
+
+// Config configures the Alibaba Cloud OSS clients.
+type Config struct {
+	Bucket              string         `yaml:"bucket"`
+	Region              string         `yaml:"region"`
+	Endpoint            string         `yaml:"endpoint"`
+	UseInternalEndpoint bool           `yaml:"use_internal_endpoint"`
+	AccessKeyID         string         `yaml:"access_key_id"`
+	AccessKeySecret     flagext.Secret `yaml:"access_key_secret"`
+	SecurityToken       flagext.Secret `yaml:"security_token"`
+	RAMRoleName         string         `yaml:"ram_role_name"`
+	RequestTimeout      time.Duration  `yaml:"request_timeout"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.Bucket, "alibaba.oss.bucket", "", "Name of the OSS bucket to put the chunks and the index files in.")
+	f.StringVar(&cfg.Region, "alibaba.oss.region", "", "Region of the OSS bucket, eg cn-hangzhou.")
+	f.StringVar(&cfg.Endpoint, "alibaba.oss.endpoint", "", "OSS endpoint to connect to, oss-<region>.aliyuncs.com when empty.")
+	f.BoolVar(&cfg.UseInternalEndpoint, "alibaba.oss.use-internal-endpoint", false, "Connect to the internal endpoint of the region, oss-<region>-internal.aliyuncs.com, from the ECS instances of the region. Ignored when the endpoint is set.")
+	f.StringVar(&cfg.AccessKeyID, "alibaba.oss.access-key-id", "", "ID of the access key to authenticate with.")
+	f.Var(&cfg.AccessKeySecret, "alibaba.oss.access-key-secret", "Secret of the access key to authenticate with.")
+	f.Var(&cfg.SecurityToken, "alibaba.oss.security-token", "STS security token of the access key, when it is a temporary one.")
+	f.StringVar(&cfg.RAMRoleName, "alibaba.oss.ram-role-name", "", "Name of the RAM role of the ECS instance to authenticate with, whose STS credentials are refreshed before they expire.")
+	f.DurationVar(&cfg.RequestTimeout, "alibaba.oss.request-timeout", 30*time.Second, "Timeout of the requests to OSS.")
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if cfg.RAMRoleName != "" && cfg.AccessKeyID != "" {
+		return errRAMRoleWithAccessKey
+	}
+	if (cfg.AccessKeyID == "") != (cfg.AccessKeySecret.Value == "") {
+		return errAccessKeyWithoutSecret
+	}
+	if cfg.SecurityToken.Value != "" && cfg.AccessKeyID == "" {
+		return errSecurityTokenWithoutKeys
+	}
+	return nil
+}
+
+// endpoint returns the URL of the OSS endpoint to connect to.
+func (cfg *Config) endpoint() string {
+	if cfg.Endpoint != "" {
+		return cfg.Endpoint
+	}
+	if cfg.UseInternalEndpoint {
+		return fmt.Sprintf("https://oss-%s-internal.aliyuncs.com", cfg.Region)
+	}
+	return fmt.Sprintf("https://oss-%s.aliyuncs.com", cfg.Region)
+}
+
+// OSSObjectClient is the Alibaba Cloud OSS object client, talking to the S3 compatible API of OSS.
+type OSSObjectClient struct {
+	s3     *s3.S3
+	bucket string
+}
+
+// NewOSSObjectClient makes a new OSSObjectClient.
+func NewOSSObjectClient(cfg Config) (*OSSObjectClient, error) {
+	return newOSSObjectClient(cfg, &http.Client{Timeout: cfg.RequestTimeout}, ecsMetadataURL)
+}
+
+func newOSSObjectClient(cfg Config, httpClient *http.Client, metadataURL string) (*OSSObjectClient, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Bucket == "" {
+		return nil, errNoBucket
+	}
+	if cfg.Region == "" {
+		return nil, errNoRegion
+	}
+
+	var creds *credentials.Credentials
+	switch {
+	case cfg.RAMRoleName != "":
+		creds = credentials.NewCredentials(&ramRoleProvider{
+			client: httpClient,
+			url:    metadataURL + cfg.RAMRoleName,
+		})
+	case cfg.AccessKeyID != "":
+		creds = credentials.NewStaticCredentials(cfg.AccessKeyID, cfg.AccessKeySecret.Value, cfg.SecurityToken.Value)
+	default:
+		creds = credentials.AnonymousCredentials
+	}
+
+	sess, err := session.NewSession(aws.NewConfig().
+		WithEndpoint(cfg.endpoint()).
+		// the requests are signed with the region of the endpoint.
+		WithRegion("oss-" + cfg.Region).
+		WithCredentials(creds).
+		WithHTTPClient(httpClient).
+		// OSS only supports the virtual hosted style.
+		WithS3ForcePathStyle(false))
+	if err != nil {
+		return nil, err
+	}
+
+	return &OSSObjectClient{
+		s3:     s3.New(sess),
+		bucket: cfg.Bucket,
+	}, nil
+}
+
+// Stop implements chunk.ObjectClient.
+func (c *OSSObjectClient) Stop() {}
+
+// GetObject implements chunk.ObjectClient.
+func (c *OSSObjectClient) GetObject(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	resp, err := c.s3.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		return nil, notFoundErr(err)
+	}
+	return resp.Body, nil
+}
+
+// PutObject implements chunk.ObjectClient.
+func (c *OSSObjectClient) PutObject(ctx context.Context, objectKey string, object io.ReadSeeker) error {
+	_, err := c.s3.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Body:   object,
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(objectKey),
+	})
+	return err
+}
+
+// List implements chunk.ObjectClient.
+func (c *OSSObjectClient) List(ctx context.Context, prefix, delimiter string) ([]chunk.StorageObject, []chunk.StorageCommonPrefix, error) {
+	var storageObjects []chunk.StorageObject
+	var commonPrefixes []chunk.StorageCommonPrefix
+
+	err := c.s3.ListObjectsPagesWithContext(ctx, &s3.ListObjectsInput{
+		Bucket:    aws.String(c.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String(delimiter),
+	}, func(output *s3.ListObjectsOutput, _ bool) bool {
+		for _, content := range output.Contents {
+			storageObjects = append(storageObjects, chunk.StorageObject{
+				Key:        aws.StringValue(content.Key),
+				ModifiedAt: aws.TimeValue(content.LastModified),
+			})
+		}
+		for _, commonPrefix := range output.CommonPrefixes {
+			commonPrefixes = append(commonPrefixes, chunk.StorageCommonPrefix(aws.StringValue(commonPrefix.Prefix)))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return storageObjects, commonPrefixes, nil
+}
+
+// DeleteObject implements chunk.ObjectClient.
+func (c *OSSObjectClient) DeleteObject(ctx context.Context, objectKey string) error {
+	_, err := c.s3.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(objectKey),
+	})
+	return notFoundErr(err)
+}
+
+// notFoundErr returns the generic chunk.ErrStorageObjectNotFound error for the missing objects.
+func notFoundErr(err error) error {
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return chunk.ErrStorageObjectNotFound
+	}
+	return err
+}
+
+// ramRoleProvider provides the STS credentials of the RAM role of the ECS instance, which are rotated before they
+// expire.
+type ramRoleProvider struct {
+	credentials.Expiry
+
+	client *http.Client
+	url    string
+}
+
+type ramRoleCredentials struct {
+	Code            string
+	AccessKeyID     string `json:"AccessKeyId"`
+	AccessKeySecret string
+	SecurityToken   string
+	Expiration      time.Time
+}
+
+// Retrieve implements credentials.Provider.
+func (p *ramRoleProvider) Retrieve() (credentials.Value, error) {
+	resp, err := p.client.Get(p.url)
+	if err != nil {
+		return credentials.Value{}, fmt.Errorf("failed to get the RAM role credentials: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return credentials.Value{}, fmt.Errorf("failed to get the RAM role credentials: unexpected status %s", resp.Status)
+	}
+
+	var creds ramRoleCredentials
+	if err := json.NewDecoder(resp.Body).Decode(&creds); err != nil {
+		return credentials.Value{}, fmt.Errorf("failed to decode the RAM role credentials: %w", err)
+	}
+	if creds.Code != "Success" {
+		return credentials.Value{}, fmt.Errorf("failed to get the RAM role credentials: %s", creds.Code)
+	}
+
+	p.SetExpiration(creds.Expiration, expiryWindow)
+	return credentials.Value{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.AccessKeySecret,
+		SessionToken:    creds.SecurityToken,
+		ProviderName:    "RAMRoleProvider",
+	}, nil
+}
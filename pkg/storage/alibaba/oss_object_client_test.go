@@ -0,0 +1,188 @@
+package alibaba
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/stretchr/testify/require"
+)
+
+// ossServer is an in memory OSS bucket, also serving the STS credentials of the RAM role of the ECS instance.
+type ossServer struct {
+	mtx            sync.Mutex
+	objects        map[string][]byte
+	hosts          []string
+	authorizations []string
+	tokens         []string
+	refreshes      int
+}
+
+func (s *ossServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if strings.HasPrefix(r.URL.Path, "/latest/meta-data/ram/security-credentials/") {
+		s.refreshes++
+		// the credentials expire within the expiry window, so they are refreshed for each request.
+		fmt.Fprintf(w, `{"Code":"Success","AccessKeyId":"STS.key","AccessKeySecret":"secret","SecurityToken":"token-%d","Expiration":"%s"}`,
+			s.refreshes, time.Now().Add(time.Minute).UTC().Format(time.RFC3339))
+		return
+	}
+
+	s.hosts = append(s.hosts, r.Host)
+	s.authorizations = append(s.authorizations, r.Header.Get("Authorization"))
+	s.tokens = append(s.tokens, r.Header.Get("X-Amz-Security-Token"))
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		prefix := r.URL.Query().Get("prefix")
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult><Name>bucket</Name><IsTruncated>false</IsTruncated>`)
+		for key := range s.objects {
+			if strings.HasPrefix(key, prefix) {
+				fmt.Fprintf(w, `<Contents><Key>%s</Key><LastModified>2006-01-02T15:04:05.000Z</LastModified></Contents>`, key)
+			}
+		}
+		fmt.Fprint(w, `</ListBucketResult>`)
+	case r.Method == http.MethodPut:
+		body, _ := ioutil.ReadAll(r.Body)
+		s.objects[key] = body
+	case r.Method == http.MethodGet:
+		object, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code></Error>`)
+			return
+		}
+		_, _ = w.Write(object)
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// newTestClient makes a client connecting to the server whatever the host of the requests.
+func newTestClient(t *testing.T, cfg Config, httpServer *httptest.Server) *OSSObjectClient {
+	// the SDK can't load a custom CA bundle in the transport of the client.
+	if caBundle, ok := os.LookupEnv("AWS_CA_BUNDLE"); ok {
+		require.NoError(t, os.Unsetenv("AWS_CA_BUNDLE"))
+		defer func() { require.NoError(t, os.Setenv("AWS_CA_BUNDLE", caBundle)) }()
+	}
+
+	httpClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, httpServer.Listener.Addr().String())
+		},
+	}}
+	client, err := newOSSObjectClient(cfg, httpClient, "http://metadata/latest/meta-data/ram/security-credentials/")
+	require.NoError(t, err)
+	return client
+}
+
+func TestOSSObjectClient(t *testing.T) {
+	server := &ossServer{objects: map[string][]byte{}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	client := newTestClient(t, Config{
+		Bucket:          "bucket",
+		Region:          "cn-hangzhou",
+		Endpoint:        "http://oss-cn-hangzhou.aliyuncs.com",
+		AccessKeyID:     "key",
+		AccessKeySecret: flagext.Secret{Value: "secret"},
+	}, httpServer)
+
+	ctx := context.Background()
+	require.NoError(t, client.PutObject(ctx, "fake/chunk", bytes.NewReader([]byte("chunk"))))
+	require.Equal(t, []byte("chunk"), server.objects["fake/chunk"])
+
+	rc, err := client.GetObject(ctx, "fake/chunk")
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	require.Equal(t, []byte("chunk"), b)
+
+	_, err = client.GetObject(ctx, "fake/missing")
+	require.Equal(t, chunk.ErrStorageObjectNotFound, err)
+
+	objects, _, err := client.List(ctx, "fake/", "")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	require.Equal(t, "fake/chunk", objects[0].Key)
+	require.True(t, time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC).Equal(objects[0].ModifiedAt))
+
+	require.NoError(t, client.DeleteObject(ctx, "fake/chunk"))
+	require.Empty(t, server.objects)
+
+	for i, host := range server.hosts {
+		// the bucket is addressed with the virtual hosted style.
+		require.Equal(t, "bucket.oss-cn-hangzhou.aliyuncs.com", host)
+		require.Contains(t, server.authorizations[i], "Credential=key/")
+		require.Contains(t, server.authorizations[i], "/oss-cn-hangzhou/s3/aws4_request")
+		require.Empty(t, server.tokens[i])
+	}
+}
+
+func TestOSSObjectClient_RAMRole(t *testing.T) {
+	server := &ossServer{objects: map[string][]byte{}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	client := newTestClient(t, Config{
+		Bucket:      "bucket",
+		Region:      "cn-shanghai",
+		Endpoint:    "http://oss-cn-shanghai-internal.aliyuncs.com",
+		RAMRoleName: "loki",
+	}, httpServer)
+
+	ctx := context.Background()
+	require.NoError(t, client.PutObject(ctx, "fake/chunk1", bytes.NewReader([]byte("chunk"))))
+	require.NoError(t, client.PutObject(ctx, "fake/chunk2", bytes.NewReader([]byte("chunk"))))
+
+	require.Equal(t, 2, server.refreshes)
+	require.Equal(t, []string{"token-1", "token-2"}, server.tokens)
+	require.Equal(t, []string{"bucket.oss-cn-shanghai-internal.aliyuncs.com", "bucket.oss-cn-shanghai-internal.aliyuncs.com"}, server.hosts)
+	for _, authorization := range server.authorizations {
+		require.Contains(t, authorization, "Credential=STS.key/")
+	}
+}
+
+func TestConfig_Endpoint(t *testing.T) {
+	require.Equal(t, "https://oss-cn-beijing.aliyuncs.com", (&Config{Region: "cn-beijing"}).endpoint())
+	require.Equal(t, "https://oss-cn-beijing-internal.aliyuncs.com", (&Config{Region: "cn-beijing", UseInternalEndpoint: true}).endpoint())
+	require.Equal(t, "http://oss.example.com", (&Config{Region: "cn-beijing", Endpoint: "http://oss.example.com", UseInternalEndpoint: true}).endpoint())
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  Config
+		err  error
+	}{
+		{name: "anonymous"},
+		{name: "access key", cfg: Config{AccessKeyID: "key", AccessKeySecret: flagext.Secret{Value: "secret"}, SecurityToken: flagext.Secret{Value: "token"}}},
+		{name: "ram role", cfg: Config{RAMRoleName: "role"}},
+		{name: "ram role and access key", cfg: Config{RAMRoleName: "role", AccessKeyID: "key", AccessKeySecret: flagext.Secret{Value: "secret"}}, err: errRAMRoleWithAccessKey},
+		{name: "no secret", cfg: Config{AccessKeyID: "key"}, err: errAccessKeyWithoutSecret},
+		{name: "security token only", cfg: Config{SecurityToken: flagext.Secret{Value: "token"}}, err: errSecurityTokenWithoutKeys},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.err, tc.cfg.Validate())
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/cortexproject/cortex/pkg/chunk"
+	cortex_azure "github.com/cortexproject/cortex/pkg/chunk/azure"
+	chunk_util "github.com/cortexproject/cortex/pkg/chunk/util"
+	pkg_util "github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/go-kit/kit/log/level"
+)
+
+// ObjectStoreType is the object store type of Azure Blob Storage.
+const ObjectStoreType = "azure"
+
+// storageResource is the resource the managed identity tokens are requested for.
+const storageResource = "https://storage.azure.com/"
+
+var (
+	errConnectionStringWithManagedIdentity = errors.New("the azure connection string can't be set along with the managed identity")
+	errUserAssignedIDWithoutIdentity       = errors.New("the azure user assigned ID requires the managed identity to be used")
+
+	endpointSuffixes = map[string]string{
+		"AzureGlobal":       "core.windows.net",
+		"AzureChinaCloud":   "core.chinacloudapi.cn",
+		"AzureGermanCloud":  "core.cloudapi.de",
+		"AzureUSGovernment": "core.usgovcloudapi.net",
+	}
+)
+
+// Config configures the authentication methods of Azure Blob Storage the Cortex client doesn't support. The other
+// settings of the clients, like the container, the timeouts and the retries, are the ones of the Cortex azure config.
+type Config struct {
+	ConnectionString   flagext.Secret `yaml:"connection_string"`
+	UseManagedIdentity bool           `yaml:"use_managed_identity"`
+	UserAssignedID     string         `yaml:"user_assigned_id"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.Var(&cfg.ConnectionString, "azure.connection-string", "Connection string of the Azure storage account, used instead of the account name and key. It can hold a shared access signature and a custom blob endpoint.")
+	f.BoolVar(&cfg.UseManagedIdentity, "azure.use-managed-identity", false, "Authenticate with the managed identity of the Azure VM or AKS node instead of the account key.")
+	f.StringVar(&cfg.UserAssignedID, "azure.user-assigned-id", "", "Client ID of the user assigned managed identity to authenticate with, the system assigned one is used when empty.")
+}
+
+// Enabled tells whether the clients must be the Loki ones.
+func (cfg *Config) Enabled() bool {
+	return cfg.ConnectionString.Value != "" || cfg.UseManagedIdentity
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if cfg.ConnectionString.Value != "" {
+		if cfg.UseManagedIdentity {
+			return errConnectionStringWithManagedIdentity
+		}
+		if _, err := parseConnectionString(cfg.ConnectionString.Value); err != nil {
+			return err
+		}
+	}
+	if cfg.UserAssignedID != "" && !cfg.UseManagedIdentity {
+		return errUserAssignedIDWithoutIdentity
+	}
+	return nil
+}
+
+// connectionString holds the settings of a connection string of a storage account.
+type connectionString struct {
+	protocol, accountName, accountKey, sas, blobEndpoint, endpointSuffix string
+}
+
+func parseConnectionString(s string) (connectionString, error) {
+	cs := connectionString{protocol: "https"}
+	for _, setting := range strings.Split(s, ";") {
+		if setting == "" {
+			continue
+		}
+		// the values, like the account keys, can hold '='.
+		parts := strings.SplitN(setting, "=", 2)
+		if len(parts) != 2 {
+			return connectionString{}, fmt.Errorf("invalid setting %q in the azure connection string", parts[0])
+		}
+		switch value := parts[1]; parts[0] {
+		case "DefaultEndpointsProtocol":
+			cs.protocol = value
+		case "AccountName":
+			cs.accountName = value
+		case "AccountKey":
+			cs.accountKey = value
+		case "SharedAccessSignature":
+			cs.sas = strings.TrimPrefix(value, "?")
+		case "BlobEndpoint":
+			cs.blobEndpoint = strings.TrimSuffix(value, "/")
+		case "EndpointSuffix":
+			cs.endpointSuffix = value
+		}
+	}
+
+	if cs.accountName == "" && cs.blobEndpoint == "" {
+		return connectionString{}, errors.New("the azure connection string must hold the AccountName or the BlobEndpoint")
+	}
+	if cs.accountKey != "" && cs.accountName == "" {
+		return connectionString{}, errors.New("the azure connection string must hold the AccountName along with the AccountKey")
+	}
+	if cs.accountKey == "" && cs.sas == "" {
+		return connectionString{}, errors.New("the azure connection string must hold the AccountKey or the SharedAccessSignature")
+	}
+	return cs, nil
+}
+
+// BlobStorage is the Azure Blob Storage object client. Unlike the Cortex one, it supports connection strings and
+// managed identities, while storing the objects with the same names so that both clients can be used interchangeably.
+type BlobStorage struct {
+	cfg          cortex_azure.BlobStorageConfig
+	containerURL azblob.ContainerURL
+}
+
+// NewBlobStorage makes a new BlobStorage.
+func NewBlobStorage(cfg cortex_azure.BlobStorageConfig, authCfg Config) (*BlobStorage, error) {
+	var (
+		credential azblob.Credential
+		u          *url.URL
+		err        error
+	)
+	if authCfg.ConnectionString.Value != "" {
+		credential, u, err = connectionStringCredential(cfg, authCfg.ConnectionString.Value)
+	} else {
+		u, err = url.Parse(fmt.Sprintf("https://%s.blob.%s/%s", cfg.AccountName, endpointSuffixes[cfg.Environment], cfg.ContainerName))
+		if err != nil {
+			return nil, err
+		}
+		if authCfg.UseManagedIdentity {
+			credential, err = managedIdentityCredential(authCfg.UserAssignedID, adal.GetMSIVMEndpoint)
+		} else {
+			credential, err = azblob.NewSharedKeyCredential(cfg.AccountName, cfg.AccountKey.Value)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newBlobStorage(cfg, *u, credential), nil
+}
+
+func newBlobStorage(cfg cortex_azure.BlobStorageConfig, containerURL url.URL, credential azblob.Credential) *BlobStorage {
+	p := azblob.NewPipeline(credential, azblob.PipelineOptions{
+		Retry: azblob.RetryOptions{
+			Policy:        azblob.RetryPolicyExponential,
+			MaxTries:      int32(cfg.MaxRetries),
+			TryTimeout:    cfg.RequestTimeout,
+			RetryDelay:    cfg.MinRetryDelay,
+			MaxRetryDelay: cfg.MaxRetryDelay,
+		},
+	})
+	return &BlobStorage{
+		cfg:          cfg,
+		containerURL: azblob.NewContainerURL(containerURL, p),
+	}
+}
+
+func connectionStringCredential(cfg cortex_azure.BlobStorageConfig, s string) (azblob.Credential, *url.URL, error) {
+	cs, err := parseConnectionString(s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	endpoint := cs.blobEndpoint
+	if endpoint == "" {
+		suffix := cs.endpointSuffix
+		if suffix == "" {
+			suffix = endpointSuffixes[cfg.Environment]
+		}
+		endpoint = fmt.Sprintf("%s://%s.blob.%s", cs.protocol, cs.accountName, suffix)
+	}
+	u, err := url.Parse(endpoint + "/" + cfg.ContainerName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cs.accountKey != "" {
+		credential, err := azblob.NewSharedKeyCredential(cs.accountName, cs.accountKey)
+		return credential, u, err
+	}
+	// the requests are authorized by the shared access signature in their query.
+	u.RawQuery = cs.sas
+	return azblob.NewAnonymousCredential(), u, nil
+}
+
+func managedIdentityCredential(userAssignedID string, msiEndpoint func() (string, error)) (azblob.Credential, error) {
+	endpoint, err := msiEndpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	var spt *adal.ServicePrincipalToken
+	if userAssignedID != "" {
+		spt, err = adal.NewServicePrincipalTokenFromMSIWithUserAssignedID(endpoint, storageResource, userAssignedID)
+	} else {
+		spt, err = adal.NewServicePrincipalTokenFromMSI(endpoint, storageResource)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := spt.Refresh(); err != nil {
+		return nil, fmt.Errorf("failed to get a token for the azure managed identity: %w", err)
+	}
+
+	initial := true
+	return azblob.NewTokenCredential(spt.Token().AccessToken, func(credential azblob.TokenCredential) time.Duration {
+		// the refresher is called right away, with the token just got.
+		if initial {
+			initial = false
+			return refreshDelay(time.Until(spt.Token().Expires()))
+		}
+
+		if err := spt.Refresh(); err != nil {
+			level.Error(pkg_util.Logger).Log("msg", "failed to refresh the token of the azure managed identity", "err", err)
+			// the current token is kept until the refresh is retried.
+			return time.Minute
+		}
+		token := spt.Token()
+		credential.SetToken(token.AccessToken)
+		return refreshDelay(time.Until(token.Expires()))
+	}), nil
+}
+
+// refreshDelay returns the time to wait before refreshing a token valid for the duration, which is refreshed before
+// it expires.
+func refreshDelay(validity time.Duration) time.Duration {
+	if delay := validity - 5*time.Minute; delay > time.Minute {
+		return delay
+	}
+	return time.Minute
+}
+
+// Stop is a no op, as there are no background workers with this driver currently.
+func (b *BlobStorage) Stop() {}
+
+// GetObject implements chunk.ObjectClient.
+func (b *BlobStorage) GetObject(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	var cancel context.CancelFunc = func() {}
+	if b.cfg.RequestTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, b.cfg.RequestTimeout)
+	}
+
+	downloadResponse, err := b.blobURL(objectKey).Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// the context is cancelled once the object is read.
+	rc := downloadResponse.Body(azblob.RetryReaderOptions{MaxRetryRequests: b.cfg.MaxRetries})
+	return chunk_util.NewReadCloserWithContextCancelFunc(rc, cancel), nil
+}
+
+// PutObject implements chunk.ObjectClient.
+func (b *BlobStorage) PutObject(ctx context.Context, objectKey string, object io.ReadSeeker) error {
+	_, err := azblob.UploadStreamToBlockBlob(ctx, object, b.blobURL(objectKey), azblob.UploadStreamToBlockBlobOptions{
+		BufferSize: b.cfg.UploadBufferSize,
+		MaxBuffers: b.cfg.UploadBufferCount,
+	})
+	return err
+}
+
+// List implements chunk.ObjectClient.
+func (b *BlobStorage) List(ctx context.Context, prefix, delimiter string) ([]chunk.StorageObject, []chunk.StorageCommonPrefix, error) {
+	var storageObjects []chunk.StorageObject
+	var commonPrefixes []chunk.StorageCommonPrefix
+
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+
+		listBlob, err := b.containerURL.ListBlobsHierarchySegment(ctx, marker, delimiter, azblob.ListBlobsSegmentOptions{Prefix: prefix})
+		if err != nil {
+			return nil, nil, err
+		}
+		marker = listBlob.NextMarker
+
+		for _, blobInfo := range listBlob.Segment.BlobItems {
+			storageObjects = append(storageObjects, chunk.StorageObject{
+				Key:        blobInfo.Name,
+				ModifiedAt: blobInfo.Properties.LastModified,
+			})
+		}
+		for _, blobPrefix := range listBlob.Segment.BlobPrefixes {
+			commonPrefixes = append(commonPrefixes, chunk.StorageCommonPrefix(blobPrefix.Name))
+		}
+	}
+
+	return storageObjects, commonPrefixes, nil
+}
+
+// DeleteObject implements chunk.ObjectClient.
+func (b *BlobStorage) DeleteObject(ctx context.Context, objectKey string) error {
+	_, err := b.blobURL(objectKey).Delete(ctx, azblob.DeleteSnapshotsOptionInclude, azblob.BlobAccessConditions{})
+	return err
+}
+
+// blobURL returns the URL of the blob of an object, named like the Cortex client does.
+func (b *BlobStorage) blobURL(objectKey string) azblob.BlockBlobURL {
+	return b.containerURL.NewBlockBlobURL(strings.Replace(objectKey, ":", "-", -1))
+}
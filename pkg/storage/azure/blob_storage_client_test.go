@@ -0,0 +1,192 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	cortex_azure "github.com/cortexproject/cortex/pkg/chunk/azure"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/stretchr/testify/require"
+)
+
+// blobServer is an in memory Azure Blob Storage container, recording the authorization of the requests.
+type blobServer struct {
+	mtx            sync.Mutex
+	blobs          map[string][]byte
+	authorizations []string
+	queries        []string
+}
+
+func (s *blobServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.authorizations = append(s.authorizations, r.Header.Get("Authorization"))
+	s.queries = append(s.queries, r.URL.Query().Get("sig"))
+
+	name := strings.TrimPrefix(r.URL.Path, "/container/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Query().Get("comp") == "list":
+		prefix := r.URL.Query().Get("prefix")
+		fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>`)
+		for name := range s.blobs {
+			if strings.HasPrefix(name, prefix) {
+				fmt.Fprintf(w, `<Blob><Name>%s</Name><Properties><Last-Modified>Mon, 02 Jan 2006 15:04:05 GMT</Last-Modified></Properties></Blob>`, name)
+			}
+		}
+		fmt.Fprint(w, `</Blobs><NextMarker/></EnumerationResults>`)
+	case r.Method == http.MethodPut:
+		body, _ := ioutil.ReadAll(r.Body)
+		s.blobs[name] = body
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet:
+		blob, ok := s.blobs[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(blob)))
+		_, _ = w.Write(blob)
+	case r.Method == http.MethodDelete:
+		delete(s.blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func newTestConfig() cortex_azure.BlobStorageConfig {
+	return cortex_azure.BlobStorageConfig{
+		Environment:       "AzureGlobal",
+		ContainerName:     "container",
+		AccountName:       "account",
+		RequestTimeout:    10 * time.Second,
+		MaxRetries:        1,
+		UploadBufferSize:  256000,
+		UploadBufferCount: 1,
+	}
+}
+
+func testObjects(t *testing.T, client *BlobStorage, server *blobServer) {
+	ctx := context.Background()
+	require.NoError(t, client.PutObject(ctx, "fake/chunk:1", bytes.NewReader([]byte("chunk"))))
+	// the blobs are named like the Cortex client does.
+	require.Equal(t, []byte("chunk"), server.blobs["fake/chunk-1"])
+
+	rc, err := client.GetObject(ctx, "fake/chunk:1")
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	require.Equal(t, []byte("chunk"), b)
+
+	objects, _, err := client.List(ctx, "fake/", "")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	require.Equal(t, "fake/chunk-1", objects[0].Key)
+	require.True(t, time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC).Equal(objects[0].ModifiedAt))
+
+	require.NoError(t, client.DeleteObject(ctx, "fake/chunk:1"))
+	require.Empty(t, server.blobs)
+}
+
+func TestBlobStorage_ConnectionString(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		connectionString string
+		authorization    string
+		sig              string
+	}{
+		{
+			name:             "account key",
+			connectionString: "DefaultEndpointsProtocol=http;AccountName=account;AccountKey=a2V5;BlobEndpoint=%s;",
+			authorization:    "SharedKey account:",
+		},
+		{
+			name:             "shared access signature",
+			connectionString: "BlobEndpoint=%s;SharedAccessSignature=sv=2019-02-02&sig=signature",
+			sig:              "signature",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := &blobServer{blobs: map[string][]byte{}}
+			httpServer := httptest.NewServer(server)
+			defer httpServer.Close()
+
+			client, err := NewBlobStorage(newTestConfig(), Config{ConnectionString: flagext.Secret{Value: fmt.Sprintf(tc.connectionString, httpServer.URL)}})
+			require.NoError(t, err)
+			testObjects(t, client, server)
+
+			for i, authorization := range server.authorizations {
+				require.True(t, strings.HasPrefix(authorization, tc.authorization), authorization)
+				require.Equal(t, tc.sig, server.queries[i])
+			}
+		})
+	}
+}
+
+func TestBlobStorage_ManagedIdentity(t *testing.T) {
+	var clientIDs []string
+	msiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientIDs = append(clientIDs, r.URL.Query().Get("client_id"))
+		fmt.Fprintf(w, `{"access_token":"token","expires_in":"3600","expires_on":"%d","resource":"%s","token_type":"Bearer"}`, time.Now().Add(time.Hour).Unix(), r.URL.Query().Get("resource"))
+	}))
+	defer msiServer.Close()
+
+	credential, err := managedIdentityCredential("client-id", func() (string, error) { return msiServer.URL, nil })
+	require.NoError(t, err)
+	require.Equal(t, []string{"client-id"}, clientIDs)
+	// the token credentials are only sent over https.
+	require.Equal(t, "token", credential.(azblob.TokenCredential).Token())
+}
+
+func TestRefreshDelay(t *testing.T) {
+	require.Equal(t, 55*time.Minute, refreshDelay(time.Hour))
+	require.Equal(t, time.Minute, refreshDelay(3*time.Minute))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  Config
+		err  bool
+	}{
+		{name: "empty"},
+		{name: "connection string", cfg: Config{ConnectionString: flagext.Secret{Value: "AccountName=account;AccountKey=a2V5=="}}},
+		{name: "managed identity", cfg: Config{UseManagedIdentity: true, UserAssignedID: "id"}},
+		{name: "both", cfg: Config{ConnectionString: flagext.Secret{Value: "AccountName=account;AccountKey=a2V5"}, UseManagedIdentity: true}, err: true},
+		{name: "user assigned ID", cfg: Config{UserAssignedID: "id"}, err: true},
+		{name: "no credentials", cfg: Config{ConnectionString: flagext.Secret{Value: "AccountName=account"}}, err: true},
+		{name: "no account", cfg: Config{ConnectionString: flagext.Secret{Value: "AccountKey=a2V5"}}, err: true},
+		{name: "invalid", cfg: Config{ConnectionString: flagext.Secret{Value: "AccountName"}}, err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.Validate()
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestParseConnectionString(t *testing.T) {
+	cs, err := parseConnectionString("DefaultEndpointsProtocol=https;AccountName=account;AccountKey=a2V5==;EndpointSuffix=core.chinacloudapi.cn")
+	require.NoError(t, err)
+	require.Equal(t, connectionString{protocol: "https", accountName: "account", accountKey: "a2V5==", endpointSuffix: "core.chinacloudapi.cn"}, cs)
+
+	credential, u, err := connectionStringCredential(cortex_azure.BlobStorageConfig{ContainerName: "container", Environment: "AzureGlobal"}, "AccountName=account;SharedAccessSignature=?sig=signature")
+	require.NoError(t, err)
+	require.NotNil(t, credential)
+	require.Equal(t, "https://account.blob.core.windows.net/container?sig=signature", u.String())
+}
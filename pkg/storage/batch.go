@@ -82,6 +82,7 @@ type batchChunkIterator struct {
 	batchSize       int
 	lastOverlapping []*LazyChunk
 	metrics         *ChunkMetrics
+	fetchQueue      *chunkFetchQueue
 	matchers        []*labels.Matcher
 
 	begun      bool
@@ -99,6 +100,7 @@ func newBatchChunkIterator(
 	direction logproto.Direction,
 	start, end time.Time,
 	metrics *ChunkMetrics,
+	fetchQueue *chunkFetchQueue,
 	matchers []*labels.Matcher,
 ) *batchChunkIterator {
 	// __name__ is not something we filter by because it's a constant in loki
@@ -106,15 +108,16 @@ func newBatchChunkIterator(
 	// The same applies to the sharding label which is injected by the cortex storage code.
 	matchers = removeMatchersByName(matchers, labels.MetricName, astmapper.ShardLabel)
 	res := &batchChunkIterator{
-		batchSize: batchSize,
-		metrics:   metrics,
-		matchers:  matchers,
-		start:     start,
-		end:       end,
-		direction: direction,
-		ctx:       ctx,
-		chunks:    lazyChunks{direction: direction, chunks: chunks},
-		next:      make(chan *chunkBatch),
+		batchSize:  batchSize,
+		metrics:    metrics,
+		fetchQueue: fetchQueue,
+		matchers:   matchers,
+		start:      start,
+		end:        end,
+		direction:  direction,
+		ctx:        ctx,
+		chunks:     lazyChunks{direction: direction, chunks: chunks},
+		next:       make(chan *chunkBatch),
 	}
 	sort.Sort(res.chunks)
 	return res
@@ -265,7 +268,7 @@ func (it *batchChunkIterator) nextBatch() *chunkBatch {
 		}
 	}
 	// download chunk for this batch.
-	chksBySeries, err := fetchChunkBySeries(it.ctx, it.metrics, batch, it.matchers)
+	chksBySeries, err := fetchChunkBySeries(it.ctx, it.metrics, it.fetchQueue, batch, it.matchers)
 	if err != nil {
 		return &chunkBatch{err: err}
 	}
@@ -299,6 +302,7 @@ type logBatchIterator struct {
 func newLogBatchIterator(
 	ctx context.Context,
 	metrics *ChunkMetrics,
+	fetchQueue *chunkFetchQueue,
 	chunks []*LazyChunk,
 	batchSize int,
 	matchers []*labels.Matcher,
@@ -311,7 +315,7 @@ func newLogBatchIterator(
 		pipeline:           pipeline,
 		ctx:                ctx,
 		cancel:             cancel,
-		batchChunkIterator: newBatchChunkIterator(ctx, chunks, batchSize, direction, start, end, metrics, matchers),
+		batchChunkIterator: newBatchChunkIterator(ctx, chunks, batchSize, direction, start, end, metrics, fetchQueue, matchers),
 	}, nil
 }
 
@@ -432,6 +436,7 @@ type sampleBatchIterator struct {
 func newSampleBatchIterator(
 	ctx context.Context,
 	metrics *ChunkMetrics,
+	fetchQueue *chunkFetchQueue,
 	chunks []*LazyChunk,
 	batchSize int,
 	matchers []*labels.Matcher,
@@ -443,7 +448,7 @@ func newSampleBatchIterator(
 		extractor:          extractor,
 		ctx:                ctx,
 		cancel:             cancel,
-		batchChunkIterator: newBatchChunkIterator(ctx, chunks, batchSize, logproto.FORWARD, start, end, metrics, matchers),
+		batchChunkIterator: newBatchChunkIterator(ctx, chunks, batchSize, logproto.FORWARD, start, end, metrics, fetchQueue, matchers),
 	}, nil
 }
 
@@ -557,12 +562,12 @@ func removeMatchersByName(matchers []*labels.Matcher, names ...string) []*labels
 	return matchers
 }
 
-func fetchChunkBySeries(ctx context.Context, metrics *ChunkMetrics, chunks []*LazyChunk, matchers []*labels.Matcher) (map[model.Fingerprint][][]*LazyChunk, error) {
+func fetchChunkBySeries(ctx context.Context, metrics *ChunkMetrics, fetchQueue *chunkFetchQueue, chunks []*LazyChunk, matchers []*labels.Matcher) (map[model.Fingerprint][][]*LazyChunk, error) {
 	chksBySeries := partitionBySeriesChunks(chunks)
 
 	// Make sure the initial chunks are loaded. This is not one chunk
 	// per series, but rather a chunk per non-overlapping iterator.
-	if err := loadFirstChunks(ctx, chksBySeries); err != nil {
+	if err := loadFirstChunks(ctx, fetchQueue, chksBySeries); err != nil {
 		return nil, err
 	}
 
@@ -578,7 +583,7 @@ func fetchChunkBySeries(ctx context.Context, metrics *ChunkMetrics, chunks []*La
 	}
 
 	// Finally we load all chunks not already loaded
-	if err := fetchLazyChunks(ctx, allChunks); err != nil {
+	if err := fetchLazyChunks(ctx, fetchQueue, allChunks); err != nil {
 		return nil, err
 	}
 	metrics.chunks.WithLabelValues(statusMatched).Add(float64(len(allChunks)))
@@ -616,7 +621,7 @@ outer:
 	return chks
 }
 
-func fetchLazyChunks(ctx context.Context, chunks []*LazyChunk) error {
+func fetchLazyChunks(ctx context.Context, fetchQueue *chunkFetchQueue, chunks []*LazyChunk) error {
 	log, ctx := spanlogger.New(ctx, "LokiStore.fetchLazyChunks")
 	defer log.Finish()
 	start := time.Now()
@@ -637,6 +642,7 @@ func fetchLazyChunks(ctx context.Context, chunks []*LazyChunk) error {
 	if len(chksByFetcher) == 0 {
 		return nil
 	}
+	log.SetTag("chunks", totalChunks)
 	level.Debug(log).Log("msg", "loading lazy chunks", "chunks", totalChunks)
 
 	errChan := make(chan error)
@@ -654,7 +660,7 @@ func fetchLazyChunks(ctx context.Context, chunks []*LazyChunk) error {
 				chks = append(chks, chk.Chunk)
 				index[key] = chk
 			}
-			chks, err := fetcher.FetchChunks(ctx, chks, keys)
+			chks, err := fetchQueue.fetch(ctx, fetcher, chks, keys)
 			if err != nil {
 				level.Error(util.Logger).Log("msg", "error fetching chunks", "err", err)
 				if isInvalidChunkError(err) {
@@ -702,7 +708,17 @@ func isInvalidChunkError(err error) bool {
 	return false
 }
 
-func loadFirstChunks(ctx context.Context, chks map[model.Fingerprint][][]*LazyChunk) error {
+// isMissingChunkError tells whether the fetch failed as a chunk referenced by the index is missing from the store, like
+// the chunks evicted from the filesystem store.
+func isMissingChunkError(err error) bool {
+	err = errors.Cause(err)
+	if err, ok := err.(promql.ErrStorage); ok {
+		return errors.Is(err.Err, chunk.ErrStorageObjectNotFound)
+	}
+	return false
+}
+
+func loadFirstChunks(ctx context.Context, fetchQueue *chunkFetchQueue, chks map[model.Fingerprint][][]*LazyChunk) error {
 	var toLoad []*LazyChunk
 	for _, lchks := range chks {
 		for _, lchk := range lchks {
@@ -712,7 +728,7 @@ func loadFirstChunks(ctx context.Context, chks map[model.Fingerprint][][]*LazyCh
 			toLoad = append(toLoad, lchk[0])
 		}
 	}
-	return fetchLazyChunks(ctx, toLoad)
+	return fetchLazyChunks(ctx, fetchQueue, toLoad)
 }
 
 func partitionBySeriesChunks(chunks []*LazyChunk) map[model.Fingerprint][][]*LazyChunk {
@@ -22,7 +22,10 @@ import (
 	"github.com/famarks/loki/pkg/logql/stats"
 )
 
-var NilMetrics = NewChunkMetrics(nil, 0)
+var (
+	NilMetrics    = NewChunkMetrics(nil, 0)
+	NilFetchQueue = newChunkFetchQueue(ChunkFetchConfig{}, nil)
+)
 
 func Test_batchIterSafeStart(t *testing.T) {
 	stream := logproto.Stream{
@@ -42,7 +45,7 @@ func Test_batchIterSafeStart(t *testing.T) {
 		newLazyChunk(stream),
 	}
 
-	batch := newBatchChunkIterator(context.Background(), chks, 1, logproto.FORWARD, from, from.Add(4*time.Millisecond), NilMetrics, []*labels.Matcher{})
+	batch := newBatchChunkIterator(context.Background(), chks, 1, logproto.FORWARD, from, from.Add(4*time.Millisecond), NilMetrics, NilFetchQueue, []*labels.Matcher{})
 
 	// if it was started already, we should see a panic before this
 	time.Sleep(time.Millisecond)
@@ -946,7 +949,7 @@ func Test_newLogBatchChunkIterator(t *testing.T) {
 	for name, tt := range tests {
 		tt := tt
 		t.Run(name, func(t *testing.T) {
-			it, err := newLogBatchIterator(context.Background(), NilMetrics, tt.chunks, tt.batchSize, newMatchers(tt.matchers), logql.NoopPipeline, tt.direction, tt.start, tt.end)
+			it, err := newLogBatchIterator(context.Background(), NilMetrics, NilFetchQueue, tt.chunks, tt.batchSize, newMatchers(tt.matchers), logql.NoopPipeline, tt.direction, tt.start, tt.end)
 			require.NoError(t, err)
 			streams, _, err := iter.ReadBatch(it, 1000)
 			_ = it.Close()
@@ -1231,7 +1234,7 @@ func Test_newSampleBatchChunkIterator(t *testing.T) {
 	for name, tt := range tests {
 		tt := tt
 		t.Run(name, func(t *testing.T) {
-			it, err := newSampleBatchIterator(context.Background(), NilMetrics, tt.chunks, tt.batchSize, newMatchers(tt.matchers), log.CountExtractor.ToSampleExtractor(nil, false, false), tt.start, tt.end)
+			it, err := newSampleBatchIterator(context.Background(), NilMetrics, NilFetchQueue, tt.chunks, tt.batchSize, newMatchers(tt.matchers), log.CountExtractor.ToSampleExtractor(nil, false, false), tt.start, tt.end)
 			require.NoError(t, err)
 			series, _, err := iter.ReadSampleBatch(it, 1000)
 			_ = it.Close()
@@ -1540,7 +1543,8 @@ func Benchmark_store_OverlappingChunks(b *testing.B) {
 		cfg: Config{
 			MaxChunkBatchSize: 50,
 		},
-		Store: newMockChunkStore(newOverlappingStreams(200, 200)),
+		Store:      newMockChunkStore(newOverlappingStreams(200, 200)),
+		fetchQueue: NilFetchQueue,
 	}
 	b.ResetTimer()
 	ctx := user.InjectOrgID(stats.NewContext(context.Background()), "fake")
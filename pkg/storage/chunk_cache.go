@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cortexproject/cortex/pkg/chunk/cache"
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/famarks/loki/pkg/storage/embeddedcache"
+)
+
+// ChunkCacheLimits are the per tenant limits of the chunk cache.
+type ChunkCacheLimits interface {
+	DisableChunkCache(userID string) bool
+}
+
+// NewChunksCache makes the cache of the chunks shared by all the periods of the schema config. Unlike the Cortex caches
+// which only support a single remote backend, memcached and Redis can both be configured, in which case Redis is used as
+// a second tier behind memcached. Both of them are written back in the background. When enabled, the embedded cache is
+// the first tier. The chunks of the tenants which have the chunk cache disabled are neither fetched from nor stored in
+// the cache.
+func NewChunksCache(cfg cache.Config, embeddedCfg embeddedcache.Config, limits ChunkCacheLimits, reg prometheus.Registerer, logger log.Logger) (cache.Cache, error) {
+	cfg.Prefix = "chunks"
+
+	var tiers []cache.Cache
+	if embeddedCfg.Enabled {
+		name := cfg.Prefix + "embeddedcache"
+		tiers = append(tiers, cache.Instrument(name, embeddedcache.New(name, embeddedCfg, reg), reg))
+	}
+	if usesMemcached(cfg) && cfg.Redis.Endpoint != "" {
+		memcachedCfg := cfg
+		memcachedCfg.Redis = cache.RedisConfig{}
+
+		redisCfg := cfg
+		redisCfg.EnableFifoCache = false
+		redisCfg.MemcacheClient = cache.MemcachedClientConfig{}
+
+		for _, tierCfg := range []cache.Config{memcachedCfg, redisCfg} {
+			tier, err := cache.New(tierCfg, reg, logger)
+			if err != nil {
+				return nil, err
+			}
+			tiers = append(tiers, tier)
+		}
+	} else {
+		tier, err := cache.New(cfg, reg, logger)
+		if err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, tier)
+	}
+
+	return cache.StopOnce(&tenantChunksCache{
+		Cache:  cache.NewTiered(tiers),
+		limits: limits,
+	}), nil
+}
+
+func usesMemcached(cfg cache.Config) bool {
+	return cfg.MemcacheClient.Host != "" || cfg.MemcacheClient.Addresses != ""
+}
+
+// tenantChunksCache skips the chunks of the tenants which have the chunk cache disabled. The tenant of a chunk is the
+// prefix of its external key, which is the key the chunks are cached with.
+type tenantChunksCache struct {
+	cache.Cache
+	limits ChunkCacheLimits
+}
+
+func (c *tenantChunksCache) Store(ctx context.Context, keys []string, bufs [][]byte) {
+	cachedKeys, cachedBufs := keys[:0:0], bufs[:0:0]
+	for i, key := range keys {
+		if c.disabled(key) {
+			continue
+		}
+		cachedKeys = append(cachedKeys, key)
+		cachedBufs = append(cachedBufs, bufs[i])
+	}
+	if len(cachedKeys) == 0 {
+		return
+	}
+	c.Cache.Store(ctx, cachedKeys, cachedBufs)
+}
+
+func (c *tenantChunksCache) Fetch(ctx context.Context, keys []string) ([]string, [][]byte, []string) {
+	var cachedKeys, missing []string
+	for _, key := range keys {
+		if c.disabled(key) {
+			missing = append(missing, key)
+			continue
+		}
+		cachedKeys = append(cachedKeys, key)
+	}
+	if len(cachedKeys) == 0 {
+		return nil, nil, missing
+	}
+
+	found, bufs, cacheMissing := c.Cache.Fetch(ctx, cachedKeys)
+	return found, bufs, append(cacheMissing, missing...)
+}
+
+func (c *tenantChunksCache) disabled(key string) bool {
+	idx := strings.IndexByte(key, '/')
+	if idx < 0 {
+		return false
+	}
+	return c.limits.DisableChunkCache(key[:idx])
+}
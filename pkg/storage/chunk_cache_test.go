@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/chunk/cache"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/storage/embeddedcache"
+)
+
+type fakeChunkCacheLimits map[string]bool
+
+func (l fakeChunkCacheLimits) DisableChunkCache(userID string) bool {
+	return l[userID]
+}
+
+func TestTenantChunksCache(t *testing.T) {
+	ctx := context.Background()
+	c := &tenantChunksCache{
+		Cache:  cache.NewMockCache(),
+		limits: fakeChunkCacheLimits{"disabled": true},
+	}
+
+	keys := []string{"enabled/1:2:3:4", "disabled/1:2:3:4", "1:2:3"}
+	c.Store(ctx, keys, [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+
+	found, bufs, missing := c.Fetch(ctx, keys)
+	require.ElementsMatch(t, []string{"enabled/1:2:3:4", "1:2:3"}, found)
+	require.ElementsMatch(t, [][]byte{[]byte("a"), []byte("c")}, bufs)
+	require.Equal(t, []string{"disabled/1:2:3:4"}, missing)
+
+	// the chunks stored before the cache got disabled for the tenant are not fetched anymore.
+	c.limits = fakeChunkCacheLimits{"enabled": true, "disabled": true}
+	found, _, missing = c.Fetch(ctx, keys)
+	require.Equal(t, []string{"1:2:3"}, found)
+	require.ElementsMatch(t, []string{"enabled/1:2:3:4", "disabled/1:2:3:4"}, missing)
+}
+
+func TestNewChunksCache(t *testing.T) {
+	// a Redis tier can be added behind memcached, which the Cortex caches don't allow.
+	var storeCfg chunk.StoreConfig
+	flagext.DefaultValues(&storeCfg)
+	cfg := storeCfg.ChunkCacheConfig
+	cfg.EnableFifoCache = true
+	cfg.Fifocache.MaxSizeItems = 10
+	cfg.MemcacheClient.Host = "localhost"
+	cfg.Redis.Endpoint = "localhost:6379"
+
+	_, err := cache.New(cfg, prometheus.NewRegistry(), log.NewNopLogger())
+	require.Error(t, err)
+
+	c, err := NewChunksCache(cfg, embeddedcache.Config{}, fakeChunkCacheLimits{}, prometheus.NewRegistry(), log.NewNopLogger())
+	require.NoError(t, err)
+	c.Stop()
+}
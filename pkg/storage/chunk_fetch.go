@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/user"
+)
+
+var (
+	errChunkFetchMaxParallel       = errors.New("the max parallel chunk fetches per batch can't be negative")
+	errChunkFetchTenantConcurrency = errors.New("the chunk fetch concurrency per tenant can't be negative")
+	errChunkFetchMaxRetries        = errors.New("the max retries of the chunk fetches can't be negative")
+)
+
+// ChunkFetchConfig configures the fetches of the chunks on the read path of the store.
+type ChunkFetchConfig struct {
+	MaxParallelGetChunks int           `yaml:"max_parallel_get_chunks"`
+	TenantConcurrency    int           `yaml:"tenant_concurrency"`
+	MaxRetries           int           `yaml:"max_retries"`
+	MinBackoff           time.Duration `yaml:"min_backoff"`
+	MaxBackoff           time.Duration `yaml:"max_backoff"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *ChunkFetchConfig) RegisterFlags(f *flag.FlagSet) {
+	f.IntVar(&cfg.MaxParallelGetChunks, "store.chunk-fetch.max-parallel-get-chunks", 0, "Maximum number of chunks fetched in parallel by each fetch of a batch of chunks, the chunks of the batch stored by different periods of the schema being fetched apart. It doesn't bound the chunks fetched by a query overall, use tenant_concurrency for that. 0 to fetch all the chunks of a batch at once.")
+	f.IntVar(&cfg.TenantConcurrency, "store.chunk-fetch.tenant-concurrency", 0, "Maximum number of chunks fetched in parallel for a tenant by all its queries. 0 to disable.")
+	f.IntVar(&cfg.MaxRetries, "store.chunk-fetch.max-retries", 0, "Number of times a failed chunk fetch is retried with an exponential backoff.")
+	f.DurationVar(&cfg.MinBackoff, "store.chunk-fetch.min-backoff", 100*time.Millisecond, "Delay before the first retry of a failed chunk fetch.")
+	f.DurationVar(&cfg.MaxBackoff, "store.chunk-fetch.max-backoff", 5*time.Second, "Maximum delay between the retries of a failed chunk fetch.")
+}
+
+// Validate validates the config.
+func (cfg *ChunkFetchConfig) Validate() error {
+	if cfg.MaxParallelGetChunks < 0 {
+		return errChunkFetchMaxParallel
+	}
+	if cfg.TenantConcurrency < 0 {
+		return errChunkFetchTenantConcurrency
+	}
+	if cfg.MaxRetries < 0 {
+		return errChunkFetchMaxRetries
+	}
+	return nil
+}
+
+// limited tells whether the chunks are fetched one by one, for their fetches to be bounded.
+func (cfg *ChunkFetchConfig) limited() bool {
+	return cfg.MaxParallelGetChunks > 0 || cfg.TenantConcurrency > 0
+}
+
+type chunkFetchMetrics struct {
+	queueDuration prometheus.Histogram
+	retries       prometheus.Counter
+	missing       prometheus.Counter
+}
+
+func newChunkFetchMetrics(r prometheus.Registerer) *chunkFetchMetrics {
+	return &chunkFetchMetrics{
+		queueDuration: promauto.With(r).NewHistogram(prometheus.HistogramOpts{
+			Namespace: "loki",
+			Subsystem: "store",
+			Name:      "chunk_fetch_queue_duration_seconds",
+			Help:      "Time the chunk fetches waited for the parallelism of their query and the concurrency of their tenant.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+		}),
+		retries: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki",
+			Subsystem: "store",
+			Name:      "chunk_fetch_retries_total",
+			Help:      "Number of retries of the failed chunk fetches.",
+		}),
+		missing: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki",
+			Subsystem: "store",
+			Name:      "chunk_fetch_missing_chunks_total",
+			Help:      "Number of chunks referenced by the index which were missing from the store and skipped.",
+		}),
+	}
+}
+
+// chunkFetchQueue fetches the lazy chunks of the queries, bounding the chunks fetched in parallel per fetch and per
+// tenant and retrying the failed fetches.
+type chunkFetchQueue struct {
+	cfg     ChunkFetchConfig
+	metrics *chunkFetchMetrics
+
+	mtx     sync.Mutex
+	tenants map[string]*tenantSlots
+}
+
+// tenantSlots are the slots of the chunk fetches of a tenant, dropped once none of its fetches uses them.
+type tenantSlots struct {
+	slots   chan struct{}
+	fetches int
+}
+
+func newChunkFetchQueue(cfg ChunkFetchConfig, r prometheus.Registerer) *chunkFetchQueue {
+	return &chunkFetchQueue{
+		cfg:     cfg,
+		metrics: newChunkFetchMetrics(r),
+		tenants: map[string]*tenantSlots{},
+	}
+}
+
+// fetch fetches the chunks with the fetcher. When the fetches are limited, the chunks are fetched one by one by the
+// workers of the fetch, each one waiting for a slot of the tenant.
+func (q *chunkFetchQueue) fetch(ctx context.Context, fetcher *chunk.Fetcher, chunks []chunk.Chunk, keys []string) ([]chunk.Chunk, error) {
+	if !q.cfg.limited() {
+		return q.fetchWithRetries(ctx, fetcher, chunks, keys)
+	}
+
+	var tenant chan struct{}
+	if q.cfg.TenantConcurrency > 0 {
+		userID, err := user.ExtractOrgID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		tenant = q.acquireTenant(userID)
+		defer q.releaseTenant(userID)
+	}
+
+	workers := len(chunks)
+	if q.cfg.MaxParallelGetChunks > 0 && q.cfg.MaxParallelGetChunks < workers {
+		workers = q.cfg.MaxParallelGetChunks
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mtx     sync.Mutex
+		fetched = make([]chunk.Chunk, 0, len(chunks))
+		lastErr error
+	)
+	queued := time.Now()
+	indexes := make(chan int)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				chks, err := q.fetchOne(ctx, tenant, queued, fetcher, chunks[i], keys[i])
+				mtx.Lock()
+				if err != nil {
+					lastErr = err
+				}
+				fetched = append(fetched, chks...)
+				mtx.Unlock()
+			}
+		}()
+	}
+
+outer:
+	for i := range chunks {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break outer
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if lastErr == nil {
+		lastErr = ctx.Err()
+	}
+	return fetched, lastErr
+}
+
+func (q *chunkFetchQueue) fetchOne(ctx context.Context, tenant chan struct{}, queued time.Time, fetcher *chunk.Fetcher, chk chunk.Chunk, key string) ([]chunk.Chunk, error) {
+	if tenant != nil {
+		select {
+		case tenant <- struct{}{}:
+			defer func() { <-tenant }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	q.metrics.queueDuration.Observe(time.Since(queued).Seconds())
+
+	return q.fetchWithRetries(ctx, fetcher, []chunk.Chunk{chk}, []string{key})
+}
+
+// fetchWithRetries retries the fetches failing with errors other than invalid checksums and missing chunks, with an
+// exponential backoff.
+func (q *chunkFetchQueue) fetchWithRetries(ctx context.Context, fetcher *chunk.Fetcher, chunks []chunk.Chunk, keys []string) ([]chunk.Chunk, error) {
+	backoff := util.NewBackoff(ctx, util.BackoffConfig{
+		MinBackoff: q.cfg.MinBackoff,
+		MaxBackoff: q.cfg.MaxBackoff,
+		MaxRetries: q.cfg.MaxRetries + 1,
+	})
+	for {
+		chks, err := fetcher.FetchChunks(ctx, chunks, keys)
+		if isMissingChunkError(err) {
+			return q.fetchExisting(ctx, fetcher, chunks, keys)
+		}
+		if err == nil || isInvalidChunkError(err) || ctx.Err() != nil {
+			return chks, err
+		}
+
+		backoff.Wait()
+		if !backoff.Ongoing() {
+			return chks, err
+		}
+		q.metrics.retries.Inc()
+	}
+}
+
+// fetchExisting fetches the chunks one by one once some of them are missing, skipping the missing ones: the index keeps
+// referencing the chunks evicted from the filesystem store until the retention deletes them, and their queries return
+// the chunks left rather than failing.
+func (q *chunkFetchQueue) fetchExisting(ctx context.Context, fetcher *chunk.Fetcher, chunks []chunk.Chunk, keys []string) ([]chunk.Chunk, error) {
+	if len(chunks) == 1 {
+		q.metrics.missing.Inc()
+		level.Debug(util.Logger).Log("msg", "skipping missing chunk", "chunk", keys[0])
+		return nil, nil
+	}
+
+	fetched := make([]chunk.Chunk, 0, len(chunks))
+	for i := range chunks {
+		chks, err := q.fetchWithRetries(ctx, fetcher, chunks[i:i+1], keys[i:i+1])
+		if err != nil {
+			return fetched, err
+		}
+		fetched = append(fetched, chks...)
+	}
+	return fetched, nil
+}
+
+// acquireTenant returns the slots of the chunk fetches of the tenant, which must be released once the fetch is done.
+func (q *chunkFetchQueue) acquireTenant(userID string) chan struct{} {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	tenant, ok := q.tenants[userID]
+	if !ok {
+		tenant = &tenantSlots{slots: make(chan struct{}, q.cfg.TenantConcurrency)}
+		q.tenants[userID] = tenant
+	}
+	tenant.fetches++
+	return tenant.slots
+}
+
+// releaseTenant releases the slots of the chunk fetches of the tenant, dropping them once the tenant has no fetch left.
+func (q *chunkFetchQueue) releaseTenant(userID string) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	tenant := q.tenants[userID]
+	tenant.fetches--
+	if tenant.fetches == 0 {
+		delete(q.tenants, userID)
+	}
+}
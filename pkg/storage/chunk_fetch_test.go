@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/chunk/cache"
+	pkg_util "github.com/cortexproject/cortex/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/famarks/loki/pkg/logproto"
+)
+
+// limitedChunkClient tracks the chunks fetched in parallel, failing the first fetches.
+type limitedChunkClient struct {
+	mockChunkStoreClient
+
+	mtx         sync.Mutex
+	inflight    int
+	maxInflight int
+	failures    int
+	missing     string
+}
+
+func (c *limitedChunkClient) GetChunks(ctx context.Context, chunks []chunk.Chunk) ([]chunk.Chunk, error) {
+	c.mtx.Lock()
+	c.inflight++
+	if c.inflight > c.maxInflight {
+		c.maxInflight = c.inflight
+	}
+	failed := c.failures > 0
+	c.failures--
+	c.mtx.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	c.mtx.Lock()
+	c.inflight--
+	c.mtx.Unlock()
+	if failed {
+		return nil, errors.New("slow down")
+	}
+	for _, chk := range chunks {
+		if chk.ExternalKey() == c.missing {
+			return nil, fmt.Errorf("%s: %w", chk.ExternalKey(), chunk.ErrStorageObjectNotFound)
+		}
+	}
+	return c.mockChunkStoreClient.GetChunks(ctx, chunks)
+}
+
+func newTestChunkFetch(t *testing.T, n, failures int) (*limitedChunkClient, *chunk.Fetcher, []chunk.Chunk, []string) {
+	var streams []*logproto.Stream
+	for i := 0; i < n; i++ {
+		streams = append(streams, &logproto.Stream{
+			Labels:  fmt.Sprintf(`{foo="%d"}`, i),
+			Entries: []logproto.Entry{{Timestamp: from, Line: "1"}},
+		})
+	}
+	store := newMockChunkStore(streams)
+	client := &limitedChunkClient{mockChunkStoreClient: *store.client, failures: failures}
+
+	var (
+		refs []chunk.Chunk
+		keys []string
+	)
+	for _, c := range store.chunks {
+		ref, err := chunk.ParseExternalKey("fake", c.ExternalKey())
+		require.NoError(t, err)
+		refs = append(refs, ref)
+		keys = append(keys, c.ExternalKey())
+	}
+
+	chunksCache, err := cache.New(cache.Config{Prefix: "chunks"}, nil, pkg_util.Logger)
+	require.NoError(t, err)
+	fetcher, err := chunk.NewChunkFetcher(chunksCache, false, client)
+	require.NoError(t, err)
+	return client, fetcher, refs, keys
+}
+
+func TestChunkFetchQueue_MaxParallelGetChunks(t *testing.T) {
+	client, fetcher, chunks, keys := newTestChunkFetch(t, 10, 0)
+	defer fetcher.Stop()
+
+	queue := newChunkFetchQueue(ChunkFetchConfig{MaxParallelGetChunks: 2}, prometheus.NewRegistry())
+	fetched, err := queue.fetch(user.InjectOrgID(context.Background(), "fake"), fetcher, chunks, keys)
+	require.NoError(t, err)
+	require.Len(t, fetched, 10)
+	require.Equal(t, 2, client.maxInflight)
+}
+
+func TestChunkFetchQueue_TenantConcurrency(t *testing.T) {
+	client, fetcher, chunks, keys := newTestChunkFetch(t, 10, 0)
+	defer fetcher.Stop()
+
+	queue := newChunkFetchQueue(ChunkFetchConfig{TenantConcurrency: 3}, prometheus.NewRegistry())
+	ctx := user.InjectOrgID(context.Background(), "fake")
+
+	// the queries of the tenant share its concurrency.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(chunks []chunk.Chunk, keys []string) {
+			defer wg.Done()
+			fetched, err := queue.fetch(ctx, fetcher, chunks, keys)
+			require.NoError(t, err)
+			require.Len(t, fetched, len(chunks))
+		}(chunks[i*5:(i+1)*5], keys[i*5:(i+1)*5])
+	}
+	wg.Wait()
+	require.Equal(t, 3, client.maxInflight)
+	// the slots of the tenant are dropped once it has no fetch left.
+	require.Empty(t, queue.tenants)
+
+	// the queue time of each chunk is observed.
+	var m dto.Metric
+	require.NoError(t, queue.metrics.queueDuration.Write(&m))
+	require.Equal(t, uint64(10), m.GetHistogram().GetSampleCount())
+}
+
+func TestChunkFetchQueue_Retries(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		maxRetries int
+		failures   int
+		err        bool
+	}{
+		{name: "no retries", failures: 1, err: true},
+		{name: "retried", maxRetries: 2, failures: 2},
+		{name: "too many failures", maxRetries: 2, failures: 3, err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, fetcher, chunks, keys := newTestChunkFetch(t, 3, tc.failures)
+			defer fetcher.Stop()
+
+			queue := newChunkFetchQueue(ChunkFetchConfig{MaxRetries: tc.maxRetries, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}, prometheus.NewRegistry())
+			fetched, err := queue.fetch(context.Background(), fetcher, chunks, keys)
+			if tc.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Len(t, fetched, 3)
+			}
+			retries := tc.failures
+			if retries > tc.maxRetries {
+				retries = tc.maxRetries
+			}
+			require.Equal(t, float64(retries), testutil.ToFloat64(queue.metrics.retries))
+		})
+	}
+}
+
+func TestChunkFetchQueue_MissingChunks(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  ChunkFetchConfig
+	}{
+		{name: "unlimited"},
+		{name: "limited", cfg: ChunkFetchConfig{MaxParallelGetChunks: 2}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, fetcher, chunks, keys := newTestChunkFetch(t, 5, 0)
+			defer fetcher.Stop()
+			// the chunk is still referenced by the index but evicted from the store.
+			client.missing = keys[2]
+
+			queue := newChunkFetchQueue(tc.cfg, prometheus.NewRegistry())
+			fetched, err := queue.fetch(user.InjectOrgID(context.Background(), "fake"), fetcher, chunks, keys)
+			require.NoError(t, err)
+			require.Len(t, fetched, 4)
+			for _, chk := range fetched {
+				require.NotEqual(t, keys[2], chk.ExternalKey())
+			}
+			require.Equal(t, float64(1), testutil.ToFloat64(queue.metrics.missing))
+			require.Equal(t, float64(0), testutil.ToFloat64(queue.metrics.retries))
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"path/filepath"
+	"strings"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/chunk/objectclient"
+
+	"github.com/famarks/loki/pkg/storage/alibaba"
+	"github.com/famarks/loki/pkg/storage/local"
+)
+
+var (
+	errColdTierObjectStore = errors.New("the object store of the cold tier must be one of aws, s3, gcs, azure, swift, filesystem, alibaba")
+	errColdTierBucket      = errors.New("the bucket of the cold tier must differ from the buckets of the object stores of the periodic configs and of the shared stores")
+)
+
+// ColdTierConfig configures the cold tier the compactor moves the old chunks to, see the tiering of the compactor. The
+// cold tier is another bucket of one of the object stores of the storage config, whose storage class is usually set by
+// the lifecycle policy of the bucket.
+type ColdTierConfig struct {
+	ObjectStore string `yaml:"object_store"`
+	Bucket      string `yaml:"bucket"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *ColdTierConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.ObjectStore, "store.cold-tier.object-store", "", "Object store of the cold tier the compactor moves the old chunks to. Supported types: aws, s3, gcs, azure, swift, filesystem, alibaba. Empty to disable the cold tier.")
+	f.StringVar(&cfg.Bucket, "store.cold-tier.bucket", "", "Bucket of the cold tier, overriding the bucket, container or directory of the config of its object store.")
+}
+
+// Enabled tells whether the chunks are read from the cold tier too.
+func (cfg *ColdTierConfig) Enabled() bool {
+	return cfg.ObjectStore != ""
+}
+
+// ValidateColdTier validates the config of the cold tier, the chunks of the periods and of the shared stores must not
+// be moved to the bucket they are in: the move would delete them once copied onto themselves.
+func (cfg *Config) ValidateColdTier(schemaCfg chunk.SchemaConfig, sharedStores ...string) error {
+	if !cfg.ColdTier.Enabled() {
+		return nil
+	}
+	if !isObjectStore(cfg.ColdTier.ObjectStore) {
+		return errColdTierObjectStore
+	}
+
+	coldStore := normalizeObjectStore(cfg.ColdTier.ObjectStore)
+	coldBuckets := objectStoreBuckets(*cfg, cfg.ColdTier.ObjectStore)
+	if cfg.ColdTier.Bucket != "" {
+		coldBuckets = objectStoreBuckets(withBucket(*cfg, cfg.ColdTier.ObjectStore, cfg.ColdTier.Bucket), cfg.ColdTier.ObjectStore)
+	}
+
+	hotStores := append([]string{}, sharedStores...)
+	for _, period := range schemaCfg.Configs {
+		hotStores = append(hotStores, period.ObjectType)
+	}
+	for _, hotStore := range hotStores {
+		if normalizeObjectStore(hotStore) != coldStore {
+			continue
+		}
+		for _, hotBucket := range objectStoreBuckets(*cfg, hotStore) {
+			for _, coldBucket := range coldBuckets {
+				if hotBucket == coldBucket {
+					return errColdTierBucket
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeObjectStore returns the object store type the store type is an alias of.
+func normalizeObjectStore(name string) string {
+	if name == "aws" {
+		return "s3"
+	}
+	return name
+}
+
+// objectStoreBuckets returns the buckets, containers or directories the object store type stores the objects in.
+func objectStoreBuckets(cfg Config, name string) []string {
+	var buckets string
+	switch normalizeObjectStore(name) {
+	case "s3":
+		// like the S3 client, the buckets override the bucket of the URL.
+		buckets = cfg.AWSStorageConfig.S3Config.BucketNames
+		if buckets == "" && cfg.AWSStorageConfig.S3Config.S3.URL != nil {
+			buckets = strings.TrimPrefix(cfg.AWSStorageConfig.S3Config.S3.URL.Path, "/")
+		}
+	case "gcs":
+		buckets = cfg.GCSConfig.BucketName
+	case "azure":
+		buckets = cfg.AzureStorageConfig.ContainerName
+	case "swift":
+		buckets = cfg.Swift.ContainerName
+	case local.ObjectStoreType:
+		return []string{filepath.Clean(cfg.FSConfig.Directory)}
+	case alibaba.ObjectStoreType:
+		buckets = cfg.AlibabaOSSConfig.Bucket
+	}
+	return strings.Split(buckets, ",")
+}
+
+// isObjectStore tells whether the chunks of the store type are stored in an object store, which can be tiered.
+func isObjectStore(name string) bool {
+	switch name {
+	case "aws", "s3", "gcs", "azure", "swift", local.ObjectStoreType, alibaba.ObjectStoreType:
+		return true
+	}
+	return false
+}
+
+// usesColdTier tells whether the chunks of the store type are read from the cold tier too.
+func usesColdTier(name string, cfg Config) bool {
+	return cfg.ColdTier.Enabled() && isObjectStore(name)
+}
+
+// NewColdTierObjectClient makes the object client of the cold tier, whose objects have the keys they had in the object
+// store they were moved from.
+func NewColdTierObjectClient(cfg Config) (chunk.ObjectClient, error) {
+	coldCfg := cfg
+	if cfg.ColdTier.Bucket != "" {
+		coldCfg = withBucket(cfg, cfg.ColdTier.ObjectStore, cfg.ColdTier.Bucket)
+	}
+	return NewObjectClient(cfg.ColdTier.ObjectStore, coldCfg)
+}
+
+// chunkKeyEncoder returns the encoder of the chunk keys of the store type.
+func chunkKeyEncoder(name string) objectclient.KeyEncoder {
+	if name == local.ObjectStoreType {
+		return objectclient.Base64Encoder
+	}
+	return nil
+}
+
+// tieredChunkClient reads the chunks from the hot tier, falling back to the cold tier for those which were moved to it.
+// The chunks are written to the hot tier only.
+type tieredChunkClient struct {
+	chunk.Client
+	cold chunk.Client
+}
+
+func newTieredChunkClient(hot, cold chunk.Client) chunk.Client {
+	return &tieredChunkClient{Client: hot, cold: cold}
+}
+
+func (c *tieredChunkClient) Stop() {
+	c.Client.Stop()
+	c.cold.Stop()
+}
+
+// GetChunks gets the chunks from the hot tier, and those which it failed to get from the cold tier.
+func (c *tieredChunkClient) GetChunks(ctx context.Context, chunks []chunk.Chunk) ([]chunk.Chunk, error) {
+	found, err := c.Client.GetChunks(ctx, chunks)
+	if err == nil {
+		return found, nil
+	}
+
+	got := make(map[string]struct{}, len(found))
+	for _, chk := range found {
+		got[chk.ExternalKey()] = struct{}{}
+	}
+	missing := make([]chunk.Chunk, 0, len(chunks)-len(found))
+	for _, chk := range chunks {
+		if _, ok := got[chk.ExternalKey()]; !ok {
+			missing = append(missing, chk)
+		}
+	}
+
+	cold, err := c.cold.GetChunks(ctx, missing)
+	return append(found, cold...), err
+}
+
+// DeleteChunk deletes the chunk from both tiers, as it can be moved while being deleted.
+func (c *tieredChunkClient) DeleteChunk(ctx context.Context, userID, chunkID string) error {
+	hotErr := c.Client.DeleteChunk(ctx, userID, chunkID)
+	coldErr := c.cold.DeleteChunk(ctx, userID, chunkID)
+	switch {
+	case hotErr == nil || coldErr == nil:
+		return nil
+	case errors.Is(hotErr, chunk.ErrStorageObjectNotFound):
+		return coldErr
+	default:
+		return hotErr
+	}
+}
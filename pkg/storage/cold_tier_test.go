@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/chunk/objectclient"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/logproto"
+)
+
+func TestTieredChunkClient(t *testing.T) {
+	hot := objectclient.NewClient(chunk.NewMockStorage(), nil)
+	cold := objectclient.NewClient(chunk.NewMockStorage(), nil)
+	client := newTieredChunkClient(hot, cold)
+
+	recent := newChunk(logproto.Stream{Labels: `{app="recent"}`, Entries: []logproto.Entry{{Timestamp: from, Line: "1"}}})
+	moved := newChunk(logproto.Stream{Labels: `{app="moved"}`, Entries: []logproto.Entry{{Timestamp: from, Line: "1"}}})
+	require.NoError(t, hot.PutChunks(context.Background(), []chunk.Chunk{recent}))
+	require.NoError(t, cold.PutChunks(context.Background(), []chunk.Chunk{moved}))
+
+	refs := make([]chunk.Chunk, 0, 2)
+	for _, c := range []chunk.Chunk{recent, moved} {
+		ref, err := chunk.ParseExternalKey("fake", c.ExternalKey())
+		require.NoError(t, err)
+		refs = append(refs, ref)
+	}
+
+	// the chunks are got from either tier.
+	chunks, err := client.GetChunks(context.Background(), refs)
+	require.NoError(t, err)
+	keys := make([]string, 0, len(chunks))
+	for _, c := range chunks {
+		keys = append(keys, c.ExternalKey())
+	}
+	sort.Strings(keys)
+	expected := []string{recent.ExternalKey(), moved.ExternalKey()}
+	sort.Strings(expected)
+	require.Equal(t, expected, keys)
+
+	// the moved chunk is deleted from the cold tier.
+	require.NoError(t, client.DeleteChunk(context.Background(), "fake", moved.ExternalKey()))
+	_, err = client.GetChunks(context.Background(), refs[1:])
+	require.Error(t, err)
+}
+
+func TestConfig_ValidateColdTier(t *testing.T) {
+	s3URL := flagext.URLValue{}
+	require.NoError(t, s3URL.Set("s3://region/chunks"))
+
+	for _, tc := range []struct {
+		name         string
+		cfg          Config
+		objectType   string
+		sharedStores []string
+		expected     error
+	}{
+		{name: "disabled", objectType: "s3"},
+		{
+			name:       "invalid object store",
+			cfg:        Config{ColdTier: ColdTierConfig{ObjectStore: "cassandra"}},
+			objectType: "s3",
+			expected:   errColdTierObjectStore,
+		},
+		{
+			name:       "other bucket",
+			cfg:        s3Config(s3URL, "chunks", ColdTierConfig{ObjectStore: "s3", Bucket: "cold"}),
+			objectType: "s3",
+		},
+		{
+			name:       "other object store",
+			cfg:        s3Config(s3URL, "chunks", ColdTierConfig{ObjectStore: "gcs"}),
+			objectType: "s3",
+		},
+		{
+			name:       "same bucket",
+			cfg:        s3Config(s3URL, "chunks", ColdTierConfig{ObjectStore: "s3"}),
+			objectType: "s3",
+			expected:   errColdTierBucket,
+		},
+		{
+			name:       "same bucket of an alias",
+			cfg:        s3Config(s3URL, "chunks", ColdTierConfig{ObjectStore: "s3"}),
+			objectType: "aws",
+			expected:   errColdTierBucket,
+		},
+		{
+			name:       "same bucket from the URL",
+			cfg:        s3Config(s3URL, "", ColdTierConfig{ObjectStore: "aws"}),
+			objectType: "s3",
+			expected:   errColdTierBucket,
+		},
+		{
+			name:       "explicit bucket equal to the hot bucket",
+			cfg:        s3Config(s3URL, "chunks", ColdTierConfig{ObjectStore: "s3", Bucket: "chunks"}),
+			objectType: "s3",
+			expected:   errColdTierBucket,
+		},
+		{
+			name:         "same bucket as a shared store",
+			cfg:          s3Config(s3URL, "chunks", ColdTierConfig{ObjectStore: "s3"}),
+			objectType:   "gcs",
+			sharedStores: []string{"aws"},
+			expected:     errColdTierBucket,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			schemaCfg := chunk.SchemaConfig{Configs: []chunk.PeriodConfig{{ObjectType: tc.objectType}}}
+			require.Equal(t, tc.expected, tc.cfg.ValidateColdTier(schemaCfg, tc.sharedStores...))
+		})
+	}
+}
+
+func s3Config(url flagext.URLValue, buckets string, coldTier ColdTierConfig) Config {
+	cfg := Config{ColdTier: coldTier}
+	cfg.AWSStorageConfig.S3Config.S3 = url
+	cfg.AWSStorageConfig.S3Config.BucketNames = buckets
+	return cfg
+}
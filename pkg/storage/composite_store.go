@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"context"
+	"sort"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/chunk/cache"
+	"github.com/cortexproject/cortex/pkg/chunk/storage"
+	pkg_util "github.com/cortexproject/cortex/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/famarks/loki/pkg/storage/stores/tsdb"
+)
+
+// StoreLimits are the per tenant limits of the chunk store.
+type StoreLimits interface {
+	storage.StoreLimits
+	ChunkCacheLimits
+}
+
+// NewChunkStore makes the chunk store of the schema config. The periods using the tsdb index are served by a tsdb.Store each
+// while the other ones are served by the Cortex chunk store. All of them share the chunks cache made by NewChunksCache.
+// The Cortex chunk store can't be given the Loki object clients, so the periods storing their chunks with one are served
+// by a Cortex store made of their index and chunk clients, which doesn't cache the index queries.
+func NewChunkStore(cfg Config, storeCfg chunk.StoreConfig, schemaCfg SchemaConfig, limits StoreLimits, registerer prometheus.Registerer) (chunk.Store, error) {
+	chunksCache, err := NewChunksCache(storeCfg.ChunkCacheConfig, cfg.EmbeddedChunkCache, limits, registerer, pkg_util.Logger)
+	if err != nil {
+		return nil, err
+	}
+	storeCfg.ChunkCacheConfig.Cache = chunksCache
+
+	servedByCortex := func(periodCfg chunk.PeriodConfig) bool {
+		return periodCfg.IndexType != tsdb.TSDBType && !usesLokiClient(periodCfg.ObjectType, cfg)
+	}
+	if allPeriods(schemaCfg.Configs, servedByCortex) {
+		return storage.NewStore(cfg.Config, storeCfg, schemaCfg.SchemaConfig, limits, registerer, nil, pkg_util.Logger)
+	}
+
+	if err := schemaCfg.Load(); err != nil {
+		return nil, err
+	}
+
+	var cortexSchemaCfg chunk.SchemaConfig
+	for _, periodCfg := range schemaCfg.Configs {
+		if servedByCortex(periodCfg) {
+			cortexSchemaCfg.Configs = append(cortexSchemaCfg.Configs, periodCfg)
+		}
+	}
+
+	var cortexStore chunk.Store
+	if len(cortexSchemaCfg.Configs) > 0 {
+		cortexStore, err = storage.NewStore(cfg.Config, storeCfg, cortexSchemaCfg, limits, registerer, nil, pkg_util.Logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var indexShipper *tsdb.IndexShipper
+	if !allPeriods(schemaCfg.Configs, func(periodCfg chunk.PeriodConfig) bool { return periodCfg.IndexType != tsdb.TSDBType }) {
+		objectClient, err := NewObjectClient(cfg.TSDBShipperConfig.SharedStoreType, cfg)
+		if err != nil {
+			return nil, err
+		}
+		indexShipper, err = tsdb.NewIndexShipper(cfg.TSDBShipperConfig, objectClient)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var lokiClientsStore *chunk.CompositeStore
+	stores := &compositeStore{}
+	for _, periodCfg := range schemaCfg.Configs {
+		if servedByCortex(periodCfg) {
+			stores.add(periodCfg.From.Time, cortexStore)
+			continue
+		}
+
+		chunkClientReg := prometheus.WrapRegistererWith(prometheus.Labels{"component": "chunk-store-" + periodCfg.From.String()}, registerer)
+		chunks, err := NewChunkClient(periodCfg.ObjectType, cfg, schemaCfg.SchemaConfig, chunkClientReg)
+		if err != nil {
+			return nil, err
+		}
+
+		if periodCfg.IndexType == tsdb.TSDBType {
+			store, err := tsdb.NewStore(periodCfg, indexShipper, chunks, chunksCache, limits)
+			if err != nil {
+				return nil, err
+			}
+			stores.add(periodCfg.From.Time, store)
+			continue
+		}
+
+		if lokiClientsStore == nil {
+			store := chunk.NewCompositeStore(nil)
+			lokiClientsStore = &store
+		}
+		indexClientReg := prometheus.WrapRegistererWith(prometheus.Labels{"component": "index-store-" + periodCfg.From.String()}, registerer)
+		index, err := storage.NewIndexClient(periodCfg.IndexType, cfg.Config, schemaCfg.SchemaConfig, indexClientReg)
+		if err != nil {
+			return nil, err
+		}
+		writeDedupeCache, err := cache.New(storeCfg.WriteDedupeCacheConfig, registerer, pkg_util.Logger)
+		if err != nil {
+			return nil, err
+		}
+		if err := lokiClientsStore.AddPeriod(storeCfg, periodCfg, index, chunks, limits, chunksCache, writeDedupeCache); err != nil {
+			return nil, err
+		}
+		stores.add(periodCfg.From.Time, lokiClientsStore)
+	}
+
+	return stores, nil
+}
+
+// compositeStore delegates to the store of the periods the operations apply to, like the Cortex composite store which
+// can't be extended with stores not using an index client.
+type compositeStore struct {
+	stores []compositeStoreEntry
+}
+
+type compositeStoreEntry struct {
+	start model.Time
+	chunk.Store
+}
+
+func (c compositeStore) Put(ctx context.Context, chunks []chunk.Chunk) error {
+	for _, chk := range chunks {
+		err := c.forStores(chk.From, chk.Through, func(from, through model.Time, store chunk.Store) error {
+			return store.PutOne(ctx, from, through, chk)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c compositeStore) PutOne(ctx context.Context, from, through model.Time, chk chunk.Chunk) error {
+	return c.forStores(from, through, func(from, through model.Time, store chunk.Store) error {
+		return store.PutOne(ctx, from, through, chk)
+	})
+}
+
+func (c compositeStore) Get(ctx context.Context, userID string, from, through model.Time, matchers ...*labels.Matcher) ([]chunk.Chunk, error) {
+	var results []chunk.Chunk
+	err := c.forStores(from, through, func(from, through model.Time, store chunk.Store) error {
+		chunks, err := store.Get(ctx, userID, from, through, matchers...)
+		if err != nil {
+			return err
+		}
+		results = append(results, chunks...)
+		return nil
+	})
+	return results, err
+}
+
+func (c compositeStore) GetChunkRefs(ctx context.Context, userID string, from, through model.Time, matchers ...*labels.Matcher) ([][]chunk.Chunk, []*chunk.Fetcher, error) {
+	var (
+		chunks   [][]chunk.Chunk
+		fetchers []*chunk.Fetcher
+	)
+	err := c.forStores(from, through, func(from, through model.Time, store chunk.Store) error {
+		storeChunks, storeFetchers, err := store.GetChunkRefs(ctx, userID, from, through, matchers...)
+		if err != nil {
+			return err
+		}
+		chunks = append(chunks, storeChunks...)
+		fetchers = append(fetchers, storeFetchers...)
+		return nil
+	})
+	return chunks, fetchers, err
+}
+
+func (c compositeStore) LabelValuesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string, labelName string) ([]string, error) {
+	var result chunk.UniqueStrings
+	err := c.forStores(from, through, func(from, through model.Time, store chunk.Store) error {
+		labelValues, err := store.LabelValuesForMetricName(ctx, userID, from, through, metricName, labelName)
+		if err != nil {
+			return err
+		}
+		result.Add(labelValues...)
+		return nil
+	})
+	return result.Strings(), err
+}
+
+func (c compositeStore) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, metricName string) ([]string, error) {
+	var result chunk.UniqueStrings
+	err := c.forStores(from, through, func(from, through model.Time, store chunk.Store) error {
+		labelNames, err := store.LabelNamesForMetricName(ctx, userID, from, through, metricName)
+		if err != nil {
+			return err
+		}
+		result.Add(labelNames...)
+		return nil
+	})
+	return result.Strings(), err
+}
+
+func (c compositeStore) GetChunkFetcher(tm model.Time) *chunk.Fetcher {
+	// find the store with the highest start before or at tm.
+	i := sort.Search(len(c.stores), func(i int) bool {
+		return c.stores[i].start > tm
+	}) - 1
+
+	if i < 0 {
+		return nil
+	}
+	return c.stores[i].GetChunkFetcher(tm)
+}
+
+func (c compositeStore) DeleteChunk(ctx context.Context, from, through model.Time, userID, chunkID string, metric labels.Labels, partiallyDeletedInterval *model.Interval) error {
+	return c.forStores(from, through, func(from, through model.Time, store chunk.Store) error {
+		return store.DeleteChunk(ctx, from, through, userID, chunkID, metric, partiallyDeletedInterval)
+	})
+}
+
+func (c compositeStore) DeleteSeriesIDs(ctx context.Context, from, through model.Time, userID string, metric labels.Labels) error {
+	return c.forStores(from, through, func(from, through model.Time, store chunk.Store) error {
+		return store.DeleteSeriesIDs(ctx, from, through, userID, metric)
+	})
+}
+
+func (c compositeStore) Stop() {
+	// the Cortex stores can serve periods on both sides of other periods, they must be stopped only once.
+	stopped := map[chunk.Store]struct{}{}
+	for _, store := range c.stores {
+		if _, ok := stopped[store.Store]; ok {
+			continue
+		}
+		stopped[store.Store] = struct{}{}
+		store.Stop()
+	}
+}
+
+// add adds the store of the period starting at start, consecutive periods served by the same store sharing an entry.
+func (c *compositeStore) add(start model.Time, store chunk.Store) {
+	if n := len(c.stores); n > 0 && c.stores[n-1].Store == store {
+		return
+	}
+	c.stores = append(c.stores, compositeStoreEntry{start: start, Store: store})
+}
+
+func allPeriods(configs []chunk.PeriodConfig, f func(chunk.PeriodConfig) bool) bool {
+	for _, periodCfg := range configs {
+		if !f(periodCfg) {
+			return false
+		}
+	}
+	return true
+}
+
+// forStores calls the callback with the time range of each period between from and through and the store of the period.
+func (c compositeStore) forStores(from, through model.Time, callback func(from, through model.Time, store chunk.Store) error) error {
+	if len(c.stores) == 0 {
+		return nil
+	}
+
+	// first, find the store with the highest start before or at from.
+	i := sort.Search(len(c.stores), func(i int) bool {
+		return c.stores[i].start > from
+	})
+	if i > 0 {
+		i--
+	} else {
+		// this could happen if we get passed a sample from before 1970.
+		from = c.stores[0].start
+	}
+
+	// next, find the store with the lowest start after through.
+	j := sort.Search(len(c.stores), func(j int) bool {
+		return c.stores[j].start > through
+	})
+
+	start := from
+	for ; i < j; i++ {
+		nextStoreStarts := model.Latest
+		if i+1 < len(c.stores) {
+			nextStoreStarts = c.stores[i+1].start
+		}
+
+		end := through
+		if nextStoreStarts-1 < end {
+			end = nextStoreStarts - 1
+		}
+		if err := callback(start, end, c.stores[i].Store); err != nil {
+			return err
+		}
+
+		start = nextStoreStarts
+	}
+
+	return nil
+}
@@ -0,0 +1,286 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	errCongestionControlLimits        = errors.New("the congestion control limits must be positive, with the min limit not above the max limit")
+	errCongestionControlBackoffFactor = errors.New("the congestion control backoff factor must be between 0 and 1")
+)
+
+var (
+	congestionControlLimit = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "loki",
+		Subsystem: "store",
+		Name:      "congestion_control_limit",
+		Help:      "Current number of concurrent requests allowed to the object store by the congestion control.",
+	}, []string{"store"})
+	congestionControlInflight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "loki",
+		Subsystem: "store",
+		Name:      "congestion_control_inflight_requests",
+		Help:      "Current number of requests to the object store allowed by the congestion control.",
+	}, []string{"store"})
+	congestionControlThrottledTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "loki",
+		Subsystem: "store",
+		Name:      "congestion_control_throttled_requests_total",
+		Help:      "Number of requests to the object store throttled by the store.",
+	}, []string{"store"})
+)
+
+// CongestionControlConfig configures the congestion control of the requests to the object stores: the number of
+// concurrent requests grows by one with every limit of requests succeeding, and is multiplied by the backoff factor
+// when the store throttles them.
+type CongestionControlConfig struct {
+	Enabled       bool    `yaml:"enabled"`
+	InitialLimit  int     `yaml:"initial_limit"`
+	MinLimit      int     `yaml:"min_limit"`
+	MaxLimit      int     `yaml:"max_limit"`
+	BackoffFactor float64 `yaml:"backoff_factor"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *CongestionControlConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "store.congestion-control.enabled", false, "Adapt the number of concurrent requests to the object stores to their throttling, like S3 503 SlowDown responses.")
+	f.IntVar(&cfg.InitialLimit, "store.congestion-control.initial-limit", 100, "Number of concurrent requests allowed to each object store at startup.")
+	f.IntVar(&cfg.MinLimit, "store.congestion-control.min-limit", 4, "Minimum number of concurrent requests allowed to each object store.")
+	f.IntVar(&cfg.MaxLimit, "store.congestion-control.max-limit", 1000, "Maximum number of concurrent requests allowed to each object store.")
+	f.Float64Var(&cfg.BackoffFactor, "store.congestion-control.backoff-factor", 0.5, "Factor the number of concurrent requests allowed is multiplied by when the object store throttles them.")
+}
+
+// Validate validates the config.
+func (cfg *CongestionControlConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.MinLimit <= 0 || cfg.MinLimit > cfg.MaxLimit {
+		return errCongestionControlLimits
+	}
+	if cfg.BackoffFactor <= 0 || cfg.BackoffFactor >= 1 {
+		return errCongestionControlBackoffFactor
+	}
+	return nil
+}
+
+// usesCongestionControl tells whether the requests to the object store type are congestion controlled.
+func usesCongestionControl(name string, cfg Config) bool {
+	return cfg.CongestionControl.Enabled && isObjectStore(name)
+}
+
+var (
+	congestionControllersMtx sync.Mutex
+	// the object clients of a store type share its controller, since the store throttles them all.
+	congestionControllers = map[string]*congestionController{}
+)
+
+func newCongestionControlledObjectClient(client chunk.ObjectClient, name string, cfg CongestionControlConfig) chunk.ObjectClient {
+	congestionControllersMtx.Lock()
+	defer congestionControllersMtx.Unlock()
+
+	controller, ok := congestionControllers[name]
+	if !ok {
+		controller = newCongestionController(name, cfg)
+		congestionControllers[name] = controller
+	}
+	return &congestionControlledObjectClient{ObjectClient: client, controller: controller}
+}
+
+// congestionController limits the number of concurrent requests to an object store with an additive increase and a
+// multiplicative decrease of the limit. The throttled requests started before the last decrease don't decrease the
+// limit again, since they were sent with the previous limit.
+type congestionController struct {
+	minLimit, maxLimit float64
+	backoffFactor      float64
+
+	limitGauge    prometheus.Gauge
+	inflightGauge prometheus.Gauge
+	throttled     prometheus.Counter
+
+	mtx          sync.Mutex
+	limit        float64
+	inflight     int
+	waiters      []chan struct{}
+	lastDecrease time.Time
+}
+
+func newCongestionController(name string, cfg CongestionControlConfig) *congestionController {
+	c := &congestionController{
+		minLimit:      float64(cfg.MinLimit),
+		maxLimit:      float64(cfg.MaxLimit),
+		backoffFactor: cfg.BackoffFactor,
+		limitGauge:    congestionControlLimit.WithLabelValues(name),
+		inflightGauge: congestionControlInflight.WithLabelValues(name),
+		throttled:     congestionControlThrottledTotal.WithLabelValues(name),
+		limit:         float64(cfg.InitialLimit),
+	}
+	if c.limit < c.minLimit {
+		c.limit = c.minLimit
+	}
+	if c.limit > c.maxLimit {
+		c.limit = c.maxLimit
+	}
+	c.limitGauge.Set(c.limit)
+	return c
+}
+
+// acquire waits for a request to be allowed and returns when it was.
+func (c *congestionController) acquire(ctx context.Context) (time.Time, error) {
+	c.mtx.Lock()
+	if c.inflight < int(c.limit) {
+		c.inflight++
+		c.inflightGauge.Set(float64(c.inflight))
+		c.mtx.Unlock()
+		return time.Now(), nil
+	}
+	ready := make(chan struct{})
+	c.waiters = append(c.waiters, ready)
+	c.mtx.Unlock()
+
+	select {
+	case <-ready:
+		return time.Now(), nil
+	case <-ctx.Done():
+		c.mtx.Lock()
+		defer c.mtx.Unlock()
+		for i, w := range c.waiters {
+			if w == ready {
+				c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+				return time.Time{}, ctx.Err()
+			}
+		}
+		// the request was allowed meanwhile, let another one go.
+		c.inflight--
+		c.wakeUp()
+		return time.Time{}, ctx.Err()
+	}
+}
+
+// release adapts the limit to the result of the request allowed at start.
+func (c *congestionController) release(start time.Time, err error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.inflight--
+	switch {
+	case isThrottled(err):
+		c.throttled.Inc()
+		if start.After(c.lastDecrease) {
+			c.limit *= c.backoffFactor
+			if c.limit < c.minLimit {
+				c.limit = c.minLimit
+			}
+			c.lastDecrease = time.Now()
+		}
+	case err == nil:
+		c.limit += 1 / c.limit
+		if c.limit > c.maxLimit {
+			c.limit = c.maxLimit
+		}
+	}
+	c.limitGauge.Set(c.limit)
+	c.wakeUp()
+}
+
+// wakeUp allows the waiting requests the limit has room for, it must be called with the lock held.
+func (c *congestionController) wakeUp() {
+	for len(c.waiters) > 0 && c.inflight < int(c.limit) {
+		close(c.waiters[0])
+		c.waiters = c.waiters[1:]
+		c.inflight++
+	}
+	c.inflightGauge.Set(float64(c.inflight))
+}
+
+// isThrottled tells whether the object store refused the request because of its rate: S3 and the S3 compatible stores
+// respond 503 SlowDown, GCS 429 and Azure 503 ServerBusy.
+func isThrottled(err error) bool {
+	if err == nil {
+		return false
+	}
+	if code := statusCode(err); code != 0 {
+		return code == http.StatusServiceUnavailable || code == http.StatusTooManyRequests
+	}
+
+	msg := err.Error()
+	for _, s := range []string{"SlowDown", "ServerBusy", "Error 429", "Error 503", "rateLimitExceeded"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// statusCode returns the status code of the response of the object store to the request which failed with the error,
+// 0 when it is unknown.
+func statusCode(err error) int {
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) {
+		return reqErr.StatusCode()
+	}
+	var respErr interface{ Response() *http.Response }
+	if errors.As(err, &respErr) && respErr.Response() != nil {
+		return respErr.Response().StatusCode
+	}
+	return 0
+}
+
+// congestionControlledObjectClient sends the requests of an object client allowed by the controller of its store.
+// A GET is done once its response started, reading its body doesn't hold the request.
+type congestionControlledObjectClient struct {
+	chunk.ObjectClient
+	controller *congestionController
+}
+
+func (c *congestionControlledObjectClient) PutObject(ctx context.Context, objectKey string, object io.ReadSeeker) error {
+	start, err := c.controller.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	err = c.ObjectClient.PutObject(ctx, objectKey, object)
+	c.controller.release(start, err)
+	return err
+}
+
+func (c *congestionControlledObjectClient) GetObject(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	start, err := c.controller.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.ObjectClient.GetObject(ctx, objectKey)
+	c.controller.release(start, err)
+	return body, err
+}
+
+func (c *congestionControlledObjectClient) List(ctx context.Context, prefix, delimiter string) ([]chunk.StorageObject, []chunk.StorageCommonPrefix, error) {
+	start, err := c.controller.acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	objects, prefixes, err := c.ObjectClient.List(ctx, prefix, delimiter)
+	c.controller.release(start, err)
+	return objects, prefixes, err
+}
+
+func (c *congestionControlledObjectClient) DeleteObject(ctx context.Context, objectKey string) error {
+	start, err := c.controller.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	err = c.ObjectClient.DeleteObject(ctx, objectKey)
+	c.controller.release(start, err)
+	return err
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+var errSlowDown = awserr.NewRequestFailure(awserr.New("SlowDown", "Please reduce your request rate.", nil), 503, "id")
+
+// throttlingObjectClient fails the GETs with the error.
+type throttlingObjectClient struct {
+	chunk.ObjectClient
+	err error
+}
+
+func (c *throttlingObjectClient) GetObject(_ context.Context, _ string) (io.ReadCloser, error) {
+	return nil, c.err
+}
+
+func TestCongestionControlledObjectClient(t *testing.T) {
+	controller := newCongestionController("test", CongestionControlConfig{InitialLimit: 8, MinLimit: 2, MaxLimit: 9, BackoffFactor: 0.5})
+	store := &throttlingObjectClient{ObjectClient: chunk.NewMockStorage()}
+	client := &congestionControlledObjectClient{ObjectClient: store, controller: controller}
+
+	// the limit grows by about one once as many requests as allowed succeeded, up to the max limit.
+	for i := 0; i < 8; i++ {
+		_, _ = client.GetObject(context.Background(), "key")
+	}
+	require.InDelta(t, 9, controller.limit, 0.1)
+	for i := 0; i < 20; i++ {
+		_, _ = client.GetObject(context.Background(), "key")
+	}
+	require.Equal(t, float64(9), testutil.ToFloat64(controller.limitGauge))
+
+	// the limit is divided when the store throttles requests, down to the min limit.
+	store.err = errSlowDown
+	throttled := testutil.ToFloat64(controller.throttled)
+	for i := 0; i < 3; i++ {
+		_, err := client.GetObject(context.Background(), "key")
+		require.Equal(t, errSlowDown, err)
+	}
+	require.Equal(t, float64(2), controller.limit)
+	require.Equal(t, throttled+3, testutil.ToFloat64(controller.throttled))
+
+	// the other errors don't change the limit.
+	store.err = errors.New("not found")
+	_, _ = client.GetObject(context.Background(), "key")
+	require.Equal(t, float64(2), controller.limit)
+}
+
+func TestCongestionController_Acquire(t *testing.T) {
+	controller := newCongestionController("test", CongestionControlConfig{InitialLimit: 2, MinLimit: 1, MaxLimit: 10, BackoffFactor: 0.5})
+
+	first, err := controller.acquire(context.Background())
+	require.NoError(t, err)
+	second, err := controller.acquire(context.Background())
+	require.NoError(t, err)
+
+	// the requests above the limit wait for one to be done.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = controller.acquire(ctx)
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		start, err := controller.acquire(context.Background())
+		require.NoError(t, err)
+		controller.release(start, nil)
+	}()
+
+	// the throttled requests sent before the limit was decreased don't decrease it again.
+	controller.release(first, errSlowDown)
+	require.Equal(t, float64(1), controller.limit)
+	controller.release(second, errSlowDown)
+	require.Equal(t, float64(1), controller.limit)
+
+	<-done
+	require.Equal(t, 0, controller.inflight)
+}
+
+func TestIsThrottled(t *testing.T) {
+	require.True(t, isThrottled(errSlowDown))
+	require.True(t, isThrottled(errors.New("googleapi: Error 429: rate limit exceeded")))
+	require.False(t, isThrottled(awserr.NewRequestFailure(awserr.New("NoSuchKey", "not found", nil), 404, "id")))
+	require.False(t, isThrottled(errors.New("not found")))
+	require.False(t, isThrottled(nil))
+}
+
+func TestCongestionControlConfig_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cfg      CongestionControlConfig
+		expected error
+	}{
+		{name: "disabled", cfg: CongestionControlConfig{}},
+		{name: "valid", cfg: CongestionControlConfig{Enabled: true, MinLimit: 4, MaxLimit: 1000, BackoffFactor: 0.5}},
+		{name: "limits", cfg: CongestionControlConfig{Enabled: true, MinLimit: 10, MaxLimit: 5, BackoffFactor: 0.5}, expected: errCongestionControlLimits},
+		{name: "backoff factor", cfg: CongestionControlConfig{Enabled: true, MinLimit: 4, MaxLimit: 1000, BackoffFactor: 1}, expected: errCongestionControlBackoffFactor},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.cfg.Validate())
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package embeddedcache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"flag"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/famarks/loki/pkg/util/flagext"
+)
+
+const (
+	// entryOverheadBytes is the memory used by an entry besides its key and value: the entry itself, its element in the
+	// FIFO list and its pointer in the map.
+	entryOverheadBytes = uint64(unsafe.Sizeof(entry{})) + uint64(unsafe.Sizeof(list.Element{})) + uint64(unsafe.Sizeof(&list.Element{}))
+
+	reasonExpired = "expired"
+	reasonFull    = "full"
+)
+
+var errNoMaxSize = errors.New("the max size of the embedded cache must be set when it is enabled")
+
+// Config configures the embedded cache.
+type Config struct {
+	Enabled bool             `yaml:"enabled"`
+	MaxSize flagext.ByteSize `yaml:"max_size"`
+	TTL     time.Duration    `yaml:"ttl"`
+}
+
+// RegisterFlagsWithPrefix registers flags.
+func (cfg *Config) RegisterFlagsWithPrefix(prefix, description string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+"embedded-cache.enabled", false, description+"Cache in the memory of the process, without running a cache server.")
+	cfg.MaxSize = 100 << 20
+	f.Var(&cfg.MaxSize, prefix+"embedded-cache.max-size", description+"Maximum memory used by the keys and values of the cached entries, i.e. 100MB. The oldest entries are evicted when it is reached.")
+	f.DurationVar(&cfg.TTL, prefix+"embedded-cache.ttl", time.Hour, description+"How long entries are kept in the cache. 0 to keep them until evicted for space.")
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if cfg.Enabled && cfg.MaxSize == 0 {
+		return errNoMaxSize
+	}
+	return nil
+}
+
+type entry struct {
+	key     string
+	value   []byte
+	addedAt time.Time
+}
+
+func (e *entry) size() uint64 {
+	return uint64(len(e.key)+len(e.value)) + entryOverheadBytes
+}
+
+// Cache is an in-memory FIFO cache bounded by the memory used by its entries rather than their number.
+// It implements the Cortex cache.Cache interface.
+type Cache struct {
+	maxSizeBytes uint64
+	ttl          time.Duration
+	now          func() time.Time
+
+	mtx       sync.Mutex
+	sizeBytes uint64
+	entries   map[string]*list.Element
+	// fifo has the newest entries at the front.
+	fifo *list.List
+
+	entriesCount prometheus.Gauge
+	memoryBytes  prometheus.Gauge
+	evictions    *prometheus.CounterVec
+	gets         prometheus.Counter
+	misses       prometheus.Counter
+}
+
+// New makes a new Cache, its metrics are labelled with the name of the cache.
+func New(name string, cfg Config, reg prometheus.Registerer) *Cache {
+	constLabels := prometheus.Labels{"cache": name}
+	return &Cache{
+		maxSizeBytes: uint64(cfg.MaxSize),
+		ttl:          cfg.TTL,
+		now:          time.Now,
+		entries:      map[string]*list.Element{},
+		fifo:         list.New(),
+
+		entriesCount: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Namespace:   "loki",
+			Name:        "embedded_cache_entries",
+			Help:        "The number of entries in the embedded cache.",
+			ConstLabels: constLabels,
+		}),
+		memoryBytes: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Namespace:   "loki",
+			Name:        "embedded_cache_memory_bytes",
+			Help:        "The memory used by the entries of the embedded cache.",
+			ConstLabels: constLabels,
+		}),
+		evictions: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "loki",
+			Name:        "embedded_cache_evicted_total",
+			Help:        "The total number of entries evicted from the embedded cache, by reason.",
+			ConstLabels: constLabels,
+		}, []string{"reason"}),
+		gets: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace:   "loki",
+			Name:        "embedded_cache_gets_total",
+			Help:        "The total number of keys fetched from the embedded cache.",
+			ConstLabels: constLabels,
+		}),
+		misses: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace:   "loki",
+			Name:        "embedded_cache_misses_total",
+			Help:        "The total number of keys fetched from the embedded cache which were missing or expired.",
+			ConstLabels: constLabels,
+		}),
+	}
+}
+
+// Store implements cache.Cache. The values are copied so that the cache doesn't retain the buffers of the callers,
+// whose capacity isn't accounted for.
+func (c *Cache) Store(_ context.Context, keys []string, bufs [][]byte) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	now := c.now()
+	for i, key := range keys {
+		if elem, ok := c.entries[key]; ok {
+			c.remove(elem)
+		}
+
+		e := &entry{key: key, value: append([]byte(nil), bufs[i]...), addedAt: now}
+		if e.size() > c.maxSizeBytes {
+			continue
+		}
+
+		c.evictExpired(now)
+		for c.sizeBytes+e.size() > c.maxSizeBytes {
+			c.remove(c.fifo.Back())
+			c.evictions.WithLabelValues(reasonFull).Inc()
+		}
+
+		c.entries[key] = c.fifo.PushFront(e)
+		c.sizeBytes += e.size()
+	}
+	c.updateMetrics()
+}
+
+// Fetch implements cache.Cache.
+func (c *Cache) Fetch(_ context.Context, keys []string) (found []string, bufs [][]byte, missing []string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.gets.Add(float64(len(keys)))
+
+	now := c.now()
+	for _, key := range keys {
+		elem, ok := c.entries[key]
+		if !ok {
+			missing = append(missing, key)
+			continue
+		}
+
+		e := elem.Value.(*entry)
+		if c.expired(e, now) {
+			c.remove(elem)
+			c.evictions.WithLabelValues(reasonExpired).Inc()
+			missing = append(missing, key)
+			continue
+		}
+		found = append(found, key)
+		bufs = append(bufs, e.value)
+	}
+
+	c.misses.Add(float64(len(missing)))
+	c.updateMetrics()
+	return
+}
+
+// Stop implements cache.Cache.
+func (c *Cache) Stop() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.entries = map[string]*list.Element{}
+	c.fifo.Init()
+	c.sizeBytes = 0
+	c.updateMetrics()
+}
+
+// evictExpired evicts the expired entries, which are the oldest ones since they all have the same TTL.
+func (c *Cache) evictExpired(now time.Time) {
+	for elem := c.fifo.Back(); elem != nil && c.expired(elem.Value.(*entry), now); elem = c.fifo.Back() {
+		c.remove(elem)
+		c.evictions.WithLabelValues(reasonExpired).Inc()
+	}
+}
+
+func (c *Cache) expired(e *entry, now time.Time) bool {
+	return c.ttl > 0 && now.Sub(e.addedAt) >= c.ttl
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	e := c.fifo.Remove(elem).(*entry)
+	delete(c.entries, e.key)
+	c.sizeBytes -= e.size()
+}
+
+func (c *Cache) updateMetrics() {
+	c.entriesCount.Set(float64(len(c.entries)))
+	c.memoryBytes.Set(float64(c.sizeBytes))
+}
@@ -0,0 +1,74 @@
+package embeddedcache
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	value := make([]byte, 100)
+	entrySize := (&entry{key: "key-0", value: value}).size()
+
+	now := time.Unix(0, 0)
+	c := New("test", Config{MaxSize: 3 * 1024 * 1024, TTL: time.Hour}, prometheus.NewRegistry())
+	c.maxSizeBytes = 3 * entrySize
+	c.now = func() time.Time { return now }
+
+	store := func(ids ...int) {
+		for _, id := range ids {
+			c.Store(ctx, []string{"key-" + strconv.Itoa(id)}, [][]byte{value})
+		}
+	}
+	fetch := func(ids ...int) (found []string) {
+		var keys []string
+		for _, id := range ids {
+			keys = append(keys, "key-"+strconv.Itoa(id))
+		}
+		found, _, _ = c.Fetch(ctx, keys)
+		return found
+	}
+
+	// the oldest entries are evicted when the cache is full.
+	store(0, 1, 2)
+	require.Equal(t, 3*entrySize, c.sizeBytes)
+	store(3)
+	require.Equal(t, []string{"key-1", "key-2", "key-3"}, fetch(0, 1, 2, 3))
+	require.Equal(t, float64(1), testutil.ToFloat64(c.evictions.WithLabelValues(reasonFull)))
+	require.Equal(t, 3*entrySize, c.sizeBytes)
+	require.Equal(t, float64(3*entrySize), testutil.ToFloat64(c.memoryBytes))
+
+	// storing an entry again replaces it.
+	store(1)
+	require.Equal(t, 3*entrySize, c.sizeBytes)
+	require.Equal(t, float64(3), testutil.ToFloat64(c.entriesCount))
+
+	// entries bigger than the cache are not stored.
+	c.Store(ctx, []string{"big"}, [][]byte{make([]byte, 4*entrySize)})
+	require.Empty(t, fetch())
+	require.Len(t, c.entries, 3)
+
+	// expired entries are missing, and evicted before the ones which are still valid.
+	now = now.Add(30 * time.Minute)
+	store(4)
+	require.Equal(t, []string{"key-3", "key-1", "key-4"}, fetch(2, 3, 1, 4))
+
+	now = now.Add(45 * time.Minute)
+	require.Equal(t, []string{"key-4"}, fetch(3, 4))
+	require.Equal(t, float64(1), testutil.ToFloat64(c.evictions.WithLabelValues(reasonExpired)))
+
+	store(5)
+	require.Equal(t, float64(2), testutil.ToFloat64(c.evictions.WithLabelValues(reasonExpired)))
+	require.Equal(t, []string{"key-4", "key-5"}, fetch(1, 4, 5))
+	require.Equal(t, 2*entrySize, c.sizeBytes)
+
+	c.Stop()
+	require.Empty(t, fetch(4, 5))
+	require.Equal(t, uint64(0), c.sizeBytes)
+}
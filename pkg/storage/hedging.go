@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// hedgingWindow is the number of the latest GETs whose latency gives the delay of the hedged requests.
+	hedgingWindow = 1000
+	// hedgingUpdateEvery is the number of GETs after which the delay of the hedged requests is computed again.
+	hedgingUpdateEvery = 100
+)
+
+var (
+	errHedgingQuantile     = errors.New("the quantile of the hedged requests must be between 0 and 1")
+	errHedgingMaxPerSecond = errors.New("the max hedged requests per second must be positive")
+)
+
+var (
+	hedgedRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "loki",
+		Subsystem: "store",
+		Name:      "hedged_requests_total",
+		Help:      "Number of hedged GETs sent to the object store.",
+	}, []string{"store"})
+	hedgedRequestsWinsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "loki",
+		Subsystem: "store",
+		Name:      "hedged_requests_wins_total",
+		Help:      "Number of hedged GETs to the object store which returned before the requests they hedged.",
+	}, []string{"store"})
+	hedgedRequestsRateLimitedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "loki",
+		Subsystem: "store",
+		Name:      "hedged_requests_rate_limited_total",
+		Help:      "Number of hedged GETs to the object store not sent because of the max hedged requests per second.",
+	}, []string{"store"})
+)
+
+// HedgingConfig configures the hedging of the GETs of the chunks and the index files to the object stores: a second
+// request is sent when the first one takes longer than the quantile of the latency of the recent ones.
+type HedgingConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	Quantile     float64       `yaml:"quantile"`
+	MinDelay     time.Duration `yaml:"min_delay"`
+	MaxPerSecond float64       `yaml:"max_per_second"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *HedgingConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "store.hedging.enabled", false, "Send a second GET of the chunks and the index files to the object stores when the first one is slower than most of the recent ones.")
+	f.Float64Var(&cfg.Quantile, "store.hedging.quantile", 0.99, "Quantile of the latency of the recent GETs after which a hedged GET is sent.")
+	f.DurationVar(&cfg.MinDelay, "store.hedging.min-delay", 10*time.Millisecond, "Minimum delay after which a hedged GET is sent.")
+	f.Float64Var(&cfg.MaxPerSecond, "store.hedging.max-per-second", 10, "Maximum number of hedged GETs sent per second, for the hedging not to overload the object stores when they are all slow.")
+}
+
+// Validate validates the config.
+func (cfg *HedgingConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Quantile <= 0 || cfg.Quantile >= 1 {
+		return errHedgingQuantile
+	}
+	if cfg.MaxPerSecond <= 0 {
+		return errHedgingMaxPerSecond
+	}
+	return nil
+}
+
+// usesHedging tells whether the GETs of the object store type are hedged.
+func usesHedging(name string, cfg Config) bool {
+	return cfg.Hedging.Enabled && isObjectStore(name)
+}
+
+// hedgedObjectClient hedges the GETs of an object client. No request is hedged until the latency of enough of them is
+// known, and the requests failing before their hedged request is sent are not sent again.
+type hedgedObjectClient struct {
+	chunk.ObjectClient
+	quantile float64
+	minDelay time.Duration
+	limiter  *rate.Limiter
+
+	hedged      prometheus.Counter
+	wins        prometheus.Counter
+	rateLimited prometheus.Counter
+
+	mtx       sync.Mutex
+	latencies []time.Duration
+	next      int
+	observed  int
+	delay     time.Duration
+}
+
+func newHedgedObjectClient(client chunk.ObjectClient, name string, cfg HedgingConfig) *hedgedObjectClient {
+	return &hedgedObjectClient{
+		ObjectClient: client,
+		quantile:     cfg.Quantile,
+		minDelay:     cfg.MinDelay,
+		limiter:      rate.NewLimiter(rate.Limit(cfg.MaxPerSecond), 1),
+		hedged:       hedgedRequestsTotal.WithLabelValues(name),
+		wins:         hedgedRequestsWinsTotal.WithLabelValues(name),
+		rateLimited:  hedgedRequestsRateLimitedTotal.WithLabelValues(name),
+		latencies:    make([]time.Duration, 0, hedgingWindow),
+	}
+}
+
+type getResult struct {
+	body   io.ReadCloser
+	err    error
+	hedged bool
+}
+
+func (c *hedgedObjectClient) GetObject(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	delay := c.hedgingDelay()
+	// the requests are only hedged once the delay is known.
+	if delay == 0 {
+		start := time.Now()
+		body, err := c.ObjectClient.GetObject(ctx, objectKey)
+		if err == nil {
+			c.observe(time.Since(start))
+		}
+		return body, err
+	}
+
+	results := make(chan getResult, 2)
+	cancels := make([]context.CancelFunc, 0, 2)
+	get := func(hedged bool) {
+		getCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		go func() {
+			body, err := c.ObjectClient.GetObject(getCtx, objectKey)
+			results <- getResult{body: body, err: err, hedged: hedged}
+		}()
+	}
+
+	start := time.Now()
+	get(false)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	pending := 1
+	for {
+		select {
+		case <-timer.C:
+			if !c.limiter.Allow() {
+				c.rateLimited.Inc()
+				continue
+			}
+			c.hedged.Inc()
+			get(true)
+			pending++
+		case res := <-results:
+			pending--
+			if res.err != nil && pending > 0 {
+				continue
+			}
+
+			// the other request is canceled and its body closed, if it returns.
+			winner := len(cancels) - 1
+			if !res.hedged {
+				winner = 0
+			}
+			for i, cancel := range cancels {
+				if i != winner {
+					cancel()
+				}
+			}
+			if pending > 0 {
+				go drainGetResults(results, pending)
+			}
+
+			if res.err != nil {
+				cancels[winner]()
+				return nil, res.err
+			}
+			if res.hedged {
+				c.wins.Inc()
+			} else {
+				c.observe(time.Since(start))
+			}
+			return &cancelingReadCloser{ReadCloser: res.body, cancel: cancels[winner]}, nil
+		}
+	}
+}
+
+// drainGetResults closes the bodies of the requests which lost.
+func drainGetResults(results chan getResult, pending int) {
+	for ; pending > 0; pending-- {
+		if res := <-results; res.err == nil {
+			_ = res.body.Close()
+		}
+	}
+}
+
+// hedgingDelay returns the delay after which a request is hedged, 0 while the latency of too few requests is known.
+func (c *hedgedObjectClient) hedgingDelay() time.Duration {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.delay
+}
+
+// observe records the latency of a request which returned before its hedged request, if any.
+func (c *hedgedObjectClient) observe(latency time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if len(c.latencies) < hedgingWindow {
+		c.latencies = append(c.latencies, latency)
+	} else {
+		c.latencies[c.next] = latency
+		c.next = (c.next + 1) % hedgingWindow
+	}
+
+	c.observed++
+	if c.observed%hedgingUpdateEvery != 0 {
+		return
+	}
+
+	sorted := make([]time.Duration, len(c.latencies))
+	copy(sorted, c.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	c.delay = sorted[int(c.quantile*float64(len(sorted)-1))]
+	if c.delay < c.minDelay {
+		c.delay = c.minDelay
+	}
+}
+
+// cancelingReadCloser cancels the context of the request whose body it reads when closed.
+type cancelingReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelingReadCloser) Close() error {
+	defer r.cancel()
+	return r.ReadCloser.Close()
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// slowObjectClient delays the GETs by the given latencies, in turn.
+type slowObjectClient struct {
+	chunk.ObjectClient
+
+	mtx       sync.Mutex
+	latencies []time.Duration
+	gets      int
+}
+
+func (c *slowObjectClient) GetObject(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	c.mtx.Lock()
+	latency := c.latencies[c.gets%len(c.latencies)]
+	c.gets++
+	c.mtx.Unlock()
+
+	select {
+	case <-time.After(latency):
+		return ioutil.NopCloser(bytes.NewReader([]byte(objectKey))), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestHedgedObjectClient(t *testing.T) {
+	slow := &slowObjectClient{ObjectClient: chunk.NewMockStorage(), latencies: []time.Duration{time.Second, time.Millisecond}}
+	client := newHedgedObjectClient(slow, "test", HedgingConfig{Quantile: 0.99, MinDelay: 10 * time.Millisecond, MaxPerSecond: 1})
+
+	// the requests aren't hedged until the latency of enough of them is known.
+	for i := 0; i < hedgingUpdateEvery; i++ {
+		client.observe(time.Duration(i) * time.Millisecond)
+	}
+	require.Equal(t, 98*time.Millisecond, client.hedgingDelay())
+	client.delay = 10 * time.Millisecond
+
+	// the hedged request returns first.
+	wins := testutil.ToFloat64(client.wins)
+	start := time.Now()
+	body, err := client.GetObject(context.Background(), "key")
+	require.NoError(t, err)
+	require.Less(t, int64(time.Since(start)), int64(time.Second))
+	content, err := ioutil.ReadAll(body)
+	require.NoError(t, err)
+	require.Equal(t, "key", string(content))
+	require.NoError(t, body.Close())
+	require.Equal(t, wins+1, testutil.ToFloat64(client.wins))
+
+	// the hedged requests are rate limited.
+	limited := testutil.ToFloat64(client.rateLimited)
+	_, err = client.GetObject(context.Background(), "key")
+	require.NoError(t, err)
+	require.Equal(t, limited+1, testutil.ToFloat64(client.rateLimited))
+}
+
+func TestHedgingConfig_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cfg      HedgingConfig
+		expected error
+	}{
+		{name: "disabled", cfg: HedgingConfig{}},
+		{name: "valid", cfg: HedgingConfig{Enabled: true, Quantile: 0.99, MaxPerSecond: 10}},
+		{name: "quantile", cfg: HedgingConfig{Enabled: true, Quantile: 1, MaxPerSecond: 10}, expected: errHedgingQuantile},
+		{name: "rate", cfg: HedgingConfig{Enabled: true, Quantile: 0.99}, expected: errHedgingMaxPerSecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.cfg.Validate())
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var objectStoreRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "loki",
+	Subsystem: "store",
+	Name:      "object_store_request_duration_seconds",
+	Help:      "Time spent by the requests to the object store by operation and status code.",
+	Buckets:   prometheus.ExponentialBuckets(0.005, 4, 7),
+}, []string{"store", "operation", "status_code"})
+
+// InstrumentationConfig configures the instrumentation of the requests to the object stores.
+type InstrumentationConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *InstrumentationConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "store.instrumentation.enabled", false, "Record the duration and the status code of every request to the object stores by operation, and trace them with the key of their object.")
+}
+
+// usesInstrumentation tells whether the requests to the object store type are instrumented.
+func usesInstrumentation(name string, cfg Config) bool {
+	return cfg.Instrumentation.Enabled && isObjectStore(name)
+}
+
+// instrumentedObjectClient records the duration of the requests of an object client and traces them. The requests are
+// the ones sent to the store, so the hedged GETs are recorded on their own and the requests waiting for the congestion
+// control aren't recorded until they are sent. A GET is done once its response started.
+type instrumentedObjectClient struct {
+	chunk.ObjectClient
+	name string
+}
+
+func newInstrumentedObjectClient(client chunk.ObjectClient, name string) *instrumentedObjectClient {
+	return &instrumentedObjectClient{ObjectClient: client, name: name}
+}
+
+func (c *instrumentedObjectClient) PutObject(ctx context.Context, objectKey string, object io.ReadSeeker) error {
+	return c.instrument(ctx, "PutObject", "key", objectKey, func(ctx context.Context) error {
+		return c.ObjectClient.PutObject(ctx, objectKey, object)
+	})
+}
+
+func (c *instrumentedObjectClient) GetObject(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	var body io.ReadCloser
+	err := c.instrument(ctx, "GetObject", "key", objectKey, func(ctx context.Context) error {
+		var err error
+		body, err = c.ObjectClient.GetObject(ctx, objectKey)
+		return err
+	})
+	return body, err
+}
+
+func (c *instrumentedObjectClient) List(ctx context.Context, prefix, delimiter string) ([]chunk.StorageObject, []chunk.StorageCommonPrefix, error) {
+	var (
+		objects  []chunk.StorageObject
+		prefixes []chunk.StorageCommonPrefix
+	)
+	err := c.instrument(ctx, "List", "prefix", prefix, func(ctx context.Context) error {
+		var err error
+		objects, prefixes, err = c.ObjectClient.List(ctx, prefix, delimiter)
+		return err
+	})
+	return objects, prefixes, err
+}
+
+func (c *instrumentedObjectClient) DeleteObject(ctx context.Context, objectKey string) error {
+	return c.instrument(ctx, "DeleteObject", "key", objectKey, func(ctx context.Context) error {
+		return c.ObjectClient.DeleteObject(ctx, objectKey)
+	})
+}
+
+func (c *instrumentedObjectClient) instrument(ctx context.Context, operation, tag, value string, f func(context.Context) error) error {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, "ObjectStore."+operation)
+	ext.SpanKindRPCClient.Set(sp)
+	sp.SetTag("store", c.name)
+	sp.SetTag(tag, value)
+
+	start := time.Now()
+	err := f(ctx)
+	objectStoreRequestDuration.WithLabelValues(c.name, operation, statusCodeLabel(err)).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			ext.Error.Set(sp, true)
+		}
+		sp.LogFields(otlog.Error(err))
+	}
+	sp.Finish()
+	return err
+}
+
+// statusCodeLabel returns the status code of the request which returned the error, 500 when the store didn't respond
+// with one.
+func statusCodeLabel(err error) string {
+	switch {
+	case err == nil:
+		return "200"
+	case errors.Is(err, chunk.ErrStorageObjectNotFound):
+		return "404"
+	case errors.Is(err, context.Canceled):
+		return "cancel"
+	}
+	if code := statusCode(err); code != 0 {
+		return strconv.Itoa(code)
+	}
+	return "500"
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentedObjectClient(t *testing.T) {
+	client := newInstrumentedObjectClient(chunk.NewMockStorage(), "instrumented")
+
+	require.NoError(t, client.PutObject(context.Background(), "key", bytes.NewReader([]byte("data"))))
+	body, err := client.GetObject(context.Background(), "key")
+	require.NoError(t, err)
+	require.NoError(t, body.Close())
+	_, err = client.GetObject(context.Background(), "missing")
+	require.Error(t, err)
+
+	for _, tc := range []struct {
+		operation, statusCode string
+	}{
+		{operation: "PutObject", statusCode: "200"},
+		{operation: "GetObject", statusCode: "200"},
+		{operation: "GetObject", statusCode: "404"},
+	} {
+		var m dto.Metric
+		require.NoError(t, objectStoreRequestDuration.WithLabelValues("instrumented", tc.operation, tc.statusCode).(prometheus.Histogram).Write(&m))
+		require.Equal(t, uint64(1), m.GetHistogram().GetSampleCount(), tc)
+	}
+}
+
+func TestStatusCodeLabel(t *testing.T) {
+	require.Equal(t, "200", statusCodeLabel(nil))
+	require.Equal(t, "404", statusCodeLabel(chunk.ErrStorageObjectNotFound))
+	require.Equal(t, "cancel", statusCodeLabel(context.Canceled))
+	require.Equal(t, "503", statusCodeLabel(errSlowDown))
+	require.Equal(t, "403", statusCodeLabel(awserr.NewRequestFailure(awserr.New("AccessDenied", "denied", nil), 403, "id")))
+	require.Equal(t, "500", statusCodeLabel(errors.New("connection reset")))
+}
@@ -0,0 +1,197 @@
+package local
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	cortex_local "github.com/cortexproject/cortex/pkg/chunk/local"
+	pkg_util "github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/famarks/loki/pkg/util/flagext"
+)
+
+// ObjectStoreType is the object store type of the local filesystem.
+const ObjectStoreType = "filesystem"
+
+const (
+	reasonAge  = "age"
+	reasonSize = "size"
+)
+
+// EvictionConfig configures the eviction of the chunks of the filesystem store.
+type EvictionConfig struct {
+	MaxTotalBytes flagext.ByteSize `yaml:"max_total_bytes"`
+	MaxChunkAge   time.Duration    `yaml:"max_chunk_age"`
+	SweepInterval time.Duration    `yaml:"sweep_interval"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *EvictionConfig) RegisterFlags(f *flag.FlagSet) {
+	f.Var(&cfg.MaxTotalBytes, "local.eviction.max-total-bytes", "Maximum total size of the chunks of the filesystem store, the oldest chunks being deleted beyond it. 0 to disable.")
+	f.DurationVar(&cfg.MaxChunkAge, "local.eviction.max-chunk-age", 0, "Maximum age of the chunks of the filesystem store since they were written, older chunks being deleted. 0 to disable.")
+	f.DurationVar(&cfg.SweepInterval, "local.eviction.sweep-interval", 5*time.Minute, "Interval between the sweeps of the filesystem store evicting the chunks.")
+}
+
+// Enabled tells whether the chunks are evicted.
+func (cfg *EvictionConfig) Enabled() bool {
+	return cfg.MaxTotalBytes > 0 || cfg.MaxChunkAge > 0
+}
+
+type evictionMetrics struct {
+	evictedChunks *prometheus.CounterVec
+	evictedBytes  *prometheus.CounterVec
+	chunks        prometheus.Gauge
+	bytes         prometheus.Gauge
+	sweepFailures prometheus.Counter
+}
+
+func newEvictionMetrics(registerer prometheus.Registerer) *evictionMetrics {
+	return &evictionMetrics{
+		evictedChunks: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "filesystem_evicted_chunks_total",
+			Help:      "The total number of chunks evicted from the filesystem store, by reason.",
+		}, []string{"reason"}),
+		evictedBytes: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "filesystem_evicted_bytes_total",
+			Help:      "The total size of the chunks evicted from the filesystem store, by reason.",
+		}, []string{"reason"}),
+		chunks: promauto.With(registerer).NewGauge(prometheus.GaugeOpts{
+			Namespace: "loki",
+			Name:      "filesystem_chunks",
+			Help:      "The number of chunks in the filesystem store after the last sweep.",
+		}),
+		bytes: promauto.With(registerer).NewGauge(prometheus.GaugeOpts{
+			Namespace: "loki",
+			Name:      "filesystem_chunks_bytes",
+			Help:      "The total size of the chunks in the filesystem store after the last sweep.",
+		}),
+		sweepFailures: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "filesystem_sweep_failures_total",
+			Help:      "The total number of failed sweeps of the filesystem store.",
+		}),
+	}
+}
+
+// EvictingFSObjectClient is the filesystem object client of the chunks, evicting them in the background once they are
+// too old or take too much space.
+// The chunks are the files at the root of the directory, the index files being in sub-directories. The index still
+// references the evicted chunks until the retention deletes their index entries, the queries skipping them as missing
+// in the meantime.
+type EvictingFSObjectClient struct {
+	*cortex_local.FSObjectClient
+	directory string
+	cfg       EvictionConfig
+	metrics   *evictionMetrics
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewEvictingFSObjectClient makes a new EvictingFSObjectClient, whose sweeper runs until it is stopped.
+func NewEvictingFSObjectClient(fsCfg cortex_local.FSConfig, cfg EvictionConfig, registerer prometheus.Registerer) (*EvictingFSObjectClient, error) {
+	client, err := cortex_local.NewFSObjectClient(fsCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &EvictingFSObjectClient{
+		FSObjectClient: client,
+		directory:      filepath.Clean(fsCfg.Directory),
+		cfg:            cfg,
+		metrics:        newEvictionMetrics(registerer),
+		quit:           make(chan struct{}),
+	}
+	c.wg.Add(1)
+	go c.loop()
+	return c, nil
+}
+
+// Stop implements chunk.ObjectClient, it stops the sweeper.
+func (c *EvictingFSObjectClient) Stop() {
+	close(c.quit)
+	c.wg.Wait()
+	c.FSObjectClient.Stop()
+}
+
+func (c *EvictingFSObjectClient) loop() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.cfg.SweepInterval)
+	defer ticker.Stop()
+	for {
+		if err := c.sweep(time.Now()); err != nil {
+			c.metrics.sweepFailures.Inc()
+			level.Error(pkg_util.Logger).Log("msg", "failed to sweep the filesystem store", "directory", c.directory, "err", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// sweep evicts the chunks older than the max age, then the oldest ones until their total size is within the max.
+func (c *EvictingFSObjectClient) sweep(now time.Time) error {
+	files, err := ioutil.ReadDir(c.directory)
+	if err != nil {
+		return err
+	}
+
+	chunks := files[:0]
+	for _, file := range files {
+		if file.Mode().IsRegular() {
+			chunks = append(chunks, file)
+		}
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].ModTime().Before(chunks[j].ModTime()) })
+
+	var totalBytes int64
+	for _, chunk := range chunks {
+		totalBytes += chunk.Size()
+	}
+
+	evicted := 0
+	for _, chunk := range chunks {
+		var reason string
+		if c.cfg.MaxChunkAge > 0 && now.Sub(chunk.ModTime()) > c.cfg.MaxChunkAge {
+			reason = reasonAge
+		} else if c.cfg.MaxTotalBytes > 0 && totalBytes > int64(c.cfg.MaxTotalBytes) {
+			reason = reasonSize
+		} else {
+			// the chunks are sorted from the oldest, so the next ones are kept too.
+			break
+		}
+
+		evicted++
+		totalBytes -= chunk.Size()
+		if err := os.Remove(filepath.Join(c.directory, chunk.Name())); err != nil {
+			// the chunk might have been deleted in the meantime, by the sweeper of another client.
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		c.metrics.evictedChunks.WithLabelValues(reason).Inc()
+		c.metrics.evictedBytes.WithLabelValues(reason).Add(float64(chunk.Size()))
+	}
+
+	c.metrics.chunks.Set(float64(len(chunks) - evicted))
+	c.metrics.bytes.Set(float64(totalBytes))
+	if evicted > 0 {
+		level.Info(pkg_util.Logger).Log("msg", "evicted chunks from the filesystem store", "directory", c.directory, "chunks", evicted)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	cortex_local "github.com/cortexproject/cortex/pkg/chunk/local"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, cfg EvictionConfig) (*EvictingFSObjectClient, string, func()) {
+	dir, err := ioutil.TempDir("", "fs-eviction")
+	require.NoError(t, err)
+
+	// the sweeps of the tests are done by hand.
+	cfg.SweepInterval = time.Hour
+	client, err := NewEvictingFSObjectClient(cortex_local.FSConfig{Directory: dir}, cfg, prometheus.NewRegistry())
+	require.NoError(t, err)
+	return client, dir, func() {
+		client.Stop()
+		require.NoError(t, os.RemoveAll(dir))
+	}
+}
+
+// putChunk writes a chunk of the size, written at the time.
+func putChunk(t *testing.T, client *EvictingFSObjectClient, dir, name string, size int, writtenAt time.Time) {
+	require.NoError(t, client.PutObject(context.Background(), name, bytes.NewReader(make([]byte, size))))
+	require.NoError(t, os.Chtimes(filepath.Join(dir, filepath.FromSlash(name)), writtenAt, writtenAt))
+}
+
+func files(t *testing.T, dir string) []string {
+	var names []string
+	require.NoError(t, filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		names = append(names, filepath.ToSlash(rel))
+		return err
+	}))
+	sort.Strings(names)
+	return names
+}
+
+func TestEvictingFSObjectClient_MaxChunkAge(t *testing.T) {
+	client, dir, cleanup := newTestClient(t, EvictionConfig{MaxChunkAge: time.Hour})
+	defer cleanup()
+
+	now := time.Now()
+	putChunk(t, client, dir, "old", 10, now.Add(-2*time.Hour))
+	putChunk(t, client, dir, "recent", 10, now.Add(-time.Minute))
+	// the index files are never evicted.
+	putChunk(t, client, dir, "index/table/file", 10, now.Add(-2*time.Hour))
+
+	require.NoError(t, client.sweep(now))
+	require.Equal(t, []string{"index/table/file", "recent"}, files(t, dir))
+	require.Equal(t, 1.0, testutil.ToFloat64(client.metrics.evictedChunks.WithLabelValues(reasonAge)))
+	require.Equal(t, 10.0, testutil.ToFloat64(client.metrics.evictedBytes.WithLabelValues(reasonAge)))
+	require.Equal(t, 1.0, testutil.ToFloat64(client.metrics.chunks))
+	require.Equal(t, 10.0, testutil.ToFloat64(client.metrics.bytes))
+}
+
+func TestEvictingFSObjectClient_MaxTotalBytes(t *testing.T) {
+	client, dir, cleanup := newTestClient(t, EvictionConfig{MaxTotalBytes: 25, MaxChunkAge: 24 * time.Hour})
+	defer cleanup()
+
+	now := time.Now()
+	putChunk(t, client, dir, "chunk1", 10, now.Add(-3*time.Hour))
+	putChunk(t, client, dir, "chunk2", 10, now.Add(-2*time.Hour))
+	putChunk(t, client, dir, "chunk3", 10, now.Add(-time.Hour))
+	putChunk(t, client, dir, "index/table/file", 100, now.Add(-4*time.Hour))
+
+	require.NoError(t, client.sweep(now))
+	require.Equal(t, []string{"chunk2", "chunk3", "index/table/file"}, files(t, dir))
+	require.Equal(t, 1.0, testutil.ToFloat64(client.metrics.evictedChunks.WithLabelValues(reasonSize)))
+	require.Equal(t, 0.0, testutil.ToFloat64(client.metrics.evictedChunks.WithLabelValues(reasonAge)))
+	require.Equal(t, 20.0, testutil.ToFloat64(client.metrics.bytes))
+
+	// the chunks within the limits are kept.
+	require.NoError(t, client.sweep(now))
+	require.Equal(t, []string{"chunk2", "chunk3", "index/table/file"}, files(t, dir))
+	require.Equal(t, 1.0, testutil.ToFloat64(client.metrics.evictedChunks.WithLabelValues(reasonSize)))
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/chunk/objectclient"
+	"github.com/cortexproject/cortex/pkg/chunk/storage"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/famarks/loki/pkg/storage/alibaba"
+	"github.com/famarks/loki/pkg/storage/azure"
+	"github.com/famarks/loki/pkg/storage/local"
+	"github.com/famarks/loki/pkg/storage/openstack"
+)
+
+// NewObjectClient makes the object client of the object store type. The Azure clients are the Loki ones when
+// authenticating with a connection string or a managed identity, and so are the Swift clients when uploading the objects
+// in segments or putting the chunks in tenant containers, and the S3 clients when encrypting the objects with SSE-KMS.
+// Alibaba Cloud OSS is only supported by Loki. The requests are
+// instrumented and congestion controlled, and the GETs hedged when enabled.
+func NewObjectClient(name string, cfg Config) (chunk.ObjectClient, error) {
+	client, err := newObjectClient(name, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return wrapObjectClient(name, cfg, client), nil
+}
+
+// wrapObjectClient wraps the object client of the object store type with the instrumentation, the congestion control
+// and the hedging. The hedged GETs are instrumented and congestion controlled too.
+func wrapObjectClient(name string, cfg Config, client chunk.ObjectClient) chunk.ObjectClient {
+	if usesInstrumentation(name, cfg) {
+		client = newInstrumentedObjectClient(client, name)
+	}
+	if usesCongestionControl(name, cfg) {
+		client = newCongestionControlledObjectClient(client, name, cfg.CongestionControl)
+	}
+	if usesHedging(name, cfg) {
+		client = newHedgedObjectClient(client, name, cfg.Hedging)
+	}
+	return client
+}
+
+func newObjectClient(name string, cfg Config) (chunk.ObjectClient, error) {
+	switch {
+	case name == alibaba.ObjectStoreType:
+		return alibaba.NewOSSObjectClient(cfg.AlibabaOSSConfig)
+	case usesLokiAzureClient(name, cfg):
+		return azure.NewBlobStorage(cfg.AzureStorageConfig, cfg.AzureBlobConfig)
+	case usesLokiSwiftClient(name, cfg):
+		return openstack.NewSwiftObjectClient(cfg.Swift, cfg.SwiftObjectsConfig, false)
+	case usesSSEKMS(name, cfg):
+		return newSSEKMSObjectClient(cfg.AWSStorageConfig.S3Config, cfg.S3SSEKMS)
+	}
+	return storage.NewObjectClient(name, cfg.Config)
+}
+
+// NewChunkClient makes the chunk client of the object store type, like NewObjectClient. The chunks of the object stores
+// are read from the cold tier too when it is enabled, or stored apart for each tenant with the tenant objects.
+func NewChunkClient(name string, cfg Config, schemaCfg chunk.SchemaConfig, registerer prometheus.Registerer) (chunk.Client, error) {
+	if usesTenantObjects(name, cfg) {
+		return newTenantObjectsChunkClient(name, cfg), nil
+	}
+	client, err := newChunkClient(name, cfg, schemaCfg, registerer)
+	if err != nil || !usesColdTier(name, cfg) {
+		return client, err
+	}
+	cold, err := NewColdTierObjectClient(cfg)
+	if err != nil {
+		client.Stop()
+		return nil, err
+	}
+	return newTieredChunkClient(client, objectclient.NewClient(cold, chunkKeyEncoder(name))), nil
+}
+
+func newChunkClient(name string, cfg Config, schemaCfg chunk.SchemaConfig, registerer prometheus.Registerer) (chunk.Client, error) {
+	var (
+		client chunk.ObjectClient
+		err    error
+	)
+	switch {
+	case name == alibaba.ObjectStoreType:
+		client, err = alibaba.NewOSSObjectClient(cfg.AlibabaOSSConfig)
+	case usesLokiAzureClient(name, cfg):
+		client, err = azure.NewBlobStorage(cfg.AzureStorageConfig, cfg.AzureBlobConfig)
+	case usesLokiSwiftClient(name, cfg):
+		// the keys of the chunks start with their tenant.
+		client, err = openstack.NewSwiftObjectClient(cfg.Swift, cfg.SwiftObjectsConfig, true)
+	case usesSSEKMS(name, cfg):
+		client, err = newSSEKMSObjectClient(cfg.AWSStorageConfig.S3Config, cfg.S3SSEKMS)
+	case usesLokiFSClient(name, cfg):
+		client, err = local.NewEvictingFSObjectClient(cfg.FSConfig, cfg.FSEvictionConfig, registerer)
+	case usesHedging(name, cfg) || usesCongestionControl(name, cfg) || usesInstrumentation(name, cfg):
+		client, err = newObjectClient(name, cfg)
+	default:
+		return storage.NewChunkClient(name, cfg.Config, schemaCfg, registerer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	client = wrapObjectClient(name, cfg, client)
+	// the chunks of the filesystem are files at the root of the directory, like with the Cortex chunk clients.
+	return objectclient.NewClient(client, chunkKeyEncoder(name)), nil
+}
+
+// withBucket returns a copy of the config whose object store of the type stores the objects in the bucket, container or
+// directory.
+func withBucket(cfg Config, name, bucket string) Config {
+	switch name {
+	case "aws", "s3":
+		cfg.AWSStorageConfig.S3Config.BucketNames = bucket
+	case "gcs":
+		cfg.GCSConfig.BucketName = bucket
+	case "azure":
+		cfg.AzureStorageConfig.ContainerName = bucket
+	case "swift":
+		cfg.Swift.ContainerName = bucket
+	case local.ObjectStoreType:
+		cfg.FSConfig.Directory = bucket
+	case alibaba.ObjectStoreType:
+		cfg.AlibabaOSSConfig.Bucket = bucket
+	}
+	return cfg
+}
+
+// usesLokiClient tells whether the chunks of the object store type are stored with a Loki client, which the Cortex
+// chunk store can't be given.
+func usesLokiClient(name string, cfg Config) bool {
+	return name == alibaba.ObjectStoreType || usesLokiAzureClient(name, cfg) || usesLokiSwiftClient(name, cfg) ||
+		usesLokiFSClient(name, cfg) || usesSSEKMS(name, cfg) || usesColdTier(name, cfg) || usesTenantObjects(name, cfg) ||
+		usesHedging(name, cfg) || usesCongestionControl(name, cfg) || usesInstrumentation(name, cfg)
+}
+
+func usesLokiAzureClient(name string, cfg Config) bool {
+	return name == azure.ObjectStoreType && cfg.AzureBlobConfig.Enabled()
+}
+
+func usesLokiSwiftClient(name string, cfg Config) bool {
+	return name == openstack.ObjectStoreType && cfg.SwiftObjectsConfig.Enabled()
+}
+
+// usesLokiFSClient tells whether the chunks are stored with the filesystem chunk client evicting them. The object
+// clients of the index files never evict them.
+func usesLokiFSClient(name string, cfg Config) bool {
+	return name == local.ObjectStoreType && cfg.FSEvictionConfig.Enabled()
+}
@@ -0,0 +1,312 @@
+package openstack
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	cortex_openstack "github.com/cortexproject/cortex/pkg/chunk/openstack"
+	"github.com/ncw/swift"
+
+	"github.com/famarks/loki/pkg/util/flagext"
+)
+
+// ObjectStoreType is the object store type of OpenStack Swift.
+const ObjectStoreType = "swift"
+
+var errSegmentContainerWithoutSegments = errors.New("the swift segment container requires the segment size to be set")
+
+// Config configures the features of the Swift client the Cortex one doesn't have. The other settings of the clients,
+// like the Keystone authentication and the container, are the ones of the Cortex swift config.
+type Config struct {
+	SegmentSize          flagext.ByteSize `yaml:"segment_size"`
+	SegmentContainerName string           `yaml:"segment_container_name"`
+	ContainerPerTenant   bool             `yaml:"container_per_tenant"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.Var(&cfg.SegmentSize, "swift.segment-size", "Size of the segments of the objects uploaded as static large objects, the objects bigger than it being uploaded in segments. 0 to upload all the objects at once.")
+	f.StringVar(&cfg.SegmentContainerName, "swift.segment-container-name", "", "Name of the Swift container to put the segments in, <container>_segments when empty.")
+	f.BoolVar(&cfg.ContainerPerTenant, "swift.container-per-tenant", false, "Put the chunks of each tenant in a container of their own, named <container>-<tenant>. The index files stay in the container. The chunks are listed from all the containers named <container>-*, which must not be used for anything else.")
+}
+
+// Enabled tells whether the clients must be the Loki ones.
+func (cfg *Config) Enabled() bool {
+	return cfg.SegmentSize > 0 || cfg.ContainerPerTenant
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if cfg.SegmentContainerName != "" && cfg.SegmentSize == 0 {
+		return errSegmentContainerWithoutSegments
+	}
+	return nil
+}
+
+// SwiftObjectClient is the OpenStack Swift object client. Unlike the Cortex one, it uploads the big objects in segments
+// and can put the chunks of each tenant in a container of their own.
+type SwiftObjectClient struct {
+	conn             *swift.Connection
+	cfg              cortex_openstack.SwiftConfig
+	objCfg           Config
+	segmentContainer string
+	tenantContainers bool
+
+	// createdContainers holds the tenant containers known to exist.
+	createdContainers sync.Map
+}
+
+// NewSwiftObjectClient makes a new SwiftObjectClient. The tenant containers are only used when tenantContainers is set,
+// for the clients of the chunks whose keys start with their tenant.
+func NewSwiftObjectClient(cfg cortex_openstack.SwiftConfig, objCfg Config, tenantContainers bool) (*SwiftObjectClient, error) {
+	c := &swift.Connection{
+		AuthUrl:  cfg.AuthUrl,
+		ApiKey:   cfg.Password,
+		UserName: cfg.Username,
+		UserId:   cfg.UserId,
+
+		TenantId:       cfg.ProjectID,
+		Tenant:         cfg.ProjectName,
+		TenantDomain:   cfg.ProjectDomainName,
+		TenantDomainId: cfg.ProjectDomainID,
+
+		Domain:   cfg.DomainName,
+		DomainId: cfg.DomainId,
+
+		Region: cfg.RegionName,
+	}
+
+	switch {
+	case cfg.UserDomainName != "":
+		c.Domain = cfg.UserDomainName
+	case cfg.UserDomainID != "":
+		c.DomainId = cfg.UserDomainID
+	}
+
+	if err := c.Authenticate(); err != nil {
+		return nil, err
+	}
+
+	// Ensure the containers are created, no error is returned if they already exist.
+	if err := c.ContainerCreate(cfg.ContainerName, nil); err != nil {
+		return nil, err
+	}
+
+	client := &SwiftObjectClient{
+		conn:             c,
+		cfg:              cfg,
+		objCfg:           objCfg,
+		tenantContainers: tenantContainers && objCfg.ContainerPerTenant,
+	}
+	if objCfg.SegmentSize > 0 {
+		client.segmentContainer = objCfg.SegmentContainerName
+		if client.segmentContainer == "" {
+			client.segmentContainer = cfg.ContainerName + "_segments"
+		}
+		if err := c.ContainerCreate(client.segmentContainer, nil); err != nil {
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// Stop implements chunk.ObjectClient.
+func (s *SwiftObjectClient) Stop() {
+	s.conn.UnAuthenticate()
+}
+
+// GetObject implements chunk.ObjectClient. The large objects are read as a whole.
+func (s *SwiftObjectClient) GetObject(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	container, objectName := s.location(objectKey)
+
+	var buf bytes.Buffer
+	if _, err := s.conn.ObjectGet(container, objectName, &buf, false, nil); err != nil {
+		return nil, notFoundErr(err)
+	}
+	return ioutil.NopCloser(&buf), nil
+}
+
+// PutObject implements chunk.ObjectClient.
+func (s *SwiftObjectClient) PutObject(ctx context.Context, objectKey string, object io.ReadSeeker) error {
+	container, objectName := s.location(objectKey)
+	if err := s.ensureContainer(container); err != nil {
+		return err
+	}
+
+	size, err := objectSize(object)
+	if err != nil {
+		return err
+	}
+	if s.objCfg.SegmentSize == 0 || size <= int64(s.objCfg.SegmentSize) {
+		_, err := s.conn.ObjectPut(container, objectName, object, false, "", "", nil)
+		return err
+	}
+
+	file, err := s.conn.StaticLargeObjectCreate(&swift.LargeObjectOpts{
+		Container:        container,
+		ObjectName:       objectName,
+		ChunkSize:        int64(s.objCfg.SegmentSize),
+		SegmentContainer: s.segmentContainer,
+		// the segments are already as big as the chunk size.
+		NoBuffer: true,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, object); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// List implements chunk.ObjectClient. With the tenant containers, the containers named <container>-<tenant> are listed
+// along with the container, their objects being listed under their tenant like their keys.
+func (s *SwiftObjectClient) List(ctx context.Context, prefix, delimiter string) ([]chunk.StorageObject, []chunk.StorageCommonPrefix, error) {
+	if len(delimiter) > 1 {
+		return nil, nil, fmt.Errorf("delimiter must be a single character but was %s", delimiter)
+	}
+
+	storageObjects, storagePrefixes, err := s.listContainer(s.cfg.ContainerName, "", prefix, delimiter)
+	if err != nil || !s.tenantContainers {
+		return storageObjects, storagePrefixes, err
+	}
+
+	containers, err := s.conn.ContainersAll(&swift.ContainersOpts{Prefix: s.cfg.ContainerName + "-"})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, container := range containers {
+		tenantPrefix := strings.TrimPrefix(container.Name, s.cfg.ContainerName+"-") + "/"
+		containerPrefix := ""
+		switch {
+		case strings.HasPrefix(prefix, tenantPrefix):
+			// the prefix is within the container of the tenant.
+			containerPrefix = prefix[len(tenantPrefix):]
+		case strings.HasPrefix(tenantPrefix, prefix):
+			// all the objects of the tenant are under a common prefix when the delimiter follows the prefix in their
+			// tenant.
+			if idx := strings.Index(tenantPrefix[len(prefix):], delimiter); delimiter != "" && idx >= 0 {
+				if container.Count > 0 {
+					storagePrefixes = append(storagePrefixes, chunk.StorageCommonPrefix(tenantPrefix[:len(prefix)+idx+1]))
+				}
+				continue
+			}
+		default:
+			continue
+		}
+
+		objects, prefixes, err := s.listContainer(container.Name, tenantPrefix, containerPrefix, delimiter)
+		if err != nil {
+			return nil, nil, err
+		}
+		storageObjects = append(storageObjects, objects...)
+		storagePrefixes = append(storagePrefixes, prefixes...)
+	}
+	return storageObjects, storagePrefixes, nil
+}
+
+// listContainer lists the objects of the container under the prefix, their keys starting with keyPrefix.
+func (s *SwiftObjectClient) listContainer(container, keyPrefix, prefix, delimiter string) ([]chunk.StorageObject, []chunk.StorageCommonPrefix, error) {
+	opts := &swift.ObjectsOpts{
+		Prefix: prefix,
+	}
+	if len(delimiter) > 0 {
+		opts.Delimiter = []rune(delimiter)[0]
+	}
+
+	objs, err := s.conn.Objects(container, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var storageObjects []chunk.StorageObject
+	var storagePrefixes []chunk.StorageCommonPrefix
+	for _, obj := range objs {
+		// the pseudo directories have the subdir set.
+		if obj.SubDir != "" {
+			storagePrefixes = append(storagePrefixes, chunk.StorageCommonPrefix(keyPrefix+obj.SubDir))
+			continue
+		}
+
+		storageObjects = append(storageObjects, chunk.StorageObject{
+			Key:        keyPrefix + obj.Name,
+			ModifiedAt: obj.LastModified,
+		})
+	}
+
+	return storageObjects, storagePrefixes, nil
+}
+
+// DeleteObject implements chunk.ObjectClient, deleting the segments of the large objects along with them.
+func (s *SwiftObjectClient) DeleteObject(ctx context.Context, objectKey string) error {
+	container, objectName := s.location(objectKey)
+
+	var err error
+	if s.objCfg.SegmentSize > 0 {
+		err = s.conn.LargeObjectDelete(container, objectName)
+	} else {
+		err = s.conn.ObjectDelete(container, objectName)
+	}
+	return notFoundErr(err)
+}
+
+// location returns the container and the name of the object of a key, the chunks of the tenants being in the container
+// of their tenant.
+func (s *SwiftObjectClient) location(objectKey string) (string, string) {
+	if !s.tenantContainers {
+		return s.cfg.ContainerName, objectKey
+	}
+	idx := strings.IndexByte(objectKey, '/')
+	if idx <= 0 {
+		return s.cfg.ContainerName, objectKey
+	}
+	return s.cfg.ContainerName + "-" + objectKey[:idx], objectKey[idx+1:]
+}
+
+func (s *SwiftObjectClient) ensureContainer(container string) error {
+	if container == s.cfg.ContainerName {
+		return nil
+	}
+	if _, ok := s.createdContainers.Load(container); ok {
+		return nil
+	}
+	if err := s.conn.ContainerCreate(container, nil); err != nil {
+		return err
+	}
+	s.createdContainers.Store(container, struct{}{})
+	return nil
+}
+
+// objectSize returns the size of the object left to read.
+func objectSize(object io.ReadSeeker) (int64, error) {
+	pos, err := object.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	end, err := object.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := object.Seek(pos, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return end - pos, nil
+}
+
+// notFoundErr returns the generic chunk.ErrStorageObjectNotFound error for the missing objects, whose tenant container
+// might not even exist.
+func notFoundErr(err error) error {
+	if err == swift.ObjectNotFound || err == swift.ContainerNotFound {
+		return chunk.ErrStorageObjectNotFound
+	}
+	return err
+}
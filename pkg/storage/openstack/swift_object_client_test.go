@@ -0,0 +1,342 @@
+package openstack
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	cortex_openstack "github.com/cortexproject/cortex/pkg/chunk/openstack"
+	"github.com/stretchr/testify/require"
+)
+
+type swiftSegment struct {
+	Path string `json:"path"`
+	Size int64  `json:"size_bytes"`
+}
+
+// swiftServer is an in memory Swift account authenticating with Keystone v3 and supporting the static large objects.
+type swiftServer struct {
+	mtx        sync.Mutex
+	url        string
+	containers map[string]bool
+	objects    map[string][]byte
+	manifests  map[string][]swiftSegment
+}
+
+func newSwiftServer() (*swiftServer, *httptest.Server) {
+	s := &swiftServer{
+		containers: map[string]bool{},
+		objects:    map[string][]byte{},
+		manifests:  map[string][]swiftSegment{},
+	}
+	httpServer := httptest.NewServer(s)
+	s.url = httpServer.URL
+	return s, httpServer
+}
+
+func (s *swiftServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	switch r.URL.Path {
+	case "/v3/auth/tokens":
+		w.Header().Set("X-Subject-Token", "token")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token":{"catalog":[{"type":"object-store","endpoints":[{"interface":"public","url":"%s/v1/AUTH_test"}]}]}}`, s.url)
+		return
+	case "/info":
+		fmt.Fprint(w, `{"slo":{"min_segment_size":1}}`)
+		return
+	}
+	if r.Header.Get("X-Auth-Token") != "token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.URL.Path == "/v1/AUTH_test" {
+		s.serveAccount(w, r)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v1/AUTH_test/")
+	if !strings.Contains(path, "/") {
+		s.serveContainer(w, r, path)
+		return
+	}
+	if !s.containers[strings.SplitN(path, "/", 2)[0]] {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Query().Get("multipart-manifest") == "put" {
+			var segments []swiftSegment
+			if err := json.Unmarshal(body, &segments); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			s.manifests[path] = segments
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		s.objects[path] = body
+		sum := md5.Sum(body)
+		w.Header().Set("Etag", hex.EncodeToString(sum[:]))
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet, http.MethodHead:
+		if segments, ok := s.manifests[path]; ok {
+			w.Header().Set("X-Static-Large-Object", "True")
+			if r.URL.Query().Get("multipart-manifest") == "get" {
+				var manifest []map[string]interface{}
+				for _, segment := range segments {
+					manifest = append(manifest, map[string]interface{}{"name": "/" + segment.Path, "bytes": segment.Size})
+				}
+				_ = json.NewEncoder(w).Encode(manifest)
+				return
+			}
+			var object []byte
+			for _, segment := range segments {
+				object = append(object, s.objects[segment.Path]...)
+			}
+			s.writeObject(w, r, object)
+			return
+		}
+		object, ok := s.objects[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s.writeObject(w, r, object)
+	case http.MethodDelete:
+		_, isObject := s.objects[path]
+		_, isManifest := s.manifests[path]
+		if !isObject && !isManifest {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.objects, path)
+		delete(s.manifests, path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (s *swiftServer) serveContainer(w http.ResponseWriter, r *http.Request, container string) {
+	switch r.Method {
+	case http.MethodPut:
+		s.containers[container] = true
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet:
+		if !s.containers[container] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		prefix := r.URL.Query().Get("prefix")
+		delimiter := r.URL.Query().Get("delimiter")
+		var objects []map[string]interface{}
+		subdirs := map[string]bool{}
+		for path := range s.objects {
+			name := strings.TrimPrefix(path, container+"/")
+			if !strings.HasPrefix(path, container+"/") || !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			if idx := strings.Index(name[len(prefix):], delimiter); delimiter != "" && idx >= 0 {
+				subdir := name[:len(prefix)+idx+1]
+				if !subdirs[subdir] {
+					subdirs[subdir] = true
+					objects = append(objects, map[string]interface{}{"subdir": subdir})
+				}
+				continue
+			}
+			objects = append(objects, map[string]interface{}{"name": name, "last_modified": "2006-01-02T15:04:05.000000"})
+		}
+		_ = json.NewEncoder(w).Encode(objects)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// serveAccount lists the containers of the account.
+func (s *swiftServer) serveAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	prefix := r.URL.Query().Get("prefix")
+	containers := []map[string]interface{}{}
+	for container := range s.containers {
+		if !strings.HasPrefix(container, prefix) {
+			continue
+		}
+		count := 0
+		for path := range s.objects {
+			if strings.HasPrefix(path, container+"/") {
+				count++
+			}
+		}
+		containers = append(containers, map[string]interface{}{"name": container, "count": count})
+	}
+	sort.Slice(containers, func(i, j int) bool { return containers[i]["name"].(string) < containers[j]["name"].(string) })
+	_ = json.NewEncoder(w).Encode(containers)
+}
+
+func (s *swiftServer) writeObject(w http.ResponseWriter, r *http.Request, object []byte) {
+	w.Header().Set("Content-Length", strconv.Itoa(len(object)))
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(object)
+	}
+}
+
+func newTestConfig(url string) cortex_openstack.SwiftConfig {
+	var cfg cortex_openstack.SwiftConfig
+	cfg.AuthUrl = url + "/v3"
+	cfg.Username = "user"
+	cfg.UserDomainName = "domain"
+	cfg.Password = "password"
+	cfg.ProjectName = "project"
+	cfg.ProjectDomainName = "domain"
+	cfg.ContainerName = "chunks"
+	return cfg
+}
+
+func TestSwiftObjectClient_TenantContainers(t *testing.T) {
+	server, httpServer := newSwiftServer()
+	defer httpServer.Close()
+
+	client, err := NewSwiftObjectClient(newTestConfig(httpServer.URL), Config{ContainerPerTenant: true}, true)
+	require.NoError(t, err)
+	defer client.Stop()
+
+	ctx := context.Background()
+	require.NoError(t, client.PutObject(ctx, "user1/chunk", bytes.NewReader([]byte("chunk"))))
+	require.Equal(t, []byte("chunk"), server.objects["chunks-user1/chunk"])
+
+	rc, err := client.GetObject(ctx, "user1/chunk")
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, []byte("chunk"), b)
+
+	_, err = client.GetObject(ctx, "user2/chunk")
+	require.Equal(t, chunk.ErrStorageObjectNotFound, err)
+
+	require.NoError(t, client.DeleteObject(ctx, "user1/chunk"))
+	require.Empty(t, server.objects)
+
+	// the objects of the other clients stay in the container.
+	indexClient, err := NewSwiftObjectClient(newTestConfig(httpServer.URL), Config{ContainerPerTenant: true}, false)
+	require.NoError(t, err)
+	defer indexClient.Stop()
+	require.NoError(t, indexClient.PutObject(ctx, "index/table", bytes.NewReader([]byte("index"))))
+	require.Equal(t, []byte("index"), server.objects["chunks/index/table"])
+
+	objects, _, err := indexClient.List(ctx, "index/", "")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	require.Equal(t, "index/table", objects[0].Key)
+}
+
+func TestSwiftObjectClient_ListTenantContainers(t *testing.T) {
+	_, httpServer := newSwiftServer()
+	defer httpServer.Close()
+
+	client, err := NewSwiftObjectClient(newTestConfig(httpServer.URL), Config{ContainerPerTenant: true}, true)
+	require.NoError(t, err)
+	defer client.Stop()
+	indexClient, err := NewSwiftObjectClient(newTestConfig(httpServer.URL), Config{ContainerPerTenant: true}, false)
+	require.NoError(t, err)
+	defer indexClient.Stop()
+
+	ctx := context.Background()
+	require.NoError(t, client.PutObject(ctx, "user1/chunk1", bytes.NewReader([]byte("chunk"))))
+	require.NoError(t, client.PutObject(ctx, "user1/chunk2", bytes.NewReader([]byte("chunk"))))
+	require.NoError(t, client.PutObject(ctx, "user2/chunk1", bytes.NewReader([]byte("chunk"))))
+	require.NoError(t, indexClient.PutObject(ctx, "index/table", bytes.NewReader([]byte("index"))))
+
+	for _, tc := range []struct {
+		name      string
+		prefix    string
+		delimiter string
+		keys      []string
+		prefixes  []chunk.StorageCommonPrefix
+	}{
+		{name: "all", keys: []string{"index/table", "user1/chunk1", "user1/chunk2", "user2/chunk1"}},
+		{name: "tenant", prefix: "user1/", keys: []string{"user1/chunk1", "user1/chunk2"}},
+		{name: "tenant objects", prefix: "user1/chunk2", keys: []string{"user1/chunk2"}},
+		{name: "tenants", prefix: "user", keys: []string{"user1/chunk1", "user1/chunk2", "user2/chunk1"}},
+		{name: "delimiter", delimiter: "/", prefixes: []chunk.StorageCommonPrefix{"index/", "user1/", "user2/"}},
+		{name: "tenant delimiter", prefix: "user2/", delimiter: "/", keys: []string{"user2/chunk1"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			objects, prefixes, err := client.List(ctx, tc.prefix, tc.delimiter)
+			require.NoError(t, err)
+
+			var keys []string
+			for _, object := range objects {
+				keys = append(keys, object.Key)
+			}
+			sort.Strings(keys)
+			require.Equal(t, tc.keys, keys)
+			sort.Slice(prefixes, func(i, j int) bool { return prefixes[i] < prefixes[j] })
+			require.Equal(t, tc.prefixes, prefixes)
+		})
+	}
+
+	// the index clients only list the container.
+	objects, _, err := indexClient.List(ctx, "", "")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	require.Equal(t, "index/table", objects[0].Key)
+}
+
+func TestSwiftObjectClient_Segments(t *testing.T) {
+	server, httpServer := newSwiftServer()
+	defer httpServer.Close()
+
+	client, err := NewSwiftObjectClient(newTestConfig(httpServer.URL), Config{SegmentSize: 4}, false)
+	require.NoError(t, err)
+	defer client.Stop()
+	require.True(t, server.containers["chunks_segments"])
+
+	ctx := context.Background()
+	require.NoError(t, client.PutObject(ctx, "fake/small", bytes.NewReader([]byte("abc"))))
+	require.Equal(t, []byte("abc"), server.objects["chunks/fake/small"])
+
+	require.NoError(t, client.PutObject(ctx, "fake/chunk", bytes.NewReader([]byte("0123456789"))))
+	require.Len(t, server.manifests["chunks/fake/chunk"], 3)
+	for _, segment := range server.manifests["chunks/fake/chunk"] {
+		require.True(t, strings.HasPrefix(segment.Path, "chunks_segments/"), segment.Path)
+	}
+
+	rc, err := client.GetObject(ctx, "fake/chunk")
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, []byte("0123456789"), b)
+
+	require.NoError(t, client.DeleteObject(ctx, "fake/chunk"))
+	require.NoError(t, client.DeleteObject(ctx, "fake/small"))
+	require.Empty(t, server.objects)
+	require.Empty(t, server.manifests)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	require.NoError(t, (&Config{}).Validate())
+	require.NoError(t, (&Config{SegmentSize: 1024, SegmentContainerName: "segments"}).Validate())
+	require.Equal(t, errSegmentContainerWithoutSegments, (&Config{SegmentContainerName: "segments"}).Validate())
+}
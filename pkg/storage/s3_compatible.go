@@ -0,0 +1,363 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	cortex_aws "github.com/cortexproject/cortex/pkg/chunk/aws"
+	awscommon "github.com/weaveworks/common/aws"
+)
+
+const (
+	signatureV2 = "v2"
+	signatureV4 = "v4"
+
+	connectivityCheckTimeout = 30 * time.Second
+)
+
+var errNoCACertificates = errors.New("no certificates found in the s3 CA file")
+
+// s3SubResources are the query parameters of the S3 requests that are part of their resource signed with the
+// signature version 2, see https://docs.aws.amazon.com/AmazonS3/latest/userguide/RESTAuthentication.html.
+var s3SubResources = map[string]bool{
+	"acl": true, "delete": true, "lifecycle": true, "location": true, "logging": true, "notification": true,
+	"partNumber": true, "policy": true, "requestPayment": true, "tagging": true, "torrent": true, "uploadId": true,
+	"uploads": true, "versionId": true, "versioning": true, "versions": true, "website": true,
+	"response-cache-control": true, "response-content-disposition": true, "response-content-encoding": true,
+	"response-content-language": true, "response-content-type": true, "response-expires": true,
+}
+
+// S3CompatibleConfig configures the S3 clients for the stores implementing the S3 API, like MinIO or IBM Cloud Object
+// Storage, which can require settings AWS S3 doesn't.
+type S3CompatibleConfig struct {
+	ForcePathStyle     bool   `yaml:"force_path_style"`
+	SignatureVersion   string `yaml:"signature_version"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	CAFile             string `yaml:"ca_file"`
+	CheckConnectivity  bool   `yaml:"check_connectivity"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *S3CompatibleConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.ForcePathStyle, "s3.compatible.force-path-style", false, "Address the buckets in the path of the requests instead of their host, which most S3 compatible stores require. Same as s3.force-path-style.")
+	f.StringVar(&cfg.SignatureVersion, "s3.compatible.signature-version", signatureV4, "Version of the signature of the S3 requests, v4 or v2 for the stores not supporting the version 4.")
+	f.BoolVar(&cfg.InsecureSkipVerify, "s3.compatible.insecure-skip-verify", false, "Skip the verification of the TLS certificate of the S3 endpoint. Same as s3.http.insecure-skip-verify.")
+	f.StringVar(&cfg.CAFile, "s3.compatible.ca-file", "", "Path of the PEM file of the CA certificates to verify the TLS certificate of the S3 endpoint with, instead of the ones of the system.")
+	f.BoolVar(&cfg.CheckConnectivity, "s3.compatible.check-connectivity", false, "Check that the S3 buckets can be reached at startup, failing with a diagnostic of the problem otherwise. The check requests HEAD on the buckets, which requires the s3:ListBucket permission.")
+}
+
+// Validate validates the config.
+func (cfg *S3CompatibleConfig) Validate() error {
+	switch cfg.SignatureVersion {
+	case "", signatureV4, signatureV2:
+		return nil
+	default:
+		return fmt.Errorf("unsupported s3 signature version %q, must be v4 or v2", cfg.SignatureVersion)
+	}
+}
+
+// injectS3Compatible applies the config to the S3 config the S3 clients are built from. The middlewares it injects
+// must be the closest to the transport, so it must be called before the other injections.
+func injectS3Compatible(s3Cfg *cortex_aws.S3Config, cfg S3CompatibleConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	s3Cfg.S3ForcePathStyle = s3Cfg.S3ForcePathStyle || cfg.ForcePathStyle
+	s3Cfg.HTTPConfig.InsecureSkipVerify = s3Cfg.HTTPConfig.InsecureSkipVerify || cfg.InsecureSkipVerify
+
+	var rootCAs *x509.CertPool
+	if cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read the s3 CA file: %w", err)
+		}
+		rootCAs = x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return errNoCACertificates
+		}
+	}
+
+	var signer *s3V2Signer
+	if cfg.SignatureVersion == signatureV2 {
+		creds, err := s3Credentials(*s3Cfg)
+		if err != nil {
+			return err
+		}
+		signer = &s3V2Signer{creds: creds, buckets: s3BucketNames(*s3Cfg)}
+	}
+
+	if rootCAs == nil && signer == nil {
+		return nil
+	}
+
+	inject := s3Cfg.Inject
+	s3Cfg.Inject = func(next http.RoundTripper) http.RoundTripper {
+		// the S3 clients are given an *http.Transport, whose TLS config is the one of their http_config.
+		if transport, ok := next.(*http.Transport); ok && rootCAs != nil {
+			transport = transport.Clone()
+			if transport.TLSClientConfig == nil {
+				transport.TLSClientConfig = &tls.Config{}
+			}
+			transport.TLSClientConfig.RootCAs = rootCAs
+			next = transport
+		}
+		if signer != nil {
+			next = &s3V2RoundTripper{next: next, signer: signer}
+		}
+		if inject != nil {
+			next = inject(next)
+		}
+		return next
+	}
+	return nil
+}
+
+// s3Credentials returns the credentials used by the S3 clients built from the config.
+func s3Credentials(cfg cortex_aws.S3Config) (*credentials.Credentials, error) {
+	awsCfg := aws.NewConfig()
+	if cfg.S3.URL != nil {
+		var err error
+		if awsCfg, err = awscommon.ConfigFromURL(cfg.S3.URL); err != nil {
+			return nil, err
+		}
+	} else {
+		awsCfg = awsCfg.WithCredentials(credentials.AnonymousCredentials)
+	}
+
+	if cfg.AccessKeyID != "" && cfg.SecretAccessKey != "" {
+		awsCfg = awsCfg.WithCredentials(credentials.NewStaticCredentials(cfg.AccessKeyID, cfg.SecretAccessKey, ""))
+	}
+	if awsCfg.Credentials != nil {
+		return awsCfg.Credentials, nil
+	}
+
+	// the session resolves the credentials from the environment like the S3 clients do.
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, err
+	}
+	return sess.Config.Credentials, nil
+}
+
+// s3BucketNames returns the buckets of the S3 config, like the S3 clients do.
+func s3BucketNames(cfg cortex_aws.S3Config) []string {
+	var buckets []string
+	if cfg.S3.URL != nil {
+		buckets = []string{strings.TrimPrefix(cfg.S3.URL.Path, "/")}
+	}
+	if cfg.BucketNames != "" {
+		buckets = strings.Split(cfg.BucketNames, ",")
+	}
+	return buckets
+}
+
+type s3V2RoundTripper struct {
+	next   http.RoundTripper
+	signer *s3V2Signer
+}
+
+func (rt *s3V2RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// anonymous requests are not signed.
+	if req.Header.Get("Authorization") == "" {
+		return rt.next.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Del("Authorization")
+	req.Header.Del("X-Amz-Date")
+	if err := rt.signer.sign(req, time.Now()); err != nil {
+		return nil, err
+	}
+	return rt.next.RoundTrip(req)
+}
+
+// s3V2Signer signs the requests with the signature version 2 of S3, which the SDK doesn't support.
+type s3V2Signer struct {
+	creds   *credentials.Credentials
+	buckets []string
+}
+
+func (s *s3V2Signer) sign(req *http.Request, now time.Time) error {
+	value, err := s.creds.Get()
+	if err != nil {
+		return err
+	}
+	if value.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", value.SessionToken)
+	} else {
+		req.Header.Del("X-Amz-Security-Token")
+	}
+	req.Header.Set("Date", now.UTC().Format(http.TimeFormat))
+
+	req.Header.Set("Authorization", "AWS "+value.AccessKeyID+":"+signatureV2Of(value.SecretAccessKey, s.stringToSign(req)))
+	return nil
+}
+
+func signatureV2Of(secretAccessKey, stringToSign string) string {
+	mac := hmac.New(sha1.New, []byte(secretAccessKey))
+	_, _ = mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *s3V2Signer) stringToSign(req *http.Request) string {
+	var b strings.Builder
+	b.WriteString(req.Method + "\n")
+	b.WriteString(req.Header.Get("Content-Md5") + "\n")
+	b.WriteString(req.Header.Get("Content-Type") + "\n")
+	b.WriteString(req.Header.Get("Date") + "\n")
+
+	var amzHeaders []string
+	for name := range req.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-amz-") {
+			amzHeaders = append(amzHeaders, name)
+		}
+	}
+	sort.Strings(amzHeaders)
+	for _, name := range amzHeaders {
+		b.WriteString(name + ":" + strings.Join(req.Header.Values(name), ",") + "\n")
+	}
+
+	b.WriteString(s.canonicalResource(req))
+	return b.String()
+}
+
+// canonicalResource returns the resource of a request, its path prefixed with the bucket when it is in its host
+// followed by its sub-resources.
+func (s *s3V2Signer) canonicalResource(req *http.Request) string {
+	resource := req.URL.EscapedPath()
+	for _, bucket := range s.buckets {
+		if strings.HasPrefix(req.URL.Host, bucket+".") {
+			resource = "/" + bucket + resource
+			break
+		}
+	}
+	if resource == "" {
+		resource = "/"
+	}
+
+	query := req.URL.Query()
+	var subResources []string
+	for name := range query {
+		if s3SubResources[name] {
+			subResources = append(subResources, name)
+		}
+	}
+	sort.Strings(subResources)
+	for i, name := range subResources {
+		if value := query.Get(name); value != "" {
+			name += "=" + value
+		}
+		subResources[i] = name
+	}
+	if len(subResources) > 0 {
+		resource += "?" + strings.Join(subResources, "&")
+	}
+	return resource
+}
+
+// usingS3 tells whether S3 stores chunks or index files.
+func usingS3(cfg Config, schemaCfg SchemaConfig) bool {
+	isS3 := func(name string) bool { return name == "aws" || name == "s3" }
+	for _, periodCfg := range schemaCfg.Configs {
+		objectType := periodCfg.ObjectType
+		if objectType == "" {
+			objectType = periodCfg.IndexType
+		}
+		if isS3(objectType) {
+			return true
+		}
+	}
+	return (UsingBoltdbShipper(schemaCfg.Configs) && isS3(cfg.BoltDBShipperConfig.SharedStoreType)) ||
+		(UsingTSDB(schemaCfg.Configs) && isS3(cfg.TSDBShipperConfig.SharedStoreType))
+}
+
+// CheckS3Connectivity checks that the S3 buckets can be reached when S3 is used and the check is enabled, returning
+// an error explaining the likely cause of the problem otherwise.
+func CheckS3Connectivity(cfg Config, schemaCfg SchemaConfig) error {
+	if !cfg.S3Compatible.CheckConnectivity || !usingS3(cfg, schemaCfg) {
+		return nil
+	}
+	return checkS3Connectivity(cfg.AWSStorageConfig.S3Config)
+}
+
+func checkS3Connectivity(s3Cfg cortex_aws.S3Config) error {
+	client, err := cortex_aws.NewS3ObjectClient(s3Cfg)
+	if err != nil {
+		return err
+	}
+
+	endpoint := s3Cfg.Endpoint
+	if endpoint == "" && s3Cfg.S3.URL != nil {
+		endpoint = s3Cfg.S3.URL.Host
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectivityCheckTimeout)
+	defer cancel()
+	for _, bucket := range s3BucketNames(s3Cfg) {
+		if _, err := client.S3.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)}); err != nil {
+			return fmt.Errorf("failed to reach the s3 bucket %q at %q, %s: %w", bucket, endpoint, s3Diagnostic(err), err)
+		}
+	}
+	return nil
+}
+
+// s3Diagnostic returns the likely cause of an error of a request to S3.
+func s3Diagnostic(err error) string {
+	var requestFailure awserr.RequestFailure
+	if errors.As(err, &requestFailure) {
+		switch requestFailure.StatusCode() {
+		case http.StatusForbidden:
+			return "the request was denied: check the credentials, their permissions, the region and the signature_version of s3_compatible"
+		case http.StatusNotFound:
+			return "the bucket doesn't exist: check the bucket name, and enable force_path_style of s3_compatible if the store doesn't support the virtual hosted style"
+		case http.StatusMovedPermanently, http.StatusBadRequest:
+			return "the request was rejected: check the region of the bucket, and enable force_path_style of s3_compatible if the store doesn't support the virtual hosted style"
+		}
+		return "the store answered with an unexpected status"
+	}
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.OrigErr() != nil {
+		err = awsErr.OrigErr()
+	}
+
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostnameErr      x509.HostnameError
+		certInvalid      x509.CertificateInvalidError
+		recordHeaderErr  tls.RecordHeaderError
+		dnsErr           *net.DNSError
+		opErr            *net.OpError
+	)
+	switch {
+	case errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr), errors.As(err, &certInvalid):
+		return "the TLS certificate of the endpoint couldn't be verified: set the ca_file of s3_compatible, or enable its insecure_skip_verify"
+	case errors.As(err, &recordHeaderErr):
+		return "the endpoint doesn't serve TLS: enable the insecure setting of the s3 config to connect with plain HTTP"
+	case errors.As(err, &dnsErr):
+		return "the host of the endpoint couldn't be resolved: check the endpoint, and enable force_path_style of s3_compatible if the store doesn't support the virtual hosted style, which puts the bucket in the host"
+	case errors.As(err, &opErr):
+		return "the endpoint couldn't be reached: check the endpoint and the network"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "the endpoint didn't answer in time: check the endpoint and the network"
+	}
+	return "the request failed"
+}
@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	cortex_aws "github.com/cortexproject/cortex/pkg/chunk/aws"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/storage/stores/shipper"
+)
+
+// withoutAWSCABundle unsets the AWS_CA_BUNDLE environment variable, as the SDK can't load a custom CA bundle in the
+// transport of the S3 clients once wrapped. The returned function restores it.
+func withoutAWSCABundle(t *testing.T) func() {
+	caBundle, ok := os.LookupEnv("AWS_CA_BUNDLE")
+	if !ok {
+		return func() {}
+	}
+	require.NoError(t, os.Unsetenv("AWS_CA_BUNDLE"))
+	return func() { require.NoError(t, os.Setenv("AWS_CA_BUNDLE", caBundle)) }
+}
+
+func TestS3V2Signer_StringToSign(t *testing.T) {
+	// the examples of https://docs.aws.amazon.com/AmazonS3/latest/userguide/RESTAuthentication.html
+	const secret = "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"
+	signer := &s3V2Signer{buckets: []string{"awsexamplebucket1"}}
+
+	get, err := http.NewRequest(http.MethodGet, "https://awsexamplebucket1.us-west-1.s3.amazonaws.com/photos/puppy.jpg", nil)
+	require.NoError(t, err)
+	get.Header.Set("Date", "Tue, 27 Mar 2007 19:36:42 +0000")
+	require.Equal(t, "GET\n\n\nTue, 27 Mar 2007 19:36:42 +0000\n/awsexamplebucket1/photos/puppy.jpg", signer.stringToSign(get))
+	require.Equal(t, "qgk2+6Sv9/oM7G3qLEjTH1a1l1g=", signatureV2Of(secret, signer.stringToSign(get)))
+
+	put, err := http.NewRequest(http.MethodPut, "https://awsexamplebucket1.us-west-1.s3.amazonaws.com/photos/puppy.jpg", nil)
+	require.NoError(t, err)
+	put.Header.Set("Content-Type", "image/jpeg")
+	put.Header.Set("Date", "Tue, 27 Mar 2007 21:15:45 +0000")
+	require.Equal(t, "iqRzw+ileNPu1fhspnRs8nOjjIA=", signatureV2Of(secret, signer.stringToSign(put)))
+
+	list, err := http.NewRequest(http.MethodGet, "https://s3.us-west-1.amazonaws.com/awsexamplebucket1/?prefix=photos&max-keys=50&marker=puppy", nil)
+	require.NoError(t, err)
+	list.Header.Set("Date", "Tue, 27 Mar 2007 19:42:41 +0000")
+	list.Header.Set("X-Amz-Meta-Author", "foo@example.com")
+	require.Equal(t, "GET\n\n\nTue, 27 Mar 2007 19:42:41 +0000\nx-amz-meta-author:foo@example.com\n/awsexamplebucket1/", signer.stringToSign(list))
+
+	acl, err := http.NewRequest(http.MethodGet, "https://s3.us-west-1.amazonaws.com/awsexamplebucket1/index/table?acl&versionId=1", nil)
+	require.NoError(t, err)
+	require.Equal(t, "/awsexamplebucket1/index/table?acl&versionId=1", signer.canonicalResource(acl))
+}
+
+// s3V2Server checks the signature version 2 of the requests it receives.
+type s3V2Server struct {
+	mtx     sync.Mutex
+	objects map[string][]byte
+	invalid []string
+}
+
+func (s *s3V2Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	signer := &s3V2Signer{}
+	if r.Header.Get("Authorization") != "AWS key:"+signatureV2Of("secret", signer.stringToSign(r)) {
+		s.invalid = append(s.invalid, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := ioutil.ReadAll(r.Body)
+		s.objects[r.URL.Path] = body
+	case http.MethodGet:
+		_, _ = w.Write(s.objects[r.URL.Path])
+	}
+}
+
+func TestS3Compatible_SignatureV2(t *testing.T) {
+	defer withoutAWSCABundle(t)()
+
+	server := &s3V2Server{objects: map[string][]byte{}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	s3Cfg := cortex_aws.S3Config{
+		Endpoint:        httpServer.URL,
+		Insecure:        true,
+		BucketNames:     "bucket",
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+	}
+	require.NoError(t, injectS3Compatible(&s3Cfg, S3CompatibleConfig{ForcePathStyle: true, SignatureVersion: signatureV2}))
+	require.True(t, s3Cfg.S3ForcePathStyle)
+
+	client, err := cortex_aws.NewS3ObjectClient(s3Cfg)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, client.PutObject(ctx, "fake/chunk", bytes.NewReader([]byte("chunk"))))
+	rc, err := client.GetObject(ctx, "fake/chunk")
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, []byte("chunk"), b)
+	require.Empty(t, server.invalid)
+}
+
+func TestS3Compatible_CAFile(t *testing.T) {
+	defer withoutAWSCABundle(t)()
+
+	httpServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer httpServer.Close()
+
+	dir, err := ioutil.TempDir("", "s3-ca")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: httpServer.Certificate().Raw}), 0o600))
+
+	newS3Config := func() cortex_aws.S3Config {
+		return cortex_aws.S3Config{Endpoint: httpServer.URL, S3ForcePathStyle: true, BucketNames: "bucket"}
+	}
+
+	err = checkS3Connectivity(newS3Config())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "ca_file")
+
+	s3Cfg := newS3Config()
+	require.NoError(t, injectS3Compatible(&s3Cfg, S3CompatibleConfig{CAFile: caFile}))
+	require.NoError(t, checkS3Connectivity(s3Cfg))
+
+	s3Cfg = newS3Config()
+	require.NoError(t, injectS3Compatible(&s3Cfg, S3CompatibleConfig{InsecureSkipVerify: true}))
+	require.NoError(t, checkS3Connectivity(s3Cfg))
+
+	emptyFile := filepath.Join(dir, "empty.pem")
+	require.NoError(t, ioutil.WriteFile(emptyFile, nil, 0o600))
+	require.Equal(t, errNoCACertificates, injectS3Compatible(&cortex_aws.S3Config{}, S3CompatibleConfig{CAFile: emptyFile}))
+}
+
+func TestCheckS3Connectivity(t *testing.T) {
+	defer withoutAWSCABundle(t)()
+
+	for _, tc := range []struct {
+		name      string
+		status    int
+		closed    bool
+		diagnosis string
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "missing bucket", status: http.StatusNotFound, diagnosis: "the bucket doesn't exist"},
+		{name: "denied", status: http.StatusForbidden, diagnosis: "the request was denied"},
+		{name: "unreachable", closed: true, diagnosis: "the endpoint couldn't be reached"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+			}))
+			defer httpServer.Close()
+			if tc.closed {
+				httpServer.Close()
+			}
+
+			err := checkS3Connectivity(cortex_aws.S3Config{Endpoint: httpServer.URL, Insecure: true, S3ForcePathStyle: true, BucketNames: "bucket"})
+			if tc.diagnosis == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), `failed to reach the s3 bucket "bucket"`)
+			require.Contains(t, err.Error(), tc.diagnosis)
+		})
+	}
+}
+
+func TestUsingS3(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		configs []chunk.PeriodConfig
+		cfg     Config
+		s3      bool
+	}{
+		{name: "s3 chunks", configs: []chunk.PeriodConfig{{IndexType: "boltdb", ObjectType: "s3"}}, s3: true},
+		{name: "aws index", configs: []chunk.PeriodConfig{{IndexType: "aws"}}, s3: true},
+		{name: "filesystem", configs: []chunk.PeriodConfig{{IndexType: "boltdb", ObjectType: "filesystem"}}},
+		{
+			name:    "shared store",
+			configs: []chunk.PeriodConfig{{IndexType: "boltdb-shipper", ObjectType: "filesystem"}},
+			cfg:     Config{BoltDBShipperConfig: shipper.Config{SharedStoreType: "aws"}},
+			s3:      true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.s3, usingS3(tc.cfg, SchemaConfig{SchemaConfig: chunk.SchemaConfig{Configs: tc.configs}}))
+		})
+	}
+}
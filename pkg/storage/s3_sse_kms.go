@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	cortex_aws "github.com/cortexproject/cortex/pkg/chunk/aws"
+)
+
+var errSSEKMSWithSSES3 = errors.New("the s3 sse_encryption can't be enabled along with the SSE-KMS encryption")
+
+// S3SSEKMSConfig configures the SSE-KMS encryption of the objects written to S3.
+type S3SSEKMSConfig struct {
+	KMSKeyID             string            `yaml:"kms_key_id"`
+	KMSEncryptionContext string            `yaml:"kms_encryption_context"`
+	TenantKMSKeyIDs      map[string]string `yaml:"tenant_kms_key_ids"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *S3SSEKMSConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.KMSKeyID, "s3.sse-kms-key-id", "", "KMS key ID used to encrypt the objects written to S3 with SSE-KMS. The AWS managed key is used when only tenant_kms_key_ids is set.")
+	f.StringVar(&cfg.KMSEncryptionContext, "s3.sse-kms-encryption-context", "", "KMS encryption context of the objects written to S3 with SSE-KMS, as a JSON object of string values.")
+}
+
+// Enabled tells whether the objects are encrypted with SSE-KMS.
+func (cfg *S3SSEKMSConfig) Enabled() bool {
+	return cfg.KMSKeyID != "" || len(cfg.TenantKMSKeyIDs) > 0
+}
+
+// encodedContext returns the encryption context as expected by the PutObject requests.
+func (cfg *S3SSEKMSConfig) encodedContext() (string, error) {
+	if cfg.KMSEncryptionContext == "" {
+		return "", nil
+	}
+
+	var encryptionContext map[string]string
+	if err := json.Unmarshal([]byte(cfg.KMSEncryptionContext), &encryptionContext); err != nil {
+		return "", fmt.Errorf("invalid SSE-KMS encryption context: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString([]byte(cfg.KMSEncryptionContext)), nil
+}
+
+// keyID returns the KMS key of an object, the tenant of the chunks being the first part of their key.
+func (cfg *S3SSEKMSConfig) keyID(objectKey string) string {
+	if idx := strings.IndexByte(objectKey, '/'); idx > 0 {
+		if keyID, ok := cfg.TenantKMSKeyIDs[objectKey[:idx]]; ok {
+			return keyID
+		}
+	}
+	return cfg.KMSKeyID
+}
+
+// Validate validates the config, along with the S3 config the objects are written with.
+func (cfg *S3SSEKMSConfig) Validate(s3Cfg cortex_aws.S3Config) error {
+	if !cfg.Enabled() {
+		return nil
+	}
+	if s3Cfg.SSEEncryption {
+		return errSSEKMSWithSSES3
+	}
+	_, err := cfg.encodedContext()
+	return err
+}
+
+// usesSSEKMS tells whether the objects of the object store type are written with the SSE-KMS object client.
+func usesSSEKMS(name string, cfg Config) bool {
+	return (name == "aws" || name == "s3") && cfg.S3SSEKMS.Enabled()
+}
+
+// sseKMSObjectClient is the S3 object client encrypting the objects it writes with SSE-KMS, whose parameters are given
+// to its PutObject requests.
+type sseKMSObjectClient struct {
+	*cortex_aws.S3ObjectClient
+	cfg            S3SSEKMSConfig
+	encodedContext *string
+	buckets        []string
+}
+
+func newSSEKMSObjectClient(s3Cfg cortex_aws.S3Config, cfg S3SSEKMSConfig) (*sseKMSObjectClient, error) {
+	if err := cfg.Validate(s3Cfg); err != nil {
+		return nil, err
+	}
+	encodedContext, err := cfg.encodedContext()
+	if err != nil {
+		return nil, err
+	}
+	client, err := cortex_aws.NewS3ObjectClient(s3Cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &sseKMSObjectClient{
+		S3ObjectClient: client,
+		cfg:            cfg,
+		buckets:        s3BucketNames(s3Cfg),
+	}
+	if encodedContext != "" {
+		c.encodedContext = aws.String(encodedContext)
+	}
+	return c, nil
+}
+
+// PutObject implements chunk.ObjectClient.
+func (c *sseKMSObjectClient) PutObject(ctx context.Context, objectKey string, object io.ReadSeeker) error {
+	input := &s3.PutObjectInput{
+		Body:                    object,
+		Bucket:                  aws.String(c.bucketFromKey(objectKey)),
+		Key:                     aws.String(objectKey),
+		ServerSideEncryption:    aws.String(s3.ServerSideEncryptionAwsKms),
+		SSEKMSEncryptionContext: c.encodedContext,
+	}
+	if keyID := c.cfg.keyID(objectKey); keyID != "" {
+		input.SSEKMSKeyId = aws.String(keyID)
+	}
+	_, err := c.S3.PutObjectWithContext(ctx, input)
+	return err
+}
+
+// bucketFromKey returns the bucket of an object, hashing its key like the S3 clients do.
+func (c *sseKMSObjectClient) bucketFromKey(key string) string {
+	if len(c.buckets) == 0 {
+		return ""
+	}
+	hasher := fnv.New32a()
+	_, _ = hasher.Write([]byte(key))
+	return c.buckets[hasher.Sum32()%uint32(len(c.buckets))]
+}
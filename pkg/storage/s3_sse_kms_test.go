@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	cortex_aws "github.com/cortexproject/cortex/pkg/chunk/aws"
+	"github.com/stretchr/testify/require"
+)
+
+type s3Request struct {
+	path, sse, keyID, context string
+	validSignature            bool
+}
+
+// s3Server records the PUT requests it receives and checks their signature.
+type s3Server struct {
+	mtx      sync.Mutex
+	requests []s3Request
+}
+
+func (s *s3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.requests = append(s.requests, s3Request{
+		path:           r.URL.Path,
+		sse:            r.Header.Get("X-Amz-Server-Side-Encryption"),
+		keyID:          r.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"),
+		context:        r.Header.Get("X-Amz-Server-Side-Encryption-Context"),
+		validSignature: validSignature(r),
+	})
+	w.WriteHeader(http.StatusOK)
+}
+
+// validSignature signs the request again with the headers it was signed with.
+func validSignature(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	idx := strings.Index(auth, "SignedHeaders=")
+	if idx < 0 {
+		return false
+	}
+	signedHeaders := strings.Split(strings.SplitN(auth[idx+len("SignedHeaders="):], ",", 2)[0], ";")
+
+	signed, err := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	if err != nil {
+		return false
+	}
+	for _, header := range signedHeaders {
+		if header != "host" {
+			signed.Header.Set(header, r.Header.Get(header))
+		}
+	}
+	date, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+
+	signer := v4.NewSigner(credentials.NewStaticCredentials("key", "secret", ""), func(s *v4.Signer) {
+		s.DisableURIPathEscaping = true
+		s.DisableRequestBodyOverwrite = true
+	})
+	if _, err := signer.Sign(signed, nil, "s3", "dummy", date); err != nil {
+		return false
+	}
+	return signed.Header.Get("Authorization") == auth
+}
+
+func TestS3SSEKMS(t *testing.T) {
+	server := &s3Server{}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	s3Cfg := cortex_aws.S3Config{
+		Endpoint:         httpServer.URL,
+		Insecure:         true,
+		S3ForcePathStyle: true,
+		BucketNames:      "bucket",
+		AccessKeyID:      "key",
+		SecretAccessKey:  "secret",
+	}
+	sseCfg := S3SSEKMSConfig{
+		KMSKeyID:             "default-key",
+		KMSEncryptionContext: `{"cluster":"loki"}`,
+		TenantKMSKeyIDs:      map[string]string{"user1": "user1-key"},
+	}
+	client, err := newSSEKMSObjectClient(s3Cfg, sseCfg)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, client.PutObject(ctx, "user1/chunk", bytes.NewReader([]byte("chunk"))))
+	require.NoError(t, client.PutObject(ctx, "user2/chunk", bytes.NewReader([]byte("chunk"))))
+	require.NoError(t, client.PutObject(ctx, "index/table", bytes.NewReader([]byte("index"))))
+	_, err = client.GetObject(ctx, "user1/chunk")
+	require.NoError(t, err)
+
+	encodedContext := base64.StdEncoding.EncodeToString([]byte(`{"cluster":"loki"}`))
+	require.Equal(t, []s3Request{
+		{path: "/bucket/user1/chunk", sse: "aws:kms", keyID: "user1-key", context: encodedContext, validSignature: true},
+		{path: "/bucket/user2/chunk", sse: "aws:kms", keyID: "default-key", context: encodedContext, validSignature: true},
+		{path: "/bucket/index/table", sse: "aws:kms", keyID: "default-key", context: encodedContext, validSignature: true},
+	}, server.requests)
+}
+
+func TestS3SSEKMSConfig_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		cfg   S3SSEKMSConfig
+		s3Cfg cortex_aws.S3Config
+		err   bool
+	}{
+		{name: "disabled", cfg: S3SSEKMSConfig{KMSEncryptionContext: "invalid"}, s3Cfg: cortex_aws.S3Config{SSEEncryption: true}},
+		{name: "valid", cfg: S3SSEKMSConfig{TenantKMSKeyIDs: map[string]string{"user1": "key"}, KMSEncryptionContext: `{"a":"b"}`}},
+		{name: "invalid context", cfg: S3SSEKMSConfig{KMSKeyID: "key", KMSEncryptionContext: `{"a":1}`}, err: true},
+		{name: "sse-s3", cfg: S3SSEKMSConfig{KMSKeyID: "key"}, s3Cfg: cortex_aws.S3Config{SSEEncryption: true}, err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.Validate(tc.s3Cfg)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
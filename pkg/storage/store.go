@@ -11,6 +11,7 @@ import (
 	cortex_local "github.com/cortexproject/cortex/pkg/chunk/local"
 	"github.com/cortexproject/cortex/pkg/chunk/storage"
 	"github.com/cortexproject/cortex/pkg/querier/astmapper"
+	"github.com/cortexproject/cortex/pkg/util/spanlogger"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/pkg/labels"
@@ -20,7 +21,14 @@ import (
 	"github.com/famarks/loki/pkg/logproto"
 	"github.com/famarks/loki/pkg/logql"
 	"github.com/famarks/loki/pkg/logql/stats"
+	"github.com/famarks/loki/pkg/storage/alibaba"
+	"github.com/famarks/loki/pkg/storage/azure"
+	"github.com/famarks/loki/pkg/storage/embeddedcache"
+	"github.com/famarks/loki/pkg/storage/local"
+	"github.com/famarks/loki/pkg/storage/openstack"
 	"github.com/famarks/loki/pkg/storage/stores/shipper"
+	"github.com/famarks/loki/pkg/storage/stores/shipper/indexgateway"
+	"github.com/famarks/loki/pkg/storage/stores/tsdb"
 	"github.com/famarks/loki/pkg/util"
 )
 
@@ -28,22 +36,108 @@ var (
 	errCurrentBoltdbShipperNon24Hours  = errors.New("boltdb-shipper works best with 24h periodic index config. Either add a new config with future date set to 24h to retain the existing index or change the existing config to use 24h period")
 	errUpcomingBoltdbShipperNon24Hours = errors.New("boltdb-shipper with future date must always have periodic config for index set to 24h")
 	errZeroLengthConfig                = errors.New("must specify at least one schema configuration")
+	errTSDBWithoutObjectStore          = errors.New("the object store of the periodic configs using the tsdb index must be set")
+	errWriteBehindConcurrency          = errors.New("the concurrency of the write-behind queue must be positive")
+	errFSEvictionSweepInterval         = errors.New("the sweep interval of the filesystem eviction must be positive")
 )
 
 // Config is the loki storage configuration
 type Config struct {
 	storage.Config      `yaml:",inline"`
-	MaxChunkBatchSize   int            `yaml:"max_chunk_batch_size"`
-	BoltDBShipperConfig shipper.Config `yaml:"boltdb_shipper"`
+	MaxChunkBatchSize   int                     `yaml:"max_chunk_batch_size"`
+	BoltDBShipperConfig shipper.Config          `yaml:"boltdb_shipper"`
+	TSDBShipperConfig   tsdb.Config             `yaml:"tsdb_shipper"`
+	EmbeddedChunkCache  embeddedcache.Config    `yaml:"embedded_chunk_cache"`
+	WriteBehind         WriteBehindConfig       `yaml:"write_behind"`
+	S3SSEKMS            S3SSEKMSConfig          `yaml:"s3_sse_kms"`
+	S3Compatible        S3CompatibleConfig      `yaml:"s3_compatible"`
+	AzureBlobConfig     azure.Config            `yaml:"azure_blob"`
+	SwiftObjectsConfig  openstack.Config        `yaml:"swift_objects"`
+	AlibabaOSSConfig    alibaba.Config          `yaml:"alibaba_oss"`
+	FSEvictionConfig    local.EvictionConfig    `yaml:"filesystem_eviction"`
+	ChunkFetch          ChunkFetchConfig        `yaml:"chunk_fetch"`
+	ColdTier            ColdTierConfig          `yaml:"cold_tier"`
+	TenantObjects       TenantObjectsConfig     `yaml:"tenant_objects"`
+	Hedging             HedgingConfig           `yaml:"hedging"`
+	CongestionControl   CongestionControlConfig `yaml:"congestion_control"`
+	Instrumentation     InstrumentationConfig   `yaml:"instrumentation"`
 }
 
 // RegisterFlags adds the flags required to configure this flag set.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.Config.RegisterFlags(f)
 	cfg.BoltDBShipperConfig.RegisterFlags(f)
+	cfg.TSDBShipperConfig.RegisterFlags(f)
+	cfg.EmbeddedChunkCache.RegisterFlagsWithPrefix("store.chunks-cache.", "Cache config for chunks. ", f)
+	cfg.WriteBehind.RegisterFlags(f)
+	cfg.S3SSEKMS.RegisterFlags(f)
+	cfg.S3Compatible.RegisterFlags(f)
+	cfg.AzureBlobConfig.RegisterFlags(f)
+	cfg.SwiftObjectsConfig.RegisterFlags(f)
+	cfg.AlibabaOSSConfig.RegisterFlags(f)
+	cfg.FSEvictionConfig.RegisterFlags(f)
+	cfg.ChunkFetch.RegisterFlags(f)
+	cfg.ColdTier.RegisterFlags(f)
+	cfg.TenantObjects.RegisterFlags(f)
+	cfg.Hedging.RegisterFlags(f)
+	cfg.CongestionControl.RegisterFlags(f)
+	cfg.Instrumentation.RegisterFlags(f)
 	f.IntVar(&cfg.MaxChunkBatchSize, "store.max-chunk-batch-size", 50, "The maximum number of chunks to fetch per batch.")
 }
 
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if err := cfg.Config.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.EmbeddedChunkCache.Validate(); err != nil {
+		return err
+	}
+	if cfg.WriteBehind.Enabled && cfg.WriteBehind.Concurrency <= 0 {
+		return errWriteBehindConcurrency
+	}
+	if cfg.FSEvictionConfig.Enabled() && cfg.FSEvictionConfig.SweepInterval <= 0 {
+		return errFSEvictionSweepInterval
+	}
+	if err := cfg.ChunkFetch.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.Hedging.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.CongestionControl.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.TenantObjects.Validate(); err != nil {
+		return err
+	}
+	if cfg.TenantObjects.Enabled() && cfg.ColdTier.Enabled() {
+		return errTenantObjectsColdTier
+	}
+	if cfg.TenantObjects.Enabled() && cfg.FSEvictionConfig.Enabled() {
+		return errTenantObjectsFSEviction
+	}
+	if err := cfg.S3Compatible.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.AzureBlobConfig.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.SwiftObjectsConfig.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.AlibabaOSSConfig.Validate(); err != nil {
+		return err
+	}
+	return cfg.S3SSEKMS.Validate(cfg.AWSStorageConfig.S3Config)
+}
+
+// InjectS3Compatible applies the S3 compatible config to the S3 clients built from the config. It must be called once,
+// before any S3 client is built.
+func (cfg *Config) InjectS3Compatible() error {
+	return injectS3Compatible(&cfg.AWSStorageConfig.S3Config, cfg.S3Compatible)
+}
+
 // SchemaConfig contains the config for our chunk index schemas
 type SchemaConfig struct {
 	chunk.SchemaConfig `yaml:",inline"`
@@ -66,6 +160,13 @@ func (cfg *SchemaConfig) Validate() error {
 		return errUpcomingBoltdbShipperNon24Hours
 	}
 
+	// the index type can't be used as the object store type with the tsdb index.
+	for _, periodCfg := range cfg.Configs {
+		if periodCfg.IndexType == tsdb.TSDBType && periodCfg.ObjectType == "" {
+			return errTSDBWithoutObjectStore
+		}
+	}
+
 	return cfg.SchemaConfig.Validate()
 }
 
@@ -82,6 +183,7 @@ type store struct {
 	chunk.Store
 	cfg          Config
 	chunkMetrics *ChunkMetrics
+	fetchQueue   *chunkFetchQueue
 	schemaCfg    SchemaConfig
 }
 
@@ -91,6 +193,7 @@ func NewStore(cfg Config, schemaCfg SchemaConfig, chunkStore chunk.Store, regist
 		Store:        chunkStore,
 		cfg:          cfg,
 		chunkMetrics: NewChunkMetrics(registerer, cfg.MaxChunkBatchSize),
+		fetchQueue:   newChunkFetchQueue(cfg.ChunkFetch, registerer),
 		schemaCfg:    schemaCfg,
 	}, nil
 }
@@ -155,6 +258,9 @@ func (s *store) lazyChunks(ctx context.Context, matchers []*labels.Matcher, from
 		return nil, err
 	}
 
+	log, ctx := spanlogger.New(ctx, "LokiStore.lazyChunks")
+	defer log.Finish()
+
 	storeStats := stats.GetStoreData(ctx)
 
 	chks, fetchers, err := s.GetChunkRefs(ctx, userID, from, through, matchers...)
@@ -173,6 +279,8 @@ func (s *store) lazyChunks(ctx context.Context, matchers []*labels.Matcher, from
 
 	s.chunkMetrics.refs.WithLabelValues(statusDiscarded).Add(float64(prefiltered - filtered))
 	s.chunkMetrics.refs.WithLabelValues(statusMatched).Add(float64(filtered))
+	log.SetTag("chunk_refs", prefiltered)
+	log.SetTag("chunk_refs_in_range", filtered)
 
 	// creates lazychunks with chunks ref.
 	lazyChunks := make([]*LazyChunk, 0, filtered)
@@ -239,7 +347,7 @@ func (s *store) GetSeries(ctx context.Context, req logql.SelectLogParams) ([]log
 	}
 
 	for _, group := range groups {
-		err = fetchLazyChunks(ctx, group)
+		err = fetchLazyChunks(ctx, s.fetchQueue, group)
 		if err != nil {
 			return nil, err
 		}
@@ -291,7 +399,7 @@ func (s *store) SelectLogs(ctx context.Context, req logql.SelectLogParams) (iter
 		return iter.NoopIterator, nil
 	}
 
-	return newLogBatchIterator(ctx, s.chunkMetrics, lazyChunks, s.cfg.MaxChunkBatchSize, matchers, pipeline, req.Direction, req.Start, req.End)
+	return newLogBatchIterator(ctx, s.chunkMetrics, s.fetchQueue, lazyChunks, s.cfg.MaxChunkBatchSize, matchers, pipeline, req.Direction, req.Start, req.End)
 
 }
 
@@ -319,7 +427,7 @@ func (s *store) SelectSamples(ctx context.Context, req logql.SelectSampleParams)
 	if len(lazyChunks) == 0 {
 		return iter.NoopIterator, nil
 	}
-	return newSampleBatchIterator(ctx, s.chunkMetrics, lazyChunks, s.cfg.MaxChunkBatchSize, matchers, extractor, req.Start, req.End)
+	return newSampleBatchIterator(ctx, s.chunkMetrics, s.fetchQueue, lazyChunks, s.cfg.MaxChunkBatchSize, matchers, extractor, req.Start, req.End)
 }
 
 func (s *store) GetSchemaConfigs() []chunk.PeriodConfig {
@@ -348,7 +456,17 @@ func RegisterCustomIndexClients(cfg *Config, registerer prometheus.Registerer) {
 			return boltDBIndexClientWithShipper, nil
 		}
 
-		objectClient, err := storage.NewObjectClient(cfg.BoltDBShipperConfig.SharedStoreType, cfg.Config)
+		// read-only components can query the index through an index gateway instead of downloading it.
+		if cfg.BoltDBShipperConfig.Mode == shipper.ModeReadOnly && cfg.BoltDBShipperConfig.IndexGatewayClientConfig.Address != "" {
+			gatewayClient, err := indexgateway.NewClient(cfg.BoltDBShipperConfig.IndexGatewayClientConfig)
+			if err != nil {
+				return nil, err
+			}
+			boltDBIndexClientWithShipper = gatewayClient
+			return boltDBIndexClientWithShipper, nil
+		}
+
+		objectClient, err := NewObjectClient(cfg.BoltDBShipperConfig.SharedStoreType, *cfg)
 		if err != nil {
 			return nil, err
 		}
@@ -357,13 +475,26 @@ func RegisterCustomIndexClients(cfg *Config, registerer prometheus.Registerer) {
 
 		return boltDBIndexClientWithShipper, err
 	}, func() (client chunk.TableClient, e error) {
-		objectClient, err := storage.NewObjectClient(cfg.BoltDBShipperConfig.SharedStoreType, cfg.Config)
+		objectClient, err := NewObjectClient(cfg.BoltDBShipperConfig.SharedStoreType, *cfg)
 		if err != nil {
 			return nil, err
 		}
 
 		return shipper.NewBoltDBShipperTableClient(objectClient), nil
 	})
+
+	// the tsdb index is served by the tsdb stores made by NewChunkStore, its index client is only registered for
+	// its table client to be used by the table manager.
+	storage.RegisterIndexStore(tsdb.TSDBType, func() (chunk.IndexClient, error) {
+		return nil, errors.New("the tsdb index can't be used as an index client")
+	}, func() (chunk.TableClient, error) {
+		objectClient, err := NewObjectClient(cfg.TSDBShipperConfig.SharedStoreType, *cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		return shipper.NewTableClient(objectClient, tsdb.StorageKeyPrefix), nil
+	})
 }
 
 // ActivePeriodConfig returns index of active PeriodicConfig which would be applicable to logs that would be pushed starting now.
@@ -389,3 +520,20 @@ func UsingBoltdbShipper(configs []chunk.PeriodConfig) bool {
 
 	return false
 }
+
+// IsShippedIndexType tells whether the index of the given type is built by the ingesters and shipped to the shared store,
+// in which case the ingesters also have to be queried for the chunks they flushed recently.
+func IsShippedIndexType(indexType string) bool {
+	return indexType == shipper.BoltDBShipperType || indexType == tsdb.TSDBType
+}
+
+// UsingTSDB checks whether current or the next index type is tsdb, returns true if yes.
+func UsingTSDB(configs []chunk.PeriodConfig) bool {
+	activePCIndex := ActivePeriodConfig(configs)
+	if configs[activePCIndex].IndexType == tsdb.TSDBType ||
+		(len(configs)-1 > activePCIndex && configs[activePCIndex+1].IndexType == tsdb.TSDBType) {
+		return true
+	}
+
+	return false
+}
@@ -401,6 +401,7 @@ func Test_store_SelectLogs(t *testing.T) {
 					MaxChunkBatchSize: 10,
 				},
 				chunkMetrics: NilMetrics,
+				fetchQueue:   NilFetchQueue,
 			}
 
 			ctx = user.InjectOrgID(context.Background(), "test-user")
@@ -611,6 +612,7 @@ func Test_store_SelectSample(t *testing.T) {
 					MaxChunkBatchSize: 10,
 				},
 				chunkMetrics: NilMetrics,
+				fetchQueue:   NilFetchQueue,
 			}
 
 			ctx = user.InjectOrgID(context.Background(), "test-user")
@@ -681,6 +683,7 @@ func Test_store_GetSeries(t *testing.T) {
 					MaxChunkBatchSize: tt.batchSize,
 				},
 				chunkMetrics: NilMetrics,
+				fetchQueue:   NilFetchQueue,
 			}
 			ctx = user.InjectOrgID(context.Background(), "test-user")
 			out, err := s.GetSeries(ctx, logql.SelectLogParams{QueryRequest: tt.req})
@@ -3,18 +3,25 @@ package compactor
 import (
 	"context"
 	"flag"
+	"fmt"
+	"hash/fnv"
+	"net/http"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/cortexproject/cortex/pkg/chunk"
-	"github.com/cortexproject/cortex/pkg/chunk/storage"
 	chunk_util "github.com/cortexproject/cortex/pkg/chunk/util"
+	cortex_compactor "github.com/cortexproject/cortex/pkg/compactor"
+	"github.com/cortexproject/cortex/pkg/ring"
 	pkg_util "github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/services"
 	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
 
+	"github.com/famarks/loki/pkg/chunkenc"
 	"github.com/famarks/loki/pkg/storage/stores/shipper"
 	"github.com/famarks/loki/pkg/storage/stores/util"
 )
@@ -22,9 +29,24 @@ import (
 const delimiter = "/"
 
 type Config struct {
-	WorkingDirectory   string        `yaml:"working_directory"`
-	SharedStoreType    string        `yaml:"shared_store"`
-	CompactionInterval time.Duration `yaml:"compaction_interval"`
+	WorkingDirectory          string                      `yaml:"working_directory"`
+	SharedStoreType           string                      `yaml:"shared_store"`
+	CompactionInterval        time.Duration               `yaml:"compaction_interval"`
+	DeletionDelay             time.Duration               `yaml:"deletion_delay"`
+	RetentionEnabled          bool                        `yaml:"retention_enabled"`
+	DeletionEnabled           bool                        `yaml:"deletion_enabled"`
+	DeleteRequestCancelPeriod time.Duration               `yaml:"delete_request_cancel_period"`
+	ShardingEnabled           bool                        `yaml:"sharding_enabled"`
+	ShardingRing              cortex_compactor.RingConfig `yaml:"sharding_ring"`
+	TieringEnabled            bool                        `yaml:"tiering_enabled"`
+	TieringMoveAfter          time.Duration               `yaml:"tiering_move_after"`
+	ReencodingEncoding        string                      `yaml:"reencoding_encoding"`
+	ReencodingMinAge          time.Duration               `yaml:"reencoding_min_age"`
+	ReencodingMaxChunksPerRun int                         `yaml:"reencoding_max_chunks_per_run"`
+	UsageEnabled              bool                        `yaml:"usage_enabled"`
+	UsageMaxChunksPerRun      int                         `yaml:"usage_max_chunks_per_run"`
+	IntegrityCheckEnabled     bool                        `yaml:"integrity_check_enabled"`
+	IntegrityCheckSampleSize  int                         `yaml:"integrity_check_sample_size"`
 }
 
 // RegisterFlags registers flags.
@@ -32,24 +54,78 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.WorkingDirectory, "boltdb.shipper.compactor.working-directory", "", "Directory where files can be downloaded for compaction.")
 	f.StringVar(&cfg.SharedStoreType, "boltdb.shipper.compactor.shared-store", "", "Shared store used for storing boltdb files. Supported types: gcs, s3, azure, swift, filesystem")
 	f.DurationVar(&cfg.CompactionInterval, "boltdb.shipper.compactor.compaction-interval", 2*time.Hour, "Interval at which to re-run the compaction operation.")
+	f.DurationVar(&cfg.DeletionDelay, "boltdb.shipper.compactor.deletion-delay", 10*time.Minute, "Time to wait before removing compacted source files from the store, giving queriers time to sync the compacted file. Source files are removed by the first compaction run after the delay. 0 removes them right after compaction.")
+	f.BoolVar(&cfg.RetentionEnabled, "boltdb.shipper.compactor.retention-enabled", false, "Delete the chunks and index entries past the retention of their tenant and stream, see retention_period and retention_stream in limits_config.")
+	f.BoolVar(&cfg.DeletionEnabled, "boltdb.shipper.compactor.deletion-enabled", false, "Enable the /loki/api/v1/delete endpoints and the processing of the delete requests, the deleted log entries are removed from the chunks and index.")
+	f.DurationVar(&cfg.DeleteRequestCancelPeriod, "boltdb.shipper.compactor.delete-request-cancel-period", 24*time.Hour, "Time during which a delete request can be cancelled. Requests are only processed after it.")
+	f.BoolVar(&cfg.ShardingEnabled, "boltdb.shipper.compactor.sharding-enabled", false, "Shard tables across the compactors using the compactor ring, so that multiple compactors can run at the same time with each table compacted by a single one of them.")
+	cfg.ShardingRing.RegisterFlags(f)
+	f.BoolVar(&cfg.TieringEnabled, "boltdb.shipper.compactor.tiering-enabled", false, "Move the chunks of the shared store to the cold tier of the storage config once they are older than tiering-move-after, see cold_tier in storage_config.")
+	f.DurationVar(&cfg.TieringMoveAfter, "boltdb.shipper.compactor.tiering-move-after", 30*24*time.Hour, "Age of the chunks, from their end, after which they are moved to the cold tier.")
+	f.StringVar(&cfg.ReencodingEncoding, "boltdb.shipper.compactor.reencoding-encoding", "", fmt.Sprintf("Re-encode the chunks older than reencoding-min-age with this encoding, replacing them in the index. Empty to disable. Supported values: %s.", chunkenc.SupportedEncoding()))
+	f.DurationVar(&cfg.ReencodingMinAge, "boltdb.shipper.compactor.reencoding-min-age", 48*time.Hour, "Age of the chunks, from their end, after which they are re-encoded.")
+	f.IntVar(&cfg.ReencodingMaxChunksPerRun, "boltdb.shipper.compactor.reencoding-max-chunks-per-run", 10000, "Maximum number of chunks fetched for being re-encoded by a compaction run, the others being re-encoded by the next runs. 0 for no limit.")
+	f.BoolVar(&cfg.UsageEnabled, "boltdb.shipper.compactor.usage-enabled", false, "Compute the chunks and index size of each tenant in every table along the compaction, served by the /compactor/usage endpoint.")
+	f.IntVar(&cfg.UsageMaxChunksPerRun, "boltdb.shipper.compactor.usage-max-chunks-per-run", 10000, "Maximum number of chunks fetched for measuring their size by a compaction run, the others being measured by the next runs. 0 for no limit.")
+	f.BoolVar(&cfg.IntegrityCheckEnabled, "boltdb.shipper.compactor.integrity-check-enabled", false, "Check a sample of the chunks of a table by compaction run, the table checked the longest time ago, reporting the corrupt chunks on the /compactor/integrity endpoint.")
+	f.IntVar(&cfg.IntegrityCheckSampleSize, "boltdb.shipper.compactor.integrity-check-sample-size", 100, "Number of chunks of the table fetched and decoded by a compaction run when checking their integrity.")
+}
+
+// Validate verifies the config does not contain inappropriate values
+func (cfg *Config) Validate() error {
+	// chunks rewritten by a delete request can be referenced by tables owned by other compactors.
+	if cfg.DeletionEnabled && cfg.ShardingEnabled {
+		return errors.New("deletion can't be enabled along with sharding")
+	}
+	// like the chunks rewritten by a delete request, the chunks to move can be referenced by tables owned by other
+	// compactors.
+	if cfg.TieringEnabled && cfg.ShardingEnabled {
+		return errors.New("tiering can't be enabled along with sharding")
+	}
+	if cfg.TieringEnabled && cfg.TieringMoveAfter <= 0 {
+		return errors.New("the tiering move after duration must be positive")
+	}
+	if cfg.ReencodingEncoding != "" {
+		// like the chunks rewritten by a delete request, the re-encoded chunks can be referenced by tables owned by
+		// other compactors.
+		if cfg.ShardingEnabled {
+			return errors.New("re-encoding can't be enabled along with sharding")
+		}
+		if _, err := chunkenc.ParseEncoding(cfg.ReencodingEncoding); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 type Compactor struct {
 	services.Service
 
-	cfg          Config
-	objectClient chunk.ObjectClient
+	cfg            Config
+	objectClient   chunk.ObjectClient
+	deletionQueue  *deletionQueue
+	retention      *retention
+	deleteRequests *deleteRequestsStore
+	tiering        *tiering
+	reencoding     *reencoding
+	usage          *usage
+	integrity      *integrity
+	chunkClient    chunk.Client
+	schemaConfig   chunk.SchemaConfig
 
-	metrics *metrics
-}
+	// Ring used to shard the tables across compactors.
+	ringLifecycler  *ring.Lifecycler
+	ring            *ring.Ring
+	ringSubservices *services.Manager
 
-func NewCompactor(cfg Config, storageConfig storage.Config, r prometheus.Registerer) (*Compactor, error) {
-	objectClient, err := storage.NewObjectClient(cfg.SharedStoreType, storageConfig)
-	if err != nil {
-		return nil, err
-	}
+	registerer prometheus.Registerer
+	metrics    *metrics
+}
 
-	err = chunk_util.EnsureDirectory(cfg.WorkingDirectory)
+// NewCompactor makes a new Compactor of the index stored with the object client. The chunk client of the shared store is
+// only used with the retention, the deletion, the re-encoding, the usage or the integrity check enabled, and the object client of the cold tier with the tiering enabled.
+func NewCompactor(cfg Config, objectClient chunk.ObjectClient, chunkClient chunk.Client, coldObjectClient chunk.ObjectClient, schemaConfig chunk.SchemaConfig, limits Limits, r prometheus.Registerer) (*Compactor, error) {
+	err := chunk_util.EnsureDirectory(cfg.WorkingDirectory)
 	if err != nil {
 		return nil, err
 	}
@@ -57,13 +133,120 @@ func NewCompactor(cfg Config, storageConfig storage.Config, r prometheus.Registe
 	compactor := Compactor{
 		cfg:          cfg,
 		objectClient: util.NewPrefixedObjectClient(objectClient, shipper.StorageKeyPrefix),
+		schemaConfig: schemaConfig,
+		registerer:   r,
 		metrics:      newMetrics(r),
 	}
 
-	compactor.Service = services.NewBasicService(nil, compactor.loop, nil)
+	if cfg.RetentionEnabled || cfg.DeletionEnabled || cfg.ReencodingEncoding != "" || cfg.UsageEnabled || cfg.IntegrityCheckEnabled {
+		if chunkClient == nil {
+			return nil, errors.New("the chunk client is required with the retention, the deletion, the re-encoding, the usage or the integrity check enabled")
+		}
+		compactor.chunkClient = chunkClient
+	}
+
+	if cfg.DeletionDelay > 0 {
+		compactor.deletionQueue = newDeletionQueue(compactor.objectClient, compactor.chunkClient, cfg.DeletionDelay)
+	}
+
+	if cfg.RetentionEnabled {
+		compactor.retention = &retention{limits: limits, chunkClient: compactor.chunkClient}
+	}
+
+	if cfg.DeletionEnabled {
+		compactor.deleteRequests = newDeleteRequestsStore(util.NewPrefixedObjectClient(objectClient, DeleteRequestsPrefix))
+	}
+
+	if cfg.ReencodingEncoding != "" {
+		compactor.reencoding, err = newReencoding(cfg, compactor.chunkClient, schemaConfig, util.NewPrefixedObjectClient(objectClient, ReencodingPrefix), compactor.metrics)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.UsageEnabled {
+		compactor.usage = newUsage(cfg, compactor.chunkClient, schemaConfig, util.NewPrefixedObjectClient(objectClient, UsagePrefix), compactor.metrics)
+	}
+
+	if cfg.IntegrityCheckEnabled {
+		compactor.integrity = newIntegrity(cfg, compactor.chunkClient, util.NewPrefixedObjectClient(objectClient, IntegrityPrefix), compactor.metrics)
+	}
+
+	if cfg.TieringEnabled {
+		if coldObjectClient == nil {
+			return nil, errors.New("the object client of the cold tier is required with the tiering enabled")
+		}
+		compactor.tiering = newTiering(cfg, objectClient, coldObjectClient, schemaConfig, util.NewPrefixedObjectClient(objectClient, TieringPrefix), compactor.metrics)
+	}
+
+	compactor.Service = services.NewBasicService(compactor.starting, compactor.loop, compactor.stopping)
 	return &compactor, nil
 }
 
+func (c *Compactor) starting(ctx context.Context) error {
+	if !c.cfg.ShardingEnabled {
+		return nil
+	}
+
+	var err error
+	lifecyclerCfg := c.cfg.ShardingRing.ToLifecyclerConfig()
+	c.ringLifecycler, err = ring.NewLifecycler(lifecyclerCfg, ring.NewNoopFlushTransferer(), "compactor", ring.CompactorRingKey, false, c.registerer)
+	if err != nil {
+		return errors.Wrap(err, "unable to initialize compactor ring lifecycler")
+	}
+
+	c.ring, err = ring.New(lifecyclerCfg.RingConfig, "compactor", ring.CompactorRingKey, c.registerer)
+	if err != nil {
+		return errors.Wrap(err, "unable to initialize compactor ring")
+	}
+
+	c.ringSubservices, err = services.NewManager(c.ringLifecycler, c.ring)
+	if err == nil {
+		err = services.StartManagerAndAwaitHealthy(ctx, c.ringSubservices)
+	}
+	if err != nil {
+		return errors.Wrap(err, "unable to start compactor ring dependencies")
+	}
+
+	// wait until this compactor is ACTIVE within the ring before checking which tables it owns.
+	level.Info(pkg_util.Logger).Log("msg", "waiting until compactor is ACTIVE in the ring")
+	if err := ring.WaitInstanceState(ctx, c.ring, c.ringLifecycler.ID, ring.ACTIVE); err != nil {
+		return err
+	}
+	level.Info(pkg_util.Logger).Log("msg", "compactor is ACTIVE in the ring")
+
+	return nil
+}
+
+func (c *Compactor) stopping(_ error) error {
+	if c.ringSubservices != nil {
+		return services.StopManagerAndAwaitStopped(context.Background(), c.ringSubservices)
+	}
+	return nil
+}
+
+// ownTable tells whether the table has to be compacted by this compactor.
+func (c *Compactor) ownTable(tableName string) (bool, error) {
+	// Always owned if sharding is disabled.
+	if !c.cfg.ShardingEnabled {
+		return true, nil
+	}
+
+	hasher := fnv.New32a()
+	_, _ = hasher.Write([]byte(tableName))
+
+	rs, err := c.ring.Get(hasher.Sum32(), ring.Read, []ring.IngesterDesc{})
+	if err != nil {
+		return false, err
+	}
+
+	if len(rs.Ingesters) != 1 {
+		return false, fmt.Errorf("unexpected number of compactors in the shard (expected 1, got %d)", len(rs.Ingesters))
+	}
+
+	return rs.Ingesters[0].Addr == c.ringLifecycler.Addr, nil
+}
+
 func (c *Compactor) loop(ctx context.Context) error {
 	runCompaction := func() {
 		err := c.Run(ctx)
@@ -110,8 +293,67 @@ func (c *Compactor) Run(ctx context.Context) error {
 		tables[i] = strings.TrimSuffix(string(dir), delimiter)
 	}
 
+	var (
+		del             *deletion
+		pendingRequests []*DeleteRequest
+	)
+	if c.deleteRequests != nil {
+		pendingRequests, err = c.pendingDeleteRequests(ctx)
+		if err != nil {
+			status = statusFailure
+			return err
+		}
+		if len(pendingRequests) > 0 {
+			del = newDeletion(pendingRequests, c.chunkClient, c.schemaConfig)
+		}
+	}
+
+	if c.reencoding != nil {
+		if err := c.reencoding.start(ctx, model.Now()); err != nil {
+			status = statusFailure
+			return err
+		}
+	}
+
+	if c.usage != nil {
+		if err := c.usage.start(ctx, model.Now()); err != nil {
+			status = statusFailure
+			return err
+		}
+	}
+
+	if c.integrity != nil {
+		if err := c.integrity.start(ctx, model.Now(), tables); err != nil {
+			status = statusFailure
+			return err
+		}
+	}
+
+	if c.tiering != nil {
+		if err := c.tiering.start(ctx, time.Now()); err != nil {
+			status = statusFailure
+			return err
+		}
+	}
+
 	for _, tableName := range tables {
-		table, err := newTable(ctx, filepath.Join(c.cfg.WorkingDirectory, tableName), c.objectClient)
+		owned, err := c.ownTable(tableName)
+		if err != nil {
+			status = statusFailure
+			level.Error(pkg_util.Logger).Log("msg", "failed to check the ownership of table", "table", tableName, "err", err)
+			continue
+		}
+		if !owned {
+			level.Debug(pkg_util.Logger).Log("msg", "skipping table not owned by this compactor", "table", tableName)
+			continue
+		}
+
+		reencoding := c.reencoding
+		if reencoding != nil && reencoding.isDone(tableName) {
+			reencoding = nil
+		}
+
+		table, err := newTable(ctx, filepath.Join(c.cfg.WorkingDirectory, tableName), c.objectClient, c.deletionQueue, c.retention, del, reencoding, c.usage, c.integrity, c.tiering)
 		if err != nil {
 			status = statusFailure
 			level.Error(pkg_util.Logger).Log("msg", "failed to initialize table for compaction", "table", tableName, "err", err)
@@ -132,5 +374,92 @@ func (c *Compactor) Run(ctx context.Context) error {
 		}
 	}
 
+	if c.deletionQueue != nil {
+		err = c.deletionQueue.deleteExpired(ctx, time.Now())
+		if err != nil {
+			status = statusFailure
+			return err
+		}
+	}
+
+	// the delete requests are done only once every table got processed, they are applied again by the next run otherwise.
+	if del != nil && status == statusSuccess {
+		if err := c.removeChunks(ctx, del.replacedChunks()); err != nil {
+			status = statusFailure
+			return err
+		}
+
+		for _, req := range pendingRequests {
+			req.Status = StatusProcessed
+			if err := c.deleteRequests.put(ctx, req); err != nil {
+				status = statusFailure
+				return err
+			}
+			level.Info(pkg_util.Logger).Log("msg", "processed delete request", "user", req.UserID, "request_id", req.RequestID)
+		}
+	}
+
+	// like the delete requests, the re-encoded chunks are only removed once every table got processed.
+	if c.reencoding != nil && status == statusSuccess {
+		if err := c.removeChunks(ctx, c.reencoding.replacedChunks()); err != nil {
+			status = statusFailure
+			return err
+		}
+		if err := c.reencoding.finish(ctx); err != nil {
+			status = statusFailure
+			return err
+		}
+	}
+
+	// the chunks are moved once the retention, the delete requests and the re-encoding are applied to every table, for them
+	// not to delete the chunks being moved.
+	if c.tiering != nil {
+		if err := c.tiering.moveChunks(ctx); err != nil {
+			status = statusFailure
+			return err
+		}
+	}
+
 	return nil
 }
+
+// removeChunks removes the chunks not referenced by the compacted tables anymore. With the deletion delay, they are
+// removed along the source files of the tables still referencing them, otherwise right away as the source files
+// already are.
+func (c *Compactor) removeChunks(ctx context.Context, chunks []chunkRef) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	if c.deletionQueue != nil {
+		c.deletionQueue.addChunks(chunks, time.Now())
+		return nil
+	}
+	level.Info(pkg_util.Logger).Log("msg", "removing replaced chunks from storage", "count", len(chunks))
+	return deleteChunks(ctx, c.chunkClient, chunks)
+}
+
+// pendingDeleteRequests returns the delete requests which are not processed yet and can't be cancelled anymore.
+func (c *Compactor) pendingDeleteRequests(ctx context.Context) ([]*DeleteRequest, error) {
+	requests, err := c.deleteRequests.listAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := model.Now()
+	pending := requests[:0]
+	for _, req := range requests {
+		if req.Status == StatusReceived && now.Sub(req.CreatedAt) > c.cfg.DeleteRequestCancelPeriod {
+			pending = append(pending, req)
+		}
+	}
+	return pending, nil
+}
+
+// ServeHTTP serves the compactor ring status page.
+func (c *Compactor) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if c.ring == nil {
+		http.Error(w, "compactor sharding is disabled", http.StatusNotFound)
+		return
+	}
+	c.ring.ServeHTTP(w, req)
+}
@@ -0,0 +1,168 @@
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/famarks/loki/pkg/logql"
+)
+
+// DeleteRequestsPrefix is the prefix of the delete requests in the shared store.
+const DeleteRequestsPrefix = "delete_requests/"
+
+// DeleteRequestStatus is the processing status of a delete request.
+type DeleteRequestStatus string
+
+const (
+	StatusReceived  DeleteRequestStatus = "received"
+	StatusProcessed DeleteRequestStatus = "processed"
+)
+
+// DeleteRequest asks for the removal of the log entries of the streams matching Query between StartTime and EndTime.
+type DeleteRequest struct {
+	RequestID string              `json:"request_id"`
+	UserID    string              `json:"-"`
+	Query     string              `json:"query"`
+	StartTime model.Time          `json:"start_time"`
+	EndTime   model.Time          `json:"end_time"`
+	CreatedAt model.Time          `json:"created_at"`
+	Status    DeleteRequestStatus `json:"status"`
+
+	matchers []*labels.Matcher
+}
+
+// matches tells whether the request applies to the entries of a stream between from and through.
+func (r *DeleteRequest) matches(lbls labels.Labels, from, through model.Time) bool {
+	if through.Before(r.StartTime) || from.After(r.EndTime) {
+		return false
+	}
+	return matchAll(r.matchers, lbls)
+}
+
+// covers tells whether all the entries between from and through are deleted by the request.
+func (r *DeleteRequest) covers(from, through model.Time) bool {
+	return !from.Before(r.StartTime) && !through.After(r.EndTime)
+}
+
+func (r *DeleteRequest) parseQuery() error {
+	var err error
+	r.matchers, err = logql.ParseMatchers(r.Query)
+	return err
+}
+
+func generateRequestID(r *DeleteRequest) string {
+	hasher := fnv.New64a()
+	_, _ = hasher.Write([]byte(fmt.Sprintf("%s:%s:%d:%d:%d", r.UserID, r.Query, r.StartTime, r.EndTime, r.CreatedAt)))
+	return fmt.Sprintf("%016x", hasher.Sum64())
+}
+
+// validRequestID tells whether the id is one generateRequestID could have returned, for the ids given by the clients
+// not to reach the objects of other tenants.
+func validRequestID(requestID string) bool {
+	if len(requestID) != 16 {
+		return false
+	}
+	for _, r := range requestID {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// deleteRequestsStore keeps the delete requests in the shared store, one object per request under <userID>/<requestID>.json.
+type deleteRequestsStore struct {
+	objectClient chunk.ObjectClient
+}
+
+func newDeleteRequestsStore(objectClient chunk.ObjectClient) *deleteRequestsStore {
+	return &deleteRequestsStore{objectClient: objectClient}
+}
+
+func requestObjectKey(userID, requestID string) string {
+	return userID + delimiter + requestID + ".json"
+}
+
+// put adds the request or updates it if it already exists.
+func (s *deleteRequestsStore) put(ctx context.Context, r *DeleteRequest) error {
+	buf, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return s.objectClient.PutObject(ctx, requestObjectKey(r.UserID, r.RequestID), bytes.NewReader(buf))
+}
+
+func (s *deleteRequestsStore) get(ctx context.Context, userID, requestID string) (*DeleteRequest, error) {
+	reader, err := s.objectClient.GetObject(ctx, requestObjectKey(userID, requestID))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &DeleteRequest{UserID: userID}
+	if err := json.Unmarshal(buf, r); err != nil {
+		return nil, fmt.Errorf("failed to decode delete request %s of user %s: %w", requestID, userID, err)
+	}
+	if err := r.parseQuery(); err != nil {
+		return nil, fmt.Errorf("invalid query of delete request %s of user %s: %w", requestID, userID, err)
+	}
+	return r, nil
+}
+
+func (s *deleteRequestsStore) remove(ctx context.Context, userID, requestID string) error {
+	return s.objectClient.DeleteObject(ctx, requestObjectKey(userID, requestID))
+}
+
+// list returns the requests of a user, sorted by creation time.
+func (s *deleteRequestsStore) list(ctx context.Context, userID string) ([]*DeleteRequest, error) {
+	objects, _, err := s.objectClient.List(ctx, userID+delimiter, delimiter)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := make([]*DeleteRequest, 0, len(objects))
+	for _, object := range objects {
+		requestID := strings.TrimSuffix(strings.TrimPrefix(object.Key, userID+delimiter), ".json")
+		r, err := s.get(ctx, userID, requestID)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, r)
+	}
+
+	sort.Slice(requests, func(i, j int) bool { return requests[i].CreatedAt < requests[j].CreatedAt })
+	return requests, nil
+}
+
+// listAll returns the requests of every user.
+func (s *deleteRequestsStore) listAll(ctx context.Context) ([]*DeleteRequest, error) {
+	_, users, err := s.objectClient.List(ctx, "", delimiter)
+	if err != nil {
+		return nil, err
+	}
+
+	var requests []*DeleteRequest
+	for _, user := range users {
+		userRequests, err := s.list(ctx, strings.TrimSuffix(string(user), delimiter))
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, userRequests...)
+	}
+	return requests, nil
+}
@@ -0,0 +1,108 @@
+package compactor
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/prometheus/common/model"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+
+	"github.com/famarks/loki/pkg/loghttp"
+	serverutil "github.com/famarks/loki/pkg/util/server"
+)
+
+// AddDeleteRequestHandler registers a request to delete the log entries of the selected streams within a time range.
+func (c *Compactor) AddDeleteRequestHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, err.Error()), w)
+		return
+	}
+
+	params, err := loghttp.ParseDeleteRequest(r)
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, err.Error()), w)
+		return
+	}
+
+	req := &DeleteRequest{
+		UserID:    userID,
+		Query:     params.Query,
+		StartTime: model.TimeFromUnixNano(params.Start.UnixNano()),
+		EndTime:   model.TimeFromUnixNano(params.End.UnixNano()),
+		CreatedAt: model.Now(),
+		Status:    StatusReceived,
+	}
+	req.RequestID = generateRequestID(req)
+
+	if err := c.deleteRequests.put(r.Context(), req); err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAllDeleteRequestsHandler lists the delete requests of the tenant.
+func (c *Compactor) GetAllDeleteRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, err.Error()), w)
+		return
+	}
+
+	requests, err := c.deleteRequests.list(r.Context(), userID)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(requests); err != nil {
+		serverutil.WriteError(err, w)
+	}
+}
+
+// CancelDeleteRequestHandler cancels a delete request of the tenant, which is only possible until the cancel period
+// is over since the compactor can start processing the request afterwards.
+func (c *Compactor) CancelDeleteRequestHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, err.Error()), w)
+		return
+	}
+
+	requestID := r.Form.Get("request_id")
+	if requestID == "" {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "request_id is required"), w)
+		return
+	}
+	if !validRequestID(requestID) {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "invalid request_id %q", requestID), w)
+		return
+	}
+
+	req, err := c.deleteRequests.get(r.Context(), userID, requestID)
+	if err == chunk.ErrStorageObjectNotFound {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusNotFound, "delete request %s not found", requestID), w)
+		return
+	}
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+
+	if req.Status != StatusReceived || model.Now().Sub(req.CreatedAt) > c.cfg.DeleteRequestCancelPeriod {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "delete request %s can't be cancelled anymore", requestID), w)
+		return
+	}
+
+	if err := c.deleteRequests.remove(r.Context(), userID, requestID); err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
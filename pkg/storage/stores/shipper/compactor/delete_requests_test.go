@@ -0,0 +1,102 @@
+package compactor
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk/local"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+)
+
+func TestDeleteRequestsHandlers(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "delete-requests")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: tempDir})
+	require.NoError(t, err)
+
+	c := &Compactor{
+		cfg:            Config{DeleteRequestCancelPeriod: time.Hour},
+		deleteRequests: newDeleteRequestsStore(objectClient),
+	}
+
+	do := func(handler http.HandlerFunc, userID, method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		require.NoError(t, req.ParseForm())
+		rec := httptest.NewRecorder()
+		handler(rec, req.WithContext(user.InjectOrgID(req.Context(), userID)))
+		return rec
+	}
+
+	rec := do(c.AddDeleteRequestHandler, "1", http.MethodPost, `/loki/api/v1/delete?query={app="foo"}&start=1000&end=2000`)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	// the requests are listed by creation time, in milliseconds.
+	time.Sleep(2 * time.Millisecond)
+	rec = do(c.AddDeleteRequestHandler, "1", http.MethodPost, `/loki/api/v1/delete?query={app="bar"}`)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	rec = do(c.AddDeleteRequestHandler, "2", http.MethodPost, `/loki/api/v1/delete?query={app="foo"}`)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	rec = do(c.AddDeleteRequestHandler, "1", http.MethodPost, `/loki/api/v1/delete?query=foo`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = do(c.GetAllDeleteRequestsHandler, "1", http.MethodGet, "/loki/api/v1/delete")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var requests []DeleteRequest
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &requests))
+	require.Len(t, requests, 2)
+	require.Equal(t, `{app="foo"}`, requests[0].Query)
+	require.Equal(t, model.TimeFromUnix(1000), requests[0].StartTime)
+	require.Equal(t, model.TimeFromUnix(2000), requests[0].EndTime)
+	require.Equal(t, StatusReceived, requests[0].Status)
+
+	all, err := c.deleteRequests.listAll(context.Background())
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+
+	// requests can't be cancelled by other tenants, even with a request id out of their requests.
+	rec = do(c.CancelDeleteRequestHandler, "2", http.MethodDelete, "/loki/api/v1/delete?request_id="+requests[0].RequestID)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	rec = do(c.CancelDeleteRequestHandler, "2", http.MethodDelete, "/loki/api/v1/delete?request_id="+url.QueryEscape("../1/"+requests[0].RequestID))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	_, err = c.deleteRequests.get(context.Background(), "1", requests[0].RequestID)
+	require.NoError(t, err)
+
+	rec = do(c.CancelDeleteRequestHandler, "1", http.MethodDelete, "/loki/api/v1/delete?request_id="+requests[0].RequestID)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	remaining, err := c.deleteRequests.list(context.Background(), "1")
+	require.NoError(t, err)
+	require.Len(t, remaining, 1)
+	require.Equal(t, requests[1].RequestID, remaining[0].RequestID)
+
+	// processed requests can't be cancelled anymore, and neither can the pending ones past the cancel period.
+	remaining[0].Status = StatusProcessed
+	require.NoError(t, c.deleteRequests.put(context.Background(), remaining[0]))
+	rec = do(c.CancelDeleteRequestHandler, "1", http.MethodDelete, "/loki/api/v1/delete?request_id="+remaining[0].RequestID)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	others, err := c.deleteRequests.list(context.Background(), "2")
+	require.NoError(t, err)
+	others[0].CreatedAt = model.Now().Add(-2 * time.Hour)
+	require.NoError(t, c.deleteRequests.put(context.Background(), others[0]))
+
+	pending, err := c.pendingDeleteRequests(context.Background())
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, "2", pending[0].UserID)
+	rec = do(c.CancelDeleteRequestHandler, "2", http.MethodDelete, "/loki/api/v1/delete?request_id="+pending[0].RequestID)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
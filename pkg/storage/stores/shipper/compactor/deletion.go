@@ -0,0 +1,298 @@
+package compactor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"go.etcd.io/bbolt"
+
+	"github.com/famarks/loki/pkg/chunkenc"
+	"github.com/famarks/loki/pkg/logproto"
+	"github.com/famarks/loki/pkg/logql"
+	loki_util "github.com/famarks/loki/pkg/util"
+)
+
+const (
+	// sizes of the rewritten chunks, they only ever hold fewer entries than the chunk they replace.
+	rewrittenChunkBlockSize  = 256 * 1024
+	rewrittenChunkTargetSize = 0
+)
+
+// rewrittenChunk is the chunk replacing a chunk partially covered by a delete request.
+type rewrittenChunk struct {
+	chunkID       string
+	from, through model.Time
+	metric        labels.Labels
+}
+
+// deletion applies the pending delete requests to the tables processed by a compaction run.
+// Chunks fully covered by a request are dropped, the ones partially covered are rewritten without the deleted
+// entries. Since the chunks can be referenced by several tables, the replaced chunks are only removed from the
+// store once every table got processed, along the source files of the compacted tables still referencing them.
+type deletion struct {
+	requests     map[string][]*DeleteRequest
+	chunkClient  chunk.Client
+	schemaConfig chunk.SchemaConfig
+
+	// rewritten chunks by id of the chunk they replace, nil when no entry is left.
+	rewritten map[string]*rewrittenChunk
+	// chunks which are not referenced by the processed tables anymore.
+	replaced map[string]chunkRef
+}
+
+func newDeletion(requests []*DeleteRequest, chunkClient chunk.Client, schemaConfig chunk.SchemaConfig) *deletion {
+	d := &deletion{
+		requests:     map[string][]*DeleteRequest{},
+		chunkClient:  chunkClient,
+		schemaConfig: schemaConfig,
+		rewritten:    map[string]*rewrittenChunk{},
+		replaced:     map[string]chunkRef{},
+	}
+	for _, r := range requests {
+		d.requests[r.UserID] = append(d.requests[r.UserID], r)
+	}
+	return d
+}
+
+// apply removes the entries of the deleted chunks from the db of the table, rewriting the partially deleted ones.
+// It returns whether the db was changed.
+func (d *deletion) apply(ctx context.Context, tableName string, db *bbolt.DB) (bool, error) {
+	allSeries, err := readSeries(db)
+	if err != nil {
+		return false, err
+	}
+
+	var (
+		keysToPurge  [][]byte
+		entriesToAdd []indexEntry
+	)
+	for _, s := range allSeries {
+		requests := d.requests[s.userID]
+		if len(requests) == 0 {
+			continue
+		}
+
+		kept := 0
+		for _, c := range s.chunks {
+			var matching []*DeleteRequest
+			covered := false
+			for _, r := range requests {
+				if r.matches(s.labels, c.from, c.through) {
+					matching = append(matching, r)
+					covered = covered || r.covers(c.from, c.through)
+				}
+			}
+			if len(matching) == 0 {
+				kept++
+				continue
+			}
+
+			if covered {
+				keysToPurge = append(keysToPurge, c.key)
+				d.replaced[c.chunkID] = c
+				continue
+			}
+
+			rc, err := d.rewrite(ctx, c, matching)
+			if err != nil {
+				return false, err
+			}
+			// the chunk holds no deleted entry, it was already rewritten when the requests are applied again after a
+			// failed run.
+			if rc != nil && rc.chunkID == c.chunkID {
+				kept++
+				continue
+			}
+
+			keysToPurge = append(keysToPurge, c.key)
+			d.replaced[c.chunkID] = c
+			if rc == nil {
+				continue
+			}
+
+			entries, err := rewrittenIndexEntries(d.schemaConfig, tableName, s.userID, rc)
+			if err != nil {
+				return false, err
+			}
+			entriesToAdd = append(entriesToAdd, entries...)
+			kept++
+		}
+
+		if kept == 0 {
+			keysToPurge = append(keysToPurge, s.keys...)
+		}
+	}
+
+	if len(keysToPurge) == 0 {
+		return false, nil
+	}
+
+	level.Info(util.Logger).Log("msg", "applying delete requests to index", "table", tableName, "removed", len(keysToPurge), "added", len(entriesToAdd))
+
+	if err := updateIndex(db, keysToPurge, entriesToAdd); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// rewrite stores a copy of the chunk without the entries deleted by the requests. It returns nil if no entry is left,
+// and the chunk itself if none of its entries is deleted.
+func (d *deletion) rewrite(ctx context.Context, c chunkRef, requests []*DeleteRequest) (*rewrittenChunk, error) {
+	if rc, ok := d.rewritten[c.chunkID]; ok {
+		return rc, nil
+	}
+
+	key, err := chunk.ParseExternalKey(c.userID, c.chunkID)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := d.chunkClient.GetChunks(ctx, []chunk.Chunk{key})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch chunk %s: %w", c.chunkID, err)
+	}
+	old := chunks[0]
+
+	facade, ok := old.Data.(*chunkenc.Facade)
+	if !ok {
+		return nil, fmt.Errorf("unexpected encoding of chunk %s", c.chunkID)
+	}
+	enc := chunkenc.EncGZIP
+	if mc, ok := facade.LokiChunk().(*chunkenc.MemChunk); ok {
+		enc = mc.Encoding()
+	}
+
+	it, err := facade.LokiChunk().Iterator(ctx, time.Unix(0, 0), time.Unix(0, math.MaxInt64), logproto.FORWARD, old.Metric, logql.NoopPipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	mc := chunkenc.NewMemChunk(enc, rewrittenChunkBlockSize, rewrittenChunkTargetSize)
+	entries, deletedEntries := 0, 0
+	for it.Next() {
+		entry := it.Entry()
+		if deleted(requests, entry.Timestamp) {
+			deletedEntries++
+			continue
+		}
+		if err := mc.Append(&entry); err != nil {
+			return nil, err
+		}
+		entries++
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+
+	if deletedEntries == 0 {
+		rc := &rewrittenChunk{chunkID: c.chunkID, from: c.from, through: c.through, metric: old.Metric}
+		d.rewritten[c.chunkID] = rc
+		return rc, nil
+	}
+	if entries == 0 {
+		d.rewritten[c.chunkID] = nil
+		return nil, nil
+	}
+
+	if err := mc.Close(); err != nil {
+		return nil, err
+	}
+	from, through := loki_util.RoundToMilliseconds(mc.Bounds())
+	newChunk := chunk.NewChunk(c.userID, old.Fingerprint, old.Metric, chunkenc.NewFacade(mc, rewrittenChunkBlockSize, rewrittenChunkTargetSize), from, through)
+	if err := newChunk.Encode(); err != nil {
+		return nil, err
+	}
+	// the key of a chunk holds its checksum, a chunk holding the same entries would replace itself.
+	if newChunk.ExternalKey() == c.chunkID {
+		rc := &rewrittenChunk{chunkID: c.chunkID, from: c.from, through: c.through, metric: old.Metric}
+		d.rewritten[c.chunkID] = rc
+		return rc, nil
+	}
+	if err := d.chunkClient.PutChunks(ctx, []chunk.Chunk{newChunk}); err != nil {
+		return nil, err
+	}
+
+	rc := &rewrittenChunk{chunkID: newChunk.ExternalKey(), from: from, through: through, metric: old.Metric}
+	d.rewritten[c.chunkID] = rc
+	return rc, nil
+}
+
+func deleted(requests []*DeleteRequest, ts time.Time) bool {
+	t := model.TimeFromUnixNano(ts.UnixNano())
+	for _, r := range requests {
+		if !t.Before(r.StartTime) && !t.After(r.EndTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// rewrittenIndexEntries returns the entries of the table referencing the rewritten chunk.
+func rewrittenIndexEntries(schemaConfig chunk.SchemaConfig, tableName, userID string, rc *rewrittenChunk) ([]indexEntry, error) {
+	schema, err := seriesStoreSchemaFor(schemaConfig, rc.from)
+	if err != nil {
+		return nil, err
+	}
+
+	metricName := rc.metric.Get(labels.MetricName)
+	chunkEntries, err := schema.GetChunkWriteEntries(rc.from, rc.through, userID, metricName, rc.metric, rc.chunkID)
+	if err != nil {
+		return nil, err
+	}
+	_, labelEntries, err := schema.GetCacheKeysAndLabelWriteEntries(rc.from, rc.through, userID, metricName, rc.metric, rc.chunkID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range labelEntries {
+		chunkEntries = append(chunkEntries, e...)
+	}
+
+	result := make([]indexEntry, 0, len(chunkEntries))
+	for _, e := range chunkEntries {
+		if e.TableName != tableName {
+			continue
+		}
+		result = append(result, indexEntry{
+			k: []byte(e.HashValue + "\x00" + string(e.RangeValue)),
+			v: e.Value,
+		})
+	}
+	return result, nil
+}
+
+func seriesStoreSchemaFor(schemaConfig chunk.SchemaConfig, t model.Time) (chunk.SeriesStoreSchema, error) {
+	for i := len(schemaConfig.Configs) - 1; i >= 0; i-- {
+		cfg := schemaConfig.Configs[i]
+		if cfg.From.Time > t {
+			continue
+		}
+
+		schema, err := cfg.CreateSchema()
+		if err != nil {
+			return nil, err
+		}
+		seriesStoreSchema, ok := schema.(chunk.SeriesStoreSchema)
+		if !ok {
+			return nil, fmt.Errorf("schema %s does not support rewriting chunks", cfg.Schema)
+		}
+		return seriesStoreSchema, nil
+	}
+	return nil, fmt.Errorf("no schema config found for time %v", t)
+}
+
+// replacedChunks returns the chunks which are not referenced by the processed tables anymore, they must only be
+// removed once every table was processed successfully.
+func (d *deletion) replacedChunks() []chunkRef {
+	chunks := make([]chunkRef, 0, len(d.replaced))
+	for _, c := range d.replaced {
+		chunks = append(chunks, c)
+	}
+	return chunks
+}
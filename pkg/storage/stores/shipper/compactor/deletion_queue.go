@@ -0,0 +1,116 @@
+package compactor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+)
+
+// deletionQueue holds the source files which were compacted, until they can be safely removed from the storage.
+// Deleting the source files right after uploading the compacted file could fail queriers which have just listed
+// the table and are about to download them, so we wait for the deletion delay to give them a chance to sync.
+// The chunks removed from the compacted files are held as well, since the source files still reference them: they
+// are only removed from the storage along the source files queued before them.
+// The queue is not persisted, source files left in the storage after a restart would just be compacted again, while
+// the chunks would be left in the storage.
+type deletionQueue struct {
+	storageClient chunk.ObjectClient
+	chunkClient   chunk.Client
+	delay         time.Duration
+
+	objects map[string]time.Time
+	chunks  map[string]queuedChunk
+	mtx     sync.Mutex
+}
+
+type queuedChunk struct {
+	chunkRef
+	removedAt time.Time
+}
+
+func newDeletionQueue(storageClient chunk.ObjectClient, chunkClient chunk.Client, delay time.Duration) *deletionQueue {
+	return &deletionQueue{
+		storageClient: storageClient,
+		chunkClient:   chunkClient,
+		delay:         delay,
+		objects:       map[string]time.Time{},
+		chunks:        map[string]queuedChunk{},
+	}
+}
+
+// add queues the objects for deletion on behalf of a compaction which completed at compactedAt.
+func (q *deletionQueue) add(objects []chunk.StorageObject, compactedAt time.Time) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	for _, object := range objects {
+		q.objects[object.Key] = compactedAt
+	}
+}
+
+// addChunks queues the chunks for deletion once they were removed from the compacted files at removedAt, the source
+// files referencing them having been queued before.
+func (q *deletionQueue) addChunks(chunks []chunkRef, removedAt time.Time) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	for _, c := range chunks {
+		q.chunks[c.chunkID] = queuedChunk{chunkRef: c, removedAt: removedAt}
+	}
+}
+
+// contains tells whether the object is already compacted and waiting for deletion.
+func (q *deletionQueue) contains(objectKey string) bool {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	_, ok := q.objects[objectKey]
+	return ok
+}
+
+// deleteExpired removes the objects from storage which were compacted more than the deletion delay ago, and then the
+// chunks removed from the compacted files more than the deletion delay ago, which are not referenced anymore.
+func (q *deletionQueue) deleteExpired(ctx context.Context, now time.Time) error {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	for objectKey, compactedAt := range q.objects {
+		if now.Sub(compactedAt) < q.delay {
+			continue
+		}
+
+		level.Info(util.Logger).Log("msg", "removing compacted source file from storage", "objectKey", objectKey)
+		err := q.storageClient.DeleteObject(ctx, objectKey)
+		if err != nil && err != chunk.ErrStorageObjectNotFound {
+			return err
+		}
+
+		delete(q.objects, objectKey)
+	}
+
+	var expired []chunkRef
+	for _, c := range q.chunks {
+		if now.Sub(c.removedAt) >= q.delay {
+			expired = append(expired, c.chunkRef)
+		}
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+
+	level.Info(util.Logger).Log("msg", "removing chunks not referenced anymore from storage", "count", len(expired))
+	for _, c := range expired {
+		err := q.chunkClient.DeleteChunk(ctx, c.userID, c.chunkID)
+		if err != nil && err != chunk.ErrStorageObjectNotFound {
+			return err
+		}
+
+		delete(q.chunks, c.chunkID)
+	}
+
+	return nil
+}
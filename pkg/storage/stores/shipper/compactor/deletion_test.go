@@ -0,0 +1,149 @@
+package compactor
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/chunk/local"
+	"github.com/cortexproject/cortex/pkg/chunk/objectclient"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+
+	"github.com/famarks/loki/pkg/chunkenc"
+	"github.com/famarks/loki/pkg/logproto"
+	"github.com/famarks/loki/pkg/logql"
+	loki_util "github.com/famarks/loki/pkg/util"
+)
+
+func TestDeletion_Apply(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "deletion")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	fsClient, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(tempDir, "chunks")})
+	require.NoError(t, err)
+	chunkClient := objectclient.NewClient(fsClient, nil)
+
+	db, err := local.OpenBoltdbFile(filepath.Join(tempDir, "db"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	periodConfig := newTestPeriodConfig()
+	schema := newTestSchema(t)
+	start := model.TimeFromUnix(18000 * 24 * 3600)
+	tableName := periodConfig.IndexTables.TableFor(start)
+
+	// 10 entries 1m apart in each stream.
+	foo := storeTestChunk(t, db, schema, chunkClient, "1", `{app="foo"}`, start, 10)
+	bar := storeTestChunk(t, db, schema, chunkClient, "1", `{app="bar"}`, start, 10)
+	baz := storeTestChunk(t, db, schema, chunkClient, "1", `{app="baz"}`, start, 10)
+	other := storeTestChunk(t, db, schema, chunkClient, "2", `{app="foo"}`, start, 10)
+	entriesBefore := countEntries(t, db)
+
+	requests := []*DeleteRequest{
+		// entries 3 to 5 of foo.
+		{UserID: "1", Query: `{app="foo"}`, StartTime: start.Add(3 * time.Minute), EndTime: start.Add(5 * time.Minute)},
+		// all of bar.
+		{UserID: "1", Query: `{app="bar"}`, StartTime: 0, EndTime: start.Add(time.Hour)},
+		// after the entries of baz.
+		{UserID: "1", Query: `{app="baz"}`, StartTime: start.Add(time.Hour), EndTime: start.Add(2 * time.Hour)},
+	}
+	for _, r := range requests {
+		require.NoError(t, r.parseQuery())
+	}
+
+	d := newDeletion(requests, chunkClient, chunk.SchemaConfig{Configs: []chunk.PeriodConfig{periodConfig}})
+	changed, err := d.apply(context.Background(), tableName, db)
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	replaced := make([]string, 0, len(d.replaced))
+	for id := range d.replaced {
+		replaced = append(replaced, id)
+	}
+	sort.Strings(replaced)
+	expectedReplaced := []string{foo, bar}
+	sort.Strings(expectedReplaced)
+	require.Equal(t, expectedReplaced, replaced)
+
+	// the chunk entry of foo is replaced by the one of its rewritten chunk and bar lost its chunk, series and label entries.
+	require.Equal(t, entriesBefore-3, countEntries(t, db))
+
+	allSeries, err := readSeries(db)
+	require.NoError(t, err)
+	chunks := map[string]string{}
+	for _, s := range allSeries {
+		require.Len(t, s.chunks, 1)
+		chunks[s.userID+s.labels.String()] = s.chunks[0].chunkID
+	}
+	require.Len(t, chunks, 3)
+	require.Equal(t, baz, chunks[`1{app="baz"}`])
+	require.Equal(t, other, chunks[`2{app="foo"}`])
+
+	rewritten := chunks[`1{app="foo"}`]
+	require.NotEqual(t, foo, rewritten)
+	require.Equal(t, []int64{0, 1, 2, 6, 7, 8, 9}, readTestChunk(t, chunkClient, "1", rewritten, start))
+
+	require.NoError(t, deleteChunks(context.Background(), chunkClient, d.replacedChunks()))
+	for _, id := range []string{foo, bar} {
+		c, err := chunk.ParseExternalKey("1", id)
+		require.NoError(t, err)
+		_, err = chunkClient.GetChunks(context.Background(), []chunk.Chunk{c})
+		require.Error(t, err)
+	}
+}
+
+// storeTestChunk stores a chunk with n entries 1m apart from start and writes its index entries.
+func storeTestChunk(t *testing.T, db *bbolt.DB, schema chunk.SeriesStoreSchema, client chunk.Client, userID, stream string, start model.Time, n int) string {
+	lbls, err := loki_util.ParseLabels(stream)
+	require.NoError(t, err)
+	lbls = append(lbls, labels.Label{Name: labels.MetricName, Value: "logs"})
+	sort.Sort(lbls)
+
+	mc := chunkenc.NewMemChunk(chunkenc.EncSnappy, 256*1024, 0)
+	for i := 0; i < n; i++ {
+		require.NoError(t, mc.Append(&logproto.Entry{Timestamp: start.Add(time.Duration(i) * time.Minute).Time(), Line: "line"}))
+	}
+	require.NoError(t, mc.Close())
+
+	from, through := loki_util.RoundToMilliseconds(mc.Bounds())
+	c := chunk.NewChunk(userID, model.Fingerprint(lbls.Hash()), lbls, chunkenc.NewFacade(mc, 256*1024, 0), from, through)
+	require.NoError(t, c.Encode())
+	require.NoError(t, client.PutChunks(context.Background(), []chunk.Chunk{c}))
+
+	writeIndexEntries(t, db, schema, userID, lbls, c.ExternalKey(), from, through)
+	return c.ExternalKey()
+}
+
+// readTestChunk returns the minutes since start of the entries of a chunk.
+func readTestChunk(t *testing.T, client chunk.Client, userID, chunkID string, start model.Time) []int64 {
+	c, err := chunk.ParseExternalKey(userID, chunkID)
+	require.NoError(t, err)
+	chunks, err := client.GetChunks(context.Background(), []chunk.Chunk{c})
+	require.NoError(t, err)
+
+	it, err := chunks[0].Data.(*chunkenc.Facade).LokiChunk().Iterator(context.Background(), time.Unix(0, 0), time.Unix(0, math.MaxInt64), logproto.FORWARD, chunks[0].Metric, logql.NoopPipeline)
+	require.NoError(t, err)
+	defer it.Close()
+
+	var minutes []int64
+	for it.Next() {
+		minutes = append(minutes, int64(it.Entry().Timestamp.Sub(start.Time())/time.Minute))
+	}
+	require.NoError(t, it.Error())
+	return minutes
+}
@@ -0,0 +1,260 @@
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"go.etcd.io/bbolt"
+
+	"github.com/famarks/loki/pkg/chunkenc"
+	"github.com/famarks/loki/pkg/logproto"
+	"github.com/famarks/loki/pkg/logql"
+)
+
+// IntegrityPrefix is the prefix of the integrity reports of the tables, in the shared store.
+const IntegrityPrefix = "integrity/"
+
+const (
+	integrityResultOK      = "ok"
+	integrityResultCorrupt = "corrupt"
+	integrityResultFailed  = "failed"
+)
+
+// CorruptChunk is a chunk of the store which failed to be decoded.
+type CorruptChunk struct {
+	UserID    string     `json:"tenant"`
+	ChunkID   string     `json:"chunk"`
+	Error     string     `json:"error"`
+	CheckedAt model.Time `json:"checked_at"`
+}
+
+// IntegrityReport is the result of the last check of the chunks of a table.
+type IntegrityReport struct {
+	Table         string         `json:"table"`
+	CheckedAt     model.Time     `json:"checked_at"`
+	CheckedChunks int            `json:"checked_chunks"`
+	Corrupt       []CorruptChunk `json:"corrupt"`
+}
+
+// integrity checks a sample of the chunks of a table by run, the table checked the longest time ago, detecting the
+// corrupt chunks before the queries fetch them. A chunk is corrupt when its checksum, its metadata or its blocks don't
+// match its content, or when its entries fail to be decoded. The chunks failing to be fetched aren't reported as
+// corrupt, the store can be unavailable. The corrupt chunks stay in the report of their table while the index
+// references them.
+type integrity struct {
+	sampleSize  int
+	chunkClient chunk.Client
+	reports     chunk.ObjectClient
+	metrics     *metrics
+
+	// state of the current run.
+	now     model.Time
+	due     string
+	current map[string]*IntegrityReport
+}
+
+func newIntegrity(cfg Config, chunkClient chunk.Client, reports chunk.ObjectClient, metrics *metrics) *integrity {
+	return &integrity{
+		sampleSize:  cfg.IntegrityCheckSampleSize,
+		chunkClient: chunkClient,
+		reports:     reports,
+		metrics:     metrics,
+	}
+}
+
+// start starts a run, picking the table to check among the tables: the first one never checked, or the one checked
+// the longest time ago.
+func (i *integrity) start(ctx context.Context, now model.Time, tables []string) error {
+	reports, err := readIntegrityReports(ctx, i.reports)
+	if err != nil {
+		return err
+	}
+
+	i.now, i.due = now, ""
+	i.current = make(map[string]*IntegrityReport, len(reports))
+	for _, report := range reports {
+		i.current[report.Table] = report
+	}
+
+	sorted := append([]string(nil), tables...)
+	sort.Strings(sorted)
+	var oldest model.Time
+	for _, tableName := range sorted {
+		report, ok := i.current[tableName]
+		if !ok {
+			i.due = tableName
+			return nil
+		}
+		if i.due == "" || report.CheckedAt < oldest {
+			i.due, oldest = tableName, report.CheckedAt
+		}
+	}
+	return nil
+}
+
+// isDue tells whether the chunks of the table are checked by this run.
+func (i *integrity) isDue(tableName string) bool {
+	return tableName == i.due
+}
+
+// check checks a sample of the chunks of the db of the table and stores the report of the table.
+func (i *integrity) check(ctx context.Context, tableName string, db *bbolt.DB) error {
+	allSeries, err := readSeries(db)
+	if err != nil {
+		return err
+	}
+
+	var chunks []chunkRef
+	referenced := map[string]struct{}{}
+	for _, s := range allSeries {
+		for _, c := range s.chunks {
+			// the chunks overlapping several days are referenced by several buckets.
+			if _, ok := referenced[c.chunkID]; ok {
+				continue
+			}
+			referenced[c.chunkID] = struct{}{}
+			chunks = append(chunks, c)
+		}
+	}
+
+	rand.Shuffle(len(chunks), func(a, b int) { chunks[a], chunks[b] = chunks[b], chunks[a] })
+	if len(chunks) > i.sampleSize {
+		chunks = chunks[:i.sampleSize]
+	}
+
+	// the corrupt chunks of the previous checks are kept unless they are checked again.
+	report := &IntegrityReport{Table: tableName, CheckedAt: i.now}
+	if previous, ok := i.current[tableName]; ok {
+		sampled := make(map[string]struct{}, len(chunks))
+		for _, c := range chunks {
+			sampled[c.chunkID] = struct{}{}
+		}
+		for _, c := range previous.Corrupt {
+			_, isReferenced := referenced[c.ChunkID]
+			_, isSampled := sampled[c.ChunkID]
+			if isReferenced && !isSampled {
+				report.Corrupt = append(report.Corrupt, c)
+			}
+		}
+	}
+
+	for _, c := range chunks {
+		result, err := i.checkChunk(ctx, c)
+		i.metrics.integrityCheckedChunksTotal.WithLabelValues(result).Inc()
+		switch result {
+		case integrityResultCorrupt:
+			level.Error(util.Logger).Log("msg", "found corrupt chunk", "table", tableName, "user", c.userID, "chunk", c.chunkID, "err", err)
+			report.Corrupt = append(report.Corrupt, CorruptChunk{UserID: c.userID, ChunkID: c.chunkID, Error: err.Error(), CheckedAt: i.now})
+		case integrityResultFailed:
+			level.Warn(util.Logger).Log("msg", "failed to fetch chunk for checking it", "table", tableName, "user", c.userID, "chunk", c.chunkID, "err", err)
+		}
+		report.CheckedChunks++
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	if err := i.reports.PutObject(ctx, tableName+".json", bytes.NewReader(data)); err != nil {
+		return err
+	}
+	i.current[tableName] = report
+	level.Info(util.Logger).Log("msg", "checked chunks of table", "table", tableName, "checked", report.CheckedChunks, "corrupt", len(report.Corrupt))
+	return nil
+}
+
+// checkChunk fetches and decodes the chunk, returning the result of the check along the error of the chunk.
+func (i *integrity) checkChunk(ctx context.Context, c chunkRef) (string, error) {
+	key, err := chunk.ParseExternalKey(c.userID, c.chunkID)
+	if err != nil {
+		return integrityResultCorrupt, err
+	}
+	// the chunk client checks the checksum and the metadata of the chunk, and its blocks by decoding it.
+	chunks, err := i.chunkClient.GetChunks(ctx, []chunk.Chunk{key})
+	if err != nil {
+		if isChunkDecodingErr(err) {
+			return integrityResultCorrupt, err
+		}
+		return integrityResultFailed, err
+	}
+
+	facade, ok := chunks[0].Data.(*chunkenc.Facade)
+	if !ok {
+		return integrityResultCorrupt, fmt.Errorf("unexpected encoding of chunk %s", c.chunkID)
+	}
+	it, err := facade.LokiChunk().Iterator(ctx, time.Unix(0, 0), time.Unix(0, math.MaxInt64), logproto.FORWARD, chunks[0].Metric, logql.NoopPipeline)
+	if err != nil {
+		return integrityResultCorrupt, err
+	}
+	defer it.Close()
+
+	for it.Next() {
+		// the entries are decoded by the iterator.
+	}
+	if err := it.Error(); err != nil {
+		return integrityResultCorrupt, err
+	}
+	return integrityResultOK, nil
+}
+
+// isChunkDecodingErr tells whether the chunk client failed to decode the fetched chunk, rather than to fetch it.
+func isChunkDecodingErr(err error) bool {
+	switch errors.Cause(err) {
+	case chunk.ErrInvalidChecksum, chunk.ErrWrongMetadata, chunk.ErrMetadataLength, chunk.ErrDataLength,
+		chunkenc.ErrInvalidChecksum, chunkenc.ErrInvalidSize, chunkenc.ErrInvalidFlag:
+		return true
+	}
+	// see chunk.Decode and chunkenc.NewByteChunk for the errors without a value.
+	msg := err.Error()
+	for _, s := range []string{"when reading", "when decoding chunk metadata", "invalid magic number", "invalid version", "verifying"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// readIntegrityReports reads the reports of all the tables from the store.
+func readIntegrityReports(ctx context.Context, reports chunk.ObjectClient) ([]*IntegrityReport, error) {
+	objects, _, err := reports.List(ctx, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*IntegrityReport, 0, len(objects))
+	for _, object := range objects {
+		if !strings.HasSuffix(object.Key, ".json") {
+			continue
+		}
+		r, err := reports.GetObject(ctx, object.Key)
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		report := &IntegrityReport{}
+		if err := json.Unmarshal(data, report); err != nil {
+			return nil, fmt.Errorf("failed to decode integrity report %s: %w", object.Key, err)
+		}
+		result = append(result, report)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Table < result[j].Table })
+	return result, nil
+}
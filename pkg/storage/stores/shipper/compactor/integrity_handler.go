@@ -0,0 +1,52 @@
+package compactor
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/weaveworks/common/user"
+
+	serverutil "github.com/famarks/loki/pkg/util/server"
+)
+
+// IntegrityHandler reports the result of the last check of the chunks of every table, listing the corrupt chunks of
+// the tenant of the request only, or only of the tables with corrupt chunks of the tenant when the corrupt parameter
+// is true.
+func (c *Compactor) IntegrityHandler(w http.ResponseWriter, r *http.Request) {
+	tenant, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	reports, err := readIntegrityReports(r.Context(), c.integrity.reports)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+
+	for _, report := range reports {
+		corrupt := report.Corrupt[:0]
+		for _, chunk := range report.Corrupt {
+			if chunk.UserID == tenant {
+				corrupt = append(corrupt, chunk)
+			}
+		}
+		report.Corrupt = corrupt
+	}
+
+	if r.FormValue("corrupt") == "true" {
+		corrupt := reports[:0]
+		for _, report := range reports {
+			if len(report.Corrupt) > 0 {
+				corrupt = append(corrupt, report)
+			}
+		}
+		reports = corrupt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reports); err != nil {
+		serverutil.WriteError(err, w)
+	}
+}
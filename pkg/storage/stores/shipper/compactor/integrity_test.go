@@ -0,0 +1,101 @@
+package compactor
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cortexproject/cortex/pkg/chunk/local"
+	"github.com/cortexproject/cortex/pkg/chunk/objectclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/famarks/loki/pkg/storage/stores/util"
+)
+
+func TestIntegrity_Check(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "integrity")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	fsClient, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(tempDir, "chunks")})
+	require.NoError(t, err)
+	chunkClient := objectclient.NewClient(fsClient, nil)
+	reportsClient, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(tempDir, "reports")})
+	require.NoError(t, err)
+
+	db, err := local.OpenBoltdbFile(filepath.Join(tempDir, "db"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	periodConfig := newTestPeriodConfig()
+	schema := newTestSchema(t)
+	start := model.TimeFromUnix(18000 * 24 * 3600)
+	tableName := periodConfig.IndexTables.TableFor(start)
+	otherTableName := periodConfig.IndexTables.TableFor(start.Add(-periodConfig.IndexTables.Period))
+
+	storeTestChunk(t, db, schema, chunkClient, "1", `{app="foo"}`, start, 10)
+	bar := storeTestChunk(t, db, schema, chunkClient, "1", `{app="bar"}`, start, 10)
+
+	// flip a byte of a chunk.
+	path := filepath.Join(tempDir, "chunks", bar)
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	data[len(data)-10] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(path, data, 0666))
+
+	compactor := &Compactor{
+		integrity: newIntegrity(Config{IntegrityCheckSampleSize: 10}, chunkClient, util.NewPrefixedObjectClient(reportsClient, IntegrityPrefix), newMetrics(prometheus.NewRegistry())),
+	}
+	i := compactor.integrity
+
+	// the tables never checked are checked first.
+	require.NoError(t, i.start(context.Background(), start, []string{tableName, otherTableName}))
+	require.True(t, i.isDue(otherTableName))
+	require.NoError(t, i.start(context.Background(), start, []string{tableName}))
+	require.True(t, i.isDue(tableName))
+
+	require.NoError(t, i.check(context.Background(), tableName, db))
+	require.Equal(t, float64(1), testutil.ToFloat64(i.metrics.integrityCheckedChunksTotal.WithLabelValues(integrityResultOK)))
+	require.Equal(t, float64(1), testutil.ToFloat64(i.metrics.integrityCheckedChunksTotal.WithLabelValues(integrityResultCorrupt)))
+
+	// the corrupt chunks are reported while the index references them.
+	require.NoError(t, i.start(context.Background(), start.Add(1), []string{tableName}))
+	require.NoError(t, i.check(context.Background(), tableName, db))
+	report := i.current[tableName]
+	require.Equal(t, 2, report.CheckedChunks)
+	require.Len(t, report.Corrupt, 1)
+	require.Equal(t, "1", report.Corrupt[0].UserID)
+	require.Equal(t, bar, report.Corrupt[0].ChunkID)
+	require.Equal(t, start.Add(1), report.Corrupt[0].CheckedAt)
+
+	w := httptest.NewRecorder()
+	compactor.IntegrityHandler(w, httptest.NewRequest("GET", "/compactor/integrity?corrupt=true", nil).WithContext(user.InjectOrgID(context.Background(), "1")))
+	var response []*IntegrityReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, []*IntegrityReport{report}, response)
+
+	// the corrupt chunks of the other tenants are not served.
+	w = httptest.NewRecorder()
+	compactor.IntegrityHandler(w, httptest.NewRequest("GET", "/compactor/integrity?corrupt=true", nil).WithContext(user.InjectOrgID(context.Background(), "2")))
+	response = nil
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Empty(t, response)
+
+	w = httptest.NewRecorder()
+	compactor.IntegrityHandler(w, httptest.NewRequest("GET", "/compactor/integrity", nil))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
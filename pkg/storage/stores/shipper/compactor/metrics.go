@@ -14,6 +14,13 @@ type metrics struct {
 	compactTablesOperationTotal           *prometheus.CounterVec
 	compactTablesOperationDurationSeconds prometheus.Gauge
 	compactTablesOperationLastSuccess     prometheus.Gauge
+	tieringMovedChunksTotal               prometheus.Counter
+	tieringMovedBytesTotal                prometheus.Counter
+	tieringFailuresTotal                  prometheus.Counter
+	reencodedChunksTotal                  prometheus.Counter
+	reencodedBytesTotal                   *prometheus.CounterVec
+	usageMeasuredChunksTotal              prometheus.Counter
+	integrityCheckedChunksTotal           *prometheus.CounterVec
 }
 
 func newMetrics(r prometheus.Registerer) *metrics {
@@ -33,6 +40,41 @@ func newMetrics(r prometheus.Registerer) *metrics {
 			Name:      "compact_tables_operation_last_successful_run_timestamp_seconds",
 			Help:      "Unix timestamp of the last successful compaction run",
 		}),
+		tieringMovedChunksTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "compactor_tiering_moved_chunks_total",
+			Help:      "Total number of chunks moved to the cold tier",
+		}),
+		tieringMovedBytesTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "compactor_tiering_moved_bytes_total",
+			Help:      "Total size of the chunks moved to the cold tier",
+		}),
+		tieringFailuresTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "compactor_tiering_failures_total",
+			Help:      "Total number of chunks which failed to be moved to the cold tier",
+		}),
+		reencodedChunksTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "compactor_reencoded_chunks_total",
+			Help:      "Total number of chunks re-encoded",
+		}),
+		reencodedBytesTotal: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "compactor_reencoded_bytes_total",
+			Help:      "Total compressed size of the re-encoded chunks, before and after being re-encoded",
+		}, []string{"state"}),
+		usageMeasuredChunksTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "compactor_usage_measured_chunks_total",
+			Help:      "Total number of chunks fetched for measuring the storage used by the tenants",
+		}),
+		integrityCheckedChunksTotal: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki_boltdb_shipper",
+			Name:      "compactor_integrity_checked_chunks_total",
+			Help:      "Total number of chunks checked for their integrity by result, ok, corrupt or failed to be fetched",
+		}, []string{"result"}),
 	}
 
 	return &m
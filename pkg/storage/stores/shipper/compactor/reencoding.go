@@ -0,0 +1,252 @@
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	"go.etcd.io/bbolt"
+
+	"github.com/famarks/loki/pkg/chunkenc"
+	"github.com/famarks/loki/pkg/logproto"
+	"github.com/famarks/loki/pkg/logql"
+)
+
+// ReencodingPrefix is the prefix of the markers of the tables whose chunks are all re-encoded, in the shared store.
+const ReencodingPrefix = "reencoding/"
+
+// reencoding rewrites the chunks older than the min age with the encoding, along the compaction of the tables.
+// The key of a chunk holds its checksum, so a re-encoded chunk gets a new key: its index entries replace the ones of
+// the chunk it replaces in a single transaction of the db of the table. Like with the delete requests, the replaced
+// chunks can be referenced by several tables so they are only removed from the store once every table got processed.
+// The tables whose chunks are all re-encoded are marked in the shared store, for the next runs to skip them.
+type reencoding struct {
+	encoding     chunkenc.Encoding
+	minAge       time.Duration
+	maxChunks    int
+	chunkClient  chunk.Client
+	schemaConfig chunk.SchemaConfig
+	markers      chunk.ObjectClient
+	metrics      *metrics
+
+	// state of the current run.
+	now       model.Time
+	fetched   int
+	reencoded map[string]*rewrittenChunk
+	done      map[string]struct{}
+	completed []string
+
+	// chunks which are not referenced by the processed tables anymore, kept until a run succeeds to remove them.
+	replaced map[string]chunkRef
+	// chunks already in the encoding by table, for the tables not done yet not to fetch them on every run.
+	checked map[string]map[string]struct{}
+}
+
+func newReencoding(cfg Config, chunkClient chunk.Client, schemaConfig chunk.SchemaConfig, markers chunk.ObjectClient, metrics *metrics) (*reencoding, error) {
+	encoding, err := chunkenc.ParseEncoding(cfg.ReencodingEncoding)
+	if err != nil {
+		return nil, err
+	}
+	return &reencoding{
+		encoding:     encoding,
+		minAge:       cfg.ReencodingMinAge,
+		maxChunks:    cfg.ReencodingMaxChunksPerRun,
+		chunkClient:  chunkClient,
+		schemaConfig: schemaConfig,
+		markers:      markers,
+		metrics:      metrics,
+		replaced:     map[string]chunkRef{},
+		checked:      map[string]map[string]struct{}{},
+	}, nil
+}
+
+// start starts a run, listing the tables done.
+func (r *reencoding) start(ctx context.Context, now model.Time) error {
+	prefix := r.encoding.String() + delimiter
+	objects, _, err := r.markers.List(ctx, prefix, "")
+	if err != nil {
+		return err
+	}
+
+	r.now, r.fetched = now, 0
+	r.reencoded = map[string]*rewrittenChunk{}
+	r.completed = nil
+	r.done = make(map[string]struct{}, len(objects))
+	for _, object := range objects {
+		r.done[strings.TrimPrefix(object.Key, prefix)] = struct{}{}
+	}
+	return nil
+}
+
+// isDone tells whether the chunks of the table are all re-encoded.
+func (r *reencoding) isDone(tableName string) bool {
+	_, ok := r.done[tableName]
+	return ok
+}
+
+// apply re-encodes the chunks of the db of the table, replacing them in the db. It returns whether the db was changed.
+func (r *reencoding) apply(ctx context.Context, tableName string, db *bbolt.DB) (bool, error) {
+	allSeries, err := readSeries(db)
+	if err != nil {
+		return false, err
+	}
+
+	checked, ok := r.checked[tableName]
+	if !ok {
+		checked = map[string]struct{}{}
+		r.checked[tableName] = checked
+	}
+
+	var (
+		keysToPurge  [][]byte
+		entriesToAdd []indexEntry
+		complete     = true
+		before       = r.now.Add(-r.minAge)
+	)
+	for _, s := range allSeries {
+		for _, c := range s.chunks {
+			if c.through >= before {
+				complete = false
+				continue
+			}
+			if _, ok := checked[c.chunkID]; ok {
+				continue
+			}
+
+			rc, ok := r.reencoded[c.chunkID]
+			if !ok {
+				if r.maxChunks > 0 && r.fetched >= r.maxChunks {
+					complete = false
+					continue
+				}
+				rc, err = r.reencode(ctx, c)
+				if err != nil {
+					return false, err
+				}
+			}
+			if rc == nil {
+				checked[c.chunkID] = struct{}{}
+				continue
+			}
+
+			entries, err := rewrittenIndexEntries(r.schemaConfig, tableName, s.userID, rc)
+			if err != nil {
+				return false, err
+			}
+			keysToPurge = append(keysToPurge, c.key)
+			entriesToAdd = append(entriesToAdd, entries...)
+			r.replaced[c.chunkID] = c
+			checked[rc.chunkID] = struct{}{}
+		}
+	}
+
+	if complete {
+		r.completed = append(r.completed, tableName)
+	}
+	if len(keysToPurge) == 0 {
+		return false, nil
+	}
+
+	level.Info(util.Logger).Log("msg", "replacing re-encoded chunks in index", "table", tableName, "chunks", len(keysToPurge))
+
+	if err := updateIndex(db, keysToPurge, entriesToAdd); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// reencode stores a copy of the chunk with the encoding. It returns nil if the chunk already has the encoding.
+func (r *reencoding) reencode(ctx context.Context, c chunkRef) (*rewrittenChunk, error) {
+	r.fetched++
+
+	key, err := chunk.ParseExternalKey(c.userID, c.chunkID)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := r.chunkClient.GetChunks(ctx, []chunk.Chunk{key})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch chunk %s: %w", c.chunkID, err)
+	}
+	old := chunks[0]
+
+	facade, ok := old.Data.(*chunkenc.Facade)
+	if !ok {
+		return nil, fmt.Errorf("unexpected encoding of chunk %s", c.chunkID)
+	}
+	oldChunk, ok := facade.LokiChunk().(*chunkenc.MemChunk)
+	if !ok {
+		return nil, fmt.Errorf("unexpected encoding of chunk %s", c.chunkID)
+	}
+	if oldChunk.Encoding() == r.encoding && !oldChunk.Legacy() {
+		r.reencoded[c.chunkID] = nil
+		return nil, nil
+	}
+
+	it, err := oldChunk.Iterator(ctx, time.Unix(0, 0), time.Unix(0, math.MaxInt64), logproto.FORWARD, old.Metric, logql.NoopPipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	mc := chunkenc.NewMemChunk(r.encoding, rewrittenChunkBlockSize, rewrittenChunkTargetSize)
+	for it.Next() {
+		entry := it.Entry()
+		if err := mc.Append(&entry); err != nil {
+			return nil, err
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	if err := mc.Close(); err != nil {
+		return nil, err
+	}
+
+	// the chunk keeps its bounds, only its key changes.
+	newChunk := chunk.NewChunk(c.userID, old.Fingerprint, old.Metric, chunkenc.NewFacade(mc, rewrittenChunkBlockSize, rewrittenChunkTargetSize), old.From, old.Through)
+	if err := newChunk.Encode(); err != nil {
+		return nil, err
+	}
+	if err := r.chunkClient.PutChunks(ctx, []chunk.Chunk{newChunk}); err != nil {
+		return nil, err
+	}
+	r.metrics.reencodedChunksTotal.Inc()
+	r.metrics.reencodedBytesTotal.WithLabelValues("before").Add(float64(oldChunk.CompressedSize()))
+	r.metrics.reencodedBytesTotal.WithLabelValues("after").Add(float64(mc.CompressedSize()))
+
+	rc := &rewrittenChunk{chunkID: newChunk.ExternalKey(), from: old.From, through: old.Through, metric: old.Metric}
+	r.reencoded[c.chunkID] = rc
+	return rc, nil
+}
+
+// replacedChunks returns the chunks which are not referenced by the processed tables anymore, they must only be
+// removed once every table was processed successfully.
+func (r *reencoding) replacedChunks() []chunkRef {
+	chunks := make([]chunkRef, 0, len(r.replaced))
+	for _, c := range r.replaced {
+		chunks = append(chunks, c)
+	}
+	return chunks
+}
+
+// finish forgets the replaced chunks, once removed, and marks the tables whose chunks are all re-encoded, it must only
+// be called once every table was processed successfully.
+func (r *reencoding) finish(ctx context.Context) error {
+	r.replaced = map[string]chunkRef{}
+
+	for _, tableName := range r.completed {
+		if err := r.markers.PutObject(ctx, r.encoding.String()+delimiter+tableName, bytes.NewReader(nil)); err != nil {
+			return err
+		}
+		delete(r.checked, tableName)
+		level.Info(util.Logger).Log("msg", "re-encoded all the chunks of table", "table", tableName, "encoding", r.encoding)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package compactor
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/chunk/local"
+	"github.com/cortexproject/cortex/pkg/chunk/objectclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/chunkenc"
+	"github.com/famarks/loki/pkg/storage/stores/util"
+)
+
+func TestReencoding_Apply(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "reencoding")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	fsClient, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(tempDir, "chunks")})
+	require.NoError(t, err)
+	chunkClient := objectclient.NewClient(fsClient, nil)
+	markersClient, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(tempDir, "markers")})
+	require.NoError(t, err)
+
+	db, err := local.OpenBoltdbFile(filepath.Join(tempDir, "db"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	periodConfig := newTestPeriodConfig()
+	schema := newTestSchema(t)
+	start := model.TimeFromUnix(18000 * 24 * 3600)
+	tableName := periodConfig.IndexTables.TableFor(start)
+
+	// the chunks are stored with snappy.
+	foo := storeTestChunk(t, db, schema, chunkClient, "1", `{app="foo"}`, start, 10)
+	bar := storeTestChunk(t, db, schema, chunkClient, "1", `{app="bar"}`, start, 10)
+	entriesBefore := countEntries(t, db)
+
+	cfg := Config{ReencodingEncoding: "gzip", ReencodingMinAge: 24 * time.Hour, ReencodingMaxChunksPerRun: 1}
+	r, err := newReencoding(cfg, chunkClient, chunk.SchemaConfig{Configs: []chunk.PeriodConfig{periodConfig}}, util.NewPrefixedObjectClient(markersClient, ReencodingPrefix), newMetrics(prometheus.NewRegistry()))
+	require.NoError(t, err)
+	now := start.Add(48 * time.Hour)
+
+	// a single chunk is re-encoded by the first run, so the table isn't done yet.
+	require.NoError(t, r.start(context.Background(), now))
+	changed, err := r.apply(context.Background(), tableName, db)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Len(t, r.replaced, 1)
+	require.NoError(t, deleteChunks(context.Background(), chunkClient, r.replacedChunks()))
+	require.NoError(t, r.finish(context.Background()))
+
+	require.NoError(t, r.start(context.Background(), now))
+	require.False(t, r.isDone(tableName))
+	changed, err = r.apply(context.Background(), tableName, db)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.NoError(t, deleteChunks(context.Background(), chunkClient, r.replacedChunks()))
+	require.NoError(t, r.finish(context.Background()))
+	require.Empty(t, r.replacedChunks())
+	require.Equal(t, float64(2), testutil.ToFloat64(r.metrics.reencodedChunksTotal))
+
+	// the chunk entries are replaced by the ones of the re-encoded chunks, which hold the same entries.
+	require.Equal(t, entriesBefore, countEntries(t, db))
+	allSeries, err := readSeries(db)
+	require.NoError(t, err)
+	require.Len(t, allSeries, 2)
+	for _, s := range allSeries {
+		require.Len(t, s.chunks, 1)
+		c := s.chunks[0]
+		require.NotContains(t, []string{foo, bar}, c.chunkID)
+		require.Equal(t, []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, readTestChunk(t, chunkClient, "1", c.chunkID, start))
+
+		key, err := chunk.ParseExternalKey("1", c.chunkID)
+		require.NoError(t, err)
+		chunks, err := chunkClient.GetChunks(context.Background(), []chunk.Chunk{key})
+		require.NoError(t, err)
+		require.Equal(t, chunkenc.EncGZIP, chunks[0].Data.(*chunkenc.Facade).LokiChunk().(*chunkenc.MemChunk).Encoding())
+	}
+
+	// the replaced chunks are removed from the store.
+	for _, id := range []string{foo, bar} {
+		c, err := chunk.ParseExternalKey("1", id)
+		require.NoError(t, err)
+		_, err = chunkClient.GetChunks(context.Background(), []chunk.Chunk{c})
+		require.Error(t, err)
+	}
+
+	// the table is skipped by the next runs.
+	require.NoError(t, r.start(context.Background(), now))
+	require.True(t, r.isDone(tableName))
+}
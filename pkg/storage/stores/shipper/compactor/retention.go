@@ -0,0 +1,300 @@
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"go.etcd.io/bbolt"
+
+	"github.com/famarks/loki/pkg/util/validation"
+)
+
+const (
+	// range value types of the index entries written by the v9+ schemas, see cortex chunk/schema.go.
+	chunkTimeRangeKeyV3   = '3'
+	seriesRangeKeyV1      = '7'
+	labelSeriesRangeKeyV1 = '8'
+
+	// separator between the hash and range values in the boltdb keys.
+	keySeparator = 0
+)
+
+// Limits are the per tenant limits used by the compactor to apply retention.
+type Limits interface {
+	RetentionPeriod(userID string) time.Duration
+	StreamRetention(userID string) []validation.StreamRetention
+}
+
+// retention holds what's needed to apply retention while compacting tables.
+type retention struct {
+	limits      Limits
+	chunkClient chunk.Client
+}
+
+// expiration tells whether a chunk is past the retention of its tenant and stream.
+type expiration struct {
+	limits Limits
+	now    model.Time
+}
+
+// retentionPeriod returns the retention period of a stream, 0 meaning it is kept forever.
+func (e expiration) retentionPeriod(userID string, lbls labels.Labels) time.Duration {
+	var (
+		matched  bool
+		priority int
+		period   time.Duration
+	)
+
+	for _, rule := range e.limits.StreamRetention(userID) {
+		if matched && rule.Priority <= priority {
+			continue
+		}
+		if matchAll(rule.Matchers, lbls) {
+			matched, priority, period = true, rule.Priority, rule.Period
+		}
+	}
+
+	if matched {
+		return period
+	}
+	return e.limits.RetentionPeriod(userID)
+}
+
+func (e expiration) expired(userID string, lbls labels.Labels, through model.Time) bool {
+	period := e.retentionPeriod(userID, lbls)
+	if period <= 0 {
+		return false
+	}
+	return through.Before(e.now.Add(-period))
+}
+
+func matchAll(matchers []*labels.Matcher, lbls labels.Labels) bool {
+	for _, m := range matchers {
+		if !m.Matches(lbls.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// chunkRef is a chunk referenced by the index of a table.
+type chunkRef struct {
+	key     []byte
+	userID  string
+	chunkID string
+	from    model.Time
+	through model.Time
+}
+
+// series holds the index entries of a series in a table.
+type series struct {
+	userID string
+	labels labels.Labels
+	// keys of the series and label entries, removed along the last chunk of the series.
+	keys   [][]byte
+	chunks []chunkRef
+}
+
+// indexKey is a boltdb key split in its hash and range value, the range value split in its components.
+type indexKey struct {
+	hashValue  []string
+	components [][]byte
+}
+
+func parseIndexKey(k []byte) (indexKey, bool) {
+	i := bytes.IndexByte(k, keySeparator)
+	if i < 0 {
+		return indexKey{}, false
+	}
+
+	// decode the range value components, they are separated by a 0 byte. See cortex chunk/schema_util.go.
+	rangeValue := k[i+1:]
+	components := make([][]byte, 0, 5)
+	start := 0
+	for j := 0; j < len(rangeValue); j++ {
+		if rangeValue[j] == 0 {
+			components = append(components, rangeValue[start:j])
+			start = j + 1
+		}
+	}
+
+	if len(components) < 4 || len(components[3]) != 1 {
+		return indexKey{}, false
+	}
+	return indexKey{hashValue: strings.Split(string(k[:i]), ":"), components: components}, true
+}
+
+func (ik indexKey) rangeKeyType() byte {
+	return ik.components[3][0]
+}
+
+// readSeries reads the series of the db along with their chunks.
+func readSeries(db *bbolt.DB) ([]*series, error) {
+	allSeries := map[string]*series{}
+	// series are tracked per bucket since tables can hold the entries of several days.
+	getSeries := func(userID, day string, seriesID []byte) *series {
+		id := userID + ":" + day + ":" + string(seriesID)
+		s, ok := allSeries[id]
+		if !ok {
+			s = &series{userID: userID}
+			allSeries[id] = s
+		}
+		return s
+	}
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucketName)
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			ik, ok := parseIndexKey(k)
+			if !ok {
+				return nil
+			}
+			hv := ik.hashValue
+
+			switch ik.rangeKeyType() {
+			case chunkTimeRangeKeyV3:
+				// <userID>:d<day>:<seriesID> -> chunkID
+				if len(hv) < 3 {
+					return nil
+				}
+				userID, chunkID := hv[len(hv)-3], string(ik.components[2])
+				c, err := chunk.ParseExternalKey(userID, chunkID)
+				if err != nil {
+					return fmt.Errorf("failed to parse chunk id of index entry: %w", err)
+				}
+				s := getSeries(userID, hv[len(hv)-2], []byte(hv[len(hv)-1]))
+				s.chunks = append(s.chunks, chunkRef{
+					key:     copyBytes(k),
+					userID:  userID,
+					chunkID: chunkID,
+					from:    c.From,
+					through: c.Through,
+				})
+			case seriesRangeKeyV1:
+				// [<shard>:]<userID>:d<day>:<metricName> -> seriesID
+				if len(hv) < 3 {
+					return nil
+				}
+				s := getSeries(hv[len(hv)-3], hv[len(hv)-2], ik.components[0])
+				s.keys = append(s.keys, copyBytes(k))
+			case labelSeriesRangeKeyV1:
+				// [<shard>:]<userID>:d<day>:<metricName>:<labelName> -> hash(labelValue):seriesID, labelValue
+				if len(hv) < 4 {
+					return nil
+				}
+				s := getSeries(hv[len(hv)-4], hv[len(hv)-3], ik.components[1])
+				s.keys = append(s.keys, copyBytes(k))
+				s.labels = append(s.labels, labels.Label{Name: hv[len(hv)-1], Value: string(v)})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*series, 0, len(allSeries))
+	for _, s := range allSeries {
+		if len(s.chunks) == 0 {
+			continue
+		}
+		sort.Sort(s.labels)
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// updateIndex removes and adds the given entries to the db.
+func updateIndex(db *bbolt.DB, keysToPurge [][]byte, entriesToAdd []indexEntry) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucketName)
+		if err != nil {
+			return err
+		}
+		for _, k := range keysToPurge {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		for _, e := range entriesToAdd {
+			if err := b.Put(e.k, e.v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// applyRetention removes the index entries of the chunks past their retention from the db, along with the series
+// and label entries of the series left without chunks in the table. It returns the expired chunks, which can be
+// deleted from the store once the db is uploaded.
+// The label names entries of the v11 schema are kept since they are only keyed by series ID, which is shared by
+// the buckets and tenants having a stream with the same labels.
+func applyRetention(db *bbolt.DB, exp expiration) ([]chunkRef, error) {
+	allSeries, err := readSeries(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		expired     []chunkRef
+		keysToPurge [][]byte
+	)
+	for _, s := range allSeries {
+		kept := 0
+		for _, c := range s.chunks {
+			if !exp.expired(s.userID, s.labels, c.through) {
+				kept++
+				continue
+			}
+			expired = append(expired, c)
+			keysToPurge = append(keysToPurge, c.key)
+		}
+
+		if kept == 0 {
+			keysToPurge = append(keysToPurge, s.keys...)
+		}
+	}
+
+	if len(keysToPurge) == 0 {
+		return nil, nil
+	}
+
+	level.Info(util.Logger).Log("msg", "removing expired index entries", "chunks", len(expired), "entries", len(keysToPurge))
+
+	if err := updateIndex(db, keysToPurge, nil); err != nil {
+		return nil, err
+	}
+
+	return expired, nil
+}
+
+// deleteChunks removes the chunks from the store.
+func deleteChunks(ctx context.Context, chunkClient chunk.Client, chunks []chunkRef) error {
+	for _, c := range chunks {
+		err := chunkClient.DeleteChunk(ctx, c.userID, c.chunkID)
+		if err != nil && err != chunk.ErrStorageObjectNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
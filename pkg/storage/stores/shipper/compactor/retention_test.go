@@ -0,0 +1,175 @@
+package compactor
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/chunk/local"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+
+	"github.com/famarks/loki/pkg/util"
+	"github.com/famarks/loki/pkg/util/validation"
+)
+
+type fakeLimits struct {
+	retention       map[string]time.Duration
+	streamRetention map[string][]validation.StreamRetention
+}
+
+func (f fakeLimits) RetentionPeriod(userID string) time.Duration {
+	return f.retention[userID]
+}
+
+func (f fakeLimits) StreamRetention(userID string) []validation.StreamRetention {
+	return f.streamRetention[userID]
+}
+
+func TestExpiration_RetentionPeriod(t *testing.T) {
+	exp := expiration{limits: fakeLimits{
+		retention: map[string]time.Duration{"1": 30 * 24 * time.Hour},
+		streamRetention: map[string][]validation.StreamRetention{"1": {
+			{Period: 7 * 24 * time.Hour, Priority: 1, Matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "namespace", "dev")}},
+			{Period: 365 * 24 * time.Hour, Priority: 2, Matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "app", "audit")}},
+		}},
+	}}
+
+	for _, tc := range []struct {
+		userID   string
+		labels   labels.Labels
+		expected time.Duration
+	}{
+		{"1", labels.FromStrings("namespace", "prod"), 30 * 24 * time.Hour},
+		{"1", labels.FromStrings("namespace", "dev"), 7 * 24 * time.Hour},
+		{"1", labels.FromStrings("app", "audit", "namespace", "dev"), 365 * 24 * time.Hour},
+		{"2", labels.FromStrings("namespace", "dev"), 0},
+	} {
+		t.Run(tc.userID+tc.labels.String(), func(t *testing.T) {
+			require.Equal(t, tc.expected, exp.retentionPeriod(tc.userID, tc.labels))
+		})
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "retention")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	// 20:00 so that chunks of the previous day are in the same bucket.
+	now := model.TimeFromUnix(18000*24*3600 + 20*3600)
+	schema := newTestSchema(t)
+
+	db, err := local.OpenBoltdbFile(filepath.Join(tempDir, "db"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	devOld := writeTestChunk(t, db, schema, "1", `{app="dev"}`, now.Add(-30*time.Hour))
+	writeTestChunk(t, db, schema, "1", `{app="dev"}`, now.Add(-23*time.Hour))
+	writeTestChunk(t, db, schema, "1", `{app="prod"}`, now.Add(-30*time.Hour))
+	otherOld := writeTestChunk(t, db, schema, "2", `{app="foo"}`, now.Add(-72*time.Hour))
+
+	entriesBefore := countEntries(t, db)
+
+	expired, err := applyRetention(db, expiration{
+		now: now,
+		limits: fakeLimits{
+			retention: map[string]time.Duration{"2": 48 * time.Hour},
+			streamRetention: map[string][]validation.StreamRetention{"1": {
+				{Period: 24 * time.Hour, Matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "app", "dev")}},
+			}},
+		},
+	})
+	require.NoError(t, err)
+
+	expiredIDs := make([]string, 0, len(expired))
+	for _, c := range expired {
+		expiredIDs = append(expiredIDs, c.chunkID)
+	}
+	sort.Strings(expiredIDs)
+	require.Equal(t, []string{devOld, otherOld}, expiredIDs)
+
+	// the chunk entries of the expired chunks are removed, along with the series and label entries of tenant 2 which
+	// has no chunk left, while the ones of {app="dev"} are kept for its remaining chunk.
+	require.Equal(t, entriesBefore-1-3, countEntries(t, db))
+}
+
+func newTestSchema(t *testing.T) chunk.SeriesStoreSchema {
+	schema, err := newTestPeriodConfig().CreateSchema()
+	require.NoError(t, err)
+	return schema.(chunk.SeriesStoreSchema)
+}
+
+func newTestPeriodConfig() chunk.PeriodConfig {
+	return chunk.PeriodConfig{
+		Schema:    "v11",
+		IndexType: "boltdb",
+		IndexTables: chunk.PeriodicTableConfig{
+			Prefix: "index_",
+			Period: 24 * time.Hour,
+		},
+		RowShards: 16,
+	}
+}
+
+// writeTestChunk writes the index entries of a 10m chunk ending at through and returns its id.
+func writeTestChunk(t *testing.T, db *bbolt.DB, schema chunk.SeriesStoreSchema, userID, stream string, through model.Time) string {
+	lbls, err := util.ParseLabels(stream)
+	require.NoError(t, err)
+	lbls = append(lbls, labels.Label{Name: labels.MetricName, Value: "logs"})
+	sort.Sort(lbls)
+
+	from := through.Add(-10 * time.Minute)
+	chunkID := fmt.Sprintf("%s/%x:%x:%x:%x", userID, lbls.Hash(), int64(from), int64(through), 0)
+	writeIndexEntries(t, db, schema, userID, lbls, chunkID, from, through)
+
+	return chunkID
+}
+
+func writeIndexEntries(t *testing.T, db *bbolt.DB, schema chunk.SeriesStoreSchema, userID string, lbls labels.Labels, chunkID string, from, through model.Time) {
+	_, labelEntries, err := schema.GetCacheKeysAndLabelWriteEntries(from, through, userID, "logs", lbls, chunkID)
+	require.NoError(t, err)
+	chunkEntries, err := schema.GetChunkWriteEntries(from, through, userID, "logs", lbls, chunkID)
+	require.NoError(t, err)
+
+	require.NoError(t, db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucketName)
+		if err != nil {
+			return err
+		}
+
+		entries := chunkEntries
+		for _, e := range labelEntries {
+			entries = append(entries, e...)
+		}
+		for _, e := range entries {
+			if err := b.Put([]byte(e.HashValue+"\x00"+string(e.RangeValue)), e.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+func countEntries(t *testing.T, db *bbolt.DB) int {
+	count := 0
+	require.NoError(t, db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketName).ForEach(func(_, _ []byte) error {
+			count++
+			return nil
+		})
+	}))
+	return count
+}
@@ -13,6 +13,7 @@ import (
 	chunk_util "github.com/cortexproject/cortex/pkg/chunk/util"
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
 	"go.etcd.io/bbolt"
 
 	shipper_util "github.com/famarks/loki/pkg/storage/stores/shipper/util"
@@ -39,11 +40,27 @@ type table struct {
 
 	compactedDB *bbolt.DB
 
+	// deletionQueue, when set, defers the removal of compacted source files and of the chunks they only reference,
+	// otherwise they are removed right away.
+	deletionQueue *deletionQueue
+	// retention, when set, is applied to the compacted index.
+	retention *retention
+	// deletion, when set, applies the pending delete requests to the compacted index.
+	deletion *deletion
+	// reencoding, when set, re-encodes the chunks of the compacted index.
+	reencoding *reencoding
+	// usage, when set, reports the storage used by the tenants in the compacted index.
+	usage *usage
+	// integrity, when set, checks a sample of the chunks of the compacted index.
+	integrity *integrity
+	// tiering, when set, collects the chunks of the compacted index to move to the cold tier.
+	tiering *tiering
+
 	ctx  context.Context
 	quit chan struct{}
 }
 
-func newTable(ctx context.Context, workingDirectory string, objectClient chunk.ObjectClient) (*table, error) {
+func newTable(ctx context.Context, workingDirectory string, objectClient chunk.ObjectClient, deletionQueue *deletionQueue, retention *retention, deletion *deletion, reencoding *reencoding, usage *usage, integrity *integrity, tiering *tiering) (*table, error) {
 	err := chunk_util.EnsureDirectory(workingDirectory)
 	if err != nil {
 		return nil, err
@@ -54,6 +71,13 @@ func newTable(ctx context.Context, workingDirectory string, objectClient chunk.O
 		name:             filepath.Base(workingDirectory),
 		workingDirectory: workingDirectory,
 		storageClient:    objectClient,
+		deletionQueue:    deletionQueue,
+		retention:        retention,
+		deletion:         deletion,
+		reencoding:       reencoding,
+		usage:            usage,
+		integrity:        integrity,
+		tiering:          tiering,
 		quit:             make(chan struct{}),
 	}
 
@@ -68,9 +92,25 @@ func (t *table) compact() error {
 		return err
 	}
 
+	// skip the files which are already compacted and just waiting to be removed.
+	if t.deletionQueue != nil {
+		pending := objects
+		objects = make([]chunk.StorageObject, 0, len(pending))
+		for _, object := range pending {
+			if !t.deletionQueue.contains(object.Key) {
+				objects = append(objects, object)
+			}
+		}
+	}
+
 	level.Info(util.Logger).Log("msg", "listed files", "count", len(objects))
 
-	if len(objects) < compactMinDBs {
+	// with retention enabled, delete requests to apply or chunks to re-encode, tables need to be rewritten even when they
+	// are already compacted, and read when their usage is not up to date, their chunks are checked or moved.
+	usageDue := t.usage != nil && !t.usage.isUpToDate(t.name, objects)
+	integrityDue := t.integrity != nil && t.integrity.isDue(t.name)
+	tieringDue := t.tiering != nil && t.tiering.isDue(t.name)
+	if len(objects) < compactMinDBs && ((t.retention == nil && t.deletion == nil && t.reencoding == nil && !usageDue && !integrityDue && !tieringDue) || len(objects) == 0) {
 		level.Info(util.Logger).Log("msg", fmt.Sprintf("skipping compaction since we have just %d files in storage", len(objects)))
 		return nil
 	}
@@ -177,14 +217,92 @@ func (t *table) compact() error {
 
 	level.Info(util.Logger).Log("msg", "finished compacting the dbs")
 
+	var expiredChunks []chunkRef
+	if t.retention != nil {
+		expiredChunks, err = applyRetention(t.compactedDB, expiration{limits: t.retention.limits, now: model.Now()})
+		if err != nil {
+			return err
+		}
+	}
+
+	deleted := false
+	if t.deletion != nil {
+		deleted, err = t.deletion.apply(t.ctx, t.name, t.compactedDB)
+		if err != nil {
+			return err
+		}
+	}
+
+	reencoded := false
+	if t.reencoding != nil {
+		reencoded, err = t.reencoding.apply(t.ctx, t.name, t.compactedDB)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the chunks rewritten by the delete requests or the re-encoding are written to the shared store, even in the tables
+	// whose chunks were all moved.
+	if tieringDue || (t.tiering != nil && (deleted || reencoded)) {
+		if err := t.tiering.collect(t.name, t.compactedDB); err != nil {
+			return err
+		}
+	}
+
+	if integrityDue {
+		if err := t.integrity.check(t.ctx, t.name, t.compactedDB); err != nil {
+			return err
+		}
+	}
+
+	var report *usageReport
+	if usageDue {
+		report, err = t.usage.compute(t.ctx, t.name, t.compactedDB)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(objects) < compactMinDBs && len(expiredChunks) == 0 && !deleted && !reencoded {
+		level.Info(util.Logger).Log("msg", fmt.Sprintf("skipping upload since we have just %d files in storage and no expired, deleted or re-encoded chunks", len(objects)))
+		if report == nil {
+			return nil
+		}
+		files := make([]string, 0, len(objects))
+		for _, object := range objects {
+			files = append(files, object.Key)
+		}
+		return t.usage.save(t.ctx, report, files)
+	}
+
 	// upload the compacted db
-	err = t.upload()
+	objectKey, err := t.upload()
 	if err != nil {
 		return err
 	}
 
-	// remove source files from storage which were compacted
-	return t.removeObjectsFromStorage(objects)
+	if report != nil {
+		if err := t.usage.save(t.ctx, report, []string{objectKey}); err != nil {
+			return err
+		}
+	}
+
+	// remove source files from storage which were compacted, and then the expired chunks which are only referenced by
+	// them.
+	if t.deletionQueue != nil {
+		now := time.Now()
+		t.deletionQueue.add(objects, now)
+		t.deletionQueue.addChunks(expiredChunks, now)
+		return nil
+	}
+	if err := t.removeObjectsFromStorage(objects); err != nil {
+		return err
+	}
+	if len(expiredChunks) > 0 {
+		level.Info(util.Logger).Log("msg", "removing expired chunks from storage", "count", len(expiredChunks))
+		return deleteChunks(t.ctx, t.retention.chunkClient, expiredChunks)
+	}
+	return nil
 }
 
 func (t *table) cleanup() error {
@@ -278,14 +396,14 @@ func (t *table) readFile(path string) error {
 	})
 }
 
-// upload uploads the compacted db in compressed format.
-func (t *table) upload() error {
+// upload uploads the compacted db in compressed format and returns its object key.
+func (t *table) upload() (string, error) {
 	compactedDBPath := t.compactedDB.Path()
 
 	// close the compactedDB to make sure all the writes are processed.
 	err := t.compactedDB.Close()
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	t.compactedDB = nil
@@ -294,13 +412,13 @@ func (t *table) upload() error {
 	compressedDBPath := fmt.Sprintf("%s.gz", compactedDBPath)
 	err = shipper_util.CompressFile(compactedDBPath, compressedDBPath)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// open the file for reading.
 	compressedDB, err := os.Open(compressedDBPath)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	defer func() {
@@ -316,7 +434,7 @@ func (t *table) upload() error {
 	objectKey := fmt.Sprintf("%s.gz", shipper_util.BuildObjectKey(t.name, uploaderName, fmt.Sprint(time.Now().Unix())))
 	level.Info(util.Logger).Log("msg", "uploading the compacted file", "objectKey", objectKey)
 
-	return t.storageClient.PutObject(t.ctx, objectKey, compressedDB)
+	return objectKey, t.storageClient.PutObject(t.ctx, objectKey, compressedDB)
 }
 
 // removeObjectsFromStorage deletes objects from storage.
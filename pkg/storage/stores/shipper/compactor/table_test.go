@@ -8,8 +8,12 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/cortexproject/cortex/pkg/chunk"
 	"github.com/cortexproject/cortex/pkg/chunk/local"
+	"github.com/cortexproject/cortex/pkg/chunk/objectclient"
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
 	"go.etcd.io/bbolt"
 
@@ -55,7 +59,7 @@ func TestTable_Compaction(t *testing.T) {
 	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: objectStoragePath})
 	require.NoError(t, err)
 
-	table, err := newTable(context.Background(), tableWorkingDirectory, objectClient)
+	table, err := newTable(context.Background(), tableWorkingDirectory, objectClient, nil, nil, nil, nil, nil, nil, nil)
 	require.NoError(t, err)
 
 	require.NoError(t, table.compact())
@@ -70,6 +74,182 @@ func TestTable_Compaction(t *testing.T) {
 	compareCompactedDB(t, filepath.Join(tablePathInStorage, files[0].Name()), filepath.Join(objectStoragePath, "test-copy"))
 }
 
+func TestTable_CompactionWithDeletionQueue(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "table-compaction-deletion-queue")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	tableName := "test"
+	objectStoragePath := filepath.Join(tempDir, objectsStorageDirName)
+	tablePathInStorage := filepath.Join(objectStoragePath, tableName)
+	tableWorkingDirectory := filepath.Join(tempDir, workingDirName, tableName)
+
+	// setup some dbs
+	numDBs := compactMinDBs * 2
+	numRecordsPerDB := 100
+
+	dbsToSetup := make(map[string]testutil.DBRecords)
+	for i := 0; i < numDBs; i++ {
+		dbsToSetup[fmt.Sprint(i)] = testutil.DBRecords{
+			Start:      i * numRecordsPerDB,
+			NumRecords: (i + 1) * numRecordsPerDB,
+		}
+	}
+
+	testutil.SetupDBTablesAtPath(t, tableName, objectStoragePath, dbsToSetup, true)
+
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: objectStoragePath})
+	require.NoError(t, err)
+
+	queue := newDeletionQueue(objectClient, nil, time.Hour)
+
+	table, err := newTable(context.Background(), tableWorkingDirectory, objectClient, queue, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, table.compact())
+
+	// source files should be kept in storage along with the compacted file until the deletion delay has passed.
+	files, err := ioutil.ReadDir(tablePathInStorage)
+	require.NoError(t, err)
+	require.Len(t, files, numDBs+1)
+
+	// compacting again should ignore the source files waiting for deletion, leaving just the compacted file which is not enough to compact.
+	table, err = newTable(context.Background(), tableWorkingDirectory, objectClient, queue, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, table.compact())
+
+	files, err = ioutil.ReadDir(tablePathInStorage)
+	require.NoError(t, err)
+	require.Len(t, files, numDBs+1)
+
+	// nothing should be removed before the deletion delay.
+	require.NoError(t, queue.deleteExpired(context.Background(), time.Now()))
+	files, err = ioutil.ReadDir(tablePathInStorage)
+	require.NoError(t, err)
+	require.Len(t, files, numDBs+1)
+
+	// source files should be removed after the delay, leaving just the compacted file.
+	require.NoError(t, queue.deleteExpired(context.Background(), time.Now().Add(time.Hour)))
+	files, err = ioutil.ReadDir(tablePathInStorage)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.True(t, strings.HasSuffix(files[0].Name(), ".gz"))
+}
+
+func TestTable_CompactionWithRetentionAndDeletionQueue(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "table-compaction-retention")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	start := model.TimeFromUnix(18000 * 24 * 3600)
+	periodConfig := newTestPeriodConfig()
+	tableName := periodConfig.IndexTables.TableFor(start)
+	objectStoragePath := filepath.Join(tempDir, objectsStorageDirName)
+	tablePathInStorage := filepath.Join(objectStoragePath, tableName)
+	tableWorkingDirectory := filepath.Join(tempDir, workingDirName, tableName)
+
+	fsClient, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(tempDir, "chunks")})
+	require.NoError(t, err)
+	chunkClient := objectclient.NewClient(fsClient, nil)
+
+	// a source file referencing a chunk past the retention.
+	require.NoError(t, os.MkdirAll(tablePathInStorage, 0777))
+	db, err := local.OpenBoltdbFile(filepath.Join(tablePathInStorage, "db"))
+	require.NoError(t, err)
+	expired := storeTestChunk(t, db, newTestSchema(t), chunkClient, "1", `{app="foo"}`, start, 10)
+	require.NoError(t, db.Close())
+
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: objectStoragePath})
+	require.NoError(t, err)
+
+	queue := newDeletionQueue(objectClient, chunkClient, time.Hour)
+	retention := &retention{limits: fakeLimits{retention: map[string]time.Duration{"1": 24 * time.Hour}}, chunkClient: chunkClient}
+
+	table, err := newTable(context.Background(), tableWorkingDirectory, objectClient, queue, retention, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, table.compact())
+
+	// the expired chunk is kept along the source file still referencing it until the deletion delay has passed.
+	files, err := ioutil.ReadDir(tablePathInStorage)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.Len(t, readTestChunk(t, chunkClient, "1", expired, start), 10)
+
+	require.NoError(t, queue.deleteExpired(context.Background(), time.Now()))
+	files, err = ioutil.ReadDir(tablePathInStorage)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.Len(t, readTestChunk(t, chunkClient, "1", expired, start), 10)
+
+	// the expired chunk is removed along the source file after the delay.
+	require.NoError(t, queue.deleteExpired(context.Background(), time.Now().Add(time.Hour)))
+	files, err = ioutil.ReadDir(tablePathInStorage)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	key, err := chunk.ParseExternalKey("1", expired)
+	require.NoError(t, err)
+	_, err = chunkClient.GetChunks(context.Background(), []chunk.Chunk{key})
+	require.Error(t, err)
+}
+
+func TestTable_CompactionWithDeleteRequestsAppliedTwice(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "table-compaction-deletion")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	start := model.TimeFromUnix(18000 * 24 * 3600)
+	periodConfig := newTestPeriodConfig()
+	tableName := periodConfig.IndexTables.TableFor(start)
+	objectStoragePath := filepath.Join(tempDir, objectsStorageDirName)
+	tablePathInStorage := filepath.Join(objectStoragePath, tableName)
+	tableWorkingDirectory := filepath.Join(tempDir, workingDirName, tableName)
+
+	fsClient, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(tempDir, "chunks")})
+	require.NoError(t, err)
+	chunkClient := objectclient.NewClient(fsClient, nil)
+
+	require.NoError(t, os.MkdirAll(tablePathInStorage, 0777))
+	db, err := local.OpenBoltdbFile(filepath.Join(tablePathInStorage, "db"))
+	require.NoError(t, err)
+	foo := storeTestChunk(t, db, newTestSchema(t), chunkClient, "1", `{app="foo"}`, start, 10)
+	require.NoError(t, db.Close())
+
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: objectStoragePath})
+	require.NoError(t, err)
+
+	// entries 3 to 5 of foo, in the middle of the chunk so that its rewritten chunk still overlaps the request.
+	request := &DeleteRequest{UserID: "1", Query: `{app="foo"}`, StartTime: start.Add(3 * time.Minute), EndTime: start.Add(5 * time.Minute)}
+	require.NoError(t, request.parseQuery())
+	schemaConfig := chunk.SchemaConfig{Configs: []chunk.PeriodConfig{periodConfig}}
+
+	compact := func() *deletion {
+		del := newDeletion([]*DeleteRequest{request}, chunkClient, schemaConfig)
+		table, err := newTable(context.Background(), tableWorkingDirectory, objectClient, nil, nil, del, nil, nil, nil, nil)
+		require.NoError(t, err)
+		require.NoError(t, table.compact())
+		require.NoError(t, deleteChunks(context.Background(), chunkClient, del.replacedChunks()))
+		return del
+	}
+
+	del := compact()
+	require.Len(t, del.replaced, 1)
+	rewritten := del.rewritten[foo].chunkID
+	require.NotEqual(t, foo, rewritten)
+
+	// applying the request again, as after a failed run, leaves the rewritten chunk as is.
+	del = compact()
+	require.Empty(t, del.replaced)
+	require.Equal(t, []int64{0, 1, 2, 6, 7, 8, 9}, readTestChunk(t, chunkClient, "1", rewritten, start))
+}
+
 func TestTable_CompactionFailure(t *testing.T) {
 	tempDir, err := ioutil.TempDir("", "table-compaction-failure")
 	require.NoError(t, err)
@@ -104,7 +284,7 @@ func TestTable_CompactionFailure(t *testing.T) {
 	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: objectStoragePath})
 	require.NoError(t, err)
 
-	table, err := newTable(context.Background(), tableWorkingDirectory, objectClient)
+	table, err := newTable(context.Background(), tableWorkingDirectory, objectClient, nil, nil, nil, nil, nil, nil, nil)
 	require.NoError(t, err)
 
 	// compaction should fail due to a non-boltdb file.
@@ -121,7 +301,7 @@ func TestTable_CompactionFailure(t *testing.T) {
 	// remove the non-boltdb file and ensure that compaction succeeds now.
 	require.NoError(t, os.Remove(filepath.Join(tablePathInStorage, "fail.txt")))
 
-	table, err = newTable(context.Background(), tableWorkingDirectory, objectClient)
+	table, err = newTable(context.Background(), tableWorkingDirectory, objectClient, nil, nil, nil, nil, nil, nil, nil)
 	require.NoError(t, err)
 	require.NoError(t, table.compact())
 
@@ -0,0 +1,187 @@
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/chunk/objectclient"
+	pkg_util "github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	"go.etcd.io/bbolt"
+
+	"github.com/famarks/loki/pkg/storage/stores/shipper"
+)
+
+// TieringPrefix is the prefix of the markers of the tables whose chunks are all moved to the cold tier, in the shared
+// store.
+const TieringPrefix = "tiering/"
+
+// tiering moves the chunks of the shared store to the cold tier once they are older than the move after duration. The
+// chunks keep their key in the cold tier, so that their index entries don't change.
+// Like the retention, the chunks to move are the ones referenced by the tables being compacted, for the chunks of the
+// shared store not to be listed on every run. The tables whose chunks are all moved are marked in the shared store,
+// for the next runs to skip them.
+type tiering struct {
+	hot, cold    chunk.ObjectClient
+	keyEncoder   objectclient.KeyEncoder
+	moveAfter    time.Duration
+	schemaConfig chunk.SchemaConfig
+	markers      chunk.ObjectClient
+	metrics      *metrics
+
+	// state of the current run.
+	before model.Time
+	done   map[string]struct{}
+	// chunks to move by table, and whether the table has no chunk left to move once they are.
+	pending  map[string][]chunkRef
+	complete map[string]bool
+}
+
+func newTiering(cfg Config, hot, cold chunk.ObjectClient, schemaConfig chunk.SchemaConfig, markers chunk.ObjectClient, metrics *metrics) *tiering {
+	// the chunk keys are encoded like the chunk clients of the store do.
+	var keyEncoder objectclient.KeyEncoder
+	if cfg.SharedStoreType == shipper.FilesystemObjectStoreType {
+		keyEncoder = objectclient.Base64Encoder
+	}
+	return &tiering{
+		hot:          hot,
+		cold:         cold,
+		keyEncoder:   keyEncoder,
+		moveAfter:    cfg.TieringMoveAfter,
+		schemaConfig: schemaConfig,
+		markers:      markers,
+		metrics:      metrics,
+	}
+}
+
+// start starts a run, listing the tables done.
+func (t *tiering) start(ctx context.Context, now time.Time) error {
+	objects, _, err := t.markers.List(ctx, "", "")
+	if err != nil {
+		return err
+	}
+
+	t.before = model.TimeFromUnixNano(now.Add(-t.moveAfter).UnixNano())
+	t.pending = map[string][]chunkRef{}
+	t.complete = map[string]bool{}
+	t.done = make(map[string]struct{}, len(objects))
+	for _, object := range objects {
+		t.done[object.Key] = struct{}{}
+	}
+	return nil
+}
+
+// isDue tells whether the table can reference chunks to move, its chunks not being all moved yet.
+func (t *tiering) isDue(tableName string) bool {
+	if _, ok := t.done[tableName]; ok {
+		return false
+	}
+	start, _ := tableStart(t.schemaConfig, tableName)
+	return start < t.before
+}
+
+// collect collects the chunks of the db of the table to move.
+func (t *tiering) collect(tableName string, db *bbolt.DB) error {
+	allSeries, err := readSeries(db)
+	if err != nil {
+		return err
+	}
+
+	complete := true
+	var chunks []chunkRef
+	for _, s := range allSeries {
+		for _, c := range s.chunks {
+			if c.through >= t.before {
+				complete = false
+				continue
+			}
+			chunks = append(chunks, c)
+		}
+	}
+	t.pending[tableName] = chunks
+	t.complete[tableName] = complete
+	return nil
+}
+
+// moveChunks moves the chunks collected from the tables, marking the tables whose chunks are all moved. The chunks
+// failing to be moved are moved by the next run.
+func (t *tiering) moveChunks(ctx context.Context) error {
+	var lastErr error
+	// the chunks referenced by several tables are moved once.
+	moved := map[string]struct{}{}
+	for tableName, chunks := range t.pending {
+		failed := false
+		for _, c := range chunks {
+			if _, ok := moved[c.chunkID]; ok {
+				continue
+			}
+			if err := t.moveChunk(ctx, c.chunkID); err != nil {
+				t.metrics.tieringFailuresTotal.Inc()
+				level.Error(pkg_util.Logger).Log("msg", "failed to move chunk to the cold tier", "chunk", c.chunkID, "err", err)
+				failed = true
+				lastErr = err
+			} else {
+				moved[c.chunkID] = struct{}{}
+			}
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+		}
+
+		if failed || !t.complete[tableName] {
+			continue
+		}
+		if err := t.markers.PutObject(ctx, tableName, bytes.NewReader(nil)); err != nil {
+			return err
+		}
+		level.Info(pkg_util.Logger).Log("msg", "moved all the chunks of table to the cold tier", "table", tableName)
+	}
+	t.pending = map[string][]chunkRef{}
+	return lastErr
+}
+
+// moveChunk copies the chunk to the cold tier before deleting it from the shared store, for it to always be in one of
+// them. The chunks failing to be got from the shared store were already moved by a previous run when they are in the
+// cold tier.
+func (t *tiering) moveChunk(ctx context.Context, chunkID string) error {
+	key := chunkID
+	if t.keyEncoder != nil {
+		key = t.keyEncoder(key)
+	}
+
+	reader, err := t.hot.GetObject(ctx, key)
+	if err != nil {
+		// not all the object clients tell the objects not found apart, the cold tier is checked on any error.
+		cold, coldErr := t.cold.GetObject(ctx, key)
+		if coldErr == nil {
+			cold.Close()
+			return nil
+		}
+		if err == chunk.ErrStorageObjectNotFound {
+			return fmt.Errorf("chunk %s not found in the shared store nor in the cold tier", chunkID)
+		}
+		return err
+	}
+	buf, err := ioutil.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return err
+	}
+
+	if err := t.cold.PutObject(ctx, key, bytes.NewReader(buf)); err != nil {
+		return err
+	}
+	if err := t.hot.DeleteObject(ctx, key); err != nil && err != chunk.ErrStorageObjectNotFound {
+		return err
+	}
+
+	t.metrics.tieringMovedChunksTotal.Inc()
+	t.metrics.tieringMovedBytesTotal.Add(float64(len(buf)))
+	return nil
+}
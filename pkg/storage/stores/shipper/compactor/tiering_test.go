@@ -0,0 +1,130 @@
+package compactor
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/chunk/local"
+	"github.com/cortexproject/cortex/pkg/chunk/objectclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/storage/stores/shipper"
+)
+
+func TestTiering_MoveChunks(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "tiering")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	hot, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(tempDir, "hot")})
+	require.NoError(t, err)
+	cold, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(tempDir, "cold")})
+	require.NoError(t, err)
+	markers, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(tempDir, "markers")})
+	require.NoError(t, err)
+	hotChunks := objectclient.NewClient(hot, objectclient.Base64Encoder)
+	coldChunks := objectclient.NewClient(cold, objectclient.Base64Encoder)
+
+	db, err := local.OpenBoltdbFile(filepath.Join(tempDir, "db"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	periodConfig := newTestPeriodConfig()
+	schema := newTestSchema(t)
+	start := model.TimeFromUnix(18000 * 24 * 3600)
+	tableName := periodConfig.IndexTables.TableFor(start)
+
+	old := storeTestChunk(t, db, schema, hotChunks, "1", `{app="foo"}`, start, 10)
+	recent := storeTestChunk(t, db, schema, hotChunks, "1", `{app="bar"}`, start.Add(20*time.Hour), 10)
+
+	cfg := Config{SharedStoreType: shipper.FilesystemObjectStoreType, TieringMoveAfter: 12 * time.Hour}
+	tiering := newTiering(cfg, hot, cold, chunk.SchemaConfig{Configs: []chunk.PeriodConfig{periodConfig}}, markers, newMetrics(prometheus.NewRegistry()))
+
+	run := func(now model.Time) {
+		require.NoError(t, tiering.start(context.Background(), now.Time()))
+		if tiering.isDue(tableName) {
+			require.NoError(t, tiering.collect(tableName, db))
+		}
+		require.NoError(t, tiering.moveChunks(context.Background()))
+	}
+
+	// the old chunk is moved with the same key, the recent one is kept in the shared store.
+	run(start.Add(24 * time.Hour))
+	require.Equal(t, []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, readTestChunk(t, coldChunks, "1", old, start))
+	_, err = hot.GetObject(context.Background(), objectclient.Base64Encoder(old))
+	require.Equal(t, chunk.ErrStorageObjectNotFound, err)
+	require.Len(t, readTestChunk(t, hotChunks, "1", recent, start), 10)
+	require.Equal(t, float64(1), testutil.ToFloat64(tiering.metrics.tieringMovedChunksTotal))
+
+	// the table still references a chunk to move later.
+	require.NoError(t, tiering.start(context.Background(), start.Add(24*time.Hour).Time()))
+	require.True(t, tiering.isDue(tableName))
+
+	// the recent chunk is moved once old enough, the old one being already moved, and the table is skipped by the next
+	// runs.
+	run(start.Add(48 * time.Hour))
+	require.Len(t, readTestChunk(t, coldChunks, "1", recent, start), 10)
+	_, err = hot.GetObject(context.Background(), objectclient.Base64Encoder(recent))
+	require.Equal(t, chunk.ErrStorageObjectNotFound, err)
+	require.Equal(t, float64(2), testutil.ToFloat64(tiering.metrics.tieringMovedChunksTotal))
+
+	require.NoError(t, tiering.start(context.Background(), start.Add(48*time.Hour).Time()))
+	require.False(t, tiering.isDue(tableName))
+}
+
+func TestTiering_MoveChunks_Missing(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "tiering")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	hot, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(tempDir, "hot")})
+	require.NoError(t, err)
+	cold, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(tempDir, "cold")})
+	require.NoError(t, err)
+	markers, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(tempDir, "markers")})
+	require.NoError(t, err)
+	hotChunks := objectclient.NewClient(hot, objectclient.Base64Encoder)
+
+	db, err := local.OpenBoltdbFile(filepath.Join(tempDir, "db"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	periodConfig := newTestPeriodConfig()
+	start := model.TimeFromUnix(18000 * 24 * 3600)
+	tableName := periodConfig.IndexTables.TableFor(start)
+
+	// the chunk is referenced by the index but in neither tier.
+	missing := storeTestChunk(t, db, newTestSchema(t), hotChunks, "1", `{app="foo"}`, start, 10)
+	require.NoError(t, hot.DeleteObject(context.Background(), objectclient.Base64Encoder(missing)))
+
+	cfg := Config{SharedStoreType: shipper.FilesystemObjectStoreType, TieringMoveAfter: 12 * time.Hour}
+	tiering := newTiering(cfg, hot, cold, chunk.SchemaConfig{Configs: []chunk.PeriodConfig{periodConfig}}, markers, newMetrics(prometheus.NewRegistry()))
+
+	now := start.Add(48 * time.Hour).Time()
+	require.NoError(t, tiering.start(context.Background(), now))
+	require.NoError(t, tiering.collect(tableName, db))
+	require.Error(t, tiering.moveChunks(context.Background()))
+	require.Equal(t, float64(1), testutil.ToFloat64(tiering.metrics.tieringFailuresTotal))
+
+	// the table is not marked as done.
+	require.NoError(t, tiering.start(context.Background(), now))
+	require.True(t, tiering.isDue(tableName))
+}
@@ -0,0 +1,309 @@
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	"go.etcd.io/bbolt"
+)
+
+// UsagePrefix is the prefix of the usage reports of the tables, in the shared store.
+const UsagePrefix = "usage/"
+
+// TenantUsage is the storage used by a tenant. The size of the chunks is only known for the measured ones, the
+// others being measured by the next runs.
+type TenantUsage struct {
+	Chunks         int   `json:"chunks"`
+	MeasuredChunks int   `json:"measured_chunks"`
+	ChunkBytes     int64 `json:"chunk_bytes"`
+	IndexBytes     int64 `json:"index_bytes"`
+}
+
+func (u *TenantUsage) add(o TenantUsage) {
+	u.Chunks += o.Chunks
+	u.MeasuredChunks += o.MeasuredChunks
+	u.ChunkBytes += o.ChunkBytes
+	u.IndexBytes += o.IndexBytes
+}
+
+// usageReport is the usage of the tenants of a table, computed from the files of the table it lists.
+type usageReport struct {
+	Table    string                  `json:"table"`
+	Start    model.Time              `json:"start"`
+	Files    []string                `json:"files"`
+	Complete bool                    `json:"complete"`
+	Tenants  map[string]*TenantUsage `json:"tenants"`
+}
+
+// usage reports the storage used by the tenants in each table along the compaction of the tables. The chunks are
+// counted in the table of their start only, for the chunks overlapping several tables not to be counted several times,
+// while the index entries are counted in every table. The size of the chunks is measured by fetching them, up to the
+// max chunks per run, and kept in memory while their table can still get new files. The reports are kept in the shared
+// store, one object per table, and computed again whenever the files of their table change.
+type usage struct {
+	maxChunks    int
+	chunkClient  chunk.Client
+	schemaConfig chunk.SchemaConfig
+	reports      chunk.ObjectClient
+	metrics      *metrics
+
+	// state of the current run.
+	now     model.Time
+	fetched int
+	current map[string]*usageReport
+
+	// size of the measured chunks by table.
+	sizes map[string]map[string]int64
+}
+
+func newUsage(cfg Config, chunkClient chunk.Client, schemaConfig chunk.SchemaConfig, reports chunk.ObjectClient, metrics *metrics) *usage {
+	return &usage{
+		maxChunks:    cfg.UsageMaxChunksPerRun,
+		chunkClient:  chunkClient,
+		schemaConfig: schemaConfig,
+		reports:      reports,
+		metrics:      metrics,
+		sizes:        map[string]map[string]int64{},
+	}
+}
+
+// start starts a run, reading the reports of the tables.
+func (u *usage) start(ctx context.Context, now model.Time) error {
+	reports, err := readUsageReports(ctx, u.reports)
+	if err != nil {
+		return err
+	}
+
+	u.now, u.fetched = now, 0
+	u.current = make(map[string]*usageReport, len(reports))
+	for _, report := range reports {
+		u.current[report.Table] = report
+	}
+	return nil
+}
+
+// isUpToDate tells whether the report of the table is complete and computed from the objects of the table.
+func (u *usage) isUpToDate(tableName string, objects []chunk.StorageObject) bool {
+	report, ok := u.current[tableName]
+	if !ok || !report.Complete || len(report.Files) != len(objects) {
+		return false
+	}
+
+	files := make(map[string]struct{}, len(report.Files))
+	for _, file := range report.Files {
+		files[file] = struct{}{}
+	}
+	for _, object := range objects {
+		if _, ok := files[object.Key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compute computes the usage of the tenants in the db of the table. The files of the report are set once it is known
+// which files of the table hold the db.
+func (u *usage) compute(ctx context.Context, tableName string, db *bbolt.DB) (*usageReport, error) {
+	allSeries, err := readSeries(db)
+	if err != nil {
+		return nil, err
+	}
+	indexBytes, err := indexBytesByTenant(db)
+	if err != nil {
+		return nil, err
+	}
+
+	start, period := tableStart(u.schemaConfig, tableName)
+	report := &usageReport{Table: tableName, Start: start, Complete: true, Tenants: map[string]*TenantUsage{}}
+	tenant := func(userID string) *TenantUsage {
+		tu, ok := report.Tenants[userID]
+		if !ok {
+			tu = &TenantUsage{}
+			report.Tenants[userID] = tu
+		}
+		return tu
+	}
+	for userID, size := range indexBytes {
+		tenant(userID).IndexBytes = size
+	}
+
+	sizes := u.sizes[tableName]
+	measured := make(map[string]int64, len(sizes))
+	for _, s := range allSeries {
+		for _, c := range s.chunks {
+			owner, err := tableFor(u.schemaConfig, c.from)
+			if err != nil {
+				return nil, err
+			}
+			if owner != tableName {
+				continue
+			}
+
+			tu := tenant(c.userID)
+			tu.Chunks++
+
+			size, ok := sizes[c.chunkID]
+			if !ok {
+				if u.maxChunks > 0 && u.fetched >= u.maxChunks {
+					report.Complete = false
+					continue
+				}
+				size, err = u.measure(ctx, c)
+				if err != nil {
+					return nil, err
+				}
+			}
+			measured[c.chunkID] = size
+			tu.MeasuredChunks++
+			tu.ChunkBytes += size
+		}
+	}
+
+	// the chunks of the tables which can't get new files anymore won't have to be measured again once the report is
+	// complete.
+	if report.Complete && period > 0 && start.Add(2*period) < u.now {
+		delete(u.sizes, tableName)
+	} else {
+		u.sizes[tableName] = measured
+	}
+	return report, nil
+}
+
+// measure fetches the chunk to get its size in the store.
+func (u *usage) measure(ctx context.Context, c chunkRef) (int64, error) {
+	u.fetched++
+
+	key, err := chunk.ParseExternalKey(c.userID, c.chunkID)
+	if err != nil {
+		return 0, err
+	}
+	chunks, err := u.chunkClient.GetChunks(ctx, []chunk.Chunk{key})
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch chunk %s: %w", c.chunkID, err)
+	}
+	encoded, err := chunks[0].Encoded()
+	if err != nil {
+		return 0, err
+	}
+	u.metrics.usageMeasuredChunksTotal.Inc()
+	return int64(len(encoded)), nil
+}
+
+// save stores the report of the table computed from the files.
+func (u *usage) save(ctx context.Context, report *usageReport, files []string) error {
+	report.Files = files
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	if err := u.reports.PutObject(ctx, report.Table+".json", bytes.NewReader(data)); err != nil {
+		return err
+	}
+	u.current[report.Table] = report
+	level.Info(util.Logger).Log("msg", "updated usage report of table", "table", report.Table, "tenants", len(report.Tenants), "complete", report.Complete)
+	return nil
+}
+
+// readUsageReports reads the reports of all the tables from the store.
+func readUsageReports(ctx context.Context, reports chunk.ObjectClient) ([]*usageReport, error) {
+	objects, _, err := reports.List(ctx, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*usageReport, 0, len(objects))
+	for _, object := range objects {
+		if !strings.HasSuffix(object.Key, ".json") {
+			continue
+		}
+		r, err := reports.GetObject(ctx, object.Key)
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		report := &usageReport{}
+		if err := json.Unmarshal(data, report); err != nil {
+			return nil, fmt.Errorf("failed to decode usage report %s: %w", object.Key, err)
+		}
+		result = append(result, report)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Start < result[j].Start })
+	return result, nil
+}
+
+// indexBytesByTenant returns the size of the index entries of the db, keys and values, by tenant.
+func indexBytesByTenant(db *bbolt.DB) (map[string]int64, error) {
+	result := map[string]int64{}
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucketName)
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			ik, ok := parseIndexKey(k)
+			if !ok {
+				return nil
+			}
+			hv := ik.hashValue
+
+			// see readSeries for the hash values of the entries.
+			userIDIndex := 3
+			switch ik.rangeKeyType() {
+			case chunkTimeRangeKeyV3, seriesRangeKeyV1:
+			case labelSeriesRangeKeyV1:
+				userIDIndex = 4
+			default:
+				return nil
+			}
+			if len(hv) < userIDIndex {
+				return nil
+			}
+			result[hv[len(hv)-userIDIndex]] += int64(len(k) + len(v))
+			return nil
+		})
+	})
+	return result, err
+}
+
+// tableFor returns the index table holding the time.
+func tableFor(schemaConfig chunk.SchemaConfig, t model.Time) (string, error) {
+	for i := len(schemaConfig.Configs) - 1; i >= 0; i-- {
+		if schemaConfig.Configs[i].From.Time <= t {
+			return schemaConfig.Configs[i].IndexTables.TableFor(t), nil
+		}
+	}
+	return "", fmt.Errorf("no schema config found for time %v", t)
+}
+
+// tableStart returns the start of the periodic index table and its period, 0 for the other tables.
+func tableStart(schemaConfig chunk.SchemaConfig, tableName string) (model.Time, time.Duration) {
+	for _, cfg := range schemaConfig.Configs {
+		period := cfg.IndexTables.Period
+		if period <= 0 || !strings.HasPrefix(tableName, cfg.IndexTables.Prefix) {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimPrefix(tableName, cfg.IndexTables.Prefix), 10, 64)
+		if err != nil {
+			continue
+		}
+		return model.TimeFromUnix(n * int64(period/time.Second)), period
+	}
+	return 0, 0
+}
@@ -0,0 +1,63 @@
+package compactor
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/prometheus/common/model"
+	"github.com/weaveworks/common/user"
+
+	serverutil "github.com/famarks/loki/pkg/util/server"
+)
+
+// TableUsage is the storage used by a tenant in a table.
+type TableUsage struct {
+	Table    string     `json:"table"`
+	Start    model.Time `json:"start"`
+	Complete bool       `json:"complete"`
+	TenantUsage
+}
+
+// TenantUsageReport is the storage used by a tenant in total and in each table, the oldest table first.
+type TenantUsageReport struct {
+	Total  TenantUsage  `json:"total"`
+	Tables []TableUsage `json:"tables"`
+}
+
+// UsageHandler reports the storage used by the tenant of the request, as of the last time the compactor computed the
+// usage of each table.
+func (c *Compactor) UsageHandler(w http.ResponseWriter, r *http.Request) {
+	tenant, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	reports, err := readUsageReports(r.Context(), c.usage.reports)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+
+	result := map[string]*TenantUsageReport{}
+	for _, report := range reports {
+		for userID, tu := range report.Tenants {
+			if userID != tenant {
+				continue
+			}
+
+			tr, ok := result[userID]
+			if !ok {
+				tr = &TenantUsageReport{}
+				result[userID] = tr
+			}
+			tr.Total.add(*tu)
+			tr.Tables = append(tr.Tables, TableUsage{Table: report.Table, Start: report.Start, Complete: report.Complete, TenantUsage: *tu})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		serverutil.WriteError(err, w)
+	}
+}
@@ -0,0 +1,103 @@
+package compactor
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/chunk/local"
+	"github.com/cortexproject/cortex/pkg/chunk/objectclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/famarks/loki/pkg/storage/stores/util"
+)
+
+func TestUsage_Compute(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "usage")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	fsClient, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(tempDir, "chunks")})
+	require.NoError(t, err)
+	chunkClient := objectclient.NewClient(fsClient, nil)
+	reportsClient, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(tempDir, "reports")})
+	require.NoError(t, err)
+
+	db, err := local.OpenBoltdbFile(filepath.Join(tempDir, "db"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+
+	periodConfig := newTestPeriodConfig()
+	schema := newTestSchema(t)
+	start := model.TimeFromUnix(18000 * 24 * 3600)
+	tableName := periodConfig.IndexTables.TableFor(start)
+
+	storeTestChunk(t, db, schema, chunkClient, "1", `{app="foo"}`, start, 10)
+	storeTestChunk(t, db, schema, chunkClient, "1", `{app="bar"}`, start, 10)
+	storeTestChunk(t, db, schema, chunkClient, "2", `{app="foo"}`, start, 10)
+	// the chunks starting in the previous table are only counted there.
+	storeTestChunk(t, db, schema, chunkClient, "2", `{app="bar"}`, start.Add(-5*time.Minute), 10)
+
+	cfg := Config{UsageEnabled: true, UsageMaxChunksPerRun: 2}
+	compactor := &Compactor{
+		usage: newUsage(cfg, chunkClient, chunk.SchemaConfig{Configs: []chunk.PeriodConfig{periodConfig}}, util.NewPrefixedObjectClient(reportsClient, UsagePrefix), newMetrics(prometheus.NewRegistry())),
+	}
+	u := compactor.usage
+	objects := []chunk.StorageObject{{Key: tableName + "/compactor-1.gz"}}
+
+	// the size of a part of the chunks only is measured by the first run.
+	require.NoError(t, u.start(context.Background(), start.Add(72*time.Hour)))
+	require.False(t, u.isUpToDate(tableName, objects))
+	report, err := u.compute(context.Background(), tableName, db)
+	require.NoError(t, err)
+	require.False(t, report.Complete)
+	require.Equal(t, start, report.Start)
+	require.NoError(t, u.save(context.Background(), report, []string{objects[0].Key}))
+	require.False(t, u.isUpToDate(tableName, objects))
+
+	require.NoError(t, u.start(context.Background(), start.Add(72*time.Hour)))
+	report, err = u.compute(context.Background(), tableName, db)
+	require.NoError(t, err)
+	require.True(t, report.Complete)
+	require.NoError(t, u.save(context.Background(), report, []string{objects[0].Key}))
+	require.True(t, u.isUpToDate(tableName, objects))
+	require.False(t, u.isUpToDate(tableName, append(objects, chunk.StorageObject{Key: tableName + "/ingester-1"})))
+	// the sizes of the tables which can't get new files anymore are dropped once their report is complete.
+	require.Empty(t, u.sizes)
+
+	require.Equal(t, 2, report.Tenants["1"].Chunks)
+	require.Equal(t, 2, report.Tenants["1"].MeasuredChunks)
+	require.Greater(t, report.Tenants["1"].ChunkBytes, int64(0))
+	require.Greater(t, report.Tenants["1"].IndexBytes, int64(0))
+	require.Equal(t, 1, report.Tenants["2"].Chunks)
+	require.Greater(t, report.Tenants["2"].IndexBytes, int64(0))
+
+	// the usage of the tenant is served from the reports of the store.
+	w := httptest.NewRecorder()
+	compactor.UsageHandler(w, httptest.NewRequest("GET", "/compactor/usage", nil).WithContext(user.InjectOrgID(context.Background(), "1")))
+	var response map[string]*TenantUsageReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response, 1)
+	require.Equal(t, *report.Tenants["1"], response["1"].Total)
+	require.Equal(t, []TableUsage{{Table: tableName, Start: start, Complete: true, TenantUsage: *report.Tenants["1"]}}, response["1"].Tables)
+
+	// the usage of the other tenants is not served.
+	w = httptest.NewRecorder()
+	compactor.UsageHandler(w, httptest.NewRequest("GET", "/compactor/usage?tenant=2", nil))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
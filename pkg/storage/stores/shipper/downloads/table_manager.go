@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,12 +19,18 @@ import (
 	"github.com/famarks/loki/pkg/storage/stores/shipper/util"
 )
 
-const cacheCleanupInterval = time.Hour
+const (
+	cacheCleanupInterval = time.Hour
+	daySeconds           = int64(24 * time.Hour / time.Second)
+)
 
 type Config struct {
 	CacheDir     string
 	SyncInterval time.Duration
 	CacheTTL     time.Duration
+	// QueryReadyNumDays is the number of the most recent daily tables kept downloaded, 0 to only download the tables
+	// when they are queried.
+	QueryReadyNumDays int
 }
 
 type TableManager struct {
@@ -59,6 +67,13 @@ func NewTableManager(cfg Config, boltIndexClient BoltDBIndexClient, storageClien
 		ctx:             ctx,
 		cancel:          cancel,
 	}
+
+	// download the recent tables before serving any query, so that the first queries don't wait for them.
+	if err := tm.ensureQueryReadiness(ctx); err != nil {
+		tm.Stop()
+		return nil, err
+	}
+
 	go tm.loop()
 	return tm, nil
 }
@@ -80,6 +95,12 @@ func (tm *TableManager) loop() {
 			if err != nil {
 				level.Error(pkg_util.Logger).Log("msg", "error syncing local boltdb files with storage", "err", err)
 			}
+
+			// the tables of the new days are downloaded along with the syncs.
+			err = tm.ensureQueryReadiness(tm.ctx)
+			if err != nil {
+				level.Error(pkg_util.Logger).Log("msg", "error downloading the query ready tables", "err", err)
+			}
 		case <-cacheCleanupTicker.C:
 			err := tm.cleanupCache()
 			if err != nil {
@@ -197,6 +218,10 @@ func (tm *TableManager) cleanupCache() error {
 	level.Info(pkg_util.Logger).Log("msg", "cleaning tables cache")
 
 	for name, table := range tm.tables {
+		if tm.isQueryReady(name) {
+			continue
+		}
+
 		lastUsedAt := table.LastUsedAt()
 		if lastUsedAt.Add(tm.cfg.CacheTTL).Before(time.Now()) {
 			level.Info(pkg_util.Logger).Log("msg", fmt.Sprintf("cleaning up expired table %s", name))
@@ -211,3 +236,64 @@ func (tm *TableManager) cleanupCache() error {
 
 	return nil
 }
+
+// ensureQueryReadiness downloads the query ready tables which are not downloaded yet, waiting for them to be downloaded.
+func (tm *TableManager) ensureQueryReadiness(ctx context.Context) error {
+	if tm.cfg.QueryReadyNumDays <= 0 {
+		return nil
+	}
+
+	_, dirs, err := tm.storageClient.List(ctx, "", delimiter)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		tableName := strings.TrimSuffix(string(dir), delimiter)
+		if !tm.isQueryReady(tableName) {
+			continue
+		}
+
+		tm.tablesMtx.RLock()
+		_, ok := tm.tables[tableName]
+		tm.tablesMtx.RUnlock()
+		if ok {
+			continue
+		}
+
+		table := tm.getOrCreateTable(ctx, tableName)
+		select {
+		case <-table.ready:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if table.Err() != nil {
+			// the table is removed so that the next queries or syncs create it again.
+			tm.tablesMtx.Lock()
+			delete(tm.tables, tableName)
+			tm.tablesMtx.Unlock()
+			return table.Err()
+		}
+	}
+	return nil
+}
+
+// isQueryReady tells whether the table is one of the query ready tables. The tables are daily ones, their names end with
+// the number of days since the epoch.
+func (tm *TableManager) isQueryReady(tableName string) bool {
+	if tm.cfg.QueryReadyNumDays <= 0 {
+		return false
+	}
+
+	i := len(tableName)
+	for i > 0 && tableName[i-1] >= '0' && tableName[i-1] <= '9' {
+		i--
+	}
+	tableNumber, err := strconv.ParseInt(tableName[i:], 10, 64)
+	if err != nil {
+		return false
+	}
+
+	today := time.Now().Unix() / daySeconds
+	return tableNumber > today-int64(tm.cfg.QueryReadyNumDays) && tableNumber <= today
+}
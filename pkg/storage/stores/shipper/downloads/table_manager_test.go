@@ -2,6 +2,7 @@ package downloads
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -131,3 +132,56 @@ func TestTableManager_cleanupCache(t *testing.T) {
 	_, ok = tableManager.tables[nonExpiredTableName]
 	require.True(t, ok)
 }
+
+func TestTableManager_ensureQueryReadiness(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "table-manager-query-readiness")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	objectStoragePath := filepath.Join(tempDir, objectsStorageDirName)
+	today := time.Now().Unix() / daySeconds
+	tableName := func(daysAgo int64) string {
+		return fmt.Sprintf("index_%d", today-daysAgo)
+	}
+
+	for i, daysAgo := range []int64{0, 1, 5} {
+		testutil.SetupDBTablesAtPath(t, tableName(daysAgo), objectStoragePath, map[string]testutil.DBRecords{
+			"db1": {Start: i * 10, NumRecords: 10},
+		}, true)
+	}
+
+	boltDBIndexClient, fsObjectClient := buildTestClients(t, tempDir)
+	defer boltDBIndexClient.Stop()
+
+	tableManager, err := NewTableManager(Config{
+		CacheDir:          filepath.Join(tempDir, cacheDirName),
+		SyncInterval:      time.Hour,
+		CacheTTL:          time.Hour,
+		QueryReadyNumDays: 2,
+	}, boltDBIndexClient, fsObjectClient, nil)
+	require.NoError(t, err)
+	defer tableManager.Stop()
+
+	// the tables of the last 2 days are downloaded on startup.
+	require.Len(t, tableManager.tables, 2)
+	for _, daysAgo := range []int64{0, 1} {
+		table, ok := tableManager.tables[tableName(daysAgo)]
+		require.True(t, ok)
+		require.NoError(t, table.Err())
+		require.Len(t, table.dbs, 1)
+	}
+
+	// the query ready tables are kept even when they are not queried.
+	for _, table := range tableManager.tables {
+		table.lastUsedAt = time.Now().Add(-(tableManager.cfg.CacheTTL + time.Minute))
+	}
+	require.NoError(t, tableManager.cleanupCache())
+	require.Len(t, tableManager.tables, 2)
+
+	require.False(t, tableManager.isQueryReady(tableName(2)))
+	require.False(t, tableManager.isQueryReady(tableName(-1)))
+	require.False(t, tableManager.isQueryReady("index"))
+}
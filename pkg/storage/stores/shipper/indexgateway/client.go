@@ -0,0 +1,120 @@
+package indexgateway
+
+import (
+	"context"
+	"flag"
+	"io"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	grpc_store "github.com/cortexproject/cortex/pkg/chunk/grpc"
+	chunk_util "github.com/cortexproject/cortex/pkg/chunk/util"
+	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/weaveworks/common/middleware"
+	"google.golang.org/grpc"
+
+	"github.com/famarks/loki/pkg/util/grpcclient"
+)
+
+var errReadOnly = errors.New("the index gateway client is read-only")
+
+// ClientConfig configures the client used by queriers and rulers to query the index through an index gateway.
+type ClientConfig struct {
+	Address          string            `yaml:"server_address"`
+	GRPCClientConfig grpcclient.Config `yaml:"grpc_client_config"`
+}
+
+// RegisterFlagsWithPrefix registers flags with the given prefix.
+func (cfg *ClientConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Address, prefix+".server-address", "", "Address of the index gateway in host:port format. When set, queriers and rulers query the index through the index gateway instead of downloading it.")
+	cfg.GRPCClientConfig.RegisterFlagsWithPrefix(prefix, f)
+}
+
+// Client is a read-only index client querying an index gateway.
+type Client struct {
+	grpcClient grpc_store.GrpcStoreClient
+	conn       *grpc.ClientConn
+}
+
+// NewClient makes a new Client connected to the index gateway at cfg.Address.
+func NewClient(cfg ClientConfig) (*Client, error) {
+	opts := []grpc.DialOption{
+		grpc.WithDefaultCallOptions(cfg.GRPCClientConfig.CallOptions()...),
+	}
+	dialOpts, err := cfg.GRPCClientConfig.DialOption(instrumentation())
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid index gateway client config")
+	}
+	opts = append(opts, dialOpts...)
+	conn, err := grpc.Dial(cfg.Address, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to dial index gateway %s", cfg.Address)
+	}
+
+	return &Client{
+		grpcClient: grpc_store.NewGrpcStoreClient(conn),
+		conn:       conn,
+	}, nil
+}
+
+func instrumentation() ([]grpc.UnaryClientInterceptor, []grpc.StreamClientInterceptor) {
+	return []grpc.UnaryClientInterceptor{
+		otgrpc.OpenTracingClientInterceptor(opentracing.GlobalTracer()),
+		middleware.ClientUserHeaderInterceptor,
+	}, []grpc.StreamClientInterceptor{
+		otgrpc.OpenTracingStreamClientInterceptor(opentracing.GlobalTracer()),
+		middleware.StreamClientUserHeaderInterceptor,
+	}
+}
+
+// Stop closes the connection to the index gateway.
+func (c *Client) Stop() {
+	_ = c.conn.Close()
+}
+
+// NewWriteBatch implements chunk.IndexClient.
+func (c *Client) NewWriteBatch() chunk.WriteBatch {
+	return &grpc_store.WriteBatch{}
+}
+
+// BatchWrite implements chunk.IndexClient, writes must go to the boltdb-shipper of the ingesters.
+func (c *Client) BatchWrite(_ context.Context, _ chunk.WriteBatch) error {
+	return errReadOnly
+}
+
+// QueryPages implements chunk.IndexClient.
+func (c *Client) QueryPages(ctx context.Context, queries []chunk.IndexQuery, callback func(chunk.IndexQuery, chunk.ReadBatch) (shouldContinue bool)) error {
+	return chunk_util.DoParallelQueries(ctx, c.query, queries, callback)
+}
+
+func (c *Client) query(ctx context.Context, query chunk.IndexQuery, callback chunk_util.Callback) error {
+	// cancel the stream if the callback is done before reaching its end.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.grpcClient.QueryIndex(ctx, &grpc_store.QueryIndexRequest{
+		TableName:        query.TableName,
+		HashValue:        query.HashValue,
+		RangeValuePrefix: query.RangeValuePrefix,
+		RangeValueStart:  query.RangeValueStart,
+		ValueEqual:       query.ValueEqual,
+		Immutable:        query.Immutable,
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !callback(query, resp) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package indexgateway
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	grpc_store "github.com/cortexproject/cortex/pkg/chunk/grpc"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+// IndexQuerier is the part of the index client used by the gateway to serve index lookups.
+type IndexQuerier interface {
+	QueryPages(ctx context.Context, queries []chunk.IndexQuery, callback func(chunk.IndexQuery, chunk.ReadBatch) (shouldContinue bool)) error
+	Stop()
+}
+
+// Gateway serves the index lookups of queriers and rulers over gRPC, so that they don't need to download the
+// boltdb-shipper index themselves. It implements the QueryIndex method of the cortex gRPC store service, the
+// other methods are left unimplemented since the gateway is read-only.
+type Gateway struct {
+	grpc_store.UnimplementedGrpcStoreServer
+	services.Service
+
+	indexQuerier IndexQuerier
+}
+
+// NewIndexGateway makes a new Gateway serving the lookups from the given index.
+func NewIndexGateway(indexQuerier IndexQuerier) *Gateway {
+	g := &Gateway{indexQuerier: indexQuerier}
+	g.Service = services.NewIdleService(nil, func(_ error) error {
+		g.indexQuerier.Stop()
+		return nil
+	})
+	return g
+}
+
+// QueryIndex streams back the rows of the index matching the query, one response per read batch.
+func (g *Gateway) QueryIndex(req *grpc_store.QueryIndexRequest, server grpc_store.GrpcStore_QueryIndexServer) error {
+	query := chunk.IndexQuery{
+		TableName:        req.TableName,
+		HashValue:        req.HashValue,
+		RangeValuePrefix: req.RangeValuePrefix,
+		RangeValueStart:  req.RangeValueStart,
+		ValueEqual:       req.ValueEqual,
+		Immutable:        req.Immutable,
+	}
+
+	var (
+		sendErr error
+		sendMtx sync.Mutex
+	)
+	err := g.indexQuerier.QueryPages(server.Context(), []chunk.IndexQuery{query}, func(_ chunk.IndexQuery, batch chunk.ReadBatch) bool {
+		resp := &grpc_store.QueryIndexResponse{}
+		itr := batch.Iterator()
+		for itr.Next() {
+			resp.Rows = append(resp.Rows, &grpc_store.Row{
+				RangeValue: itr.RangeValue(),
+				Value:      itr.Value(),
+			})
+		}
+
+		// batches of the different index files can be sent concurrently.
+		sendMtx.Lock()
+		defer sendMtx.Unlock()
+		if sendErr != nil {
+			return false
+		}
+		sendErr = server.Send(resp)
+		return sendErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	return sendErr
+}
@@ -0,0 +1,85 @@
+package indexgateway
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	grpc_store "github.com/cortexproject/cortex/pkg/chunk/grpc"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+)
+
+// fakeIndexQuerier returns a batch of two rows per query, with values made of the query table and hash values.
+type fakeIndexQuerier struct{}
+
+func (fakeIndexQuerier) QueryPages(_ context.Context, queries []chunk.IndexQuery, callback func(chunk.IndexQuery, chunk.ReadBatch) (shouldContinue bool)) error {
+	for _, query := range queries {
+		batch := &grpc_store.QueryIndexResponse{}
+		for i := 0; i < 2; i++ {
+			batch.Rows = append(batch.Rows, &grpc_store.Row{
+				RangeValue: []byte(fmt.Sprintf("%s-%d", query.RangeValuePrefix, i)),
+				Value:      []byte(fmt.Sprintf("%s:%s-%d", query.TableName, query.HashValue, i)),
+			})
+		}
+		if !callback(query, batch) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (fakeIndexQuerier) Stop() {}
+
+func TestGateway_QueryPages(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	server := grpc.NewServer()
+	grpc_store.RegisterGrpcStoreServer(server, NewIndexGateway(fakeIndexQuerier{}))
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+
+	var cfg ClientConfig
+	cfg.RegisterFlagsWithPrefix("index-gateway-client", flag.NewFlagSet("test", flag.PanicOnError))
+	cfg.Address = listener.Addr().String()
+
+	client, err := NewClient(cfg)
+	require.NoError(t, err)
+	defer client.Stop()
+
+	queries := []chunk.IndexQuery{
+		{TableName: "index_1", HashValue: "a", RangeValuePrefix: []byte("x")},
+		{TableName: "index_2", HashValue: "b", RangeValuePrefix: []byte("y")},
+	}
+
+	var (
+		values    []string
+		valuesMtx sync.Mutex
+	)
+	ctx := user.InjectOrgID(context.Background(), "fake")
+	err = client.QueryPages(ctx, queries, func(query chunk.IndexQuery, batch chunk.ReadBatch) bool {
+		valuesMtx.Lock()
+		defer valuesMtx.Unlock()
+
+		itr := batch.Iterator()
+		for itr.Next() {
+			values = append(values, fmt.Sprintf("%s=%s", itr.RangeValue(), itr.Value()))
+		}
+		return true
+	})
+	require.NoError(t, err)
+
+	sort.Strings(values)
+	require.Equal(t, []string{"x-0=index_1:a-0", "x-1=index_1:a-1", "y-0=index_2:b-0", "y-1=index_2:b-1"}, values)
+
+	require.Equal(t, errReadOnly, client.BatchWrite(context.Background(), client.NewWriteBatch()))
+}
@@ -21,6 +21,7 @@ import (
 	"go.etcd.io/bbolt"
 
 	"github.com/famarks/loki/pkg/storage/stores/shipper/downloads"
+	"github.com/famarks/loki/pkg/storage/stores/shipper/indexgateway"
 	"github.com/famarks/loki/pkg/storage/stores/shipper/uploads"
 	"github.com/famarks/loki/pkg/storage/stores/util"
 )
@@ -59,8 +60,11 @@ type Config struct {
 	CacheLocation        string        `yaml:"cache_location"`
 	CacheTTL             time.Duration `yaml:"cache_ttl"`
 	ResyncInterval       time.Duration `yaml:"resync_interval"`
+	QueryReadyNumDays    int           `yaml:"query_ready_num_days"`
 	IngesterName         string        `yaml:"-"`
 	Mode                 int           `yaml:"-"`
+
+	IndexGatewayClientConfig indexgateway.ClientConfig `yaml:"index_gateway_client"`
 }
 
 // RegisterFlags registers flags.
@@ -70,6 +74,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.CacheLocation, "boltdb.shipper.cache-location", "", "Cache location for restoring boltDB files for queries")
 	f.DurationVar(&cfg.CacheTTL, "boltdb.shipper.cache-ttl", 24*time.Hour, "TTL for boltDB files restored in cache for queries")
 	f.DurationVar(&cfg.ResyncInterval, "boltdb.shipper.resync-interval", 5*time.Minute, "Resync downloaded files with the storage")
+	f.IntVar(&cfg.QueryReadyNumDays, "boltdb.shipper.query-ready-num-days", 0, "Number of the most recent days of index tables to download at startup and keep downloaded, instead of downloading them when they are first queried. Works only with the tables created with a 24h period. 0 to disable.")
+	cfg.IndexGatewayClientConfig.RegisterFlagsWithPrefix("boltdb.shipper.index-gateway-client", f)
 }
 
 type Shipper struct {
@@ -137,9 +143,10 @@ func (s *Shipper) init(storageClient chunk.ObjectClient, registerer prometheus.R
 
 	if s.cfg.Mode != ModeWriteOnly {
 		cfg := downloads.Config{
-			CacheDir:     s.cfg.CacheLocation,
-			SyncInterval: s.cfg.ResyncInterval,
-			CacheTTL:     s.cfg.CacheTTL,
+			CacheDir:          s.cfg.CacheLocation,
+			SyncInterval:      s.cfg.ResyncInterval,
+			CacheTTL:          s.cfg.CacheTTL,
+			QueryReadyNumDays: s.cfg.QueryReadyNumDays,
 		}
 		downloadsManager, err := downloads.NewTableManager(cfg, s.boltDBIndexClient, prefixedObjectClient, registerer)
 		if err != nil {
@@ -22,7 +22,12 @@ type boltDBShipperTableClient struct {
 }
 
 func NewBoltDBShipperTableClient(objectClient chunk.ObjectClient) chunk.TableClient {
-	return &boltDBShipperTableClient{util.NewPrefixedObjectClient(objectClient, StorageKeyPrefix)}
+	return NewTableClient(objectClient, StorageKeyPrefix)
+}
+
+// NewTableClient makes a table client for the tables kept as folders under the given prefix of the object store.
+func NewTableClient(objectClient chunk.ObjectClient, storageKeyPrefix string) chunk.TableClient {
+	return &boltDBShipperTableClient{util.NewPrefixedObjectClient(objectClient, storageKeyPrefix)}
 }
 
 func (b *boltDBShipperTableClient) ListTables(ctx context.Context) ([]string, error) {
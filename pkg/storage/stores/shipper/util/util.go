@@ -35,6 +35,12 @@ func GetFileFromStorage(ctx context.Context, storageClient StorageClient, object
 		return err
 	}
 
+	defer func() {
+		if err := f.Close(); err != nil {
+			level.Error(util.Logger).Log("msg", "failed to close downloaded file", "path", destination, "err", err)
+		}
+	}()
+
 	var objectReader io.Reader = readCloser
 	if strings.HasSuffix(objectKey, ".gz") {
 		decompressedReader := chunkenc.Gzip.GetReader(readCloser)
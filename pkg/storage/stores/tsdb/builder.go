@@ -0,0 +1,136 @@
+package tsdb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/index"
+)
+
+type stream struct {
+	userID string
+	labels labels.Labels
+	fp     model.Fingerprint
+	chunks []chunkMeta
+	// added holds the chunks of the stream, for the chunks added several times to be kept once.
+	added map[chunkMeta]struct{}
+}
+
+// Builder collects the chunks of the series of a table and writes them to a TSDB index file.
+// It is not safe for concurrent use.
+type Builder struct {
+	streams map[string]*stream
+}
+
+// NewBuilder makes a new empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{streams: map[string]*stream{}}
+}
+
+// AddChunk adds a chunk of the series with labels ls and fingerprint fp of the user.
+// The labels must not contain the metric name.
+func (b *Builder) AddChunk(userID string, fp model.Fingerprint, ls labels.Labels, chk chunkMeta) {
+	key := userID + ls.String()
+	s, ok := b.streams[key]
+	if !ok {
+		s = &stream{userID: userID, labels: ls, fp: fp, added: map[chunkMeta]struct{}{}}
+		b.streams[key] = s
+	}
+
+	if _, ok := s.added[chk]; ok {
+		return
+	}
+	s.added[chk] = struct{}{}
+	s.chunks = append(s.chunks, chk)
+}
+
+// Empty tells whether no chunk has been added to the builder.
+func (b *Builder) Empty() bool {
+	return len(b.streams) == 0
+}
+
+func (b *Builder) forSeries(userID string, from, through model.Time, matchers []*labels.Matcher, fn func(ls labels.Labels, fp model.Fingerprint, chks []chunkMeta)) error {
+	matchers = withoutMetricName(matchers)
+
+outer:
+	for _, s := range b.streams {
+		if s.userID != userID || !hasChunksBetween(s.chunks, from, through) {
+			continue
+		}
+		for _, m := range matchers {
+			if !m.Matches(s.labels.Get(m.Name)) {
+				continue outer
+			}
+		}
+		fn(s.labels, s.fp, s.chunks)
+	}
+	return nil
+}
+
+// Build writes the series to a new TSDB index file at path, replacing any existing one. The tenant and the fingerprint
+// of the series are kept in internal labels and the checksums of the chunks in their references.
+func (b *Builder) Build(ctx context.Context, path string) error {
+	type series struct {
+		labels labels.Labels
+		chunks []chunks.Meta
+	}
+
+	allSeries := make([]series, 0, len(b.streams))
+	symbols := map[string]struct{}{}
+	for _, s := range b.streams {
+		lb := labels.NewBuilder(s.labels)
+		lb.Set(TenantLabel, s.userID)
+		lb.Set(FingerprintLabel, fmt.Sprintf("%016x", uint64(s.fp)))
+		ls := lb.Labels()
+
+		for _, l := range ls {
+			symbols[l.Name] = struct{}{}
+			symbols[l.Value] = struct{}{}
+		}
+
+		metas := make([]chunks.Meta, 0, len(s.chunks))
+		for _, c := range s.chunks {
+			metas = append(metas, chunks.Meta{
+				Ref:     uint64(c.Checksum),
+				MinTime: int64(c.From),
+				MaxTime: int64(c.Through),
+			})
+		}
+		sort.Slice(metas, func(i, j int) bool {
+			if metas[i].MinTime != metas[j].MinTime {
+				return metas[i].MinTime < metas[j].MinTime
+			}
+			return metas[i].Ref < metas[j].Ref
+		})
+
+		allSeries = append(allSeries, series{labels: ls, chunks: metas})
+	}
+	sort.Slice(allSeries, func(i, j int) bool {
+		return labels.Compare(allSeries[i].labels, allSeries[j].labels) < 0
+	})
+
+	writer, err := index.NewWriter(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	for _, symbol := range sortedKeys(symbols) {
+		if err := writer.AddSymbol(symbol); err != nil {
+			_ = writer.Close()
+			return err
+		}
+	}
+
+	for i, s := range allSeries {
+		if err := writer.AddSeries(uint64(i), s.labels, s.chunks...); err != nil {
+			_ = writer.Close()
+			return err
+		}
+	}
+
+	return writer.Close()
+}
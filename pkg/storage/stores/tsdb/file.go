@@ -0,0 +1,83 @@
+package tsdb
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/index"
+)
+
+// indexFile is a TSDB index file written by a Builder. The series are looked up from the postings of the matchers.
+type indexFile struct {
+	reader *index.Reader
+}
+
+func openIndexFile(path string) (*indexFile, error) {
+	reader, err := index.NewFileReader(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open index file %s", path)
+	}
+	return &indexFile{reader: reader}, nil
+}
+
+func (f *indexFile) forSeries(userID string, from, through model.Time, matchers []*labels.Matcher, fn func(ls labels.Labels, fp model.Fingerprint, chks []chunkMeta)) error {
+	tenantMatcher, err := labels.NewMatcher(labels.MatchEqual, TenantLabel, userID)
+	if err != nil {
+		return err
+	}
+
+	postings, err := tsdb.PostingsForMatchers(f.reader, append(withoutMetricName(matchers), tenantMatcher)...)
+	if err != nil {
+		return err
+	}
+
+	var (
+		ls    labels.Labels
+		metas []chunks.Meta
+		chks  []chunkMeta
+	)
+	for postings.Next() {
+		if err := f.reader.Series(postings.At(), &ls, &metas); err != nil {
+			return err
+		}
+
+		chks = chks[:0]
+		for _, meta := range metas {
+			chks = append(chks, chunkMeta{
+				Checksum: uint32(meta.Ref),
+				From:     model.Time(meta.MinTime),
+				Through:  model.Time(meta.MaxTime),
+			})
+		}
+		if !hasChunksBetween(chks, from, through) {
+			continue
+		}
+
+		var fp model.Fingerprint
+		filtered := ls[:0]
+		for _, l := range ls {
+			switch l.Name {
+			case TenantLabel:
+			case FingerprintLabel:
+				parsed, err := strconv.ParseUint(l.Value, 16, 64)
+				if err != nil {
+					return errors.Wrapf(err, "invalid fingerprint %q", l.Value)
+				}
+				fp = model.Fingerprint(parsed)
+			default:
+				filtered = append(filtered, l)
+			}
+		}
+
+		fn(filtered, fp, chks)
+	}
+	return postings.Err()
+}
+
+func (f *indexFile) Close() error {
+	return f.reader.Close()
+}
@@ -0,0 +1,142 @@
+package tsdb
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/require"
+)
+
+func buildTestIndex(t *testing.T, b *Builder, dir string) *indexFile {
+	path := filepath.Join(dir, "index"+indexExtension)
+	require.NoError(t, b.Build(context.Background(), path))
+
+	f, err := openIndexFile(path)
+	require.NoError(t, err)
+	return f
+}
+
+func testBuilder() *Builder {
+	b := NewBuilder()
+	b.AddChunk("user1", 1, labels.FromStrings("app", "foo", "env", "prod"), chunkMeta{Checksum: 10, From: 0, Through: 100})
+	b.AddChunk("user1", 1, labels.FromStrings("app", "foo", "env", "prod"), chunkMeta{Checksum: 11, From: 100, Through: 200})
+	// chunks added twice are only indexed once.
+	b.AddChunk("user1", 1, labels.FromStrings("app", "foo", "env", "prod"), chunkMeta{Checksum: 11, From: 100, Through: 200})
+	b.AddChunk("user1", 2, labels.FromStrings("app", "bar", "env", "dev"), chunkMeta{Checksum: 20, From: 50, Through: 150})
+	b.AddChunk("user2", 3, labels.FromStrings("app", "foo", "env", "prod"), chunkMeta{Checksum: 30, From: 0, Through: 500})
+	return b
+}
+
+func TestIndex(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "tsdb-index")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	b := testBuilder()
+	f := buildTestIndex(t, b, tempDir)
+	defer func() {
+		require.NoError(t, f.Close())
+	}()
+
+	for name, it := range map[string]seriesIterator{
+		"builder": b,
+		"file":    f,
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, tc := range []struct {
+				name          string
+				userID        string
+				from, through model.Time
+				matchers      []*labels.Matcher
+				expected      []uint32
+			}{
+				{
+					name:     "equal matcher",
+					userID:   "user1",
+					through:  1000,
+					matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "app", "foo")},
+					expected: []uint32{10, 11},
+				},
+				{
+					name:     "regex matcher",
+					userID:   "user1",
+					through:  1000,
+					matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchRegexp, "app", "foo|bar")},
+					expected: []uint32{10, 11, 20},
+				},
+				{
+					name:   "not equal matcher with the metric name",
+					userID: "user1",
+					matchers: []*labels.Matcher{
+						labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "logs"),
+						labels.MustNewMatcher(labels.MatchNotEqual, "env", "prod"),
+					},
+					through:  1000,
+					expected: []uint32{20},
+				},
+				{
+					name:     "time range",
+					userID:   "user1",
+					from:     160,
+					through:  1000,
+					matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchRegexp, "app", ".+")},
+					expected: []uint32{11},
+				},
+				{
+					name:     "other tenant",
+					userID:   "user2",
+					through:  1000,
+					matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "app", "foo")},
+					expected: []uint32{30},
+				},
+				{
+					name:     "unknown tenant",
+					userID:   "user3",
+					through:  1000,
+					matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "app", "foo")},
+				},
+			} {
+				t.Run(tc.name, func(t *testing.T) {
+					chks, err := getChunkRefs([]seriesIterator{it}, tc.userID, tc.from, tc.through, tc.matchers)
+					require.NoError(t, err)
+
+					var checksums []uint32
+					for _, chk := range chks {
+						require.Equal(t, tc.userID, chk.UserID)
+						require.True(t, chk.ChecksumSet)
+						checksums = append(checksums, chk.Checksum)
+
+						// the fingerprints are the ones of the ingesters and not the ones of the labels.
+						switch chk.Checksum {
+						case 10, 11:
+							require.Equal(t, model.Fingerprint(1), chk.Fingerprint)
+						case 20:
+							require.Equal(t, model.Fingerprint(2), chk.Fingerprint)
+						}
+					}
+					require.ElementsMatch(t, tc.expected, checksums)
+				})
+			}
+
+			names, err := labelNames([]seriesIterator{it}, "user1", 0, 1000)
+			require.NoError(t, err)
+			require.Equal(t, []string{"app", "env"}, names)
+
+			values, err := labelValues([]seriesIterator{it}, "user1", 0, 1000, "app")
+			require.NoError(t, err)
+			require.Equal(t, []string{"bar", "foo"}, values)
+
+			values, err = labelValues([]seriesIterator{it}, "user1", 160, 1000, "app")
+			require.NoError(t, err)
+			require.Equal(t, []string{"foo"}, values)
+		})
+	}
+}
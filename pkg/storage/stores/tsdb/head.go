@@ -0,0 +1,193 @@
+package tsdb
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+const (
+	walExtension   = ".wal"
+	indexExtension = ".tsdb"
+)
+
+var errHeadClosed = errors.New("head closed")
+
+type walRecord struct {
+	UserID      string            `json:"user_id"`
+	Fingerprint model.Fingerprint `json:"fingerprint"`
+	Labels      labels.Labels     `json:"labels"`
+	Chunk       chunkMeta         `json:"chunk"`
+}
+
+// head keeps the chunks added to a table by an ingester in memory, until the table isn't written anymore. Every chunk is
+// also appended to a WAL so that the head can be restored on restarts. The index file built from the head keeps being
+// uploaded to the shared store under the same name while it is written so that there is one index file per ingester
+// and table.
+type head struct {
+	name string
+	dir  string
+
+	mtx        sync.RWMutex
+	builder    *Builder
+	wal        *os.File
+	lastWrite  time.Time
+	lastUpload time.Time
+	closed     bool
+}
+
+// newHead opens the head with the given name in dir, restoring the chunks from its WAL if it exists.
+func newHead(dir, name string) (*head, error) {
+	h := &head{
+		name:    name,
+		dir:     dir,
+		builder: NewBuilder(),
+	}
+
+	walPath := filepath.Join(dir, name+walExtension)
+	if err := h.replay(walPath); err != nil {
+		return nil, errors.Wrapf(err, "failed to replay WAL %s", walPath)
+	}
+
+	var err error
+	h.wal, err = os.OpenFile(walPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *head) replay(walPath string) error {
+	f, err := os.Open(walPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record walRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// the last record could have been partially written before a crash.
+			continue
+		}
+		h.builder.AddChunk(record.UserID, record.Fingerprint, record.Labels, record.Chunk)
+	}
+
+	// the replayed chunks might not have been uploaded before the restart.
+	h.lastWrite = time.Now()
+	return scanner.Err()
+}
+
+func (h *head) append(userID string, fp model.Fingerprint, ls labels.Labels, chk chunkMeta) error {
+	record, err := json.Marshal(walRecord{
+		UserID:      userID,
+		Fingerprint: fp,
+		Labels:      ls,
+		Chunk:       chk,
+	})
+	if err != nil {
+		return err
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.closed {
+		return errHeadClosed
+	}
+	if _, err := h.wal.Write(append(record, '\n')); err != nil {
+		return err
+	}
+	if err := h.wal.Sync(); err != nil {
+		return err
+	}
+
+	h.builder.AddChunk(userID, fp, ls, chk)
+	h.lastWrite = time.Now()
+	return nil
+}
+
+func (h *head) forSeries(userID string, from, through model.Time, matchers []*labels.Matcher, fn func(ls labels.Labels, fp model.Fingerprint, chks []chunkMeta)) error {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+	return h.builder.forSeries(userID, from, through, matchers, fn)
+}
+
+// buildIndexFile writes the index file of the head if it was written since the last upload. It returns the path of the
+// file and the time to set as the upload time once the file is uploaded, or an empty path if there is nothing to upload.
+func (h *head) buildIndexFile(ctx context.Context) (string, time.Time, error) {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+	if !h.lastWrite.After(h.lastUpload) || h.builder.Empty() {
+		return "", time.Time{}, nil
+	}
+
+	buildStart := time.Now()
+	path := filepath.Join(h.dir, h.name+indexExtension)
+	if err := h.builder.Build(ctx, path); err != nil {
+		return "", time.Time{}, err
+	}
+	return path, buildStart, nil
+}
+
+func (h *head) setUploaded(t time.Time) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.lastUpload = t
+}
+
+// closeIfIdleSince closes the head if it was uploaded and not written anymore since the given time.
+// It returns whether the head was closed.
+func (h *head) closeIfIdleSince(t time.Time) (bool, error) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if !h.lastWrite.Before(t) || h.lastUpload.Before(h.lastWrite) {
+		return false, nil
+	}
+	h.closed = true
+	return true, h.wal.Close()
+}
+
+func (h *head) close() error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.closed = true
+	return h.wal.Close()
+}
+
+// removeFiles removes the files of a closed head.
+func (h *head) removeFiles() error {
+	for _, ext := range []string{walExtension, indexExtension} {
+		if err := os.RemoveAll(filepath.Join(h.dir, h.name+ext)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// headName returns the name of the head of the given WAL file or an empty string if it is not a WAL file.
+func headName(fileName string) string {
+	if !strings.HasSuffix(fileName, walExtension) {
+		return ""
+	}
+	return strings.TrimSuffix(fileName, walExtension)
+}
@@ -0,0 +1,137 @@
+package tsdb
+
+import (
+	"sort"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+const (
+	// TSDBType holds the index type for using Prometheus TSDB index files shipped to a shared storage.
+	TSDBType = "tsdb"
+
+	// StorageKeyPrefix is the prefix of the index files in the shared storage.
+	StorageKeyPrefix = "tsdb/"
+
+	// TenantLabel is the label added to the series of the index files to tell which tenant they belong to,
+	// the files of a table being shared by all the tenants.
+	TenantLabel = "__loki_tenant__"
+
+	// FingerprintLabel is the label keeping the fingerprint of the series as computed by the ingesters,
+	// since it is part of the ID of the chunks.
+	FingerprintLabel = "__loki_fp__"
+)
+
+// chunkMeta is what the index knows about a chunk, its ID is rebuilt from the series of the chunk.
+type chunkMeta struct {
+	Checksum uint32     `json:"checksum"`
+	From     model.Time `json:"from"`
+	Through  model.Time `json:"through"`
+}
+
+func (c chunkMeta) overlaps(from, through model.Time) bool {
+	return !c.Through.Before(from) && !c.From.After(through)
+}
+
+// seriesIterator is implemented by the index files and the heads of the ingesters.
+type seriesIterator interface {
+	// forSeries calls fn for each series of the user matching the matchers which has chunks between from and through.
+	// The labels passed to fn don't include the internal labels and are only valid during the call.
+	forSeries(userID string, from, through model.Time, matchers []*labels.Matcher, fn func(ls labels.Labels, fp model.Fingerprint, chks []chunkMeta)) error
+}
+
+// getChunkRefs returns the chunks of the series matching the matchers between from and through. The same chunk can be
+// returned more than once since it is indexed by every ingester flushing it.
+func getChunkRefs(iterators []seriesIterator, userID string, from, through model.Time, matchers []*labels.Matcher) ([]chunk.Chunk, error) {
+	var chks []chunk.Chunk
+	for _, it := range iterators {
+		err := it.forSeries(userID, from, through, matchers, func(_ labels.Labels, fp model.Fingerprint, metas []chunkMeta) {
+			for _, meta := range metas {
+				if !meta.overlaps(from, through) {
+					continue
+				}
+				chks = append(chks, chunk.Chunk{
+					UserID:      userID,
+					Fingerprint: fp,
+					From:        meta.From,
+					Through:     meta.Through,
+					Checksum:    meta.Checksum,
+					ChecksumSet: true,
+				})
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return chks, nil
+}
+
+// labelNames returns the sorted names of the labels of the series having chunks between from and through.
+func labelNames(iterators []seriesIterator, userID string, from, through model.Time) ([]string, error) {
+	names := map[string]struct{}{}
+	for _, it := range iterators {
+		err := it.forSeries(userID, from, through, nil, func(ls labels.Labels, _ model.Fingerprint, _ []chunkMeta) {
+			for _, l := range ls {
+				names[l.Name] = struct{}{}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sortedKeys(names), nil
+}
+
+// labelValues returns the sorted values of the label with the given name of the series having chunks between from and through.
+func labelValues(iterators []seriesIterator, userID string, from, through model.Time, name string) ([]string, error) {
+	matcher, err := labels.NewMatcher(labels.MatchNotEqual, name, "")
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]struct{}{}
+	for _, it := range iterators {
+		err := it.forSeries(userID, from, through, []*labels.Matcher{matcher}, func(ls labels.Labels, _ model.Fingerprint, _ []chunkMeta) {
+			values[ls.Get(name)] = struct{}{}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sortedKeys(values), nil
+}
+
+// hasChunksBetween tells whether any of the chunks is between from and through.
+func hasChunksBetween(chks []chunkMeta, from, through model.Time) bool {
+	for _, chk := range chks {
+		if chk.overlaps(from, through) {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutMetricName removes the matchers on the metric name, the series of the index don't have one since all the Loki
+// streams are named "logs".
+func withoutMetricName(matchers []*labels.Matcher) []*labels.Matcher {
+	filtered := make([]*labels.Matcher, 0, len(matchers))
+	for _, m := range matchers {
+		if m.Name == labels.MetricName {
+			continue
+		}
+		filtered = append(filtered, m)
+	}
+	return filtered
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,444 @@
+package tsdb
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	chunk_util "github.com/cortexproject/cortex/pkg/chunk/util"
+	pkg_util "github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/famarks/loki/pkg/storage/stores/shipper"
+	shipper_util "github.com/famarks/loki/pkg/storage/stores/shipper/util"
+	"github.com/famarks/loki/pkg/storage/stores/util"
+)
+
+const (
+	// UploadInterval defines how often the index files of the heads written since their last upload are built and uploaded.
+	UploadInterval = 1 * time.Minute
+
+	cacheCleanupInterval = time.Hour
+)
+
+// Config configures the building and the shipping of the TSDB index files. It uses the same modes as the boltdb-shipper.
+type Config struct {
+	ActiveIndexDirectory string        `yaml:"active_index_directory"`
+	SharedStoreType      string        `yaml:"shared_store"`
+	CacheLocation        string        `yaml:"cache_location"`
+	CacheTTL             time.Duration `yaml:"cache_ttl"`
+	ResyncInterval       time.Duration `yaml:"resync_interval"`
+	ChunkRefsCacheSize   int           `yaml:"chunk_refs_cache_size"`
+	IngesterName         string        `yaml:"-"`
+	Mode                 int           `yaml:"-"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.ActiveIndexDirectory, "tsdb.shipper.active-index-directory", "", "Directory where ingesters would write the WALs and TSDB index files which would then be uploaded by the shipper to configured storage")
+	f.StringVar(&cfg.SharedStoreType, "tsdb.shipper.shared-store", "", "Shared store for keeping TSDB index files. Supported types: gcs, s3, azure, filesystem")
+	f.StringVar(&cfg.CacheLocation, "tsdb.shipper.cache-location", "", "Cache location for restoring TSDB index files for queries")
+	f.DurationVar(&cfg.CacheTTL, "tsdb.shipper.cache-ttl", 24*time.Hour, "TTL for TSDB index files restored in cache for queries")
+	f.DurationVar(&cfg.ResyncInterval, "tsdb.shipper.resync-interval", 5*time.Minute, "Resync downloaded files with the storage")
+	f.IntVar(&cfg.ChunkRefsCacheSize, "tsdb.shipper.chunk-refs-cache-size", 0, "Number of lookups whose chunks are cached for each table of the downloaded index files, until the next resync downloading or removing files of the table. 0 to disable.")
+}
+
+// IndexShipper keeps the heads of the tables written by an ingester, uploading their index files to the shared store,
+// and downloads the index files of the tables queried.
+type IndexShipper struct {
+	cfg          Config
+	objectClient chunk.ObjectClient
+
+	heads    map[string]*head
+	headsMtx sync.RWMutex
+
+	tables    map[string]*table
+	tablesMtx sync.RWMutex
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// NewIndexShipper makes a new IndexShipper. The heads left in the active index directory are restored from their WAL.
+func NewIndexShipper(cfg Config, objectClient chunk.ObjectClient) (*IndexShipper, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &IndexShipper{
+		cfg:          cfg,
+		objectClient: util.NewPrefixedObjectClient(objectClient, StorageKeyPrefix),
+		heads:        map[string]*head{},
+		tables:       map[string]*table{},
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+
+	if cfg.Mode != shipper.ModeReadOnly {
+		if err := s.loadHeads(); err != nil {
+			cancel()
+			return nil, err
+		}
+		s.wg.Add(1)
+		go s.uploadLoop()
+		level.Info(pkg_util.Logger).Log("msg", fmt.Sprintf("loaded %d TSDB heads", len(s.heads)))
+	}
+
+	if cfg.Mode != shipper.ModeWriteOnly {
+		// cleanup the existing files in the cache since they could be outdated.
+		if err := os.RemoveAll(cfg.CacheLocation); err != nil {
+			cancel()
+			return nil, err
+		}
+		if err := chunk_util.EnsureDirectory(cfg.CacheLocation); err != nil {
+			cancel()
+			return nil, err
+		}
+		s.wg.Add(1)
+		go s.syncLoop()
+	}
+
+	return s, nil
+}
+
+func (s *IndexShipper) loadHeads() error {
+	if err := chunk_util.EnsureDirectory(s.cfg.ActiveIndexDirectory); err != nil {
+		return err
+	}
+
+	tableDirs, err := ioutil.ReadDir(s.cfg.ActiveIndexDirectory)
+	if err != nil {
+		return err
+	}
+
+	for _, tableDir := range tableDirs {
+		if !tableDir.IsDir() {
+			continue
+		}
+
+		dir := filepath.Join(s.cfg.ActiveIndexDirectory, tableDir.Name())
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			name := headName(file.Name())
+			if name == "" {
+				continue
+			}
+			if _, ok := s.heads[tableDir.Name()]; ok {
+				return fmt.Errorf("found more than one WAL for table %s", tableDir.Name())
+			}
+			h, err := newHead(dir, name)
+			if err != nil {
+				return err
+			}
+			s.heads[tableDir.Name()] = h
+		}
+	}
+	return nil
+}
+
+// Append adds the chunk of the series with labels ls and fingerprint fp to the head of the table.
+func (s *IndexShipper) Append(tableName, userID string, fp model.Fingerprint, ls labels.Labels, chk chunkMeta) error {
+	if s.cfg.Mode == shipper.ModeReadOnly {
+		return errors.New("can't write to a read-only TSDB index")
+	}
+
+	for {
+		h, err := s.getOrCreateHead(tableName)
+		if err != nil {
+			return err
+		}
+
+		// the head could have been dropped in the meantime, in which case a new one is created.
+		err = h.append(userID, fp, ls, chk)
+		if err != errHeadClosed {
+			return err
+		}
+	}
+}
+
+func (s *IndexShipper) getOrCreateHead(tableName string) (*head, error) {
+	s.headsMtx.RLock()
+	h, ok := s.heads[tableName]
+	s.headsMtx.RUnlock()
+	if ok {
+		return h, nil
+	}
+
+	s.headsMtx.Lock()
+	defer s.headsMtx.Unlock()
+
+	if h, ok := s.heads[tableName]; ok {
+		return h, nil
+	}
+
+	dir := filepath.Join(s.cfg.ActiveIndexDirectory, tableName)
+	if err := chunk_util.EnsureDirectory(dir); err != nil {
+		return nil, err
+	}
+
+	// every head gets its own name so that a head created after the previous one of the table has been
+	// dropped doesn't overwrite its index file in the store.
+	h, err := newHead(dir, fmt.Sprintf("%s-%d", s.cfg.IngesterName, time.Now().UnixNano()))
+	if err != nil {
+		return nil, err
+	}
+	s.heads[tableName] = h
+	return h, nil
+}
+
+// forTable calls fn with the head of the table and its downloaded index files, depending on the mode.
+func (s *IndexShipper) forTable(ctx context.Context, tableName string, fn func(iterators []seriesIterator) error) error {
+	var iterators []seriesIterator
+
+	if s.cfg.Mode != shipper.ModeReadOnly {
+		s.headsMtx.RLock()
+		h, ok := s.heads[tableName]
+		s.headsMtx.RUnlock()
+		if ok {
+			iterators = append(iterators, h)
+		}
+	}
+
+	if s.cfg.Mode == shipper.ModeWriteOnly {
+		return fn(iterators)
+	}
+
+	t, err := s.getOrCreateTable(ctx, tableName)
+	if err != nil {
+		return err
+	}
+	return t.forFiles(func(files []seriesIterator) error {
+		return fn(append(iterators, files...))
+	})
+}
+
+// getChunkRefs returns the chunks of the table of the series of the user matching the matchers between from and through,
+// from the head and the downloaded index files depending on the mode. Only the chunks of the downloaded files are cached
+// since the head is written continuously.
+func (s *IndexShipper) getChunkRefs(ctx context.Context, tableName, userID string, from, through model.Time, matchers []*labels.Matcher) ([]chunk.Chunk, error) {
+	var chks []chunk.Chunk
+
+	if s.cfg.Mode != shipper.ModeReadOnly {
+		s.headsMtx.RLock()
+		h, ok := s.heads[tableName]
+		s.headsMtx.RUnlock()
+		if ok {
+			var err error
+			chks, err = getChunkRefs([]seriesIterator{h}, userID, from, through, matchers)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if s.cfg.Mode == shipper.ModeWriteOnly {
+		return chks, nil
+	}
+
+	t, err := s.getOrCreateTable(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+	tableChks, err := t.getChunkRefs(userID, from, through, matchers)
+	if err != nil {
+		return nil, err
+	}
+	return append(chks, tableChks...), nil
+}
+
+func (s *IndexShipper) getOrCreateTable(ctx context.Context, tableName string) (*table, error) {
+	s.tablesMtx.RLock()
+	t, ok := s.tables[tableName]
+	s.tablesMtx.RUnlock()
+	if ok {
+		return t, nil
+	}
+
+	s.tablesMtx.Lock()
+	defer s.tablesMtx.Unlock()
+
+	if t, ok := s.tables[tableName]; ok {
+		return t, nil
+	}
+
+	t, err := newTable(tableName, s.cfg.CacheLocation, s.objectClient, s.cfg.ChunkRefsCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.sync(ctx); err != nil {
+		t.close()
+		return nil, errors.Wrapf(err, "failed to download the index files of table %s", tableName)
+	}
+	s.tables[tableName] = t
+	return t, nil
+}
+
+func (s *IndexShipper) uploadLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(UploadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.uploadHeads(s.ctx)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// uploadHeads uploads the index files of the heads written since their last upload and drops the ones which haven't been
+// written for long enough for the queriers to have downloaded their index file.
+func (s *IndexShipper) uploadHeads(ctx context.Context) {
+	s.headsMtx.RLock()
+	heads := make(map[string]*head, len(s.heads))
+	for tableName, h := range s.heads {
+		heads[tableName] = h
+	}
+	s.headsMtx.RUnlock()
+
+	retainSince := time.Now().Add(-(s.cfg.ResyncInterval + 2*UploadInterval))
+	for tableName, h := range heads {
+		if err := s.uploadHead(ctx, tableName, h); err != nil {
+			level.Error(pkg_util.Logger).Log("msg", "failed to upload TSDB index file", "table", tableName, "err", err)
+			continue
+		}
+
+		s.headsMtx.Lock()
+		closed, err := h.closeIfIdleSince(retainSince)
+		if closed {
+			delete(s.heads, tableName)
+		}
+		s.headsMtx.Unlock()
+
+		if err != nil {
+			level.Error(pkg_util.Logger).Log("msg", "failed to close TSDB head", "table", tableName, "err", err)
+		}
+		if !closed {
+			continue
+		}
+		if err := h.removeFiles(); err != nil {
+			level.Error(pkg_util.Logger).Log("msg", "failed to remove TSDB head", "table", tableName, "err", err)
+		}
+	}
+}
+
+func (s *IndexShipper) uploadHead(ctx context.Context, tableName string, h *head) error {
+	path, builtAt, err := h.buildIndexFile(ctx)
+	if err != nil || path == "" {
+		return err
+	}
+
+	compressedPath := path + ".gz"
+	if err := shipper_util.CompressFile(path, compressedPath); err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.Remove(compressedPath); err != nil {
+			level.Error(pkg_util.Logger).Log("msg", "failed to remove compressed TSDB index file", "path", compressedPath, "err", err)
+		}
+	}()
+
+	f, err := os.Open(compressedPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	objectKey := tableName + delimiter + filepath.Base(compressedPath)
+	if err := s.objectClient.PutObject(ctx, objectKey, f); err != nil {
+		return err
+	}
+
+	h.setUploaded(builtAt)
+	return nil
+}
+
+func (s *IndexShipper) syncLoop() {
+	defer s.wg.Done()
+
+	syncTicker := time.NewTicker(s.cfg.ResyncInterval)
+	defer syncTicker.Stop()
+
+	cacheCleanupTicker := time.NewTicker(cacheCleanupInterval)
+	defer cacheCleanupTicker.Stop()
+
+	for {
+		select {
+		case <-syncTicker.C:
+			s.syncTables(s.ctx)
+		case <-cacheCleanupTicker.C:
+			s.cleanupCache()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *IndexShipper) syncTables(ctx context.Context) {
+	s.tablesMtx.RLock()
+	defer s.tablesMtx.RUnlock()
+
+	for tableName, t := range s.tables {
+		if err := t.sync(ctx); err != nil {
+			level.Error(pkg_util.Logger).Log("msg", "failed to sync TSDB index files", "table", tableName, "err", err)
+		}
+	}
+}
+
+// cleanupCache removes the tables which haven't been queried for the cache TTL.
+func (s *IndexShipper) cleanupCache() {
+	s.tablesMtx.Lock()
+	defer s.tablesMtx.Unlock()
+
+	expiredBefore := time.Now().Add(-s.cfg.CacheTTL)
+	for tableName, t := range s.tables {
+		if t.idleSince(expiredBefore) {
+			t.close()
+			delete(s.tables, tableName)
+		}
+	}
+}
+
+// Stop uploads the heads one last time and closes them along with the downloaded files. It can be called by each of
+// the stores sharing the IndexShipper.
+func (s *IndexShipper) Stop() {
+	s.stopOnce.Do(s.stop)
+}
+
+func (s *IndexShipper) stop() {
+	s.cancel()
+	s.wg.Wait()
+
+	s.headsMtx.Lock()
+	for tableName, h := range s.heads {
+		if err := s.uploadHead(context.Background(), tableName, h); err != nil {
+			level.Error(pkg_util.Logger).Log("msg", "failed to upload TSDB index file", "table", tableName, "err", err)
+		}
+		if err := h.close(); err != nil {
+			level.Error(pkg_util.Logger).Log("msg", "failed to close TSDB head", "table", tableName, "err", err)
+		}
+	}
+	s.headsMtx.Unlock()
+
+	s.tablesMtx.Lock()
+	for _, t := range s.tables {
+		t.close()
+	}
+	s.tablesMtx.Unlock()
+
+	s.objectClient.Stop()
+}
@@ -0,0 +1,256 @@
+package tsdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/chunk/cache"
+	"github.com/cortexproject/cortex/pkg/querier/astmapper"
+	"github.com/cortexproject/cortex/pkg/util/spanlogger"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+var errDeleteNotSupported = errors.New("deleting chunks is not supported by the tsdb index")
+
+// Store is the chunk store of a period config using the TSDB index. The chunks are written to the chunk client of the
+// period and indexed in the tables of the period through the IndexShipper, which can be shared by several stores.
+type Store struct {
+	period  chunk.PeriodConfig
+	shipper *IndexShipper
+	chunks  chunk.Client
+	fetcher *chunk.Fetcher
+	limits  chunk.StoreLimits
+}
+
+// NewStore makes a new Store for the period.
+func NewStore(period chunk.PeriodConfig, indexShipper *IndexShipper, chunks chunk.Client, chunksCache cache.Cache, limits chunk.StoreLimits) (*Store, error) {
+	fetcher, err := chunk.NewChunkFetcher(chunksCache, false, chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		period:  period,
+		shipper: indexShipper,
+		chunks:  chunks,
+		fetcher: fetcher,
+		limits:  limits,
+	}, nil
+}
+
+// tablesFor returns the names of the index tables of the period between from and through.
+func (s *Store) tablesFor(from, through model.Time) []string {
+	cfg := s.period.IndexTables
+	if cfg.Period == 0 {
+		return []string{cfg.Prefix}
+	}
+
+	periodSecs := int64(cfg.Period / time.Second)
+	var tables []string
+	for i := from.Unix() / periodSecs; i <= through.Unix()/periodSecs; i++ {
+		tables = append(tables, cfg.Prefix+strconv.Itoa(int(i)))
+	}
+	return tables
+}
+
+// Put implements chunk.Store.
+func (s *Store) Put(ctx context.Context, chunks []chunk.Chunk) error {
+	for _, chk := range chunks {
+		if err := s.PutOne(ctx, chk.From, chk.Through, chk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PutOne implements chunk.Store. The chunk is indexed in every table between from and through.
+func (s *Store) PutOne(ctx context.Context, from, through model.Time, chk chunk.Chunk) error {
+	if err := s.chunks.PutChunks(ctx, []chunk.Chunk{chk}); err != nil {
+		return err
+	}
+
+	ls := labels.NewBuilder(chk.Metric).Del(labels.MetricName).Labels()
+	meta := chunkMeta{Checksum: chk.Checksum, From: chk.From, Through: chk.Through}
+	for _, tableName := range s.tablesFor(from, through) {
+		if err := s.shipper.Append(tableName, chk.UserID, chk.Fingerprint, ls, meta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get implements chunk.Store.
+func (s *Store) Get(ctx context.Context, userID string, from, through model.Time, matchers ...*labels.Matcher) ([]chunk.Chunk, error) {
+	chks, fetchers, err := s.GetChunkRefs(ctx, userID, from, through, matchers...)
+	if err != nil || len(chks) == 0 {
+		return nil, err
+	}
+
+	maxChunksPerQuery := s.limits.MaxChunksPerQuery(userID)
+	if maxChunksPerQuery > 0 && len(chks[0]) > maxChunksPerQuery {
+		return nil, chunk.QueryError(fmt.Sprintf("Query %v fetched too many chunks (%d > %d)", matchers, len(chks[0]), maxChunksPerQuery))
+	}
+
+	keys := make([]string, 0, len(chks[0]))
+	for _, chk := range chks[0] {
+		keys = append(keys, chk.ExternalKey())
+	}
+	fetched, err := fetchers[0].FetchChunks(ctx, chks[0], keys)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]chunk.Chunk, 0, len(fetched))
+outer:
+	for _, chk := range fetched {
+		for _, m := range matchers {
+			if m.Name == astmapper.ShardLabel {
+				continue
+			}
+			if !m.Matches(chk.Metric.Get(m.Name)) {
+				continue outer
+			}
+		}
+		filtered = append(filtered, chk)
+	}
+	return filtered, nil
+}
+
+// GetChunkRefs implements chunk.Store. The chunks are not loaded and don't have their labels set.
+func (s *Store) GetChunkRefs(ctx context.Context, userID string, from, through model.Time, matchers ...*labels.Matcher) ([][]chunk.Chunk, []*chunk.Fetcher, error) {
+	log, ctx := spanlogger.New(ctx, "TSDBStore.GetChunkRefs")
+	defer log.Span.Finish()
+
+	if shortcut, err := s.validateQueryTimeRange(userID, from, through); err != nil || shortcut {
+		return nil, nil, err
+	}
+
+	shard, shardIdx, err := astmapper.ShardFromMatchers(matchers)
+	if err != nil {
+		return nil, nil, err
+	}
+	if shard != nil {
+		matchers = append(append([]*labels.Matcher{}, matchers[:shardIdx]...), matchers[shardIdx+1:]...)
+	}
+
+	seen := map[string]struct{}{}
+	var chks []chunk.Chunk
+	for _, tableName := range s.tablesFor(from, through) {
+		tableChks, err := s.shipper.getChunkRefs(ctx, tableName, userID, from, through, matchers)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, chk := range tableChks {
+			if shard != nil && uint64(chk.Fingerprint)%uint64(shard.Of) != uint64(shard.Shard) {
+				continue
+			}
+			key := chk.ExternalKey()
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			chks = append(chks, chk)
+		}
+	}
+	level.Debug(log).Log("tables", len(s.tablesFor(from, through)), "chunks", len(chks))
+
+	if len(chks) == 0 {
+		return nil, nil, nil
+	}
+	return [][]chunk.Chunk{chks}, []*chunk.Fetcher{s.fetcher}, nil
+}
+
+// LabelValuesForMetricName implements chunk.Store, the index doesn't keep the metric name of the series.
+func (s *Store) LabelValuesForMetricName(ctx context.Context, userID string, from, through model.Time, _ string, labelName string) ([]string, error) {
+	if shortcut, err := s.validateQueryTimeRange(userID, from, through); err != nil || shortcut {
+		return nil, err
+	}
+
+	var result chunk.UniqueStrings
+	for _, tableName := range s.tablesFor(from, through) {
+		err := s.shipper.forTable(ctx, tableName, func(iterators []seriesIterator) error {
+			values, err := labelValues(iterators, userID, from, through, labelName)
+			if err != nil {
+				return err
+			}
+			result.Add(values...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result.Strings(), nil
+}
+
+// LabelNamesForMetricName implements chunk.Store, the index doesn't keep the metric name of the series.
+func (s *Store) LabelNamesForMetricName(ctx context.Context, userID string, from, through model.Time, _ string) ([]string, error) {
+	if shortcut, err := s.validateQueryTimeRange(userID, from, through); err != nil || shortcut {
+		return nil, err
+	}
+
+	var result chunk.UniqueStrings
+	for _, tableName := range s.tablesFor(from, through) {
+		err := s.shipper.forTable(ctx, tableName, func(iterators []seriesIterator) error {
+			names, err := labelNames(iterators, userID, from, through)
+			if err != nil {
+				return err
+			}
+			result.Add(names...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the series store of the other index types returns the metric name along with the label names.
+	if len(result.Strings()) > 0 {
+		result.Add(labels.MetricName)
+	}
+	return result.Strings(), nil
+}
+
+func (s *Store) validateQueryTimeRange(userID string, from, through model.Time) (bool, error) {
+	if through < from {
+		return false, chunk.QueryError(fmt.Sprintf("invalid query, through < from (%s < %s)", through, from))
+	}
+
+	maxQueryLength := s.limits.MaxQueryLength(userID)
+	if maxQueryLength > 0 && through.Sub(from) > maxQueryLength {
+		return false, chunk.QueryError(fmt.Sprintf(validation.ErrQueryTooLong, through.Sub(from), maxQueryLength))
+	}
+
+	// the whole time range is in the future.
+	return from.After(model.Now()), nil
+}
+
+// GetChunkFetcher implements chunk.Store.
+func (s *Store) GetChunkFetcher(_ model.Time) *chunk.Fetcher {
+	return s.fetcher
+}
+
+// DeleteChunk implements chunk.Store.
+func (s *Store) DeleteChunk(_ context.Context, _, _ model.Time, _, _ string, _ labels.Labels, _ *model.Interval) error {
+	return errDeleteNotSupported
+}
+
+// DeleteSeriesIDs implements chunk.Store.
+func (s *Store) DeleteSeriesIDs(_ context.Context, _, _ model.Time, _ string, _ labels.Labels) error {
+	return errDeleteNotSupported
+}
+
+// Stop implements chunk.Store.
+func (s *Store) Stop() {
+	s.chunks.Stop()
+	s.fetcher.Stop()
+	s.shipper.Stop()
+}
@@ -0,0 +1,213 @@
+package tsdb
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/chunk/cache"
+	"github.com/cortexproject/cortex/pkg/chunk/local"
+	"github.com/cortexproject/cortex/pkg/chunk/objectclient"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/chunkenc"
+	"github.com/famarks/loki/pkg/logproto"
+	"github.com/famarks/loki/pkg/storage/stores/shipper"
+	loki_util "github.com/famarks/loki/pkg/util"
+)
+
+type fakeLimits struct{}
+
+func (fakeLimits) MaxChunksPerQuery(_ string) int           { return 0 }
+func (fakeLimits) MaxQueryLength(_ string) time.Duration    { return 0 }
+func (fakeLimits) CardinalityLimit(_ string) int            { return 0 }
+func (fakeLimits) MaxQueryParallelism(_ string) int         { return 0 }
+func (fakeLimits) IngestionRateBytes(_ string) float64      { return 0 }
+func (fakeLimits) MaxCacheFreshness(_ string) time.Duration { return 0 }
+
+func newTestChunk(t *testing.T, userID, stream string, start model.Time) chunk.Chunk {
+	lbls, err := loki_util.ParseLabels(stream)
+	require.NoError(t, err)
+	lbls = append(lbls, labels.Label{Name: labels.MetricName, Value: "logs"})
+	sort.Sort(lbls)
+
+	mc := chunkenc.NewMemChunk(chunkenc.EncSnappy, 256*1024, 0)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, mc.Append(&logproto.Entry{Timestamp: start.Add(time.Duration(i) * time.Minute).Time(), Line: "line"}))
+	}
+	require.NoError(t, mc.Close())
+
+	from, through := loki_util.RoundToMilliseconds(mc.Bounds())
+	c := chunk.NewChunk(userID, model.Fingerprint(lbls.Hash()), lbls, chunkenc.NewFacade(mc, 256*1024, 0), from, through)
+	require.NoError(t, c.Encode())
+	return c
+}
+
+func newTestStore(t *testing.T, cfg Config, objectClient chunk.ObjectClient) *Store {
+	indexShipper, err := NewIndexShipper(cfg, objectClient)
+	require.NoError(t, err)
+
+	periodCfg := chunk.PeriodConfig{
+		IndexType:   TSDBType,
+		IndexTables: chunk.PeriodicTableConfig{Prefix: "index_", Period: 24 * time.Hour},
+	}
+	store, err := NewStore(periodCfg, indexShipper, objectclient.NewClient(objectClient, nil), cache.NewNoopCache(), fakeLimits{})
+	require.NoError(t, err)
+	return store
+}
+
+func TestStore(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "tsdb-store")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(tempDir, "objects")})
+	require.NoError(t, err)
+
+	ingesterCfg := Config{
+		ActiveIndexDirectory: filepath.Join(tempDir, "active"),
+		IngesterName:         "ingester-1",
+		Mode:                 shipper.ModeWriteOnly,
+	}
+	querierCfg := Config{
+		CacheLocation:  filepath.Join(tempDir, "cache"),
+		CacheTTL:       time.Hour,
+		ResyncInterval: time.Hour,
+		Mode:           shipper.ModeReadOnly,
+	}
+
+	now := model.Now()
+	ctx := context.Background()
+	fooMatcher := labels.MustNewMatcher(labels.MatchEqual, "app", "foo")
+	nameMatcher := labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "logs")
+
+	chunks := []chunk.Chunk{
+		newTestChunk(t, "fake", `{app="foo"}`, now.Add(-2*time.Hour)),
+		newTestChunk(t, "fake", `{app="foo"}`, now.Add(-time.Hour)),
+		newTestChunk(t, "fake", `{app="bar"}`, now.Add(-time.Hour)),
+		newTestChunk(t, "other", `{app="foo"}`, now.Add(-time.Hour)),
+	}
+
+	// the chunks can be queried from the head of the ingester right after being written.
+	ingesterStore := newTestStore(t, ingesterCfg, objectClient)
+	require.NoError(t, ingesterStore.Put(ctx, chunks))
+
+	refs, _, err := ingesterStore.GetChunkRefs(ctx, "fake", now.Add(-3*time.Hour), now, nameMatcher, fooMatcher)
+	require.NoError(t, err)
+	require.Len(t, refs, 1)
+	require.Len(t, refs[0], 2)
+
+	// the head is restored from its WAL on restarts, and uploaded when stopping.
+	ingesterStore.Stop()
+	ingesterStore = newTestStore(t, ingesterCfg, objectClient)
+	refs, _, err = ingesterStore.GetChunkRefs(ctx, "fake", now.Add(-3*time.Hour), now, nameMatcher, fooMatcher)
+	require.NoError(t, err)
+	require.Len(t, refs[0], 2)
+	ingesterStore.Stop()
+
+	querierStore := newTestStore(t, querierCfg, objectClient)
+	defer querierStore.Stop()
+
+	refs, _, err = querierStore.GetChunkRefs(ctx, "fake", now.Add(-3*time.Hour), now, nameMatcher, fooMatcher)
+	require.NoError(t, err)
+	require.Len(t, refs, 1)
+	var keys []string
+	for _, c := range refs[0] {
+		keys = append(keys, c.ExternalKey())
+	}
+	require.ElementsMatch(t, []string{chunks[0].ExternalKey(), chunks[1].ExternalKey()}, keys)
+
+	fetched, err := querierStore.Get(ctx, "fake", now.Add(-90*time.Minute), now, nameMatcher, fooMatcher)
+	require.NoError(t, err)
+	require.Len(t, fetched, 1)
+	require.Equal(t, chunks[1].ExternalKey(), fetched[0].ExternalKey())
+	require.Equal(t, `{__name__="logs", app="foo"}`, fetched[0].Metric.String())
+
+	names, err := querierStore.LabelNamesForMetricName(ctx, "fake", now.Add(-3*time.Hour), now, "logs")
+	require.NoError(t, err)
+	require.Equal(t, []string{labels.MetricName, "app"}, names)
+
+	values, err := querierStore.LabelValuesForMetricName(ctx, "fake", now.Add(-3*time.Hour), now, "logs", "app")
+	require.NoError(t, err)
+	require.Equal(t, []string{"bar", "foo"}, values)
+
+	values, err = querierStore.LabelValuesForMetricName(ctx, "other", now.Add(-3*time.Hour), now, "logs", "app")
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo"}, values)
+
+	require.Error(t, querierStore.Put(ctx, chunks[:1]))
+}
+
+func TestStore_CachesChunkRefs(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "tsdb-store-cache")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: filepath.Join(tempDir, "objects")})
+	require.NoError(t, err)
+
+	now := model.Now()
+	ctx := context.Background()
+	nameMatcher := labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "logs")
+	fooMatcher := labels.MustNewMatcher(labels.MatchEqual, "app", "foo")
+	chunks := []chunk.Chunk{
+		newTestChunk(t, "fake", `{app="foo"}`, now.Add(-2*time.Hour)),
+		newTestChunk(t, "fake", `{app="foo"}`, now.Add(-time.Hour)),
+	}
+
+	write := func(ingester string, chk chunk.Chunk) {
+		store := newTestStore(t, Config{
+			ActiveIndexDirectory: filepath.Join(tempDir, ingester),
+			IngesterName:         ingester,
+			Mode:                 shipper.ModeWriteOnly,
+		}, objectClient)
+		require.NoError(t, store.Put(ctx, []chunk.Chunk{chk}))
+		store.Stop()
+	}
+	write("ingester-1", chunks[0])
+
+	querierStore := newTestStore(t, Config{
+		CacheLocation:      filepath.Join(tempDir, "cache"),
+		CacheTTL:           time.Hour,
+		ResyncInterval:     time.Hour,
+		ChunkRefsCacheSize: 10,
+		Mode:               shipper.ModeReadOnly,
+	}, objectClient)
+	defer querierStore.Stop()
+
+	countRefs := func(from model.Time, matchers ...*labels.Matcher) int {
+		refs, _, err := querierStore.GetChunkRefs(ctx, "fake", from, now, matchers...)
+		require.NoError(t, err)
+		if len(refs) == 0 {
+			return 0
+		}
+		return len(refs[0])
+	}
+
+	hits := testutil.ToFloat64(chunkRefsCacheRequestsTotal.WithLabelValues("hit"))
+	require.Equal(t, 1, countRefs(now.Add(-3*time.Hour), nameMatcher, fooMatcher))
+	// the lookups of the same selectors are cached regardless of their time range and of the order of their matchers.
+	require.Equal(t, 0, countRefs(now.Add(-30*time.Minute), fooMatcher, nameMatcher))
+	require.Equal(t, hits+1, testutil.ToFloat64(chunkRefsCacheRequestsTotal.WithLabelValues("hit")))
+
+	// the lookups are invalidated by the syncs downloading new files.
+	write("ingester-2", chunks[1])
+	require.Equal(t, 1, countRefs(now.Add(-3*time.Hour), nameMatcher, fooMatcher))
+	querierStore.shipper.syncTables(ctx)
+	require.Equal(t, 2, countRefs(now.Add(-3*time.Hour), nameMatcher, fooMatcher))
+	require.Equal(t, 1, countRefs(now.Add(-90*time.Minute), nameMatcher, fooMatcher))
+}
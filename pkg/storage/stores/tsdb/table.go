@@ -0,0 +1,271 @@
+package tsdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	chunk_util "github.com/cortexproject/cortex/pkg/chunk/util"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	shipper_util "github.com/famarks/loki/pkg/storage/stores/shipper/util"
+)
+
+const delimiter = "/"
+
+var chunkRefsCacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "loki",
+	Name:      "tsdb_chunk_refs_cache_requests_total",
+	Help:      "Count of the lookups of the chunks of the downloaded index files by result, hit or miss.",
+}, []string{"result"})
+
+type downloadedFile struct {
+	mtime time.Time
+	index *indexFile
+}
+
+// table is the set of index files uploaded for a table by the ingesters, downloaded to a local cache for queries.
+type table struct {
+	name         string
+	dir          string
+	objectClient chunk.ObjectClient
+
+	syncMtx    sync.Mutex
+	mtx        sync.RWMutex
+	files      map[string]*downloadedFile
+	lastUsedAt time.Time
+
+	// chunks of the files of the table by lookup, dropped whenever the files change.
+	chunkRefs     map[string][]chunk.Chunk
+	chunkRefsSize int
+	generation    uint64
+}
+
+func newTable(name, cacheLocation string, objectClient chunk.ObjectClient, chunkRefsCacheSize int) (*table, error) {
+	dir := filepath.Join(cacheLocation, name)
+	if err := chunk_util.EnsureDirectory(dir); err != nil {
+		return nil, err
+	}
+
+	return &table{
+		name:          name,
+		dir:           dir,
+		objectClient:  objectClient,
+		files:         map[string]*downloadedFile{},
+		lastUsedAt:    time.Now(),
+		chunkRefs:     map[string][]chunk.Chunk{},
+		chunkRefsSize: chunkRefsCacheSize,
+	}, nil
+}
+
+// sync downloads the files uploaded or updated since the last sync and removes the ones which are not in the store anymore.
+func (t *table) sync(ctx context.Context) error {
+	t.syncMtx.Lock()
+	defer t.syncMtx.Unlock()
+
+	objects, _, err := t.objectClient.List(ctx, t.name+delimiter, delimiter)
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[string]struct{}, len(objects))
+	for _, object := range objects {
+		fileName := strings.TrimPrefix(object.Key, t.name+delimiter)
+		if !strings.HasSuffix(fileName, indexExtension+".gz") {
+			continue
+		}
+		fileName = strings.TrimSuffix(fileName, ".gz")
+		listed[fileName] = struct{}{}
+
+		t.mtx.RLock()
+		df, ok := t.files[fileName]
+		t.mtx.RUnlock()
+		if ok && df.mtime.Equal(object.ModifiedAt) {
+			continue
+		}
+
+		if err := t.downloadFile(ctx, fileName, object); err != nil {
+			return err
+		}
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for fileName, df := range t.files {
+		if _, ok := listed[fileName]; ok {
+			continue
+		}
+		if err := df.index.Close(); err != nil {
+			return err
+		}
+		delete(t.files, fileName)
+		t.resetChunkRefs()
+		if err := os.Remove(filepath.Join(t.dir, fileName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downloadFile downloads the file to a temporary location first so that the file it replaces can still be queried meanwhile.
+func (t *table) downloadFile(ctx context.Context, fileName string, object chunk.StorageObject) error {
+	filePath := filepath.Join(t.dir, fileName)
+	tempFilePath := fmt.Sprintf("%s.%s", filePath, "temp")
+
+	if err := shipper_util.GetFileFromStorage(ctx, t.objectClient, object.Key, tempFilePath); err != nil {
+		return err
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if df, ok := t.files[fileName]; ok {
+		if err := df.index.Close(); err != nil {
+			return err
+		}
+		delete(t.files, fileName)
+	}
+
+	if err := os.Rename(tempFilePath, filePath); err != nil {
+		return err
+	}
+
+	index, err := openIndexFile(filePath)
+	if err != nil {
+		return err
+	}
+	t.files[fileName] = &downloadedFile{mtime: object.ModifiedAt, index: index}
+	t.resetChunkRefs()
+	return nil
+}
+
+// forFiles calls fn with the index files of the table, which can't be replaced until fn returns.
+func (t *table) forFiles(fn func(files []seriesIterator) error) error {
+	t.mtx.Lock()
+	t.lastUsedAt = time.Now()
+	t.mtx.Unlock()
+
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	files := make([]seriesIterator, 0, len(t.files))
+	for _, df := range t.files {
+		files = append(files, df.index)
+	}
+	return fn(files)
+}
+
+// getChunkRefs returns the chunks of the files of the table of the series of the user matching the matchers between
+// from and through. The chunks of the whole table are cached by lookup until the next sync changing the files, so that
+// the lookups of the same selectors over moving time ranges, like the ones of the dashboards, are served from memory.
+func (t *table) getChunkRefs(userID string, from, through model.Time, matchers []*labels.Matcher) ([]chunk.Chunk, error) {
+	if t.chunkRefsSize <= 0 {
+		var chks []chunk.Chunk
+		err := t.forFiles(func(files []seriesIterator) error {
+			var err error
+			chks, err = getChunkRefs(files, userID, from, through, matchers)
+			return err
+		})
+		return chks, err
+	}
+
+	key := chunkRefsKey(userID, matchers)
+	var (
+		chks       []chunk.Chunk
+		ok         bool
+		generation uint64
+	)
+	err := t.forFiles(func(files []seriesIterator) error {
+		if chks, ok = t.chunkRefs[key]; ok {
+			return nil
+		}
+		generation = t.generation
+
+		var err error
+		chks, err = getChunkRefs(files, userID, model.Earliest, model.Latest, matchers)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		chunkRefsCacheRequestsTotal.WithLabelValues("hit").Inc()
+	} else {
+		chunkRefsCacheRequestsTotal.WithLabelValues("miss").Inc()
+
+		t.mtx.Lock()
+		// the chunks of files replaced in the meantime are not cached.
+		if t.generation == generation {
+			if len(t.chunkRefs) >= t.chunkRefsSize {
+				// drop any lookup, the tables are usually queried with a few selectors only.
+				for k := range t.chunkRefs {
+					delete(t.chunkRefs, k)
+					break
+				}
+			}
+			t.chunkRefs[key] = chks
+		}
+		t.mtx.Unlock()
+	}
+
+	result := make([]chunk.Chunk, 0, len(chks))
+	for _, chk := range chks {
+		if !chk.Through.Before(from) && !chk.From.After(through) {
+			result = append(result, chk)
+		}
+	}
+	return result, nil
+}
+
+// resetChunkRefs drops the cached chunks once the files changed, it must be called with the lock held.
+func (t *table) resetChunkRefs() {
+	t.chunkRefs = map[string][]chunk.Chunk{}
+	t.generation++
+}
+
+func chunkRefsKey(userID string, matchers []*labels.Matcher) string {
+	parts := make([]string, 0, len(matchers))
+	for _, m := range matchers {
+		parts = append(parts, m.String())
+	}
+	sort.Strings(parts)
+	return userID + "\x00" + strings.Join(parts, ",")
+}
+
+func (t *table) idleSince(ts time.Time) bool {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	return t.lastUsedAt.Before(ts)
+}
+
+// close closes the files of the table and removes them from the cache.
+func (t *table) close() {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for fileName, df := range t.files {
+		if err := df.index.Close(); err != nil {
+			level.Error(util.Logger).Log("msg", "failed to close index file", "table", t.name, "file", fileName, "err", err)
+		}
+	}
+	t.files = map[string]*downloadedFile{}
+	t.resetChunkRefs()
+
+	if err := os.RemoveAll(t.dir); err != nil {
+		level.Error(util.Logger).Log("msg", "failed to remove table from the cache", "table", t.name, "err", err)
+	}
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	"github.com/cortexproject/cortex/pkg/chunk/objectclient"
+
+	"github.com/famarks/loki/pkg/storage/stores/util"
+)
+
+// tenantPlaceholder is replaced by the tenant in the templates of the tenant objects config.
+const tenantPlaceholder = "{tenant}"
+
+var (
+	errTenantObjectsPlaceholder = errors.New("the prefix or the bucket of the tenant objects must contain " + tenantPlaceholder)
+	errTenantObjectsColdTier    = errors.New("the tenant objects can't be used along with the cold tier")
+	errTenantObjectsFSEviction  = errors.New("the tenant objects can't be used along with the filesystem eviction")
+)
+
+// TenantObjectsConfig maps the chunks of each tenant to a prefix or a bucket of their own, so that per tenant policies
+// and lifecycle rules can be set on them. The index files hold the entries of all the tenants, they stay in the shared
+// store.
+type TenantObjectsConfig struct {
+	Prefix string `yaml:"prefix"`
+	Bucket string `yaml:"bucket"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *TenantObjectsConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.Prefix, "store.tenant-objects.prefix", "", "Prefix of the chunks of each tenant in the object stores, where "+tenantPlaceholder+" is replaced by the tenant, e.g. "+tenantPlaceholder+"/chunks/.")
+	f.StringVar(&cfg.Bucket, "store.tenant-objects.bucket", "", "Bucket, container or directory of the chunks of each tenant in the object stores, where "+tenantPlaceholder+" is replaced by the tenant, e.g. loki-"+tenantPlaceholder+". The buckets must exist.")
+}
+
+// Enabled tells whether the chunks of each tenant are stored apart.
+func (cfg *TenantObjectsConfig) Enabled() bool {
+	return cfg.Prefix != "" || cfg.Bucket != ""
+}
+
+// Validate validates the config.
+func (cfg *TenantObjectsConfig) Validate() error {
+	if cfg.Enabled() && !strings.Contains(cfg.Prefix, tenantPlaceholder) && !strings.Contains(cfg.Bucket, tenantPlaceholder) {
+		return errTenantObjectsPlaceholder
+	}
+	return nil
+}
+
+// usesTenantObjects tells whether the chunks of the store type are stored apart for each tenant.
+func usesTenantObjects(name string, cfg Config) bool {
+	return cfg.TenantObjects.Enabled() && isObjectStore(name)
+}
+
+// newTenantObjectsChunkClient makes the chunk client of the object store type storing the chunks of each tenant in
+// their prefix or bucket. Their keys are left as is, within the prefix.
+func newTenantObjectsChunkClient(name string, cfg Config) chunk.Client {
+	return newTenantChunkClient(func(tenant string) (chunk.Client, error) {
+		if err := validateTenantObjectsTenant(tenant, cfg.TenantObjects.Bucket != ""); err != nil {
+			return nil, err
+		}
+
+		tenantCfg := cfg
+		if cfg.TenantObjects.Bucket != "" {
+			tenantCfg = withBucket(cfg, name, strings.ReplaceAll(cfg.TenantObjects.Bucket, tenantPlaceholder, tenant))
+		}
+		client, err := NewObjectClient(name, tenantCfg)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.TenantObjects.Prefix != "" {
+			client = util.NewPrefixedObjectClient(client, strings.ReplaceAll(cfg.TenantObjects.Prefix, tenantPlaceholder, tenant))
+		}
+		return objectclient.NewClient(client, chunkKeyEncoder(name)), nil
+	})
+}
+
+// validateTenantObjectsTenant checks that the tenant can be substituted in the templates of the tenant objects config,
+// for the chunks of a tenant not to be stored along the ones of other tenants or out of their bucket. The buckets
+// hold the lowercase letters, digits, dots and hyphens only.
+func validateTenantObjectsTenant(tenant string, bucket bool) error {
+	if tenant == "" || tenant == "." || strings.Contains(tenant, "..") || strings.ContainsAny(tenant, `/\`) {
+		return fmt.Errorf("invalid tenant %q for the tenant objects", tenant)
+	}
+	if !bucket {
+		return nil
+	}
+	for _, r := range tenant {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '.' && r != '-' {
+			return fmt.Errorf("invalid tenant %q for the bucket of the tenant objects: only the lowercase letters, digits, dots and hyphens are allowed", tenant)
+		}
+	}
+	return nil
+}
+
+// tenantChunkClient stores the chunks of each tenant with a chunk client of their own, made on their first use.
+type tenantChunkClient struct {
+	newClient func(tenant string) (chunk.Client, error)
+
+	mtx     sync.Mutex
+	clients map[string]chunk.Client
+}
+
+func newTenantChunkClient(newClient func(tenant string) (chunk.Client, error)) *tenantChunkClient {
+	return &tenantChunkClient{newClient: newClient, clients: map[string]chunk.Client{}}
+}
+
+func (c *tenantChunkClient) client(tenant string) (chunk.Client, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if client, ok := c.clients[tenant]; ok {
+		return client, nil
+	}
+	client, err := c.newClient(tenant)
+	if err != nil {
+		return nil, err
+	}
+	c.clients[tenant] = client
+	return client, nil
+}
+
+func (c *tenantChunkClient) Stop() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, client := range c.clients {
+		client.Stop()
+	}
+}
+
+func (c *tenantChunkClient) PutChunks(ctx context.Context, chunks []chunk.Chunk) error {
+	for tenant, tenantChunks := range chunksByTenant(chunks) {
+		client, err := c.client(tenant)
+		if err != nil {
+			return err
+		}
+		if err := client.PutChunks(ctx, tenantChunks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetChunks gets the chunks of each tenant with their client, returning the chunks it got along with the last error,
+// like the object clients.
+func (c *tenantChunkClient) GetChunks(ctx context.Context, chunks []chunk.Chunk) ([]chunk.Chunk, error) {
+	var (
+		result  = make([]chunk.Chunk, 0, len(chunks))
+		lastErr error
+	)
+	for tenant, tenantChunks := range chunksByTenant(chunks) {
+		client, err := c.client(tenant)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		found, err := client.GetChunks(ctx, tenantChunks)
+		if err != nil {
+			lastErr = err
+		}
+		result = append(result, found...)
+	}
+	return result, lastErr
+}
+
+func (c *tenantChunkClient) DeleteChunk(ctx context.Context, userID, chunkID string) error {
+	client, err := c.client(userID)
+	if err != nil {
+		return err
+	}
+	return client.DeleteChunk(ctx, userID, chunkID)
+}
+
+func chunksByTenant(chunks []chunk.Chunk) map[string][]chunk.Chunk {
+	result := map[string][]chunk.Chunk{}
+	for _, c := range chunks {
+		result[c.UserID] = append(result[c.UserID], c)
+	}
+	return result
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	cortex_local "github.com/cortexproject/cortex/pkg/chunk/local"
+	"github.com/cortexproject/cortex/pkg/chunk/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/famarks/loki/pkg/logproto"
+	"github.com/famarks/loki/pkg/storage/local"
+)
+
+func TestTenantObjectsChunkClient(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "tenant-objects")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	cfg := Config{TenantObjects: TenantObjectsConfig{Prefix: "chunks/", Bucket: filepath.Join(tempDir, "{tenant}")}}
+	require.NoError(t, cfg.TenantObjects.Validate())
+	client, err := NewChunkClient(local.ObjectStoreType, cfg, chunk.SchemaConfig{}, nil)
+	require.NoError(t, err)
+	defer client.Stop()
+
+	fake := newChunk(logproto.Stream{Labels: `{app="foo"}`, Entries: []logproto.Entry{{Timestamp: from, Line: "1"}}})
+	other := chunk.NewChunk("other", fake.Fingerprint, fake.Metric, fake.Data, fake.From, fake.Through)
+	require.NoError(t, other.Encode())
+	require.NoError(t, client.PutChunks(context.Background(), []chunk.Chunk{fake, other}))
+
+	// the chunks of each tenant are in their directory.
+	for _, c := range []chunk.Chunk{fake, other} {
+		files, err := ioutil.ReadDir(filepath.Join(tempDir, c.UserID, "chunks"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+	}
+
+	refs := make([]chunk.Chunk, 0, 2)
+	for _, c := range []chunk.Chunk{fake, other} {
+		ref, err := chunk.ParseExternalKey(c.UserID, c.ExternalKey())
+		require.NoError(t, err)
+		refs = append(refs, ref)
+	}
+	chunks, err := client.GetChunks(context.Background(), refs)
+	require.NoError(t, err)
+	require.Len(t, chunks, 2)
+
+	require.NoError(t, client.DeleteChunk(context.Background(), "other", other.ExternalKey()))
+	_, err = client.GetChunks(context.Background(), refs[1:])
+	require.Error(t, err)
+	chunks, err = client.GetChunks(context.Background(), refs[:1])
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+}
+
+func TestTenantObjectsConfig_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cfg      TenantObjectsConfig
+		expected error
+	}{
+		{name: "disabled"},
+		{name: "prefix", cfg: TenantObjectsConfig{Prefix: "{tenant}/"}},
+		{name: "bucket", cfg: TenantObjectsConfig{Prefix: "chunks/", Bucket: "loki-{tenant}"}},
+		{name: "no placeholder", cfg: TenantObjectsConfig{Prefix: "chunks/"}, expected: errTenantObjectsPlaceholder},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.cfg.Validate())
+		})
+	}
+}
+
+func TestTenantObjectsChunkClient_PathTraversal(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "tenant-objects")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	}()
+
+	for _, cfg := range []TenantObjectsConfig{
+		{Prefix: "{tenant}/chunks/"},
+		{Prefix: "chunks/", Bucket: filepath.Join(tempDir, "buckets", "{tenant}")},
+	} {
+		client, err := NewChunkClient(local.ObjectStoreType, Config{Config: storage.Config{FSConfig: cortex_local.FSConfig{Directory: filepath.Join(tempDir, "shared")}}, TenantObjects: cfg}, chunk.SchemaConfig{}, nil)
+		require.NoError(t, err)
+
+		fake := newChunk(logproto.Stream{Labels: `{app="foo"}`, Entries: []logproto.Entry{{Timestamp: from, Line: "1"}}})
+		for _, tenant := range []string{"../other", "..", "a/b", `a\b`} {
+			c := chunk.NewChunk(tenant, fake.Fingerprint, fake.Metric, fake.Data, fake.From, fake.Through)
+			require.NoError(t, c.Encode())
+			require.Error(t, client.PutChunks(context.Background(), []chunk.Chunk{c}))
+			require.Error(t, client.DeleteChunk(context.Background(), tenant, c.ExternalKey()))
+		}
+		client.Stop()
+	}
+
+	// nothing was written out of the directories of the tenants.
+	_, err = os.Stat(filepath.Join(tempDir, "other"))
+	require.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(tempDir, "buckets"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestValidateTenantObjectsTenant(t *testing.T) {
+	for _, tc := range []struct {
+		tenant    string
+		bucket    bool
+		expectErr bool
+	}{
+		{tenant: "fake"},
+		{tenant: "Tenant_1"},
+		{tenant: "tenant-1.prod", bucket: true},
+		{tenant: "Tenant_1", bucket: true, expectErr: true},
+		{tenant: "", expectErr: true},
+		{tenant: ".", expectErr: true},
+		{tenant: "..", expectErr: true},
+		{tenant: "../other", expectErr: true},
+		{tenant: "a..b", bucket: true, expectErr: true},
+		{tenant: "a/b", expectErr: true},
+		{tenant: `a\b`, expectErr: true},
+	} {
+		t.Run(tc.tenant, func(t *testing.T) {
+			err := validateTenantObjectsTenant(tc.tenant, tc.bucket)
+			if tc.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"flag"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	pkg_util "github.com/cortexproject/cortex/pkg/util"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/weaveworks/common/user"
+)
+
+// WriteBehindConfig configures the queue of the chunk writes of the WriteBehindStore.
+type WriteBehindConfig struct {
+	Enabled     bool                   `yaml:"enabled"`
+	QueueSize   int                    `yaml:"queue_size"`
+	Concurrency int                    `yaml:"concurrency"`
+	Timeout     time.Duration          `yaml:"timeout"`
+	Backoff     pkg_util.BackoffConfig `yaml:"backoff_config"`
+}
+
+// RegisterFlags registers flags.
+func (cfg *WriteBehindConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "store.write-behind.enabled", false, "Queue the chunk writes and write them to the store in the background with a bounded concurrency and retries, the writes returning once the chunks are written.")
+	f.IntVar(&cfg.QueueSize, "store.write-behind.queue-size", 1000, "Maximum number of queued writes, further writes wait for the queue to have room.")
+	f.IntVar(&cfg.Concurrency, "store.write-behind.concurrency", 16, "Number of queued writes written to the store concurrently.")
+	f.DurationVar(&cfg.Timeout, "store.write-behind.timeout", time.Minute, "Timeout of each attempt to write a queued write.")
+	cfg.Backoff.RegisterFlags("store.write-behind", f)
+}
+
+type writeBehindRequest struct {
+	userID        string
+	from, through model.Time
+	chunks        []chunk.Chunk
+	// putOne is set for the requests of PutOne, which writes the chunk between from and through.
+	putOne bool
+	// done receives the result of the write.
+	done chan error
+}
+
+// WriteBehindStore queues the chunk writes and writes them to the store in the background with a bounded concurrency,
+// retrying the failed writes, so that the latency spikes of the store are absorbed by the retries instead of failing
+// the flushes of the callers.
+// The writes are only acknowledged once the chunks are written, so that the ingesters don't mark chunks as flushed
+// before they are durable. The writes still failing after the retries return their error, for the ingesters to flush
+// the chunks again, and are counted by the loki_store_write_behind_failures_total metric. The queued writes are lost
+// on a crash, but were not acknowledged: their chunks are flushed again once the ingester recovered them from its WAL.
+type WriteBehindStore struct {
+	chunk.Store
+	cfg WriteBehindConfig
+
+	queue chan writeBehindRequest
+	wg    sync.WaitGroup
+	// mtx guards the queue from being closed while writes are enqueued.
+	mtx  sync.RWMutex
+	quit bool
+
+	queueLength prometheus.Gauge
+	retries     prometheus.Counter
+	failures    prometheus.Counter
+}
+
+// NewWriteBehindStore makes a new WriteBehindStore writing to the store.
+func NewWriteBehindStore(cfg WriteBehindConfig, store chunk.Store, registerer prometheus.Registerer) *WriteBehindStore {
+	s := &WriteBehindStore{
+		Store: store,
+		cfg:   cfg,
+		queue: make(chan writeBehindRequest, cfg.QueueSize),
+
+		queueLength: promauto.With(registerer).NewGauge(prometheus.GaugeOpts{
+			Namespace: "loki",
+			Name:      "store_write_behind_queue_length",
+			Help:      "The number of queued chunk writes.",
+		}),
+		retries: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "store_write_behind_retries_total",
+			Help:      "The total number of retried chunk writes.",
+		}),
+		failures: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "store_write_behind_failures_total",
+			Help:      "The total number of chunks whose write failed after all the retries, the failure being returned to the caller.",
+		}),
+	}
+
+	s.wg.Add(cfg.Concurrency)
+	for i := 0; i < cfg.Concurrency; i++ {
+		go s.loop()
+	}
+	return s
+}
+
+// Put implements chunk.Store, it returns once the chunks are written.
+func (s *WriteBehindStore) Put(ctx context.Context, chunks []chunk.Chunk) error {
+	return s.enqueue(ctx, writeBehindRequest{chunks: chunks})
+}
+
+// PutOne implements chunk.Store, it returns once the chunk is written.
+func (s *WriteBehindStore) PutOne(ctx context.Context, from, through model.Time, chk chunk.Chunk) error {
+	return s.enqueue(ctx, writeBehindRequest{from: from, through: through, chunks: []chunk.Chunk{chk}, putOne: true})
+}
+
+// enqueue queues the write and waits for its result. When the context is done first, the write can still complete in
+// the background: the chunks are then written twice once the caller retries, under the same keys.
+func (s *WriteBehindStore) enqueue(ctx context.Context, req writeBehindRequest) error {
+	userID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return err
+	}
+	req.userID = userID
+	req.done = make(chan error, 1)
+
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	if s.quit {
+		// the queue is closed, the write is done right away.
+		return s.put(ctx, req)
+	}
+
+	s.queueLength.Inc()
+	select {
+	case s.queue <- req:
+	case <-ctx.Done():
+		s.queueLength.Dec()
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *WriteBehindStore) loop() {
+	defer s.wg.Done()
+
+	for req := range s.queue {
+		s.queueLength.Dec()
+		req.done <- s.write(req)
+	}
+}
+
+func (s *WriteBehindStore) write(req writeBehindRequest) error {
+	backoff := pkg_util.NewBackoff(context.Background(), s.cfg.Backoff)
+	var err error
+	for backoff.Ongoing() {
+		ctx, cancel := context.WithTimeout(user.InjectOrgID(context.Background(), req.userID), s.cfg.Timeout)
+		err = s.put(ctx, req)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		level.Warn(pkg_util.Logger).Log("msg", "failed to write queued chunks, retrying", "user", req.userID, "chunks", len(req.chunks), "err", err)
+		s.retries.Inc()
+		backoff.Wait()
+	}
+
+	level.Error(pkg_util.Logger).Log("msg", "failed to write queued chunks after all the retries", "user", req.userID, "chunks", len(req.chunks), "err", err)
+	s.failures.Add(float64(len(req.chunks)))
+	return err
+}
+
+func (s *WriteBehindStore) put(ctx context.Context, req writeBehindRequest) error {
+	if req.putOne {
+		return s.Store.PutOne(ctx, req.from, req.through, req.chunks[0])
+	}
+	return s.Store.Put(ctx, req.chunks)
+}
+
+// Stop writes the queued writes before stopping the store.
+func (s *WriteBehindStore) Stop() {
+	s.mtx.Lock()
+	if !s.quit {
+		s.quit = true
+		close(s.queue)
+	}
+	s.mtx.Unlock()
+
+	s.wg.Wait()
+	s.Store.Stop()
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/chunk"
+	pkg_util "github.com/cortexproject/cortex/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+)
+
+// failingPutStore fails the first writes of each chunk and blocks the writes until unblocked.
+type failingPutStore struct {
+	chunk.Store
+	failures int
+
+	unblock chan struct{}
+	blocked int32
+	mtx     sync.Mutex
+	tries   map[string]int
+	written []string
+	stopped bool
+}
+
+func (s *failingPutStore) Put(ctx context.Context, chunks []chunk.Chunk) error {
+	atomic.AddInt32(&s.blocked, 1)
+	<-s.unblock
+
+	if _, err := user.ExtractOrgID(ctx); err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, chk := range chunks {
+		s.tries[chk.ExternalKey()]++
+		if s.tries[chk.ExternalKey()] <= s.failures {
+			return errors.New("failed")
+		}
+	}
+	for _, chk := range chunks {
+		s.written = append(s.written, chk.ExternalKey())
+	}
+	return nil
+}
+
+func (s *failingPutStore) Stop() {
+	s.stopped = true
+}
+
+func TestWriteBehindStore(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		failures   int
+		maxRetries int
+		written    int
+	}{
+		{name: "written", failures: 0, maxRetries: 3, written: 3},
+		{name: "retried", failures: 2, maxRetries: 3, written: 3},
+		{name: "failed", failures: 3, maxRetries: 3, written: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			inner := &failingPutStore{failures: tc.failures, unblock: make(chan struct{}), tries: map[string]int{}}
+			store := NewWriteBehindStore(WriteBehindConfig{
+				QueueSize:   1,
+				Concurrency: 2,
+				Timeout:     time.Second,
+				Backoff:     pkg_util.BackoffConfig{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond, MaxRetries: tc.maxRetries},
+			}, inner, prometheus.NewRegistry())
+
+			ctx := user.InjectOrgID(context.Background(), "fake")
+			var (
+				keys []string
+				errs = make(chan error, 3)
+			)
+			for i := 0; i < 3; i++ {
+				chk := newChunk(buildTestStreams(fooLabelsWithName, timeRange{from: time.Unix(0, 0).Add(time.Duration(i) * time.Hour), to: time.Unix(0, 0).Add(time.Duration(i+1) * time.Hour)}))
+				keys = append(keys, chk.ExternalKey())
+				go func() {
+					errs <- store.Put(ctx, []chunk.Chunk{chk})
+				}()
+			}
+
+			// the writes are not acknowledged while the store is blocked, two of them being taken off the queue by the
+			// workers and the third one filling the queue.
+			require.Eventually(t, func() bool {
+				return atomic.LoadInt32(&inner.blocked) == 2 && len(store.queue) == 1
+			}, time.Second, time.Millisecond)
+			require.Len(t, errs, 0)
+
+			// further writes wait for the queue to have room.
+			timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			defer cancel()
+			require.Equal(t, context.DeadlineExceeded, store.Put(timeoutCtx, []chunk.Chunk{newChunk(buildTestStreams(fooLabelsWithName, timeRange{from: time.Unix(0, 0), to: time.Unix(10, 0)}))}))
+
+			require.Error(t, store.Put(context.Background(), nil))
+
+			// the writes return once the chunks are written, or with the error of the last retry.
+			close(inner.unblock)
+			for i := 0; i < 3; i++ {
+				err := <-errs
+				if tc.written > 0 {
+					require.NoError(t, err)
+				} else {
+					require.Error(t, err)
+				}
+			}
+
+			store.Stop()
+			require.True(t, inner.stopped)
+
+			require.Len(t, inner.written, tc.written)
+			if tc.written > 0 {
+				require.ElementsMatch(t, keys, inner.written)
+			}
+			require.Equal(t, float64(3-tc.written), testutil.ToFloat64(store.failures))
+			require.Equal(t, float64(0), testutil.ToFloat64(store.queueLength))
+		})
+	}
+}
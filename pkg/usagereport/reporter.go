@@ -0,0 +1,189 @@
+package usagereport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/famarks/loki/pkg/build"
+)
+
+// Config configures the usage reporter.
+type Config struct {
+	Enabled     bool             `yaml:"enabled"`
+	URL         flagext.URLValue `yaml:"url"`
+	Interval    time.Duration    `yaml:"interval"`
+	Timeout     time.Duration    `yaml:"timeout"`
+	ClusterName string           `yaml:"cluster_name"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "usage-report.enabled", false, "Periodically send a report of the usage of the instance to the usage report URL. The report has no tenant, label or address.")
+	f.Var(&cfg.URL, "usage-report.url", "URL the usage reports are sent to, as JSON in POST requests.")
+	f.DurationVar(&cfg.Interval, "usage-report.interval", time.Hour, "Interval at which the usage reports are sent.")
+	f.DurationVar(&cfg.Timeout, "usage-report.timeout", 10*time.Second, "Timeout for the requests sending the usage reports.")
+	f.StringVar(&cfg.ClusterName, "usage-report.cluster-name", "", "Name of the cluster the instance is part of, for the reports of the instances of the cluster to be grouped.")
+}
+
+// Validate checks the config is usable.
+func (cfg *Config) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.URL.URL == nil {
+		return errors.New("the usage report url must be configured when the usage report is enabled")
+	}
+	if cfg.Interval <= 0 {
+		return errors.New("the usage report interval must be positive")
+	}
+	return nil
+}
+
+// Report is the usage of an instance sent to the usage report URL.
+type Report struct {
+	ClusterName string    `json:"clusterName"`
+	InstanceID  string    `json:"instanceID"`
+	Timestamp   time.Time `json:"timestamp"`
+	Uptime      float64   `json:"uptimeSeconds"`
+
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`
+	GoVersion string `json:"goVersion"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+
+	// Target is the target of the instance, the components of the cluster being counted by the targets of its
+	// instances.
+	Target        string          `json:"target"`
+	ChunkEncoding string          `json:"chunkEncoding,omitempty"`
+	Features      map[string]bool `json:"features"`
+	// Stats are the values of the usage at the time of the report, like the ingest volume.
+	Stats map[string]float64 `json:"stats"`
+}
+
+// Reporter is the service periodically sending the usage report of the instance, starting from the one of its
+// config and adding the stats at the time of each report.
+type Reporter struct {
+	services.Service
+
+	cfg     Config
+	report  Report
+	stats   func() map[string]float64
+	client  *http.Client
+	started time.Time
+	logger  log.Logger
+
+	reports *prometheus.CounterVec
+}
+
+// NewReporter makes a new Reporter.
+func NewReporter(cfg Config, report Report, stats func() map[string]float64, reg prometheus.Registerer, logger log.Logger) *Reporter {
+	report.ClusterName = cfg.ClusterName
+	// The instance is identified by a random ID, its name or address possibly being sensitive.
+	report.InstanceID = fmt.Sprintf("%016x", rand.New(rand.NewSource(time.Now().UnixNano())).Uint64())
+	report.Version = build.Version
+	report.Revision = build.Revision
+	report.GoVersion = runtime.Version()
+	report.OS = runtime.GOOS
+	report.Arch = runtime.GOARCH
+
+	r := &Reporter{
+		cfg:    cfg,
+		report: report,
+		stats:  stats,
+		client: &http.Client{Timeout: cfg.Timeout},
+		logger: log.With(logger, "component", "usage-report"),
+		reports: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "loki",
+			Name:      "usage_reports_total",
+			Help:      "Total number of usage reports sent, by status.",
+		}, []string{"status"}),
+	}
+	r.Service = services.NewTimerService(cfg.Interval, r.starting, r.iteration, nil)
+	return r
+}
+
+func (r *Reporter) starting(_ context.Context) error {
+	r.started = time.Now()
+	return nil
+}
+
+func (r *Reporter) iteration(ctx context.Context) error {
+	if err := r.send(ctx); err != nil {
+		r.reports.WithLabelValues("failure").Inc()
+		level.Warn(r.logger).Log("msg", "failed to send usage report", "err", err)
+		return nil
+	}
+	r.reports.WithLabelValues("success").Inc()
+	return nil
+}
+
+func (r *Reporter) send(ctx context.Context) error {
+	report := r.report
+	report.Timestamp = time.Now()
+	report.Uptime = time.Since(r.started).Seconds()
+	report.Stats = r.stats()
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", r.cfg.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		buf, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("server returned HTTP status %s: %s", resp.Status, strings.TrimSpace(string(buf)))
+	}
+	return nil
+}
+
+// SumCounters returns the sum of the series of each of the counters gathered, by name. The counters not gathered are
+// missing.
+func SumCounters(g prometheus.Gatherer, names ...string) (map[string]float64, error) {
+	families, err := g.Gather()
+	if err != nil {
+		return nil, err
+	}
+	sums := map[string]float64{}
+	for _, family := range families {
+		if family.GetType() != dto.MetricType_COUNTER {
+			continue
+		}
+		for _, name := range names {
+			if family.GetName() != name {
+				continue
+			}
+			for _, m := range family.GetMetric() {
+				sums[name] += m.GetCounter().GetValue()
+			}
+		}
+	}
+	return sums, nil
+}
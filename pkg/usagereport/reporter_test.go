@@ -0,0 +1,90 @@
+package usagereport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReporter(t *testing.T) {
+	reports := make(chan Report, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "POST", r.Method)
+		var report Report
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&report))
+		reports <- report
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	cfg := Config{
+		Enabled:     true,
+		URL:         flagext.URLValue{URL: u},
+		Interval:    10 * time.Millisecond,
+		Timeout:     time.Second,
+		ClusterName: "prod",
+	}
+	require.NoError(t, cfg.Validate())
+
+	reg := prometheus.NewRegistry()
+	r := NewReporter(cfg, Report{
+		Target:        "ingester",
+		ChunkEncoding: "snappy",
+		Features:      map[string]bool{"auth": true},
+	}, func() map[string]float64 {
+		return map[string]float64{"ingesters": 3}
+	}, reg, log.NewNopLogger())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	var report Report
+	select {
+	case report = <-reports:
+	case <-time.After(time.Second):
+		t.Fatal("no usage report sent")
+	}
+	require.Equal(t, "prod", report.ClusterName)
+	require.Len(t, report.InstanceID, 16)
+	require.Equal(t, "ingester", report.Target)
+	require.Equal(t, "snappy", report.ChunkEncoding)
+	require.Equal(t, map[string]bool{"auth": true}, report.Features)
+	require.Equal(t, map[string]float64{"ingesters": 3}, report.Stats)
+	require.NotEmpty(t, report.GoVersion)
+	require.False(t, report.Timestamp.IsZero())
+
+	// The instance keeps its ID.
+	require.Equal(t, report.InstanceID, (<-reports).InstanceID)
+	require.GreaterOrEqual(t, testutil.ToFloat64(r.reports.WithLabelValues("success")), 1.0)
+}
+
+func TestSumCounters(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	bytes := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "bytes_total"}, []string{"tenant"})
+	streams := prometheus.NewGauge(prometheus.GaugeOpts{Name: "streams"})
+	reg.MustRegister(bytes, streams)
+	bytes.WithLabelValues("tenant1").Add(10)
+	bytes.WithLabelValues("tenant2").Add(5)
+	streams.Set(3)
+
+	// Only the counters are summed, the ones not gathered being missing.
+	sums, err := SumCounters(reg, "bytes_total", "streams", "lines_total")
+	require.NoError(t, err)
+	require.Equal(t, map[string]float64{"bytes_total": 15}, sums)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	require.NoError(t, (&Config{}).Validate())
+	require.EqualError(t, (&Config{Enabled: true, Interval: time.Hour}).Validate(), "the usage report url must be configured when the usage report is enabled")
+}
@@ -0,0 +1,18 @@
+package util
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ansiEscapeSequence matches CSI sequences (colors, cursor movements...), OSC sequences
+// terminated by BEL or ST, and other two characters escape sequences such as RIS (ESC c).
+var ansiEscapeSequence = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[0-~])`)
+
+// StripANSI removes ANSI escape sequences from s.
+func StripANSI(s string) string {
+	if strings.IndexByte(s, '\x1b') < 0 {
+		return s
+	}
+	return ansiEscapeSequence.ReplaceAllString(s, "")
+}
@@ -0,0 +1,23 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripANSI(t *testing.T) {
+	for input, expected := range map[string]string{
+		"no escape sequences":                                         "no escape sequences",
+		"\x1b[31mred\x1b[0m text":                                     "red text",
+		"\x1b[1;32;40mbold green\x1b[m":                               "bold green",
+		"\x1b[2K\x1b[1Gprogress":                                      "progress",
+		"\x1b]0;window title\x07after osc":                            "after osc",
+		"\x1b]8;;http://example.com\x1b\\link\x1b]8;;\x1b\\":          "link",
+		"reset \x1bc done":                                            "reset  done",
+		"keypad \x1b= mode":                                           "keypad  mode",
+		"level=\x1b[33mwarn\x1b[0m msg=\"\x1b[4munderlined\x1b[24m\"": "level=warn msg=\"underlined\"",
+	} {
+		assert.Equal(t, expected, StripANSI(input), input)
+	}
+}
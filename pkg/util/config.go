@@ -3,6 +3,7 @@ package util
 import (
 	"fmt"
 	"io"
+	"reflect"
 	"strings"
 
 	"github.com/cortexproject/cortex/pkg/util"
@@ -37,3 +38,45 @@ func PrintConfig(w io.Writer, config interface{}) error {
 	fmt.Fprintf(w, "---\n# Loki Config\n# %s\n%s\n\n", version.Info(), string(lc))
 	return nil
 }
+
+// YAMLMarshalUnmarshal marshals a config object to YAML and unmarshals it back to a generic map, for it to be compared
+// with DiffConfig.
+func YAMLMarshalUnmarshal(in interface{}) (map[interface{}]interface{}, error) {
+	yamlBytes, err := yaml.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[interface{}]interface{})
+	if err := yaml.Unmarshal(yamlBytes, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DiffConfig returns the values of the actual config which differ from the default config, both being maps returned by
+// YAMLMarshalUnmarshal. Nested maps are compared key by key, every other value as a whole.
+func DiffConfig(defaultConfig, actualConfig map[interface{}]interface{}) map[interface{}]interface{} {
+	output := make(map[interface{}]interface{})
+	for key, value := range actualConfig {
+		defaultValue, ok := defaultConfig[key]
+		if !ok {
+			output[key] = value
+			continue
+		}
+
+		actualMap, actualIsMap := value.(map[interface{}]interface{})
+		defaultMap, defaultIsMap := defaultValue.(map[interface{}]interface{})
+		if actualIsMap && defaultIsMap {
+			if diff := DiffConfig(defaultMap, actualMap); len(diff) > 0 {
+				output[key] = diff
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(value, defaultValue) {
+			output[key] = value
+		}
+	}
+	return output
+}
@@ -0,0 +1,30 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffConfig(t *testing.T) {
+	type nested struct {
+		A string `yaml:"a"`
+		B []int  `yaml:"b"`
+	}
+	type config struct {
+		Name   string `yaml:"name"`
+		Nested nested `yaml:"nested"`
+		Other  nested `yaml:"other"`
+	}
+
+	defaults, err := YAMLMarshalUnmarshal(config{Name: "loki", Nested: nested{A: "a", B: []int{1}}})
+	require.NoError(t, err)
+	actual, err := YAMLMarshalUnmarshal(config{Name: "loki", Nested: nested{A: "b", B: []int{1}}, Other: nested{B: []int{1, 2}}})
+	require.NoError(t, err)
+
+	require.Equal(t, map[interface{}]interface{}{
+		"nested": map[interface{}]interface{}{"a": "b"},
+		"other":  map[interface{}]interface{}{"b": []interface{}{1, 2}},
+	}, DiffConfig(defaults, actual))
+	require.Empty(t, DiffConfig(defaults, defaults))
+}
@@ -0,0 +1,41 @@
+package grpcclient
+
+import (
+	"flag"
+
+	"github.com/cortexproject/cortex/pkg/util/grpcclient"
+	"github.com/go-kit/kit/log"
+	"google.golang.org/grpc"
+
+	"github.com/famarks/loki/pkg/util/tls"
+)
+
+// Config is the config of a gRPC client, the cortex one with the TLS config of the connections.
+type Config struct {
+	grpcclient.Config `yaml:",inline"`
+
+	TLS tls.ClientConfig `yaml:",inline"`
+}
+
+// RegisterFlagsWithPrefix registers flags with prefix.
+func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	cfg.Config.RegisterFlagsWithPrefix(prefix, f)
+	cfg.TLS.RegisterFlagsWithPrefix(prefix, f)
+}
+
+// Validate checks the config is usable.
+func (cfg *Config) Validate(log log.Logger) error {
+	if err := cfg.Config.Validate(log); err != nil {
+		return err
+	}
+	return cfg.TLS.Validate()
+}
+
+// DialOption returns the config as grpc.DialOptions, including the transport credentials.
+func (cfg *Config) DialOption(unaryClientInterceptors []grpc.UnaryClientInterceptor, streamClientInterceptors []grpc.StreamClientInterceptor) ([]grpc.DialOption, error) {
+	opts, err := cfg.TLS.GetGRPCDialOptions()
+	if err != nil {
+		return nil, err
+	}
+	return append(opts, cfg.Config.DialOption(unaryClientInterceptors, streamClientInterceptors)...), nil
+}
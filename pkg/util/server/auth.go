@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/pkg/errors"
+	"github.com/weaveworks/common/middleware"
+	serverww "github.com/weaveworks/common/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// BasicAuthConfig configures the credentials required by a server, basic auth being disabled when the username is empty.
+type BasicAuthConfig struct {
+	Username     string         `yaml:"username"`
+	Password     flagext.Secret `yaml:"password"`
+	PasswordFile string         `yaml:"password_file"`
+}
+
+// RegisterFlagsWithPrefix registers flags where every name is prefixed by prefix.
+func (cfg *BasicAuthConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Username, prefix+"basic-auth.username", "", "Username required by the server, basic auth being disabled when empty.")
+	f.Var(&cfg.Password, prefix+"basic-auth.password", "Password required by the server.")
+	f.StringVar(&cfg.PasswordFile, prefix+"basic-auth.password-file", "", "File containing the password required by the server.")
+}
+
+// BasicAuth checks the credentials of the requests of a server.
+type BasicAuth struct {
+	username string
+	password string
+}
+
+// NewBasicAuth returns the BasicAuth checking the credentials configured, or nil when basic auth is disabled.
+func NewBasicAuth(cfg BasicAuthConfig) (*BasicAuth, error) {
+	if cfg.Username == "" {
+		return nil, nil
+	}
+	password := cfg.Password.Value
+	if cfg.PasswordFile != "" {
+		if password != "" {
+			return nil, errors.New("at most one of basic auth password and password_file must be configured")
+		}
+		b, err := ioutil.ReadFile(cfg.PasswordFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading basic auth password file")
+		}
+		password = strings.TrimSpace(string(b))
+	}
+	return &BasicAuth{username: cfg.Username, password: password}, nil
+}
+
+func (a *BasicAuth) valid(username, password string) bool {
+	// Both are compared, for the time taken not to tell which one is wrong.
+	validUsername := subtle.ConstantTimeCompare([]byte(username), []byte(a.username)) == 1
+	validPassword := subtle.ConstantTimeCompare([]byte(password), []byte(a.password)) == 1
+	return validUsername && validPassword
+}
+
+// HTTPMiddleware returns the middleware rejecting the HTTP requests without the credentials.
+func (a *BasicAuth) HTTPMiddleware() middleware.Interface {
+	return middleware.Func(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			username, password, ok := req.BasicAuth()
+			if !ok || !a.valid(username, password) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	})
+}
+
+// AddToServerConfig adds the middleware and interceptors checking the credentials to the config of a server.
+func (a *BasicAuth) AddToServerConfig(cfg *serverww.Config) {
+	cfg.HTTPMiddleware = append(cfg.HTTPMiddleware, a.HTTPMiddleware())
+	cfg.GRPCMiddleware = append(cfg.GRPCMiddleware, a.GRPCUnaryInterceptor())
+	cfg.GRPCStreamMiddleware = append(cfg.GRPCStreamMiddleware, a.GRPCStreamInterceptor())
+}
+
+// GRPCUnaryInterceptor returns the interceptor rejecting the gRPC requests without the credentials.
+func (a *BasicAuth) GRPCUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := a.authorize(ctx); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// GRPCStreamInterceptor returns the interceptor rejecting the gRPC streams without the credentials.
+func (a *BasicAuth) GRPCStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := a.authorize(ss.Context()); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// authorize checks the credentials of the authorization metadata of a gRPC request.
+func (a *BasicAuth) authorize(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, auth := range md.Get("authorization") {
+		if !strings.HasPrefix(auth, "Basic ") {
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Basic "))
+		if err != nil {
+			continue
+		}
+		credentials := strings.SplitN(string(b), ":", 2)
+		if len(credentials) == 2 && a.valid(credentials[0], credentials[1]) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid basic auth credentials")
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestBasicAuth_Disabled(t *testing.T) {
+	a, err := NewBasicAuth(BasicAuthConfig{})
+	require.NoError(t, err)
+	require.Nil(t, a)
+}
+
+func TestBasicAuth_PasswordFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "basic-auth")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "password")
+	require.NoError(t, ioutil.WriteFile(file, []byte("secret\n"), 0600))
+
+	a, err := NewBasicAuth(BasicAuthConfig{Username: "admin", PasswordFile: file})
+	require.NoError(t, err)
+	require.True(t, a.valid("admin", "secret"))
+
+	_, err = NewBasicAuth(BasicAuthConfig{Username: "admin", Password: flagext.Secret{Value: "secret"}, PasswordFile: file})
+	require.EqualError(t, err, "at most one of basic auth password and password_file must be configured")
+}
+
+func TestBasicAuth_HTTPMiddleware(t *testing.T) {
+	a, err := NewBasicAuth(BasicAuthConfig{Username: "admin", Password: flagext.Secret{Value: "secret"}})
+	require.NoError(t, err)
+	handler := a.HTTPMiddleware().Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, err := w.Write([]byte("ok"))
+		require.NoError(t, err)
+	}))
+
+	for _, tc := range []struct {
+		desc     string
+		username string
+		password string
+		expected int
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"wrong password", "admin", "wrong", http.StatusUnauthorized},
+		{"wrong username", "user", "secret", http.StatusUnauthorized},
+		{"valid credentials", "admin", "secret", http.StatusOK},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://testing/ready", nil)
+			if tc.username != "" {
+				req.SetBasicAuth(tc.username, tc.password)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			require.Equal(t, tc.expected, w.Result().StatusCode)
+		})
+	}
+}
+
+func TestBasicAuth_GRPCUnaryInterceptor(t *testing.T) {
+	a, err := NewBasicAuth(BasicAuthConfig{Username: "admin", Password: flagext.Secret{Value: "secret"}})
+	require.NoError(t, err)
+	interceptor := a.GRPCUnaryInterceptor()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("admin:wrong"))))
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("admin:secret"))))
+	resp, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	require.Equal(t, "ok", resp)
+}
@@ -0,0 +1,92 @@
+package tls
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	cortex_tls "github.com/cortexproject/cortex/pkg/util/tls"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+var versions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+// ClientConfig is the TLS config of a client, adding to the cortex one the name of the server the certificate is
+// verified against and the minimum TLS version.
+type ClientConfig struct {
+	cortex_tls.ClientConfig `yaml:",inline"`
+
+	Enabled    bool   `yaml:"tls_enabled"`
+	ServerName string `yaml:"tls_server_name"`
+	MinVersion string `yaml:"tls_min_version"`
+}
+
+// RegisterFlagsWithPrefix registers flags with prefix.
+func (cfg *ClientConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	cfg.ClientConfig.RegisterFlagsWithPrefix(prefix, f)
+
+	f.BoolVar(&cfg.Enabled, prefix+".tls-enabled", false, "Enable TLS, validating the server certificate against the host's root CA certificates when no CA path is set. Implied when a certificate, key or CA path is set.")
+	f.StringVar(&cfg.ServerName, prefix+".tls-server-name", "", "Name of the server the certificate is validated against, instead of the host of the address dialed.")
+	f.StringVar(&cfg.MinVersion, prefix+".tls-min-version", "", fmt.Sprintf("Minimum TLS version accepted, one of %s. Defaults to the one of the Go version Loki is built with.", strings.Join(versionNames(), ", ")))
+}
+
+// Validate checks the config is usable.
+func (cfg *ClientConfig) Validate() error {
+	if cfg.MinVersion == "" {
+		return nil
+	}
+	if _, ok := versions[cfg.MinVersion]; !ok {
+		return fmt.Errorf("unknown TLS version %q, must be one of %s", cfg.MinVersion, strings.Join(versionNames(), ", "))
+	}
+	return nil
+}
+
+// GetTLSConfig returns the TLS config of the connections, nil when TLS isn't enabled.
+func (cfg *ClientConfig) GetTLSConfig() (*tls.Config, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	config, err := cfg.ClientConfig.GetTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		if !cfg.Enabled {
+			return nil, nil
+		}
+		config = &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	}
+	config.ServerName = cfg.ServerName
+	config.MinVersion = versions[cfg.MinVersion]
+	return config, nil
+}
+
+// GetGRPCDialOptions returns the transport credentials of the gRPC connections, which are insecure when TLS isn't
+// enabled.
+func (cfg *ClientConfig) GetGRPCDialOptions() ([]grpc.DialOption, error) {
+	config, err := cfg.GetTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return []grpc.DialOption{grpc.WithInsecure()}, nil
+	}
+	return []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(config))}, nil
+}
+
+func versionNames() []string {
+	names := make([]string, 0, len(versions))
+	for name := range versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,139 @@
+package tls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cortex_tls "github.com/cortexproject/cortex/pkg/util/tls"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestClientConfig_GetTLSConfig(t *testing.T) {
+	config, err := (&ClientConfig{ServerName: "ingester"}).GetTLSConfig()
+	require.NoError(t, err)
+	require.Nil(t, config)
+
+	config, err = (&ClientConfig{Enabled: true, ServerName: "ingester", MinVersion: "TLS13"}).GetTLSConfig()
+	require.NoError(t, err)
+	require.Equal(t, "ingester", config.ServerName)
+	require.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+
+	_, err = (&ClientConfig{Enabled: true, MinVersion: "TLS14"}).GetTLSConfig()
+	require.EqualError(t, err, `unknown TLS version "TLS14", must be one of TLS10, TLS11, TLS12, TLS13`)
+}
+
+func TestClientConfig_MutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ca := newCertificate(t, dir, "ca", nil)
+	server := newCertificate(t, dir, "server", ca)
+	client := newCertificate(t, dir, "client", ca)
+
+	serverCert, err := tls.LoadX509KeyPair(server.certPath, server.keyPath)
+	require.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})))
+	grpc_health_v1.RegisterHealthServer(s, health.NewServer())
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(l) //nolint:errcheck
+	defer s.Stop()
+
+	check := func(cfg ClientConfig) error {
+		opts, err := cfg.GetGRPCDialOptions()
+		require.NoError(t, err)
+		conn, err := grpc.Dial(l.Addr().String(), opts...)
+		require.NoError(t, err)
+		defer conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err = grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		return err
+	}
+
+	// The server certificate is issued for the server name, not the address dialed.
+	require.NoError(t, check(ClientConfig{
+		ClientConfig: cortex_tls.ClientConfig{CertPath: client.certPath, KeyPath: client.keyPath, CAPath: ca.certPath},
+		ServerName:   "server",
+		MinVersion:   "TLS12",
+	}))
+	require.Error(t, check(ClientConfig{
+		ClientConfig: cortex_tls.ClientConfig{CertPath: client.certPath, KeyPath: client.keyPath, CAPath: ca.certPath},
+	}))
+	// The server requires a client certificate.
+	require.Error(t, check(ClientConfig{
+		ClientConfig: cortex_tls.ClientConfig{CAPath: ca.certPath},
+		ServerName:   "server",
+	}))
+	require.Error(t, check(ClientConfig{}))
+}
+
+type certificate struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certPath string
+	keyPath  string
+}
+
+// newCertificate writes a certificate for the name, issued by the parent or self-signed CA one when it's nil.
+func newCertificate(t *testing.T, dir, name string, parent *certificate) *certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	issuer, issuerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		issuer, issuerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, issuerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	c := &certificate{
+		cert:     cert,
+		key:      key,
+		certPath: filepath.Join(dir, name+".crt"),
+		keyPath:  filepath.Join(dir, name+".key"),
+	}
+	require.NoError(t, ioutil.WriteFile(c.certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(c.keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return c
+}
@@ -0,0 +1,100 @@
+package validation
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/common/user"
+)
+
+// Discards keeps the breakdown of the entries discarded by this process.
+var Discards = NewDiscardTracker()
+
+// DiscardSummary describes the entries of a tenant discarded for a given reason.
+type DiscardSummary struct {
+	Reason  string `json:"reason"`
+	Entries int64  `json:"entries"`
+	Bytes   int64  `json:"bytes"`
+	// ExampleStream holds the labels of the last stream discarded for this reason.
+	ExampleStream string    `json:"example_stream,omitempty"`
+	LastSeen      time.Time `json:"last_seen"`
+}
+
+// DiscardTracker keeps track of discarded entries per tenant and reason, along
+// with an example of the offending stream, so operators can tell users why their
+// logs are missing.
+type DiscardTracker struct {
+	mtx     sync.Mutex
+	tenants map[string]map[string]*DiscardSummary
+	now     func() time.Time
+}
+
+// NewDiscardTracker makes a new DiscardTracker.
+func NewDiscardTracker() *DiscardTracker {
+	return &DiscardTracker{
+		tenants: map[string]map[string]*DiscardSummary{},
+		now:     time.Now,
+	}
+}
+
+// Record accounts entries and bytes discarded for the tenant, updating the
+// discarded samples and bytes metrics. stream may be empty when the discard
+// isn't tied to a single stream (e.g. rate limiting a whole push request).
+func (t *DiscardTracker) Record(reason, tenant, stream string, entries, bytes int) {
+	DiscardedSamples.WithLabelValues(reason, tenant).Add(float64(entries))
+	DiscardedBytes.WithLabelValues(reason, tenant).Add(float64(bytes))
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	reasons, ok := t.tenants[tenant]
+	if !ok {
+		reasons = map[string]*DiscardSummary{}
+		t.tenants[tenant] = reasons
+	}
+	summary, ok := reasons[reason]
+	if !ok {
+		summary = &DiscardSummary{Reason: reason}
+		reasons[reason] = summary
+	}
+	summary.Entries += int64(entries)
+	summary.Bytes += int64(bytes)
+	if stream != "" {
+		summary.ExampleStream = stream
+	}
+	summary.LastSeen = t.now()
+}
+
+// Tenant returns the discards of a tenant, sorted by reason.
+func (t *DiscardTracker) Tenant(tenant string) []DiscardSummary {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	reasons := t.tenants[tenant]
+	result := make([]DiscardSummary, 0, len(reasons))
+	for _, s := range reasons {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Reason < result[j].Reason })
+	return result
+}
+
+// ServeHTTP writes the discards of the tenant of the request as JSON. The
+// request must carry the tenant, as set by the auth middleware, for tenants not
+// to read the streams of each other.
+func (t *DiscardTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenant, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	result := map[string][]DiscardSummary{tenant: t.Tenant(tenant)}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
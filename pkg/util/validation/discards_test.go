@@ -0,0 +1,45 @@
+package validation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+)
+
+func TestDiscardTracker(t *testing.T) {
+	now := time.Unix(0, 0).UTC()
+	tracker := NewDiscardTracker()
+	tracker.now = func() time.Time { return now }
+
+	tracker.Record(LineTooLong, "1", `{app="foo"}`, 1, 100)
+	tracker.Record(LineTooLong, "1", `{app="bar"}`, 2, 50)
+	tracker.Record(RateLimited, "1", "", 10, 1000)
+	tracker.Record(OutOfOrder, "2", `{app="baz"}`, 3, 30)
+
+	require.Equal(t, []DiscardSummary{
+		{Reason: LineTooLong, Entries: 3, Bytes: 150, ExampleStream: `{app="bar"}`, LastSeen: now},
+		{Reason: RateLimited, Entries: 10, Bytes: 1000, LastSeen: now},
+	}, tracker.Tenant("1"))
+	require.Empty(t, tracker.Tenant("3"))
+
+	req := httptest.NewRequest(http.MethodGet, "/discards", nil)
+	rec := httptest.NewRecorder()
+	tracker.ServeHTTP(rec, req.WithContext(user.InjectOrgID(req.Context(), "2")))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var result map[string][]DiscardSummary
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	require.Equal(t, map[string][]DiscardSummary{
+		"2": {{Reason: OutOfOrder, Entries: 3, Bytes: 30, ExampleStream: `{app="baz"}`, LastSeen: now}},
+	}, result)
+
+	// the discards of the other tenants are never served.
+	rec = httptest.NewRecorder()
+	tracker.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/discards?tenant=1", nil))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,82 @@
+package validation
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AllTenantLimits is a function that returns the limits of all the tenants with tenant-specific limits.
+type AllTenantLimits func() map[string]*Limits
+
+// OverridesExporter exposes the limits of the tenants with tenant-specific limits, and the default limits applying to
+// the other tenants, as metrics.
+type OverridesExporter struct {
+	defaultLimits *Limits
+	tenantLimits  AllTenantLimits
+	overridesDesc *prometheus.Desc
+	defaultsDesc  *prometheus.Desc
+}
+
+// NewOverridesExporter makes a new OverridesExporter.
+func NewOverridesExporter(defaults Limits, tenantLimits AllTenantLimits) *OverridesExporter {
+	return &OverridesExporter{
+		defaultLimits: &defaults,
+		tenantLimits:  tenantLimits,
+		overridesDesc: prometheus.NewDesc(
+			"loki_overrides",
+			"Resource limit overrides applied to tenants.",
+			[]string{"limit_name", "user"},
+			nil,
+		),
+		defaultsDesc: prometheus.NewDesc(
+			"loki_overrides_defaults",
+			"Resource limits applied to the tenants without overrides.",
+			[]string{"limit_name"},
+			nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (oe *OverridesExporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- oe.overridesDesc
+	ch <- oe.defaultsDesc
+}
+
+// Collect implements prometheus.Collector.
+func (oe *OverridesExporter) Collect(ch chan<- prometheus.Metric) {
+	for name, value := range exportedLimits(oe.defaultLimits) {
+		ch <- prometheus.MustNewConstMetric(oe.defaultsDesc, prometheus.GaugeValue, value, name)
+	}
+
+	if oe.tenantLimits == nil {
+		return
+	}
+	for userID, limits := range oe.tenantLimits() {
+		if limits == nil {
+			continue
+		}
+		for name, value := range exportedLimits(limits) {
+			ch <- prometheus.MustNewConstMetric(oe.overridesDesc, prometheus.GaugeValue, value, name, userID)
+		}
+	}
+}
+
+// exportedLimits returns the limits a tenant can get close to, by their YAML name. Sizes are in bytes and durations
+// in seconds.
+func exportedLimits(l *Limits) map[string]float64 {
+	return map[string]float64{
+		"ingestion_rate_mb":              l.IngestionRateMB,
+		"ingestion_burst_size_mb":        l.IngestionBurstSizeMB,
+		"max_line_size":                  float64(l.MaxLineSize.Val()),
+		"max_label_names_per_series":     float64(l.MaxLabelNamesPerSeries),
+		"max_streams_per_user":           float64(l.MaxLocalStreamsPerUser),
+		"max_global_streams_per_user":    float64(l.MaxGlobalStreamsPerUser),
+		"max_chunks_per_query":           float64(l.MaxChunksPerQuery),
+		"max_query_length":               l.MaxQueryLength.Seconds(),
+		"max_query_parallelism":          float64(l.MaxQueryParallelism),
+		"max_streams_matchers_per_query": float64(l.MaxStreamsMatchersPerQuery),
+		"max_concurrent_tail_requests":   float64(l.MaxConcurrentTailRequests),
+		"max_entries_limit_per_query":    float64(l.MaxEntriesLimitPerQuery),
+		"retention_period":               l.RetentionPeriod.Seconds(),
+	}
+}
@@ -0,0 +1,55 @@
+package validation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverridesExporter(t *testing.T) {
+	defaults := Limits{
+		IngestionRateMB:         4,
+		MaxGlobalStreamsPerUser: 5000,
+		MaxQueryLength:          time.Hour,
+	}
+	tenant := defaults
+	tenant.MaxGlobalStreamsPerUser = 10000
+
+	exporter := NewOverridesExporter(defaults, func() map[string]*Limits {
+		return map[string]*Limits{"tenant1": &tenant, "tenant2": nil}
+	})
+
+	problems, err := testutil.CollectAndLint(exporter)
+	require.NoError(t, err)
+	require.Empty(t, problems)
+	require.Equal(t, 2*len(exportedLimits(&defaults)), testutil.CollectAndCount(exporter))
+
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(exporter))
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	values := map[string]float64{}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			key := family.GetName()
+			for _, l := range m.GetLabel() {
+				key += "," + l.GetValue()
+			}
+			values[key] = m.GetGauge().GetValue()
+		}
+	}
+	for key, expected := range map[string]float64{
+		"loki_overrides_defaults,ingestion_rate_mb":           4,
+		"loki_overrides_defaults,max_global_streams_per_user": 5000,
+		"loki_overrides_defaults,max_query_length":            3600,
+		"loki_overrides,ingestion_rate_mb,tenant1":            4,
+		"loki_overrides,max_global_streams_per_user,tenant1":  10000,
+		"loki_overrides,max_query_length,tenant1":             3600,
+	} {
+		require.Equal(t, expected, values[key], key)
+	}
+}
@@ -2,8 +2,12 @@ package validation
 
 import (
 	"flag"
+	"fmt"
 	"time"
 
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/famarks/loki/pkg/logql"
 	"github.com/famarks/loki/pkg/util/flagext"
 )
 
@@ -33,6 +37,11 @@ type Limits struct {
 	EnforceMetricName      bool             `yaml:"enforce_metric_name"`
 	MaxLineSize            flagext.ByteSize `yaml:"max_line_size"`
 
+	// Distributor line sanitization.
+	SanitizeStripANSI              bool `yaml:"sanitize_strip_ansi"`
+	SanitizeInvalidUTF8            bool `yaml:"sanitize_invalid_utf8"`
+	SanitizeTrimTrailingWhitespace bool `yaml:"sanitize_trim_trailing_whitespace"`
+
 	// Ingester enforced limits.
 	MaxLocalStreamsPerUser  int `yaml:"max_streams_per_user"`
 	MaxGlobalStreamsPerUser int `yaml:"max_global_streams_per_user"`
@@ -46,10 +55,15 @@ type Limits struct {
 	MaxConcurrentTailRequests  int           `yaml:"max_concurrent_tail_requests"`
 	MaxEntriesLimitPerQuery    int           `yaml:"max_entries_limit_per_query"`
 	MaxCacheFreshness          time.Duration `yaml:"max_cache_freshness_per_query"`
+	DisableChunkCache          bool          `yaml:"disable_chunk_cache"`
 
 	// Query frontend enforced limits. The default is actually parameterized by the queryrange config.
 	QuerySplitDuration time.Duration `yaml:"split_queries_by_interval"`
 
+	// Compactor enforced limits.
+	RetentionPeriod time.Duration     `yaml:"retention_period"`
+	StreamRetention []StreamRetention `yaml:"retention_stream"`
+
 	// Config for overrides, convenient if it goes here.
 	PerTenantOverrideConfig string        `yaml:"per_tenant_override_config"`
 	PerTenantOverridePeriod time.Duration `yaml:"per_tenant_override_period"`
@@ -66,8 +80,11 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.MaxLabelNamesPerSeries, "validation.max-label-names-per-series", 30, "Maximum number of label names per series.")
 	f.BoolVar(&l.RejectOldSamples, "validation.reject-old-samples", false, "Reject old samples.")
 	f.DurationVar(&l.RejectOldSamplesMaxAge, "validation.reject-old-samples.max-age", 14*24*time.Hour, "Maximum accepted sample age before rejecting.")
-	f.DurationVar(&l.CreationGracePeriod, "validation.create-grace-period", 10*time.Minute, "Duration which table will be created/deleted before/after it's needed; we won't accept sample from before this time.")
+	f.DurationVar(&l.CreationGracePeriod, "validation.create-grace-period", 10*time.Minute, "Maximum accepted timestamp in the future; entries newer than now plus this duration are rejected.")
 	f.BoolVar(&l.EnforceMetricName, "validation.enforce-metric-name", true, "Enforce every sample has a metric name.")
+	f.BoolVar(&l.SanitizeStripANSI, "distributor.sanitize-strip-ansi", false, "Strip ANSI escape sequences (e.g. colors) from log lines before they are validated and forwarded to ingesters.")
+	f.BoolVar(&l.SanitizeInvalidUTF8, "distributor.sanitize-invalid-utf8", false, "Replace invalid UTF-8 sequences in log lines with the Unicode replacement character.")
+	f.BoolVar(&l.SanitizeTrimTrailingWhitespace, "distributor.sanitize-trim-trailing-whitespace", false, "Trim trailing whitespace and newlines from log lines.")
 	f.IntVar(&l.MaxEntriesLimitPerQuery, "validation.max-entries-limit", 5000, "Per-user entries limit per query")
 
 	f.IntVar(&l.MaxLocalStreamsPerUser, "ingester.max-streams-per-user", 10e3, "Maximum number of active streams per user, per ingester. 0 to disable.")
@@ -79,12 +96,42 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.CardinalityLimit, "store.cardinality-limit", 1e5, "Cardinality limit for index queries.")
 	f.IntVar(&l.MaxStreamsMatchersPerQuery, "querier.max-streams-matcher-per-query", 1000, "Limit the number of streams matchers per query")
 	f.IntVar(&l.MaxConcurrentTailRequests, "querier.max-concurrent-tail-requests", 10, "Limit the number of concurrent tail requests")
+	f.BoolVar(&l.DisableChunkCache, "store.disable-chunk-cache", false, "Neither fetch the chunks of the tenant from the chunk cache nor store them in it.")
 	f.DurationVar(&l.MaxCacheFreshness, "frontend.max-cache-freshness", 1*time.Minute, "Most recent allowed cacheable result per-tenant, to prevent caching very recent results that might still be in flux.")
 
+	f.DurationVar(&l.RetentionPeriod, "store.retention", 0, "How long to keep the chunks of a tenant before the compactor deletes them, when compactor retention is enabled. 0 to disable.")
+
 	f.StringVar(&l.PerTenantOverrideConfig, "limits.per-user-override-config", "", "File name of per-user overrides.")
 	f.DurationVar(&l.PerTenantOverridePeriod, "limits.per-user-override-period", 10*time.Second, "Period with this to reload the overrides.")
 }
 
+// Validate validates that this limits config is valid.
+func (l *Limits) Validate() error {
+	switch l.IngestionRateStrategy {
+	case LocalIngestionRateStrategy, GlobalIngestionRateStrategy:
+	default:
+		return fmt.Errorf("unsupported ingestion rate strategy %q, supported values are %q and %q", l.IngestionRateStrategy, LocalIngestionRateStrategy, GlobalIngestionRateStrategy)
+	}
+
+	for i, rule := range l.StreamRetention {
+		matchers, err := logql.ParseMatchers(rule.Selector)
+		if err != nil {
+			return fmt.Errorf("invalid retention_stream selector %q: %w", rule.Selector, err)
+		}
+		l.StreamRetention[i].Matchers = matchers
+	}
+	return nil
+}
+
+// StreamRetention is a retention period applied to the streams matching a selector.
+// When several rules match a stream, the one with the highest priority wins.
+type StreamRetention struct {
+	Period   time.Duration     `yaml:"period"`
+	Priority int               `yaml:"priority"`
+	Selector string            `yaml:"selector"`
+	Matchers []*labels.Matcher `yaml:"-"` // populated by Validate
+}
+
 // UnmarshalYAML implements the yaml.Unmarshaler interface.
 func (l *Limits) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	// We want to set c to the defaults and then overwrite it with the input.
@@ -240,6 +287,21 @@ func (o *Overrides) MaxLineSize(userID string) int {
 	return o.getOverridesForUser(userID).MaxLineSize.Val()
 }
 
+// SanitizeStripANSI returns whether ANSI escape sequences should be removed from log lines.
+func (o *Overrides) SanitizeStripANSI(userID string) bool {
+	return o.getOverridesForUser(userID).SanitizeStripANSI
+}
+
+// SanitizeInvalidUTF8 returns whether invalid UTF-8 sequences should be replaced in log lines.
+func (o *Overrides) SanitizeInvalidUTF8(userID string) bool {
+	return o.getOverridesForUser(userID).SanitizeInvalidUTF8
+}
+
+// SanitizeTrimTrailingWhitespace returns whether trailing whitespace should be trimmed from log lines.
+func (o *Overrides) SanitizeTrimTrailingWhitespace(userID string) bool {
+	return o.getOverridesForUser(userID).SanitizeTrimTrailingWhitespace
+}
+
 // MaxEntriesLimitPerQuery returns the limit to number of entries the querier should return per query.
 func (o *Overrides) MaxEntriesLimitPerQuery(userID string) int {
 	return o.getOverridesForUser(userID).MaxEntriesLimitPerQuery
@@ -249,6 +311,21 @@ func (o *Overrides) MaxCacheFreshness(userID string) time.Duration {
 	return o.getOverridesForUser(userID).MaxCacheFreshness
 }
 
+// DisableChunkCache returns whether the chunks of a tenant should bypass the chunk cache.
+func (o *Overrides) DisableChunkCache(userID string) bool {
+	return o.getOverridesForUser(userID).DisableChunkCache
+}
+
+// RetentionPeriod returns the retention period of the chunks of a tenant.
+func (o *Overrides) RetentionPeriod(userID string) time.Duration {
+	return o.getOverridesForUser(userID).RetentionPeriod
+}
+
+// StreamRetention returns the retention rules applied to the streams of a tenant.
+func (o *Overrides) StreamRetention(userID string) []StreamRetention {
+	return o.getOverridesForUser(userID).StreamRetention
+}
+
 func (o *Overrides) getOverridesForUser(userID string) *Limits {
 	if o.tenantLimits != nil {
 		l := o.tenantLimits(userID)
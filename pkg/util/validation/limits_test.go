@@ -0,0 +1,45 @@
+package validation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimits_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		strategy string
+		err      bool
+	}{
+		{strategy: LocalIngestionRateStrategy},
+		{strategy: GlobalIngestionRateStrategy},
+		{strategy: "", err: true},
+		{strategy: "globl", err: true},
+	} {
+		t.Run(tc.strategy, func(t *testing.T) {
+			l := Limits{IngestionRateStrategy: tc.strategy}
+			err := l.Validate()
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestLimits_ValidateStreamRetention(t *testing.T) {
+	l := Limits{
+		IngestionRateStrategy: LocalIngestionRateStrategy,
+		StreamRetention: []StreamRetention{
+			{Period: 7 * 24 * time.Hour, Selector: `{namespace="dev"}`},
+		},
+	}
+	require.NoError(t, l.Validate())
+	require.Len(t, l.StreamRetention[0].Matchers, 1)
+	require.True(t, l.StreamRetention[0].Matchers[0].Matches("dev"))
+
+	l.StreamRetention = append(l.StreamRetention, StreamRetention{Period: time.Hour, Selector: `{namespace="dev"} |= "foo"`})
+	require.Error(t, l.Validate())
+}
@@ -23,10 +23,10 @@ const (
 	streamLimitErrorMsg = "Maximum active stream limit exceeded, reduce the number of active streams (reduce labels or reduce label values), or contact your Loki administrator to see if the limit can be increased"
 	// GreaterThanMaxSampleAge is a reason for discarding log lines which are older than the current time - `reject_old_samples_max_age`
 	GreaterThanMaxSampleAge         = "greater_than_max_sample_age"
-	greaterThanMaxSampleAgeErrorMsg = "entry for stream '%s' has timestamp too old: %v"
+	greaterThanMaxSampleAgeErrorMsg = "entry for stream '%s' has timestamp too old: %v, oldest acceptable timestamp is: %v"
 	// TooFarInFuture is a reason for discarding log lines which are newer than the current time + `creation_grace_period`
 	TooFarInFuture         = "too_far_in_future"
-	tooFarInFutureErrorMsg = "entry for stream '%s' has timestamp too new: %v"
+	tooFarInFutureErrorMsg = "entry for stream '%s' has timestamp too new: %v, newest acceptable timestamp is: %v"
 	// MaxLabelNamesPerSeries is a reason for discarding a log line which has too many label names
 	MaxLabelNamesPerSeries         = "max_label_names_per_series"
 	maxLabelNamesPerSeriesErrorMsg = "entry for stream '%s' has %d label names; limit %d"
@@ -36,6 +36,8 @@ const (
 	// LabelValueTooLong is a reason for discarding a log line which has a lable value too long
 	LabelValueTooLong         = "label_value_too_long"
 	labelValueTooLongErrorMsg = "stream '%s' has label value too long: '%s'"
+	// OutOfOrder is a reason for discarding log lines older than the last line of their stream
+	OutOfOrder = "out_of_order"
 	// DuplicateLabelNames is a reason for discarding a log line which has duplicate label names
 	DuplicateLabelNames         = "duplicate_label_names"
 	duplicateLabelNamesErrorMsg = "stream '%s' has duplicate label name: '%s'"
@@ -81,13 +83,13 @@ func StreamLimitErrorMsg() string {
 }
 
 // GreaterThanMaxSampleAgeErrorMsg returns an error string for a line with a timestamp too old
-func GreaterThanMaxSampleAgeErrorMsg(stream string, timestamp time.Time) string {
-	return fmt.Sprintf(greaterThanMaxSampleAgeErrorMsg, stream, timestamp)
+func GreaterThanMaxSampleAgeErrorMsg(stream string, timestamp, oldest time.Time) string {
+	return fmt.Sprintf(greaterThanMaxSampleAgeErrorMsg, stream, timestamp, oldest)
 }
 
 // TooFarInFutureErrorMsg returns an error string for a line with a timestamp too far in the future
-func TooFarInFutureErrorMsg(stream string, timestamp time.Time) string {
-	return fmt.Sprintf(tooFarInFutureErrorMsg, stream, timestamp)
+func TooFarInFutureErrorMsg(stream string, timestamp, newest time.Time) string {
+	return fmt.Sprintf(tooFarInFutureErrorMsg, stream, timestamp, newest)
 }
 
 // MaxLabelNamesPerSeriesErrorMsg returns an error string for a stream with too many labels